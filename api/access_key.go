@@ -1,6 +1,9 @@
 package api
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/uid"
 )
@@ -12,14 +15,18 @@ type AccessKey struct {
 	IssuedForName     string `json:"issuedForName"`
 	IssuedFor         uid.ID `json:"issuedFor"`
 	ProviderID        uid.ID `json:"providerID"`
+	CreatedBy         uid.ID `json:"createdBy,omitempty" note:"ID of the user who created the key, when it was created using the API"`
 	Expires           Time   `json:"expires" note:"key is no longer valid after this time"`
 	ExtensionDeadline Time   `json:"extensionDeadline" note:"key must be used within this duration to remain valid"`
+	LastUsed          Time   `json:"lastUsed" note:"approximate time the key was last used to authenticate"`
 }
 
 type ListAccessKeysRequest struct {
 	UserID      uid.ID `form:"user_id"`
+	ProviderID  uid.ID `form:"provider_id"`
 	Name        string `form:"name"`
 	ShowExpired bool   `form:"show_expired"`
+	Cursor      string `form:"cursor" note:"cursor from a previous response, used instead of page. When page and cursor are omitted the response includes the cursor of the next page"`
 	PaginationRequest
 }
 
@@ -32,17 +39,59 @@ func (r ListAccessKeysRequest) ValidationRules() []validate.ValidationRule {
 type CreateAccessKeyRequest struct {
 	UserID            uid.ID   `json:"userID"`
 	Name              string   `json:"name"`
-	TTL               Duration `json:"ttl" note:"maximum time valid"`
+	TTL               Duration `json:"ttl,omitempty" note:"maximum time valid, defaults to the organization setting"`
 	ExtensionDeadline Duration `json:"extensionDeadline,omitempty" note:"How long the key is active for before it needs to be renewed. The access key must be used within this amount of time to renew validity"`
+	OneTimeUse        bool     `json:"oneTimeUse,omitempty" note:"the key is deleted after it is used to authenticate once"`
+	Secret            string   `json:"secret,omitempty" note:"import a known secret instead of generating one, requires the admin role"`
 }
 
+// AccessKeySecretLength is the length of the secret part of an access key.
+const AccessKeySecretLength = 24
+
 func (r CreateAccessKeyRequest) ValidationRules() []validate.ValidationRule {
 	return []validate.ValidationRule{
-		ValidateName(r.Name),
+		validateAccessKeyName(r.Name),
 		validate.Required("userID", r.UserID),
-		validate.Required("ttl", r.TTL),
 		validate.Required("extensionDeadline", r.ExtensionDeadline),
+		validate.StringRule{
+			Value:           r.Secret,
+			Name:            "secret",
+			MinLength:       AccessKeySecretLength,
+			MaxLength:       AccessKeySecretLength,
+			CharacterRanges: validate.AlphaNumeric,
+		},
+	}
+}
+
+type DeleteAccessKeysRequest struct {
+	NamePrefix string `form:"namePrefix" note:"delete all access keys with a name that starts with this prefix"`
+	DryRun     bool   `form:"dryRun" note:"list the access keys that would be deleted, without deleting them"`
+}
+
+func (r DeleteAccessKeysRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("namePrefix", r.NamePrefix),
+	}
+}
+
+// DeleteAccessKeysResponse lists the access keys that would be deleted by a
+// DeleteAccessKeysRequest with DryRun set. When DryRun is not set the response
+// has no content.
+type DeleteAccessKeysResponse struct {
+	DryRun bool               `json:"dryRun"`
+	Items  []DeletedAccessKey `json:"items"`
+}
+
+func (r *DeleteAccessKeysResponse) StatusCode() int {
+	if r.DryRun {
+		return http.StatusOK
 	}
+	return http.StatusNoContent
+}
+
+type DeletedAccessKey struct {
+	ID   uid.ID `json:"id"`
+	Name string `json:"name"`
 }
 
 type CreateAccessKeyResponse struct {
@@ -51,11 +100,49 @@ type CreateAccessKeyResponse struct {
 	Name              string `json:"name"`
 	IssuedFor         uid.ID `json:"issuedFor"`
 	ProviderID        uid.ID `json:"providerID"`
+	CreatedBy         uid.ID `json:"createdBy"`
 	Expires           Time   `json:"expires" note:"after this deadline the key is no longer valid"`
 	ExtensionDeadline Time   `json:"extensionDeadline" note:"the key must be used by this time to remain valid"`
 	AccessKey         string `json:"accessKey"`
 }
 
+// accessKeyNameMaxLength is the maximum length of an access key name, in bytes.
+const accessKeyNameMaxLength = 256
+
+// accessKeyNameCharacters are the characters allowed in an access key name:
+// letters, numbers, and the characters '-', '_', and '.'.
+var accessKeyNameCharacters = []validate.CharRange{
+	validate.AlphabetLower,
+	validate.AlphabetUpper,
+	validate.Numbers,
+	validate.Dash, validate.Underscore, validate.Dot,
+}
+
+// validateAccessKeyName returns the validation rule for the name of an access
+// key. The name is optional, when it is empty the server generates a name.
+func validateAccessKeyName(value string) validate.ValidationRule {
+	return accessKeyNameRule{
+		StringRule: validate.StringRule{
+			Value:           value,
+			Name:            "name",
+			MinLength:       2,
+			MaxLength:       accessKeyNameMaxLength,
+			CharacterRanges: accessKeyNameCharacters,
+		},
+	}
+}
+
+type accessKeyNameRule struct {
+	validate.StringRule
+}
+
+func (r accessKeyNameRule) Validate() *validate.Failure {
+	if r.Value != "" && strings.TrimSpace(r.Value) == "" {
+		return &validate.Failure{Name: r.Name, Problems: []string{"can not be blank"}}
+	}
+	return r.StringRule.Validate()
+}
+
 // ValidateName returns a standard validation rule for all name fields. The
 // field name must always be "name".
 func ValidateName(value string) validate.StringRule {
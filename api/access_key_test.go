@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/validate"
+	"github.com/infrahq/infra/uid"
+)
+
+func TestCreateAccessKeyRequest_ValidateName(t *testing.T) {
+	type testCase struct {
+		name        string
+		keyName     string
+		expectedErr string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req := CreateAccessKeyRequest{
+			UserID:            uid.ID(1),
+			Name:              tc.keyName,
+			ExtensionDeadline: Duration(time.Minute),
+		}
+		err := validate.Validate(req)
+		if tc.expectedErr == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.Error(t, err, tc.expectedErr)
+	}
+
+	testCases := []testCase{
+		{
+			name:    "valid",
+			keyName: "ci-bot_1.prod",
+		},
+		{
+			name:    "empty name is generated",
+			keyName: "",
+		},
+		{
+			name:        "slash",
+			keyName:     "ci/bot",
+			expectedErr: `validation failed: name: character '/' at position 2 is not allowed`,
+		},
+		{
+			name:        "too long",
+			keyName:     strings.Repeat("a", accessKeyNameMaxLength+1),
+			expectedErr: "validation failed: name: can be at most 256 characters",
+		},
+		{
+			name:        "empty after trim",
+			keyName:     "   ",
+			expectedErr: "validation failed: name: can not be blank",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
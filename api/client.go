@@ -40,6 +40,14 @@ type Client struct {
 	// OnUnauthorized is a callback hook for the client to get notified of a 401 Unauthorized response to any query.
 	// This is useful as clients often need to discard expired access keys.
 	OnUnauthorized func()
+	// ReloadAccessKey is called with the access key of a request that failed
+	// with a 401 Unauthorized response. It returns an access key that was
+	// already issued to the client, for example one stored by a login that
+	// happened after the request started; it does not request a new access
+	// key from the server. When it returns a different access key the request
+	// is sent again, once, with that key. OnUnauthorized is only called when
+	// no other access key is available, or the other key is also rejected.
+	ReloadAccessKey func(rejected string) (string, error)
 
 	// ObserveFunc is a callback to measure and record the status and duration of the request
 	ObserveFunc func(time.Time, *http.Request, *http.Response, error)
@@ -62,10 +70,27 @@ func checkError(resp *http.Response, body []byte) error {
 		// Use the full body as the message if we fail to decode a response.
 		apiError.Message = string(body)
 	}
+	apiError.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
 
 	return apiError
 }
 
+// parseRetryAfter returns the duration of a Retry-After header, which is
+// either a number of seconds or an HTTP date. It returns 0 when the header is
+// missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+		return time.Until(date)
+	}
+	return 0
+}
+
 // ErrorStatusCode returns the http status code from the error.
 // Returns 0 if the error is nil, or if the error is not of type Error.
 func ErrorStatusCode(err error) int32 {
@@ -121,6 +146,19 @@ func request[Req, Res any](client Client, method string, path string, query Quer
 		client.ObserveFunc(start, req, resp, err)
 	}
 
+	if resp != nil && resp.StatusCode == 401 && client.ReloadAccessKey != nil {
+		accessKey, err := client.ReloadAccessKey(client.AccessKey)
+		switch {
+		case err != nil:
+			logging.Debugf("reload access key: %v", err)
+		case accessKey != "" && accessKey != client.AccessKey:
+			resp.Body.Close()
+			client.AccessKey = accessKey
+			client.ReloadAccessKey = nil
+			return request[Req, Res](client, method, path, query, reqBody)
+		}
+	}
+
 	if resp != nil && resp.StatusCode == 401 && client.OnUnauthorized != nil {
 		defer client.OnUnauthorized()
 	}
@@ -182,9 +220,9 @@ func (c Client) ListUsers(req ListUsersRequest) (*ListResponse[User], error) {
 	})
 	return get[ListResponse[User]](c, "/api/users",
 		Query{
-			"name": {req.Name}, "group": {req.Group.String()}, "ids": ids,
+			"name": {req.Name}, "search": {req.Search}, "group": {req.Group.String()}, "ids": ids,
 			"page": {strconv.Itoa(req.Page)}, "limit": {strconv.Itoa(req.Limit)},
-			"showSystem": {strconv.FormatBool(req.ShowSystem)},
+			"showSystem": {strconv.FormatBool(req.ShowSystem)}, "sort": {req.Sort},
 		})
 }
 
@@ -200,10 +238,24 @@ func (c Client) UpdateUser(req *UpdateUserRequest) (*User, error) {
 	return put[UpdateUserRequest, User](c, fmt.Sprintf("/api/users/%s", req.ID.String()), req)
 }
 
+// PatchUser updates only the fields of the user that are set in req.
+func (c Client) PatchUser(req *PatchUserRequest) (*User, error) {
+	return patch[PatchUserRequest, User](c, fmt.Sprintf("/api/users/%s", req.ID.String()), req)
+}
+
 func (c Client) DeleteUser(id uid.ID) error {
 	return delete(c, fmt.Sprintf("/api/users/%s", id))
 }
 
+func (c Client) AddUserToGroup(req *AddUserToGroupRequest) error {
+	_, err := post[AddUserToGroupRequest, EmptyResponse](c, fmt.Sprintf("/api/groups/%s/users", req.GroupID), req)
+	return err
+}
+
+func (c Client) RemoveUserFromGroup(req *RemoveUserFromGroupRequest) error {
+	return delete(c, fmt.Sprintf("/api/groups/%s/users/%s", req.GroupID, req.UserID))
+}
+
 // Deprecated: use ListGrants
 func (c Client) ListUserGrants(id uid.ID) (*ListResponse[Grant], error) {
 	return get[ListResponse[Grant]](c, fmt.Sprintf("/api/users/%s/grants", id), Query{})
@@ -280,26 +332,68 @@ func (c Client) DeleteProvider(id uid.ID) error {
 	return delete(c, fmt.Sprintf("/api/providers/%s", id))
 }
 
+func (c Client) TestProvider(id uid.ID) (*TestProviderResponse, error) {
+	return post[EmptyRequest, TestProviderResponse](c, fmt.Sprintf("/api/providers/%s/test", id), &EmptyRequest{})
+}
+
+func (c Client) StartDeviceAuthorization(providerID uid.ID) (*DeviceAuthorizationResponse, error) {
+	return post[EmptyRequest, DeviceAuthorizationResponse](c, fmt.Sprintf("/api/providers/%s/device-authorization", providerID), &EmptyRequest{})
+}
+
 func (c Client) ListGrants(req ListGrantsRequest) (*ListResponse[Grant], error) {
 	return get[ListResponse[Grant]](c, "/api/grants", Query{
 		"user":          {req.User.String()},
 		"group":         {req.Group.String()},
 		"resource":      {req.Resource},
-		"privilege":     {req.Privilege},
+		"privilege":     req.Privilege,
 		"showInherited": {strconv.FormatBool(req.ShowInherited)},
 		"showSystem":    {strconv.FormatBool(req.ShowSystem)},
+		"showWildcard":  {strconv.FormatBool(req.ShowWildcard)},
+		"cursor":        {req.Cursor},
 		"page":          {strconv.Itoa(req.Page)}, "limit": {strconv.Itoa(req.Limit)},
 	})
 }
 
+func (c Client) ListEffectiveGrants(req ListEffectiveGrantsRequest) (*ListResponse[EffectiveGrant], error) {
+	id := req.ID.ID.String()
+	if req.ID.IsSelf {
+		id = "self"
+	}
+	return get[ListResponse[EffectiveGrant]](c, fmt.Sprintf("/api/users/%s/effective-grants", id), Query{
+		"page": {strconv.Itoa(req.Page)}, "limit": {strconv.Itoa(req.Limit)},
+	})
+}
+
 func (c Client) CreateGrant(req *CreateGrantRequest) (*CreateGrantResponse, error) {
 	return post[CreateGrantRequest, CreateGrantResponse](c, "/api/grants", req)
 }
 
+func (c Client) CreateGrants(req *CreateGrantsRequest) (*CreateGrantsResponse, error) {
+	return post[CreateGrantsRequest, CreateGrantsResponse](c, "/api/grants/batch", req)
+}
+
+// ExportGrants returns all the grants in the organization, in a format that
+// can be imported with ImportGrants.
+func (c Client) ExportGrants() (*ExportGrantsResponse, error) {
+	return get[ExportGrantsResponse](c, "/api/grants/export", Query{})
+}
+
+// ImportGrants creates the grants from an export. Grants that already exist
+// are skipped.
+func (c Client) ImportGrants(req *ImportGrantsRequest) (*ImportGrantsResponse, error) {
+	return post[ImportGrantsRequest, ImportGrantsResponse](c, "/api/grants/import", req)
+}
+
 func (c Client) DeleteGrant(id uid.ID) error {
 	return delete(c, fmt.Sprintf("/api/grants/%s", id))
 }
 
+// CheckAuthorization checks if the authenticated user has access to each of
+// the resources in req.
+func (c Client) CheckAuthorization(req *CheckAuthorizationRequest) (*CheckAuthorizationResponse, error) {
+	return post[CheckAuthorizationRequest, CheckAuthorizationResponse](c, "/api/grants/check", req)
+}
+
 func (c Client) ListDestinations(req ListDestinationsRequest) (*ListResponse[Destination], error) {
 	return get[ListResponse[Destination]](c, "/api/destinations", Query{
 		"name":      {req.Name},
@@ -316,6 +410,11 @@ func (c Client) UpdateDestination(req UpdateDestinationRequest) (*Destination, e
 	return put[UpdateDestinationRequest, Destination](c, fmt.Sprintf("/api/destinations/%s", req.ID.String()), &req)
 }
 
+// PatchDestination updates only the fields of the destination that are set in req.
+func (c Client) PatchDestination(req PatchDestinationRequest) (*Destination, error) {
+	return patch[PatchDestinationRequest, Destination](c, fmt.Sprintf("/api/destinations/%s", req.ID.String()), &req)
+}
+
 func (c Client) DeleteDestination(id uid.ID) error {
 	return delete(c, fmt.Sprintf("/api/destinations/%s", id))
 }
@@ -323,8 +422,10 @@ func (c Client) DeleteDestination(id uid.ID) error {
 func (c Client) ListAccessKeys(req ListAccessKeysRequest) (*ListResponse[AccessKey], error) {
 	return get[ListResponse[AccessKey]](c, "/api/access-keys", Query{
 		"user_id":      {req.UserID.String()},
+		"provider_id":  {req.ProviderID.String()},
 		"name":         {req.Name},
 		"show_expired": {fmt.Sprint(req.ShowExpired)},
+		"cursor":       {req.Cursor},
 		"page":         {strconv.Itoa(req.Page)}, "limit": {strconv.Itoa(req.Limit)},
 	})
 }
@@ -366,6 +467,11 @@ func (c Client) UpdateSettings(req *Settings) (*Settings, error) {
 	return put[Settings, Settings](c, "/api/settings", req)
 }
 
+func (c Client) RotateSigningKey() error {
+	_, err := post[EmptyRequest, EmptyResponse](c, "/api/settings/rotate-signing-key", &EmptyRequest{})
+	return err
+}
+
 func partialText(body []byte, limit int) string {
 	if len(body) <= limit {
 		return string(body)
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"runtime"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -58,6 +59,16 @@ func TestErrorStatusCode(t *testing.T) {
 	})
 }
 
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, parseRetryAfter(""), time.Duration(0))
+	assert.Equal(t, parseRetryAfter("30"), 30*time.Second)
+	assert.Equal(t, parseRetryAfter("soon"), time.Duration(0))
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	retryAfter := parseRetryAfter(date)
+	assert.Assert(t, retryAfter > 50*time.Second && retryAfter <= time.Minute, retryAfter)
+}
+
 func TestGet(t *testing.T) {
 	requestCh := make(chan *http.Request, 5)
 	handler := func(resp http.ResponseWriter, r *http.Request) {
@@ -159,3 +170,63 @@ func TestDelete(t *testing.T) {
 		assert.Equal(t, r.URL.Path, "/good")
 	})
 }
+
+func TestClient_ReloadAccessKey(t *testing.T) {
+	var requests []string
+	handler := func(resp http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer the-new-key" {
+			resp.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(resp).Encode(Error{Code: http.StatusUnauthorized, Message: "unauthorized"})
+			return
+		}
+		resp.WriteHeader(http.StatusOK)
+		_, _ = resp.Write([]byte(`{}`))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(srv.Close)
+
+	type stubResponse struct{}
+
+	run := func(t *testing.T, reload func(string) (string, error)) (bool, error) {
+		t.Helper()
+		requests = nil
+		var unauthorized bool
+		c := Client{
+			URL:             srv.URL,
+			AccessKey:       "the-old-key",
+			ReloadAccessKey: reload,
+			OnUnauthorized:  func() { unauthorized = true },
+		}
+		_, err := post[EmptyRequest, stubResponse](c, "/good", &EmptyRequest{})
+		return unauthorized, err
+	}
+
+	t.Run("reload then retry", func(t *testing.T) {
+		unauthorized, err := run(t, func(rejected string) (string, error) {
+			assert.Equal(t, rejected, "the-old-key")
+			return "the-new-key", nil
+		})
+		assert.NilError(t, err)
+		assert.Assert(t, !unauthorized)
+		assert.DeepEqual(t, requests, []string{"Bearer the-old-key", "Bearer the-new-key"})
+	})
+
+	t.Run("reload fails", func(t *testing.T) {
+		unauthorized, err := run(t, func(string) (string, error) {
+			return "", fmt.Errorf("no key")
+		})
+		assert.Equal(t, ErrorStatusCode(err), int32(http.StatusUnauthorized))
+		assert.Assert(t, unauthorized)
+		assert.DeepEqual(t, requests, []string{"Bearer the-old-key"})
+	})
+
+	t.Run("reloaded key is also rejected", func(t *testing.T) {
+		unauthorized, err := run(t, func(string) (string, error) {
+			return "another-key", nil
+		})
+		assert.Equal(t, ErrorStatusCode(err), int32(http.StatusUnauthorized))
+		assert.Assert(t, unauthorized)
+		assert.DeepEqual(t, requests, []string{"Bearer the-old-key", "Bearer another-key"})
+	})
+}
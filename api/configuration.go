@@ -1,7 +1,26 @@
 package api
 
 type ServerConfiguration struct {
-	IsEmailConfigured bool   `json:"isEmailConfigured"`
-	IsSignupEnabled   bool   `json:"isSignupEnabled"`
-	BaseDomain        string `json:"baseDomain"`
+	IsEmailConfigured bool            `json:"isEmailConfigured"`
+	IsSignupEnabled   bool            `json:"isSignupEnabled"`
+	SignupDisabled    *SignupDisabled `json:"signupDisabled,omitempty" note:"why signup is disabled, only set when isSignupEnabled is false"`
+	BaseDomain        string          `json:"baseDomain"`
+}
+
+// Values of SignupDisabled.Reason. These values will not change, clients may
+// compare them to the Reason of a response.
+const (
+	// SignupDisabledReasonNotEnabled is the reason when the server does not
+	// allow signup.
+	SignupDisabledReasonNotEnabled = "not_enabled"
+	// SignupDisabledReasonDisabled is the reason when signup was disabled by
+	// the operator of a server which otherwise allows signup.
+	SignupDisabledReasonDisabled = "disabled"
+)
+
+// SignupDisabled describes why signup is disabled. The same message is
+// returned in the error from a signup request.
+type SignupDisabled struct {
+	Reason  string `json:"reason" example:"disabled" note:"one of not_enabled or disabled"`
+	Message string `json:"message" example:"signup is paused during maintenance"`
 }
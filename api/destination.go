@@ -20,6 +20,8 @@ type Destination struct {
 	Connected bool `json:"connected"`
 
 	Version string `json:"version"`
+
+	ResourceVersion int64 `json:"resourceVersion" note:"send this, or the ETag from a GET, in the If-Match header of an update to reject the update if the destination was changed"`
 }
 
 type DestinationConnection struct {
@@ -83,6 +85,36 @@ func (r UpdateDestinationRequest) ValidationRules() []validate.ValidationRule {
 	}
 }
 
+// PatchDestinationRequest updates only the fields of a destination that are
+// set in the request. Fields that are omitted, or null, are left unchanged.
+// Connection, Resources, and Roles replace the existing value when they are set.
+type PatchDestinationRequest struct {
+	ID         uid.ID                 `uri:"id" json:"-"`
+	Name       *string                `json:"name"`
+	UniqueID   *string                `json:"uniqueID"`
+	Version    *string                `json:"version"`
+	Connection *DestinationConnection `json:"connection"`
+
+	Resources *[]string `json:"resources"`
+	Roles     *[]string `json:"roles"`
+}
+
+func (r PatchDestinationRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("id", r.ID),
+		ValidateName(stringValue(r.Name)),
+		validate.ValidatorFunc(func() *validate.Failure {
+			if r.Name != nil && *r.Name == "" {
+				return &validate.Failure{Name: "name", Problems: []string{"can not be empty"}}
+			}
+			if r.UniqueID != nil && *r.UniqueID == "" {
+				return &validate.Failure{Name: "uniqueID", Problems: []string{"can not be empty"}}
+			}
+			return nil
+		}),
+	}
+}
+
 func (req ListDestinationsRequest) SetPage(page int) Paginatable {
 	req.PaginationRequest.Page = page
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // Error is used as the response body for failed HTTP requests. It is also
@@ -11,12 +12,18 @@ import (
 type Error struct {
 	// Code is the HTTP status of the response.
 	Code int32 `json:"code"`
+	// ErrorCode is a machine-readable identifier for the kind of failure. It
+	// is one of the ErrorCode constants, and is more specific than Code.
+	ErrorCode string `json:"errorCode,omitempty"`
 	// Message contains the full text of the failure as a single string. The
 	// details of the failure may also be available in a structured representation
 	// from one of the other fields on the Error struct.
 	Message string `json:"message"`
 	// FieldErrors contains a structured representation of any validation errors.
 	FieldErrors []FieldError `json:"fieldErrors,omitempty"`
+	// RetryAfter is the time to wait before the request is sent again, from the
+	// Retry-After header of the response. It is only set by api.Client.
+	RetryAfter time.Duration `json:"-"`
 }
 
 func (e Error) Error() string {
@@ -26,6 +33,32 @@ func (e Error) Error() string {
 	return e.Message
 }
 
+// Values of Error.ErrorCode. These values will not change, clients may compare
+// them to the ErrorCode of a response.
+const (
+	ErrorCodeInternal                  = "internal"
+	ErrorCodeBadRequest                = "bad_request"
+	ErrorCodeValidationFailed          = "validation_failed"
+	ErrorCodeUnauthorized              = "unauthorized"
+	ErrorCodeAccessKeyExpired          = "access_key_expired"
+	ErrorCodeAccessKeyDeadlineExceeded = "access_key_deadline_exceeded"
+	ErrorCodeNotAuthorized             = "not_authorized"
+	ErrorCodeForbidden                 = "forbidden"
+	ErrorCodeNotFound                  = "not_found"
+	ErrorCodeConflict                  = "conflict"
+	ErrorCodeGone                      = "gone"
+	ErrorCodeRequestTooLarge           = "request_too_large"
+	ErrorCodeRateLimited               = "rate_limited"
+	ErrorCodeNotImplemented            = "not_implemented"
+	ErrorCodeBadGateway                = "bad_gateway"
+	ErrorCodeTimeout                   = "timeout"
+	ErrorCodeExpired                   = "expired"
+	ErrorCodeUnavailable               = "unavailable"
+	ErrorCodeAuthorizationPending      = "authorization_pending"
+	ErrorCodeSlowDown                  = "slow_down"
+	ErrorCodeSignupDisabled            = "signup_disabled"
+)
+
 type FieldError struct {
 	FieldName string   `json:"fieldName"`
 	Errors    []string `json:"errors"`
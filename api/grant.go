@@ -1,7 +1,10 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/uid"
@@ -18,6 +21,7 @@ type Grant struct {
 	Group     uid.ID `json:"group,omitempty"`
 	Privilege string `json:"privilege" note:"a role or permission"`
 	Resource  string `json:"resource" note:"a resource name in Infra's Universal Resource Notation"`
+	Expires   Time   `json:"expires" note:"the grant no longer applies after this time, null if it does not expire"`
 }
 
 type CreateGrantResponse struct {
@@ -33,12 +37,14 @@ func (r *CreateGrantResponse) StatusCode() int {
 }
 
 type ListGrantsRequest struct {
-	User          uid.ID `form:"user"`
-	Group         uid.ID `form:"group"`
-	Resource      string `form:"resource" example:"production"`
-	Privilege     string `form:"privilege" example:"view"`
-	ShowInherited bool   `form:"showInherited" note:"if true, this field includes grants that the user inherits through groups"`
-	ShowSystem    bool   `form:"showSystem" note:"if true, this shows the connector and other internal grants"`
+	User          uid.ID   `form:"user"`
+	Group         uid.ID   `form:"group"`
+	Resource      string   `form:"resource" example:"production"`
+	Privilege     []string `form:"privilege" example:"view" note:"only include grants with one of these privileges, may be repeated"`
+	ShowInherited bool     `form:"showInherited" note:"if true, this field includes grants that the user inherits through groups"`
+	ShowSystem    bool     `form:"showSystem" note:"if true, this shows the connector and other internal grants"`
+	ShowWildcard  bool     `form:"showWildcard" note:"if true, this includes grants with a wildcard resource that matches resource, like production.* for production.default"`
+	Cursor        string   `form:"cursor" note:"cursor from a previous response, used instead of page. When page and cursor are omitted the response includes the cursor of the next page"`
 	PaginationRequest
 }
 
@@ -57,7 +63,51 @@ func (r ListGrantsRequest) ValidationRules() []validate.ValidationRule {
 			}
 			return nil
 		}),
+		validate.ValidatorFunc(func() *validate.Failure {
+			if r.ShowWildcard && r.Resource == "" {
+				return &validate.Failure{
+					Name:     "showWildcard",
+					Problems: []string{"requires a resource"},
+				}
+			}
+			return nil
+		}),
+		validate.ValidatorFunc(r.validateInfraPrivileges),
+	}
+}
+
+// infraRoles are the roles that can be granted for the infra resource. The
+// support-admin role is only granted by the server, so it has no constant.
+var infraRoles = []string{InfraAdminRole, InfraViewRole, InfraConnectorRole, "support-admin"}
+
+// validateInfraPrivileges checks that every privilege is a role of the infra
+// resource, when the request is for the infra resource. Other resources allow
+// any privilege, because the roles depend on the destination.
+func (r ListGrantsRequest) validateInfraPrivileges() *validate.Failure {
+	if strings.TrimSpace(r.Resource) != "infra" {
+		return nil
+	}
+	var problems []string
+	for _, privilege := range r.Privilege {
+		privilege = strings.TrimSpace(privilege)
+		if privilege != "" && !isInfraRole(privilege) {
+			problems = append(problems, fmt.Sprintf("%q is not a role of the infra resource, must be one of (%v)",
+				privilege, strings.Join(infraRoles, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return &validate.Failure{Name: "privilege", Problems: problems}
+	}
+	return nil
+}
+
+func isInfraRole(role string) bool {
+	for _, infraRole := range infraRoles {
+		if role == infraRole {
+			return true
+		}
 	}
+	return false
 }
 
 type CreateGrantRequest struct {
@@ -65,6 +115,7 @@ type CreateGrantRequest struct {
 	Group     uid.ID `json:"group"`
 	Privilege string `json:"privilege" example:"view" note:"a role or permission"`
 	Resource  string `json:"resource" example:"production" note:"a resource name in Infra's Universal Resource Notation"`
+	Expires   Time   `json:"expires" note:"optional time after which the grant no longer applies"`
 }
 
 func (r CreateGrantRequest) ValidationRules() []validate.ValidationRule {
@@ -75,7 +126,104 @@ func (r CreateGrantRequest) ValidationRules() []validate.ValidationRule {
 		),
 		validate.Required("privilege", r.Privilege),
 		validate.Required("resource", r.Resource),
+		validate.ValidatorFunc(func() *validate.Failure {
+			expires := r.Expires.Time()
+			if !expires.IsZero() && !expires.After(time.Now()) {
+				return &validate.Failure{
+					Name:     "expires",
+					Problems: []string{"must be in the future"},
+				}
+			}
+			return nil
+		}),
+	}
+}
+
+// MaxCreateGrantsBatchSize is the maximum number of grants in a CreateGrantsRequest.
+const MaxCreateGrantsBatchSize = 1000
+
+type CreateGrantsRequest struct {
+	Grants []CreateGrantRequest `json:"grants" note:"grants to create, either all of them are created or none are"`
+}
+
+func (r CreateGrantsRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("grants", r.Grants),
+		validate.ValidatorFunc(func() *validate.Failure {
+			if len(r.Grants) > MaxCreateGrantsBatchSize {
+				return &validate.Failure{
+					Name:     "grants",
+					Problems: []string{fmt.Sprintf("must contain at most %d grants", MaxCreateGrantsBatchSize)},
+				}
+			}
+			return nil
+		}),
+	}
+}
+
+// CreateGrantsResult is the result of creating one of the grants in a
+// CreateGrantsRequest.
+type CreateGrantsResult struct {
+	*Grant     `json:",inline"`
+	WasCreated bool `json:"wasCreated" note:"false when the grant already existed, or is a duplicate in the request"`
+	Duplicate  bool `json:"duplicate,omitempty" note:"true when an identical grant appears earlier in the request"`
+}
+
+type CreateGrantsResponse struct {
+	Results []CreateGrantsResult `json:"results" note:"one result for each grant in the request, in the same order"`
+}
+
+func (r *CreateGrantsResponse) StatusCode() int {
+	for _, result := range r.Results {
+		if result.WasCreated {
+			return http.StatusCreated
+		}
+	}
+	return http.StatusOK
+}
+
+// ExportedGrant is a grant in the format used to export and import grants. The
+// subject of the grant is identified by name instead of ID, so that the grants
+// can be imported into another organization.
+type ExportedGrant struct {
+	User      string `json:"user,omitempty" note:"name of the user"`
+	Group     string `json:"group,omitempty" note:"name of the group"`
+	Privilege string `json:"privilege" example:"view" note:"a role or permission"`
+	Resource  string `json:"resource" example:"production" note:"a resource name in Infra's Universal Resource Notation"`
+	Expires   Time   `json:"expires" note:"the grant no longer applies after this time, null if it does not expire"`
+}
+
+func (r ExportedGrant) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.RequireOneOf(
+			validate.Field{Name: "user", Value: r.User},
+			validate.Field{Name: "group", Value: r.Group},
+		),
+		validate.Required("privilege", r.Privilege),
+		validate.Required("resource", r.Resource),
+	}
+}
+
+// ExportGrantsResponse is the body of the response from GET /api/grants/export.
+type ExportGrantsResponse struct {
+	Grants []ExportedGrant `json:"grants"`
+}
+
+type ImportGrantsRequest struct {
+	Grants []ExportedGrant `json:"grants" note:"grants in the format of the export, either all of them are imported or none are"`
+}
+
+type ImportGrantsResponse struct {
+	Created int `json:"created" note:"number of grants that were created"`
+	Skipped int `json:"skipped" note:"number of grants that already existed, or are duplicates in the request"`
+	Expired int `json:"expired" note:"number of grants that were not created because they have expired"`
+}
+
+func (r *ImportGrantsResponse) StatusCode() int {
+	if r.Created > 0 {
+		return http.StatusCreated
 	}
+	return http.StatusOK
 }
 
 func (req ListGrantsRequest) SetPage(page int) Paginatable {
@@ -83,3 +231,75 @@ func (req ListGrantsRequest) SetPage(page int) Paginatable {
 
 	return req
 }
+
+// Sources of an EffectiveGrant
+const (
+	GrantSourceDirect = "direct"
+	GrantSourceGroup  = "group"
+)
+
+// GrantEvent is the data of an event sent by the grants stream when a grant is
+// created or deleted.
+type GrantEvent struct {
+	ID        uid.ID `json:"id" note:"id of the audit event"`
+	Action    string `json:"action" example:"grant.create" note:"grant.create or grant.delete"`
+	Time      Time   `json:"time"`
+	GrantID   uid.ID `json:"grantID"`
+	User      uid.ID `json:"user,omitempty"`
+	Group     uid.ID `json:"group,omitempty"`
+	Privilege string `json:"privilege"`
+	Resource  string `json:"resource"`
+}
+
+type EffectiveGrant struct {
+	*Grant    `json:",inline"`
+	Source    string `json:"source" example:"group" note:"direct if the grant is for the user, group if the user inherits it from a group"`
+	GroupName string `json:"groupName,omitempty" example:"developers" note:"name of the group the grant is inherited from"`
+}
+
+type ListEffectiveGrantsRequest struct {
+	ID IDOrSelf `uri:"id"`
+	PaginationRequest
+}
+
+func (r ListEffectiveGrantsRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("id", r.ID),
+	}
+}
+
+func (req ListEffectiveGrantsRequest) SetPage(page int) Paginatable {
+	req.PaginationRequest.Page = page
+
+	return req
+}
+
+type AuthorizationCheck struct {
+	Resource   string   `json:"resource" example:"production.namespace" note:"a resource name in Infra's Universal Resource Notation"`
+	Privileges []string `json:"privileges" example:"['view', 'edit']" note:"access is allowed if the user has any one of these privileges"`
+}
+
+func (r AuthorizationCheck) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("resource", r.Resource),
+		validate.Required("privileges", r.Privileges),
+	}
+}
+
+type CheckAuthorizationRequest struct {
+	Checks []AuthorizationCheck `json:"checks"`
+}
+
+func (r CheckAuthorizationRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("checks", r.Checks),
+	}
+}
+
+type CheckAuthorizationResponse struct {
+	Allowed map[string]bool `json:"allowed" note:"maps each resource to true if the user is allowed access"`
+}
+
+func (r *CheckAuthorizationResponse) StatusCode() int {
+	return http.StatusOK
+}
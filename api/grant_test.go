@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/validate"
+)
+
+func TestListGrantsRequest_ValidatePrivilege(t *testing.T) {
+	type testCase struct {
+		name        string
+		req         ListGrantsRequest
+		expectedErr string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := validate.Validate(tc.req)
+		if tc.expectedErr == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.Error(t, err, tc.expectedErr)
+	}
+
+	testCases := []testCase{
+		{
+			name: "infra roles",
+			req:  ListGrantsRequest{Resource: "infra", Privilege: []string{"admin", " view ", "support-admin"}},
+		},
+		{
+			name: "any privilege for other resources",
+			req:  ListGrantsRequest{Resource: "production", Privilege: []string{"cluster-admin", "custom"}},
+		},
+		{
+			name: "no resource",
+			req:  ListGrantsRequest{Privilege: []string{"custom"}},
+		},
+		{
+			name: "unknown infra role",
+			req:  ListGrantsRequest{Resource: "infra", Privilege: []string{"admin", "edit"}},
+			expectedErr: `validation failed: privilege: "edit" is not a role of the infra resource, ` +
+				`must be one of (admin, view, connector, support-admin)`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestCreateGrantRequest_Validate(t *testing.T) {
+	type testCase struct {
+		name        string
+		req         CreateGrantRequest
+		expectedErr string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := validate.Validate(tc.req)
+		if tc.expectedErr == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expectedErr)
+	}
+
+	testCases := []testCase{
+		{
+			name: "without expires",
+			req:  CreateGrantRequest{User: 1, Privilege: "view", Resource: "production"},
+		},
+		{
+			name: "expires in the future",
+			req: CreateGrantRequest{
+				User:      1,
+				Privilege: "view",
+				Resource:  "production",
+				Expires:   Time(time.Now().Add(time.Hour)),
+			},
+		},
+		{
+			name: "expires in the past",
+			req: CreateGrantRequest{
+				User:      1,
+				Privilege: "view",
+				Resource:  "production",
+				Expires:   Time(time.Now().Add(-time.Hour)),
+			},
+			expectedErr: "expires: must be in the future",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestCreateGrantsRequest_ValidateNestedFieldNames(t *testing.T) {
+	req := CreateGrantsRequest{
+		Grants: []CreateGrantRequest{
+			{User: 1, Privilege: "view", Resource: "production"},
+			{Group: 2, Privilege: "admin"},
+			{User: 3, Resource: "staging", Expires: Time(time.Now().Add(-time.Hour))},
+		},
+	}
+	err := validate.Validate(req)
+
+	var fieldErr validate.Error
+	assert.Assert(t, errors.As(err, &fieldErr), err)
+	expected := validate.Error{
+		"grants[1].resource":  {"is required"},
+		"grants[2].privilege": {"is required"},
+		"grants[2].expires":   {"must be in the future"},
+	}
+	assert.DeepEqual(t, fieldErr, expected)
+}
@@ -49,6 +49,33 @@ func (r UpdateUsersInGroupRequest) ValidationRules() []validate.ValidationRule {
 	}
 }
 
+// AddUserToGroupRequest adds a single user to a group.
+type AddUserToGroupRequest struct {
+	GroupID uid.ID `uri:"id" json:"-"`
+	UserID  uid.ID `json:"userID"`
+}
+
+func (r AddUserToGroupRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("id", r.GroupID),
+		validate.Required("userID", r.UserID),
+	}
+}
+
+// RemoveUserFromGroupRequest removes a single user from a group. A user that
+// was added to the group by an identity provider can not be removed.
+type RemoveUserFromGroupRequest struct {
+	GroupID uid.ID `uri:"id" json:"-"`
+	UserID  uid.ID `uri:"userID" json:"-"`
+}
+
+func (r RemoveUserFromGroupRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("id", r.GroupID),
+		validate.Required("userID", r.UserID),
+	}
+}
+
 func (req ListGroupsRequest) SetPage(page int) Paginatable {
 
 	req.PaginationRequest.Page = page
@@ -19,6 +19,37 @@ func (r LoginRequestOIDC) ValidationRules() []validate.ValidationRule {
 	}
 }
 
+// LoginRequestIDToken is used to login with an ID token that was issued by the
+// provider to a client that can not complete the auth code flow, like a CI job.
+type LoginRequestIDToken struct {
+	ProviderID uid.ID `json:"providerID"`
+	IDToken    string `json:"idToken"`
+}
+
+func (r LoginRequestIDToken) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("providerID", r.ProviderID),
+		validate.Required("idToken", r.IDToken),
+	}
+}
+
+// LoginRequestDevice is used to login with the device code from a device
+// authorization that was started with StartDeviceAuthorization. Login fails
+// with an authorization_pending error until the user authorizes the device.
+// Clients must wait for the interval of the device authorization between
+// login requests, and increase the interval after a slow_down error.
+type LoginRequestDevice struct {
+	ProviderID uid.ID `json:"providerID"`
+	DeviceCode string `json:"deviceCode"`
+}
+
+func (r LoginRequestDevice) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("providerID", r.ProviderID),
+		validate.Required("deviceCode", r.DeviceCode),
+	}
+}
+
 type LoginRequestPasswordCredentials struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
@@ -35,6 +66,8 @@ type LoginRequest struct {
 	AccessKey           string                           `json:"accessKey"`
 	PasswordCredentials *LoginRequestPasswordCredentials `json:"passwordCredentials"`
 	OIDC                *LoginRequestOIDC                `json:"oidc"`
+	IDToken             *LoginRequestIDToken             `json:"idToken"`
+	Device              *LoginRequestDevice              `json:"device"`
 }
 
 func (r LoginRequest) ValidationRules() []validate.ValidationRule {
@@ -43,6 +76,8 @@ func (r LoginRequest) ValidationRules() []validate.ValidationRule {
 			validate.Field{Name: "accessKey", Value: r.AccessKey},
 			validate.Field{Name: "passwordCredentials", Value: r.PasswordCredentials},
 			validate.Field{Name: "oidc", Value: r.OIDC},
+			validate.Field{Name: "idToken", Value: r.IDToken},
+			validate.Field{Name: "device", Value: r.Device},
 		),
 	}
 }
@@ -32,4 +32,6 @@ type PaginationResponse struct {
 	Limit      int `json:"limit"`
 	TotalPages int `json:"totalPages"`
 	TotalCount int `json:"totalCount"`
+	// NextCursor is only set by list operations that support cursors
+	NextCursor string `json:"nextCursor,omitempty" note:"pass as the cursor of the next request to get the next page"`
 }
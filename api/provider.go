@@ -1,6 +1,11 @@
 package api
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
 	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/uid"
 )
@@ -28,7 +33,13 @@ type Provider struct {
 	ClientID string   `json:"clientID" example:"0oapn0qwiQPiMIyR35d6"`
 	Kind     string   `json:"kind" example:"oidc"`
 	AuthURL  string   `json:"authURL" example:"https://example.com/oauth2/v1/authorize"`
-	Scopes   []string `json:"scopes" example:"['openid', 'email']"`
+	Scopes   []string `json:"scopes" example:"['openid', 'email']" note:"scopes supported by the provider"`
+
+	GroupsClaimName string   `json:"groupsClaimName,omitempty" example:"groups" note:"name of the user info claim which contains the groups of a user"`
+	EmailClaimName  string   `json:"emailClaimName,omitempty" example:"email" note:"name of the ID token claim which contains the email address of a user"`
+	RedirectURLs    []string `json:"redirectURLs,omitempty" example:"['http://localhost:8301', 'https://infra.example.com/login/callback']" note:"redirect URLs clients may use to login"`
+	RequestedScopes []string `json:"requestedScopes,omitempty" example:"['openid', 'email', 'groups']" note:"scopes requested from the provider at login, when empty clients request the supported scopes"`
+	Domains         []string `json:"domains,omitempty" example:"['example.com']" note:"email domains of the users who login with this provider"`
 }
 
 type CreateProviderRequest struct {
@@ -38,6 +49,13 @@ type CreateProviderRequest struct {
 	ClientSecret string                  `json:"clientSecret" example:"jmda5eG93ax3jMDxTGrbHd_TBGT6kgNZtrCugLbU"`
 	Kind         string                  `json:"kind" example:"oidc"`
 	API          *ProviderAPICredentials `json:"api"`
+
+	GroupsClaimName string   `json:"groupsClaimName,omitempty" example:"groups" note:"name of the user info claim which contains the groups of a user, defaults to groups"`
+	EmailClaimName  string   `json:"emailClaimName,omitempty" example:"upn" note:"name of the ID token claim which contains the email address of a user, defaults to email"`
+	RedirectURLs    []string `json:"redirectURLs,omitempty" example:"['http://localhost:8301', 'https://infra.example.com/login/callback']" note:"redirect URLs clients may use to login, defaults to the CLI redirect URL http://localhost:8301"`
+	RequestedScopes []string `json:"requestedScopes,omitempty" example:"['openid', 'email', 'groups']" note:"scopes requested from the provider at login, defaults to openid, email, groups, and offline_access. openid is always requested"`
+	Domains         []string `json:"domains,omitempty" example:"['example.com']" note:"email domains of the users who login with this provider, used to select the provider from an email address at login"`
+	SkipValidation  bool     `json:"skipValidation,omitempty" note:"store the provider without checking that it is reachable, for a provider that can not be reached from the server"`
 }
 
 var kinds = []string{"oidc", "okta", "azure", "google"}
@@ -50,6 +68,8 @@ func (r CreateProviderRequest) ValidationRules() []validate.ValidationRule {
 		validate.Required("clientID", r.ClientID),
 		validate.Required("clientSecret", r.ClientSecret),
 		validate.Enum("kind", r.Kind, kinds),
+		validateRedirectURLs(r.RedirectURLs),
+		ValidateEmailDomains(r.Domains),
 	}
 }
 
@@ -61,6 +81,13 @@ type UpdateProviderRequest struct {
 	ClientSecret string                  `json:"clientSecret" example:"jmda5eG93ax3jMDxTGrbHd_TBGT6kgNZtrCugLbU"`
 	Kind         string                  `json:"kind" example:"oidc"`
 	API          *ProviderAPICredentials `json:"api"`
+
+	GroupsClaimName string   `json:"groupsClaimName,omitempty" example:"groups" note:"name of the user info claim which contains the groups of a user, defaults to groups"`
+	EmailClaimName  string   `json:"emailClaimName,omitempty" example:"upn" note:"name of the ID token claim which contains the email address of a user, defaults to email"`
+	RedirectURLs    []string `json:"redirectURLs,omitempty" example:"['http://localhost:8301', 'https://infra.example.com/login/callback']" note:"redirect URLs clients may use to login, defaults to the CLI redirect URL http://localhost:8301"`
+	RequestedScopes []string `json:"requestedScopes,omitempty" example:"['openid', 'email', 'groups']" note:"scopes requested from the provider at login, defaults to openid, email, groups, and offline_access. openid is always requested"`
+	Domains         []string `json:"domains,omitempty" example:"['example.com']" note:"email domains of the users who login with this provider, used to select the provider from an email address at login"`
+	SkipValidation  bool     `json:"skipValidation,omitempty" note:"store the provider without checking that it is reachable, for a provider that can not be reached from the server"`
 }
 
 func (r UpdateProviderRequest) ValidationRules() []validate.ValidationRule {
@@ -72,9 +99,69 @@ func (r UpdateProviderRequest) ValidationRules() []validate.ValidationRule {
 		validate.Required("clientID", r.ClientID),
 		validate.Required("clientSecret", r.ClientSecret),
 		validate.Enum("kind", r.Kind, kinds),
+		validateRedirectURLs(r.RedirectURLs),
+		ValidateEmailDomains(r.Domains),
 	}
 }
 
+// validateRedirectURLs checks that each redirect URL is an absolute URL, as
+// required by the OAuth2 specification. The redirect URLs are stored as a
+// comma separated list, so they must not contain a comma.
+func validateRedirectURLs(values []string) validate.ValidationRule {
+	return validate.ValidatorFunc(func() *validate.Failure {
+		var problems []string
+		for _, value := range values {
+			u, err := url.Parse(value)
+			switch {
+			case err != nil || !u.IsAbs() || u.Host == "":
+				problems = append(problems, fmt.Sprintf("%q is not an absolute URL", value))
+			case strings.Contains(value, ","):
+				problems = append(problems, fmt.Sprintf("%q must not contain a comma", value))
+			}
+		}
+		if len(problems) > 0 {
+			return &validate.Failure{Name: "redirectURLs", Problems: problems}
+		}
+		return nil
+	})
+}
+
+// ValidateEmailDomains checks that each value is the domain of an email
+// address, without the @.
+func ValidateEmailDomains(values []string) validate.ValidationRule {
+	return validate.ValidatorFunc(func() *validate.Failure {
+		var problems []string
+		for _, value := range values {
+			if !isEmailDomain(value) {
+				problems = append(problems, fmt.Sprintf("%q is not an email domain", value))
+			}
+		}
+		if len(problems) > 0 {
+			return &validate.Failure{Name: "domains", Problems: problems}
+		}
+		return nil
+	})
+}
+
+func isEmailDomain(value string) bool {
+	if value == "" || strings.Trim(value, ".") != value || !strings.Contains(value, ".") {
+		return false
+	}
+	for _, label := range strings.Split(value, ".") {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
 type ListProvidersRequest struct {
 	Name string `form:"name" example:"okta"`
 	PaginationRequest
@@ -91,3 +178,38 @@ func (req ListProvidersRequest) SetPage(page int) Paginatable {
 
 	return req
 }
+
+// TestProviderResponse is the result of requesting the OpenID discovery
+// document from an identity provider.
+type TestProviderResponse struct {
+	Success   bool   `json:"success"`
+	ErrorKind string `json:"errorKind,omitempty" example:"dns" note:"the cause of a failure, one of dns, tls, connection, timeout, or not_oidc"`
+	Error     string `json:"error,omitempty" note:"details about the failure"`
+
+	Issuer      string `json:"issuer,omitempty" example:"https://example.okta.com"`
+	AuthURL     string `json:"authURL,omitempty" example:"https://example.okta.com/oauth2/v1/authorize"`
+	TokenURL    string `json:"tokenURL,omitempty" example:"https://example.okta.com/oauth2/v1/token"`
+	UserInfoURL string `json:"userInfoURL,omitempty" example:"https://example.okta.com/oauth2/v1/userinfo"`
+	JWKSURL     string `json:"jwksURL,omitempty" example:"https://example.okta.com/oauth2/v1/keys"`
+}
+
+func (r *TestProviderResponse) StatusCode() int {
+	return http.StatusOK
+}
+
+// DeviceAuthorizationResponse is returned when a client starts the device
+// authorization grant with an identity provider. The user must visit the
+// verification URI and enter the user code, while the client logs in with the
+// device code.
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode" example:"WDJB-MJHT"`
+	VerificationURI         string `json:"verificationURI" example:"https://example.okta.com/activate"`
+	VerificationURIComplete string `json:"verificationURIComplete,omitempty" example:"https://example.okta.com/activate?user_code=WDJB-MJHT"`
+	Expires                 Time   `json:"expires"`
+	Interval                int    `json:"interval" note:"the minimum number of seconds to wait between login attempts"`
+}
+
+func (r *DeviceAuthorizationResponse) StatusCode() int {
+	return http.StatusCreated
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/validate"
+)
+
+func TestCreateProviderRequest_ValidateRedirectURLs(t *testing.T) {
+	type testCase struct {
+		name         string
+		redirectURLs []string
+		expectedErr  string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req := CreateProviderRequest{
+			Name:         "okta",
+			URL:          "example.okta.com",
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			Kind:         "okta",
+			RedirectURLs: tc.redirectURLs,
+		}
+		err := validate.Validate(req)
+		if tc.expectedErr == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.Error(t, err, tc.expectedErr)
+	}
+
+	testCases := []testCase{
+		{
+			name:         "valid",
+			redirectURLs: []string{"http://localhost:8301", "https://infra.example.com/login/callback"},
+		},
+		{
+			name:         "relative URL",
+			redirectURLs: []string{"/login/callback"},
+			expectedErr:  `validation failed: redirectURLs: "/login/callback" is not an absolute URL`,
+		},
+		{
+			name:         "comma",
+			redirectURLs: []string{"https://infra.example.com/login/callback?a=1,2"},
+			expectedErr:  `validation failed: redirectURLs: "https://infra.example.com/login/callback?a=1,2" must not contain a comma`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
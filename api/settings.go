@@ -1,7 +1,28 @@
 package api
 
+import "github.com/infrahq/infra/internal/validate"
+
+// Settings of an organization. An update only changes the fields that are
+// present in the request, fields that are omitted keep their current value.
 type Settings struct {
-	PasswordRequirements PasswordRequirements `json:"passwordRequirements"`
+	PasswordRequirements *PasswordRequirements `json:"passwordRequirements,omitempty" note:"replaced as a whole, unchanged when omitted"`
+	DefaultAccessKeyTTL  *Duration             `json:"defaultAccessKeyTTL,omitempty" note:"lifetime of access keys created without a ttl, 0 uses the server default, unchanged when omitted"`
+	MaxActiveAccessKeys  *int                  `json:"maxActiveAccessKeys,omitempty" note:"maximum number of access keys, that are not expired, for each user. 0 uses the default of 100, unchanged when omitted"`
+
+	SessionDuration          *Duration `json:"sessionDuration,omitempty" note:"maximum lifetime of a login session, using the session does not extend it past this time. 0 uses the server default, unchanged when omitted"`
+	SessionExtensionDeadline *Duration `json:"sessionExtensionDeadline,omitempty" note:"a login session expires when it is not used for this long. 0 uses the server default, unchanged when omitted"`
+}
+
+func (s Settings) ValidationRules() []validate.ValidationRule {
+	var rules []validate.ValidationRule
+	if s.MaxActiveAccessKeys != nil {
+		rules = append(rules, validate.IntRule{
+			Value: *s.MaxActiveAccessKeys,
+			Name:  "maxActiveAccessKeys",
+			Min:   validate.Int(1),
+		})
+	}
+	return rules
 }
 
 type PasswordRequirements struct {
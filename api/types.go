@@ -148,7 +148,43 @@ func NewListResponse[T, M any](items []M, pr PaginationResponse, fn func(item M)
 	return result
 }
 
+// EnvelopeMediaType is the media type of a response body wrapped in an
+// Envelope. A request that includes the media type in the Accept header
+// receives an Envelope instead of the bare response. Error responses are not
+// wrapped.
+const EnvelopeMediaType = "application/vnd.infrahq.envelope+json"
+
+// Envelope wraps a response body. Data is the response, or the items of a
+// list response.
+type Envelope struct {
+	Data any          `json:"data"`
+	Meta EnvelopeMeta `json:"meta"`
+}
+
+type EnvelopeMeta struct {
+	// Pagination is only set for list responses.
+	Pagination *PaginationResponse `json:"pagination,omitempty"`
+}
+
+// Envelope returns the list response wrapped in an Envelope, with the
+// pagination fields in the Meta.
+func (r *ListResponse[T]) Envelope() Envelope {
+	pagination := r.PaginationResponse
+	return Envelope{
+		Data: r.Items,
+		Meta: EnvelopeMeta{Pagination: &pagination},
+	}
+}
+
 // PEM is a base64 encoded string, commonly used to store certificates and
 // private keys. PEM values will be normalized to remove any leading whitespace
 // and all but a single trailing newline.
 type PEM string
+
+// stringValue returns the value of s, or an empty string when s is nil.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -22,20 +22,29 @@ type User struct {
 	LastSeenAt    Time     `json:"lastSeenAt"`
 	Name          string   `json:"name"`
 	ProviderNames []string `json:"providerNames,omitempty"`
+
+	ResourceVersion int64 `json:"resourceVersion" note:"send this, or the ETag from a GET, in the If-Match header of an update to reject the update if the user was changed"`
 }
 
 type ListUsersRequest struct {
 	Name       string   `form:"name"`
+	Search     string   `form:"search" note:"only users with a name that contains this value, ignoring case"`
 	Group      uid.ID   `form:"group"`
 	IDs        []uid.ID `form:"ids"`
 	ShowSystem bool     `form:"showSystem" note:"if true, this shows the connector and other internal users"`
+	Sort       string   `form:"sort" note:"sort users by name, or by the time they were created. Defaults to name"`
 	PaginationRequest
 }
 
+// UserSortOptions are the allowed values of ListUsersRequest.Sort.
+var UserSortOptions = []string{"name", "created"}
+
 func (r ListUsersRequest) ValidationRules() []validate.ValidationRule {
-	// no-op ValidationRules implementation so that the rules from the
-	// embedded PaginationRequest struct are not applied twice.
-	return nil
+	// the rules from the embedded PaginationRequest struct are not included
+	// so that they are not applied twice.
+	return []validate.ValidationRule{
+		validate.Enum("sort", r.Sort, UserSortOptions),
+	}
 }
 
 // CreateUserRequest is only for creating users with the Infra provider
@@ -68,6 +77,20 @@ func (r UpdateUserRequest) ValidationRules() []validate.ValidationRule {
 	}
 }
 
+// PatchUserRequest updates only the fields of a user that are set in the
+// request. Fields that are omitted, or null, are left unchanged.
+type PatchUserRequest struct {
+	ID       uid.ID  `uri:"id" json:"-"`
+	Password *string `json:"password"`
+}
+
+func (r PatchUserRequest) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("id", r.ID),
+		validate.Required("password", stringValue(r.Password)),
+	}
+}
+
 func (req ListUsersRequest) SetPage(page int) Paginatable {
 	req.PaginationRequest.Page = page
 
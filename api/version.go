@@ -3,3 +3,13 @@ package api
 type Version struct {
 	Version string `json:"version"`
 }
+
+// DeprecatedRoute is an API route that is deprecated or has been removed.
+type DeprecatedRoute struct {
+	Method       string `json:"method" example:"GET"`
+	Path         string `json:"path" example:"/api/signup"`
+	DeprecatedIn string `json:"deprecatedIn,omitempty" note:"version of infra that deprecated the route"`
+	RemovedIn    string `json:"removedIn,omitempty" note:"version of infra that removed the route, requests to a removed route fail with 410 Gone"`
+	Sunset       *Time  `json:"sunset,omitempty" note:"time after which a deprecated route may be removed"`
+	ReplacedBy   string `json:"replacedBy,omitempty" note:"path of the route that replaces this one"`
+}
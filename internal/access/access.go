@@ -4,10 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
 )
 
@@ -33,7 +36,47 @@ func hasAuthorization(c *gin.Context, requestedResource uid.ID, isResourceOwner
 
 const ResourceInfraAPI = "infra"
 
-// RequireInfraRole checks that the identity in the context can perform an action on a resource based on their granted roles
+// infraRoleHierarchy maps each role of the infra API resource to the lower
+// roles it implies. A grant for a role satisfies a requirement for any of the
+// roles it implies, directly or through another role.
+var infraRoleHierarchy = map[string][]string{
+	models.InfraAdminRole: {models.InfraViewRole},
+}
+
+// rolesSatisfying returns all the roles that satisfy a requirement for one of
+// oneOfRoles, using infraRoleHierarchy.
+func rolesSatisfying(oneOfRoles []string) []string {
+	satisfied := make(map[string]bool, len(oneOfRoles))
+	result := make([]string, 0, len(oneOfRoles))
+	for _, role := range oneOfRoles {
+		if !satisfied[role] {
+			satisfied[role] = true
+			result = append(result, role)
+		}
+	}
+
+	// repeat until no more roles are added, so that the hierarchy is transitive
+	for added := true; added; {
+		added = false
+		for role, implied := range infraRoleHierarchy {
+			if satisfied[role] {
+				continue
+			}
+			for _, impliedRole := range implied {
+				if satisfied[impliedRole] {
+					satisfied[role] = true
+					result = append(result, role)
+					added = true
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+// RequireInfraRole checks that the identity in the context can perform an action on a resource based on their granted roles.
+// A role higher in infraRoleHierarchy satisfies a requirement for a lower role.
 func RequireInfraRole(c *gin.Context, oneOfRoles ...string) (data.GormTxn, error) {
 	rCtx := GetRequestContext(c)
 	db := rCtx.DBTxn
@@ -42,7 +85,7 @@ func RequireInfraRole(c *gin.Context, oneOfRoles ...string) (data.GormTxn, error
 		return nil, fmt.Errorf("no active identity")
 	}
 
-	ok, err := Can(db, identity.PolyID(), ResourceInfraAPI, oneOfRoles...)
+	ok, err := Can(db, identity.PolyID(), ResourceInfraAPI, rolesSatisfying(oneOfRoles)...)
 	switch {
 	case err != nil:
 		return nil, err
@@ -99,18 +142,105 @@ func HandleAuthErr(err error, resource, operation string, roles ...string) error
 	}
 }
 
-// Can checks if an identity has a privilege that means it can perform an action on a resource
+// Can checks if an identity has a privilege that means it can perform an action on a resource.
+// The result may be cached, see SetAuthorizationCacheTTL.
+//
+// Grants with a wildcard resource, like "kubernetes.*", match any resource
+// with the same prefix, like "kubernetes.prod". Wildcard grants never match
+// the infra API resource. Grants only ever allow access, so when both a
+// wildcard and an exact grant match a resource, access is allowed if either
+// grant has one of the privileges.
 func Can(db data.GormTxn, identity uid.PolymorphicID, resource string, privileges ...string) (bool, error) {
+	key := newAuthorizationCacheKey(db.OrganizationID(), identity, resource, privileges)
+	if allowed, ok := canCache.get(key); ok {
+		return allowed, nil
+	}
+
 	grants, err := data.ListGrants(db, data.ListGrantsOptions{
 		Pagination:                 &data.Pagination{Limit: 1},
 		BySubject:                  identity,
 		ByPrivileges:               privileges,
 		ByResource:                 resource,
+		IncludeWildcardResources:   resource != ResourceInfraAPI,
 		IncludeInheritedFromGroups: true,
 	})
 	if err != nil {
 		return false, fmt.Errorf("has grants: %w", err)
 	}
 
-	return len(grants) > 0, nil
+	allowed := len(grants) > 0
+	var until time.Time
+	if allowed && grants[0].ExpiresAt != nil {
+		// do not allow access from the cache after the grant expires
+		until = *grants[0].ExpiresAt
+	}
+	canCache.setUntil(key, allowed, until)
+	return allowed, nil
+}
+
+// ResourceQuery is a single authorization check used by CanBatch.
+type ResourceQuery struct {
+	Resource string
+	// Privileges are the privileges that allow access to Resource. Access is
+	// allowed if the identity has any one of them.
+	Privileges []string
+}
+
+// CanBatch checks if an identity can access each of the resources in queries.
+// Unlike Can, all the grants for the identity are loaded with a single query,
+// and each resource is checked in memory. The returned map is keyed by
+// resource, so each resource may only be included once.
+func CanBatch(db data.GormTxn, identity uid.PolymorphicID, queries []ResourceQuery) (map[string]bool, error) {
+	result := make(map[string]bool, len(queries))
+	for _, query := range queries {
+		if _, ok := result[query.Resource]; ok {
+			return nil, fmt.Errorf("%w: resource %v is included more than once", internal.ErrBadRequest, query.Resource)
+		}
+		result[query.Resource] = false
+	}
+
+	grants, err := data.ListGrants(db, data.ListGrantsOptions{
+		BySubject:                  identity,
+		IncludeInheritedFromGroups: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("has grants: %w", err)
+	}
+
+	for _, query := range queries {
+		result[query.Resource] = hasGrantFor(grants, query)
+	}
+	return result, nil
+}
+
+func hasGrantFor(grants []models.Grant, query ResourceQuery) bool {
+	privileges := query.Privileges
+	if query.Resource == ResourceInfraAPI {
+		// use the same role hierarchy as RequireInfraRole
+		privileges = rolesSatisfying(privileges)
+	}
+
+	for _, grant := range grants {
+		if !resourceMatches(grant.Resource, query.Resource) {
+			continue
+		}
+		for _, privilege := range privileges {
+			if grant.Privilege == privilege {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resourceMatches returns true if a grant for grantResource applies to
+// resource. It uses the same rules as data.ListGrantsOptions.IncludeWildcardResources.
+func resourceMatches(grantResource, resource string) bool {
+	if grantResource == resource {
+		return true
+	}
+	if resource == ResourceInfraAPI || !strings.HasSuffix(grantResource, ".*") {
+		return false
+	}
+	return strings.HasPrefix(resource, strings.TrimSuffix(grantResource, "*"))
 }
@@ -2,16 +2,19 @@ package access
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/uid"
 )
 
-func ListAccessKeys(c *gin.Context, identityID uid.ID, name string, showExpired bool, p *data.Pagination) ([]models.AccessKey, error) {
+func ListAccessKeys(c *gin.Context, identityID, providerID uid.ID, name string, showExpired bool, p *data.Pagination) ([]models.AccessKey, error) {
 	rCtx := GetRequestContext(c)
 	if identityID == rCtx.Authenticated.User.ID {
 		// can list own keys
@@ -27,6 +30,7 @@ func ListAccessKeys(c *gin.Context, identityID uid.ID, name string, showExpired
 		Pagination:     p,
 		IncludeExpired: showExpired,
 		ByIssuedForID:  identityID,
+		ByProviderID:   providerID,
 		ByName:         name,
 	}
 	return data.ListAccessKeys(rCtx.DBTxn, opts)
@@ -40,27 +44,139 @@ func CreateAccessKey(c *gin.Context, accessKey *models.AccessKey) (body string,
 		return "", fmt.Errorf("%w: cannot use an access key not issued from login to create other access keys", internal.ErrBadRequest)
 	}
 
-	if accessKey.IssuedFor == rCtx.Authenticated.User.ID {
-		// can create access keys for yourself.
-	} else {
+	// any user can create access keys for themselves, only admins can create
+	// access keys for other users.
+	if accessKey.IssuedFor != rCtx.Authenticated.User.ID {
 		_, err = RequireInfraRole(c, models.InfraAdminRole)
 		if err != nil {
-			return "", HandleAuthErr(err, "access key", "create", models.InfraAdminRole)
+			return "", HandleAuthErr(err, "access key for another user", "create", models.InfraAdminRole)
+		}
+	}
+
+	if accessKey.Secret != "" {
+		// importing a known secret is only used for migrations and recovery
+		_, err = RequireInfraRole(c, models.InfraAdminRole)
+		if err != nil {
+			return "", HandleAuthErr(err, "access key", "import", models.InfraAdminRole)
+		}
+		if err := checkImportedSecret(accessKey.Secret); err != nil {
+			return "", err
 		}
 	}
 
 	if accessKey.ProviderID == 0 {
 		accessKey.ProviderID = data.InfraProvider(rCtx.DBTxn).ID
 	}
+	accessKey.CreatedBy = rCtx.Authenticated.User.ID
+
+	if accessKey.Name != "" {
+		issuedFor, err := data.GetIdentity(rCtx.DBTxn, data.ByID(accessKey.IssuedFor))
+		if err != nil {
+			return "", fmt.Errorf("get identity: %w", err)
+		}
+		if isGeneratedAccessKeyName(accessKey.Name, issuedFor.Name) {
+			return "", validate.Error{"name": {
+				fmt.Sprintf("%q is reserved for generated names, which use the format <identity>-<id>", accessKey.Name),
+			}}
+		}
+	}
+
+	if err := checkMaxActiveAccessKeys(rCtx.DBTxn, accessKey.IssuedFor); err != nil {
+		return "", err
+	}
 
 	body, err = data.CreateAccessKey(rCtx.DBTxn, accessKey)
 	if err != nil {
 		return "", fmt.Errorf("create token: %w", err)
 	}
 
+	if err := auditAccessKey(rCtx, models.AuditActionCreateAccessKey, *accessKey); err != nil {
+		return "", err
+	}
+
 	return body, err
 }
 
+// checkMaxActiveAccessKeys returns an error if the identity already has the
+// maximum number of access keys that are not expired. Expired and deleted
+// keys do not count towards the limit. The identity is locked until tx ends, so
+// that concurrent requests can not create more keys than the limit.
+func checkMaxActiveAccessKeys(tx *data.Transaction, identityID uid.ID) error {
+	limit, err := data.MaxActiveAccessKeys(tx)
+	if err != nil {
+		return err
+	}
+
+	count, err := data.CountActiveAccessKeys(tx, identityID)
+	if err != nil {
+		return fmt.Errorf("count access keys: %w", err)
+	}
+	if count >= limit {
+		return fmt.Errorf("%w: the user has reached the limit of %d active access keys, delete unused access keys before creating a new one",
+			internal.ErrBadRequest, limit)
+	}
+	return nil
+}
+
+// minImportedSecretEntropy is the minimum entropy, in bits, of an imported
+// access key secret. Generated secrets almost always have more than 80 bits.
+const minImportedSecretEntropy = 72
+
+// checkImportedSecret returns an error if secret is not the length of a
+// generated secret, or if it is too predictable to be used as a secret.
+func checkImportedSecret(secret string) error {
+	if len(secret) != models.AccessKeySecretLength {
+		return validate.Error{"secret": {fmt.Sprintf("must be %d characters", models.AccessKeySecretLength)}}
+	}
+	if secretEntropy(secret) < minImportedSecretEntropy {
+		return validate.Error{"secret": {"is too predictable, use a randomly generated secret"}}
+	}
+	return nil
+}
+
+// secretEntropy estimates the entropy of secret in bits, using the frequency
+// of each character in secret.
+func secretEntropy(secret string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range secret {
+		counts[c]++
+	}
+
+	var bitsPerChar float64
+	n := float64(len(secret))
+	for _, count := range counts {
+		p := float64(count) / n
+		bitsPerChar -= p * math.Log2(p)
+	}
+	return bitsPerChar * n
+}
+
+// isGeneratedAccessKeyName returns true if name has the same format as the
+// names generated by data.CreateAccessKey for keys issued to identityName.
+func isGeneratedAccessKeyName(name, identityName string) bool {
+	prefix := identityName + "-"
+	if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+		return false
+	}
+	_, err := uid.Parse([]byte(strings.TrimPrefix(name, prefix)))
+	return err == nil
+}
+
+// auditAccessKey records an audit event for a change to key made by the
+// authenticated user.
+func auditAccessKey(rCtx RequestContext, action string, keys ...models.AccessKey) error {
+	return data.CreateAccessKeyAuditEvents(rCtx.DBTxn, action, actorID(rCtx), keys...)
+}
+
+// actorID returns the ID of the authenticated user, or zero if the request
+// was not made by a user.
+func actorID(rCtx RequestContext) uid.ID {
+	if rCtx.Authenticated.User != nil {
+		return rCtx.Authenticated.User.ID
+	}
+	return 0
+}
+
 func DeleteAccessKey(c *gin.Context, id uid.ID) error {
 	rCtx := GetRequestContext(c)
 
@@ -78,12 +194,51 @@ func DeleteAccessKey(c *gin.Context, id uid.ID) error {
 		}
 	}
 
-	return data.DeleteAccessKeys(rCtx.DBTxn, data.DeleteAccessKeysOptions{ByID: id})
+	_, err = data.DeleteAccessKeys(rCtx.DBTxn, data.DeleteAccessKeysOptions{
+		ByID:    id,
+		ActorID: actorID(rCtx),
+	})
+	return err
+}
+
+// DeleteAccessKeysByNamePrefix deletes all the access keys with a name that
+// starts with prefix, and returns the keys that were deleted. When dryRun is
+// true the keys that would be deleted are returned, and nothing is deleted.
+func DeleteAccessKeysByNamePrefix(c *gin.Context, prefix string, dryRun bool) ([]models.AccessKey, error) {
+	db, err := RequireInfraRole(c, models.InfraAdminRole)
+	if err != nil {
+		return nil, HandleAuthErr(err, "access keys", "delete", models.InfraAdminRole)
+	}
+
+	if dryRun {
+		return data.DeleteAccessKeys(db, data.DeleteAccessKeysOptions{ByNamePrefix: prefix, DryRun: true})
+	}
+
+	return data.DeleteAccessKeys(db, data.DeleteAccessKeysOptions{
+		ByNamePrefix: prefix,
+		ActorID:      actorID(GetRequestContext(c)),
+	})
 }
 
 func DeleteRequestAccessKey(c RequestContext) error {
 	// does not need authorization check, this action is limited to the calling key
 
-	id := c.Authenticated.AccessKey.ID
-	return data.DeleteAccessKeys(c.DBTxn, data.DeleteAccessKeysOptions{ByID: id})
+	key := c.Authenticated.AccessKey
+	if key == nil {
+		return fmt.Errorf("%w: the request was not authenticated with an access key", internal.ErrUnauthorized)
+	}
+
+	deleted, err := data.DeleteAccessKeys(c.DBTxn, data.DeleteAccessKeysOptions{
+		ByID:    key.ID,
+		ActorID: actorID(c),
+	})
+	if err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		// the key was deleted by another request after it was used to
+		// authenticate this one
+		return fmt.Errorf("%w: the access key has already been deleted", internal.ErrUnauthorized)
+	}
+	return nil
 }
@@ -1,13 +1,17 @@
 package access
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"gotest.tools/v3/assert"
 
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/generate"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
 )
 
 func TestAccessKeys_SelfManagement(t *testing.T) {
@@ -22,7 +26,7 @@ func TestAccessKeys_SelfManagement(t *testing.T) {
 	err = data.CreateIdentity(db, user)
 	assert.NilError(t, err)
 
-	c, _ := loginAs(&data.Transaction{DB: db.DB}, user, org)
+	c, tx := loginAs(&data.Transaction{DB: db.DB}, user, org)
 
 	t.Run("can manage my own keys", func(t *testing.T) {
 		key := &models.AccessKey{
@@ -33,13 +37,171 @@ func TestAccessKeys_SelfManagement(t *testing.T) {
 		}
 		_, err = CreateAccessKey(c, key)
 		assert.NilError(t, err)
+		assert.Equal(t, key.CreatedBy, user.ID)
 
 		err = DeleteAccessKey(c, key.ID)
 		assert.NilError(t, err)
 	})
 
+	t.Run("audit events are recorded", func(t *testing.T) {
+		key := &models.AccessKey{
+			Name:               "audited-key",
+			OrganizationMember: models.OrganizationMember{OrganizationID: org.ID},
+			IssuedFor:          user.ID,
+			ExpiresAt:          time.Now().Add(1 * time.Minute),
+		}
+		_, err = CreateAccessKey(c, key)
+		assert.NilError(t, err)
+
+		events, err := data.ListAuditEvents(tx, data.ListAuditEventsOptions{ByTargetID: key.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(events), 1)
+		assert.Equal(t, events[0].Action, models.AuditActionCreateAccessKey)
+		assert.Equal(t, events[0].ActorID, user.ID)
+		assert.Equal(t, events[0].TargetName, "audited-key")
+		assert.Equal(t, events[0].ProviderID, key.ProviderID)
+
+		err = DeleteAccessKey(c, key.ID)
+		assert.NilError(t, err)
+
+		events, err = data.ListAuditEvents(tx, data.ListAuditEventsOptions{ByTargetID: key.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(events), 2)
+		assert.Equal(t, events[1].Action, models.AuditActionDeleteAccessKey)
+		assert.Equal(t, events[1].ActorID, user.ID)
+	})
+
+	t.Run("can not create a key for another user", func(t *testing.T) {
+		other := &models.Identity{Name: "jane@example.com", OrganizationMember: models.OrganizationMember{OrganizationID: org.ID}}
+		err := data.CreateIdentity(tx, other)
+		assert.NilError(t, err)
+
+		key := &models.AccessKey{
+			Name:               "other-key",
+			OrganizationMember: models.OrganizationMember{OrganizationID: org.ID},
+			IssuedFor:          other.ID,
+			ExpiresAt:          time.Now().Add(1 * time.Minute),
+		}
+		_, err = CreateAccessKey(c, key)
+		var authzErr AuthorizationError
+		assert.Assert(t, errors.As(err, &authzErr), "wrong error: %v", err)
+		assert.Equal(t, authzErr.Error(),
+			"you do not have permission to create access key for another user, requires role admin")
+	})
+
 	t.Run("can list my own keys", func(t *testing.T) {
-		_, err := ListAccessKeys(c, user.ID, "", true, &data.Pagination{})
+		_, err := ListAccessKeys(c, user.ID, 0, "", true, &data.Pagination{})
 		assert.NilError(t, err)
 	})
+
+	t.Run("can not import a secret without the admin role", func(t *testing.T) {
+		secret, err := generate.CryptoRandom(models.AccessKeySecretLength, generate.CharsetAlphaNumeric)
+		assert.NilError(t, err)
+
+		key := &models.AccessKey{
+			Name:               "imported-key",
+			OrganizationMember: models.OrganizationMember{OrganizationID: org.ID},
+			IssuedFor:          user.ID,
+			ExpiresAt:          time.Now().Add(1 * time.Minute),
+			Secret:             secret,
+		}
+		_, err = CreateAccessKey(c, key)
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+}
+
+func TestCreateAccessKey_MaxActiveAccessKeys(t *testing.T) {
+	db := setupDB(t)
+
+	org := &models.Organization{Name: "joe's jackets", Domain: "joes-jackets"}
+	err := data.CreateOrganization(db, org)
+	assert.NilError(t, err)
+
+	user := &models.Identity{Name: "joe@example.com", OrganizationMember: models.OrganizationMember{OrganizationID: org.ID}}
+	err = data.CreateIdentity(db, user)
+	assert.NilError(t, err)
+
+	c, tx := loginAs(&data.Transaction{DB: db.DB}, user, org)
+
+	settings, err := data.GetSettings(tx)
+	assert.NilError(t, err)
+	settings.MaxActiveAccessKeys = 2
+	assert.NilError(t, data.SaveSettings(tx, settings))
+
+	createKey := func(t *testing.T, expiresAt time.Time) error {
+		t.Helper()
+		key := &models.AccessKey{
+			OrganizationMember: models.OrganizationMember{OrganizationID: org.ID},
+			IssuedFor:          user.ID,
+			ExpiresAt:          expiresAt,
+		}
+		_, err := CreateAccessKey(c, key)
+		return err
+	}
+
+	// expired keys do not count towards the limit
+	_, err = data.CreateAccessKey(tx, &models.AccessKey{
+		IssuedFor:  user.ID,
+		ProviderID: data.InfraProvider(tx).ID,
+		ExpiresAt:  time.Now().Add(-time.Minute),
+	})
+	assert.NilError(t, err)
+
+	t.Run("below the limit", func(t *testing.T) {
+		assert.NilError(t, createKey(t, time.Now().Add(time.Hour)))
+		assert.NilError(t, createKey(t, time.Now().Add(time.Hour)))
+	})
+
+	t.Run("at the limit", func(t *testing.T) {
+		err := createKey(t, time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, internal.ErrBadRequest)
+		assert.ErrorContains(t, err, "reached the limit of 2 active access keys, delete unused access keys")
+	})
+
+	t.Run("above the limit", func(t *testing.T) {
+		settings.MaxActiveAccessKeys = 1
+		assert.NilError(t, data.SaveSettings(tx, settings))
+
+		err := createKey(t, time.Now().Add(time.Hour))
+		assert.ErrorContains(t, err, "reached the limit of 1 active access keys")
+	})
+
+	t.Run("below the limit after a key is deleted", func(t *testing.T) {
+		settings.MaxActiveAccessKeys = 2
+		assert.NilError(t, data.SaveSettings(tx, settings))
+
+		keys, err := data.ListAccessKeys(tx, data.ListAccessKeyOptions{ByIssuedForID: user.ID})
+		assert.NilError(t, err)
+		assert.NilError(t, DeleteAccessKey(c, keys[0].ID))
+
+		assert.NilError(t, createKey(t, time.Now().Add(time.Hour)))
+	})
+}
+
+func TestCheckImportedSecret(t *testing.T) {
+	secret, err := generate.CryptoRandom(models.AccessKeySecretLength, generate.CharsetAlphaNumeric)
+	assert.NilError(t, err)
+	assert.NilError(t, checkImportedSecret(secret))
+
+	err = checkImportedSecret("tooshort")
+	assert.Error(t, err, "validation failed: secret: must be 24 characters")
+
+	for _, weak := range []string{
+		"aaaaaaaaaaaaaaaaaaaaaaaa",
+		"abcabcabcabcabcabcabcabc",
+		"aaaaaaaaaaaabbbbbbbbbbbb",
+	} {
+		err = checkImportedSecret(weak)
+		assert.Error(t, err, "validation failed: secret: is too predictable, use a randomly generated secret", weak)
+	}
+}
+
+func TestIsGeneratedAccessKeyName(t *testing.T) {
+	id := uid.New()
+
+	assert.Assert(t, isGeneratedAccessKeyName("connector-"+id.String(), "connector"))
+	assert.Assert(t, !isGeneratedAccessKeyName("connector-", "connector"))
+	assert.Assert(t, !isGeneratedAccessKeyName("connector-not/a/uid", "connector"))
+	assert.Assert(t, !isGeneratedAccessKeyName("other-"+id.String(), "connector"))
+	assert.Assert(t, !isGeneratedAccessKeyName("connector", "connector"))
 }
@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http/httptest"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gotest.tools/v3/assert"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/internal/testing/database"
@@ -70,7 +72,7 @@ func setupAccessTestContext(t *testing.T) (*gin.Context, *data.Transaction, *mod
 
 func txnForTestCase(t *testing.T, db *data.DB) *data.Transaction {
 	t.Helper()
-	tx, err := db.Begin(context.Background())
+	tx, err := db.Begin(context.Background(), nil)
 	assert.NilError(t, err)
 	t.Cleanup(func() {
 		assert.NilError(t, tx.Rollback())
@@ -104,6 +106,207 @@ func TestCan(t *testing.T) {
 	cant(t, db, "i:a11ce", "read", "infra")
 	cant(t, db, "i:a11ce", "read", "infra.machines.1")
 	cant(t, db, "i:a11ce", "write", "infra.machines")
+
+	grant(t, db, tom, "i:carina", "view", "kubernetes.*")
+	can(t, db, "i:carina", "view", "kubernetes.prod")
+	can(t, db, "i:carina", "view", "kubernetes.staging.default")
+	cant(t, db, "i:carina", "view", "kubernetes")
+	cant(t, db, "i:carina", "view", "kube.prod")
+	cant(t, db, "i:carina", "edit", "kubernetes.prod")
+
+	// wildcard grants never match the infra API
+	grant(t, db, tom, "i:dave", "admin", "infra.*")
+	cant(t, db, "i:dave", "admin", "infra")
+
+	// an exact grant adds to the privileges from a wildcard grant
+	grant(t, db, tom, "i:carina", "edit", "kubernetes.prod")
+	can(t, db, "i:carina", "edit", "kubernetes.prod")
+	can(t, db, "i:carina", "view", "kubernetes.prod")
+	cant(t, db, "i:carina", "edit", "kubernetes.staging")
+}
+
+func TestCan_ExpiredGrant(t *testing.T) {
+	db := setupDB(t)
+
+	expired := time.Now().Add(-time.Minute)
+	err := data.CreateGrant(db, &models.Grant{
+		Subject:   "i:expired",
+		Privilege: "view",
+		Resource:  "kubernetes.prod",
+		ExpiresAt: &expired,
+	})
+	assert.NilError(t, err)
+	cant(t, db, "i:expired", "view", "kubernetes.prod")
+
+	future := time.Now().Add(time.Hour)
+	err = data.CreateGrant(db, &models.Grant{
+		Subject:   "i:expiring",
+		Privilege: "view",
+		Resource:  "kubernetes.prod",
+		ExpiresAt: &future,
+	})
+	assert.NilError(t, err)
+	can(t, db, "i:expiring", "view", "kubernetes.prod")
+}
+
+func TestCanBatch(t *testing.T) {
+	db := setupDB(t)
+
+	provider := data.InfraProvider(db)
+	user := &models.Identity{Name: "batch@example.com"}
+	group := &models.Group{Name: "batch group"}
+
+	err := data.CreateIdentity(db, user)
+	assert.NilError(t, err)
+	_, err = data.CreateProviderUser(db, provider, user)
+	assert.NilError(t, err)
+	err = data.CreateGroup(db, group)
+	assert.NilError(t, err)
+	err = data.AssignIdentityToGroups(db, user, provider, []string{group.Name})
+	assert.NilError(t, err)
+
+	grant(t, db, tom, user.PolyID(), "view", "kubernetes.prod")
+	grant(t, db, tom, group.PolyID(), "edit", "kubernetes.staging")
+	grant(t, db, tom, group.PolyID(), "view", "kubernetes.dev.*")
+
+	t.Run("mixed allowed and denied", func(t *testing.T) {
+		tx := txnForTestCase(t, db)
+		actual, err := CanBatch(tx, user.PolyID(), []ResourceQuery{
+			{Resource: "kubernetes.prod", Privileges: []string{"view"}},
+			{Resource: "kubernetes.prod.default", Privileges: []string{"view"}},
+			{Resource: "kubernetes.staging", Privileges: []string{"view", "edit"}},
+			{Resource: "kubernetes.staging.default", Privileges: []string{"edit"}},
+			{Resource: "kubernetes.dev.default", Privileges: []string{"view"}},
+			{Resource: "kubernetes.dev", Privileges: []string{"view"}},
+			{Resource: "infra", Privileges: []string{models.InfraAdminRole}},
+		})
+		assert.NilError(t, err)
+
+		expected := map[string]bool{
+			"kubernetes.prod":            true,
+			"kubernetes.prod.default":    false,
+			"kubernetes.staging":         true,
+			"kubernetes.staging.default": false,
+			"kubernetes.dev.default":     true,
+			"kubernetes.dev":             false,
+			"infra":                      false,
+		}
+		assert.DeepEqual(t, actual, expected)
+	})
+
+	t.Run("higher infra role satisfies a lower role", func(t *testing.T) {
+		tx := txnForTestCase(t, db)
+		admin := &models.Identity{Name: "batch-admin@example.com"}
+		assert.NilError(t, data.CreateIdentity(tx, admin))
+		grant(t, tx, tom, admin.PolyID(), models.InfraAdminRole, "infra")
+
+		actual, err := CanBatch(tx, admin.PolyID(), []ResourceQuery{
+			{Resource: "infra", Privileges: []string{models.InfraViewRole}},
+		})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, actual, map[string]bool{"infra": true})
+	})
+
+	t.Run("no queries", func(t *testing.T) {
+		tx := txnForTestCase(t, db)
+		actual, err := CanBatch(tx, user.PolyID(), nil)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, actual, map[string]bool{})
+	})
+
+	t.Run("duplicate resource", func(t *testing.T) {
+		tx := txnForTestCase(t, db)
+		_, err := CanBatch(tx, user.PolyID(), []ResourceQuery{
+			{Resource: "kubernetes.prod", Privileges: []string{"view"}},
+			{Resource: "kubernetes.prod", Privileges: []string{"edit"}},
+		})
+		assert.ErrorIs(t, err, internal.ErrBadRequest)
+	})
+}
+
+func TestCan_AuthorizationCache(t *testing.T) {
+	SetAuthorizationCacheTTL(time.Minute)
+	t.Cleanup(func() {
+		SetAuthorizationCacheTTL(0)
+	})
+
+	c, tx, _ := setupAccessTestContext(t)
+
+	user := &models.Identity{Name: "cached@example.com"}
+	err := data.CreateIdentity(tx, user)
+	assert.NilError(t, err)
+
+	ok, err := Can(tx, user.PolyID(), ResourceInfraAPI, models.InfraViewRole)
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	t.Run("result is cached", func(t *testing.T) {
+		// grants created without the access package do not clear the cache
+		grant(t, tx, user, user.PolyID(), models.InfraViewRole, ResourceInfraAPI)
+
+		ok, err := Can(tx, user.PolyID(), ResourceInfraAPI, models.InfraViewRole)
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("cache is cleared by CreateGrant", func(t *testing.T) {
+		err := CreateGrant(c, &models.Grant{
+			Subject:   user.PolyID(),
+			Privilege: models.InfraAdminRole,
+			Resource:  ResourceInfraAPI,
+		})
+		assert.NilError(t, err)
+
+		ok, err := Can(tx, user.PolyID(), ResourceInfraAPI, models.InfraViewRole)
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+	})
+
+	t.Run("cache is cleared by DeleteGrant", func(t *testing.T) {
+		grants, err := data.ListGrants(tx, data.ListGrantsOptions{BySubject: user.PolyID()})
+		assert.NilError(t, err)
+
+		for _, g := range grants {
+			assert.NilError(t, DeleteGrant(c, g.ID))
+		}
+
+		ok, err := Can(tx, user.PolyID(), ResourceInfraAPI, models.InfraViewRole)
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	})
+}
+
+func BenchmarkCan(b *testing.B) {
+	driver := database.PostgresDriver(b, "_access")
+	patch.ModelsSymmetricKey(b)
+	db, err := data.NewDB(driver.Dialector, data.NewDBOptions{})
+	assert.NilError(b, err)
+
+	user := &models.Identity{Name: "bench@example.com"}
+	assert.NilError(b, data.CreateIdentity(db, user))
+	err = data.CreateGrant(db, &models.Grant{
+		Subject:   user.PolyID(),
+		Privilege: models.InfraViewRole,
+		Resource:  ResourceInfraAPI,
+	})
+	assert.NilError(b, err)
+
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		b.Run(fmt.Sprintf("cache ttl %v", ttl), func(b *testing.B) {
+			SetAuthorizationCacheTTL(ttl)
+			b.Cleanup(func() {
+				SetAuthorizationCacheTTL(0)
+			})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ok, err := Can(db, user.PolyID(), ResourceInfraAPI, models.InfraAdminRole, models.InfraViewRole)
+				if err != nil || !ok {
+					b.Fatalf("expected access, got %v %v", ok, err)
+				}
+			}
+		})
+	}
 }
 
 func TestRequireInfraRole_GrantsFromGroupMembership(t *testing.T) {
@@ -195,6 +398,72 @@ func TestRequireInfraRole(t *testing.T) {
 		assert.ErrorIs(t, err, ErrNotAuthorized)
 		assert.Assert(t, authDB == nil)
 	})
+
+	t.Run("higher role satisfies lower required role", func(t *testing.T) {
+		c := setup(t, models.InfraAdminRole)
+
+		authDB, err := RequireInfraRole(c, models.InfraViewRole)
+		assert.NilError(t, err)
+		assert.Assert(t, authDB != nil)
+	})
+
+	t.Run("unrelated role does not satisfy required role", func(t *testing.T) {
+		c := setup(t, models.InfraConnectorRole)
+
+		authDB, err := RequireInfraRole(c, models.InfraViewRole)
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+		assert.Assert(t, authDB == nil)
+	})
+}
+
+func TestRolesSatisfying(t *testing.T) {
+	orig := infraRoleHierarchy
+	t.Cleanup(func() {
+		infraRoleHierarchy = orig
+	})
+	infraRoleHierarchy = map[string][]string{
+		"owner": {"admin"},
+		"admin": {"edit"},
+		"edit":  {"view"},
+	}
+
+	type testCase struct {
+		name     string
+		roles    []string
+		expected []string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "lowest role",
+			roles:    []string{"view"},
+			expected: []string{"view", "edit", "admin", "owner"},
+		},
+		{
+			name:     "highest role",
+			roles:    []string{"owner"},
+			expected: []string{"owner"},
+		},
+		{
+			name:     "unrelated role",
+			roles:    []string{"connector"},
+			expected: []string{"connector"},
+		},
+		{
+			name:     "duplicate roles",
+			roles:    []string{"admin", "edit", "admin"},
+			expected: []string{"admin", "edit", "owner"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := rolesSatisfying(tc.roles)
+			sort.Strings(actual)
+			sort.Strings(tc.expected)
+			assert.DeepEqual(t, actual, tc.expected)
+		})
+	}
 }
 
 func grant(t *testing.T, db data.GormTxn, createdBy *models.Identity, subject uid.PolymorphicID, privilege, resource string) {
@@ -0,0 +1,119 @@
+package access
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/uid"
+)
+
+// authorizationCache stores the result of Can, so that repeated authorization
+// checks for the same identity, resource, and privileges do not query the
+// database every time. Entries expire after ttl, and the cache is cleared when
+// grants or group memberships change. A ttl of zero disables the cache.
+type authorizationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[authorizationCacheKey]authorizationCacheEntry
+}
+
+type authorizationCacheKey struct {
+	organizationID uid.ID
+	subject        uid.PolymorphicID
+	resource       string
+	privileges     string
+}
+
+type authorizationCacheEntry struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+var canCache = &authorizationCache{
+	now:     time.Now,
+	entries: map[authorizationCacheKey]authorizationCacheEntry{},
+}
+
+func newAuthorizationCacheKey(orgID uid.ID, subject uid.PolymorphicID, resource string, privileges []string) authorizationCacheKey {
+	sorted := make([]string, len(privileges))
+	copy(sorted, privileges)
+	sort.Strings(sorted)
+
+	return authorizationCacheKey{
+		organizationID: orgID,
+		subject:        subject,
+		resource:       resource,
+		privileges:     strings.Join(sorted, ","),
+	}
+}
+
+func (c *authorizationCache) get(key authorizationCacheKey) (allowed bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl == 0 {
+		return false, false
+	}
+
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return false, false
+	}
+	return entry.allowed, true
+}
+
+func (c *authorizationCache) set(key authorizationCacheKey, allowed bool) {
+	c.setUntil(key, allowed, time.Time{})
+}
+
+// setUntil adds an entry to the cache which expires after the ttl, or at until
+// if it is earlier. A zero until is ignored.
+func (c *authorizationCache) setUntil(key authorizationCacheKey, allowed bool, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl == 0 {
+		return
+	}
+
+	now := c.now()
+	// remove expired entries so that the cache does not grow without bound
+	for k, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	expiresAt := now.Add(c.ttl)
+	if !until.IsZero() && until.Before(expiresAt) {
+		expiresAt = until
+	}
+	c.entries[key] = authorizationCacheEntry{allowed: allowed, expiresAt: expiresAt}
+}
+
+func (c *authorizationCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[authorizationCacheKey]authorizationCacheEntry{}
+}
+
+// SetAuthorizationCacheTTL sets the amount of time the result of an
+// authorization check is cached. A ttl of zero disables the cache.
+func SetAuthorizationCacheTTL(ttl time.Duration) {
+	canCache.mu.Lock()
+	defer canCache.mu.Unlock()
+	canCache.ttl = ttl
+	canCache.entries = map[authorizationCacheKey]authorizationCacheEntry{}
+}
+
+// ClearAuthorizationCache removes all cached authorization checks once the
+// changes made with tx are committed. It must be called with the transaction
+// of any change to grants or group memberships. Clearing the cache before the
+// commit would allow a concurrent request to cache the previous grants again.
+func ClearAuthorizationCache(tx data.ReadTxn) {
+	data.OnCommit(tx, canCache.clear)
+}
@@ -0,0 +1,134 @@
+package access
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/uid"
+)
+
+func TestAuthorizationCache(t *testing.T) {
+	now := time.Date(2022, time.October, 7, 10, 0, 0, 0, time.UTC)
+	cache := &authorizationCache{
+		ttl:     time.Minute,
+		now:     func() time.Time { return now },
+		entries: map[authorizationCacheKey]authorizationCacheEntry{},
+	}
+
+	orgID := uid.ID(1234)
+	key := newAuthorizationCacheKey(orgID, "i:tom", "infra", []string{"view", "admin"})
+
+	_, ok := cache.get(key)
+	assert.Assert(t, !ok)
+
+	cache.set(key, true)
+
+	t.Run("cached", func(t *testing.T) {
+		allowed, ok := cache.get(key)
+		assert.Assert(t, ok)
+		assert.Assert(t, allowed)
+	})
+
+	t.Run("order of privileges does not matter", func(t *testing.T) {
+		other := newAuthorizationCacheKey(orgID, "i:tom", "infra", []string{"admin", "view"})
+		allowed, ok := cache.get(other)
+		assert.Assert(t, ok)
+		assert.Assert(t, allowed)
+	})
+
+	t.Run("other organizations are not cached", func(t *testing.T) {
+		other := newAuthorizationCacheKey(uid.ID(5678), "i:tom", "infra", []string{"view", "admin"})
+		_, ok := cache.get(other)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		_, ok := cache.get(key)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("cleared", func(t *testing.T) {
+		cache.set(key, false)
+		_, ok := cache.get(key)
+		assert.Assert(t, ok)
+
+		cache.clear()
+		_, ok = cache.get(key)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("expires at until when it is before the ttl", func(t *testing.T) {
+		cache.setUntil(key, true, now.Add(10*time.Second))
+		_, ok := cache.get(key)
+		assert.Assert(t, ok)
+
+		now = now.Add(10 * time.Second)
+		_, ok = cache.get(key)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cache.ttl = 0
+		cache.set(key, true)
+		_, ok := cache.get(key)
+		assert.Assert(t, !ok)
+	})
+}
+
+func TestClearAuthorizationCache(t *testing.T) {
+	db := setupDB(t)
+	SetAuthorizationCacheTTL(time.Minute)
+	t.Cleanup(func() {
+		SetAuthorizationCacheTTL(0)
+	})
+
+	key := newAuthorizationCacheKey(db.DefaultOrg.ID, "i:tom", "infra", []string{"view"})
+
+	t.Run("cleared after commit", func(t *testing.T) {
+		canCache.set(key, true)
+
+		tx, err := db.Begin(context.Background(), nil)
+		assert.NilError(t, err)
+		ClearAuthorizationCache(tx)
+
+		_, ok := canCache.get(key)
+		assert.Assert(t, ok, "cleared before commit")
+
+		assert.NilError(t, tx.Commit())
+		_, ok = canCache.get(key)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("not cleared after rollback", func(t *testing.T) {
+		canCache.set(key, true)
+
+		tx, err := db.Begin(context.Background(), nil)
+		assert.NilError(t, err)
+		ClearAuthorizationCache(tx)
+		assert.NilError(t, tx.Rollback())
+
+		_, ok := canCache.get(key)
+		assert.Assert(t, ok)
+	})
+}
+
+func BenchmarkAuthorizationCache_Get(b *testing.B) {
+	cache := &authorizationCache{
+		ttl:     time.Minute,
+		now:     time.Now,
+		entries: map[authorizationCacheKey]authorizationCacheEntry{},
+	}
+	key := newAuthorizationCacheKey(uid.ID(1234), "i:tom", "infra", []string{"view", "admin"})
+	cache.set(key, true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := cache.get(key); !ok {
+			b.Fatal("expected a cached result")
+		}
+	}
+}
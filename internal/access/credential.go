@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"time"
 	"unicode"
 
 	"github.com/gin-gonic/gin"
@@ -102,6 +103,11 @@ func updateCredential(c *gin.Context, user *models.Identity, newPassword string,
 
 	userCredential.PasswordHash = hash
 	userCredential.OneTimePassword = !isSelf
+	// a new password unlocks login, so that an admin can reset the password
+	// of a user that was locked after too many failed attempts.
+	userCredential.FailedLoginAttempts = 0
+	userCredential.FailedLoginWindowStart = time.Time{}
+	userCredential.LockedUntil = time.Time{}
 
 	if err := data.SaveCredential(db, userCredential); err != nil {
 		return fmt.Errorf("saving credentials: %w", err)
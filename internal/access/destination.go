@@ -28,6 +28,41 @@ func SaveDestination(c *gin.Context, destination *models.Destination) error {
 	return data.SaveDestination(db, destination)
 }
 
+// UpdateDestination saves the changes to a destination made with the API, and
+// increments its resource version. When expectedVersion is not nil, the update
+// fails if the destination has a different resource version.
+func UpdateDestination(c *gin.Context, destination *models.Destination, expectedVersion *int64) error {
+	roles := []string{models.InfraAdminRole, models.InfraConnectorRole}
+	db, err := RequireInfraRole(c, roles...)
+	if err != nil {
+		return HandleAuthErr(err, "destination", "update", roles...)
+	}
+
+	if err := data.UpdateDestinationResourceVersion(db, destination, expectedVersion); err != nil {
+		return err
+	}
+	return data.SaveDestination(db, destination)
+}
+
+// PatchDestination updates the fields of the destination that are set in
+// patch, and returns the updated destination.
+func PatchDestination(c *gin.Context, id uid.ID, patch data.DestinationPatch, expectedVersion *int64) (*models.Destination, error) {
+	roles := []string{models.InfraAdminRole, models.InfraConnectorRole}
+	db, err := RequireInfraRole(c, roles...)
+	if err != nil {
+		return nil, HandleAuthErr(err, "destination", "update", roles...)
+	}
+
+	destination := &models.Destination{Model: models.Model{ID: id}}
+	if err := data.UpdateDestinationResourceVersion(db, destination, expectedVersion); err != nil {
+		return nil, err
+	}
+	if err := data.PatchDestination(db, id, patch); err != nil {
+		return nil, err
+	}
+	return data.GetDestination(db, data.ByID(id))
+}
+
 func GetDestination(c *gin.Context, id uid.ID) (*models.Destination, error) {
 	db := getDB(c)
 	return data.GetDestination(db, data.ByID(id))
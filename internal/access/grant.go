@@ -2,6 +2,8 @@ package access
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -19,7 +21,10 @@ func GetGrant(c *gin.Context, id uid.ID) (*models.Grant, error) {
 	return data.GetGrant(db, data.GetGrantOptions{ByID: id})
 }
 
-func ListGrants(c *gin.Context, subject uid.PolymorphicID, resource string, privilege string, inherited bool, showSystem bool, p *data.Pagination) ([]models.Grant, error) {
+// ListGrants lists the grants that match subject, resource, and any one of
+// privileges. Empty values are ignored. When wildcard is true, grants with a
+// wildcard resource that matches resource are included.
+func ListGrants(c *gin.Context, subject uid.PolymorphicID, resource string, privileges []string, inherited, showSystem, wildcard bool, p *data.Pagination) ([]models.Grant, error) {
 	rCtx := GetRequestContext(c)
 
 	roles := []string{models.InfraAdminRole, models.InfraViewRole, models.InfraConnectorRole}
@@ -47,10 +52,13 @@ func ListGrants(c *gin.Context, subject uid.PolymorphicID, resource string, priv
 		BySubject:                  subject,
 		ExcludeConnectorGrant:      !showSystem,
 		IncludeInheritedFromGroups: inherited,
+		IncludeWildcardResources:   wildcard && resource != ResourceInfraAPI,
 		Pagination:                 p,
 	}
-	if privilege != "" {
-		opts.ByPrivileges = []string{privilege}
+	for _, privilege := range privileges {
+		if privilege != "" {
+			opts.ByPrivileges = append(opts.ByPrivileges, privilege)
+		}
 	}
 	return data.ListGrants(rCtx.DBTxn, opts)
 }
@@ -86,7 +94,62 @@ func CreateGrant(c *gin.Context, grant *models.Grant) error {
 	// TODO: CreatedBy should be set automatically
 	grant.CreatedBy = rCtx.Authenticated.User.ID
 
-	return data.CreateGrant(rCtx.DBTxn, grant)
+	if err := data.CreateGrant(rCtx.DBTxn, grant); err != nil {
+		return err
+	}
+	ClearAuthorizationCache(rCtx.DBTxn)
+	return auditGrant(rCtx, models.AuditActionCreateGrant, grant)
+}
+
+// CreateGrants creates all of grants in the request transaction, and returns
+// whether each one was created. A grant that already exists is replaced by
+// the existing grant, and is not created. Authorization is checked once for
+// the whole batch.
+func CreateGrants(c *gin.Context, grants []*models.Grant) ([]bool, error) {
+	rCtx := GetRequestContext(c)
+
+	roles := []string{models.InfraAdminRole}
+	for _, grant := range grants {
+		if grant.Privilege == models.InfraSupportAdminRole && grant.Resource == ResourceInfraAPI {
+			roles = append(roles, models.InfraSupportAdminRole)
+			break
+		}
+	}
+	for _, role := range roles {
+		if _, err := RequireInfraRole(c, role); err != nil {
+			return nil, HandleAuthErr(err, "grants", "create", role)
+		}
+	}
+
+	ClearAuthorizationCache(rCtx.DBTxn)
+	created := make([]bool, len(grants))
+	for i, grant := range grants {
+		// TODO: CreatedBy should be set automatically
+		grant.CreatedBy = rCtx.Authenticated.User.ID
+
+		err := data.CreateGrant(rCtx.DBTxn, grant)
+		var ucErr data.UniqueConstraintError
+		switch {
+		case errors.As(err, &ucErr):
+			existing, err := data.GetGrant(rCtx.DBTxn, data.GetGrantOptions{
+				BySubject:   grant.Subject,
+				ByPrivilege: grant.Privilege,
+				ByResource:  grant.Resource,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("get existing grant: %w", err)
+			}
+			*grant = *existing
+		case err != nil:
+			return nil, err
+		default:
+			created[i] = true
+			if err := auditGrant(rCtx, models.AuditActionCreateGrant, grant); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return created, nil
 }
 
 func DeleteGrant(c *gin.Context, id uid.ID) error {
@@ -95,5 +158,84 @@ func DeleteGrant(c *gin.Context, id uid.ID) error {
 		return HandleAuthErr(err, "grant", "delete", models.InfraAdminRole)
 	}
 
-	return data.DeleteGrants(db, data.DeleteGrantsOptions{ByID: id})
+	grant, err := data.GetGrant(db, data.GetGrantOptions{ByID: id})
+	if err != nil {
+		return err
+	}
+
+	err = data.DeleteGrants(db, data.DeleteGrantsOptions{
+		ByID:    grant.ID,
+		ActorID: actorID(GetRequestContext(c)),
+	})
+	if err != nil {
+		return err
+	}
+	ClearAuthorizationCache(db)
+	return nil
+}
+
+// WatchGrants authorizes a stream of grant events, and returns a function
+// that reports if an event may be sent to the authenticated user. Users with
+// a role that allows them to list all grants receive every grant event. Other
+// users only receive the events for their own grants, and for the grants of
+// the groups they belonged to when the stream started.
+func WatchGrants(c *gin.Context) (func(event models.AuditEvent) bool, error) {
+	rCtx := GetRequestContext(c)
+
+	roles := []string{models.InfraAdminRole, models.InfraViewRole, models.InfraConnectorRole}
+	_, err := RequireInfraRole(c, roles...)
+	err = HandleAuthErr(err, "grants", "watch", roles...)
+	switch {
+	case err == nil:
+		return isGrantEvent, nil
+	case !errors.Is(err, ErrNotAuthorized), rCtx.Authenticated.User == nil:
+		return nil, err
+	}
+
+	user := rCtx.Authenticated.User
+	groups, err := data.ListGroups(rCtx.DBTxn, nil, data.ByGroupMember(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	subjects := map[uid.PolymorphicID]bool{uid.NewIdentityPolymorphicID(user.ID): true}
+	for i := range groups {
+		subjects[groups[i].PolyID()] = true
+	}
+
+	return func(event models.AuditEvent) bool {
+		if !isGrantEvent(event) {
+			return false
+		}
+		grant, ok := GrantFromAuditEvent(event)
+		return ok && subjects[grant.Subject]
+	}, nil
+}
+
+func isGrantEvent(event models.AuditEvent) bool {
+	return event.Action == models.AuditActionCreateGrant || event.Action == models.AuditActionDeleteGrant
+}
+
+// GrantFromAuditEvent returns the grant from an audit event recorded by
+// data.CreateGrantAuditEvents. Only the ID, Subject, Privilege, and Resource of the grant are
+// set. Returns false if the event is not for a grant.
+func GrantFromAuditEvent(event models.AuditEvent) (models.Grant, bool) {
+	if !isGrantEvent(event) {
+		return models.Grant{}, false
+	}
+	parts := strings.SplitN(event.TargetName, " ", 3)
+	if len(parts) != 3 {
+		return models.Grant{}, false
+	}
+	return models.Grant{
+		Model:     models.Model{ID: event.TargetID},
+		Subject:   uid.PolymorphicID(parts[0]),
+		Privilege: parts[1],
+		Resource:  parts[2],
+	}, true
+}
+
+// auditGrant records an audit event for a change to grant made by the
+// authenticated user.
+func auditGrant(rCtx RequestContext, action string, grant *models.Grant) error {
+	return data.CreateGrantAuditEvents(rCtx.DBTxn, action, actorID(rCtx), *grant)
 }
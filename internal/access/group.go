@@ -74,6 +74,15 @@ func DeleteGroup(c *gin.Context, id uid.ID) error {
 	if err != nil {
 		return HandleAuthErr(err, "group", "delete", models.InfraAdminRole)
 	}
+	// delete the grants first, so that the audit events record the actor
+	err = data.DeleteGrants(db, data.DeleteGrantsOptions{
+		BySubject: uid.NewGroupPolymorphicID(id),
+		ActorID:   actorID(GetRequestContext(c)),
+	})
+	if err != nil {
+		return fmt.Errorf("remove grants: %w", err)
+	}
+	ClearAuthorizationCache(db)
 	return data.DeleteGroup(db, id)
 }
 
@@ -104,6 +113,42 @@ func checkIdentitiesInList(db data.GormTxn, ids []uid.ID) ([]uid.ID, error) {
 	return nil, fmt.Errorf("%w: %s", internal.ErrBadRequest, "Couldn't find UIDs: "+strings.Join(uidStrList, ","))
 }
 
+// AddUserToGroup adds the user to the group. The membership is assigned
+// manually, so provider sync does not remove it.
+func AddUserToGroup(c *gin.Context, groupID uid.ID, userID uid.ID) error {
+	db, err := RequireInfraRole(c, models.InfraAdminRole)
+	if err != nil {
+		return HandleAuthErr(err, "group", "update", models.InfraAdminRole)
+	}
+
+	if _, err := data.GetGroup(db, data.ByID(groupID)); err != nil {
+		return err
+	}
+
+	if _, err := checkIdentitiesInList(db, []uid.ID{userID}); err != nil {
+		return err
+	}
+
+	ClearAuthorizationCache(db)
+	return data.AddUsersToGroup(db, groupID, []uid.ID{userID})
+}
+
+// RemoveUserFromGroup removes the user from the group, unless the user was
+// added to the group by an identity provider.
+func RemoveUserFromGroup(c *gin.Context, groupID uid.ID, userID uid.ID) error {
+	db, err := RequireInfraRole(c, models.InfraAdminRole)
+	if err != nil {
+		return HandleAuthErr(err, "group", "update", models.InfraAdminRole)
+	}
+
+	if _, err := data.GetGroup(db, data.ByID(groupID)); err != nil {
+		return err
+	}
+
+	ClearAuthorizationCache(db)
+	return data.RemoveUserFromGroup(db, groupID, userID)
+}
+
 func UpdateUsersInGroup(c *gin.Context, groupID uid.ID, uidsToAdd []uid.ID, uidsToRemove []uid.ID) error {
 	db, err := RequireInfraRole(c, models.InfraAdminRole)
 	if err != nil {
@@ -125,6 +170,8 @@ func UpdateUsersInGroup(c *gin.Context, groupID uid.ID, uidsToAdd []uid.ID, uids
 		return err
 	}
 
+	ClearAuthorizationCache(db)
+
 	if len(addIDList) > 0 {
 		if err := data.AddUsersToGroup(db, groupID, addIDList); err != nil {
 			return err
@@ -132,7 +179,7 @@ func UpdateUsersInGroup(c *gin.Context, groupID uid.ID, uidsToAdd []uid.ID, uids
 	}
 
 	if len(rmIDList) > 0 {
-		if err := data.RemoveUsersFromGroup(db, groupID, rmIDList); err != nil {
+		if err := data.RemoveManualUsersFromGroup(db, groupID, rmIDList); err != nil {
 			return err
 		}
 	}
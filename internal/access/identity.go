@@ -39,6 +39,18 @@ func CreateIdentity(c *gin.Context, identity *models.Identity) error {
 	return data.CreateIdentity(db, identity)
 }
 
+// UpdateIdentityResourceVersion increments the resource version of the
+// identity before it is updated. When expectedVersion is not nil, it fails if
+// the identity has a different resource version.
+func UpdateIdentityResourceVersion(c *gin.Context, identity *models.Identity, expectedVersion *int64) error {
+	db, err := hasAuthorization(c, identity.ID, isIdentitySelf, models.InfraAdminRole)
+	if err != nil {
+		return HandleAuthErr(err, "user", "update", models.InfraAdminRole)
+	}
+
+	return data.UpdateIdentityResourceVersion(db, identity, expectedVersion)
+}
+
 // TODO (https://github.com/infrahq/infra/issues/2318) remove provider user, not user.
 func DeleteIdentity(c *gin.Context, id uid.ID) error {
 	rCtx := GetRequestContext(c)
@@ -60,7 +72,11 @@ func DeleteIdentity(c *gin.Context, id uid.ID) error {
 		return HandleAuthErr(err, "user", "delete", models.InfraAdminRole)
 	}
 
-	if err := data.DeleteAccessKeys(db, data.DeleteAccessKeysOptions{ByIssuedForID: id}); err != nil {
+	_, err = data.DeleteAccessKeys(db, data.DeleteAccessKeysOptions{
+		ByIssuedForID: id,
+		ActorID:       actorID(rCtx),
+	})
+	if err != nil {
 		return fmt.Errorf("delete identity access keys: %w", err)
 	}
 
@@ -87,15 +103,19 @@ func DeleteIdentity(c *gin.Context, id uid.ID) error {
 		}
 	}
 
-	err = data.DeleteGrants(db, data.DeleteGrantsOptions{BySubject: uid.NewIdentityPolymorphicID(id)})
+	err = data.DeleteGrants(db, data.DeleteGrantsOptions{
+		BySubject: uid.NewIdentityPolymorphicID(id),
+		ActorID:   actorID(rCtx),
+	})
 	if err != nil {
 		return fmt.Errorf("delete identity creds: %w", err)
 	}
+	ClearAuthorizationCache(db)
 
 	return data.DeleteIdentity(db, id)
 }
 
-func ListIdentities(c *gin.Context, name string, groupID uid.ID, ids []uid.ID, showSystem bool, p *data.Pagination) ([]models.Identity, error) {
+func ListIdentities(c *gin.Context, name, search string, groupID uid.ID, ids []uid.ID, showSystem bool, sort string, p *data.Pagination) ([]models.Identity, error) {
 	roles := []string{models.InfraAdminRole, models.InfraViewRole, models.InfraConnectorRole}
 	db, err := RequireInfraRole(c, roles...)
 	if err != nil {
@@ -105,10 +125,16 @@ func ListIdentities(c *gin.Context, name string, groupID uid.ID, ids []uid.ID, s
 	selectors := []data.SelectorFunc{
 		data.Preload("Providers"),
 		data.ByOptionalName(name),
+		data.ByOptionalNameContains(search),
 		data.ByOptionalIDs(ids),
 		data.ByOptionalIdentityGroupID(groupID),
 	}
 
+	// identities are sorted by name by default
+	if sort == "created" {
+		selectors = append(selectors, data.OrderBy("created_at"))
+	}
+
 	if !showSystem {
 		selectors = append(selectors, data.NotName(models.InternalInfraConnectorIdentityName))
 	}
@@ -125,7 +151,7 @@ func GetContextProviderIdentity(c RequestContext) (*models.Provider, string, err
 
 	if provider.Kind == models.ProviderKindInfra {
 		// no external verification needed
-		logging.L.Trace().Msg("skipped verifying identity within infra provider, not required")
+		logging.Ctx(c.Request.Context()).Trace().Msg("skipped verifying identity within infra provider, not required")
 		return provider, "", nil
 	}
 
@@ -153,6 +179,12 @@ func UpdateIdentityInfoFromProvider(c RequestContext, oidc providers.OIDCClient)
 		return errors.New("user does not have session with an identity provider")
 	}
 
+	if c.Authenticated.AccessKey.Scopes.Includes(models.ScopeIDTokenLogin) {
+		// there are no provider tokens for a session created from an ID token,
+		// the groups in the ID token were assigned at login
+		return nil
+	}
+
 	db := c.DBTxn
 	provider, err := data.GetProvider(db, data.ByID(c.Authenticated.AccessKey.ProviderID))
 	if err != nil {
@@ -161,17 +193,25 @@ func UpdateIdentityInfoFromProvider(c RequestContext, oidc providers.OIDCClient)
 
 	// get current identity provider groups and account status
 	err = data.SyncProviderUser(ctx, db, identity, provider, oidc)
+	// group membership may have changed
+	ClearAuthorizationCache(db)
 	if err != nil {
 		if errors.Is(err, internal.ErrBadGateway) {
 			return err
 		}
 
-		if nestedErr := data.DeleteAccessKeys(db, data.DeleteAccessKeysOptions{ByIssuedForID: identity.ID}); nestedErr != nil {
-			logging.Errorf("failed to revoke invalid user session: %s", nestedErr)
+		if errors.Is(err, internal.ErrForbidden) {
+			logging.Ctx(ctx).Info().Msgf("session of user %s was revoked by the identity provider", identity.ID)
+		}
+
+		// the keys are revoked by the server, so the audit events have no actor
+		_, nestedErr := data.DeleteAccessKeys(db, data.DeleteAccessKeysOptions{ByIssuedForID: identity.ID})
+		if nestedErr != nil {
+			logging.Ctx(ctx).Error().Err(nestedErr).Msg("failed to revoke invalid user session")
 		}
 
 		if nestedErr := data.DeleteProviderUsers(db, data.ByIdentityID(identity.ID), data.ByProviderID(provider.ID)); nestedErr != nil {
-			logging.Errorf("failed to delete provider user: %s", nestedErr)
+			logging.Ctx(ctx).Error().Err(nestedErr).Msg("failed to delete provider user")
 		}
 
 		return fmt.Errorf("sync user: %w", err)
@@ -64,7 +64,7 @@ func VerifiedPasswordReset(c *gin.Context, token, newPassword string) (*models.I
 	}
 
 	if err := data.DeletePasswordResetToken(db, prt); err != nil {
-		logging.Errorf("deleting password reset token: %s", err)
+		logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to delete password reset token")
 	}
 
 	return user, nil
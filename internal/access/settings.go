@@ -16,12 +16,22 @@ func GetPublicJWK(c RequestContext) ([]jose.JSONWebKey, error) {
 		return nil, fmt.Errorf("could not get JWKs: %w", err)
 	}
 
-	var pubKey jose.JSONWebKey
-	if err := pubKey.UnmarshalJSON(settings.PublicJWK); err != nil {
+	keys, err := data.PublicJWKs(settings)
+	if err != nil {
 		return nil, fmt.Errorf("could not get JWKs: %w", err)
 	}
+	return keys, nil
+}
+
+// RotateSigningKey replaces the key used to sign tokens for the organization.
+func RotateSigningKey(c *gin.Context) error {
+	db, err := RequireInfraRole(c, models.InfraAdminRole)
+	if err != nil {
+		return HandleAuthErr(err, "signing key", "rotate", models.InfraAdminRole)
+	}
 
-	return []jose.JSONWebKey{pubKey}, nil
+	_, err = data.RotateSigningKey(db)
+	return err
 }
 
 func GetSettings(c *gin.Context) (*models.Settings, error) {
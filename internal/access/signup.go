@@ -85,6 +85,10 @@ func Signup(c *gin.Context, keyExpiresAt time.Time, baseDomain string, details S
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create access key after sign-up: %w", err)
 	}
+	err = data.CreateAccessKeyAuditEvents(db, models.AuditActionCreateAccessKey, identity.ID, *accessKey)
+	if err != nil {
+		return nil, "", err
+	}
 
 	// Update the request context so that logging middleware can include the userID
 	rCtx.Authenticated.User = identity
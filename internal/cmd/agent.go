@@ -32,7 +32,10 @@ func newAgentCmd() *cobra.Command {
 				panic(err)
 			}
 
-			logging.UseFileLogger(filepath.Join(infraDir, "agent.log"))
+			err = logging.UseFileLogger(logging.FileOptions{Filename: filepath.Join(infraDir, "agent.log")})
+			if err != nil {
+				return err
+			}
 
 			var wg sync.WaitGroup
 
@@ -158,7 +161,7 @@ func syncKubeConfig(ctx context.Context, cancel context.CancelFunc) {
 		logging.Errorf("agent failed to get user destination grants: %v\n", err)
 		cancel()
 	}
-	if err := writeKubeconfig(user, destinations, grants); err != nil {
+	if err := writeKubeconfig(serverHost(client), user, destinations, grants); err != nil {
 		logging.Errorf("agent failed to update kube config: %v\n", err)
 		cancel()
 	}
@@ -31,7 +31,7 @@ func Run(ctx context.Context, args ...string) error {
 	cli := newCLI(ctx)
 	cmd := NewRootCmd(cli)
 	cmd.SetArgs(args)
-	return cmd.ExecuteContext(ctx)
+	return accessKeyError(cmd.ExecuteContext(ctx))
 }
 
 func mustBeLoggedIn() error {
@@ -89,7 +89,8 @@ func defaultAPIClient() (*api.Client, error) {
 		accessKey = config.AccessKey
 	}
 
-	if envAccessKey, ok := os.LookupEnv("INFRA_ACCESS_KEY"); ok {
+	envAccessKey, fromEnv := os.LookupEnv("INFRA_ACCESS_KEY")
+	if fromEnv {
 		accessKey = envAccessKey
 	}
 
@@ -104,7 +105,34 @@ func defaultAPIClient() (*api.Client, error) {
 		server = envServer
 	}
 
-	return apiClient(server, accessKey, httpTransportForHostConfig(config)), nil
+	client := apiClient(server, accessKey, httpTransportForHostConfig(config))
+	if !fromEnv {
+		client.ReloadAccessKey = func(rejected string) (string, error) {
+			return reloadAccessKeyFromConfig(server, rejected)
+		}
+	}
+	return client, nil
+}
+
+// reloadAccessKeyFromConfig returns the access key of the current host from
+// the config file, when the current host is still host, and the key is
+// different from the one rejected by the server. The key in the config file
+// changes when the user logs in again, for example from another terminal, while
+// a command is running. There is no silent re-login: the server does not issue
+// refresh tokens to the CLI, so when the config file has no other key the user
+// must run 'infra login'.
+func reloadAccessKeyFromConfig(host string, rejected string) (string, error) {
+	config, err := currentHostConfig()
+	if err != nil {
+		return "", err
+	}
+	if config.Host != host {
+		return "", errors.New("the current host in the config file changed")
+	}
+	if config.AccessKey == "" || config.AccessKey == rejected || config.isExpired() {
+		return "", errors.New("no new access key in the config file")
+	}
+	return config.AccessKey, nil
 }
 
 func apiClient(host string, accessKey string, transport *http.Transport) *api.Client {
@@ -152,6 +180,25 @@ func logoutCurrent() {
 }
 
 func httpTransportForHostConfig(config *ClientHostConfig) *http.Transport {
+	if config.PinnedCA != "" {
+		pool := x509.NewCertPool()
+		if ok := pool.AppendCertsFromPEM([]byte(config.PinnedCA)); !ok {
+			logging.Warnf("Failed to read the pinned CA certificate for server %s", config.Host)
+		}
+		return &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    pool,
+			},
+		}
+	}
+
+	if config.SkipTLSVerify {
+		logging.Warnf("WARNING: TLS certificate verification is disabled for server %s. "+
+			"The connection is not secure. Use 'infra login --tls-pinned-ca' to trust "+
+			"the CA of the server instead.", config.Host)
+	}
+
 	pool, err := x509.SystemCertPool()
 	if err != nil {
 		logging.Warnf("Failed to load trusted certificates from system: %v", err)
@@ -350,6 +397,7 @@ func NewRootCmd(cli *CLI) *cobra.Command {
 	rootCmd.AddCommand(newGroupsCmd(cli))
 	rootCmd.AddCommand(newKeysCmd(cli))
 	rootCmd.AddCommand(newProvidersCmd(cli))
+	rootCmd.AddCommand(newServersCmd(cli))
 
 	// Other commands:
 	rootCmd.AddCommand(newInfoCmd(cli))
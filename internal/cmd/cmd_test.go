@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -21,6 +28,7 @@ import (
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal/certs"
 	"github.com/infrahq/infra/internal/connector"
+	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/uid"
 )
 
@@ -159,7 +167,7 @@ func TestUse(t *testing.T) {
 		err := writeConfig(&cfg)
 		assert.NilError(t, err)
 
-		err = clearKubeconfig()
+		err = clearKubeconfig(cfg.Hosts[0].Host)
 		assert.NilError(t, err)
 
 		return &cfg
@@ -339,7 +347,7 @@ func TestInvalidSessions(t *testing.T) {
 		err := writeConfig(&cfg)
 		assert.NilError(t, err)
 
-		err = clearKubeconfig()
+		err = clearKubeconfig(cfg.Hosts[0].Host)
 		assert.NilError(t, err)
 
 		err = Run(context.Background(), "destinations", "list")
@@ -351,7 +359,7 @@ func TestInvalidSessions(t *testing.T) {
 		err := writeConfig(&cfg)
 		assert.NilError(t, err)
 
-		err = clearKubeconfig()
+		err = clearKubeconfig(cfg.Hosts[0].Host)
 		assert.NilError(t, err)
 
 		err = Run(context.Background(), "destinations", "list")
@@ -359,6 +367,182 @@ func TestInvalidSessions(t *testing.T) {
 	})
 }
 
+func TestReloadAccessKey(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // Windows
+
+	// k8s.io/tools/clientcmd reads HOME at import time, so this must be patched too
+	t.Setenv("KUBECONFIG", filepath.Join(home, "config"))
+
+	var onRejected func()
+	var requests []string
+	handler := func(resp http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") != "Bearer the-new-key" {
+			if onRejected != nil {
+				onRejected()
+			}
+			resp.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(resp).Encode(api.Error{
+				Code:      http.StatusUnauthorized,
+				ErrorCode: api.ErrorCodeAccessKeyExpired,
+			})
+			return
+		}
+		_, _ = resp.Write([]byte(`{"items": [], "count": 0}`))
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(handler))
+	t.Cleanup(srv.Close)
+
+	setup := func(t *testing.T) ClientConfig {
+		requests = nil
+		onRejected = nil
+		cfg := newTestClientConfig(srv, api.User{})
+		assert.NilError(t, writeConfig(&cfg))
+		return cfg
+	}
+
+	t.Run("reload then retry", func(t *testing.T) {
+		cfg := setup(t)
+		// the user logged in again from another terminal
+		onRejected = func() {
+			cfg.Hosts[0].AccessKey = "the-new-key"
+			assert.Check(t, writeConfig(&cfg))
+		}
+
+		err := Run(context.Background(), "destinations", "list")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, requests, []string{"Bearer the-access-key", "Bearer the-new-key"})
+
+		actual, err := readConfig()
+		assert.NilError(t, err)
+		assert.Equal(t, actual.Hosts[0].AccessKey, "the-new-key")
+	})
+
+	t.Run("key for a different host is not used", func(t *testing.T) {
+		cfg := setup(t)
+		// the user logged in to another server from another terminal
+		onRejected = func() {
+			cfg.Hosts[0].Current = false
+			cfg.Hosts = append(cfg.Hosts, ClientHostConfig{
+				Name:      "other@example.com",
+				Host:      "other.example.com",
+				AccessKey: "the-new-key",
+				Expires:   api.Time(time.Now().Add(time.Hour)),
+				Current:   true,
+			})
+			assert.Check(t, writeConfig(&cfg))
+		}
+
+		err := Run(context.Background(), "destinations", "list")
+		assert.ErrorContains(t, err, "run 'infra login' to start a new session")
+		assert.DeepEqual(t, requests, []string{"Bearer the-access-key"})
+	})
+
+	t.Run("give up and prompt to login", func(t *testing.T) {
+		setup(t)
+
+		err := Run(context.Background(), "destinations", "list")
+		assert.ErrorContains(t, err, "run 'infra login' to start a new session")
+		assert.DeepEqual(t, requests, []string{"Bearer the-access-key"})
+
+		actual, err := readConfig()
+		assert.NilError(t, err)
+		assert.Equal(t, actual.Hosts[0].AccessKey, "")
+	})
+}
+
+func TestHTTPTransportForHostConfig(t *testing.T) {
+	t.Run("skip TLS verify logs a warning", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logging.PatchLogger(t, buf)
+
+		transport := httpTransportForHostConfig(&ClientHostConfig{
+			Host:          "infra.example.com",
+			SkipTLSVerify: true,
+		})
+		assert.Assert(t, transport.TLSClientConfig.InsecureSkipVerify)
+		assert.Assert(t, is.Contains(buf.String(), "TLS certificate verification is disabled for server infra.example.com"))
+	})
+
+	t.Run("no warning when TLS is verified", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logging.PatchLogger(t, buf)
+
+		transport := httpTransportForHostConfig(&ClientHostConfig{Host: "infra.example.com"})
+		assert.Assert(t, !transport.TLSClientConfig.InsecureSkipVerify)
+		assert.Equal(t, buf.String(), "")
+	})
+
+	caPEM, caCert, caKey := generateTestCA(t)
+	certPEM, keyPEM, err := certs.GenerateCertificate([]string{"127.0.0.1"}, caCert, caKey)
+	assert.NilError(t, err)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NilError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	get := func(t *testing.T, config *ClientHostConfig) error {
+		t.Helper()
+		client := &http.Client{Transport: httpTransportForHostConfig(config)}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+		assert.NilError(t, err)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	t.Run("pinned CA validates the server certificate", func(t *testing.T) {
+		err := get(t, &ClientHostConfig{Host: srv.Listener.Addr().String(), PinnedCA: string(caPEM)})
+		assert.NilError(t, err)
+	})
+
+	t.Run("pinned CA rejects a certificate signed by another CA", func(t *testing.T) {
+		otherPEM, _, _ := generateTestCA(t)
+		err := get(t, &ClientHostConfig{Host: srv.Listener.Addr().String(), PinnedCA: string(otherPEM)})
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+
+	t.Run("pinned CA takes precedence over skip TLS verify", func(t *testing.T) {
+		otherPEM, _, _ := generateTestCA(t)
+		err := get(t, &ClientHostConfig{
+			Host:          srv.Listener.Addr().String(),
+			PinnedCA:      string(otherPEM),
+			SkipTLSVerify: true,
+		})
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+}
+
+func generateTestCA(t *testing.T) ([]byte, *x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NilError(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	assert.NilError(t, err)
+	return certs.PEMEncodeCertificate(raw), cert, key
+}
+
 func TestConnectorCmd(t *testing.T) {
 	var actual connector.Options
 	patchRunConnector(t, func(ctx context.Context, options connector.Options) error {
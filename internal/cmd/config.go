@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -38,6 +39,9 @@ type ClientHostConfig struct {
 	// TrustedCertificate is the PEM encoded TLS certificate used by the server
 	// that was verified and trusted by the user as part of login.
 	TrustedCertificate string `json:"trusted-certificate"`
+	// PinnedCA is the PEM encoded CA certificate that must have signed the
+	// server certificate. When it is set the system certificate pool is not used.
+	PinnedCA string `json:"pinned-ca,omitempty"`
 }
 
 // checks if user is logged in to the given session (ClientHostConfig)
@@ -170,6 +174,32 @@ func saveHostConfig(hostConfig ClientHostConfig) error {
 	return nil
 }
 
+// setCurrentHost makes host the current host in config, and returns its
+// config. The session for host must be logged in. The other sessions are kept,
+// so that they may be used again without another login.
+func setCurrentHost(config *ClientConfig, host string) (*ClientHostConfig, error) {
+	var hostConfig *ClientHostConfig
+	for i := range config.Hosts {
+		if equalHosts(config.Hosts[i].Host, host) {
+			hostConfig = &config.Hosts[i]
+			break
+		}
+	}
+
+	switch {
+	case hostConfig == nil:
+		return nil, Error{Message: fmt.Sprintf("No session for %s; run 'infra login %s' to log in", host, host)}
+	case !hostConfig.isLoggedIn():
+		return nil, Error{Message: fmt.Sprintf("Not logged in to %s; run 'infra login %s' to log in", host, host)}
+	}
+
+	for i := range config.Hosts {
+		config.Hosts[i].Current = false
+	}
+	hostConfig.Current = true
+	return hostConfig, nil
+}
+
 func currentHostConfig() (*ClientHostConfig, error) {
 	cfg, err := readConfig()
 	if err != nil {
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/infrahq/infra/api"
 )
 
 // CLI Errors are user facing errors that are formatted.
@@ -34,3 +37,21 @@ func (e Error) Error() string {
 func (e Error) Unwrap() error {
 	return e.OriginalError
 }
+
+// accessKeyError returns a user facing error that explains why the access key
+// was rejected, when err is an api.Error for an expired access key. Otherwise
+// err is returned unchanged.
+func accessKeyError(err error) error {
+	var apiErr api.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	switch apiErr.ErrorCode {
+	case api.ErrorCodeAccessKeyExpired:
+		return Error{Message: "Your access key has reached its expiry; run 'infra login' to start a new session"}
+	case api.ErrorCodeAccessKeyDeadlineExceeded:
+		return Error{Message: "Your access key expired because it was not used within its extension deadline; run 'infra login' to start a new session"}
+	}
+	return err
+}
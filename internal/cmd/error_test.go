@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
 )
 
 func TestCLIError(t *testing.T) {
@@ -66,3 +68,21 @@ func TestCLIError(t *testing.T) {
 		assert.Assert(t, ok)
 	})
 }
+
+func TestAccessKeyError(t *testing.T) {
+	t.Run("expired", func(t *testing.T) {
+		err := accessKeyError(api.Error{Code: 401, ErrorCode: api.ErrorCodeAccessKeyExpired})
+		assert.Error(t, err, "Your access key has reached its expiry; run 'infra login' to start a new session")
+	})
+
+	t.Run("extension deadline exceeded", func(t *testing.T) {
+		err := accessKeyError(fmt.Errorf("get user: %w", api.Error{Code: 401, ErrorCode: api.ErrorCodeAccessKeyDeadlineExceeded}))
+		assert.Error(t, err, "Your access key expired because it was not used within its extension deadline; run 'infra login' to start a new session")
+	})
+
+	t.Run("other errors are unchanged", func(t *testing.T) {
+		orig := api.Error{Code: 401, ErrorCode: api.ErrorCodeUnauthorized, Message: "unauthorized"}
+		assert.DeepEqual(t, accessKeyError(orig), error(orig))
+		assert.NilError(t, accessKeyError(nil))
+	})
+}
@@ -58,10 +58,12 @@ func newGrantsListCmd(cli *CLI) *cobra.Command {
 				return err
 			}
 			listReq := api.ListGrantsRequest{
-				Privilege:     options.Role,
 				Resource:      options.Resource,
 				ShowInherited: options.Inherited,
 			}
+			if options.Role != "" {
+				listReq.Privilege = []string{options.Role}
+			}
 
 			if options.UserName != "" && options.GroupName != "" {
 				return Error{Message: "You cannot use both a --user and a --group at the same time"}
@@ -259,10 +261,12 @@ func removeGrant(cli *CLI, cmdOptions grantsCmdOptions) error {
 	}
 
 	listGrantsReq := api.ListGrantsRequest{
-		User:      user,
-		Group:     group,
-		Privilege: cmdOptions.Role,
-		Resource:  cmdOptions.Resource,
+		User:     user,
+		Group:    group,
+		Resource: cmdOptions.Resource,
+	}
+	if cmdOptions.Role != "" {
+		listGrantsReq.Privilege = []string{cmdOptions.Role}
 	}
 
 	logging.Debugf("call server: list grants %#v", listGrantsReq)
@@ -5,9 +5,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/goware/urlx"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
@@ -72,10 +74,59 @@ func updateKubeConfig(client *api.Client, id uid.ID) error {
 		return err
 	}
 
-	return writeKubeconfig(user, destinations, grants)
+	return writeKubeconfig(serverHost(client), user, destinations, grants)
 }
 
-func writeKubeconfig(user *api.User, destinations []api.Destination, grants []api.Grant) error {
+// kubeconfigServerExtension is the name of the kubeconfig extension used to
+// record which Infra server a context was created for.
+const kubeconfigServerExtension = "infrahq.com/server"
+
+// serverHost returns the host of the Infra server used by client, in the same
+// form as ClientHostConfig.Host.
+func serverHost(client *api.Client) string {
+	return strings.TrimPrefix(client.URL, "https://")
+}
+
+func setContextServerHost(kubeContext *clientcmdapi.Context, host string) {
+	if kubeContext.Extensions == nil {
+		kubeContext.Extensions = make(map[string]runtime.Object)
+	}
+	kubeContext.Extensions[kubeconfigServerExtension] = &runtime.Unknown{
+		Raw:         []byte(strconv.Quote(host)),
+		ContentType: runtime.ContentTypeJSON,
+	}
+}
+
+// contextServerHost returns the host of the Infra server which created the
+// context, or an empty string if the context was created before contexts were
+// tagged with the server.
+func contextServerHost(kubeContext *clientcmdapi.Context) string {
+	ext, ok := kubeContext.Extensions[kubeconfigServerExtension].(*runtime.Unknown)
+	if !ok {
+		return ""
+	}
+
+	host, err := strconv.Unquote(string(ext.Raw))
+	if err != nil {
+		logging.Debugf("invalid %s extension in kubeconfig: %s", kubeconfigServerExtension, ext.Raw)
+		return ""
+	}
+	return host
+}
+
+// isInfraContextForHost returns true if the context was created by Infra for
+// the server at host. Contexts that are not tagged with a server are considered
+// to belong to every server.
+func isInfraContextForHost(name string, kubeContext *clientcmdapi.Context, host string) bool {
+	if !strings.HasPrefix(name, "infra:") {
+		return false
+	}
+
+	contextHost := contextServerHost(kubeContext)
+	return contextHost == "" || contextHost == host
+}
+
+func writeKubeconfig(host string, user *api.User, destinations []api.Destination, grants []api.Grant) error {
 	defaultConfig := clientConfig()
 
 	kubeConfig, err := defaultConfig.RawConfig()
@@ -155,6 +206,7 @@ func writeKubeconfig(user *api.User, destinations []api.Destination, grants []ap
 			}
 		}
 
+		setContextServerHost(kubeContext, host)
 		kubeConfig.Contexts[context] = kubeContext
 
 		executable, err := os.Executable()
@@ -174,15 +226,10 @@ func writeKubeconfig(user *api.User, destinations []api.Destination, grants []ap
 		keep[context] = true
 	}
 
-	// cleanup others
-	for c := range kubeConfig.Contexts {
-		parts := strings.Split(c, ":")
-
-		if len(parts) < 1 {
-			continue
-		}
-
-		if parts[0] != "infra" {
+	// cleanup others from the same server, contexts from other servers are
+	// removed when logging out of that server
+	for c, kubeContext := range kubeConfig.Contexts {
+		if !isInfraContextForHost(c, kubeContext, host) {
 			continue
 		}
 
@@ -236,7 +283,9 @@ func safelyWriteConfigToFile(kubeConfig clientcmdapi.Config, fileToWrite string)
 	return nil
 }
 
-func clearKubeconfig() error {
+// clearKubeconfig removes the kubeconfig entries created by Infra for the
+// servers in hosts. Entries created for other servers are left in place.
+func clearKubeconfig(hosts ...string) error {
 	defaultConfig := clientConfig()
 
 	kubeConfig, err := defaultConfig.RawConfig()
@@ -244,24 +293,21 @@ func clearKubeconfig() error {
 		return err
 	}
 
-	for c := range kubeConfig.Contexts {
-		parts := strings.Split(c, ":")
+	for c, kubeContext := range kubeConfig.Contexts {
+		for _, host := range hosts {
+			if !isInfraContextForHost(c, kubeContext, host) {
+				continue
+			}
 
-		if len(parts) < 1 {
-			continue
-		}
+			delete(kubeConfig.Clusters, c)
+			delete(kubeConfig.Contexts, c)
+			delete(kubeConfig.AuthInfos, c)
 
-		if parts[0] != "infra" {
-			continue
+			if kubeConfig.CurrentContext == c {
+				kubeConfig.CurrentContext = ""
+			}
+			break
 		}
-
-		delete(kubeConfig.Clusters, c)
-		delete(kubeConfig.Contexts, c)
-		delete(kubeConfig.AuthInfos, c)
-	}
-
-	if strings.HasPrefix(kubeConfig.CurrentContext, "infra:") {
-		kubeConfig.CurrentContext = ""
 	}
 
 	kubeConfigFilename := defaultConfig.ConfigAccess().GetDefaultFilename()
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
@@ -38,7 +39,7 @@ func TestWriteKubeconfig(t *testing.T) {
 		},
 	}
 
-	err := writeKubeconfig(&user, destinations, grants)
+	err := writeKubeconfig("infra.example.com", &user, destinations, grants)
 	assert.NilError(t, err)
 
 	expected := clientcmdapi.Config{
@@ -52,6 +53,12 @@ func TestWriteKubeconfig(t *testing.T) {
 			"infra:cluster": {
 				AuthInfo: "user",
 				Cluster:  "infra:cluster",
+				Extensions: map[string]runtime.Object{
+					kubeconfigServerExtension: &runtime.Unknown{
+						Raw:         []byte(`"infra.example.com"`),
+						ContentType: runtime.ContentTypeJSON,
+					},
+				},
 			},
 		},
 		AuthInfos: map[string]*clientcmdapi.AuthInfo{
@@ -123,7 +130,7 @@ func TestWriteKubeconfig_UserNamespaceOverride(t *testing.T) {
 		},
 	}
 
-	err = writeKubeconfig(&user, destinations, grants)
+	err = writeKubeconfig("infra.example.com", &user, destinations, grants)
 	assert.NilError(t, err)
 
 	actual, err := clientConfig().RawConfig()
@@ -177,7 +184,7 @@ func TestWriteKubeconfig_UserNamespaceOverrideResetNamespacedContext(t *testing.
 		},
 	}
 
-	err = writeKubeconfig(&user, destinations, grants)
+	err = writeKubeconfig("infra.example.com", &user, destinations, grants)
 	assert.NilError(t, err)
 
 	actual, err := clientConfig().RawConfig()
@@ -185,6 +192,46 @@ func TestWriteKubeconfig_UserNamespaceOverrideResetNamespacedContext(t *testing.
 	assert.Equal(t, actual.Contexts["infra:cluster:default"].Namespace, "default")
 }
 
+func TestWriteKubeconfig_MultipleServers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("KUBECONFIG", filepath.Join(home, "kubeconfig"))
+
+	user := api.User{Name: "user"}
+	destinations := []api.Destination{
+		{
+			Name:       "prod",
+			Connection: api.DestinationConnection{URL: "prod.example.com", CA: destinationCA},
+		},
+		{
+			Name:       "staging",
+			Connection: api.DestinationConnection{URL: "staging.example.com", CA: destinationCA},
+		},
+	}
+
+	err := writeKubeconfig("one.example.com", &user, destinations, []api.Grant{{Resource: "prod"}})
+	assert.NilError(t, err)
+	err = writeKubeconfig("two.example.com", &user, destinations, []api.Grant{{Resource: "staging"}})
+	assert.NilError(t, err)
+
+	actual, err := clientConfig().RawConfig()
+	assert.NilError(t, err)
+	assert.Equal(t, contextServerHost(actual.Contexts["infra:prod"]), "one.example.com")
+	assert.Equal(t, contextServerHost(actual.Contexts["infra:staging"]), "two.example.com")
+
+	t.Run("removes contexts only from the same server", func(t *testing.T) {
+		err := writeKubeconfig("one.example.com", &user, destinations, nil)
+		assert.NilError(t, err)
+
+		actual, err := clientConfig().RawConfig()
+		assert.NilError(t, err)
+		_, ok := actual.Contexts["infra:prod"]
+		assert.Assert(t, !ok, "expected infra:prod to be removed")
+		assert.Equal(t, contextServerHost(actual.Contexts["infra:staging"]), "two.example.com")
+	})
+}
+
 func TestSafelyWriteConfigToFile(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
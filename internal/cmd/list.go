@@ -89,7 +89,7 @@ func list(cli *CLI) error {
 		cli.Output("You have not been granted access to any active destinations")
 	}
 
-	return writeKubeconfig(user, destinations, grants)
+	return writeKubeconfig(serverHost(client), user, destinations, grants)
 }
 
 func destinationForResourceExists(resource string, destinations []api.Destination) bool {
@@ -32,11 +32,14 @@ type loginCmdOptions struct {
 	Server             string
 	AccessKey          string
 	Provider           string
+	Email              string
 	SkipTLSVerify      bool
 	TrustedCertificate string
+	PinnedCA           string
 	TrustedFingerprint string
 	NonInteractive     bool
 	NoAgent            bool
+	Device             bool
 }
 
 type loginMethod int8
@@ -68,6 +71,12 @@ $ infra login --provider okta
 $ export INFRA_ACCESS_KEY=1M4CWy9wF5.fAKeKEy5sMLH9ZZzAur0ZIjy
 $ infra login
 
+# Login with the identity provider configured for the domain of an email address
+$ infra login --email user@example.com
+
+# Login with a code from an identity provider, without a browser
+$ infra login --provider okta --device
+
 # Login with pre-set provider and server
 $ export INFRA_SERVER=example.infrahq.com
 $ export INFRA_PROVIDER=google
@@ -89,10 +98,13 @@ $ infra login`,
 
 	cmd.Flags().StringVar(&options.AccessKey, "key", "", "Login with an access key")
 	cmd.Flags().StringVar(&options.Provider, "provider", "", "Login with an identity provider")
+	cmd.Flags().StringVar(&options.Email, "email", "", "Login with the identity provider configured for the domain of this email address")
 	cmd.Flags().BoolVar(&options.SkipTLSVerify, "skip-tls-verify", false, "Skip verifying server TLS certificates")
 	cmd.Flags().Var((*types.StringOrFile)(&options.TrustedCertificate), "tls-trusted-cert", "TLS certificate or CA used by the server")
+	cmd.Flags().Var((*types.StringOrFile)(&options.PinnedCA), "tls-pinned-ca", "Only trust server TLS certificates signed by this CA")
 	cmd.Flags().StringVar(&options.TrustedFingerprint, "tls-trusted-fingerprint", "", "SHA256 fingerprint of the server TLS certificate")
 	cmd.Flags().BoolVar(&options.NoAgent, "no-agent", false, "Skip starting the Infra agent in the background")
+	cmd.Flags().BoolVar(&options.Device, "device", false, "Login to an identity provider with a code, instead of a browser")
 	addNonInteractiveFlag(cmd.Flags(), &options.NonInteractive)
 	return cmd
 }
@@ -117,12 +129,27 @@ func login(cli *CLI, options loginCmdOptions) error {
 		}
 	}
 
-	if len(options.TrustedCertificate) == 0 {
-		// Attempt to find a previously trusted certificate
-		for _, hc := range config.Hosts {
-			if equalHosts(hc.Host, options.Server) {
-				options.TrustedCertificate = hc.TrustedCertificate
-			}
+	if options.SkipTLSVerify && options.PinnedCA != "" {
+		return Error{Message: "The --skip-tls-verify and --tls-pinned-ca flags can not be used together"}
+	}
+
+	// Attempt to find a previously trusted certificate or pinned CA
+	for _, hc := range config.Hosts {
+		if !equalHosts(hc.Host, options.Server) {
+			continue
+		}
+		if len(options.TrustedCertificate) == 0 {
+			options.TrustedCertificate = hc.TrustedCertificate
+		}
+		if options.PinnedCA == "" && !options.SkipTLSVerify {
+			options.PinnedCA = hc.PinnedCA
+		}
+	}
+
+	if options.PinnedCA != "" {
+		if ok := x509.NewCertPool().AppendCertsFromPEM([]byte(options.PinnedCA)); !ok {
+			return Error{Message: fmt.Sprintf("Failed to read the pinned CA certificate for server %s, "+
+				"it must be a PEM encoded certificate", options.Server)}
 		}
 	}
 
@@ -132,6 +159,7 @@ func login(cli *CLI, options loginCmdOptions) error {
 	}
 
 	loginReq := &api.LoginRequest{}
+	var devicePollInterval time.Duration
 
 	if options.AccessKey == "" {
 		options.AccessKey = os.Getenv("INFRA_ACCESS_KEY")
@@ -151,7 +179,11 @@ func login(cli *CLI, options loginCmdOptions) error {
 				return err
 			}
 		} else {
-			loginReq.OIDC, err = loginToProviderN(lc.APIClient, options.Provider)
+			provider, err := GetProviderByName(lc.APIClient, options.Provider)
+			if err != nil {
+				return err
+			}
+			devicePollInterval, err = loginReqForProvider(cli, lc.APIClient, loginReq, provider, options.Device)
 			if err != nil {
 				return err
 			}
@@ -160,7 +192,7 @@ func login(cli *CLI, options loginCmdOptions) error {
 		if options.NonInteractive {
 			return Error{Message: "Non-interactive login only supports access keys, set the INFRA_ACCESS_KEY environment variable and try again"}
 		}
-		loginMethod, provider, err := promptLoginOptions(cli, lc.APIClient)
+		loginMethod, provider, err := promptLoginOptions(cli, lc.APIClient, options.Email)
 		if err != nil {
 			return err
 		}
@@ -172,13 +204,13 @@ func login(cli *CLI, options loginCmdOptions) error {
 				return err
 			}
 		case oidcLogin:
-			loginReq.OIDC, err = loginToProvider(provider)
+			devicePollInterval, err = loginReqForProvider(cli, lc.APIClient, loginReq, provider, options.Device)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	return loginToInfra(cli, lc, loginReq, options.NoAgent)
+	return loginToInfra(cli, lc, loginReq, devicePollInterval, options.NoAgent)
 }
 
 func equalHosts(x, y string) bool {
@@ -191,8 +223,8 @@ func equalHosts(x, y string) bool {
 	return false
 }
 
-func loginToInfra(cli *CLI, lc loginClient, loginReq *api.LoginRequest, noAgent bool) error {
-	loginRes, err := lc.APIClient.Login(loginReq)
+func loginToInfra(cli *CLI, lc loginClient, loginReq *api.LoginRequest, devicePollInterval time.Duration, noAgent bool) error {
+	loginRes, err := sendLoginRequest(lc.APIClient, loginReq, devicePollInterval)
 	if err != nil {
 		logging.Debugf("login: %s", err)
 		if api.ErrorStatusCode(err) == http.StatusUnauthorized || api.ErrorStatusCode(err) == http.StatusNotFound {
@@ -201,7 +233,7 @@ func loginToInfra(cli *CLI, lc loginClient, loginReq *api.LoginRequest, noAgent
 				return &LoginError{Message: "your access key may be invalid"}
 			case loginReq.PasswordCredentials != nil:
 				return &LoginError{Message: "your username or password may be invalid"}
-			case loginReq.OIDC != nil:
+			case loginReq.OIDC != nil, loginReq.Device != nil:
 				return &LoginError{Message: "please contact an administrator and check identity provider configurations"}
 			}
 		}
@@ -275,9 +307,13 @@ func updateInfraConfig(lc loginClient, loginReq *api.LoginRequest, loginRes *api
 	if lc.TrustedCertificate != "" {
 		clientHostConfig.TrustedCertificate = lc.TrustedCertificate
 	}
+	clientHostConfig.PinnedCA = lc.PinnedCA
 
-	if loginReq.OIDC != nil {
+	switch {
+	case loginReq.OIDC != nil:
 		clientHostConfig.ProviderID = loginReq.OIDC.ProviderID
+	case loginReq.Device != nil:
+		clientHostConfig.ProviderID = loginReq.Device.ProviderID
 	}
 
 	u, err := urlx.Parse(lc.APIClient.URL)
@@ -328,13 +364,17 @@ func oidcflow(provider *api.Provider) (string, error) {
 	return code, nil
 }
 
-// Given the provider name, directs user to its OIDC login page, then saves the auth code (to later login to infra)
-func loginToProviderN(client *api.Client, providerName string) (*api.LoginRequestOIDC, error) {
-	provider, err := GetProviderByName(client, providerName)
-	if err != nil {
-		return nil, err
-	}
-	return loginToProvider(provider)
+// loginReqForProvider sets the credentials for login with an identity
+// provider on loginReq, using either the browser or a device code. When a
+// device code is used, pollInterval is the minimum time to wait between login
+// requests.
+func loginReqForProvider(cli *CLI, client *api.Client, loginReq *api.LoginRequest, provider *api.Provider, device bool) (pollInterval time.Duration, err error) {
+	if device {
+		loginReq.Device, pollInterval, err = loginToProviderWithDevice(cli, client, provider)
+		return pollInterval, err
+	}
+	loginReq.OIDC, err = loginToProvider(provider)
+	return 0, err
 }
 
 // Given the provider, directs user to its OIDC login page, then saves the auth code (to later login to infra)
@@ -353,24 +393,109 @@ func loginToProvider(provider *api.Provider) (*api.LoginRequestOIDC, error) {
 	}, nil
 }
 
+// loginToProviderWithDevice starts the device authorization grant with the
+// provider, and shows the user the code to enter on another device. The user
+// does not need a browser on this machine to login.
+func loginToProviderWithDevice(cli *CLI, client *api.Client, provider *api.Provider) (*api.LoginRequestDevice, time.Duration, error) {
+	fmt.Fprintf(cli.Stderr, "  Logging in with %s...\n", termenv.String(provider.Name).Bold().String())
+
+	logging.Debugf("call server: start device authorization for provider %s", provider.ID)
+	auth, err := client.StartDeviceAuthorization(provider.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fmt.Fprintf(cli.Stderr, "  To login, visit %s and enter the code %s\n",
+		auth.VerificationURI, termenv.String(auth.UserCode).Bold().String())
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(cli.Stderr, "  Or visit %s\n", auth.VerificationURIComplete)
+	}
+	fmt.Fprintf(cli.Stderr, "  Waiting for login to complete...\n")
+
+	return &api.LoginRequestDevice{
+		ProviderID: provider.ID,
+		DeviceCode: auth.DeviceCode,
+	}, time.Duration(auth.Interval) * time.Second, nil
+}
+
+// defaultDevicePollInterval is the time to wait between device login requests
+// when the server did not respond with an interval, from RFC 8628.
+const defaultDevicePollInterval = 5 * time.Second
+
+// deviceSlowDownIncrement is added to the interval between device login
+// requests every time the server responds with slow_down, from RFC 8628.
+const deviceSlowDownIncrement = 5 * time.Second
+
+// waitForDeviceLogin waits before a device login request is sent again. Tests
+// replace it to avoid waiting.
+var waitForDeviceLogin = time.Sleep
+
+// sendLoginRequest sends loginReq to the server. A device login is sent again,
+// every pollInterval, until the user authorizes the device, or the device code
+// expires. The interval is increased when the server responds with slow_down.
+// When the server rate limits the device login, the next request is sent after
+// the time in the Retry-After header of the response.
+func sendLoginRequest(client *api.Client, loginReq *api.LoginRequest, pollInterval time.Duration) (*api.LoginResponse, error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultDevicePollInterval
+	}
+	for {
+		loginRes, err := client.Login(loginReq)
+		if loginReq.Device == nil {
+			return loginRes, err
+		}
+		wait := pollInterval
+		var apiError api.Error
+		switch {
+		case isAPIErrorCode(err, api.ErrorCodeAuthorizationPending):
+			logging.Debugf("login: device authorization is pending")
+		case isAPIErrorCode(err, api.ErrorCodeSlowDown):
+			pollInterval += deviceSlowDownIncrement
+			wait = pollInterval
+			logging.Debugf("login: device authorization is pending, poll interval increased to %v", pollInterval)
+		case errors.As(err, &apiError) && apiError.Code == http.StatusTooManyRequests:
+			if apiError.RetryAfter > 0 {
+				wait = apiError.RetryAfter
+			}
+			logging.Debugf("login: device login was rate limited, retry after %v", wait)
+		default:
+			return loginRes, err
+		}
+		waitForDeviceLogin(wait)
+	}
+}
+
+func isAPIErrorCode(err error, code string) bool {
+	var apiError api.Error
+	return errors.As(err, &apiError) && apiError.ErrorCode == code
+}
+
 type loginClient struct {
 	APIClient *api.Client
 	// TrustedCertificate is a PEM encoded certificate that has been trusted by
 	// the user for TLS communication with the server.
 	TrustedCertificate string
+	// PinnedCA is a PEM encoded CA certificate that must have signed the
+	// server certificate.
+	PinnedCA string
 }
 
 // Only used when logging in or switching to a new session, since user has no credentials. Otherwise, use defaultAPIClient().
 func newLoginClient(cli *CLI, options loginCmdOptions) (loginClient, error) {
 	cfg := &ClientHostConfig{
+		Host:               options.Server,
 		TrustedCertificate: options.TrustedCertificate,
 		SkipTLSVerify:      options.SkipTLSVerify,
+		PinnedCA:           options.PinnedCA,
 	}
 	c := loginClient{
 		APIClient:          apiClient(options.Server, "", httpTransportForHostConfig(cfg)),
 		TrustedCertificate: options.TrustedCertificate,
+		PinnedCA:           options.PinnedCA,
 	}
-	if options.SkipTLSVerify {
+	// A pinned CA is never replaced by a certificate the user accepts from a
+	// prompt, so a server that is not signed by the CA fails to connect.
+	if options.SkipTLSVerify || options.PinnedCA != "" {
 		return c, nil
 	}
 
@@ -527,7 +652,10 @@ func listProviders(client *api.Client) ([]api.Provider, error) {
 	return providers.Items, nil
 }
 
-func promptLoginOptions(cli *CLI, client *api.Client) (loginMethod loginMethod, provider *api.Provider, err error) {
+// promptLoginOptions selects the login method. When email is set, or the user
+// enters an email when prompted, the provider configured for the domain of the
+// email is used. Otherwise the user selects the login method from a list.
+func promptLoginOptions(cli *CLI, client *api.Client, email string) (loginMethod loginMethod, provider *api.Provider, err error) {
 	providers, err := listProviders(client)
 	if err != nil {
 		return 0, nil, err
@@ -537,6 +665,21 @@ func promptLoginOptions(cli *CLI, client *api.Client) (loginMethod loginMethod,
 		return localLogin, nil, nil
 	}
 
+	if email == "" && providersHaveDomains(providers) {
+		prompt := &survey.Input{Message: "Email (leave empty to select a login method):"}
+		if err := survey.AskOne(prompt, &email, cli.surveyIO); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if email != "" {
+		provider, err := inferProvider(providers, email)
+		if err == nil {
+			return oidcLogin, provider, nil
+		}
+		fmt.Fprintf(cli.Stderr, "  %v, select a login method\n", err)
+	}
+
 	var options []string
 	for _, p := range providers {
 		options = append(options, fmt.Sprintf("%s (%s)", p.Name, p.URL))
@@ -560,6 +703,48 @@ func promptLoginOptions(cli *CLI, client *api.Client) (loginMethod loginMethod,
 	return oidcLogin, &providers[i], nil
 }
 
+func providersHaveDomains(providers []api.Provider) bool {
+	for _, p := range providers {
+		if len(p.Domains) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// inferProvider returns the provider configured for the domain of email. It
+// returns an error when no provider, or more than one provider, is configured
+// for the domain.
+func inferProvider(providers []api.Provider, email string) (*api.Provider, error) {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok || domain == "" {
+		return nil, fmt.Errorf("%q is not an email address", email)
+	}
+
+	var matches []int
+	for i, p := range providers {
+		for _, d := range p.Domains {
+			if strings.EqualFold(d, domain) {
+				matches = append(matches, i)
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no identity provider is configured for %s", domain)
+	case 1:
+		return &providers[matches[0]], nil
+	default:
+		names := make([]string, 0, len(matches))
+		for _, i := range matches {
+			names = append(names, providers[i].Name)
+		}
+		return nil, fmt.Errorf("more than one identity provider is configured for %s (%s)", domain, strings.Join(names, ", "))
+	}
+}
+
 func promptVerifyTLSCert(cli *CLI, cert *x509.Certificate) error {
 	formatTime := func(t time.Time) string {
 		return fmt.Sprintf("%v (%v)", format.HumanTime(t, "none"), t.Format(time.RFC1123))
@@ -691,6 +876,9 @@ func authURLForProvider(provider api.Provider, state string) (string, error) {
 
 	authURL := provider.AuthURL
 	scopes := provider.Scopes
+	if len(provider.RequestedScopes) > 0 {
+		scopes = provider.RequestedScopes
+	}
 
 	if authURL == "" {
 		// this is an old server that doesn't populate the auth URL
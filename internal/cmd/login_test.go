@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -224,6 +227,18 @@ func setupServerOptions(t *testing.T, opts *server.Options) {
 	opts.DBConnectionString = pgDriver.DSN
 }
 
+func TestLoginCmd_InvalidPinnedCA(t *testing.T) {
+	setupEnv(t)
+
+	err := Run(context.Background(), "login", "127.0.0.1:1", "--tls-pinned-ca", "not a certificate")
+	assert.ErrorContains(t, err, "Failed to read the pinned CA certificate for server 127.0.0.1:1")
+
+	// Check we haven't persisted the invalid CA
+	cfg, err := readConfig()
+	assert.NilError(t, err)
+	assert.Equal(t, len(cfg.Hosts), 0)
+}
+
 func TestLoginCmd_TLSVerify(t *testing.T) {
 	dir := t.TempDir()
 	t.Setenv("HOME", dir)
@@ -489,3 +504,101 @@ func TestAuthURLForProvider(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Equal(t, url, expectedResolvedAuthURL)
 }
+
+func TestSendLoginRequest_Device(t *testing.T) {
+	var waits []time.Duration
+	waitForDeviceLogin = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { waitForDeviceLogin = time.Sleep })
+
+	pending := []string{api.ErrorCodeAuthorizationPending, api.ErrorCodeRateLimited, api.ErrorCodeSlowDown}
+	var requests []api.LoginRequest
+	handler := func(resp http.ResponseWriter, req *http.Request) {
+		var loginReq api.LoginRequest
+		assert.Check(t, json.NewDecoder(req.Body).Decode(&loginReq))
+		requests = append(requests, loginReq)
+
+		if len(pending) > 0 {
+			code := pending[0]
+			pending = pending[1:]
+			if code == api.ErrorCodeRateLimited {
+				resp.Header().Set("Retry-After", "3")
+				resp.WriteHeader(http.StatusTooManyRequests)
+				assert.Check(t, json.NewEncoder(resp).Encode(api.Error{
+					Code:      http.StatusTooManyRequests,
+					ErrorCode: code,
+					Message:   "too many requests",
+				}))
+				return
+			}
+			resp.WriteHeader(http.StatusBadRequest)
+			assert.Check(t, json.NewEncoder(resp).Encode(api.Error{
+				Code:      http.StatusBadRequest,
+				ErrorCode: code,
+				Message:   "the device has not been authorized yet",
+			}))
+			return
+		}
+		resp.WriteHeader(http.StatusCreated)
+		assert.Check(t, json.NewEncoder(resp).Encode(api.LoginResponse{Name: "device@example.com"}))
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(handler))
+	t.Cleanup(srv.Close)
+
+	client := &api.Client{URL: srv.URL, HTTP: *srv.Client()}
+	device := &api.LoginRequestDevice{ProviderID: uid.ID(1234), DeviceCode: "the-device-code"}
+
+	loginRes, err := sendLoginRequest(client, &api.LoginRequest{Device: device}, 2*time.Second)
+	assert.NilError(t, err)
+	assert.Equal(t, loginRes.Name, "device@example.com")
+
+	assert.Equal(t, len(requests), 4)
+	for _, req := range requests {
+		assert.DeepEqual(t, req.Device, device)
+	}
+	// a rate limited request waits for Retry-After, and slow_down increases the interval
+	assert.DeepEqual(t, waits, []time.Duration{2 * time.Second, 3 * time.Second, 7 * time.Second})
+
+	t.Run("only device logins are retried", func(t *testing.T) {
+		requests = nil
+		pending = []string{api.ErrorCodeAuthorizationPending}
+		_, err := sendLoginRequest(client, &api.LoginRequest{AccessKey: "aaaaaaaaaa.bbbbbbbbbbbbbbbbbbbbbbbb"}, 0)
+		assert.ErrorContains(t, err, "not been authorized yet")
+		assert.Equal(t, len(requests), 1)
+	})
+}
+
+func TestInferProvider(t *testing.T) {
+	providers := []api.Provider{
+		{Name: "okta", Domains: []string{"example.com", "example.org"}},
+		{Name: "google", Domains: []string{"example.net"}},
+		{Name: "azure", Domains: []string{"example.net"}},
+		{Name: "other"},
+	}
+
+	t.Run("mapped domain", func(t *testing.T) {
+		provider, err := inferProvider(providers, "user@example.org")
+		assert.NilError(t, err)
+		assert.Equal(t, provider.Name, "okta")
+	})
+
+	t.Run("mapped domain ignores case", func(t *testing.T) {
+		provider, err := inferProvider(providers, "User@Example.COM")
+		assert.NilError(t, err)
+		assert.Equal(t, provider.Name, "okta")
+	})
+
+	t.Run("unmapped domain", func(t *testing.T) {
+		_, err := inferProvider(providers, "user@unknown.com")
+		assert.Error(t, err, "no identity provider is configured for unknown.com")
+	})
+
+	t.Run("ambiguous domain", func(t *testing.T) {
+		_, err := inferProvider(providers, "user@example.net")
+		assert.Error(t, err, "more than one identity provider is configured for example.net (google, azure)")
+	})
+
+	t.Run("not an email", func(t *testing.T) {
+		_, err := inferProvider(providers, "example.com")
+		assert.Error(t, err, `"example.com" is not an email address`)
+	})
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -13,19 +13,23 @@ import (
 )
 
 type logoutCmdOptions struct {
-	clear  bool
-	server string
-	all    bool
+	clear     bool
+	server    string
+	all       bool
+	localOnly bool
 }
 
-func newLogoutCmd(_ *CLI) *cobra.Command {
+func newLogoutCmd(cli *CLI) *cobra.Command {
 	var options logoutCmdOptions
 
 	cmd := &cobra.Command{
 		Use:   "logout [SERVER]",
 		Short: "Log out of Infra",
 		Long: `Log out of Infra
-Note: [SERVER] and [--all] cannot be both specified. Choose either one or all servers.`,
+Note: [SERVER] and [--all] cannot be both specified. Choose either one or all servers.
+
+The session is revoked on the server before it is removed locally. If the server
+can not be reached the local session is kept, use --local-only to remove it anyway.`,
 		Example: `# Log out of current server
 $ infra logout
 		
@@ -42,7 +46,10 @@ $ infra logout --clear
 $ infra logout infraexampleserver.com --clear 
 		
 # Logout and clear list of all servers 
-$ infra logout --all --clear`,
+$ infra logout --all --clear
+
+# Remove the local session without revoking it on the server
+$ infra logout --local-only`,
 		Args:  MaxArgs(1),
 		Group: "Core commands:",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -52,60 +59,65 @@ $ infra logout --all --clear`,
 				}
 				options.server = args[0]
 			}
-			return logout(options.clear, options.server, options.all)
+			return logout(cli, options)
 		},
 	}
 
 	cmd.Flags().BoolVar(&options.clear, "clear", false, "clear from list of servers")
 	cmd.Flags().BoolVar(&options.all, "all", false, "logout of all servers")
+	cmd.Flags().BoolVar(&options.localOnly, "local-only", false, "remove the local session without revoking it on the server")
 
 	return cmd
 }
 
-func logoutOfServer(hostConfig *ClientHostConfig) (success bool) {
-	client := apiClient(hostConfig.Host, hostConfig.AccessKey, httpTransportForHostConfig(hostConfig))
-
-	defer func() {
-		hostConfig.AccessKey = ""
-		hostConfig.UserID = 0
-		hostConfig.Name = ""
-		hostConfig.Expires = api.Time{}
-	}()
+// logoutOfServer revokes the session on the server, and then removes it from
+// hostConfig. When the session could not be revoked an error is returned and
+// hostConfig is not changed, unless localOnly is true. When localOnly is true
+// the server is not called.
+func logoutOfServer(hostConfig *ClientHostConfig, localOnly bool) error {
+	if hostConfig.isLoggedIn() && !localOnly {
+		client := apiClient(hostConfig.Host, hostConfig.AccessKey, httpTransportForHostConfig(hostConfig))
 
-	if hostConfig.isLoggedIn() {
 		err := client.Logout()
 		switch {
 		case api.ErrorStatusCode(err) == http.StatusUnauthorized:
+			// the session is already expired or revoked on the server
 			logging.Debugf("err: %s", err)
-			return false
 		case err != nil:
-			logging.Debugf("err: %s", err)
-			return false
+			return err
 		}
 	}
 
+	hostConfig.AccessKey = ""
+	hostConfig.UserID = 0
+	hostConfig.Name = ""
+	hostConfig.Expires = api.Time{}
+
 	logging.Debugf("logged out of server [%s]", hostConfig.Host)
-	return true
+	return nil
 }
 
-func logout(clear bool, server string, all bool) error {
+func logout(cli *CLI, options logoutCmdOptions) error {
 	switch {
-	case all:
+	case options.all:
 		logging.Debugf("logging out of all servers\n")
-	case server == "":
+	case options.server == "":
 		logging.Debugf("logging out of current server\n")
 	default:
-		logging.Debugf("logging out of server [%s]\n", server)
+		logging.Debugf("logging out of server [%s]\n", options.server)
 	}
 
-	if all {
-		return logoutAll(clear)
+	if options.all {
+		return logoutAll(cli, options)
 	}
 
-	return logoutOne(clear, server)
+	return logoutOne(cli, options)
 }
 
-func logoutAll(clear bool) error {
+// logoutAll logs out of every server in the config. A failure to logout of one
+// server does not stop the others, the failures are reported at the end. The
+// servers which failed keep their local session, so that logout can be retried.
+func logoutAll(cli *CLI, options logoutCmdOptions) error {
 	config, err := readConfig()
 	if err != nil {
 		if errors.Is(err, ErrConfigNotFound) {
@@ -115,17 +127,32 @@ func logoutAll(clear bool) error {
 		return err
 	}
 
+	var failed, loggedOut []string
+	hosts := config.Hosts[:0]
 	for i := range config.Hosts {
-		logoutOfServer(&config.Hosts[i])
+		host := config.Hosts[i]
+		if err := logoutOfServer(&host, options.localOnly); err != nil {
+			logging.Debugf("err: %s", err)
+			failed = append(failed, host.Host)
+			hosts = append(hosts, host)
+			continue
+		}
+		loggedOut = append(loggedOut, host.Host)
+		if !options.clear {
+			hosts = append(hosts, host)
+		}
 	}
+	total := len(config.Hosts)
 
-	fmt.Fprintf(os.Stderr, "Logged out of all servers.\n")
-	if clear {
+	config.Hosts = hosts
+	if len(config.Hosts) == 0 {
 		config.Hosts = nil
-		logging.Debugf("cleared all servers from login list\n")
+	}
+	if options.clear {
+		logging.Debugf("cleared servers from login list\n")
 	}
 
-	if err := clearKubeconfig(); err != nil {
+	if err := clearKubeconfig(loggedOut...); err != nil {
 		return err
 	}
 
@@ -133,10 +160,17 @@ func logoutAll(clear bool) error {
 		return err
 	}
 
+	if len(failed) > 0 {
+		fmt.Fprintf(cli.Stderr, "Logged out of %d of %d servers. Failed to end the session on: %s\n",
+			total-len(failed), total, strings.Join(failed, ", "))
+		return Error{Message: "Some sessions could not be revoked; run 'infra logout --all' again, or use --local-only to remove them without revoking them"}
+	}
+
+	fmt.Fprintf(cli.Stderr, "Logged out of all servers.\n")
 	return nil
 }
 
-func logoutOne(clear bool, server string) error {
+func logoutOne(cli *CLI, options logoutCmdOptions) error {
 	config, err := readConfig()
 	if err != nil {
 		if errors.Is(err, ErrConfigNotFound) {
@@ -146,28 +180,31 @@ func logoutOne(clear bool, server string) error {
 		return err
 	}
 
-	host, idx := findClientConfigHost(config, server)
+	host, idx := findClientConfigHost(config, options.server)
 
 	if host == nil {
 		return nil
 	}
 
-	success := logoutOfServer(host)
-	if success {
-		fmt.Fprintf(os.Stderr, "Logged out of server %s\n", host.Host)
+	if err := logoutOfServer(host, options.localOnly); err != nil {
+		return Error{
+			Message:       fmt.Sprintf("Failed to revoke the session on %s; use --local-only to remove it without revoking it", host.Host),
+			OriginalError: err,
+		}
 	}
+	fmt.Fprintf(cli.Stderr, "Logged out of server %s\n", host.Host)
 
-	if clear {
+	if err := clearKubeconfig(host.Host); err != nil {
+		return err
+	}
+
+	if options.clear {
 		serverURL := host.Host
 		config.Hosts[idx] = config.Hosts[len(config.Hosts)-1]
 		config.Hosts = config.Hosts[:len(config.Hosts)-1]
 		logging.Debugf("cleared server [%s]", serverURL)
 	}
 
-	if err := clearKubeconfig(); err != nil {
-		return err
-	}
-
 	if err := writeConfig(config); err != nil {
 		return err
 	}
@@ -320,15 +320,124 @@ func TestLogout(t *testing.T) {
 	t.Run("error", func(t *testing.T) {
 		testFields := setupError(t, "infra:prod")
 		err := Run(context.Background(), "logout", testFields.serverURLs[0])
-		assert.NilError(t, err)
+		assert.ErrorContains(t, err, "Failed to revoke the session on "+testFields.serverURLs[0])
 
 		assert.Equal(t, int32(1), atomic.LoadInt32(testFields.count), "calls to API")
 
+		// the local session is kept
+		updatedCfg, err := readConfig()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, &testFields.config, updatedCfg)
+	})
+
+	t.Run("server unreachable", func(t *testing.T) {
+		testFields := setup(t, "infra:prod")
+		cfg := testFields.config
+		cfg.Hosts[0].Host = "127.0.0.1:1"
+		err := writeConfig(&cfg)
+		assert.NilError(t, err)
+
+		err = Run(context.Background(), "logout")
+		assert.ErrorContains(t, err, "use --local-only to remove it without revoking it")
+
+		updatedCfg, err := readConfig()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, &cfg, updatedCfg)
+	})
+
+	t.Run("server unreachable with local only", func(t *testing.T) {
+		testFields := setup(t, "infra:prod")
+		cfg := testFields.config
+		cfg.Hosts[0].Host = "127.0.0.1:1"
+		err := writeConfig(&cfg)
+		assert.NilError(t, err)
+
+		err = Run(context.Background(), "logout", "--local-only")
+		assert.NilError(t, err)
+
+		updatedCfg, err := readConfig()
+		assert.NilError(t, err)
+
+		expected := cfg
+		expected.Hosts[0].AccessKey = ""
+		expected.Hosts[0].Name = ""
+		expected.Hosts[0].UserID = 0
+		expected.Hosts[0].Expires = api.Time{}
+		assert.DeepEqual(t, &expected, updatedCfg)
+
+		updatedKubeCfg, err := clientConfig().RawConfig()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedKubeCfg, updatedKubeCfg, cmpopts.EquateEmpty())
+	})
+
+	t.Run("with all continues past failures", func(t *testing.T) {
+		testFields := setup(t, "infra:prod")
+		errFields := setupError(t, "infra:prod")
+
+		cfg := testFields.config
+		cfg.Hosts = append(cfg.Hosts, errFields.config.Hosts[0])
+		cfg.Hosts[2].Current = false
+		err := writeConfig(&cfg)
+		assert.NilError(t, err)
+
+		ctx, bufs := PatchCLI(context.Background())
+		err = Run(ctx, "logout", "--all", "--clear")
+		assert.ErrorContains(t, err, "Some sessions could not be revoked")
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(testFields.count), "calls to API")
+		assert.Equal(t, int32(1), atomic.LoadInt32(errFields.count), "calls to API")
+
+		expectedErr := "Logged out of 2 of 3 servers. Failed to end the session on: " + errFields.serverURLs[0] + "\n"
+		assert.Equal(t, bufs.Stderr.String(), expectedErr)
+
+		// the server which failed keeps its session so that logout can be retried
 		updatedCfg, err := readConfig()
 		assert.NilError(t, err)
+		expected := &ClientConfig{
+			ClientConfigVersion: clientConfigVersion,
+			Hosts:               []ClientHostConfig{cfg.Hosts[2]},
+		}
+		assert.DeepEqual(t, expected, updatedCfg)
 
-		assert.Assert(t, updatedCfg.Hosts[0].Name == "")
-		assert.Assert(t, updatedCfg.Hosts[0].AccessKey == "")
+		updatedKubeCfg, err := clientConfig().RawConfig()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedKubeCfg, updatedKubeCfg, cmpopts.EquateEmpty())
+	})
+
+	t.Run("keeps contexts from other servers", func(t *testing.T) {
+		testFields := setup(t, "infra:staging")
+
+		kubeCfg, err := clientConfig().RawConfig()
+		assert.NilError(t, err)
+		setContextServerHost(kubeCfg.Contexts["infra:prod"], testFields.serverURLs[0])
+		kubeCfg.Clusters["infra:staging"] = &clientcmdapi.Cluster{Server: "https://infrastaging:8080"}
+		kubeCfg.Contexts["infra:staging"] = &clientcmdapi.Context{Cluster: "infra:staging"}
+		setContextServerHost(kubeCfg.Contexts["infra:staging"], testFields.serverURLs[1])
+		err = clientcmd.WriteToFile(kubeCfg, kubeConfigPath)
+		assert.NilError(t, err)
+
+		err = Run(context.Background(), "logout")
+		assert.NilError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(testFields.count), "calls to API")
+
+		updatedKubeCfg, err := clientConfig().RawConfig()
+		assert.NilError(t, err)
+		assert.Equal(t, updatedKubeCfg.CurrentContext, "infra:staging")
+		_, ok := updatedKubeCfg.Contexts["infra:prod"]
+		assert.Assert(t, !ok, "expected infra:prod to be removed")
+		_, ok = updatedKubeCfg.Clusters["infra:prod"]
+		assert.Assert(t, !ok, "expected infra:prod to be removed")
+		assert.Equal(t, contextServerHost(updatedKubeCfg.Contexts["infra:staging"]), testFields.serverURLs[1])
+		assert.Equal(t, updatedKubeCfg.Clusters["infra:staging"].Server, "https://infrastaging:8080")
+
+		t.Run("logout of the other server", func(t *testing.T) {
+			err = Run(context.Background(), "logout", testFields.serverURLs[1])
+			assert.NilError(t, err)
+
+			updatedKubeCfg, err := clientConfig().RawConfig()
+			assert.NilError(t, err)
+			assert.DeepEqual(t, expectedKubeCfg, updatedKubeCfg, cmpopts.EquateEmpty())
+		})
 	})
 
 	t.Run("with too many arguments", func(t *testing.T) {
@@ -341,7 +450,7 @@ func TestLogout(t *testing.T) {
 		testFields := setupError(t, "keep:non-infra")
 		err := Run(context.Background(), "logout", "--all")
 
-		assert.NilError(t, err)
+		assert.ErrorContains(t, err, "Some sessions could not be revoked")
 		assert.Equal(t, int32(1), atomic.LoadInt32(testFields.count), "calls to API")
 
 		updatedKubeCfg, err := clientConfig().RawConfig()
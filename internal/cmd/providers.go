@@ -65,6 +65,7 @@ func (o providerAPIOptions) Validate(providerKind string) error {
 
 type providerEditOptions struct {
 	ClientSecret       string
+	SkipValidation     bool
 	ProviderAPIOptions providerAPIOptions
 }
 
@@ -102,6 +103,7 @@ $ infra providers edit google --client-secret VT_oXtkEDaT7UFY-C3DSRWYb00qyKZ1K1V
 	cmd.Flags().Var((*types.StringOrFile)(&opts.ProviderAPIOptions.PrivateKey), "service-account-key", "The private key used to make authenticated requests to Google's API")
 	cmd.Flags().StringVar(&opts.ProviderAPIOptions.ClientEmail, "service-account-email", "", "The email assigned to the Infra service client in Google")
 	cmd.Flags().StringVar(&opts.ProviderAPIOptions.WorkspaceDomainAdminEmail, "workspace-domain-admin", "", "The email of your Google workspace domain admin")
+	cmd.Flags().BoolVar(&opts.SkipValidation, "skip-validation", false, "Do not check that the provider can be reached from the server")
 	return cmd
 }
 
@@ -168,6 +170,8 @@ type providerAddOptions struct {
 	ClientID           string
 	ClientSecret       string
 	Kind               string
+	Domains            []string
+	SkipValidation     bool
 	ProviderAPIOptions providerAPIOptions
 }
 
@@ -199,6 +203,9 @@ PROVIDER is a short unique name of the identity provider being added (eg. okta)`
 		Example: `# Connect Okta to Infra
 $ infra providers add okta --url example.okta.com --client-id 0oa3sz06o6do0muoW5d7 --client-secret VT_oXtkEDaT7UFY-C3DSRWYb00qyKZ1K1VCq7YzN --kind okta
 
+# Connect Okta to Infra, and use it to login users with an example.com email
+$ infra providers add okta --url example.okta.com --client-id 0oa3sz06o6do0muoW5d7 --client-secret VT_oXtkEDaT7UFY-C3DSRWYb00qyKZ1K1VCq7YzN --kind okta --domain example.com
+
 # Connect Google to Infra with group sync
 $ infra providers add google --url accounts.google.com --client-id 0oa3sz06o6do0muoW5d7 --client-secret VT_oXtkEDaT7UFY-C3DSRWYb00qyKZ1K1VCq7YzN --service-account-key ~/client-123.json --workspace-domain-admin admin@example.com --kind google`,
 		Args: ExactArgs(1),
@@ -223,11 +230,13 @@ $ infra providers add google --url accounts.google.com --client-id 0oa3sz06o6do0
 
 			logging.Debugf("call server: create provider named %q", args[0])
 			_, err = client.CreateProvider(&api.CreateProviderRequest{
-				Name:         args[0],
-				URL:          opts.URL,
-				ClientID:     opts.ClientID,
-				ClientSecret: opts.ClientSecret,
-				Kind:         opts.Kind,
+				Name:           args[0],
+				URL:            opts.URL,
+				ClientID:       opts.ClientID,
+				ClientSecret:   opts.ClientSecret,
+				Kind:           opts.Kind,
+				Domains:        opts.Domains,
+				SkipValidation: opts.SkipValidation,
 				API: &api.ProviderAPICredentials{
 					PrivateKey:       api.PEM(opts.ProviderAPIOptions.PrivateKey),
 					ClientEmail:      opts.ProviderAPIOptions.ClientEmail,
@@ -253,9 +262,11 @@ $ infra providers add google --url accounts.google.com --client-id 0oa3sz06o6do0
 	cmd.Flags().StringVar(&opts.ClientID, "client-id", "", "OIDC client ID")
 	cmd.Flags().StringVar(&opts.ClientSecret, "client-secret", "", "OIDC client secret")
 	cmd.Flags().StringVar(&opts.Kind, "kind", "oidc", "The identity provider kind. One of 'oidc, okta, azure, or google'")
+	cmd.Flags().StringSliceVar(&opts.Domains, "domain", nil, "Email domain of the users who login with this provider, can be repeated")
 	cmd.Flags().Var((*types.StringOrFile)(&opts.ProviderAPIOptions.PrivateKey), "service-account-key", "The private key used to make authenticated requests to Google's API, can be a file or the key string directly")
 	cmd.Flags().StringVar(&opts.ProviderAPIOptions.ClientEmail, "service-account-email", "", "The email assigned to the Infra service client in Google") // this is only needed with the private key is not a file
 	cmd.Flags().StringVar(&opts.ProviderAPIOptions.WorkspaceDomainAdminEmail, "workspace-domain-admin", "", "The email of your Google Workspace domain admin")
+	cmd.Flags().BoolVar(&opts.SkipValidation, "skip-validation", false, "Do not check that the provider can be reached from the server")
 	return cmd
 }
 
@@ -288,12 +299,14 @@ func updateProvider(cli *CLI, name string, opts providerEditOptions) error {
 
 	logging.Debugf("call server: update provider named %q", name)
 	_, err = client.UpdateProvider(api.UpdateProviderRequest{
-		ID:           provider.ID,
-		Name:         name,
-		URL:          provider.URL,
-		ClientID:     provider.ClientID,
-		ClientSecret: opts.ClientSecret,
-		Kind:         provider.Kind,
+		ID:             provider.ID,
+		Name:           name,
+		URL:            provider.URL,
+		ClientID:       provider.ClientID,
+		ClientSecret:   opts.ClientSecret,
+		Kind:           provider.Kind,
+		Domains:        provider.Domains,
+		SkipValidation: opts.SkipValidation,
 		API: &api.ProviderAPICredentials{
 			PrivateKey:       api.PEM(opts.ProviderAPIOptions.PrivateKey),
 			ClientEmail:      opts.ProviderAPIOptions.ClientEmail,
@@ -89,6 +89,33 @@ func TestProvidersAddCmd(t *testing.T) {
 		assert.DeepEqual(t, createProviderRequest, expected)
 	})
 
+	t.Run("okta provider with domains", func(t *testing.T) {
+		ch := setup(t)
+
+		err := Run(context.Background(),
+			"providers", "add", "okta",
+			"--url", "https://okta.com/path",
+			"--client-id", "okta-client-id",
+			"--client-secret", "okta-client-secret",
+			"--domain", "example.com",
+			"--domain", "example.org",
+		)
+		assert.NilError(t, err)
+
+		createProviderRequest := <-ch
+
+		expected := api.CreateProviderRequest{
+			Name:         "okta",
+			URL:          "https://okta.com/path",
+			ClientID:     "okta-client-id",
+			ClientSecret: "okta-client-secret",
+			Kind:         "oidc",
+			Domains:      []string{"example.com", "example.org"},
+			API:          &api.ProviderAPICredentials{},
+		}
+		assert.DeepEqual(t, createProviderRequest, expected)
+	})
+
 	t.Run("okta provider with env vars", func(t *testing.T) {
 		ch := setup(t)
 
@@ -39,6 +39,16 @@ func newServerCmd() *cobra.Command {
 				return err
 			}
 
+			if options.LogFile.Filename != "" {
+				if err := logging.UseFileLogger(options.LogFile); err != nil {
+					return err
+				}
+			}
+			logging.UseSampling(options.LogSampling)
+			if err := logging.SetRedactionPatterns(options.LogRedactionPatterns); err != nil {
+				return err
+			}
+
 			tlsCache, err := canonicalPath(options.TLSCache)
 			if err != nil {
 				return err
@@ -57,6 +67,9 @@ func newServerCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("creating server: %w", err)
 			}
+			if options.DryRun {
+				return nil
+			}
 			return runServer(cmd.Context(), srv)
 		},
 	}
@@ -77,22 +90,47 @@ func newServerCmd() *cobra.Command {
 	cmd.Flags().Duration("session-extension-deadline", 0, "A user must interact with Infra at least once within this amount of time for their session to remain valid")
 	cmd.Flags().Bool("enable-signup", false, "Enable one-time admin signup")
 	cmd.Flags().String("base-domain", "", "base-domain for the server, eg example.com")
+	cmd.Flags().Bool("dry-run", false, "Log the changes the config would make to the database, without applying them, and exit")
 
 	return cmd
 }
 
 func defaultServerOptions(infraDir string) server.Options {
 	return server.Options{
-		Version:                  0.2, // update this as the config version changes
-		TLSCache:                 filepath.Join(infraDir, "cache"),
-		DBEncryptionKey:          filepath.Join(infraDir, "sqlite3.db.key"),
-		DBEncryptionKeyProvider:  "native",
-		EnableTelemetry:          true,
-		SessionDuration:          24 * time.Hour * 30, // 30 days
-		SessionExtensionDeadline: 24 * time.Hour * 3,  // 3 days
-		EnableSignup:             false,
-		BaseDomain:               "",
-		EnableLogSampling:        true,
+		Version:                    0.2, // update this as the config version changes
+		TLSCache:                   filepath.Join(infraDir, "cache"),
+		DBEncryptionKey:            filepath.Join(infraDir, "sqlite3.db.key"),
+		DBEncryptionKeyProvider:    "native",
+		EnableTelemetry:            true,
+		SessionDuration:            24 * time.Hour * 30,  // 30 days
+		SessionExtensionDeadline:   24 * time.Hour * 3,   // 3 days
+		MaxAccessKeyTTL:            24 * time.Hour * 365, // 1 year
+		MaxRequestBodyBytes:        1024 * 1024,          // 1 MiB
+		ShutdownGracePeriod:        30 * time.Second,
+		ProviderRequestTimeout:     10 * time.Second,
+		ProviderDiscoveryCacheTTL:  10 * time.Minute,
+		AccessKeyExtensionInterval: time.Minute,
+		EnableSignup:               false,
+		BaseDomain:                 "",
+		EnableLogSampling:          true,
+		EnablePprof:                true,
+
+		AuthRateLimit: server.RateLimitOptions{
+			RequestsPerMinute: 60,
+			Burst:             10,
+		},
+
+		LoginLockout: server.LoginLockoutOptions{
+			MaxAttempts: 10,
+			Window:      15 * time.Minute,
+			Duration:    15 * time.Minute,
+		},
+
+		Cookie: server.CookieOptions{
+			SameSite: "strict",
+		},
+
+		SecurityHeaders: server.DefaultSecurityHeadersOptions(),
 
 		Addr: server.ListenerOptions{
 			HTTP:    ":80",
@@ -14,7 +14,9 @@ import (
 	"gotest.tools/v3/fs"
 
 	"github.com/infrahq/infra/internal/cmd/types"
+	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server"
+	"github.com/infrahq/infra/internal/server/webhook"
 	"github.com/infrahq/infra/internal/testing/database"
 )
 
@@ -124,9 +126,59 @@ version: 0.2
 tlsCache: /cache/dir
 enableTelemetry: false # default is true
 enableSignup: false    # default is true
+disableSignup: true
+signupDisabledMessage: signup is paused during maintenance
 enableLogSampling: false # default is true
+enablePprof: false       # default is true
+logSampling:
+  first: 10
+  period: 2s
+logFile:
+  filename: /var/log/infra.log
+  maxSizeMB: 20
+  maxBackups: 3
+  maxAgeDays: 7
+  format: console
+logRedactionPatterns:
+  - 'ssn=(\d+)'
 sessionDuration: 3m
 sessionExtensionDeadline: 1m
+maxAccessKeyTTL: 48h
+maxRequestBodyBytes: 2048
+authorizationCacheTTL: 5s
+providerRequestTimeout: 3s
+providerDiscoveryCacheTTL: 1m
+accessKeyExtensionInterval: 30s
+accessKeyChecksum:
+  keys:
+    - env:INFRA_CHECKSUM_KEY
+    - env:INFRA_PREVIOUS_CHECKSUM_KEY
+  disableLegacy: true
+defaultAPIVersion: 0.14.0
+shutdownGracePeriod: 10s
+authRateLimit:
+  requestsPerMinute: 30
+  burst: 5
+  trustedProxies:
+    - 10.0.0.0/8
+loginLockout:
+  maxAttempts: 5
+  window: 10m
+  duration: 1h
+cookie:
+  sameSite: lax
+  namePrefix: infra_acme_
+  hostPrefix: true
+cors:
+  allowedOrigins:
+    - https://app.example.com
+  allowCredentials: true
+securityHeaders:
+  frameOptions: SAMEORIGIN
+  contentSecurityPolicy: ""
+webhook:
+  url: https://hooks.example.com/infra
+  secret: the-webhook-secret
 
 dbEncryptionKey: /this-is-the-path
 dbEncryptionKeyProvider: the-provider
@@ -173,6 +225,12 @@ providers:
     clientID: client-id
     clientSecret: the-secret
 
+groups:
+  - name: group1
+    users: [user1]
+
+skipPrune: true
+
 grants:
   - user: user1
     resource: infra
@@ -193,10 +251,64 @@ users:
 			},
 			expected: func(t *testing.T) server.Options {
 				return server.Options{
-					Version:                  0.2,
-					TLSCache:                 "/cache/dir",
-					SessionDuration:          3 * time.Minute,
-					SessionExtensionDeadline: 1 * time.Minute,
+					Version:                    0.2,
+					TLSCache:                   "/cache/dir",
+					SessionDuration:            3 * time.Minute,
+					SessionExtensionDeadline:   1 * time.Minute,
+					MaxAccessKeyTTL:            48 * time.Hour,
+					MaxRequestBodyBytes:        2048,
+					AuthorizationCacheTTL:      5 * time.Second,
+					ProviderRequestTimeout:     3 * time.Second,
+					ProviderDiscoveryCacheTTL:  time.Minute,
+					AccessKeyExtensionInterval: 30 * time.Second,
+					DefaultAPIVersion:          "0.14.0",
+					ShutdownGracePeriod:        10 * time.Second,
+					DisableSignup:              true,
+					SignupDisabledMessage:      "signup is paused during maintenance",
+					AccessKeyChecksum: server.AccessKeyChecksumOptions{
+						Keys:          []string{"env:INFRA_CHECKSUM_KEY", "env:INFRA_PREVIOUS_CHECKSUM_KEY"},
+						DisableLegacy: true,
+					},
+					LogSampling: logging.SamplingOptions{
+						First:  10,
+						Period: 2 * time.Second,
+					},
+					LogFile: logging.FileOptions{
+						Filename:   "/var/log/infra.log",
+						MaxSizeMB:  20,
+						MaxBackups: 3,
+						MaxAgeDays: 7,
+						Format:     "console",
+					},
+					LogRedactionPatterns: []string{`ssn=(\d+)`},
+					AuthRateLimit: server.RateLimitOptions{
+						RequestsPerMinute: 30,
+						Burst:             5,
+						TrustedProxies:    []string{"10.0.0.0/8"},
+					},
+					LoginLockout: server.LoginLockoutOptions{
+						MaxAttempts: 5,
+						Window:      10 * time.Minute,
+						Duration:    time.Hour,
+					},
+					Cookie: server.CookieOptions{
+						SameSite:   "lax",
+						NamePrefix: "infra_acme_",
+						HostPrefix: true,
+					},
+					CORS: server.CORSOptions{
+						AllowedOrigins:   []string{"https://app.example.com"},
+						AllowCredentials: true,
+					},
+					SecurityHeaders: server.SecurityHeadersOptions{
+						StrictTransportSecurity: "max-age=31536000",
+						ContentTypeOptions:      "nosniff",
+						FrameOptions:            "SAMEORIGIN",
+					},
+					Webhook: webhook.Options{
+						URL:    "https://hooks.example.com/infra",
+						Secret: "the-webhook-secret",
+					},
 
 					DBEncryptionKey:         "/this-is-the-path",
 					DBEncryptionKeyProvider: "the-provider",
@@ -257,6 +369,10 @@ users:
 								ClientSecret: "the-secret",
 							},
 						},
+						Groups: []server.Group{
+							{Name: "group1", Users: []string{"user1"}},
+						},
+						SkipPrune: true,
 						Grants: []server.Grant{
 							{
 								User:     "user1",
@@ -291,6 +407,7 @@ users:
 					"--session-duration", "3m",
 					"--session-extension-deadline", "1m",
 					"--enable-signup=false",
+					"--dry-run",
 				})
 			},
 			expected: func(t *testing.T) server.Options {
@@ -302,6 +419,7 @@ users:
 				expected.SessionDuration = 3 * time.Minute
 				expected.SessionExtensionDeadline = 1 * time.Minute
 				expected.EnableSignup = false
+				expected.DryRun = true
 				expected.BaseDomain = ""
 				return expected
 			},
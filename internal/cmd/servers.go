@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/infrahq/infra/internal/logging"
+)
+
+func newServersCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "servers",
+		Short: "Manage the servers you are logged in to",
+		Group: "Management commands:",
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return rootPreRun(cmd.Flags())
+		},
+	}
+
+	cmd.AddCommand(newServersListCmd(cli))
+	cmd.AddCommand(newServersUseCmd(cli))
+
+	return cmd
+}
+
+func newServersListCmd(cli *CLI) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the servers in your config",
+		Args:    NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			config, err := readConfig()
+			if err != nil {
+				return err
+			}
+
+			type row struct {
+				Current string `header:"CURRENT"`
+				Server  string `header:"SERVER"`
+				User    string `header:"USER"`
+				Status  string `header:"STATUS"`
+				TLS     string `header:"TLS"`
+			}
+
+			var rows []row
+			for _, hostConfig := range config.Hosts {
+				r := row{
+					Server: hostConfig.Host,
+					User:   hostConfig.Name,
+					Status: "Logged out",
+					TLS:    tlsVerification(hostConfig),
+				}
+				if hostConfig.Current {
+					r.Current = "*"
+				}
+				switch {
+				case hostConfig.isLoggedIn():
+					r.Status = "Logged in"
+				case hostConfig.AccessKey != "" && hostConfig.isExpired():
+					r.Status = "Expired"
+				}
+				rows = append(rows, r)
+			}
+
+			if len(rows) > 0 {
+				printTable(rows, cli.Stdout)
+			} else {
+				cli.Output("No servers found; run 'infra login' to log in to a server")
+			}
+			return nil
+		},
+	}
+}
+
+// tlsVerification describes how the TLS certificate of the server is verified.
+// Servers that skip verification are flagged as insecure.
+func tlsVerification(hostConfig ClientHostConfig) string {
+	switch {
+	case hostConfig.PinnedCA != "":
+		return "pinned-ca"
+	case hostConfig.SkipTLSVerify:
+		return "INSECURE"
+	default:
+		return "verified"
+	}
+}
+
+func newServersUseCmd(cli *CLI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "use SERVER",
+		Short: "Switch the current server without logging out",
+		Long: `Switch the current server without logging out of the other servers.
+The kubeconfig is updated with the destinations of the new current server.`,
+		Example: `# Switch to another server
+$ infra servers use infra.example.com`,
+		Args: ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return useServer(cli, args[0])
+		},
+	}
+}
+
+// useServer makes host the current server, and updates the kubeconfig with
+// the destinations that the user can access on that server.
+func useServer(cli *CLI, host string) error {
+	config, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	hostConfig, err := setCurrentHost(config, host)
+	if err != nil {
+		return err
+	}
+
+	if err := writeConfig(config); err != nil {
+		return err
+	}
+
+	logging.Debugf("switched current server to [%s]", hostConfig.Host)
+
+	client := apiClient(hostConfig.Host, hostConfig.AccessKey, httpTransportForHostConfig(hostConfig))
+	if err := updateKubeConfig(client, hostConfig.UserID); err != nil {
+		return fmt.Errorf("update kubeconfig: %w", err)
+	}
+
+	fmt.Fprintf(cli.Stderr, "  Switched to %s as %s\n", hostConfig.Host, hostConfig.Name)
+	return nil
+}
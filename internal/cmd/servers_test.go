@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/uid"
+)
+
+func TestServersCmd(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // Windows
+
+	// k8s.io/tools/clientcmd reads HOME at import time, so this must be patched too
+	t.Setenv("KUBECONFIG", filepath.Join(home, "config"))
+
+	userID := uid.New()
+	handler := func(resp http.ResponseWriter, req *http.Request) {
+		var body any
+		switch req.URL.Path {
+		case "/api/destinations":
+			body = api.ListResponse[api.Destination]{
+				Items: []api.Destination{{
+					ID:   uid.New(),
+					Name: "cluster",
+					Connection: api.DestinationConnection{
+						URL: "kubernetes.docker.local",
+						CA:  destinationCA,
+					},
+				}},
+			}
+		case "/api/grants":
+			body = api.ListResponse[api.Grant]{
+				Items: []api.Grant{{ID: uid.New(), User: userID, Resource: "cluster", Privilege: "admin"}},
+			}
+		case fmt.Sprintf("/api/users/%s", userID):
+			body = api.User{ID: userID, Name: "testuser@example.com"}
+		default:
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Check(t, json.NewEncoder(resp).Encode(body))
+	}
+
+	first := httptest.NewTLSServer(http.HandlerFunc(handler))
+	t.Cleanup(first.Close)
+	second := httptest.NewTLSServer(http.HandlerFunc(handler))
+	t.Cleanup(second.Close)
+	loggedOut := httptest.NewTLSServer(http.HandlerFunc(handler))
+	t.Cleanup(loggedOut.Close)
+
+	setup := func(t *testing.T) ClientConfig {
+		cfg := newTestClientConfig(first, api.User{ID: userID})
+		other := newTestClientConfig(second, api.User{ID: userID}).Hosts[0]
+		other.Current = false
+		cfg.Hosts = append(cfg.Hosts, other, ClientHostConfig{
+			Host:          loggedOut.Listener.Addr().String(),
+			Expires:       api.Time(time.Now().Add(-time.Hour)),
+			SkipTLSVerify: true,
+		})
+		assert.NilError(t, writeConfig(&cfg))
+		assert.NilError(t, clearKubeconfig(cfg.Hosts[0].Host, cfg.Hosts[1].Host))
+		return cfg
+	}
+
+	t.Run("list", func(t *testing.T) {
+		cfg := setup(t)
+
+		ctx, bufs := PatchCLI(context.Background())
+		err := Run(ctx, "servers", "list")
+		assert.NilError(t, err)
+
+		var rows [][]string
+		for _, line := range strings.Split(strings.TrimSpace(bufs.Stdout.String()), "\n") {
+			rows = append(rows, strings.Fields(line))
+		}
+		expected := [][]string{
+			{"CURRENT", "SERVER", "USER", "STATUS", "TLS"},
+			{"*", cfg.Hosts[0].Host, "testuser@example.com", "Logged", "in", "verified"},
+			{cfg.Hosts[1].Host, "testuser@example.com", "Logged", "in", "verified"},
+			{cfg.Hosts[2].Host, "Logged", "out", "INSECURE"},
+		}
+		assert.DeepEqual(t, rows, expected)
+	})
+
+	t.Run("use switches current and updates kubeconfig", func(t *testing.T) {
+		cfg := setup(t)
+		secondHost := cfg.Hosts[1].Host
+
+		err := Run(context.Background(), "servers", "use", secondHost)
+		assert.NilError(t, err)
+
+		actual, err := readConfig()
+		assert.NilError(t, err)
+		assert.Assert(t, !actual.Hosts[0].Current)
+		assert.Assert(t, actual.Hosts[1].Current)
+		// the other session is still logged in
+		assert.Equal(t, actual.Hosts[0].AccessKey, "the-access-key")
+
+		kubeconfig, err := clientConfig().RawConfig()
+		assert.NilError(t, err)
+		kubeContext, ok := kubeconfig.Contexts["infra:cluster"]
+		assert.Assert(t, ok, "missing context for the new current server")
+		assert.Equal(t, contextServerHost(kubeContext), secondHost)
+	})
+
+	t.Run("use a server that is not logged in", func(t *testing.T) {
+		cfg := setup(t)
+
+		err := Run(context.Background(), "servers", "use", cfg.Hosts[2].Host)
+		assert.ErrorContains(t, err, "Not logged in to "+cfg.Hosts[2].Host)
+
+		err = Run(context.Background(), "servers", "use", "unknown.example.com")
+		assert.ErrorContains(t, err, "No session for unknown.example.com")
+
+		actual, err := readConfig()
+		assert.NilError(t, err)
+		assert.Assert(t, actual.Hosts[0].Current, "current server should not change")
+	})
+}
@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"encoding/json"
+	"net/http"
 	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal/logging"
 )
 
 func newTokensCmd(cli *CLI) *cobra.Command {
@@ -23,6 +27,7 @@ func newTokensCmd(cli *CLI) *cobra.Command {
 	}
 
 	cmd.AddCommand(newTokensAddCmd(cli))
+	cmd.AddCommand(newTokensRotateSigningKeyCmd(cli))
 
 	return cmd
 }
@@ -38,6 +43,34 @@ func newTokensAddCmd(cli *CLI) *cobra.Command {
 	}
 }
 
+func newTokensRotateSigningKeyCmd(cli *CLI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate-signing-key",
+		Short: "Replace the key used to sign tokens",
+		Long: `Replace the key used to sign tokens. Tokens signed by the previous key
+continue to be accepted by destinations for one hour.`,
+		Args: NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			client, err := defaultAPIClient()
+			if err != nil {
+				return err
+			}
+
+			logging.Debugf("call server: rotate signing key")
+			if err := client.RotateSigningKey(); err != nil {
+				if api.ErrorStatusCode(err) == http.StatusForbidden {
+					logging.Debugf("%s", err.Error())
+					return Error{Message: "Cannot rotate signing key: missing privileges for RotateSigningKey"}
+				}
+				return err
+			}
+
+			cli.Output("Rotated the token signing key")
+			return nil
+		},
+	}
+}
+
 func tokensCreate(cli *CLI) error {
 	client, err := defaultAPIClient()
 	if err != nil {
@@ -455,7 +455,7 @@ func createUser(client *api.Client, name string) (*api.CreateUserResponse, error
 func hasAccessToChangePasswordsForOtherUsers(client *api.Client, config *ClientHostConfig) (bool, error) {
 	grants, err := client.ListGrants(api.ListGrantsRequest{
 		User:          config.UserID,
-		Privilege:     api.InfraAdminRole,
+		Privilege:     []string{api.InfraAdminRole},
 		Resource:      "infra",
 		ShowInherited: true,
 	})
@@ -19,8 +19,11 @@ import (
 
 type authenticator struct {
 	mu          sync.Mutex
-	key         *jose.JSONWebKey
+	keys        []jose.JSONWebKey
 	lastChecked time.Time
+	// etag is the ETag of the response that included keys. It is used to
+	// request the keys again only when they have changed.
+	etag string
 
 	client          httpClient
 	baseURL         string
@@ -42,6 +45,11 @@ func newAuthenticator(url string, options Options) *authenticator {
 
 var JWKCacheRefresh = 5 * time.Minute
 
+// jwkUnknownKeyRefresh is the minimum amount of time between requests for the
+// JWKs when a token is signed by a key that is not in the cached keys. The
+// server may have rotated its signing key since the keys were cached.
+var jwkUnknownKeyRefresh = 10 * time.Second
+
 func (j *authenticator) Authenticate(req *http.Request) (claims.Custom, error) {
 	c := claims.Custom{}
 	authHeader := req.Header.Get("Authorization")
@@ -56,7 +64,12 @@ func (j *authenticator) Authenticate(req *http.Request) (claims.Custom, error) {
 		return c, fmt.Errorf("invalid JWT signature: %w", err)
 	}
 
-	key, err := j.getJWK()
+	var kid string
+	if len(tok.Headers) > 0 {
+		kid = tok.Headers[0].KeyID
+	}
+
+	key, err := j.getJWK(kid)
 	if err != nil {
 		return c, fmt.Errorf("get JWK from server: %w", err)
 	}
@@ -84,19 +97,62 @@ func (j *authenticator) Authenticate(req *http.Request) (claims.Custom, error) {
 	return allClaims.Custom, nil
 }
 
-func (j *authenticator) getJWK() (*jose.JSONWebKey, error) {
+// getJWK returns the key with the key ID kid from the JWKs published by the
+// server. The keys are cached, and requested again when the cache is stale or
+// when no cached key has the key ID. When kid is empty the first key is used,
+// which is the key the server currently uses to sign tokens.
+func (j *authenticator) getJWK(kid string) (*jose.JSONWebKey, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	if !j.lastChecked.IsZero() && time.Now().Before(j.lastChecked.Add(JWKCacheRefresh)) {
-		return j.key, nil
+	if !j.lastChecked.IsZero() {
+		now := time.Now()
+		key := findJWK(j.keys, kid)
+		switch {
+		case now.After(j.lastChecked.Add(JWKCacheRefresh)):
+			// the cached keys are stale, request them again
+		case key != nil:
+			return key, nil
+		case now.Before(j.lastChecked.Add(jwkUnknownKeyRefresh)):
+			return nil, fmt.Errorf("no JWK with key ID %q", kid)
+		}
 	}
 
+	keys, err := j.requestJWKs()
+	if err != nil {
+		return nil, err
+	}
+
+	j.lastChecked = time.Now().UTC()
+	j.keys = keys
+
+	if key := findJWK(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no JWK with key ID %q", kid)
+}
+
+func findJWK(keys []jose.JSONWebKey, kid string) *jose.JSONWebKey {
+	if kid == "" && len(keys) > 0 {
+		return &keys[0]
+	}
+	for i := range keys {
+		if keys[i].KeyID == kid {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func (j *authenticator) requestJWKs() ([]jose.JSONWebKey, error) {
 	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, fmt.Sprintf("%s/.well-known/jwks.json", j.baseURL), nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+j.serverAccessKey)
+	if j.etag != "" && len(j.keys) > 0 {
+		req.Header.Set("If-None-Match", j.etag)
+	}
 
 	res, err := j.client.Do(req)
 	if err != nil {
@@ -104,6 +160,10 @@ func (j *authenticator) getJWK() (*jose.JSONWebKey, error) {
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotModified && len(j.keys) > 0 {
+		return j.keys, nil
+	}
+
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
 		return nil, fmt.Errorf("unexpected response: %v ", res.Status)
 	}
@@ -126,8 +186,6 @@ func (j *authenticator) getJWK() (*jose.JSONWebKey, error) {
 		return nil, errors.New("no jwks provided by infra")
 	}
 
-	j.lastChecked = time.Now().UTC()
-	j.key = &response.Keys[0]
-
-	return &response.Keys[0], nil
+	j.etag = res.Header.Get("ETag")
+	return response.Keys, nil
 }
@@ -369,13 +369,18 @@ func syncWithServer(k8s *kubernetes.Kubernetes, client *api.Client, destination
 
 		// TODO(https://github.com/infrahq/infra/issues/2422): support wildcard resource searches
 		for _, n := range namespaces {
-			g, err := client.ListGrants(api.ListGrantsRequest{Resource: fmt.Sprintf("%s.%s", destination.Name, n)})
+			resource := fmt.Sprintf("%s.%s", destination.Name, n)
+			g, err := client.ListGrants(api.ListGrantsRequest{Resource: resource, ShowWildcard: true})
 			if err != nil {
 				logging.Errorf("error listing grants: %v", err)
 				return
 			}
 
-			grants.Items = append(grants.Items, g.Items...)
+			for _, grant := range g.Items {
+				// a wildcard grant applies to each of the namespaces it matches
+				grant.Resource = resource
+				grants.Items = append(grants.Items, grant)
+			}
 		}
 
 		err = updateRoles(client, k8s, grants.Items)
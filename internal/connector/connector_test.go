@@ -34,6 +34,8 @@ func TestAuthenticator_Authenticate(t *testing.T) {
 	}
 
 	pub, priv := generateJWK(t)
+	retiredPub, retiredPriv := generateJWK(t)
+	_, unknownPriv := generateJWK(t)
 
 	run := func(t *testing.T, tc testCase) {
 		req := httptest.NewRequest(http.MethodGet, "/apis", nil)
@@ -120,6 +122,26 @@ func TestAuthenticator_Authenticate(t *testing.T) {
 				assert.DeepEqual(t, actual, expected)
 			},
 		},
+		{
+			name: "JWT signed by a retired key",
+			setup: func(t *testing.T, req *http.Request) {
+				j := generateJWT(t, retiredPriv, "test@example.com", time.Now().Add(time.Hour))
+				req.Header.Set("Authorization", "Bearer "+j)
+			},
+			fakeClient: fakeClient{keys: []jose.JSONWebKey{*pub, *retiredPub}},
+			expected: func(t *testing.T, actual claims.Custom) {
+				assert.Equal(t, actual.Name, "test@example.com")
+			},
+		},
+		{
+			name: "JWT signed by an unknown key",
+			setup: func(t *testing.T, req *http.Request) {
+				j := generateJWT(t, unknownPriv, "test@example.com", time.Now().Add(time.Hour))
+				req.Header.Set("Authorization", "Bearer "+j)
+			},
+			fakeClient:  fakeClient{keys: []jose.JSONWebKey{*pub, *retiredPub}},
+			expectedErr: "no JWK with key ID",
+		},
 		{
 			name: "error status code from server",
 			setup: func(t *testing.T, req *http.Request) {
@@ -176,6 +198,7 @@ func generateJWT(t *testing.T, priv *jose.JSONWebKey, email string, expiry time.
 
 type fakeClient struct {
 	key        jose.JSONWebKey
+	keys       []jose.JSONWebKey // when set, used instead of key
 	err        error
 	statusCode int
 }
@@ -186,6 +209,9 @@ func (f fakeClient) Do(req *http.Request) (*http.Response, error) {
 	}
 
 	r := server.WellKnownJWKResponse{Keys: []jose.JSONWebKey{f.key}}
+	if len(f.keys) > 0 {
+		r.Keys = f.keys
+	}
 
 	var buf bytes.Buffer
 	err := json.NewEncoder(&buf).Encode(r)
@@ -247,3 +273,92 @@ func TestCertCache_Certificate(t *testing.T) {
 		assert.Equal(t, parsedCert.DNSNames[0], "test-host")
 	})
 }
+
+func TestAuthenticator_GetJWK_Rotation(t *testing.T) {
+	oldPub, _ := generateJWK(t)
+	newPub, _ := generateJWK(t)
+
+	client := &countingClient{fakeClient: fakeClient{keys: []jose.JSONWebKey{*oldPub}}}
+	authn := newAuthenticator("https://127.0.0.1:12345", Options{
+		Server: ServerOptions{SkipTLSVerify: true, AccessKey: "the-access-key"},
+	})
+	authn.client = client
+
+	key, err := authn.getJWK(oldPub.KeyID)
+	assert.NilError(t, err)
+	assert.Equal(t, key.KeyID, oldPub.KeyID)
+	assert.Equal(t, client.requests, 1)
+
+	// the server rotated its signing key
+	client.keys = []jose.JSONWebKey{*newPub, *oldPub}
+
+	t.Run("cached key is used", func(t *testing.T) {
+		key, err := authn.getJWK(oldPub.KeyID)
+		assert.NilError(t, err)
+		assert.Equal(t, key.KeyID, oldPub.KeyID)
+		assert.Equal(t, client.requests, 1)
+	})
+
+	t.Run("unknown key is not requested again right away", func(t *testing.T) {
+		_, err := authn.getJWK(newPub.KeyID)
+		assert.ErrorContains(t, err, "no JWK with key ID")
+		assert.Equal(t, client.requests, 1)
+	})
+
+	t.Run("unknown key refreshes the keys", func(t *testing.T) {
+		authn.lastChecked = time.Now().Add(-jwkUnknownKeyRefresh - time.Second)
+
+		key, err := authn.getJWK(newPub.KeyID)
+		assert.NilError(t, err)
+		assert.Equal(t, key.KeyID, newPub.KeyID)
+		assert.Equal(t, client.requests, 2)
+	})
+}
+
+type countingClient struct {
+	fakeClient
+	requests int
+	// etag is sent in the response, and a request with a matching
+	// If-None-Match header receives a 304 Not Modified.
+	etag string
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests++
+	if c.etag != "" && req.Header.Get("If-None-Match") == c.etag {
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Status:     http.StatusText(http.StatusNotModified),
+			Body:       ioutil.NopCloser(&bytes.Buffer{}),
+		}, nil
+	}
+	resp, err := c.fakeClient.Do(req)
+	if err == nil && c.etag != "" {
+		resp.Header = http.Header{"Etag": {c.etag}}
+	}
+	return resp, err
+}
+
+func TestAuthenticator_GetJWK_NotModified(t *testing.T) {
+	pub, _ := generateJWK(t)
+
+	client := &countingClient{fakeClient: fakeClient{keys: []jose.JSONWebKey{*pub}}, etag: `W/"abc"`}
+	authn := newAuthenticator("https://127.0.0.1:12345", Options{
+		Server: ServerOptions{SkipTLSVerify: true, AccessKey: "the-access-key"},
+	})
+	authn.client = client
+
+	_, err := authn.getJWK(pub.KeyID)
+	assert.NilError(t, err)
+	assert.Equal(t, authn.etag, `W/"abc"`)
+
+	// the cache is stale, the keys are requested with the ETag
+	authn.lastChecked = time.Now().Add(-JWKCacheRefresh - time.Second)
+	client.keys = nil
+
+	key, err := authn.getJWK(pub.KeyID)
+	assert.NilError(t, err)
+	assert.Equal(t, key.KeyID, pub.KeyID)
+	assert.Equal(t, client.requests, 2)
+	assert.Assert(t, time.Since(authn.lastChecked) < time.Minute)
+}
@@ -9,6 +9,8 @@ import (
 var (
 	// ErrUnauthorized refers to the http response code unauthorized, which really means not authenticated, despite its name. See https://stackoverflow.com/a/6937030/155585
 	ErrUnauthorized = fmt.Errorf("unauthorized")
+	// ErrForbidden means the request was authenticated, but is not permitted
+	ErrForbidden = fmt.Errorf("forbidden")
 	// ErrBadGateway means an invalid response was received from an upstream server (probably an OIDC provider)
 	ErrBadGateway = fmt.Errorf("bad gateway")
 
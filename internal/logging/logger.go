@@ -2,6 +2,7 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -14,7 +15,7 @@ import (
 
 var L = &logger{
 	Logger: zerolog.New(zerolog.ConsoleWriter{
-		Out:          os.Stderr,
+		Out:          newRedactingWriter(os.Stderr),
 		NoColor:      !isTerminal(),
 		PartsExclude: []string{"time"},
 		FormatLevel:  consoleFormatLevel,
@@ -36,10 +37,19 @@ func init() {
 
 func newLogger(writer io.Writer) *logger {
 	return &logger{
-		Logger: zerolog.New(writer).With().Timestamp().Caller().Logger(),
+		Logger: zerolog.New(newRedactingWriter(writer)).With().Timestamp().Caller().Logger(),
 	}
 }
 
+// Formats of log entries.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// format is the format of the logs written by L.
+var format = FormatConsole
+
 // UseServerLogger changes L to a logger appropriate for long-running processes,
 // like the infra server and connector. If the process is being run in an
 // interactive terminal, use the default console logger.
@@ -48,23 +58,102 @@ func UseServerLogger() {
 		return
 	}
 	L = newLogger(os.Stderr)
+	format = FormatJSON
 }
 
 func isTerminal() bool {
 	return os.Stdin != nil && term.IsTerminal(int(os.Stdin.Fd()))
 }
 
+// FileOptions configure a log file, and when the file is rotated.
+type FileOptions struct {
+	// Filename is the path to the log file.
+	Filename string
+	// MaxSizeMB is the size in megabytes at which the file is rotated.
+	// Defaults to 10.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files to keep. Defaults to 7.
+	MaxBackups int
+	// MaxAgeDays is the number of days to keep rotated files. Defaults to 28.
+	MaxAgeDays int
+	// Format is the format of log entries, either "json" or "console".
+	// Defaults to the format of the current logger.
+	Format string
+}
+
 // UseFileLogger changes L to a logger that writes log output to a file that is
 // rotated.
-func UseFileLogger(filepath string) {
+func UseFileLogger(opts FileOptions) error {
+	logger, err := newFileLogger(opts)
+	if err != nil {
+		return err
+	}
+	L = logger
+	return nil
+}
+
+func newFileLogger(opts FileOptions) (*logger, error) {
+	if opts.Format == "" {
+		opts.Format = format
+	}
+	writer := newFileWriter(opts)
+	switch opts.Format {
+	case FormatJSON:
+		format = FormatJSON
+		return newLogger(writer), nil
+	case FormatConsole:
+		format = FormatConsole
+		console := zerolog.ConsoleWriter{
+			Out:     newRedactingWriter(writer),
+			NoColor: true,
+			FormatLevel: func(i interface{}) string {
+				return formatLevel(i, true)
+			},
+		}
+		return &logger{
+			Logger: zerolog.New(console).With().Timestamp().Caller().Logger(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, must be one of: %v, %v",
+			opts.Format, FormatJSON, FormatConsole)
+	}
+}
+
+func newFileWriter(opts FileOptions) *lumberjack.Logger {
 	writer := &lumberjack.Logger{
-		Filename:   filepath,
-		MaxSize:    10, // megabytes
-		MaxBackups: 7,
-		MaxAge:     28, // days
+		Filename:   opts.Filename,
+		MaxSize:    opts.MaxSizeMB,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAgeDays,
+	}
+	if writer.MaxSize <= 0 {
+		writer.MaxSize = 10
+	}
+	if writer.MaxBackups <= 0 {
+		writer.MaxBackups = 7
+	}
+	if writer.MaxAge <= 0 {
+		writer.MaxAge = 28
 	}
+	return writer
+}
 
-	L = newLogger(writer)
+type loggerContextKey struct{}
+
+// WithContext returns a copy of ctx that stores logger. Use Ctx to retrieve the
+// logger.
+func WithContext(ctx context.Context, logger zerolog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, &logger)
+}
+
+// Ctx returns the logger stored in ctx by WithContext, or the global L logger
+// if ctx has no logger. Request handlers should use Ctx so that the fields
+// that identify the request are included in the logs.
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*zerolog.Logger); ok {
+		return logger
+	}
+	return &L.Logger
 }
 
 func Debugf(format string, v ...interface{}) {
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFileLogger_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	writer := newFileWriter(FileOptions{
+		Filename:  filepath.Join(dir, "infra.log"),
+		MaxSizeMB: 1,
+	})
+	t.Cleanup(func() {
+		assert.NilError(t, writer.Close())
+	})
+	logger := newLogger(writer)
+
+	message := strings.Repeat("a", 1024)
+	for i := 0; i < 900; i++ {
+		logger.Info().Msg(message)
+	}
+
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1, "rotated before reaching the max size")
+
+	for i := 0; i < 200; i++ {
+		logger.Info().Msg(message)
+	}
+
+	entries, err = os.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 2, "expected a rotated file")
+
+	info, err := os.Stat(filepath.Join(dir, "infra.log"))
+	assert.NilError(t, err)
+	assert.Assert(t, info.Size() < 1024*1024)
+}
+
+func TestFileOptions_Defaults(t *testing.T) {
+	writer := newFileWriter(FileOptions{Filename: "infra.log"})
+	assert.Equal(t, writer.MaxSize, 10)
+	assert.Equal(t, writer.MaxBackups, 7)
+	assert.Equal(t, writer.MaxAge, 28)
+}
+
+func TestFileLogger_Format(t *testing.T) {
+	origFormat := format
+	t.Cleanup(func() {
+		format = origFormat
+	})
+
+	readLogs := func(t *testing.T, opts FileOptions) string {
+		t.Helper()
+		opts.Filename = filepath.Join(t.TempDir(), "infra.log")
+		logger, err := newFileLogger(opts)
+		assert.NilError(t, err)
+		logger.Info().Str("user", "alice").Msg("the message")
+
+		content, err := os.ReadFile(opts.Filename)
+		assert.NilError(t, err)
+		return string(content)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		logs := readLogs(t, FileOptions{Format: FormatJSON})
+		assert.Assert(t, strings.HasPrefix(logs, `{"level":"info"`), logs)
+		assert.Assert(t, strings.Contains(logs, `"message":"the message"`), logs)
+	})
+
+	t.Run("console", func(t *testing.T) {
+		logs := readLogs(t, FileOptions{Format: FormatConsole})
+		assert.Assert(t, strings.Contains(logs, "INFO "), logs)
+		assert.Assert(t, strings.Contains(logs, "the message user=alice"), logs)
+		assert.Assert(t, !strings.Contains(logs, "\x1b["), logs)
+	})
+
+	t.Run("default to the format of the current logger", func(t *testing.T) {
+		format = FormatJSON
+		logs := readLogs(t, FileOptions{})
+		assert.Assert(t, strings.HasPrefix(logs, `{"level":"info"`), logs)
+
+		format = FormatConsole
+		logs = readLogs(t, FileOptions{})
+		assert.Assert(t, strings.Contains(logs, "the message user=alice"), logs)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := newFileLogger(FileOptions{Format: "xml"})
+		assert.ErrorContains(t, err, `invalid log format "xml"`)
+	})
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+)
+
+const redacted = "REDACTED"
+
+// DefaultRedactionPatterns match sensitive values that should never be written
+// to logs. When a pattern has a capture group only the text matched by the
+// first group is redacted, otherwise the entire match is redacted.
+var DefaultRedactionPatterns = []string{
+	// bearer tokens in an Authorization header
+	`(?i)bearer\s+([^\s"'\\,]+)`,
+	// the secret part of an access key
+	`\b[a-zA-Z0-9]{10}\.([a-zA-Z0-9]{24})\b`,
+	// passwords in JSON, query strings, and connection strings
+	`(?i)password\\?"?\s*[:=]\s*\\?"?([^\s"'\\,}&]+)`,
+}
+
+// redactor replaces the text matched by patterns with REDACTED.
+type redactor struct {
+	mu       sync.RWMutex
+	patterns []*regexp.Regexp
+}
+
+var defaultRedactor = mustNewRedactor(DefaultRedactionPatterns)
+
+func newRedactor(patterns []string) (*redactor, error) {
+	r := &redactor{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func mustNewRedactor(patterns []string) *redactor {
+	r, err := newRedactor(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// SetRedactionPatterns sets the regular expressions used to redact sensitive
+// values from all log output, in addition to DefaultRedactionPatterns.
+func SetRedactionPatterns(patterns []string) error {
+	r, err := newRedactor(append(append([]string{}, DefaultRedactionPatterns...), patterns...))
+	if err != nil {
+		return err
+	}
+
+	defaultRedactor.mu.Lock()
+	defer defaultRedactor.mu.Unlock()
+	defaultRedactor.patterns = r.patterns
+	return nil
+}
+
+// redact returns b with all matches replaced. If nothing matches, b is
+// returned without being copied.
+func (r *redactor) redact(b []byte) []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, re := range r.patterns {
+		if !re.Match(b) {
+			continue
+		}
+		b = re.ReplaceAllFunc(b, func(match []byte) []byte {
+			return redactMatch(re, match)
+		})
+	}
+	return b
+}
+
+func redactMatch(re *regexp.Regexp, match []byte) []byte {
+	idx := re.FindSubmatchIndex(match)
+	if len(idx) < 4 || idx[2] < 0 {
+		return []byte(redacted)
+	}
+
+	result := make([]byte, 0, len(match))
+	result = append(result, match[:idx[2]]...)
+	result = append(result, redacted...)
+	return append(result, match[idx[3]:]...)
+}
+
+// redactingWriter redacts sensitive values from each write before writing it to
+// the underlying writer.
+type redactingWriter struct {
+	io.Writer
+	redactor *redactor
+}
+
+func newRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{Writer: w, redactor: defaultRedactor}
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := w.Writer.Write(w.redactor.redact(p)); err != nil {
+		return 0, err
+	}
+	// report the length of the original, so that callers don't treat a
+	// shorter redacted write as an error.
+	return len(p), nil
+}
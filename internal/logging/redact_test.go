@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"gotest.tools/v3/assert"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	token := "abcdefghij.0123456789abcdefghijklmn"
+
+	t.Run("json", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		logger := newLogger(b)
+		logger.Info().Str("authorization", "Bearer "+token).Msg("request")
+
+		assert.Assert(t, !strings.Contains(b.String(), token), b.String())
+		assert.Assert(t, strings.Contains(b.String(), `"authorization":"Bearer REDACTED"`), b.String())
+	})
+
+	t.Run("console", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		logger := zerolog.New(zerolog.ConsoleWriter{Out: newRedactingWriter(b), NoColor: true})
+		logger.Info().Msgf("failed with header Authorization: Bearer %v", token)
+
+		assert.Assert(t, !strings.Contains(b.String(), token), b.String())
+		assert.Assert(t, strings.Contains(b.String(), "Authorization: Bearer REDACTED"), b.String())
+	})
+}
+
+func TestRedactor(t *testing.T) {
+	r := mustNewRedactor(DefaultRedactionPatterns)
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "nothing to see here",
+			expected: "nothing to see here",
+		},
+		{
+			input:    `{"msg":"login","accessKey":"abcdefghij.0123456789abcdefghijklmn"}`,
+			expected: `{"msg":"login","accessKey":"abcdefghij.REDACTED"}`,
+		},
+		{
+			input:    `{"msg":"body {\"name\":\"a@example.com\",\"password\":\"hunter2\"}"}`,
+			expected: `{"msg":"body {\"name\":\"a@example.com\",\"password\":\"REDACTED\"}"}`,
+		},
+		{
+			input:    `connecting with host=db user=infra password=hunter2 port=5432`,
+			expected: `connecting with host=db user=infra password=REDACTED port=5432`,
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, string(r.redact([]byte(tc.input))), tc.expected)
+	}
+
+	t.Run("nothing matched is not copied", func(t *testing.T) {
+		input := []byte("nothing to see here")
+		assert.Equal(t, &r.redact(input)[0], &input[0])
+	})
+}
+
+func TestSetRedactionPatterns(t *testing.T) {
+	orig := defaultRedactor.patterns
+	t.Cleanup(func() {
+		defaultRedactor.patterns = orig
+	})
+
+	err := SetRedactionPatterns([]string{`ssn=(\d+)`})
+	assert.NilError(t, err)
+
+	b := &bytes.Buffer{}
+	logger := newLogger(b)
+	logger.Info().Msg("ssn=123456789 Bearer token")
+	assert.Assert(t, strings.Contains(b.String(), "ssn=REDACTED Bearer REDACTED"), b.String())
+
+	err = SetRedactionPatterns([]string{`(`})
+	assert.ErrorContains(t, err, "invalid redaction pattern")
+}
@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SamplingOptions configure the sampling of identical log messages, which
+// reduces the volume of logs when the same message is logged many times.
+type SamplingOptions struct {
+	// First is the number of identical messages that are logged in each
+	// period. The rest of the identical messages in that period are dropped.
+	// Zero disables sampling.
+	First int
+	// Period is the length of each sampling period. Defaults to one second.
+	Period time.Duration
+}
+
+// UseSampling changes L to drop identical debug and info messages after
+// opts.First messages in each period. Warnings and errors are never dropped.
+// The first message logged after a period where messages were dropped includes
+// a sampledDropped field with the number of messages that were dropped.
+func UseSampling(opts SamplingOptions) {
+	if opts.First <= 0 {
+		return
+	}
+	L = &logger{Logger: L.Hook(newSamplingHook(opts))}
+}
+
+type samplingHook struct {
+	first  int
+	period time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	counters  map[samplingKey]*samplingCounter
+	lastPurge time.Time
+}
+
+type samplingKey struct {
+	level   zerolog.Level
+	message string
+}
+
+type samplingCounter struct {
+	resetAt time.Time
+	count   int
+	dropped int
+}
+
+func newSamplingHook(opts SamplingOptions) *samplingHook {
+	period := opts.Period
+	if period <= 0 {
+		period = time.Second
+	}
+	return &samplingHook{
+		first:    opts.First,
+		period:   period,
+		now:      time.Now,
+		counters: map[samplingKey]*samplingCounter{},
+	}
+}
+
+func (h *samplingHook) Run(e *zerolog.Event, level zerolog.Level, message string) {
+	if level >= zerolog.WarnLevel || level == zerolog.NoLevel {
+		return
+	}
+
+	now := h.now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.purge(now)
+
+	key := samplingKey{level: level, message: message}
+	counter, ok := h.counters[key]
+	if !ok {
+		counter = &samplingCounter{}
+		h.counters[key] = counter
+	}
+
+	if !now.Before(counter.resetAt) {
+		if counter.dropped > 0 {
+			e.Int("sampledDropped", counter.dropped)
+		}
+		counter.resetAt = now.Add(h.period)
+		counter.count = 0
+		counter.dropped = 0
+	}
+
+	counter.count++
+	if counter.count > h.first {
+		counter.dropped++
+		e.Discard()
+	}
+}
+
+// purge removes the counters of messages that have not been logged recently, so
+// that the number of counters does not grow without bound. The caller must hold
+// the lock.
+func (h *samplingHook) purge(now time.Time) {
+	if now.Sub(h.lastPurge) < h.period {
+		return
+	}
+	for key, counter := range h.counters {
+		if now.After(counter.resetAt) && counter.dropped == 0 {
+			delete(h.counters, key)
+		}
+	}
+	h.lastPurge = now
+}
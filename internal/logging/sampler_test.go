@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gotest.tools/v3/assert"
+)
+
+func TestSamplingHook(t *testing.T) {
+	now := time.Date(2022, time.October, 12, 10, 0, 0, 0, time.UTC)
+	hook := newSamplingHook(SamplingOptions{First: 3, Period: time.Second})
+	hook.now = func() time.Time { return now }
+
+	b := &bytes.Buffer{}
+	logger := zerolog.New(b).Hook(hook)
+
+	for i := 0; i < 100; i++ {
+		logger.Info().Msg("the same message")
+	}
+	logger.Info().Msg("a different message")
+	for i := 0; i < 5; i++ {
+		logger.Error().Msg("the same message")
+	}
+
+	type entry struct {
+		Level          string `json:"level"`
+		Message        string `json:"message"`
+		SampledDropped int    `json:"sampledDropped"`
+	}
+	decode := func() []entry {
+		var entries []entry
+		dec := json.NewDecoder(b)
+		for dec.More() {
+			var e entry
+			assert.NilError(t, dec.Decode(&e))
+			entries = append(entries, e)
+		}
+		return entries
+	}
+
+	expected := []entry{
+		{Level: "info", Message: "the same message"},
+		{Level: "info", Message: "the same message"},
+		{Level: "info", Message: "the same message"},
+		{Level: "info", Message: "a different message"},
+		{Level: "error", Message: "the same message"},
+		{Level: "error", Message: "the same message"},
+		{Level: "error", Message: "the same message"},
+		{Level: "error", Message: "the same message"},
+		{Level: "error", Message: "the same message"},
+	}
+	assert.DeepEqual(t, decode(), expected)
+
+	t.Run("next period includes the number dropped", func(t *testing.T) {
+		now = now.Add(time.Second)
+		logger.Info().Msg("the same message")
+		logger.Info().Msg("the same message")
+
+		expected := []entry{
+			{Level: "info", Message: "the same message", SampledDropped: 97},
+			{Level: "info", Message: "the same message"},
+		}
+		assert.DeepEqual(t, decode(), expected)
+	})
+
+	t.Run("idle counters are removed", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		logger.Info().Msg("another message")
+		assert.Equal(t, len(hook.counters), 1)
+	})
+}
+
+func TestUseSampling_Disabled(t *testing.T) {
+	orig := L
+	t.Cleanup(func() {
+		L = orig
+	})
+
+	UseSampling(SamplingOptions{})
+	assert.Equal(t, L, orig)
+}
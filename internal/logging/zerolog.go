@@ -6,10 +6,13 @@ import (
 	"github.com/rs/zerolog"
 )
 
-// consoleDefaultFormatLevel is copied from zerolog/console.go to modify the
-// names and colors used for levels.
 func consoleFormatLevel(i interface{}) string {
-	noColor := !isTerminal()
+	return formatLevel(i, !isTerminal())
+}
+
+// formatLevel is copied from zerolog/console.go to modify the names and colors
+// used for levels.
+func formatLevel(i interface{}, noColor bool) string {
 	l, ok := i.(string)
 	if !ok {
 		return fmt.Sprintf("%v", i)
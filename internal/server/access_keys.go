@@ -1,6 +1,8 @@
 package server
 
 import (
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,8 +13,11 @@ import (
 )
 
 func (a *API) ListAccessKeys(c *gin.Context, r *api.ListAccessKeysRequest) (*api.ListResponse[api.AccessKey], error) {
-	p := PaginationFromRequest(r.PaginationRequest)
-	accessKeys, err := access.ListAccessKeys(c, r.UserID, r.Name, r.ShowExpired, &p)
+	p, err := cursorPaginationFromRequest(r.PaginationRequest, r.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	accessKeys, err := access.ListAccessKeys(c, r.UserID, r.ProviderID, r.Name, r.ShowExpired, &p)
 	if err != nil {
 		return nil, err
 	}
@@ -28,13 +33,52 @@ func (a *API) DeleteAccessKey(c *gin.Context, r *api.Resource) (*api.EmptyRespon
 	return nil, access.DeleteAccessKey(c, r.ID)
 }
 
+func (a *API) DeleteAccessKeys(c *gin.Context, r *api.DeleteAccessKeysRequest) (*api.DeleteAccessKeysResponse, error) {
+	keys, err := access.DeleteAccessKeysByNamePrefix(c, r.NamePrefix, r.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.DeleteAccessKeysResponse{DryRun: r.DryRun}
+	if !r.DryRun {
+		// the response to a delete has no body
+		return resp, nil
+	}
+	for _, key := range keys {
+		resp.Items = append(resp.Items, api.DeletedAccessKey{ID: key.ID, Name: key.Name})
+	}
+	return resp, nil
+}
+
+// accessKeyIdempotencyResponse is the response sent to a retry of a request to
+// create an access key with the same Idempotency-Key. The secret of the access
+// key is only sent in the response to the first request, so the retry receives
+// a conflict with the ID of the access key that was created.
+func accessKeyIdempotencyResponse(resp *api.CreateAccessKeyResponse) (int, any) {
+	return http.StatusConflict, &api.Error{
+		Code:      http.StatusConflict,
+		ErrorCode: api.ErrorCodeConflict,
+		Message: fmt.Sprintf("access key %v was already created by a request with this %v",
+			resp.ID, idempotencyKeyHeader),
+	}
+}
+
 func (a *API) CreateAccessKey(c *gin.Context, r *api.CreateAccessKeyRequest) (*api.CreateAccessKeyResponse, error) {
+	if err := a.validateAccessKeyTTL("ttl", time.Duration(r.TTL)); err != nil {
+		return nil, err
+	}
+
 	accessKey := &models.AccessKey{
 		IssuedFor:         r.UserID,
 		Name:              r.Name,
-		ExpiresAt:         time.Now().UTC().Add(time.Duration(r.TTL)),
 		Extension:         time.Duration(r.ExtensionDeadline),
 		ExtensionDeadline: time.Now().UTC().Add(time.Duration(r.ExtensionDeadline)),
+		OneTimeUse:        r.OneTimeUse,
+		Secret:            r.Secret,
+	}
+	// when the TTL is not set the organization default is used
+	if r.TTL > 0 {
+		accessKey.ExpiresAt = time.Now().UTC().Add(time.Duration(r.TTL))
 	}
 
 	raw, err := access.CreateAccessKey(c, accessKey)
@@ -47,6 +91,7 @@ func (a *API) CreateAccessKey(c *gin.Context, r *api.CreateAccessKeyRequest) (*a
 		Created:           api.Time(accessKey.CreatedAt),
 		Name:              accessKey.Name,
 		IssuedFor:         accessKey.IssuedFor,
+		CreatedBy:         accessKey.CreatedBy,
 		Expires:           api.Time(accessKey.ExpiresAt),
 		ExtensionDeadline: api.Time(accessKey.ExtensionDeadline),
 		AccessKey:         raw,
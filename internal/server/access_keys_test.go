@@ -1,8 +1,10 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -15,6 +17,7 @@ import (
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/server/webhook"
 	"github.com/infrahq/infra/uid"
 )
 
@@ -25,10 +28,15 @@ func TestAPI_CreateAccessKey(t *testing.T) {
 		expected func(t *testing.T, response *httptest.ResponseRecorder)
 	}
 
-	srv := setupServer(t, withAdminUser)
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.MaxAccessKeyTTL = 24 * time.Hour
+	})
 	routes := srv.GenerateRoutes()
 
 	userResp := createUser(t, srv, routes, "usera@example.com")
+	connector := data.InfraConnectorIdentity(srv.DB())
+	admin, err := data.GetIdentity(srv.DB(), data.ByName("admin@example.com"))
+	assert.NilError(t, err)
 
 	run := func(t *testing.T, tc testCase) {
 		body := tc.setup(t)
@@ -64,6 +72,30 @@ func TestAPI_CreateAccessKey(t *testing.T) {
 				assert.Assert(t, strings.HasPrefix(respBody.Name, "usera@example.com-"), respBody.Name)
 			},
 		},
+		{
+			name: "created by the caller",
+			setup: func(t *testing.T) api.CreateAccessKeyRequest {
+				return api.CreateAccessKeyRequest{
+					UserID:            userResp.ID,
+					Name:              "provisioned-by-admin",
+					TTL:               api.Duration(time.Minute),
+					ExtensionDeadline: api.Duration(time.Minute),
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+				respBody := &api.CreateAccessKeyResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.Equal(t, respBody.IssuedFor, userResp.ID)
+				assert.Equal(t, respBody.CreatedBy, admin.ID)
+
+				key, err := data.GetAccessKey(srv.DB(), data.GetAccessKeysOptions{ByID: respBody.ID})
+				assert.NilError(t, err)
+				assert.Equal(t, key.CreatedBy, admin.ID)
+			},
+		},
 		{
 			name: "user provided name",
 			setup: func(t *testing.T) api.CreateAccessKeyRequest {
@@ -106,6 +138,120 @@ func TestAPI_CreateAccessKey(t *testing.T) {
 				assert.DeepEqual(t, respBody.FieldErrors, expected)
 			},
 		},
+		{
+			name: "ttl exceeds maximum",
+			setup: func(t *testing.T) api.CreateAccessKeyRequest {
+				return api.CreateAccessKeyRequest{
+					UserID:            userResp.ID,
+					TTL:               api.Duration(48 * time.Hour),
+					ExtensionDeadline: api.Duration(time.Minute),
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "ttl", Errors: []string{"must be at most 24h0m0s"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "import a secret",
+			setup: func(t *testing.T) api.CreateAccessKeyRequest {
+				return api.CreateAccessKeyRequest{
+					UserID:            userResp.ID,
+					Name:              "imported",
+					TTL:               api.Duration(time.Minute),
+					ExtensionDeadline: api.Duration(time.Minute),
+					Secret:            "Xk93hDfQ2mLpZr7TnWb4YcVe",
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+				respBody := &api.CreateAccessKeyResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.Assert(t, strings.HasSuffix(respBody.AccessKey, ".Xk93hDfQ2mLpZr7TnWb4YcVe"), respBody.AccessKey)
+
+				key, err := data.ValidateRequestAccessKey(srv.DB(), respBody.AccessKey)
+				assert.NilError(t, err)
+				assert.Equal(t, key.ID, respBody.ID)
+			},
+		},
+		{
+			name: "import a weak secret",
+			setup: func(t *testing.T) api.CreateAccessKeyRequest {
+				return api.CreateAccessKeyRequest{
+					UserID:            userResp.ID,
+					TTL:               api.Duration(time.Minute),
+					ExtensionDeadline: api.Duration(time.Minute),
+					Secret:            "abcabcabcabcabcabcabcabc",
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "secret", Errors: []string{"is too predictable, use a randomly generated secret"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "import a secret with the wrong length",
+			setup: func(t *testing.T) api.CreateAccessKeyRequest {
+				return api.CreateAccessKeyRequest{
+					UserID:            userResp.ID,
+					TTL:               api.Duration(time.Minute),
+					ExtensionDeadline: api.Duration(time.Minute),
+					Secret:            "Xk93hDfQ2mLp",
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "secret", Errors: []string{"must be at least 24 characters"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "reserved name format",
+			setup: func(t *testing.T) api.CreateAccessKeyRequest {
+				return api.CreateAccessKeyRequest{
+					UserID:            connector.ID,
+					Name:              connector.Name + "-" + uid.New().String(),
+					TTL:               api.Duration(time.Minute),
+					ExtensionDeadline: api.Duration(time.Minute),
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				assert.Equal(t, len(respBody.FieldErrors), 1)
+				assert.Equal(t, respBody.FieldErrors[0].FieldName, "name")
+				assert.Assert(t, strings.Contains(respBody.FieldErrors[0].Errors[0], "reserved for generated names"))
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -115,6 +261,78 @@ func TestAPI_CreateAccessKey(t *testing.T) {
 	}
 }
 
+func TestAPI_CreateAccessKey_IssuedFor(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	admin, err := data.GetIdentity(srv.DB(), data.ByName("admin@example.com"))
+	assert.NilError(t, err)
+	user := createUser(t, srv, routes, "usera@example.com")
+	other := createUser(t, srv, routes, "userb@example.com")
+
+	// a key issued from login is allowed to create other access keys
+	userKey, err := data.CreateAccessKey(srv.DB(), &models.AccessKey{
+		IssuedFor:  user.ID,
+		ProviderID: data.InfraProvider(srv.DB()).ID,
+		ExpiresAt:  time.Now().Add(time.Minute),
+		Scopes:     []string{models.ScopeAllowCreateAccessKey},
+	})
+	assert.NilError(t, err)
+
+	run := func(t *testing.T, accessKey string, issuedFor uid.ID) *httptest.ResponseRecorder {
+		t.Helper()
+		body := api.CreateAccessKeyRequest{
+			UserID:            issuedFor,
+			TTL:               api.Duration(time.Minute),
+			ExtensionDeadline: api.Duration(time.Minute),
+		}
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodPost, "/api/access-keys", jsonBody(t, body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+accessKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("user creates a key for themselves", func(t *testing.T) {
+		resp := run(t, userKey, user.ID)
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		respBody := &api.CreateAccessKeyResponse{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+		assert.Equal(t, respBody.IssuedFor, user.ID)
+		assert.Equal(t, respBody.CreatedBy, user.ID)
+	})
+
+	t.Run("user creates a key for another user", func(t *testing.T) {
+		resp := run(t, userKey, other.ID)
+		assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+
+		respBody := &api.Error{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+		assert.Equal(t, respBody.ErrorCode, api.ErrorCodeNotAuthorized)
+		assert.Equal(t, respBody.Message,
+			"you do not have permission to create access key for another user, requires role admin")
+
+		keys, err := data.ListAccessKeys(srv.DB(), data.ListAccessKeyOptions{ByIssuedForID: other.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(keys), 0)
+	})
+
+	t.Run("admin creates a key for another user", func(t *testing.T) {
+		resp := run(t, adminAccessKey(srv), other.ID)
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		respBody := &api.CreateAccessKeyResponse{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+		assert.Equal(t, respBody.IssuedFor, other.ID)
+		assert.Equal(t, respBody.CreatedBy, admin.ID)
+	})
+}
+
 func TestAPI_ListAccessKeys_Success(t *testing.T) {
 	srv := setupServer(t, withAdminUser)
 	routes := srv.GenerateRoutes()
@@ -265,3 +483,263 @@ func TestAPI_ListAccessKeys(t *testing.T) {
 		assert.Equal(t, errMsg.Code, int32(400))
 	})
 }
+
+func TestAPI_ListAccessKeys_ResponseFormat(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	run := func(t *testing.T, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodGet, "/api/access-keys?limit=1", nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+		return resp
+	}
+
+	t.Run("default", func(t *testing.T) {
+		for _, accept := range []string{"", "application/json", "*/*"} {
+			resp := run(t, accept)
+			assert.Equal(t, resp.Header().Get("Content-Type"), "application/json; charset=utf-8")
+
+			var body map[string]any
+			assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+			assert.Assert(t, body["items"] != nil, "accept=%q", accept)
+			assert.Equal(t, body["limit"], float64(1), "accept=%q", accept)
+			assert.Assert(t, body["data"] == nil, "accept=%q", accept)
+		}
+	})
+
+	t.Run("envelope", func(t *testing.T) {
+		resp := run(t, api.EnvelopeMediaType)
+		assert.Equal(t, resp.Header().Get("Content-Type"), api.EnvelopeMediaType+"; charset=utf-8")
+
+		var body struct {
+			Data []api.AccessKey  `json:"data"`
+			Meta api.EnvelopeMeta `json:"meta"`
+		}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+		assert.Equal(t, len(body.Data), 1)
+
+		assert.Assert(t, body.Meta.Pagination != nil)
+		assert.Equal(t, body.Meta.Pagination.Page, 1)
+		assert.Equal(t, body.Meta.Pagination.Limit, 1)
+		assert.Assert(t, body.Meta.Pagination.TotalCount >= 1)
+	})
+}
+
+func TestAPI_ListAccessKeys_Filters(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	db := srv.DB()
+	infraProvider := data.InfraProvider(db)
+	okta := &models.Provider{Name: "okta", Kind: models.ProviderKindOkta}
+	assert.NilError(t, data.CreateProvider(db, okta))
+
+	alice := &models.Identity{Name: "alice@example.com"}
+	bob := &models.Identity{Name: "bob@example.com"}
+	createIdentities(t, db, alice, bob)
+
+	createKey := func(name string, user *models.Identity, provider *models.Provider, expiresAt time.Time) {
+		t.Helper()
+		_, err := data.CreateAccessKey(db, &models.AccessKey{
+			Name:       name,
+			IssuedFor:  user.ID,
+			ProviderID: provider.ID,
+			ExpiresAt:  expiresAt,
+		})
+		assert.NilError(t, err)
+	}
+	createKey("alice-infra", alice, infraProvider, time.Now().Add(time.Hour))
+	createKey("alice-okta", alice, okta, time.Now().Add(time.Hour))
+	createKey("alice-okta-expired", alice, okta, time.Now().Add(-time.Hour))
+	createKey("bob-okta", bob, okta, time.Now().Add(time.Hour))
+
+	type testCase struct {
+		name     string
+		query    string
+		expected []string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req := httptest.NewRequest(http.MethodGet, "/api/access-keys?"+tc.query, nil)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		keys := api.ListResponse[api.AccessKey]{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &keys))
+
+		var names []string
+		for _, key := range keys.Items {
+			names = append(names, key.Name)
+		}
+		assert.DeepEqual(t, names, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "by user",
+			query:    "user_id=" + alice.ID.String(),
+			expected: []string{"alice-infra", "alice-okta"},
+		},
+		{
+			name:     "by provider",
+			query:    "provider_id=" + okta.ID.String(),
+			expected: []string{"alice-okta", "bob-okta"},
+		},
+		{
+			name:     "by user and provider",
+			query:    "user_id=" + alice.ID.String() + "&provider_id=" + okta.ID.String(),
+			expected: []string{"alice-okta"},
+		},
+		{
+			name:     "by user and provider, show expired",
+			query:    "user_id=" + alice.ID.String() + "&provider_id=" + okta.ID.String() + "&show_expired=true",
+			expected: []string{"alice-okta", "alice-okta-expired"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestAPI_DeleteAccessKeys(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	user := &models.Identity{Name: "ci@example.com"}
+	err := data.CreateIdentity(srv.DB(), user)
+	assert.NilError(t, err)
+
+	provider := data.InfraProvider(srv.DB())
+	for _, name := range []string{"ci-bot-1", "ci-bot-2", "deploy-1"} {
+		_, err := data.CreateAccessKey(srv.DB(), &models.AccessKey{
+			Name:       name,
+			IssuedFor:  user.ID,
+			ProviderID: provider.ID,
+		})
+		assert.NilError(t, err)
+	}
+
+	run := func(t *testing.T, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/access-keys"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("missing prefix", func(t *testing.T) {
+		resp := run(t, "")
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		resp := run(t, "?namePrefix=ci-bot-&dryRun=true")
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		respBody := &api.DeleteAccessKeysResponse{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+		assert.Assert(t, respBody.DryRun)
+
+		var names []string
+		for _, key := range respBody.Items {
+			names = append(names, key.Name)
+		}
+		sort.Strings(names)
+		assert.DeepEqual(t, names, []string{"ci-bot-1", "ci-bot-2"})
+
+		keys, err := data.ListAccessKeys(srv.DB(), data.ListAccessKeyOptions{ByIssuedForID: user.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(keys), 3)
+	})
+
+	t.Run("by name prefix", func(t *testing.T) {
+		resp := run(t, "?namePrefix=ci-bot-")
+		assert.Equal(t, resp.Code, http.StatusNoContent, resp.Body.String())
+		assert.Equal(t, resp.Body.Len(), 0)
+
+		keys, err := data.ListAccessKeys(srv.DB(), data.ListAccessKeyOptions{ByIssuedForID: user.ID})
+		assert.NilError(t, err)
+
+		var names []string
+		for _, key := range keys {
+			names = append(names, key.Name)
+		}
+		assert.DeepEqual(t, names, []string{"deploy-1"})
+	})
+}
+
+func TestAPI_CreateAccessKey_Webhook(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.Check(t, err)
+		requests <- req
+		bodies <- body
+	}))
+	t.Cleanup(receiver.Close)
+
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.Webhook = webhook.Options{URL: receiver.URL, Secret: "the-secret"}
+	})
+	routes := srv.GenerateRoutes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go srv.webhooks.Run(ctx)
+
+	user := createUser(t, srv, routes, "usera@example.com")
+
+	body := api.CreateAccessKeyRequest{
+		UserID:            user.ID,
+		Name:              "the-key",
+		TTL:               api.Duration(time.Minute),
+		ExtensionDeadline: api.Duration(time.Minute),
+	}
+	// nolint:noctx
+	req, err := http.NewRequest(http.MethodPost, "/api/access-keys", jsonBody(t, body))
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+	req.Header.Set("Infra-Version", apiVersionLatest)
+
+	resp := httptest.NewRecorder()
+	routes.ServeHTTP(resp, req)
+	assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+	respBody := &api.CreateAccessKeyResponse{}
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+
+	select {
+	case hookReq := <-requests:
+		hookBody := <-bodies
+		assert.Equal(t, hookReq.Header.Get(webhook.SignatureHeader), webhook.Sign("the-secret", hookBody))
+
+		var event webhook.Event
+		assert.NilError(t, json.Unmarshal(hookBody, &event))
+		assert.Equal(t, event.Action, models.AuditActionCreateAccessKey)
+		assert.Equal(t, event.TargetID, respBody.ID)
+		assert.Equal(t, event.TargetName, "the-key")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the webhook request")
+	}
+}
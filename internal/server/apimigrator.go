@@ -59,7 +59,7 @@ func addRequestRewrite[oldReq any, newReq any](api *API, method, path, version s
 		version: version,
 		index:   len(api.migrations),
 		requestRewrite: func(c *gin.Context) {
-			if !rewriteRequired(c, migrationVersion) {
+			if !api.rewriteRequired(c, migrationVersion) {
 				c.Next()
 				return
 			}
@@ -81,20 +81,94 @@ func addRequestRewrite[oldReq any, newReq any](api *API, method, path, version s
 	})
 }
 
-func requestVersion(req *http.Request) (*semver.Version, error) {
+// requestVersion returns the API version from the Infra-Version header of req.
+// A missing or empty header uses Options.DefaultAPIVersion. When there is no
+// default the header is required. The header may be a range with a wildcard
+// minor or patch number, like 0.12.x, which resolves to the newest known
+// version in the range.
+func (a *API) requestVersion(req *http.Request) (*semver.Version, error) {
 	headerVer := req.Header.Get("Infra-Version")
+	if headerVer == "" {
+		headerVer = a.defaultVersion()
+	}
 	if headerVer == "" {
 		return nil, fmt.Errorf("%w: Infra-Version header is required. The current version is %s", internal.ErrBadRequest, internal.FullVersion())
 	}
 	reqVer, err := semver.NewVersion(headerVer)
-	if err != nil {
-		return nil, fmt.Errorf("%w: invalid Infra-Version header: %v. Current version is %s", internal.ErrBadRequest, err, internal.FullVersion())
+	if err == nil {
+		return reqVer, nil
+	}
+	if lower, upper, ok := parseVersionRange(headerVer); ok {
+		return a.newestVersionInRange(lower, upper), nil
+	}
+	return nil, fmt.Errorf("%w: invalid Infra-Version header: %v. Current version is %s", internal.ErrBadRequest, err, internal.FullVersion())
+}
+
+// parseVersionRange parses a version with a wildcard minor or patch number,
+// like 0.x or 0.12.x, and returns the first version in the range, and the
+// first version after the range.
+func parseVersionRange(value string) (lower, upper *semver.Version, ok bool) {
+	isWildcard := func(s string) bool {
+		return s == "x" || s == "X" || s == "*"
+	}
+
+	parts := strings.Split(value, ".")
+	switch {
+	case len(parts) == 2 && isWildcard(parts[1]),
+		len(parts) == 3 && isWildcard(parts[1]) && isWildcard(parts[2]):
+		v, err := semver.NewVersion(parts[0] + ".0.0")
+		if err != nil {
+			return nil, nil, false
+		}
+		next := v.IncMajor()
+		return v, &next, true
+	case len(parts) == 3 && isWildcard(parts[2]):
+		v, err := semver.NewVersion(parts[0] + "." + parts[1] + ".0")
+		if err != nil {
+			return nil, nil, false
+		}
+		next := v.IncMinor()
+		return v, &next, true
+	}
+	return nil, nil, false
+}
+
+// newestVersionInRange returns the newest known version that is at least lower
+// and less than upper. The known versions are the versions of the API
+// migrations, and the version of the server. When no known version is in the
+// range, every version in the range has the same migrations, so lower is
+// returned. A range that is newer than all of the migrations uses the latest
+// handlers.
+func (a *API) newestVersionInRange(lower, upper *semver.Version) *semver.Version {
+	known := []string{internal.FullVersion()}
+	for _, migration := range a.migrations {
+		known = append(known, migration.version)
+	}
+
+	newest := lower
+	for _, value := range known {
+		v, err := semver.NewVersion(value)
+		if err != nil {
+			continue
+		}
+		if v.LessThan(upper) && v.GreaterThan(newest) {
+			newest = v
+		}
+	}
+	return newest
+}
+
+// defaultVersion returns the API version used for requests without an
+// Infra-Version header, or an empty string if the header is required.
+func (a *API) defaultVersion() string {
+	if a.server == nil {
+		return ""
 	}
-	return reqVer, nil
+	return a.server.options.DefaultAPIVersion
 }
 
-func rewriteRequired(c *gin.Context, migrationVersion *semver.Version) bool {
-	reqVer, err := requestVersion(c.Request)
+func (a *API) rewriteRequired(c *gin.Context, migrationVersion *semver.Version) bool {
+	reqVer, err := a.requestVersion(c.Request)
 	if err != nil {
 		// should be impossible, header was already validated earlier
 		return false
@@ -174,7 +248,7 @@ func addResponseRewrite[newResp any, oldResp any](a *API, method, path, version
 		version: version,
 		index:   len(a.migrations),
 		responseRewrite: func(c *gin.Context) {
-			if !rewriteRequired(c, migrationVersion) {
+			if !a.rewriteRequired(c, migrationVersion) {
 				c.Next()
 				return
 			}
@@ -211,13 +285,13 @@ func addResponseRewrite[newResp any, oldResp any](a *API, method, path, version
 	})
 }
 
-func (m *apiMigration) RedirectHandler() gin.HandlerFunc {
+func (m *apiMigration) RedirectHandler(a *API) gin.HandlerFunc {
 	migrationVersion, err := semver.NewVersion(m.version)
 	if err != nil {
 		panic(err) // dev mistake
 	}
 	return func(c *gin.Context) {
-		if !rewriteRequired(c, migrationVersion) {
+		if !a.rewriteRequired(c, migrationVersion) {
 			// requesting a path that doesn't exist in the version you asked for
 			sendAPIError(c, internal.ErrNotFound)
 			return
@@ -262,7 +336,7 @@ func bindRoute(a *API, r *gin.RouterGroup, routeID routeIdentifier, handler gin.
 		if len(migration.redirect) > 0 {
 			// Redirects end up duplicating/splitting into a new path without destroying the old one
 			if route, ok := routes[migration.redirect]; ok {
-				route = append([]gin.HandlerFunc{migration.RedirectHandler()}, route...)
+				route = append([]gin.HandlerFunc{migration.RedirectHandler(a)}, route...)
 				if migration.redirectHandler != nil {
 					// if the migration has a custom redirect handler, prepend it.
 					route = append([]gin.HandlerFunc{migration.redirectHandler}, route...)
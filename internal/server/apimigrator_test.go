@@ -6,10 +6,12 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/gin-gonic/gin"
 	"gotest.tools/v3/assert"
 
 	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/uid"
 )
 
@@ -467,3 +469,85 @@ func TestRedirectWithARequestRewriteToQueryParameter(t *testing.T) {
 	})
 
 }
+
+func TestRewriteRequired(t *testing.T) {
+	migrationVersion := semver.MustParse("0.12.2")
+
+	type testCase struct {
+		name           string
+		header         string
+		omitHeader     bool
+		defaultVersion string
+		expected       bool
+		expectedError  string
+	}
+
+	testCases := []testCase{
+		{name: "exact version", header: "0.12.2", expected: true},
+		{name: "older version", header: "0.12.0", expected: true},
+		{name: "older minor version", header: "0.11.5", expected: true},
+		{name: "newer version", header: "0.12.3", expected: false},
+		{name: "newer than all migrations", header: "99.0.0", expected: false},
+		{name: "missing version", omitHeader: true, expectedError: "Infra-Version header is required"},
+		{name: "empty version", header: "", expectedError: "Infra-Version header is required"},
+		{name: "malformed version", header: "not-a-version", expectedError: "invalid Infra-Version header"},
+		{name: "range with the migration version", header: "0.12.x", expected: true},
+		{name: "range older than the migration", header: "0.11.x", expected: true},
+		{name: "range newer than the migration", header: "0.13.x", expected: false},
+		{name: "range with the server version", header: "0.x", expected: false},
+		{name: "range newer than all migrations", header: "99.x", expected: false},
+		{name: "range with wildcard patch", header: "0.12.*", expected: true},
+		{name: "malformed range", header: "0.x.1", expectedError: "invalid Infra-Version header"},
+		{name: "malformed range minor", header: "0.twelve.x", expectedError: "invalid Infra-Version header"},
+		{
+			name:           "missing version with default",
+			omitHeader:     true,
+			defaultVersion: "0.12.0",
+			expected:       true,
+		},
+		{
+			name:           "empty version with default",
+			header:         "",
+			defaultVersion: "0.13.0",
+			expected:       false,
+		},
+		{
+			name:           "malformed version with default",
+			header:         "not-a-version",
+			defaultVersion: "0.12.0",
+			expectedError:  "invalid Infra-Version header",
+		},
+		{
+			name:           "header overrides default",
+			header:         "0.13.0",
+			defaultVersion: "0.12.0",
+			expected:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &API{
+				server:     &Server{options: Options{DefaultAPIVersion: tc.defaultVersion}},
+				migrations: []apiMigration{{version: migrationVersion.String()}},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if !tc.omitHeader {
+				req.Header.Set("Infra-Version", tc.header)
+			}
+
+			_, err := a.requestVersion(req)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.ErrorIs(t, err, internal.ErrBadRequest)
+				return
+			}
+			assert.NilError(t, err)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+			assert.Equal(t, a.rewriteRequired(c, migrationVersion), tc.expected)
+		})
+	}
+}
@@ -26,6 +26,9 @@ type LoginMethod interface {
 
 type AuthScope struct {
 	PasswordResetOnly bool
+	// IDTokenLogin indicates that the login used an ID token issued by the
+	// provider, so there are no provider tokens to refresh the session with.
+	IDTokenLogin bool
 }
 
 type LoginResult struct {
@@ -64,11 +67,18 @@ func Login(
 	if authenticated.AuthScope.PasswordResetOnly {
 		accessKey.Scopes = append(accessKey.Scopes, models.ScopePasswordReset)
 	}
+	if authenticated.AuthScope.IDTokenLogin {
+		accessKey.Scopes = append(accessKey.Scopes, models.ScopeIDTokenLogin)
+	}
 
 	bearer, err := data.CreateAccessKey(db, accessKey)
 	if err != nil {
 		return LoginResult{}, fmt.Errorf("failed to create access key after login: %w", err)
 	}
+	err = data.CreateAccessKeyAuditEvents(db, models.AuditActionCreateAccessKey, authenticated.Identity.ID, *accessKey)
+	if err != nil {
+		return LoginResult{}, err
+	}
 
 	authenticated.Identity.LastSeenAt = time.Now().UTC()
 	if err := data.SaveIdentity(db, authenticated.Identity); err != nil {
@@ -67,5 +67,11 @@ func TestLogin(t *testing.T) {
 		assert.Equal(t, result.AccessKey.ExpiresAt, exp)
 		assert.Equal(t, result.AccessKey.Extension, ext)
 		assert.Equal(t, result.User.ID, user.ID)
+
+		events, err := data.ListAuditEvents(db, data.ListAuditEventsOptions{ByTargetID: result.AccessKey.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(events), 1)
+		assert.Equal(t, events[0].Action, models.AuditActionCreateAccessKey)
+		assert.Equal(t, events[0].ActorID, user.ID)
 	})
 }
@@ -0,0 +1,93 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/server/providers"
+	"github.com/infrahq/infra/uid"
+)
+
+type deviceAuthn struct {
+	ProviderID         uid.ID
+	DeviceCode         string
+	OIDCProviderClient providers.OIDCClient
+}
+
+// NewDeviceAuthentication returns a LoginMethod which authenticates a user
+// with the device authorization grant. The device code must be one that was
+// returned when the device authorization was started with the provider.
+func NewDeviceAuthentication(providerID uid.ID, deviceCode string, oidcProviderClient providers.OIDCClient) LoginMethod {
+	return &deviceAuthn{
+		ProviderID:         providerID,
+		DeviceCode:         deviceCode,
+		OIDCProviderClient: oidcProviderClient,
+	}
+}
+
+func (a *deviceAuthn) Authenticate(ctx context.Context, db data.GormTxn, requestedExpiry time.Time) (AuthenticatedIdentity, error) {
+	provider, err := data.GetProvider(db, data.ByID(a.ProviderID))
+	if err != nil {
+		return AuthenticatedIdentity{}, err
+	}
+
+	// the provider is polled once, so that the transaction is not held open
+	// while waiting for the user. The client logs in again while the
+	// authorization is pending.
+	auth := &providers.DeviceAuthorization{DeviceCode: a.DeviceCode}
+	accessToken, refreshToken, expiry, email, groups, err := a.OIDCProviderClient.PollDeviceAuthorization(ctx, auth)
+	if err != nil {
+		switch {
+		case errors.Is(err, providers.ErrDeviceAuthorizationPending),
+			errors.Is(err, providers.ErrDeviceAuthorizationSlowDown):
+			return AuthenticatedIdentity{}, err
+		case errors.Is(err, context.DeadlineExceeded):
+			return AuthenticatedIdentity{}, fmt.Errorf("%w: %s", internal.ErrBadGateway, err.Error())
+		}
+		return AuthenticatedIdentity{}, fmt.Errorf("poll device authorization: %w", err)
+	}
+
+	identity, err := getOrCreateIdentity(db, email)
+	if err != nil {
+		return AuthenticatedIdentity{}, err
+	}
+
+	providerUser, err := data.CreateProviderUser(db, provider, identity)
+	if err != nil {
+		return AuthenticatedIdentity{}, fmt.Errorf("add user for provider login: %w", err)
+	}
+
+	providerUser.AccessToken = models.EncryptedAtRest(accessToken)
+	providerUser.RefreshToken = models.EncryptedAtRest(refreshToken)
+	providerUser.ExpiresAt = expiry
+	err = data.UpdateProviderUser(db, providerUser)
+	if err != nil {
+		return AuthenticatedIdentity{}, fmt.Errorf("UpdateProviderUser: %w", err)
+	}
+
+	if groups != nil {
+		if err := data.AssignIdentityToGroups(db, identity, provider, groups); err != nil {
+			return AuthenticatedIdentity{}, fmt.Errorf("assign identity to groups: %w", err)
+		}
+	} else {
+		err = data.SyncProviderUser(ctx, db, identity, provider, a.OIDCProviderClient)
+		if err != nil {
+			return AuthenticatedIdentity{}, fmt.Errorf("sync user on login: %w", err)
+		}
+	}
+
+	return AuthenticatedIdentity{
+		Identity:      identity,
+		Provider:      provider,
+		SessionExpiry: requestedExpiry,
+	}, nil
+}
+
+func (a *deviceAuthn) Name() string {
+	return "device"
+}
@@ -0,0 +1,67 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/server/providers"
+)
+
+func TestDeviceAuthenticate(t *testing.T) {
+	db := setupDB(t)
+
+	provider := models.Provider{Name: "device", Kind: models.ProviderKindOIDC}
+	err := data.CreateProvider(db, &provider)
+	assert.NilError(t, err)
+
+	expiry := time.Now().Add(time.Minute)
+
+	t.Run("authorization pending", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{DevicePollErr: providers.ErrDeviceAuthorizationPending}
+		_, err := NewDeviceAuthentication(provider.ID, "device-code", oidc).Authenticate(context.Background(), db, expiry)
+		assert.ErrorIs(t, err, providers.ErrDeviceAuthorizationPending)
+	})
+
+	t.Run("slow down", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{DevicePollErr: providers.ErrDeviceAuthorizationSlowDown}
+		_, err := NewDeviceAuthentication(provider.ID, "device-code", oidc).Authenticate(context.Background(), db, expiry)
+		assert.ErrorIs(t, err, providers.ErrDeviceAuthorizationSlowDown)
+	})
+
+	t.Run("authorization denied", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{DevicePollErr: providers.ErrDeviceAuthorizationDenied}
+		_, err := NewDeviceAuthentication(provider.ID, "device-code", oidc).Authenticate(context.Background(), db, expiry)
+		assert.ErrorIs(t, err, providers.ErrDeviceAuthorizationDenied)
+	})
+
+	t.Run("provider request timed out", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{DevicePollErr: context.DeadlineExceeded}
+		_, err := NewDeviceAuthentication(provider.ID, "device-code", oidc).Authenticate(context.Background(), db, expiry)
+		assert.Assert(t, errors.Is(err, internal.ErrBadGateway))
+	})
+
+	t.Run("successful authentication", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{
+			UserEmailResp:     "device@example.com",
+			IDTokenGroupsResp: []string{"operators"},
+		}
+		authnIdentity, err := NewDeviceAuthentication(provider.ID, "device-code", oidc).Authenticate(context.Background(), db, expiry)
+		assert.NilError(t, err)
+
+		assert.Equal(t, authnIdentity.Identity.Name, "device@example.com")
+		assert.Equal(t, authnIdentity.Provider.ID, provider.ID)
+		assert.Equal(t, authnIdentity.SessionExpiry, expiry)
+
+		providerUser, err := data.GetProviderUser(db, provider.ID, authnIdentity.Identity.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, string(providerUser.AccessToken), "acc")
+		assert.Equal(t, string(providerUser.RefreshToken), "ref")
+	})
+}
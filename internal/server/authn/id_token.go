@@ -0,0 +1,74 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/providers"
+	"github.com/infrahq/infra/uid"
+)
+
+type idTokenAuthn struct {
+	ProviderID         uid.ID
+	IDToken            string
+	OIDCProviderClient providers.OIDCClient
+}
+
+// NewIDTokenAuthentication returns a LoginMethod which authenticates a user
+// with an ID token issued by the provider, instead of exchanging an auth code.
+// It is used by clients that can not open a browser, like CI jobs which
+// receive an ID token from their own OIDC provider.
+func NewIDTokenAuthentication(providerID uid.ID, idToken string, oidcProviderClient providers.OIDCClient) LoginMethod {
+	return &idTokenAuthn{
+		ProviderID:         providerID,
+		IDToken:            idToken,
+		OIDCProviderClient: oidcProviderClient,
+	}
+}
+
+func (a *idTokenAuthn) Authenticate(ctx context.Context, db data.GormTxn, requestedExpiry time.Time) (AuthenticatedIdentity, error) {
+	provider, err := data.GetProvider(db, data.ByID(a.ProviderID))
+	if err != nil {
+		return AuthenticatedIdentity{}, err
+	}
+
+	email, groups, err := a.OIDCProviderClient.VerifyIDToken(ctx, a.IDToken)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return AuthenticatedIdentity{}, fmt.Errorf("%w: %s", internal.ErrBadGateway, err.Error())
+		}
+		return AuthenticatedIdentity{}, fmt.Errorf("verify id token: %w", err)
+	}
+
+	identity, err := getOrCreateIdentity(db, email)
+	if err != nil {
+		return AuthenticatedIdentity{}, err
+	}
+
+	if _, err := data.CreateProviderUser(db, provider, identity); err != nil {
+		return AuthenticatedIdentity{}, fmt.Errorf("add user for provider login: %w", err)
+	}
+
+	// there are no provider tokens to request the user info with, so the
+	// groups in the ID token are the only groups available
+	if groups != nil {
+		if err := data.AssignIdentityToGroups(db, identity, provider, groups); err != nil {
+			return AuthenticatedIdentity{}, fmt.Errorf("assign identity to groups: %w", err)
+		}
+	}
+
+	return AuthenticatedIdentity{
+		Identity:      identity,
+		Provider:      provider,
+		SessionExpiry: requestedExpiry,
+		AuthScope:     AuthScope{IDTokenLogin: true},
+	}, nil
+}
+
+func (a *idTokenAuthn) Name() string {
+	return "idToken"
+}
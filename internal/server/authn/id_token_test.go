@@ -0,0 +1,63 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+func TestIDTokenAuthenticate(t *testing.T) {
+	db := setupDB(t)
+
+	provider := models.Provider{Name: "ci", Kind: models.ProviderKindOIDC}
+	err := data.CreateProvider(db, &provider)
+	assert.NilError(t, err)
+
+	expiry := time.Now().Add(time.Minute)
+
+	t.Run("invalid provider", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{UserEmailResp: "ci@example.com"}
+		_, err := NewIDTokenAuthentication(uid.New(), "token", oidc).Authenticate(context.Background(), db, expiry)
+		assert.ErrorIs(t, err, internal.ErrNotFound)
+	})
+
+	t.Run("invalid ID token", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{IDTokenErr: errors.New("oidc: token is expired")}
+		_, err := NewIDTokenAuthentication(provider.ID, "token", oidc).Authenticate(context.Background(), db, expiry)
+		assert.ErrorContains(t, err, "verify id token: oidc: token is expired")
+
+		_, err = data.GetIdentity(db, data.ByName("ci@example.com"))
+		assert.ErrorIs(t, err, internal.ErrNotFound)
+	})
+
+	t.Run("successful authentication", func(t *testing.T) {
+		oidc := &mockOIDCImplementation{
+			UserEmailResp:     "ci@example.com",
+			IDTokenGroupsResp: []string{"deployers"},
+		}
+		authnIdentity, err := NewIDTokenAuthentication(provider.ID, "token", oidc).Authenticate(context.Background(), db, expiry)
+		assert.NilError(t, err)
+
+		assert.Equal(t, authnIdentity.Identity.Name, "ci@example.com")
+		assert.Equal(t, authnIdentity.Provider.ID, provider.ID)
+		assert.Equal(t, authnIdentity.SessionExpiry, expiry)
+		assert.Assert(t, authnIdentity.AuthScope.IDTokenLogin)
+
+		var groups []string
+		for _, g := range authnIdentity.Identity.Groups {
+			groups = append(groups, g.Name)
+		}
+		assert.DeepEqual(t, groups, []string{"deployers"})
+
+		_, err = data.GetProviderUser(db, provider.ID, authnIdentity.Identity.ID)
+		assert.NilError(t, err)
+	})
+}
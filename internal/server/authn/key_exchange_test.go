@@ -36,7 +36,7 @@ func TestKeyExchangeAuthentication(t *testing.T) {
 
 				return NewKeyExchangeAuthentication(invalidKey), time.Now().Add(5 * time.Minute)
 			},
-			expectedErr: "could not get access key from database",
+			expectedErr: data.ErrAccessKeyInvalid.Error(),
 		},
 		"ExpiredAccessKeyCannotBeExchanged": {
 			setup: func(t *testing.T, db data.GormTxn) (LoginMethod, time.Time) {
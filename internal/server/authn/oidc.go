@@ -36,26 +36,21 @@ func (a *oidcAuthn) Authenticate(ctx context.Context, db data.GormTxn, requested
 	}
 
 	// exchange code for tokens from identity provider (these tokens are for the IDP, not Infra)
-	accessToken, refreshToken, expiry, email, err := a.OIDCProviderClient.ExchangeAuthCodeForProviderTokens(ctx, a.Code)
+	accessToken, refreshToken, expiry, email, groups, err := a.OIDCProviderClient.ExchangeAuthCodeForProviderTokens(ctx, a.Code, a.RedirectURL)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return AuthenticatedIdentity{}, fmt.Errorf("%w: %s", internal.ErrBadGateway, err.Error())
 		}
+		if errors.Is(err, providers.ErrRedirectURLNotAllowed) {
+			return AuthenticatedIdentity{}, fmt.Errorf("%w: %s", internal.ErrBadRequest, err.Error())
+		}
 
 		return AuthenticatedIdentity{}, fmt.Errorf("exhange code for tokens: %w", err)
 	}
 
-	identity, err := data.GetIdentity(db, data.Preload("Groups"), data.ByName(email))
+	identity, err := getOrCreateIdentity(db, email)
 	if err != nil {
-		if !errors.Is(err, internal.ErrNotFound) {
-			return AuthenticatedIdentity{}, fmt.Errorf("get user: %w", err)
-		}
-
-		identity = &models.Identity{Name: email}
-
-		if err := data.CreateIdentity(db, identity); err != nil {
-			return AuthenticatedIdentity{}, fmt.Errorf("create user: %w", err)
-		}
+		return AuthenticatedIdentity{}, err
 	}
 
 	providerUser, err := data.CreateProviderUser(db, provider, identity)
@@ -72,10 +67,18 @@ func (a *oidcAuthn) Authenticate(ctx context.Context, db data.GormTxn, requested
 		return AuthenticatedIdentity{}, fmt.Errorf("UpdateProviderUser: %w", err)
 	}
 
-	// update users attributes (such as groups) from the IDP
-	err = data.SyncProviderUser(ctx, db, identity, provider, a.OIDCProviderClient)
-	if err != nil {
-		return AuthenticatedIdentity{}, fmt.Errorf("sync user on login: %w", err)
+	if groups != nil {
+		// the groups were included in the ID token, there is no need to
+		// request them from the IDP again
+		if err := data.AssignIdentityToGroups(db, identity, provider, groups); err != nil {
+			return AuthenticatedIdentity{}, fmt.Errorf("assign identity to groups: %w", err)
+		}
+	} else {
+		// update users attributes (such as groups) from the IDP
+		err = data.SyncProviderUser(ctx, db, identity, provider, a.OIDCProviderClient)
+		if err != nil {
+			return AuthenticatedIdentity{}, fmt.Errorf("sync user on login: %w", err)
+		}
 	}
 
 	return AuthenticatedIdentity{
@@ -88,3 +91,21 @@ func (a *oidcAuthn) Authenticate(ctx context.Context, db data.GormTxn, requested
 func (a *oidcAuthn) Name() string {
 	return "oidc"
 }
+
+// getOrCreateIdentity returns the user with the email address of a provider
+// login, and creates the user when it does not exist yet.
+func getOrCreateIdentity(db data.GormTxn, email string) (*models.Identity, error) {
+	identity, err := data.GetIdentity(db, data.Preload("Groups"), data.ByName(email))
+	if err == nil {
+		return identity, nil
+	}
+	if !errors.Is(err, internal.ErrNotFound) {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	identity = &models.Identity{Name: email}
+	if err := data.CreateIdentity(db, identity); err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	return identity, nil
+}
@@ -18,8 +18,11 @@ import (
 
 // mockOIDC is a mock oidc identity provider
 type mockOIDCImplementation struct {
-	UserEmailResp  string
-	UserGroupsResp []string
+	UserEmailResp     string
+	UserGroupsResp    []string
+	IDTokenGroupsResp []string // when set the user info groups are not used
+	IDTokenErr        error    // when set it is returned from VerifyIDToken
+	DevicePollErr     error    // when set it is returned from PollDeviceAuthorization
 }
 
 func (m *mockOIDCImplementation) Validate(_ context.Context) error {
@@ -30,13 +33,35 @@ func (m *mockOIDCImplementation) AuthServerInfo(_ context.Context) (*providers.A
 	return &providers.AuthServerInfo{AuthURL: "example.com/v1/auth", ScopesSupported: []string{"openid", "email"}}, nil
 }
 
-func (m *mockOIDCImplementation) ExchangeAuthCodeForProviderTokens(_ context.Context, _ string) (acc, ref string, exp time.Time, email string, err error) {
-	return "acc", "ref", exp, m.UserEmailResp, nil
+func (m *mockOIDCImplementation) Discover(_ context.Context) (*providers.DiscoveryResult, error) {
+	return &providers.DiscoveryResult{AuthURL: "example.com/v1/auth"}, nil
 }
 
-func (m *mockOIDCImplementation) RefreshAccessToken(_ context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error) {
+func (m *mockOIDCImplementation) ExchangeAuthCodeForProviderTokens(_ context.Context, _, _ string) (acc, ref string, exp time.Time, email string, groups []string, err error) {
+	return "acc", "ref", exp, m.UserEmailResp, m.IDTokenGroupsResp, nil
+}
+
+func (m *mockOIDCImplementation) VerifyIDToken(_ context.Context, _ string) (email string, groups []string, err error) {
+	if m.IDTokenErr != nil {
+		return "", nil, m.IDTokenErr
+	}
+	return m.UserEmailResp, m.IDTokenGroupsResp, nil
+}
+
+func (m *mockOIDCImplementation) StartDeviceAuthorization(_ context.Context) (*providers.DeviceAuthorization, error) {
+	return &providers.DeviceAuthorization{DeviceCode: "device-code", UserCode: "ABCD-EFGH"}, nil
+}
+
+func (m *mockOIDCImplementation) PollDeviceAuthorization(_ context.Context, _ *providers.DeviceAuthorization) (acc, ref string, exp time.Time, email string, groups []string, err error) {
+	if m.DevicePollErr != nil {
+		return "", "", exp, "", nil, m.DevicePollErr
+	}
+	return "acc", "ref", exp, m.UserEmailResp, m.IDTokenGroupsResp, nil
+}
+
+func (m *mockOIDCImplementation) RefreshAccessToken(_ context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error) {
 	// never update
-	return string(providerUser.AccessToken), &providerUser.ExpiresAt, nil
+	return string(providerUser.AccessToken), string(providerUser.RefreshToken), &providerUser.ExpiresAt, nil
 }
 
 func (m *mockOIDCImplementation) GetUserInfo(_ context.Context, providerUser *models.ProviderUser) (*providers.UserInfoClaims, error) {
@@ -81,6 +106,23 @@ func TestOIDCAuthenticate(t *testing.T) {
 
 		assert.Equal(t, authnIdentity.Provider.ID, mocktaProvider.ID)
 	})
+
+	t.Run("groups from the ID token are preferred", func(t *testing.T) {
+		idTokenOIDC := &mockOIDCImplementation{
+			UserEmailResp:     "idtoken@example.com",
+			UserGroupsResp:    []string{"userinfo-group"},
+			IDTokenGroupsResp: []string{"idtoken-group"},
+		}
+		oidcAuthn := NewOIDCAuthentication(mocktaProvider.ID, "localhost:8031", "1234", idTokenOIDC)
+		authnIdentity, err := oidcAuthn.Authenticate(context.Background(), db, time.Now().Add(1*time.Minute))
+		assert.NilError(t, err)
+
+		var groups []string
+		for _, g := range authnIdentity.Identity.Groups {
+			groups = append(groups, g.Name)
+		}
+		assert.DeepEqual(t, groups, []string{"idtoken-group"})
+	})
 }
 
 func TestExchangeAuthCodeForProviderTokens(t *testing.T) {
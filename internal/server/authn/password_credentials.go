@@ -2,14 +2,57 @@ package authn
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/uid"
 )
 
+// InvalidPasswordError is returned when the password does not match the
+// credential of the identity.
+type InvalidPasswordError struct {
+	IdentityID uid.ID
+	Err        error
+}
+
+func (e InvalidPasswordError) Error() string {
+	return fmt.Sprintf("could not verify password: %v", e.Err)
+}
+
+func (e InvalidPasswordError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownUserError is returned when there is no identity with a password
+// credential for the username.
+type UnknownUserError struct {
+	Username string
+	Err      error
+}
+
+func (e UnknownUserError) Error() string {
+	return fmt.Sprintf("no password credential for username: %v", e.Err)
+}
+
+func (e UnknownUserError) Unwrap() error {
+	return e.Err
+}
+
+// LoginLockedError is returned when login with the credential of an identity
+// is locked, because of too many failed login attempts.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, login is locked until %v", e.Until.Format(time.RFC3339))
+}
+
 // passwordCredentialAuthn allows presenting username/password credentials in exchange for an access key
 type passwordCredentialAuthn struct {
 	Username string
@@ -25,21 +68,37 @@ func NewPasswordCredentialAuthentication(username, password string) LoginMethod
 
 func (a *passwordCredentialAuthn) Authenticate(_ context.Context, db data.GormTxn, requestedExpiry time.Time) (AuthenticatedIdentity, error) {
 	identity, err := data.GetIdentity(db, data.ByName(a.Username))
-	if err != nil {
+	switch {
+	case errors.Is(err, internal.ErrNotFound):
+		return AuthenticatedIdentity{}, UnknownUserError{Username: a.Username, Err: err}
+	case err != nil:
 		return AuthenticatedIdentity{}, fmt.Errorf("could not get identity for username: %w", err)
 	}
 
 	// Infra users can have only one username/password combo, look it up
 	userCredential, err := data.GetCredential(db, data.ByIdentityID(identity.ID))
-	if err != nil {
+	switch {
+	case errors.Is(err, internal.ErrNotFound):
+		return AuthenticatedIdentity{}, UnknownUserError{Username: a.Username, Err: err}
+	case err != nil:
 		return AuthenticatedIdentity{}, fmt.Errorf("validate creds get user: %w", err)
 	}
 
+	if time.Now().Before(userCredential.LockedUntil) {
+		return AuthenticatedIdentity{}, LoginLockedError{Until: userCredential.LockedUntil}
+	}
+
 	// compare the stored hash of the user's password and the hash of the presented password
 	err = bcrypt.CompareHashAndPassword(userCredential.PasswordHash, []byte(a.Password))
 	if err != nil {
 		// this probably means the password was wrong
-		return AuthenticatedIdentity{}, fmt.Errorf("could not verify password: %w", err)
+		return AuthenticatedIdentity{}, InvalidPasswordError{IdentityID: identity.ID, Err: err}
+	}
+
+	if userCredential.FailedLoginAttempts > 0 {
+		if err := data.ResetFailedLogins(db, identity.ID); err != nil {
+			return AuthenticatedIdentity{}, fmt.Errorf("reset failed logins: %w", err)
+		}
 	}
 
 	authnIdentity := AuthenticatedIdentity{
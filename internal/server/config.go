@@ -14,6 +14,7 @@ import (
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/access"
 	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
@@ -35,6 +36,9 @@ type Provider struct {
 	PrivateKey       string
 	ClientEmail      string
 	DomainAdminEmail string
+
+	// Domains are the email domains of the users who login with the provider.
+	Domains []string
 }
 
 func (p Provider) ValidationRules() []validate.ValidationRule {
@@ -44,6 +48,7 @@ func (p Provider) ValidationRules() []validate.ValidationRule {
 		validate.Required("url", p.URL),
 		validate.Required("clientID", p.ClientID),
 		validate.Required("clientSecret", p.ClientSecret),
+		api.ValidateEmailDomains(p.Domains),
 	}
 }
 
@@ -66,6 +71,20 @@ func (g Grant) ValidationRules() []validate.ValidationRule {
 	}
 }
 
+// Group is a group of users that is created from the config. Groups are not
+// deleted when they are removed from the config, because an identity provider
+// may also add users to them.
+type Group struct {
+	Name  string
+	Users []string
+}
+
+func (g Group) ValidationRules() []validate.ValidationRule {
+	return []validate.ValidationRule{
+		validate.Required("name", g.Name),
+	}
+}
+
 type User struct {
 	Name      string
 	AccessKey string
@@ -81,8 +100,13 @@ func (u User) ValidationRules() []validate.ValidationRule {
 type Config struct {
 	DefaultOrganizationDomain string
 	Providers                 []Provider
+	Groups                    []Group
 	Grants                    []Grant
 	Users                     []User
+
+	// SkipPrune keeps the providers, users, and grants that were created from
+	// the config when they are removed from it. By default they are deleted.
+	SkipPrune bool
 }
 
 func (c Config) ValidationRules() []validate.ValidationRule {
@@ -606,7 +630,7 @@ func (s Server) loadConfig(config Config) error {
 
 	org := s.db.DefaultOrg
 
-	tx, err := s.db.Begin(context.Background())
+	tx, err := s.db.Begin(context.Background(), nil)
 	if err != nil {
 		return err
 	}
@@ -616,8 +640,11 @@ func (s Server) loadConfig(config Config) error {
 		}
 	}()
 	tx = tx.WithOrgID(org.ID)
+	// the config may change grants and group memberships
+	access.ClearAuthorizationCache(tx)
 
 	if config.DefaultOrganizationDomain != org.Domain {
+		s.logConfigChange("update default organization domain to %q", config.DefaultOrganizationDomain)
 		org.Domain = config.DefaultOrganizationDomain
 		if err := data.UpdateOrganization(tx, org); err != nil {
 			return fmt.Errorf("update default org domain: %w", err)
@@ -640,10 +667,19 @@ func (s Server) loadConfig(config Config) error {
 		Resource: "infra",
 	})
 
-	if err := s.loadProviders(tx, config.Providers); err != nil {
+	prune := !config.SkipPrune
+
+	if err := s.loadProviders(tx, config.Providers, prune); err != nil {
 		return fmt.Errorf("load providers: %w", err)
 	}
 
+	// extract users from groups and add them to users
+	for _, g := range config.Groups {
+		for _, name := range g.Users {
+			config.Users = append(config.Users, User{Name: name})
+		}
+	}
+
 	// extract users from grants and add them to users
 	for _, g := range config.Grants {
 		switch {
@@ -655,18 +691,37 @@ func (s Server) loadConfig(config Config) error {
 		}
 	}
 
-	if err := s.loadUsers(tx, config.Users); err != nil {
+	if err := s.loadUsers(tx, config.Users, prune); err != nil {
 		return fmt.Errorf("load users: %w", err)
 	}
 
-	if err := s.loadGrants(tx, config.Grants); err != nil {
+	if err := s.loadGroups(tx, config.Groups); err != nil {
+		return fmt.Errorf("load groups: %w", err)
+	}
+
+	if err := s.loadGrants(tx, config.Grants, prune); err != nil {
 		return fmt.Errorf("load grants: %w", err)
 	}
 
+	if s.options.DryRun {
+		logging.Infof("dry run: the changes from the config were not applied")
+		return nil
+	}
+
 	return tx.Commit()
 }
 
-func (s Server) loadProviders(db data.GormTxn, providers []Provider) error {
+// logConfigChange logs a change that loadConfig makes to the database. In a
+// dry run the transaction is rolled back, so the log is the plan of changes.
+func (s Server) logConfigChange(format string, args ...any) {
+	if s.options.DryRun {
+		logging.Infof("dry run: "+format, args...)
+		return
+	}
+	logging.Infof("config: "+format, args...)
+}
+
+func (s Server) loadProviders(db data.GormTxn, providers []Provider, prune bool) error {
 	keep := []uid.ID{}
 
 	for _, p := range providers {
@@ -678,15 +733,26 @@ func (s Server) loadProviders(db data.GormTxn, providers []Provider) error {
 		keep = append(keep, provider.ID)
 	}
 
+	if !prune {
+		return nil
+	}
+
 	// remove any provider previously defined by config
-	if err := data.DeleteProviders(db, data.NotIDs(keep), data.CreatedBy(models.CreatedBySystem)); err != nil {
+	selectors := []data.SelectorFunc{data.NotIDs(keep), data.CreatedBy(models.CreatedBySystem)}
+	removed, err := data.ListProviders(db, nil, selectors...)
+	if err != nil {
 		return err
 	}
-
-	return nil
+	if len(removed) == 0 {
+		return nil
+	}
+	for _, p := range removed {
+		s.logConfigChange("delete provider %q", p.Name)
+	}
+	return data.DeleteProviders(db, selectors...)
 }
 
-func (Server) loadProvider(db data.GormTxn, input Provider) (*models.Provider, error) {
+func (s Server) loadProvider(db data.GormTxn, input Provider) (*models.Provider, error) {
 	// provider kind is an optional field
 	kind, err := models.ParseProviderKind(input.Kind)
 	if err != nil {
@@ -712,12 +778,14 @@ func (Server) loadProvider(db data.GormTxn, input Provider) (*models.Provider, e
 			PrivateKey:       models.EncryptedAtRest(input.PrivateKey),
 			ClientEmail:      input.ClientEmail,
 			DomainAdminEmail: input.DomainAdminEmail,
+
+			Domains: input.Domains,
 		}
 
 		if provider.Kind != models.ProviderKindInfra {
 			// only call the provider to resolve info if it is not known
 			if input.AuthURL == "" && len(input.Scopes) == 0 {
-				providerClient := providers.NewOIDCClient(*provider, input.ClientSecret, "http://localhost:8301")
+				providerClient := providers.NewOIDCClient(*provider, input.ClientSecret, "http://localhost:8301", s.secrets)
 				authServerInfo, err := providerClient.AuthServerInfo(context.Background())
 				if err != nil {
 					if errors.Is(err, context.DeadlineExceeded) {
@@ -740,6 +808,7 @@ func (Server) loadProvider(db data.GormTxn, input Provider) (*models.Provider, e
 			}
 		}
 
+		s.logConfigChange("create provider %q", input.Name)
 		if err := data.CreateProvider(db, provider); err != nil {
 			return nil, err
 		}
@@ -747,12 +816,23 @@ func (Server) loadProvider(db data.GormTxn, input Provider) (*models.Provider, e
 		return provider, nil
 	}
 
+	changed := provider.URL != input.URL ||
+		provider.ClientID != input.ClientID ||
+		string(provider.ClientSecret) != input.ClientSecret ||
+		provider.Kind != kind ||
+		strings.Join(provider.Domains, ",") != strings.Join(input.Domains, ",")
+	if !changed {
+		return provider, nil
+	}
+
 	// provider already exists, update it
 	provider.URL = input.URL
 	provider.ClientID = input.ClientID
 	provider.ClientSecret = models.EncryptedAtRest(input.ClientSecret)
 	provider.Kind = kind
+	provider.Domains = input.Domains
 
+	s.logConfigChange("update provider %q", input.Name)
 	if err := data.SaveProvider(db, provider); err != nil {
 		return nil, err
 	}
@@ -760,7 +840,7 @@ func (Server) loadProvider(db data.GormTxn, input Provider) (*models.Provider, e
 	return provider, nil
 }
 
-func (s Server) loadGrants(db data.GormTxn, grants []Grant) error {
+func (s Server) loadGrants(db data.GormTxn, grants []Grant, prune bool) error {
 	keep := make([]uid.ID, 0, len(grants))
 
 	for _, g := range grants {
@@ -772,18 +852,32 @@ func (s Server) loadGrants(db data.GormTxn, grants []Grant) error {
 		keep = append(keep, grant.ID)
 	}
 
+	if !prune {
+		return nil
+	}
+
 	// remove any grant previously defined by config
-	if err := data.DeleteGrants(db, data.DeleteGrantsOptions{
-		NotIDs:      keep,
-		ByCreatedBy: models.CreatedBySystem,
-	}); err != nil {
+	existing, err := data.ListGrants(db, data.ListGrantsOptions{})
+	if err != nil {
 		return err
 	}
+	kept := make(map[uid.ID]bool, len(keep))
+	for _, id := range keep {
+		kept[id] = true
+	}
+	for _, g := range existing {
+		if g.CreatedBy == models.CreatedBySystem && !kept[g.ID] {
+			s.logConfigChange("delete grant %q on %q for %s", g.Privilege, g.Resource, g.Subject)
+		}
+	}
 
-	return nil
+	return data.DeleteGrants(db, data.DeleteGrantsOptions{
+		NotIDs:      keep,
+		ByCreatedBy: models.CreatedBySystem,
+	})
 }
 
-func (Server) loadGrant(db data.GormTxn, input Grant) (*models.Grant, error) {
+func (s Server) loadGrant(db data.GormTxn, input Grant) (*models.Grant, error) {
 	var id uid.PolymorphicID
 
 	switch {
@@ -802,7 +896,7 @@ func (Server) loadGrant(db data.GormTxn, input Grant) (*models.Grant, error) {
 				return nil, err
 			}
 
-			logging.Debugf("creating placeholder group %q", input.Group)
+			s.logConfigChange("create placeholder group %q", input.Group)
 
 			// group does not exist yet, create a placeholder
 			group = &models.Group{
@@ -851,6 +945,7 @@ func (Server) loadGrant(db data.GormTxn, input Grant) (*models.Grant, error) {
 			CreatedBy: models.CreatedBySystem,
 		}
 
+		s.logConfigChange("create grant %q on %q for %s", input.Role, input.Resource, grantSubjectName(input))
 		if err := data.CreateGrant(db, grant); err != nil {
 			return nil, err
 		}
@@ -859,7 +954,83 @@ func (Server) loadGrant(db data.GormTxn, input Grant) (*models.Grant, error) {
 	return grant, nil
 }
 
-func (s Server) loadUsers(db data.GormTxn, users []User) error {
+func grantSubjectName(g Grant) string {
+	switch {
+	case g.User != "":
+		return fmt.Sprintf("user %q", g.User)
+	case g.Group != "":
+		return fmt.Sprintf("group %q", g.Group)
+	default:
+		return fmt.Sprintf("user %q", g.Machine)
+	}
+}
+
+func (s Server) loadGroups(db data.GormTxn, groups []Group) error {
+	for _, g := range groups {
+		if _, err := s.loadGroup(db, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGroup creates the group if it does not exist, and adds the users from
+// the config that are not already members. Members that are not in the config
+// are not removed.
+func (s Server) loadGroup(db data.GormTxn, input Group) (*models.Group, error) {
+	group, err := data.GetGroup(db, data.ByName(input.Name))
+	if err != nil {
+		if !errors.Is(err, internal.ErrNotFound) {
+			return nil, err
+		}
+
+		group = &models.Group{
+			Name:      input.Name,
+			CreatedBy: models.CreatedBySystem,
+		}
+
+		s.logConfigChange("create group %q", input.Name)
+		if err := data.CreateGroup(db, group); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(input.Users) == 0 {
+		return group, nil
+	}
+
+	members, err := data.ListIdentities(db, nil, data.ByOptionalIdentityGroupID(group.ID))
+	if err != nil {
+		return nil, err
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, m := range members {
+		isMember[m.Name] = true
+	}
+
+	var add []uid.ID
+	for _, name := range input.Users {
+		if isMember[name] {
+			continue
+		}
+		user, err := data.GetIdentity(db, data.ByName(name))
+		if err != nil {
+			return nil, fmt.Errorf("group %q: user %q: %w", input.Name, name, err)
+		}
+		s.logConfigChange("add user %q to group %q", name, input.Name)
+		add = append(add, user.ID)
+		isMember[name] = true
+	}
+
+	if len(add) > 0 {
+		if err := data.AddUsersToGroup(db, group.ID, add); err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+func (s Server) loadUsers(db data.GormTxn, users []User, prune bool) error {
 	keep := make([]uid.ID, 0, len(users)+1)
 
 	for _, i := range users {
@@ -871,12 +1042,32 @@ func (s Server) loadUsers(db data.GormTxn, users []User) error {
 		keep = append(keep, user.ID)
 	}
 
+	if !prune {
+		return nil
+	}
+
 	// remove any users previously defined by config
-	if err := data.DeleteIdentities(db, data.NotIDs(keep), data.CreatedBy(models.CreatedBySystem)); err != nil {
+	selectors := []data.SelectorFunc{data.NotIDs(keep), data.CreatedBy(models.CreatedBySystem)}
+	removed, err := data.ListIdentities(db, nil, selectors...)
+	if err != nil {
 		return err
 	}
+	if len(removed) == 0 {
+		return nil
+	}
+	for _, u := range removed {
+		s.logConfigChange("delete user %q", u.Name)
 
-	return nil
+		// grants for the user are deleted with the user
+		grants, err := data.ListGrants(db, data.ListGrantsOptions{BySubject: u.PolyID()})
+		if err != nil {
+			return err
+		}
+		for _, g := range grants {
+			s.logConfigChange("delete grant %q on %q for %s", g.Privilege, g.Resource, g.Subject)
+		}
+	}
+	return data.DeleteIdentities(db, selectors...)
 }
 
 func (s Server) loadUser(db data.GormTxn, input User) (*models.Identity, error) {
@@ -898,6 +1089,7 @@ func (s Server) loadUser(db data.GormTxn, input User) (*models.Identity, error)
 			CreatedBy: models.CreatedBySystem,
 		}
 
+		s.logConfigChange("create user %q", input.Name)
 		if err := data.CreateIdentity(db, identity); err != nil {
 			return nil, err
 		}
@@ -995,6 +1187,11 @@ func (s Server) loadAccessKey(db data.GormTxn, identity *models.Identity, key st
 		if _, err := data.CreateAccessKey(db, accessKey); err != nil {
 			return err
 		}
+		// the key was created by the server from the config file
+		err := data.CreateAccessKeyAuditEvents(db, models.AuditActionCreateAccessKey, 0, *accessKey)
+		if err != nil {
+			return err
+		}
 
 		if _, err := data.CreateProviderUser(db, provider, identity); err != nil {
 			return err
@@ -14,7 +14,9 @@ import (
 	"gotest.tools/v3/assert/opt"
 	"k8s.io/utils/strings/slices"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/cmd/cliopts"
+	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
@@ -366,6 +368,19 @@ func TestLoadConfigInvalid(t *testing.T) {
 				},
 			},
 		},
+		"InvalidProviderDomain": {
+			Providers: []Provider{
+				{
+					Name:         "okta",
+					URL:          "example.com",
+					ClientID:     "client-id",
+					ClientSecret: "client-secret",
+					AuthURL:      "example.com/auth",
+					Scopes:       []string{"openid", "email"},
+					Domains:      []string{"@example.com"},
+				},
+			},
+		},
 		"MissingGrantIdentity": {
 			Grants: []Grant{
 				{
@@ -738,6 +753,168 @@ func TestLoadConfigPruneConfig(t *testing.T) {
 	assert.Equal(t, int64(1), groups)
 }
 
+func TestLoadConfigWithGroups(t *testing.T) {
+	s := setupServer(t)
+
+	config := Config{
+		Providers: []Provider{
+			{
+				Name:         "okta",
+				URL:          "example.com",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				AuthURL:      "example.com/auth",
+				Scopes:       []string{"openid", "email"},
+			},
+		},
+		Groups: []Group{
+			{Name: "Developers", Users: []string{"alice@example.com"}},
+		},
+		Grants: []Grant{
+			{Group: "Developers", Role: "view", Resource: "dev"},
+		},
+	}
+
+	err := s.loadConfig(config)
+	assert.NilError(t, err)
+
+	members := func(t *testing.T, name string) []string {
+		t.Helper()
+		group, err := data.GetGroup(s.DB(), data.ByName(name))
+		assert.NilError(t, err)
+		users, err := data.ListIdentities(s.DB(), nil, data.ByOptionalIdentityGroupID(group.ID))
+		assert.NilError(t, err)
+		var names []string
+		for _, u := range users {
+			names = append(names, u.Name)
+		}
+		return names
+	}
+	count := func(t *testing.T, table string) int64 {
+		t.Helper()
+		var n int64
+		stmt := "SELECT COUNT(*) FROM " + table + " WHERE organization_id = ? AND deleted_at IS null;"
+		err := s.db.Raw(stmt, s.db.DefaultOrg.ID).Scan(&n).Error
+		assert.NilError(t, err)
+		return n
+	}
+
+	assert.DeepEqual(t, members(t, "Developers"), []string{"alice@example.com"})
+	assert.Equal(t, count(t, "groups"), int64(1))
+	assert.Equal(t, count(t, "grants"), int64(2)) // 1 from config, 1 internal connector
+
+	t.Run("loading the same config again does not create duplicates", func(t *testing.T) {
+		err := s.loadConfig(config)
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, members(t, "Developers"), []string{"alice@example.com"})
+		assert.Equal(t, count(t, "groups"), int64(1))
+		assert.Equal(t, count(t, "grants"), int64(2))
+		assert.Equal(t, count(t, "providers"), int64(2))  // okta and infra
+		assert.Equal(t, count(t, "identities"), int64(2)) // alice and connector
+	})
+
+	t.Run("changed resources are updated", func(t *testing.T) {
+		config.Providers[0].ClientID = "new-client-id"
+		config.Groups[0].Users = append(config.Groups[0].Users, "bob@example.com")
+
+		err := s.loadConfig(config)
+		assert.NilError(t, err)
+
+		provider, err := data.GetProvider(s.DB(), data.ByName("okta"))
+		assert.NilError(t, err)
+		assert.Equal(t, provider.ClientID, "new-client-id")
+		assert.Equal(t, count(t, "providers"), int64(2))
+
+		assert.Assert(t, is.Len(members(t, "Developers"), 2))
+		assert.Equal(t, count(t, "groups"), int64(1))
+	})
+}
+
+func TestLoadConfigSkipPrune(t *testing.T) {
+	s := setupServer(t)
+
+	config := Config{
+		Grants: []Grant{
+			{User: "test@example.com", Role: "admin", Resource: "test-cluster"},
+		},
+	}
+	err := s.loadConfig(config)
+	assert.NilError(t, err)
+
+	err = s.loadConfig(Config{SkipPrune: true})
+	assert.NilError(t, err)
+
+	user, err := data.GetIdentity(s.DB(), data.ByName("test@example.com"))
+	assert.NilError(t, err)
+
+	_, err = data.GetGrant(s.DB(), data.GetGrantOptions{
+		BySubject:   uid.NewIdentityPolymorphicID(user.ID),
+		ByResource:  "test-cluster",
+		ByPrivilege: "admin",
+	})
+	assert.NilError(t, err)
+}
+
+func TestLoadConfigDryRun(t *testing.T) {
+	s := setupServer(t)
+	s.options.DryRun = true
+
+	logs := new(bytes.Buffer)
+	logging.PatchLogger(t, logs)
+
+	config := Config{
+		Groups: []Group{
+			{Name: "Developers", Users: []string{"alice@example.com"}},
+		},
+		Grants: []Grant{
+			{Group: "Developers", Role: "view", Resource: "dev"},
+		},
+	}
+	err := s.loadConfig(config)
+	assert.NilError(t, err)
+
+	assert.Assert(t, is.Contains(logs.String(), `dry run: create group \"Developers\"`))
+	assert.Assert(t, is.Contains(logs.String(), `dry run: add user \"alice@example.com\" to group \"Developers\"`))
+	assert.Assert(t, is.Contains(logs.String(), `dry run: create grant \"view\" on \"dev\" for group \"Developers\"`))
+
+	_, err = data.GetGroup(s.DB(), data.ByName("Developers"))
+	assert.ErrorIs(t, err, internal.ErrNotFound)
+	_, err = data.GetIdentity(s.DB(), data.ByName("alice@example.com"))
+	assert.ErrorIs(t, err, internal.ErrNotFound)
+
+	t.Run("grants of a pruned user", func(t *testing.T) {
+		s.options.DryRun = false
+		config := Config{
+			Grants: []Grant{
+				{User: "bob@example.com", Role: "view", Resource: "dev"},
+			},
+		}
+		assert.NilError(t, s.loadConfig(config))
+
+		bob, err := data.GetIdentity(s.DB(), data.ByName("bob@example.com"))
+		assert.NilError(t, err)
+		// a grant that was not created by the config
+		err = data.CreateGrant(s.DB(), &models.Grant{
+			Subject:   bob.PolyID(),
+			Privilege: "admin",
+			Resource:  "prod",
+		})
+		assert.NilError(t, err)
+
+		s.options.DryRun = true
+		logs.Reset()
+		assert.NilError(t, s.loadConfig(Config{}))
+
+		assert.Assert(t, is.Contains(logs.String(), `dry run: delete user \"bob@example.com\"`))
+		assert.Assert(t, is.Contains(logs.String(), `dry run: delete grant \"view\" on \"dev\" for `+bob.PolyID().String()))
+		assert.Assert(t, is.Contains(logs.String(), `dry run: delete grant \"admin\" on \"prod\" for `+bob.PolyID().String()))
+
+		_, err = data.GetIdentity(s.DB(), data.ByName("bob@example.com"))
+		assert.NilError(t, err)
+	})
+}
+
 func TestLoadConfigUpdate(t *testing.T) {
 	s := setupServer(t)
 
@@ -8,8 +8,11 @@ import (
 )
 
 func (a *API) GetServerConfiguration(c *gin.Context, _ *api.EmptyRequest) (*api.ServerConfiguration, error) {
+	disabled := a.server.signupDisabled()
 	return &api.ServerConfiguration{
 		IsEmailConfigured: email.IsConfigured(),
+		IsSignupEnabled:   disabled == nil,
+		SignupDisabled:    disabled,
 		BaseDomain:        a.server.options.BaseDomain,
 	}, nil
 }
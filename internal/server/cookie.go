@@ -1,8 +1,10 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,16 +15,75 @@ import (
 const (
 	cookieAuthorizationName       = "auth"
 	cookieSignupName              = "signup"
+	cookieHostPrefix              = "__Host-"
 	cookiePath                    = "/"
 	cookieMaxAgeDeleteImmediately = -1 // <0: delete immediately
 	cookieMaxAgeNoExpiry          = 0  // zero has special meaning of "no expiry"
 )
 
+// CookieOptions configure the cookies used to authenticate requests from a
+// browser.
+type CookieOptions struct {
+	// SameSite is the SameSite attribute of the cookies. One of strict, lax,
+	// or none. Defaults to strict. Cookies with SameSite=None are always
+	// Secure, because browsers reject them otherwise.
+	SameSite string
+	// NamePrefix is added to the name of each cookie. Use a different prefix
+	// for each Infra server that shares a domain, so that their cookies do not
+	// collide. For example, a prefix of "infra_acme_" sets an "infra_acme_auth"
+	// cookie.
+	NamePrefix string
+	// HostPrefix adds the __Host- prefix to the name of each cookie. Browsers
+	// only accept these cookies when they are Secure, have a Path of /, and
+	// do not set a Domain, which prevents them from being set or read by
+	// any other host. Requires TLS, and can not be used with a BaseDomain.
+	HostPrefix bool
+}
+
+func (o CookieOptions) validate() error {
+	switch strings.ToLower(o.SameSite) {
+	case "", "strict", "lax", "none":
+	default:
+		return fmt.Errorf("invalid cookie sameSite %q, must be one of strict, lax, or none", o.SameSite)
+	}
+
+	if strings.IndexFunc(o.NamePrefix, isInvalidCookieNameRune) >= 0 {
+		return fmt.Errorf("invalid cookie namePrefix %q, must not contain spaces or separators", o.NamePrefix)
+	}
+	return nil
+}
+
+// isInvalidCookieNameRune returns true if r is not allowed in a cookie name,
+// as defined by RFC 6265.
+func isInvalidCookieNameRune(r rune) bool {
+	return r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, r)
+}
+
+// name returns the name of the cookie with the configured prefix.
+func (o CookieOptions) name(name string) string {
+	if o.HostPrefix {
+		return cookieHostPrefix + o.NamePrefix + name
+	}
+	return o.NamePrefix + name
+}
+
+func (o CookieOptions) sameSiteMode() http.SameSite {
+	switch strings.ToLower(o.SameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
 type cookieConfig struct {
-	Name    string
-	Value   string
-	Domain  string
-	Expires time.Time
+	Name     string
+	Value    string
+	Domain   string
+	Expires  time.Time
+	SameSite http.SameSite
 }
 
 func setCookie(c *gin.Context, config cookieConfig) {
@@ -37,19 +98,38 @@ func setCookie(c *gin.Context, config cookieConfig) {
 		secure = false
 	}
 
+	sameSite := config.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteStrictMode
+	}
+	if sameSite == http.SameSiteNoneMode {
+		// browsers reject SameSite=None cookies that are not secure
+		secure = true
+	}
+
+	domain := config.Domain
+	if strings.HasPrefix(config.Name, cookieHostPrefix) {
+		// browsers reject __Host- cookies that are not secure, or that set a domain
+		secure = true
+		domain = ""
+	}
+
 	http.SetCookie(c.Writer, &http.Cookie{
 		Name:     config.Name,
 		Value:    url.QueryEscape(config.Value),
 		MaxAge:   maxAge,
 		Path:     cookiePath,
-		Domain:   config.Domain,
-		SameSite: http.SameSiteStrictMode,
+		Domain:   domain,
+		SameSite: sameSite,
 		Secure:   secure,
 		HttpOnly: true, // not accessible by javascript
 	})
 }
 
 func deleteCookie(c *gin.Context, name, domain string) {
+	if strings.HasPrefix(name, cookieHostPrefix) {
+		domain = ""
+	}
 	http.SetCookie(c.Writer, &http.Cookie{
 		Name:     name,
 		MaxAge:   cookieMaxAgeDeleteImmediately,
@@ -62,22 +142,23 @@ func deleteCookie(c *gin.Context, name, domain string) {
 
 // exchangeSignupCookieForSession sets the auth cookie on the current host making the request
 func exchangeSignupCookieForSession(c *gin.Context, opts Options) string {
-	signupCookie, err := getCookie(c.Request, cookieSignupName)
+	signupCookie, err := getCookie(c.Request, opts.Cookie.name(cookieSignupName))
 	if err != nil {
-		logging.L.Trace().Err(err).Msg("failed to find signup cookie, this may be expected")
+		logging.Ctx(c.Request.Context()).Trace().Err(err).Msg("failed to find signup cookie, this may be expected")
 		return ""
 	}
 
 	exp := time.Now().UTC().Add(opts.SessionDuration)
 
 	conf := cookieConfig{
-		Name:    cookieAuthorizationName,
-		Value:   signupCookie,
-		Domain:  c.Request.Host,
-		Expires: exp,
+		Name:     opts.Cookie.name(cookieAuthorizationName),
+		Value:    signupCookie,
+		Domain:   c.Request.Host,
+		Expires:  exp,
+		SameSite: opts.Cookie.sameSiteMode(),
 	}
 	setCookie(c, conf)
-	deleteCookie(c, cookieSignupName, opts.BaseDomain)
+	deleteCookie(c, opts.Cookie.name(cookieSignupName), opts.BaseDomain)
 
 	return signupCookie
 }
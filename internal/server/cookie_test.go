@@ -55,3 +55,108 @@ func TestResendAuthCookie(t *testing.T) {
 
 	assert.Equal(t, "signup=; Path=/; Domain=example.com; Max-Age=0; HttpOnly; Secure", c.Writer.Header()["Set-Cookie"][1])
 }
+
+func TestSetCookie_SameSite(t *testing.T) {
+	type testCase struct {
+		sameSite       string
+		tls            bool
+		expected       http.SameSite
+		expectedSecure bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		opts := CookieOptions{SameSite: tc.sameSite}
+		assert.NilError(t, opts.validate())
+
+		resp := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(resp)
+		c.Request = httptest.NewRequest(http.MethodPost, "http://example.com/api/login", nil)
+		if tc.tls {
+			c.Request = httptest.NewRequest(http.MethodPost, "https://example.com/api/login", nil)
+		}
+
+		setCookie(c, cookieConfig{
+			Name:     cookieAuthorizationName,
+			Value:    "aaa",
+			Domain:   "example.com",
+			Expires:  time.Now().Add(time.Minute),
+			SameSite: opts.sameSiteMode(),
+		})
+
+		cookies := resp.Result().Cookies()
+		assert.Equal(t, len(cookies), 1)
+		assert.Equal(t, cookies[0].SameSite, tc.expected)
+		assert.Equal(t, cookies[0].Secure, tc.expectedSecure)
+	}
+
+	testCases := map[string]testCase{
+		"default": {
+			expected: http.SameSiteStrictMode,
+		},
+		"strict": {
+			sameSite: "strict",
+			expected: http.SameSiteStrictMode,
+		},
+		"lax": {
+			sameSite: "Lax",
+			expected: http.SameSiteLaxMode,
+		},
+		"lax with TLS": {
+			sameSite:       "lax",
+			tls:            true,
+			expected:       http.SameSiteLaxMode,
+			expectedSecure: true,
+		},
+		"none is always secure": {
+			sameSite:       "none",
+			expected:       http.SameSiteNoneMode,
+			expectedSecure: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestCookieOptions_Validate(t *testing.T) {
+	err := CookieOptions{SameSite: "sometimes"}.validate()
+	assert.Error(t, err, `invalid cookie sameSite "sometimes", must be one of strict, lax, or none`)
+
+	err = CookieOptions{NamePrefix: "infra acme;"}.validate()
+	assert.Error(t, err, `invalid cookie namePrefix "infra acme;", must not contain spaces or separators`)
+
+	err = CookieOptions{SameSite: "lax", NamePrefix: "infra_acme_"}.validate()
+	assert.NilError(t, err)
+}
+
+func TestSetCookie_HostPrefix(t *testing.T) {
+	opts := CookieOptions{HostPrefix: true, NamePrefix: "infra_acme_"}
+	assert.Equal(t, opts.name(cookieAuthorizationName), "__Host-infra_acme_auth")
+
+	resp := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(resp)
+	// the cookie is secure even when the request is not over TLS
+	c.Request = httptest.NewRequest(http.MethodPost, "http://example.com/api/login", nil)
+
+	setCookie(c, cookieConfig{
+		Name:    opts.name(cookieAuthorizationName),
+		Value:   "aaa",
+		Domain:  "example.com",
+		Expires: time.Now().Add(time.Minute),
+	})
+	deleteCookie(c, opts.name(cookieAuthorizationName), "example.com")
+
+	cookies := resp.Result().Cookies()
+	assert.Equal(t, len(cookies), 2)
+	for _, cookie := range cookies {
+		assert.Equal(t, cookie.Name, "__Host-infra_acme_auth")
+		assert.Equal(t, cookie.Domain, "")
+		assert.Equal(t, cookie.Path, "/")
+		assert.Assert(t, cookie.Secure)
+	}
+	assert.Equal(t, cookies[0].Value, "aaa")
+	assert.Equal(t, cookies[1].MaxAge, -1)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/infrahq/infra/internal/logging"
+)
+
+// corsMaxAge is the amount of time a browser may cache the response to a
+// preflight request.
+const corsMaxAge = 2 * time.Hour
+
+var (
+	corsAllowedMethods = []string{
+		http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	}
+	corsAllowedHeaders = []string{
+		"Authorization", "Content-Type", "Infra-Version", "If-Match", idempotencyKeyHeader, headerRequestID,
+	}
+	// corsExposedHeaders are the response headers that a browser allows a
+	// cross-origin caller to read, in addition to the CORS-safelisted ones.
+	corsExposedHeaders = []string{
+		headerRequestID, "ETag", "Deprecation", "Sunset", "Link", idempotencyReplayedHeader,
+	}
+)
+
+// CORSOptions configure the Cross-Origin Resource Sharing headers, which allow
+// a browser app on a different origin than the server to call the API.
+type CORSOptions struct {
+	// AllowedOrigins are the origins allowed to call the API from a browser,
+	// for example https://app.example.com. An origin of * allows any origin.
+	// When empty, cross-origin requests are not allowed.
+	AllowedOrigins []string
+	// AllowCredentials allows browsers to include the authentication cookie
+	// in cross-origin requests. Browsers only send the cookie to a different
+	// site when the cookie sameSite is none. Can not be used with an
+	// allowed origin of *.
+	AllowCredentials bool
+}
+
+// validate returns an error if the options are invalid. cookie are the options
+// of the authentication cookie, which is only sent cross-site when its
+// sameSite is none.
+func (o CORSOptions) validate(cookie CookieOptions) error {
+	for _, origin := range o.AllowedOrigins {
+		switch {
+		case origin == "*" && o.AllowCredentials:
+			return errors.New("invalid cors allowedOrigins, * can not be used with allowCredentials")
+		case origin != "*" && strings.Contains(origin, "*"):
+			return fmt.Errorf("invalid cors allowedOrigins %q, wildcards are only supported as an origin of *", origin)
+		}
+	}
+
+	if o.AllowCredentials && cookie.sameSiteMode() != http.SameSiteNoneMode {
+		// same-site origins, like a subdomain of the server, still receive
+		// the cookie, so this is not an error.
+		logging.L.Warn().Str("sameSite", cookie.SameSite).
+			Msg("cors allowCredentials is set, but browsers only send the authentication cookie to other sites when the cookie sameSite is none")
+	}
+	return nil
+}
+
+func (o CORSOptions) allowsOrigin(origin string) bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware adds the CORS headers to responses to requests from an
+// allowed origin. Preflight requests are answered by the middleware, without
+// authenticating the request, because browsers do not send credentials with
+// a preflight request.
+func CORSMiddleware(opts CORSOptions) gin.HandlerFunc {
+	allowedMethods := strings.Join(corsAllowedMethods, ", ")
+	allowedHeaders := strings.Join(corsAllowedHeaders, ", ")
+	exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(corsMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// the response depends on the origin, even when the origin is not allowed
+		c.Writer.Header().Add("Vary", "Origin")
+
+		if !opts.allowsOrigin(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		if opts.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", allowedMethods)
+			header.Set("Access-Control-Allow-Headers", allowedHeaders)
+			header.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		header.Set("Access-Control-Expose-Headers", exposedHeaders)
+		c.Next()
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/infrahq/infra/internal/logging"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+	}
+
+	var handlerCalled bool
+	router := gin.New()
+	router.Use(CORSMiddleware(opts))
+	router.NoRoute(func(c *gin.Context) {
+		handlerCalled = true
+		// requests that reach the handler are not authenticated
+		c.Status(http.StatusUnauthorized)
+	})
+	router.GET("/api/users", func(c *gin.Context) {
+		handlerCalled = true
+		c.Status(http.StatusOK)
+	})
+
+	run := func(req *http.Request) *httptest.ResponseRecorder {
+		handlerCalled = false
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		resp := run(req)
+
+		assert.Equal(t, resp.Code, http.StatusOK)
+		assert.Assert(t, handlerCalled)
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "https://app.example.com")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Credentials"), "true")
+		assert.Equal(t, resp.Header().Get("Access-Control-Expose-Headers"),
+			"X-Request-ID, ETag, Deprecation, Sunset, Link, Idempotent-Replayed")
+		assert.DeepEqual(t, resp.Header().Values("Vary"), []string{"Origin"})
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		resp := run(req)
+
+		assert.Equal(t, resp.Code, http.StatusOK)
+		assert.Assert(t, handlerCalled)
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Credentials"), "")
+		assert.DeepEqual(t, resp.Header().Values("Vary"), []string{"Origin"})
+	})
+
+	t.Run("no origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		resp := run(req)
+
+		assert.Equal(t, resp.Code, http.StatusOK)
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, resp.Header().Get("Vary"), "")
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, infra-version")
+		resp := run(req)
+
+		assert.Equal(t, resp.Code, http.StatusNoContent)
+		assert.Assert(t, !handlerCalled, "preflight request reached the handler")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "https://app.example.com")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Credentials"), "true")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Methods"), "GET, POST, PUT, PATCH, DELETE")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Headers"),
+			"Authorization, Content-Type, Infra-Version, If-Match, Idempotency-Key, X-Request-ID")
+		assert.Equal(t, resp.Header().Get("Access-Control-Max-Age"), "7200")
+	})
+
+	t.Run("preflight from disallowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		resp := run(req)
+
+		assert.Equal(t, resp.Code, http.StatusForbidden)
+		assert.Assert(t, !handlerCalled, "preflight request reached the handler")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "")
+	})
+}
+
+func TestCORSMiddleware_AnyOrigin(t *testing.T) {
+	router := gin.New()
+	router.Use(CORSMiddleware(CORSOptions{AllowedOrigins: []string{"*"}}))
+	router.GET("/api/version", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	req.Header.Set("Origin", "https://other.example.com")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusOK)
+	assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "https://other.example.com")
+	assert.Equal(t, resp.Header().Get("Access-Control-Allow-Credentials"), "")
+}
+
+func TestCORSOptions_Validate(t *testing.T) {
+	sameSiteNone := CookieOptions{SameSite: "none"}
+
+	opts := CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+	assert.ErrorContains(t, opts.validate(sameSiteNone), "* can not be used with allowCredentials")
+
+	opts = CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}
+	assert.ErrorContains(t, opts.validate(sameSiteNone), "wildcards are only supported as an origin of *")
+
+	opts = CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}
+	assert.NilError(t, opts.validate(sameSiteNone))
+
+	t.Run("credentials with a strict cookie", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logging.PatchLogger(t, buf)
+
+		opts := CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}
+		assert.NilError(t, opts.validate(CookieOptions{}))
+		assert.Assert(t, cmp.Contains(buf.String(), "only send the authentication cookie to other sites when the cookie sameSite is none"))
+
+		buf.Reset()
+		assert.NilError(t, opts.validate(sameSiteNone))
+		assert.Equal(t, buf.String(), "")
+	})
+}
@@ -1,13 +1,19 @@
 package data
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/generate"
+	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data/querybuilder"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
@@ -20,27 +26,134 @@ func (a accessKeyTable) Table() string {
 }
 
 func (a accessKeyTable) Columns() []string {
-	return []string{"created_at", "deleted_at", "expires_at", "extension", "extension_deadline", "id", "issued_for", "key_id", "name", "organization_id", "provider_id", "scopes", "secret_checksum", "updated_at"}
+	return []string{"created_at", "created_by", "deleted_at", "expires_at", "extension", "extension_deadline", "id", "issued_for", "key_id", "last_used_at", "name", "one_time_use", "organization_id", "provider_id", "scopes", "secret_checksum", "updated_at"}
 }
 
 func (a accessKeyTable) Values() []any {
-	return []any{a.CreatedAt, a.DeletedAt, a.ExpiresAt, a.Extension, a.ExtensionDeadline, a.ID, a.IssuedFor, a.KeyID, a.Name, a.OrganizationID, a.ProviderID, a.Scopes, a.SecretChecksum, a.UpdatedAt}
+	return []any{a.CreatedAt, a.CreatedBy, a.DeletedAt, a.ExpiresAt, a.Extension, a.ExtensionDeadline, a.ID, a.IssuedFor, a.KeyID, a.LastUsedAt, a.Name, a.OneTimeUse, a.OrganizationID, a.ProviderID, a.Scopes, a.SecretChecksum, a.UpdatedAt}
 }
 
 func (a *accessKeyTable) ScanFields() []any {
-	return []any{&a.CreatedAt, &a.DeletedAt, &a.ExpiresAt, &a.Extension, &a.ExtensionDeadline, &a.ID, &a.IssuedFor, &a.KeyID, &a.Name, &a.OrganizationID, &a.ProviderID, &a.Scopes, &a.SecretChecksum, &a.UpdatedAt}
+	return []any{&a.CreatedAt, &a.CreatedBy, &a.DeletedAt, &a.ExpiresAt, &a.Extension, &a.ExtensionDeadline, &a.ID, &a.IssuedFor, &a.KeyID, &a.LastUsedAt, &a.Name, &a.OneTimeUse, &a.OrganizationID, &a.ProviderID, &a.Scopes, &a.SecretChecksum, &a.UpdatedAt}
 }
 
 var (
 	ErrAccessKeyExpired          = fmt.Errorf("access key expired")
 	ErrAccessKeyDeadlineExceeded = fmt.Errorf("%w: extension deadline exceeded", ErrAccessKeyExpired)
+	// ErrAccessKeyInvalid is returned when the access key does not exist or
+	// the secret does not match. Both cases return the same error so that a
+	// caller can not use it to learn whether a key ID exists.
+	ErrAccessKeyInvalid = fmt.Errorf("invalid access key")
+	// ErrOneTimeAccessKeyUsed is returned when a one-time use access key was
+	// already used by a concurrent request.
+	ErrOneTimeAccessKeyUsed = fmt.Errorf("%w: one-time use access key has already been used", ErrAccessKeyInvalid)
 )
 
+// checksumVersionHMAC is the first byte of a checksum computed with an HMAC
+// keyed with one of the access key checksum keys. Checksums written before the
+// keys were introduced are an unkeyed SHA-256 hash, and have no version.
+const checksumVersionHMAC byte = 1
+
+// checksumKeyIDSize is the size of the identifier of the checksum key, which
+// follows the version in a keyed checksum.
+const checksumKeyIDSize = 8
+
+type checksumKey struct {
+	id  []byte
+	key []byte
+}
+
+type accessKeyChecksumConfig struct {
+	keys        []checksumKey
+	allowLegacy bool
+}
+
+var accessKeyChecksum atomic.Value // accessKeyChecksumConfig
+
+func init() {
+	accessKeyChecksum.Store(accessKeyChecksumConfig{allowLegacy: true})
+}
+
+// SetAccessKeyChecksumKeys sets the keys of the HMAC used as the checksum of
+// access key secrets. The first key is used for every new checksum. The other
+// keys are only used to validate existing checksums, so that a key can be
+// rotated by adding a new key to the front of the list. When no keys are set
+// the checksum is an unkeyed SHA-256 hash.
+//
+// When allowLegacy is true, access keys with an unkeyed SHA-256 checksum are
+// still accepted, and their checksum is replaced the first time they are used.
+// It can be set to false once every access key in use has been migrated.
+func SetAccessKeyChecksumKeys(keys [][]byte, allowLegacy bool) error {
+	if len(keys) == 0 && !allowLegacy {
+		return fmt.Errorf("legacy access key checksums can only be disabled when a checksum key is set")
+	}
+
+	cfg := accessKeyChecksumConfig{allowLegacy: allowLegacy}
+	for i, key := range keys {
+		if len(key) < 32 {
+			return fmt.Errorf("access key checksum key %d must be at least 32 bytes", i)
+		}
+		id := sha256.Sum256(key)
+		cfg.keys = append(cfg.keys, checksumKey{id: id[:checksumKeyIDSize], key: key})
+	}
+	accessKeyChecksum.Store(cfg)
+	return nil
+}
+
+// secretChecksum returns the checksum of secret, computed with the current
+// checksum key.
 func secretChecksum(secret string) []byte {
+	cfg := accessKeyChecksum.Load().(accessKeyChecksumConfig)
+	if len(cfg.keys) == 0 {
+		return legacySecretChecksum(secret)
+	}
+	return keyedSecretChecksum(cfg.keys[0], secret)
+}
+
+func legacySecretChecksum(secret string) []byte {
 	chksm := sha256.Sum256([]byte(secret))
 	return chksm[:]
 }
 
+func keyedSecretChecksum(key checksumKey, secret string) []byte {
+	mac := hmac.New(sha256.New, key.key)
+	mac.Write([]byte(secret))
+
+	chksm := make([]byte, 0, 1+checksumKeyIDSize+sha256.Size)
+	chksm = append(chksm, checksumVersionHMAC)
+	chksm = append(chksm, key.id...)
+	return mac.Sum(chksm)
+}
+
+// checkSecretChecksum returns true if checksum is the checksum of secret. The
+// second return value is true when the checksum was not computed with the
+// current checksum key, and should be replaced.
+func checkSecretChecksum(checksum []byte, secret string) (valid bool, outdated bool) {
+	cfg := accessKeyChecksum.Load().(accessKeyChecksumConfig)
+
+	if len(checksum) == sha256.Size {
+		if !cfg.allowLegacy {
+			return false, false
+		}
+		valid := subtle.ConstantTimeCompare(checksum, legacySecretChecksum(secret)) == 1
+		return valid, valid && len(cfg.keys) > 0
+	}
+
+	if len(checksum) != 1+checksumKeyIDSize+sha256.Size || checksum[0] != checksumVersionHMAC {
+		return false, false
+	}
+
+	keyID := checksum[1 : 1+checksumKeyIDSize]
+	for i, key := range cfg.keys {
+		if subtle.ConstantTimeCompare(keyID, key.id) != 1 {
+			continue
+		}
+		valid := subtle.ConstantTimeCompare(checksum, keyedSecretChecksum(key, secret)) == 1
+		return valid, valid && i > 0
+	}
+	return false, false
+}
+
 func validateAccessKey(accessKey *models.AccessKey) error {
 	switch {
 	case accessKey.IssuedFor == 0:
@@ -73,7 +186,14 @@ func CreateAccessKey(db GormTxn, accessKey *models.AccessKey) (body string, err
 	accessKey.SecretChecksum = secretChecksum(accessKey.Secret)
 
 	if accessKey.ExpiresAt.IsZero() {
-		accessKey.ExpiresAt = time.Now().Add(time.Hour * 12).UTC()
+		ttl, err := defaultAccessKeyTTL(db)
+		if err != nil {
+			return "", err
+		}
+		accessKey.ExpiresAt = time.Now().Add(ttl).UTC()
+	}
+	if accessKey.ExtensionDeadline.After(accessKey.ExpiresAt) {
+		accessKey.ExtensionDeadline = accessKey.ExpiresAt
 	}
 
 	if accessKey.Name == "" {
@@ -101,6 +221,39 @@ func CreateAccessKey(db GormTxn, accessKey *models.AccessKey) (body string, err
 	return fmt.Sprintf("%s.%s", accessKey.KeyID, accessKey.Secret), nil
 }
 
+// defaultAccessKeyTTL returns the lifetime of access keys created without an
+// explicit expiry. The organization setting takes precedence over the
+// default of 12 hours.
+func defaultAccessKeyTTL(tx GormTxn) (time.Duration, error) {
+	settings, err := GetSettings(tx)
+	if err != nil {
+		return 0, fmt.Errorf("access key ttl from settings: %w", err)
+	}
+	if settings.DefaultAccessKeyTTL > 0 {
+		return settings.DefaultAccessKeyTTL, nil
+	}
+	return 12 * time.Hour, nil
+}
+
+// defaultMaxActiveAccessKeys is the number of access keys, that are not
+// expired, which may be issued for each identity when the organization does
+// not set a limit.
+const defaultMaxActiveAccessKeys = 100
+
+// MaxActiveAccessKeys returns the maximum number of access keys, that are not
+// expired, which may be issued for each identity. The organization setting
+// takes precedence over the default.
+func MaxActiveAccessKeys(tx GormTxn) (int, error) {
+	settings, err := GetSettings(tx)
+	if err != nil {
+		return 0, fmt.Errorf("max active access keys from settings: %w", err)
+	}
+	if settings.MaxActiveAccessKeys > 0 {
+		return settings.MaxActiveAccessKeys, nil
+	}
+	return defaultMaxActiveAccessKeys, nil
+}
+
 func UpdateAccessKey(tx WriteTxn, key *models.AccessKey) error {
 	if key.Secret != "" {
 		key.SecretChecksum = secretChecksum(key.Secret)
@@ -114,23 +267,73 @@ func UpdateAccessKey(tx WriteTxn, key *models.AccessKey) error {
 
 type ListAccessKeyOptions struct {
 	IncludeExpired bool
+	// IncludeDeleted instructs ListAccessKeys to also return keys that were
+	// deleted.
+	IncludeDeleted bool
 	ByIssuedForID  uid.ID
+	ByProviderID   uid.ID
 	ByName         string
+	ByNamePrefix   string
 	Pagination     *Pagination
 }
 
+// CountActiveAccessKeys returns the number of access keys issued for the
+// identity that are not expired or deleted. The row of the identity is locked
+// until the transaction ends, so that concurrent transactions which count the
+// keys of the same identity, before creating a new one, run one at a time.
+func CountActiveAccessKeys(tx WriteTxn, identityID uid.ID) (int, error) {
+	query := querybuilder.New("SELECT id FROM identities")
+	query.B("WHERE id = ? AND organization_id = ? AND", identityID, tx.OrganizationID())
+	query.B(notDeleted(identitiesTable{}))
+	query.B("FOR UPDATE")
+	var id uid.ID
+	if err := tx.QueryRow(query.String(), query.Args...).Scan(&id); err != nil {
+		return 0, handleError(err)
+	}
+
+	now, zero := time.Now(), time.Time{}
+	query = querybuilder.New("SELECT count(*) FROM access_keys")
+	query.B("WHERE organization_id = ? AND issued_for = ?", tx.OrganizationID(), identityID)
+	query.B("AND")
+	query.B(notDeleted(&accessKeyTable{}))
+	query.B("AND (expires_at > ? OR expires_at = ? OR expires_at is null)", now, zero)
+	query.B("AND (extension_deadline > ? OR extension_deadline = ? OR extension_deadline is null)", now, zero)
+
+	var count int
+	if err := tx.QueryRow(query.String(), query.Args...).Scan(&count); err != nil {
+		return 0, handleError(err)
+	}
+	return count, nil
+}
+
+// ListAccessKeys returns the access keys ordered by name, then ID. When
+// opts.Pagination has a Cursor the keys are ordered by creation time, then ID,
+// and only the keys created after the cursor are returned, so that keys
+// created or renamed between requests are not skipped or repeated.
 func ListAccessKeys(tx ReadTxn, opts ListAccessKeyOptions) ([]models.AccessKey, error) {
+	cursor := opts.Pagination.after()
+
 	table := &accessKeyTable{}
 	query := querybuilder.New("SELECT")
+	if cursor != nil {
+		// select the page from a subquery, so that the count includes the
+		// keys before the cursor.
+		query.B("* FROM (SELECT")
+	}
 	query.B(columnsForSelect(table))
-	query.B(", identities.name")
+	query.B(", identities.name AS issued_for_name")
 	if opts.Pagination != nil {
-		query.B(", count(*) OVER()")
+		query.B(", count(*) OVER() AS total_count")
 	}
 	query.B("FROM access_keys INNER JOIN identities")
 	query.B("ON access_keys.issued_for = identities.id")
-	query.B("WHERE access_keys.deleted_at is null AND identities.deleted_at is null")
-	query.B("AND access_keys.organization_id = ?", tx.OrganizationID())
+	query.B("WHERE access_keys.organization_id = ?", tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(identitiesTable{}))
+	if !opts.IncludeDeleted {
+		query.B("AND")
+		query.B(notDeleted(table))
+	}
 
 	if !opts.IncludeExpired {
 		// TODO: can we remove the need to check for both the zero value and nil?
@@ -141,10 +344,24 @@ func ListAccessKeys(tx ReadTxn, opts ListAccessKeyOptions) ([]models.AccessKey,
 	if opts.ByIssuedForID != 0 {
 		query.B("AND issued_for = ?", opts.ByIssuedForID)
 	}
+	if opts.ByProviderID != 0 {
+		query.B("AND access_keys.provider_id = ?", opts.ByProviderID)
+	}
 	if opts.ByName != "" {
 		query.B("AND access_keys.name = ?", opts.ByName)
 	}
-	query.B("ORDER BY access_keys.name ASC")
+	if opts.ByNamePrefix != "" {
+		query.B("AND access_keys.name LIKE ?", escapeLikePattern(opts.ByNamePrefix)+"%")
+	}
+	switch {
+	case cursor != nil:
+		query.B(") AS keys WHERE (created_at, id) > (?, ?)", cursor.Created, cursor.ID)
+		query.B("ORDER BY created_at ASC, id ASC")
+	case opts.Pagination != nil && opts.Pagination.Cursor != nil:
+		query.B("ORDER BY access_keys.created_at ASC, access_keys.id ASC")
+	default:
+		query.B("ORDER BY access_keys.name ASC, access_keys.id ASC")
+	}
 	if opts.Pagination != nil {
 		opts.Pagination.PaginateQuery(query)
 	}
@@ -168,12 +385,24 @@ func ListAccessKeys(tx ReadTxn, opts ListAccessKeyOptions) ([]models.AccessKey,
 		}
 		result = append(result, key)
 	}
-	return result, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if p := opts.Pagination; p != nil && p.hasNextPage(len(result)) {
+		result = result[:p.Limit]
+		last := result[len(result)-1]
+		p.NextCursor = &Cursor{Created: last.CreatedAt, ID: last.ID}
+	}
+	return result, nil
 }
 
 type GetAccessKeysOptions struct {
 	ByID    uid.ID
 	ByKeyID string
+	// IncludeDeleted instructs GetAccessKey to also return a key that was
+	// deleted.
+	IncludeDeleted bool
 }
 
 // GetAccessKey using the keyID. Note that the keyID is globally unique, so
@@ -187,15 +416,20 @@ func GetAccessKey(tx ReadTxn, opts GetAccessKeysOptions) (*models.AccessKey, err
 	query.B(columnsForSelect(accessKey))
 	query.B("FROM")
 	query.B(accessKey.Table())
-	query.B("WHERE deleted_at is null")
+	query.B("WHERE")
+	if opts.IncludeDeleted {
+		query.B("true")
+	} else {
+		query.B(notDeleted(accessKey))
+	}
 	if len(opts.ByKeyID) > 0 {
 		query.B("AND key_id = ?", opts.ByKeyID)
 	}
 	if opts.ByID > 0 {
-		query.B("and id = ?", opts.ByID)
+		query.B("AND id = ?", opts.ByID)
 	}
 
-	err := tx.QueryRow(query.String(), query.Args...).Scan(accessKey.ScanFields()...)
+	err := allowUnscoped(tx).QueryRow(query.String(), query.Args...).Scan(accessKey.ScanFields()...)
 	if err != nil {
 		return nil, handleReadError(err)
 	}
@@ -210,11 +444,37 @@ type DeleteAccessKeysOptions struct {
 	// ByProviderID instructs DeleteAccessKeys to delete keys issued by this
 	// provider.
 	ByProviderID uid.ID
+	// ByNamePrefix instructs DeleteAccessKeys to delete keys with a name that
+	// starts with this prefix.
+	ByNamePrefix string
+	// DryRun instructs DeleteAccessKeys to return the keys that match the
+	// other options without deleting them.
+	DryRun bool
+	// ActorID is the user who deleted the keys, and is recorded in the audit
+	// event for each deleted key. Zero means the keys were deleted by the
+	// server.
+	ActorID uid.ID
 }
 
-func DeleteAccessKeys(tx WriteTxn, opts DeleteAccessKeysOptions) error {
-	query := querybuilder.New("UPDATE access_keys")
-	query.B("SET deleted_at = ? WHERE", time.Now())
+// escapeLikePattern escapes the characters which have a special meaning in
+// the pattern of a LIKE expression.
+func escapeLikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
+
+// DeleteAccessKeys soft-deletes the access keys that match opts, records an
+// audit event for each key that was deleted, and returns the ID and Name of
+// each deleted key. When opts.DryRun is true nothing is deleted, and the keys
+// that would be deleted are returned.
+func DeleteAccessKeys(tx WriteTxn, opts DeleteAccessKeysOptions) ([]models.AccessKey, error) {
+	var query *querybuilder.Query
+	if opts.DryRun {
+		query = querybuilder.New("SELECT id, name, provider_id FROM access_keys WHERE")
+	} else {
+		query = querybuilder.New("UPDATE access_keys")
+		query.B("SET deleted_at = ? WHERE", time.Now())
+	}
 	switch {
 	case opts.ByID != 0:
 		query.B("id = ?", opts.ByID)
@@ -222,13 +482,45 @@ func DeleteAccessKeys(tx WriteTxn, opts DeleteAccessKeysOptions) error {
 		query.B("issued_for = ?", opts.ByIssuedForID)
 	case opts.ByProviderID != 0:
 		query.B("provider_id = ?", opts.ByProviderID)
+	case opts.ByNamePrefix != "":
+		query.B("name LIKE ?", escapeLikePattern(opts.ByNamePrefix)+"%")
 	default:
-		return fmt.Errorf("DeleteAccessKeys requires an ID to delete")
+		return nil, fmt.Errorf("DeleteAccessKeys requires an ID to delete")
 	}
 	query.B("AND organization_id = ?", tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(accessKeyTable{}))
+	if !opts.DryRun {
+		query.B("RETURNING id, name, provider_id")
+	}
 
-	_, err := tx.Exec(query.String(), query.Args...)
-	return err
+	rows, err := tx.Query(query.String(), query.Args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := scanDeletedAccessKeys(rows)
+	if err != nil || opts.DryRun {
+		return result, err
+	}
+
+	err = CreateAccessKeyAuditEvents(tx, models.AuditActionDeleteAccessKey, opts.ActorID, result...)
+	return result, err
+}
+
+// scanDeletedAccessKeys reads and closes rows, so that the connection can be
+// used for the audit events.
+func scanDeletedAccessKeys(rows *sql.Rows) ([]models.AccessKey, error) {
+	defer rows.Close()
+
+	var result []models.AccessKey
+	for rows.Next() {
+		var key models.AccessKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.ProviderID); err != nil {
+			return nil, err
+		}
+		result = append(result, key)
+	}
+	return result, rows.Err()
 }
 
 // TODO: move this to access package?
@@ -239,30 +531,197 @@ func ValidateRequestAccessKey(tx WriteTxn, authnKey string) (*models.AccessKey,
 	}
 
 	t, err := GetAccessKey(tx, GetAccessKeysOptions{ByKeyID: keyID})
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not get access key from database, it may not exist", err)
+	switch {
+	case errors.Is(err, internal.ErrNotFound):
+		// compare the secret to a checksum anyway, so that an unknown key ID
+		// takes about as long to reject as a secret that does not match
+		checkSecretChecksum(secretChecksum(""), secret)
+		logging.L.Debug().Str("keyID", keyID).Msg("access key not found")
+		return nil, ErrAccessKeyInvalid
+	case err != nil:
+		return nil, fmt.Errorf("could not get access key from database: %w", err)
 	}
 
-	sum := secretChecksum(secret)
-
-	if subtle.ConstantTimeCompare(t.SecretChecksum, sum) != 1 {
-		return nil, fmt.Errorf("access key invalid secret")
+	valid, outdated := checkSecretChecksum(t.SecretChecksum, secret)
+	if !valid {
+		logging.L.Debug().Str("keyID", keyID).Msg("access key secret does not match")
+		return nil, ErrAccessKeyInvalid
 	}
 
-	if time.Now().UTC().After(t.ExpiresAt) {
+	now := time.Now().UTC()
+	if now.After(t.ExpiresAt) {
 		return nil, ErrAccessKeyExpired
 	}
 
+	if !t.ExtensionDeadline.IsZero() && now.After(t.ExtensionDeadline) {
+		return nil, ErrAccessKeyDeadlineExceeded
+	}
+
+	if outdated && !t.OneTimeUse {
+		t.SecretChecksum = secretChecksum(secret)
+		if err := updateAccessKeySecretChecksum(tx, t); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.OneTimeUse {
+		t.LastUsedAt = now
+		if err := deleteOneTimeUseAccessKey(tx, t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
 	if !t.ExtensionDeadline.IsZero() {
-		if time.Now().UTC().After(t.ExtensionDeadline) {
-			return nil, ErrAccessKeyDeadlineExceeded
+		// only write the deadline when it advances by more than the threshold,
+		// to avoid a write on every request made with a hot key
+		deadline := extensionDeadline(now, t)
+		if deadline.Sub(t.ExtensionDeadline) > extensionWriteThreshold(t.Extension) {
+			t.ExtensionDeadline = deadline
+			t.LastUsedAt = now
+			if err := updateAccessKeyExtensionDeadline(tx, t); err != nil {
+				return nil, err
+			}
+			return t, nil
 		}
+	}
 
-		t.ExtensionDeadline = time.Now().UTC().Add(t.Extension)
-		if err := UpdateAccessKey(tx, t); err != nil {
+	// only write lastUsedAt periodically, to avoid a write on every request
+	if now.Sub(t.LastUsedAt) > accessKeyLastUsedInterval {
+		t.LastUsedAt = now
+		if err := updateAccessKeyLastUsedAt(tx, t); err != nil {
 			return nil, err
 		}
 	}
 
 	return t, nil
 }
+
+// extensionDeadline returns the deadline of the key after it is used at now.
+// The deadline is never later than the expiry of the key, so that using the key
+// can not extend it past its expiry.
+func extensionDeadline(now time.Time, key *models.AccessKey) time.Time {
+	deadline := now.Add(key.Extension)
+	if deadline.After(key.ExpiresAt) {
+		return key.ExpiresAt
+	}
+	return deadline
+}
+
+const defaultAccessKeyExtensionInterval = time.Minute
+
+var accessKeyExtensionInterval = int64(defaultAccessKeyExtensionInterval)
+
+// SetAccessKeyExtensionInterval sets the minimum amount the extension deadline
+// of an access key must advance before the new deadline is written. An interval
+// of zero uses the default of 1 minute.
+func SetAccessKeyExtensionInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultAccessKeyExtensionInterval
+	}
+	atomic.StoreInt64(&accessKeyExtensionInterval, int64(interval))
+}
+
+// extensionWriteThreshold returns the minimum amount the extension deadline of
+// a key with extension must advance before it is written. The threshold is at
+// most a quarter of the extension, so that a key which is used regularly does
+// not reach its deadline before the deadline is written.
+func extensionWriteThreshold(extension time.Duration) time.Duration {
+	threshold := time.Duration(atomic.LoadInt64(&accessKeyExtensionInterval))
+	if threshold > extension/4 {
+		return extension / 4
+	}
+	return threshold
+}
+
+// updateAccessKeyExtensionDeadline writes the extension deadline and the
+// LastUsedAt of key. The deadline only ever advances, so a later deadline
+// written by a concurrent request is never replaced. If another transaction is
+// already updating the key the write is skipped instead of waiting for the
+// lock, because that transaction is extending the deadline of the same key.
+func updateAccessKeyExtensionDeadline(tx WriteTxn, key *models.AccessKey) error {
+	// The transaction used to validate an access key is not yet scoped to
+	// an organization, so use the organization of the key.
+	query := querybuilder.New("UPDATE access_keys")
+	query.B("SET extension_deadline = ?, last_used_at = ?", key.ExtensionDeadline, key.LastUsedAt)
+	query.B("WHERE id IN (")
+	query.B("SELECT id FROM access_keys")
+	query.B("WHERE id = ? AND organization_id = ?", key.ID, key.OrganizationID)
+	query.B("AND")
+	query.B(notDeleted(accessKeyTable{}))
+	query.B("AND extension_deadline < ?", key.ExtensionDeadline)
+	query.B("FOR UPDATE SKIP LOCKED)")
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return handleError(err)
+}
+
+// accessKeyLastUsedInterval is the minimum amount of time between writes of
+// the LastUsedAt field of an access key.
+const accessKeyLastUsedInterval = 5 * time.Minute
+
+func updateAccessKeyLastUsedAt(tx WriteTxn, key *models.AccessKey) error {
+	// The transaction used to validate an access key is not yet scoped to
+	// an organization, so use the organization of the key.
+	query := querybuilder.New("UPDATE access_keys")
+	query.B("SET last_used_at = ?", key.LastUsedAt)
+	query.B("WHERE id = ? AND organization_id = ?", key.ID, key.OrganizationID)
+	query.B("AND")
+	query.B(notDeleted(accessKeyTable{}))
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return err
+}
+
+// updateAccessKeySecretChecksum replaces the checksum of a key that was
+// validated with a legacy or rotated checksum key.
+func updateAccessKeySecretChecksum(tx WriteTxn, key *models.AccessKey) error {
+	// The transaction used to validate an access key is not yet scoped to
+	// an organization, so use the organization of the key.
+	query := querybuilder.New("UPDATE access_keys")
+	query.B("SET secret_checksum = ?", key.SecretChecksum)
+	query.B("WHERE id = ? AND organization_id = ?", key.ID, key.OrganizationID)
+	query.B("AND")
+	query.B(notDeleted(accessKeyTable{}))
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return handleError(err)
+}
+
+// deleteOneTimeUseAccessKey deletes a one-time use key after it is validated,
+// and records an audit event for the user of the key. The delete only succeeds
+// if the key was not already deleted, so that concurrent requests with the
+// same key can not both be authenticated. ErrOneTimeAccessKeyUsed is returned
+// when the key was already deleted.
+func deleteOneTimeUseAccessKey(tx WriteTxn, key *models.AccessKey) error {
+	// The transaction used to validate an access key is not yet scoped to
+	// an organization, so use the organization of the key.
+	query := querybuilder.New("UPDATE access_keys")
+	query.B("SET deleted_at = ?, last_used_at = ?", key.LastUsedAt, key.LastUsedAt)
+	query.B("WHERE id = ? AND organization_id = ?", key.ID, key.OrganizationID)
+	query.B("AND")
+	query.B(notDeleted(accessKeyTable{}))
+	query.B("RETURNING id")
+
+	var id uid.ID
+	err := tx.QueryRow(query.String(), query.Args...).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrOneTimeAccessKeyUsed
+	case err != nil:
+		return handleError(err)
+	}
+
+	event := &models.AuditEvent{
+		OrganizationMember: models.OrganizationMember{OrganizationID: key.OrganizationID},
+		Action:             models.AuditActionDeleteAccessKey,
+		ActorID:            key.IssuedFor,
+		TargetID:           key.ID,
+		TargetName:         key.Name,
+		ProviderID:         key.ProviderID,
+	}
+	if err := CreateAuditEvent(tx, event); err != nil {
+		return fmt.Errorf("audit event: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
@@ -14,6 +15,8 @@ import (
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/generate"
 	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/testing/database"
+	"github.com/infrahq/infra/internal/testing/patch"
 	"github.com/infrahq/infra/uid"
 )
 
@@ -94,6 +97,40 @@ func TestCreateAccessKey(t *testing.T) {
 			assert.DeepEqual(t, fromDB, key, cmpTimeWithDBPrecision)
 		})
 
+		t.Run("expiry precedence", func(t *testing.T) {
+			tx := txnForTestCase(t, db, org.ID)
+
+			newKey := func(expiresAt time.Time) *models.AccessKey {
+				key := &models.AccessKey{
+					IssuedFor:  jerry.ID,
+					ProviderID: infraProviderID,
+					ExpiresAt:  expiresAt,
+				}
+				_, err := CreateAccessKey(tx, key)
+				assert.NilError(t, err)
+				return key
+			}
+			cmpExpiry := opt.TimeWithThreshold(time.Second)
+
+			// default when the organization has no setting
+			key := newKey(time.Time{})
+			assert.DeepEqual(t, key.ExpiresAt, time.Now().Add(12*time.Hour), cmpExpiry)
+
+			settings, err := GetSettings(tx)
+			assert.NilError(t, err)
+			settings.DefaultAccessKeyTTL = 72 * time.Hour
+			assert.NilError(t, SaveSettings(tx, settings))
+
+			// organization setting replaces the default
+			key = newKey(time.Time{})
+			assert.DeepEqual(t, key.ExpiresAt, time.Now().Add(72*time.Hour), cmpExpiry)
+
+			// explicit expiry takes precedence over the organization setting
+			expires := time.Now().Add(time.Hour)
+			key = newKey(expires)
+			assert.DeepEqual(t, key.ExpiresAt, expires, cmpExpiry)
+		})
+
 		t.Run("invalid specified key id length", func(t *testing.T) {
 			key := &models.AccessKey{
 				KeyID:      "too-short",
@@ -175,7 +212,500 @@ func TestValidateRequestAccessKey(t *testing.T) {
 		authorization := fmt.Sprintf("%s.%s", strings.Split(body, ".")[0], random)
 
 		_, err = ValidateRequestAccessKey(db, authorization)
-		assert.Error(t, err, "access key invalid secret")
+		assert.Error(t, err, "invalid access key")
+
+		t.Run("unknown key ID returns the same error as a bad secret", func(t *testing.T) {
+			secret := strings.Split(body, ".")[1]
+			unknown := fmt.Sprintf("%s.%s", generate.MathRandom(models.AccessKeyKeyLength, generate.CharsetAlphaNumeric), secret)
+
+			_, unknownErr := ValidateRequestAccessKey(db, unknown)
+			_, badSecretErr := ValidateRequestAccessKey(db, authorization)
+			assert.Equal(t, unknownErr, badSecretErr)
+			assert.Equal(t, unknownErr, ErrAccessKeyInvalid)
+		})
+	})
+}
+
+func TestValidateRequestAccessKey_LastUsedAt(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		body, key := createTestAccessKey(t, db, time.Hour*5)
+		assert.Assert(t, key.LastUsedAt.IsZero())
+
+		validated, err := ValidateRequestAccessKey(db, body)
+		assert.NilError(t, err)
+		assert.Assert(t, !validated.LastUsedAt.IsZero())
+
+		fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, fromDB.LastUsedAt, validated.LastUsedAt, cmpTimeWithDBPrecision)
+
+		t.Run("recent use is not written", func(t *testing.T) {
+			recent := time.Now().UTC().Add(-time.Minute)
+			_, err := db.Exec(`UPDATE access_keys SET last_used_at = ? WHERE id = ?`, recent, key.ID)
+			assert.NilError(t, err)
+
+			_, err = ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, fromDB.LastUsedAt, recent, cmpTimeWithDBPrecision)
+		})
+
+		t.Run("old use is updated", func(t *testing.T) {
+			old := time.Now().UTC().Add(-time.Hour)
+			_, err := db.Exec(`UPDATE access_keys SET last_used_at = ? WHERE id = ?`, old, key.ID)
+			assert.NilError(t, err)
+
+			_, err = ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, fromDB.LastUsedAt, time.Now(), opt.TimeWithThreshold(2*time.Second))
+		})
+	})
+}
+
+func TestValidateRequestAccessKey_ExtensionDeadline(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		user := &models.Identity{Name: "extend@example.com"}
+		assert.NilError(t, CreateIdentity(db, user))
+
+		key := &models.AccessKey{
+			IssuedFor:         user.ID,
+			ProviderID:        InfraProvider(db).ID,
+			ExpiresAt:         time.Now().Add(time.Hour).UTC(),
+			Extension:         30 * time.Minute,
+			ExtensionDeadline: time.Now().Add(30 * time.Minute).UTC(),
+		}
+		body, err := CreateAccessKey(db, key)
+		assert.NilError(t, err)
+
+		t.Run("use extends the deadline", func(t *testing.T) {
+			_, err := db.Exec(`UPDATE access_keys SET extension_deadline = ? WHERE id = ?`,
+				time.Now().Add(time.Minute).UTC(), key.ID)
+			assert.NilError(t, err)
+
+			validated, err := ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+			expected := time.Now().Add(30 * time.Minute)
+			assert.DeepEqual(t, validated.ExtensionDeadline, expected, opt.TimeWithThreshold(2*time.Second))
+		})
+
+		t.Run("use does not extend past the expiry", func(t *testing.T) {
+			expires := time.Now().Add(10 * time.Minute).UTC()
+			_, err := db.Exec(`UPDATE access_keys SET expires_at = ?, extension_deadline = ? WHERE id = ?`,
+				expires, time.Now().Add(time.Minute).UTC(), key.ID)
+			assert.NilError(t, err)
+
+			validated, err := ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, validated.ExtensionDeadline, expires, cmpTimeWithDBPrecision)
+
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, fromDB.ExtensionDeadline, expires, cmpTimeWithDBPrecision)
+		})
+	})
+}
+
+func TestValidateRequestAccessKey_ExtensionWriteThreshold(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		user := &models.Identity{Name: "threshold@example.com"}
+		assert.NilError(t, CreateIdentity(db, user))
+
+		key := &models.AccessKey{
+			IssuedFor:         user.ID,
+			ProviderID:        InfraProvider(db).ID,
+			ExpiresAt:         time.Now().Add(24 * time.Hour).UTC(),
+			Extension:         time.Hour,
+			ExtensionDeadline: time.Now().Add(time.Hour).UTC(),
+		}
+		body, err := CreateAccessKey(db, key)
+		assert.NilError(t, err)
+
+		t.Run("recent extension is not written", func(t *testing.T) {
+			recent := time.Now().Add(time.Hour - 10*time.Second).UTC()
+			_, err := db.Exec(`UPDATE access_keys SET extension_deadline = ? WHERE id = ?`, recent, key.ID)
+			assert.NilError(t, err)
+
+			_, err = ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, fromDB.ExtensionDeadline, recent, cmpTimeWithDBPrecision)
+		})
+
+		t.Run("old extension is written", func(t *testing.T) {
+			old := time.Now().Add(time.Hour - 10*time.Minute).UTC()
+			_, err := db.Exec(`UPDATE access_keys SET extension_deadline = ? WHERE id = ?`, old, key.ID)
+			assert.NilError(t, err)
+
+			_, err = ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			expected := time.Now().Add(time.Hour)
+			assert.DeepEqual(t, fromDB.ExtensionDeadline, expected, opt.TimeWithThreshold(2*time.Second))
+		})
+
+		t.Run("configured interval", func(t *testing.T) {
+			SetAccessKeyExtensionInterval(time.Second)
+			t.Cleanup(func() {
+				SetAccessKeyExtensionInterval(0)
+			})
+
+			recent := time.Now().Add(time.Hour - 10*time.Second).UTC()
+			_, err := db.Exec(`UPDATE access_keys SET extension_deadline = ? WHERE id = ?`, recent, key.ID)
+			assert.NilError(t, err)
+
+			_, err = ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			assert.Assert(t, fromDB.ExtensionDeadline.After(recent))
+		})
+	})
+}
+
+func TestValidateRequestAccessKey_Concurrent(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		user := &models.Identity{Name: "concurrent@example.com"}
+		assert.NilError(t, CreateIdentity(db, user))
+
+		key := &models.AccessKey{
+			IssuedFor:         user.ID,
+			ProviderID:        InfraProvider(db).ID,
+			ExpiresAt:         time.Now().Add(24 * time.Hour).UTC(),
+			Extension:         time.Hour,
+			ExtensionDeadline: time.Now().Add(time.Minute).UTC(),
+		}
+		body, err := CreateAccessKey(db, key)
+		assert.NilError(t, err)
+
+		// hold a lock on the key, like a concurrent request which is extending it
+		locked, err := db.Begin(context.Background(), nil)
+		assert.NilError(t, err)
+		_, err = locked.Exec(`SELECT id FROM access_keys WHERE id = ? FOR UPDATE`, key.ID)
+		assert.NilError(t, err)
+
+		const workers = 10
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				tx, err := db.Begin(context.Background(), nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer tx.Rollback() // nolint:errcheck
+
+				if _, err := ValidateRequestAccessKey(tx, body); err != nil {
+					errs <- err
+					return
+				}
+				errs <- tx.Commit()
+			}()
+		}
+
+		// none of the requests wait for the lock held by the other transaction
+		for i := 0; i < workers; i++ {
+			select {
+			case err := <-errs:
+				assert.NilError(t, err)
+			case <-time.After(10 * time.Second):
+				t.Fatal("timeout waiting for concurrent validation")
+			}
+		}
+		assert.NilError(t, locked.Rollback())
+
+		// once the lock is released the deadline is extended
+		_, err = ValidateRequestAccessKey(db, body)
+		assert.NilError(t, err)
+
+		fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+		assert.NilError(t, err)
+		expected := time.Now().Add(time.Hour)
+		assert.DeepEqual(t, fromDB.ExtensionDeadline, expected, opt.TimeWithThreshold(2*time.Second))
+	})
+}
+
+func BenchmarkValidateRequestAccessKey(b *testing.B) {
+	driver := database.PostgresDriver(b, "")
+	patch.ModelsSymmetricKey(b)
+	db, err := NewDB(driver.Dialector, NewDBOptions{})
+	assert.NilError(b, err)
+
+	user := &models.Identity{Name: "bench@example.com"}
+	assert.NilError(b, CreateIdentity(db, user))
+
+	key := &models.AccessKey{
+		IssuedFor:         user.ID,
+		ProviderID:        InfraProvider(db).ID,
+		ExpiresAt:         time.Now().Add(24 * time.Hour).UTC(),
+		Extension:         time.Hour,
+		ExtensionDeadline: time.Now().Add(time.Hour).UTC(),
+	}
+	body, err := CreateAccessKey(db, key)
+	assert.NilError(b, err)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := ValidateRequestAccessKey(db, body); err != nil {
+				b.Errorf("validate access key: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func TestValidateRequestAccessKey_OneTimeUse(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		user := &models.Identity{Name: "once@example.com"}
+		createIdentities(t, db, user)
+
+		newKey := func(t *testing.T) (string, *models.AccessKey) {
+			key := &models.AccessKey{
+				IssuedFor:  user.ID,
+				ProviderID: InfraProvider(db).ID,
+				ExpiresAt:  time.Now().Add(time.Hour),
+				OneTimeUse: true,
+			}
+			body, err := CreateAccessKey(db, key)
+			assert.NilError(t, err)
+			return body, key
+		}
+
+		t.Run("deleted after use", func(t *testing.T) {
+			body, key := newKey(t)
+
+			_, err := ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+
+			_, err = GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.ErrorIs(t, err, internal.ErrNotFound)
+
+			tx := txnForTestCase(t, db, key.OrganizationID)
+			events, err := ListAuditEvents(tx, ListAuditEventsOptions{ByTargetID: key.ID})
+			assert.NilError(t, err)
+			assert.Equal(t, len(events), 1)
+			assert.Equal(t, events[0].Action, models.AuditActionDeleteAccessKey)
+			assert.Equal(t, events[0].ActorID, user.ID)
+
+			_, err = ValidateRequestAccessKey(db, body)
+			assert.ErrorIs(t, err, ErrAccessKeyInvalid)
+		})
+
+		t.Run("concurrent use", func(t *testing.T) {
+			body, _ := newKey(t)
+
+			first, err := db.Begin(context.Background(), nil)
+			assert.NilError(t, err)
+			defer first.Rollback() // nolint:errcheck
+
+			second, err := db.Begin(context.Background(), nil)
+			assert.NilError(t, err)
+			defer second.Rollback() // nolint:errcheck
+
+			_, err = ValidateRequestAccessKey(first, body)
+			assert.NilError(t, err)
+
+			// the second validation blocks on the row lock held by the first
+			// transaction, so it must run concurrently with the commit.
+			secondErr := make(chan error, 1)
+			go func() {
+				_, err := ValidateRequestAccessKey(second, body)
+				secondErr <- err
+			}()
+
+			assert.NilError(t, first.Commit())
+			assert.ErrorIs(t, <-secondErr, ErrOneTimeAccessKeyUsed)
+		})
+	})
+}
+
+func setAccessKeyChecksumKeys(t *testing.T, keys [][]byte, allowLegacy bool) {
+	t.Helper()
+	assert.NilError(t, SetAccessKeyChecksumKeys(keys, allowLegacy))
+	t.Cleanup(func() {
+		assert.NilError(t, SetAccessKeyChecksumKeys(nil, true))
+	})
+}
+
+func TestCheckSecretChecksum(t *testing.T) {
+	secret := "the-secret-value"
+	oldKey := []byte(strings.Repeat("o", 32))
+	newKey := []byte(strings.Repeat("n", 32))
+
+	legacy := legacySecretChecksum(secret)
+
+	setAccessKeyChecksumKeys(t, [][]byte{oldKey}, true)
+	fromOldKey := secretChecksum(secret)
+	assert.Equal(t, len(fromOldKey), 1+checksumKeyIDSize+32)
+
+	type testCase struct {
+		name         string
+		keys         [][]byte
+		allowLegacy  bool
+		checksum     []byte
+		secret       string
+		expectValid  bool
+		expectUpdate bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		setAccessKeyChecksumKeys(t, tc.keys, tc.allowLegacy)
+		valid, outdated := checkSecretChecksum(tc.checksum, tc.secret)
+		assert.Equal(t, valid, tc.expectValid)
+		assert.Equal(t, outdated, tc.expectUpdate)
+	}
+
+	testCases := []testCase{
+		{
+			name:        "legacy checksum without keys",
+			allowLegacy: true,
+			checksum:    legacy,
+			secret:      secret,
+			expectValid: true,
+		},
+		{
+			name:         "legacy checksum during migration",
+			keys:         [][]byte{newKey},
+			allowLegacy:  true,
+			checksum:     legacy,
+			secret:       secret,
+			expectValid:  true,
+			expectUpdate: true,
+		},
+		{
+			name:        "keyed checksum during migration",
+			keys:        [][]byte{oldKey},
+			allowLegacy: true,
+			checksum:    fromOldKey,
+			secret:      secret,
+			expectValid: true,
+		},
+		{
+			name:     "legacy checksum after migration",
+			keys:     [][]byte{newKey},
+			checksum: legacy,
+			secret:   secret,
+		},
+		{
+			name:        "keyed checksum after migration",
+			keys:        [][]byte{oldKey},
+			checksum:    fromOldKey,
+			secret:      secret,
+			expectValid: true,
+		},
+		{
+			name:         "checksum from rotated key",
+			keys:         [][]byte{newKey, oldKey},
+			checksum:     fromOldKey,
+			secret:       secret,
+			expectValid:  true,
+			expectUpdate: true,
+		},
+		{
+			name:     "checksum from removed key",
+			keys:     [][]byte{newKey},
+			checksum: fromOldKey,
+			secret:   secret,
+		},
+		{
+			name:        "wrong secret with legacy checksum",
+			keys:        [][]byte{oldKey},
+			allowLegacy: true,
+			checksum:    legacy,
+			secret:      "not-the-secret",
+		},
+		{
+			name:        "wrong secret with keyed checksum",
+			keys:        [][]byte{oldKey},
+			allowLegacy: true,
+			checksum:    fromOldKey,
+			secret:      "not-the-secret",
+		},
+		{
+			name:        "unknown checksum format",
+			keys:        [][]byte{oldKey},
+			allowLegacy: true,
+			checksum:    append([]byte{2}, fromOldKey[1:]...),
+			secret:      secret,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestSetAccessKeyChecksumKeys(t *testing.T) {
+	t.Cleanup(func() {
+		assert.NilError(t, SetAccessKeyChecksumKeys(nil, true))
+	})
+
+	err := SetAccessKeyChecksumKeys([][]byte{[]byte("too-short")}, true)
+	assert.ErrorContains(t, err, "must be at least 32 bytes")
+
+	err = SetAccessKeyChecksumKeys(nil, false)
+	assert.ErrorContains(t, err, "can only be disabled when a checksum key is set")
+}
+
+func TestValidateRequestAccessKey_ChecksumMigration(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		oldKey := []byte(strings.Repeat("o", 32))
+		newKey := []byte(strings.Repeat("n", 32))
+
+		body, key := createTestAccessKey(t, db, time.Hour*5)
+		assert.DeepEqual(t, key.SecretChecksum, legacySecretChecksum(key.Secret))
+
+		getChecksum := func(t *testing.T) []byte {
+			t.Helper()
+			fromDB, err := GetAccessKey(db, GetAccessKeysOptions{ByID: key.ID})
+			assert.NilError(t, err)
+			return fromDB.SecretChecksum
+		}
+
+		t.Run("legacy checksum is replaced", func(t *testing.T) {
+			setAccessKeyChecksumKeys(t, [][]byte{oldKey}, true)
+
+			_, err := ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, getChecksum(t), secretChecksum(key.Secret))
+		})
+
+		t.Run("rotated checksum is replaced", func(t *testing.T) {
+			setAccessKeyChecksumKeys(t, [][]byte{newKey, oldKey}, false)
+
+			_, err := ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, getChecksum(t), secretChecksum(key.Secret))
+		})
+
+		t.Run("removed key is rejected", func(t *testing.T) {
+			setAccessKeyChecksumKeys(t, [][]byte{oldKey}, true)
+
+			_, err := ValidateRequestAccessKey(db, body)
+			assert.ErrorIs(t, err, ErrAccessKeyInvalid)
+		})
+
+		t.Run("new keys use the current key", func(t *testing.T) {
+			setAccessKeyChecksumKeys(t, [][]byte{newKey}, false)
+
+			body, key := createTestAccessKey(t, db, time.Hour*5)
+			assert.DeepEqual(t, key.SecretChecksum, secretChecksum(key.Secret))
+
+			_, err := ValidateRequestAccessKey(db, body)
+			assert.NilError(t, err)
+		})
 	})
 }
 
@@ -191,7 +721,7 @@ func TestDeleteAccessKeys(t *testing.T) {
 		createIdentities(t, db, user, otherUser)
 
 		t.Run("empty options", func(t *testing.T) {
-			err := DeleteAccessKeys(db, DeleteAccessKeysOptions{})
+			_, err := DeleteAccessKeys(db, DeleteAccessKeysOptions{})
 			assert.ErrorContains(t, err, "requires an ID to delete")
 		})
 
@@ -202,7 +732,7 @@ func TestDeleteAccessKeys(t *testing.T) {
 			toKeep := &models.AccessKey{IssuedFor: otherUser.ID, ProviderID: otherProvider.ID}
 			createAccessKeys(t, tx, key1, key2, toKeep)
 
-			err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByIssuedForID: user.ID})
+			_, err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByIssuedForID: user.ID})
 			assert.NilError(t, err)
 
 			remaining, err := ListAccessKeys(tx, ListAccessKeyOptions{})
@@ -220,7 +750,7 @@ func TestDeleteAccessKeys(t *testing.T) {
 			toKeep := &models.AccessKey{IssuedFor: user.ID, ProviderID: otherProvider.ID}
 			createAccessKeys(t, tx, key1, key2, toKeep)
 
-			err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByProviderID: provider.ID})
+			_, err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByProviderID: provider.ID})
 			assert.NilError(t, err)
 
 			remaining, err := ListAccessKeys(tx, ListAccessKeyOptions{})
@@ -237,7 +767,7 @@ func TestDeleteAccessKeys(t *testing.T) {
 			toKeep := &models.AccessKey{IssuedFor: user.ID, ProviderID: otherProvider.ID}
 			createAccessKeys(t, tx, key1, toKeep)
 
-			err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByID: key1.ID})
+			_, err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByID: key1.ID})
 			assert.NilError(t, err)
 
 			remaining, err := ListAccessKeys(tx, ListAccessKeyOptions{})
@@ -247,9 +777,77 @@ func TestDeleteAccessKeys(t *testing.T) {
 			}
 			assert.DeepEqual(t, remaining, expected, cmpModelByID)
 		})
+
+		t.Run("by name prefix", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			key1 := &models.AccessKey{Name: "ci_bot-1", IssuedFor: user.ID, ProviderID: provider.ID}
+			key2 := &models.AccessKey{Name: "ci_bot-2", IssuedFor: otherUser.ID, ProviderID: provider.ID}
+			toKeep1 := &models.AccessKey{Name: "cixbot-1", IssuedFor: user.ID, ProviderID: provider.ID}
+			toKeep2 := &models.AccessKey{Name: "other-ci_bot-1", IssuedFor: user.ID, ProviderID: provider.ID}
+			createAccessKeys(t, tx, key1, key2, toKeep1, toKeep2)
+
+			deleted, err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{
+				ByNamePrefix: "ci_bot-",
+				ActorID:      otherUser.ID,
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, len(deleted), 2)
+
+			for _, key := range []*models.AccessKey{key1, key2} {
+				events, err := ListAuditEvents(tx, ListAuditEventsOptions{ByTargetID: key.ID})
+				assert.NilError(t, err)
+				assert.Equal(t, len(events), 1)
+				assert.Equal(t, events[0].Action, models.AuditActionDeleteAccessKey)
+				assert.Equal(t, events[0].ActorID, otherUser.ID)
+				assert.Equal(t, events[0].TargetName, key.Name)
+			}
+
+			remaining, err := ListAccessKeys(tx, ListAccessKeyOptions{})
+			assert.NilError(t, err)
+			expected := []models.AccessKey{
+				{Model: models.Model{ID: toKeep1.ID}},
+				{Model: models.Model{ID: toKeep2.ID}},
+			}
+			assert.DeepEqual(t, remaining, expected, cmpModelByID)
+		})
+
+		t.Run("dry run", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			key1 := &models.AccessKey{Name: "ci_bot-1", IssuedFor: user.ID, ProviderID: provider.ID}
+			key2 := &models.AccessKey{Name: "ci_bot-2", IssuedFor: otherUser.ID, ProviderID: provider.ID}
+			other := &models.AccessKey{Name: "cixbot-1", IssuedFor: user.ID, ProviderID: provider.ID}
+			createAccessKeys(t, tx, key1, key2, other)
+
+			before, err := ListAccessKeys(tx, ListAccessKeyOptions{IncludeExpired: true})
+			assert.NilError(t, err)
+
+			matched, err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{
+				ByNamePrefix: "ci_bot-",
+				DryRun:       true,
+			})
+			assert.NilError(t, err)
+
+			expected := []models.AccessKey{
+				{Model: models.Model{ID: key1.ID}, Name: "ci_bot-1"},
+				{Model: models.Model{ID: key2.ID}, Name: "ci_bot-2"},
+			}
+			assert.DeepEqual(t, matched, expected, cmpAccessKeyIDAndName)
+
+			after, err := ListAccessKeys(tx, ListAccessKeyOptions{IncludeExpired: true})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, after, before)
+
+			events, err := ListAuditEvents(tx, ListAuditEventsOptions{ByTargetID: key1.ID})
+			assert.NilError(t, err)
+			assert.Equal(t, len(events), 0)
+		})
 	})
 }
 
+var cmpAccessKeyIDAndName = cmp.Comparer(func(x, y models.AccessKey) bool {
+	return x.ID == y.ID && x.Name == y.Name
+})
+
 func createAccessKeys(t *testing.T, db GormTxn, keys ...*models.AccessKey) {
 	t.Helper()
 	for i := range keys {
@@ -403,6 +1001,18 @@ func TestListAccessKeys(t *testing.T) {
 			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
 		})
 
+		t.Run("include deleted", func(t *testing.T) {
+			actual, err := ListAccessKeys(db, ListAccessKeyOptions{IncludeDeleted: true})
+			assert.NilError(t, err)
+
+			expected := []models.AccessKey{
+				{Model: models.Model{ID: 5}, IssuedForName: "tmp@infrahq.com"},
+				{Model: models.Model{ID: 8}, IssuedForName: "tmp@infrahq.com"},
+				{Model: models.Model{ID: 9}, IssuedForName: "admin@infrahq.com"},
+			}
+			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+		})
+
 		t.Run("by name", func(t *testing.T) {
 			actual, err := ListAccessKeys(db, ListAccessKeyOptions{ByName: "alpha"})
 			assert.NilError(t, err)
@@ -423,6 +1033,73 @@ func TestListAccessKeys(t *testing.T) {
 			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
 		})
 
+		t.Run("by provider", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+			okta := &models.Provider{Name: "okta", Kind: models.ProviderKindOkta}
+			assert.NilError(t, CreateProvider(tx, okta))
+
+			createAccessKeys(t, tx,
+				&models.AccessKey{
+					Name:       "okta-user",
+					Model:      models.Model{ID: 10},
+					IssuedFor:  user.ID,
+					ProviderID: okta.ID,
+					ExpiresAt:  time.Now().Add(time.Hour).UTC(),
+				},
+				&models.AccessKey{
+					Name:       "okta-other",
+					Model:      models.Model{ID: 11},
+					IssuedFor:  otherUser.ID,
+					ProviderID: okta.ID,
+					ExpiresAt:  time.Now().Add(time.Hour).UTC(),
+				},
+				&models.AccessKey{
+					Name:       "okta-expired",
+					Model:      models.Model{ID: 12},
+					IssuedFor:  user.ID,
+					ProviderID: okta.ID,
+					ExpiresAt:  time.Now().Add(-time.Hour).UTC(),
+				})
+
+			actual, err := ListAccessKeys(tx, ListAccessKeyOptions{ByProviderID: okta.ID})
+			assert.NilError(t, err)
+
+			expected := []models.AccessKey{
+				{Model: models.Model{ID: 11}, IssuedForName: "admin@infrahq.com"},
+				{Model: models.Model{ID: 10}, IssuedForName: "tmp@infrahq.com"},
+			}
+			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+
+			t.Run("and issued for user", func(t *testing.T) {
+				actual, err := ListAccessKeys(tx, ListAccessKeyOptions{
+					ByProviderID:  okta.ID,
+					ByIssuedForID: user.ID,
+				})
+				assert.NilError(t, err)
+
+				expected := []models.AccessKey{
+					{Model: models.Model{ID: 10}, IssuedForName: "tmp@infrahq.com"},
+				}
+				assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+			})
+
+			t.Run("and expired", func(t *testing.T) {
+				actual, err := ListAccessKeys(tx, ListAccessKeyOptions{
+					ByProviderID:   okta.ID,
+					ByIssuedForID:  user.ID,
+					IncludeExpired: true,
+				})
+				assert.NilError(t, err)
+
+				expected := []models.AccessKey{
+					{Model: models.Model{ID: 12}, IssuedForName: "tmp@infrahq.com"},
+					{Model: models.Model{ID: 10}, IssuedForName: "tmp@infrahq.com"},
+				}
+				assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+			})
+		})
+
 		t.Run("by name and expired", func(t *testing.T) {
 			actual, err := ListAccessKeys(db, ListAccessKeyOptions{
 				ByName:         "beta",
@@ -472,6 +1149,63 @@ func TestListAccessKeys(t *testing.T) {
 			assert.DeepEqual(t, page, expectedPage)
 		})
 
+		t.Run("include expired with cursor", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			page := &Pagination{Limit: 2, Cursor: &Cursor{}}
+			actual, err := ListAccessKeys(tx, ListAccessKeyOptions{
+				IncludeExpired: true,
+				Pagination:     page,
+			})
+			assert.NilError(t, err)
+
+			// keys are ordered by creation time
+			expected := []models.AccessKey{
+				{Model: models.Model{ID: 9}, IssuedForName: "admin@infrahq.com"},
+				{Model: models.Model{ID: 7}, IssuedForName: "tmp@infrahq.com"},
+			}
+			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+			assert.DeepEqual(t, page.NextCursor, &Cursor{Created: actual[1].CreatedAt, ID: 7})
+
+			// a key created between requests is not repeated, and is listed last
+			created := &models.AccessKey{
+				Name:       "aardvark",
+				IssuedFor:  user.ID,
+				ProviderID: InfraProvider(db).ID,
+				ExpiresAt:  time.Now().Add(time.Hour).UTC(),
+			}
+			createAccessKeys(t, tx, created)
+
+			page = &Pagination{Limit: 2, Cursor: page.NextCursor}
+			actual, err = ListAccessKeys(tx, ListAccessKeyOptions{
+				IncludeExpired: true,
+				Pagination:     page,
+			})
+			assert.NilError(t, err)
+
+			expected = []models.AccessKey{
+				{Model: models.Model{ID: 6}, IssuedForName: "tmp@infrahq.com"},
+				{Model: models.Model{ID: 5}, IssuedForName: "tmp@infrahq.com"},
+			}
+			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+			// the count includes keys before the cursor
+			assert.Equal(t, page.TotalCount, 5)
+			assert.Assert(t, page.NextCursor != nil)
+
+			page = &Pagination{Limit: 2, Cursor: page.NextCursor}
+			actual, err = ListAccessKeys(tx, ListAccessKeyOptions{
+				IncludeExpired: true,
+				Pagination:     page,
+			})
+			assert.NilError(t, err)
+
+			expected = []models.AccessKey{
+				{Model: models.Model{ID: created.ID}, IssuedForName: "tmp@infrahq.com"},
+			}
+			assert.DeepEqual(t, actual, expected, cmpAccessKeyShallow)
+			// the last page has no cursor
+			assert.Assert(t, page.NextCursor == nil)
+		})
+
 		t.Run("by issued for with pagination", func(t *testing.T) {
 			page := &Pagination{Page: 1, Limit: 2}
 			actual, err := ListAccessKeys(db, ListAccessKeyOptions{
@@ -511,6 +1245,44 @@ func createTestAccessKey(t *testing.T, db GormTxn, sessionDuration time.Duration
 	return body, token
 }
 
+func TestCountActiveAccessKeys(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+		user := &models.Identity{Name: "counted@example.com"}
+		assert.NilError(t, CreateIdentity(tx, user))
+		other := &models.Identity{Name: "other@example.com"}
+		assert.NilError(t, CreateIdentity(tx, other))
+
+		provider := InfraProvider(tx)
+		createKey := func(issuedFor uid.ID, expiresAt time.Time) *models.AccessKey {
+			key := &models.AccessKey{
+				IssuedFor:  issuedFor,
+				ProviderID: provider.ID,
+				ExpiresAt:  expiresAt,
+			}
+			_, err := CreateAccessKey(tx, key)
+			assert.NilError(t, err)
+			return key
+		}
+
+		createKey(user.ID, time.Now().Add(time.Hour))
+		deleted := createKey(user.ID, time.Now().Add(time.Hour))
+		createKey(user.ID, time.Now().Add(-time.Hour))
+		createKey(other.ID, time.Now().Add(time.Hour))
+
+		_, err := DeleteAccessKeys(tx, DeleteAccessKeysOptions{ByID: deleted.ID})
+		assert.NilError(t, err)
+
+		count, err := CountActiveAccessKeys(tx, user.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+
+		_, err = CountActiveAccessKeys(tx, uid.ID(9999))
+		assert.ErrorIs(t, err, internal.ErrNotFound)
+	})
+}
+
 func TestUpdateAccessKey(t *testing.T) {
 	runDBTests(t, func(t *testing.T, db *DB) {
 		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
@@ -596,11 +1368,21 @@ func TestGetAccessKey(t *testing.T) {
 		})
 
 		t.Run("not found soft deleted", func(t *testing.T) {
-			err := DeleteAccessKeys(db, DeleteAccessKeysOptions{ByID: ak.ID})
+			_, err := DeleteAccessKeys(db, DeleteAccessKeysOptions{ByID: ak.ID})
 			assert.NilError(t, err)
 
 			_, err = GetAccessKey(db, GetAccessKeysOptions{ByKeyID: ak.KeyID})
 			assert.ErrorIs(t, err, internal.ErrNotFound)
+
+			_, err = GetAccessKey(db, GetAccessKeysOptions{ByID: ak.ID})
+			assert.ErrorIs(t, err, internal.ErrNotFound)
+		})
+
+		t.Run("found soft deleted with include deleted", func(t *testing.T) {
+			actual, err := GetAccessKey(db, GetAccessKeysOptions{ByKeyID: ak.KeyID, IncludeDeleted: true})
+			assert.NilError(t, err)
+			assert.Equal(t, actual.ID, ak.ID)
+			assert.Assert(t, actual.DeletedAt.Valid)
 		})
 	})
 }
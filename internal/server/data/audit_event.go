@@ -0,0 +1,147 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/infrahq/infra/internal/logging"
+	"github.com/infrahq/infra/internal/server/data/querybuilder"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+type auditEventsTable models.AuditEvent
+
+func (a auditEventsTable) Table() string {
+	return "audit_events"
+}
+
+func (a auditEventsTable) Columns() []string {
+	return []string{"action", "actor_id", "created_at", "deleted_at", "id", "organization_id", "provider_id", "target_id", "target_name", "updated_at"}
+}
+
+func (a auditEventsTable) Values() []any {
+	return []any{a.Action, a.ActorID, a.CreatedAt, a.DeletedAt, a.ID, a.OrganizationID, a.ProviderID, a.TargetID, a.TargetName, a.UpdatedAt}
+}
+
+func (a *auditEventsTable) ScanFields() []any {
+	return []any{&a.Action, &a.ActorID, &a.CreatedAt, &a.DeletedAt, &a.ID, &a.OrganizationID, &a.ProviderID, &a.TargetID, &a.TargetName, &a.UpdatedAt}
+}
+
+// CreateAuditEvent stores the event, and writes it to the log. Use the same
+// transaction as the change being audited, so that the change is rolled back
+// if the event can not be stored. DB.OnAuditEvent is called with the event
+// after the transaction is committed.
+func CreateAuditEvent(tx WriteTxn, event *models.AuditEvent) error {
+	if event.Action == "" {
+		return fmt.Errorf("action is required")
+	}
+
+	if err := insert(tx, (*auditEventsTable)(event)); err != nil {
+		return err
+	}
+
+	logging.L.Info().
+		Str("action", event.Action).
+		Str("actorID", event.ActorID.String()).
+		Str("targetID", event.TargetID.String()).
+		Str("targetName", event.TargetName).
+		Str("providerID", event.ProviderID.String()).
+		Time("time", event.CreatedAt).
+		Msg("audit event")
+
+	notifyAuditEvent(tx, *event)
+	return nil
+}
+
+// CreateGrantAuditEvents records an audit event with action for each of the
+// grants. actorID is the identity that made the change, or zero if the change
+// was made by the server. The target name of the event is the subject,
+// privilege, and resource of the grant separated by spaces.
+func CreateGrantAuditEvents(tx WriteTxn, action string, actorID uid.ID, grants ...models.Grant) error {
+	for _, grant := range grants {
+		event := &models.AuditEvent{
+			Action:     action,
+			ActorID:    actorID,
+			TargetID:   grant.ID,
+			TargetName: fmt.Sprintf("%s %s %s", grant.Subject, grant.Privilege, grant.Resource),
+		}
+		if err := CreateAuditEvent(tx, event); err != nil {
+			return fmt.Errorf("audit event: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateAccessKeyAuditEvents records an audit event with action for each of
+// the keys. actorID is the identity that made the change, or zero if the
+// change was made by the server.
+func CreateAccessKeyAuditEvents(tx WriteTxn, action string, actorID uid.ID, keys ...models.AccessKey) error {
+	for _, key := range keys {
+		event := &models.AuditEvent{
+			Action:     action,
+			ActorID:    actorID,
+			TargetID:   key.ID,
+			TargetName: key.Name,
+			ProviderID: key.ProviderID,
+		}
+		if err := CreateAuditEvent(tx, event); err != nil {
+			return fmt.Errorf("audit event: %w", err)
+		}
+	}
+	return nil
+}
+
+// notifyAuditEvent calls the OnAuditEvent function of the DB once the event
+// is committed.
+func notifyAuditEvent(tx WriteTxn, event models.AuditEvent) {
+	switch t := tx.(type) {
+	case *Transaction:
+		if t.onAuditEvent != nil {
+			t.onCommit(func() { t.onAuditEvent(event) })
+		}
+	case *DB:
+		// statements outside of a transaction are committed immediately
+		if t.OnAuditEvent != nil {
+			t.OnAuditEvent(event)
+		}
+	}
+}
+
+type ListAuditEventsOptions struct {
+	ByTargetID uid.ID
+	ByAction   string
+}
+
+func ListAuditEvents(tx ReadTxn, opts ListAuditEventsOptions) ([]models.AuditEvent, error) {
+	table := &auditEventsTable{}
+	query := querybuilder.New("SELECT")
+	query.B(columnsForSelect(table))
+	query.B("FROM")
+	query.B(table.Table())
+	query.B("WHERE")
+	query.B(notDeleted(table))
+	query.B("AND organization_id = ?", tx.OrganizationID())
+	if opts.ByTargetID != 0 {
+		query.B("AND target_id = ?", opts.ByTargetID)
+	}
+	if opts.ByAction != "" {
+		query.B("AND action = ?", opts.ByAction)
+	}
+	query.B("ORDER BY created_at ASC, id ASC")
+
+	rows, err := tx.Query(query.String(), query.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := rows.Scan((*auditEventsTable)(&event).ScanFields()...); err != nil {
+			return nil, err
+		}
+		result = append(result, event)
+	}
+	return result, rows.Err()
+}
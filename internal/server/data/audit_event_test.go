@@ -0,0 +1,96 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+func TestCreateAuditEvent(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		t.Run("action is required", func(t *testing.T) {
+			err := CreateAuditEvent(db, &models.AuditEvent{})
+			assert.ErrorContains(t, err, "action is required")
+		})
+
+		t.Run("success", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			event := &models.AuditEvent{
+				Action:     models.AuditActionCreateAccessKey,
+				ActorID:    1234,
+				TargetID:   5678,
+				TargetName: "the-key",
+				ProviderID: 4321,
+			}
+			err := CreateAuditEvent(tx, event)
+			assert.NilError(t, err)
+
+			other := &models.AuditEvent{Action: models.AuditActionDeleteAccessKey, TargetID: 9999}
+			err = CreateAuditEvent(tx, other)
+			assert.NilError(t, err)
+
+			actual, err := ListAuditEvents(tx, ListAuditEventsOptions{ByTargetID: 5678})
+			assert.NilError(t, err)
+
+			expected := []models.AuditEvent{*event}
+			assert.DeepEqual(t, actual, expected, cmpTimeWithDBPrecision)
+		})
+
+		t.Run("access key events", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			keys := []models.AccessKey{
+				{Model: models.Model{ID: 2001}, Name: "first", ProviderID: 4321},
+				{Model: models.Model{ID: 2002}, Name: "second", ProviderID: 4321},
+			}
+			err := CreateAccessKeyAuditEvents(tx, models.AuditActionDeleteAccessKey, 1234, keys...)
+			assert.NilError(t, err)
+
+			actual, err := ListAuditEvents(tx, ListAuditEventsOptions{ByAction: models.AuditActionDeleteAccessKey})
+			assert.NilError(t, err)
+			assert.Equal(t, len(actual), 2)
+			for i, event := range actual {
+				assert.Equal(t, event.ActorID, uid.ID(1234))
+				assert.Equal(t, event.TargetID, keys[i].ID)
+				assert.Equal(t, event.TargetName, keys[i].Name)
+				assert.Equal(t, event.ProviderID, keys[i].ProviderID)
+			}
+		})
+
+		t.Run("OnAuditEvent is called after commit", func(t *testing.T) {
+			var received []string
+			db.OnAuditEvent = func(event models.AuditEvent) {
+				received = append(received, event.TargetName)
+			}
+			t.Cleanup(func() {
+				db.OnAuditEvent = nil
+			})
+
+			tx, err := db.Begin(context.Background(), nil)
+			assert.NilError(t, err)
+			tx = tx.WithOrgID(db.DefaultOrg.ID)
+			err = CreateAuditEvent(tx, &models.AuditEvent{
+				Action:     models.AuditActionCreateAccessKey,
+				TargetName: "committed",
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, len(received), 0, "called before commit")
+			assert.NilError(t, tx.Commit())
+
+			tx, err = db.Begin(context.Background(), nil)
+			assert.NilError(t, err)
+			tx = tx.WithOrgID(db.DefaultOrg.ID)
+			err = CreateAuditEvent(tx, &models.AuditEvent{
+				Action:     models.AuditActionCreateAccessKey,
+				TargetName: "rolled-back",
+			})
+			assert.NilError(t, err)
+			assert.NilError(t, tx.Rollback())
+
+			assert.DeepEqual(t, received, []string{"committed"})
+		})
+	})
+}
@@ -2,11 +2,19 @@ package data
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/infrahq/infra/internal/server/data/querybuilder"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
 )
 
+type credentialsTable models.Credential
+
+func (c credentialsTable) Table() string {
+	return "credentials"
+}
+
 func validateCredential(c *models.Credential) error {
 	switch {
 	case len(c.PasswordHash) == 0:
@@ -38,3 +46,51 @@ func GetCredential(db GormTxn, selectors ...SelectorFunc) (*models.Credential, e
 func DeleteCredential(db GormTxn, id uid.ID) error {
 	return delete[models.Credential](db, id)
 }
+
+// RecordFailedLogin counts a failed login attempt with the credential of the
+// identity, and returns the number of failed attempts in the current window.
+// The count starts again from one when the first counted attempt is older than
+// window. The update is a single statement, so that concurrent attempts are
+// all counted.
+func RecordFailedLogin(tx WriteTxn, identityID uid.ID, window time.Duration) (int, error) {
+	now := time.Now().UTC()
+	windowStart := now.Add(-window)
+
+	query := querybuilder.New("UPDATE credentials SET")
+	query.B("failed_login_attempts = CASE WHEN failed_login_window_start > ? THEN failed_login_attempts + 1 ELSE 1 END,", windowStart)
+	query.B("failed_login_window_start = CASE WHEN failed_login_window_start > ? THEN failed_login_window_start ELSE ? END", windowStart, now)
+	query.B("WHERE identity_id = ? AND organization_id = ?", identityID, tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(credentialsTable{}))
+	query.B("RETURNING failed_login_attempts")
+
+	var attempts int
+	err := tx.QueryRow(query.String(), query.Args...).Scan(&attempts)
+	return attempts, handleError(err)
+}
+
+// LockLogin rejects login attempts with the credential of the identity until
+// the time until, and starts counting failed attempts again.
+func LockLogin(tx WriteTxn, identityID uid.ID, until time.Time) error {
+	query := querybuilder.New("UPDATE credentials")
+	query.B("SET locked_until = ?, failed_login_attempts = 0, failed_login_window_start = null", until)
+	query.B("WHERE identity_id = ? AND organization_id = ?", identityID, tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(credentialsTable{}))
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return handleError(err)
+}
+
+// ResetFailedLogins removes the count of failed login attempts with the
+// credential of the identity, after a successful login.
+func ResetFailedLogins(tx WriteTxn, identityID uid.ID) error {
+	query := querybuilder.New("UPDATE credentials")
+	query.B("SET failed_login_attempts = 0, failed_login_window_start = null")
+	query.B("WHERE identity_id = ? AND organization_id = ?", identityID, tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(credentialsTable{}))
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return handleError(err)
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/server/models"
+)
+
+func TestRecordFailedLogin(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		user := &models.Identity{Name: "failed@example.com"}
+		other := &models.Identity{Name: "other@example.com"}
+		createIdentities(t, db, user, other)
+
+		for _, identity := range []*models.Identity{user, other} {
+			credential := &models.Credential{IdentityID: identity.ID, PasswordHash: []byte("hash")}
+			assert.NilError(t, CreateCredential(db, credential))
+		}
+
+		getCredential := func(t *testing.T) *models.Credential {
+			t.Helper()
+			credential, err := GetCredential(db, ByIdentityID(user.ID))
+			assert.NilError(t, err)
+			return credential
+		}
+
+		t.Run("attempts are counted for each identity", func(t *testing.T) {
+			for i := 1; i <= 3; i++ {
+				attempts, err := RecordFailedLogin(db, user.ID, time.Minute)
+				assert.NilError(t, err)
+				assert.Equal(t, attempts, i)
+			}
+
+			attempts, err := RecordFailedLogin(db, other.ID, time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 1)
+		})
+
+		t.Run("count starts again after the window", func(t *testing.T) {
+			old := time.Now().Add(-2 * time.Minute)
+			_, err := db.Exec(`UPDATE credentials SET failed_login_window_start = ? WHERE identity_id = ?`, old, user.ID)
+			assert.NilError(t, err)
+
+			attempts, err := RecordFailedLogin(db, user.ID, time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 1)
+		})
+
+		t.Run("lock resets the count", func(t *testing.T) {
+			until := time.Now().Add(time.Hour)
+			assert.NilError(t, LockLogin(db, user.ID, until))
+
+			credential := getCredential(t)
+			assert.DeepEqual(t, credential.LockedUntil, until, cmpTimeWithDBPrecision)
+			assert.Equal(t, credential.FailedLoginAttempts, 0)
+		})
+
+		t.Run("reset after success", func(t *testing.T) {
+			_, err := RecordFailedLogin(db, user.ID, time.Minute)
+			assert.NilError(t, err)
+
+			assert.NilError(t, ResetFailedLogins(db, user.ID))
+			assert.Equal(t, getCredential(t).FailedLoginAttempts, 0)
+
+			attempts, err := RecordFailedLogin(db, user.ID, time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 1)
+		})
+
+		t.Run("identity without a credential", func(t *testing.T) {
+			missing := &models.Identity{Name: "missing@example.com"}
+			createIdentities(t, db, missing)
+
+			_, err := RecordFailedLogin(db, missing.ID, time.Minute)
+			assert.ErrorContains(t, err, "not found")
+		})
+	})
+}
+
+func TestRecordFailedUnknownLogin(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		otherOrg := &models.Organization{Name: "other", Domain: "other.example.org"}
+		assert.NilError(t, CreateOrganization(db, otherOrg))
+
+		t.Run("attempts are counted for each username", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			for i := 1; i <= 3; i++ {
+				attempts, lockedUntil, err := RecordFailedUnknownLogin(tx, "nobody@example.com", time.Minute)
+				assert.NilError(t, err)
+				assert.Equal(t, attempts, i)
+				assert.Assert(t, lockedUntil.IsZero())
+			}
+
+			attempts, _, err := RecordFailedUnknownLogin(tx, "somebody@example.com", time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 1)
+
+			attempts, _, err = RecordFailedUnknownLogin(tx.WithOrgID(otherOrg.ID), "nobody@example.com", time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 1)
+		})
+
+		t.Run("count starts again after the window", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			_, _, err := RecordFailedUnknownLogin(tx, "nobody@example.com", time.Minute)
+			assert.NilError(t, err)
+
+			old := time.Now().Add(-2 * time.Minute)
+			_, err = tx.Exec(`UPDATE unknown_login_attempts SET failed_login_window_start = ? WHERE organization_id = ?`, old, db.DefaultOrg.ID)
+			assert.NilError(t, err)
+
+			attempts, _, err := RecordFailedUnknownLogin(tx, "nobody@example.com", time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 1)
+		})
+
+		t.Run("attempts are not counted while locked", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			_, _, err := RecordFailedUnknownLogin(tx, "nobody@example.com", time.Minute)
+			assert.NilError(t, err)
+
+			until := time.Now().Add(time.Hour)
+			assert.NilError(t, LockUnknownLogin(tx, "nobody@example.com", until))
+
+			attempts, lockedUntil, err := RecordFailedUnknownLogin(tx, "nobody@example.com", time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, attempts, 0)
+			assert.DeepEqual(t, lockedUntil, until, cmpTimeWithDBPrecision)
+		})
+
+		t.Run("usernames are not stored", func(t *testing.T) {
+			tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+			_, _, err := RecordFailedUnknownLogin(tx, "secret@example.com", time.Minute)
+			assert.NilError(t, err)
+
+			var count int
+			err = tx.QueryRow(`SELECT count(*) FROM unknown_login_attempts WHERE organization_id = ? AND username_hash = ?`,
+				db.DefaultOrg.ID, []byte("secret@example.com")).Scan(&count)
+			assert.NilError(t, err)
+			assert.Equal(t, count, 0)
+		})
+	})
+}
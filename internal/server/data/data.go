@@ -26,8 +26,17 @@ import (
 type NewDBOptions struct {
 	EncryptionKeyProvider EncryptionKeyProvider
 	RootKeyID             string
+
+	// NoMigrate opens the database without changing it. NewDB returns
+	// ErrMigrationsPending instead of running migrations, and an error if the
+	// database key or the default organization do not exist yet.
+	NoMigrate bool
 }
 
+// ErrMigrationsPending is returned by NewDB when NewDBOptions.NoMigrate is set,
+// and the database has migrations which have not been applied.
+var ErrMigrationsPending = errors.New("database migrations have not been applied")
+
 // NewDB creates a new database connection and runs any required database migrations
 // before returning the connection. The loadDBKey function is called after
 // initializing the schema, but before any migrations.
@@ -38,20 +47,33 @@ func NewDB(connection gorm.Dialector, dbOpts NewDBOptions) (*DB, error) {
 	}
 	dataDB := &DB{DB: db}
 
+	loadKey := func(tx migrator.DB) error {
+		if dbOpts.EncryptionKeyProvider == nil {
+			return nil
+		}
+		return loadDBKey(tx, dbOpts.EncryptionKeyProvider, dbOpts.RootKeyID, !dbOpts.NoMigrate)
+	}
 	opts := migrator.Options{
 		InitSchema: initializeSchema,
-		LoadKey: func(tx migrator.DB) error {
-			if dbOpts.EncryptionKeyProvider == nil {
-				return nil
-			}
-			return loadDBKey(tx, dbOpts.EncryptionKeyProvider, dbOpts.RootKeyID)
-		},
+		LoadKey:    loadKey,
 	}
 	m := migrator.New(dataDB, opts, migrations())
-	if err := m.Migrate(); err != nil {
+
+	if dbOpts.NoMigrate {
+		switch pending, err := m.Pending(); {
+		case err != nil:
+			return nil, fmt.Errorf("check migrations: %w", err)
+		case pending:
+			return nil, ErrMigrationsPending
+		}
+		if err := loadKey(dataDB); err != nil {
+			return nil, fmt.Errorf("load key: %w", err)
+		}
+	} else if err := m.Migrate(); err != nil {
 		return nil, fmt.Errorf("migration failed: %w", err)
 	}
-	if err := initialize(dataDB); err != nil {
+
+	if err := initialize(dataDB, !dbOpts.NoMigrate); err != nil {
 		return nil, fmt.Errorf("initialize database: %w", err)
 	}
 
@@ -66,6 +88,10 @@ type DB struct {
 	DefaultOrg *models.Organization
 	// DefaultOrgSettings are the settings for DefaultOrg
 	DefaultOrgSettings *models.Settings
+
+	// OnAuditEvent is called with each audit event after the transaction
+	// that stored the event is committed. It must not block.
+	OnAuditEvent func(event models.AuditEvent)
 }
 
 func (d *DB) Close() error {
@@ -76,6 +102,15 @@ func (d *DB) Close() error {
 	return sqlDB.Close()
 }
 
+// Ping checks that the database is reachable.
+func (d *DB) Ping(ctx context.Context) error {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 func (d *DB) SQLdb() *sql.DB {
 	sqlDB, err := d.DB.DB()
 	if err != nil {
@@ -111,13 +146,19 @@ func (d *DB) GormDB() *gorm.DB {
 	return d.DB
 }
 
-// TODO: accept sql.TxOptions when we remove gorm
-func (d *DB) Begin(ctx context.Context) (*Transaction, error) {
-	tx := d.DB.WithContext(ctx).Begin()
+// Begin starts a transaction. opts may be nil to use the default isolation
+// level of the database.
+func (d *DB) Begin(ctx context.Context, opts *sql.TxOptions) (*Transaction, error) {
+	tx := d.DB.WithContext(ctx).Begin(opts)
 	if err := tx.Error; err != nil {
 		return nil, err
 	}
-	return &Transaction{DB: tx, committed: new(atomic.Bool)}, nil
+	return &Transaction{
+		DB:           tx,
+		committed:    new(atomic.Bool),
+		afterCommit:  new([]func()),
+		onAuditEvent: d.OnAuditEvent,
+	}, nil
 }
 
 type WriteTxn interface {
@@ -147,6 +188,13 @@ type Transaction struct {
 	*gorm.DB
 	orgID     uid.ID
 	committed *atomic.Bool
+	// afterCommit are the functions called after the transaction is committed.
+	afterCommit *[]func()
+	// onAuditEvent is DB.OnAuditEvent
+	onAuditEvent func(event models.AuditEvent)
+	// unscoped disables the check that queries of tenant tables filter by
+	// organization_id. See allowUnscoped.
+	unscoped bool
 }
 
 func (t *Transaction) DriverName() string {
@@ -158,15 +206,18 @@ func (t *Transaction) OrganizationID() uid.ID {
 }
 
 func (t *Transaction) Exec(query string, args ...any) (sql.Result, error) {
+	t.guardOrganizationScope(query)
 	db := t.DB.Exec(query, args...)
 	return driver.RowsAffected(db.RowsAffected), db.Error
 }
 
 func (t *Transaction) Query(query string, args ...any) (*sql.Rows, error) {
+	t.guardOrganizationScope(query)
 	return t.DB.Raw(query, args...).Rows()
 }
 
 func (t *Transaction) QueryRow(query string, args ...any) *sql.Row {
+	t.guardOrganizationScope(query)
 	return t.DB.Raw(query, args...).Row()
 }
 
@@ -185,10 +236,38 @@ func (t *Transaction) Rollback() error {
 
 func (t *Transaction) Commit() error {
 	err := t.DB.Commit().Error
-	if err == nil {
-		t.committed.Store(true)
+	if err != nil {
+		return err
 	}
-	return err
+	t.committed.Store(true)
+
+	if t.afterCommit != nil {
+		for _, fn := range *t.afterCommit {
+			fn()
+		}
+		*t.afterCommit = nil
+	}
+	return nil
+}
+
+// onCommit calls fn after the transaction is committed. fn is not called if
+// the transaction is rolled back.
+func (t *Transaction) onCommit(fn func()) {
+	if t.afterCommit == nil {
+		return
+	}
+	*t.afterCommit = append(*t.afterCommit, fn)
+}
+
+// OnCommit calls fn after the changes made with tx are committed. When tx is
+// not a transaction the changes have already been committed, so fn is called
+// immediately.
+func OnCommit(tx ReadTxn, fn func()) {
+	if t, ok := tx.(*Transaction); ok && t.afterCommit != nil {
+		t.onCommit(fn)
+		return
+	}
+	fn()
 }
 
 // WithOrgID returns a shallow copy of the Transaction with the OrganizationID
@@ -224,8 +303,10 @@ func newRawDB(connection gorm.Dialector) (*gorm.DB, error) {
 
 const defaultOrganizationID = 1000
 
-func initialize(db *DB) error {
-	tx, err := db.Begin(context.TODO())
+// initialize loads the default organization and its settings. When create is
+// true, the default organization is created if it does not exist.
+func initialize(db *DB, create bool) error {
+	tx, err := db.Begin(context.TODO(), nil)
 	if err != nil {
 		return err
 	}
@@ -233,6 +314,8 @@ func initialize(db *DB) error {
 
 	org, err := GetOrganization(tx, ByID(defaultOrganizationID))
 	switch {
+	case errors.Is(err, internal.ErrNotFound) && !create:
+		return fmt.Errorf("default organization does not exist")
 	case errors.Is(err, internal.ErrNotFound):
 		org = &models.Organization{
 			Model:     models.Model{ID: defaultOrganizationID},
@@ -365,6 +448,10 @@ func add[T models.Modelable](tx GormTxn, model *T) error {
 	return handleError(err)
 }
 
+// ErrWriteConflict is returned when a write conflicts with a concurrent
+// transaction. The operation may succeed when it is retried.
+var ErrWriteConflict = errors.New("conflict with a concurrent write")
+
 type UniqueConstraintError struct {
 	Table  string
 	Column string
@@ -427,6 +514,8 @@ func handleError(err error) error {
 
 			columnName := constraintFields[pgErr.ConstraintName]
 			return UniqueConstraintError{Table: pgErr.TableName, Column: columnName}
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected, pgerrcode.LockNotAvailable:
+			return fmt.Errorf("%w: %v", ErrWriteConflict, err)
 		}
 	}
 
@@ -2,6 +2,8 @@ package data
 
 import (
 	"context"
+	"database/sql"
+	"os"
 	"testing"
 
 	"github.com/rs/zerolog"
@@ -16,6 +18,12 @@ import (
 	"github.com/infrahq/infra/uid"
 )
 
+func TestMain(m *testing.M) {
+	// fail any test that queries a tenant table without an organization
+	panicOnUnscopedQuery = true
+	os.Exit(m.Run())
+}
+
 func setupDB(t *testing.T, driver gorm.Dialector) *DB {
 	t.Helper()
 	patch.ModelsSymmetricKey(t)
@@ -30,7 +38,7 @@ func setupDB(t *testing.T, driver gorm.Dialector) *DB {
 
 func txnForTestCase(t *testing.T, db *DB, orgID uid.ID) *Transaction {
 	t.Helper()
-	tx, err := db.Begin(context.Background())
+	tx, err := db.Begin(context.Background(), nil)
 	assert.NilError(t, err)
 	t.Cleanup(func() {
 		_ = tx.Rollback()
@@ -169,11 +177,37 @@ func TestNewDB(t *testing.T) {
 	})
 }
 
+func TestNewDB_NoMigrate(t *testing.T) {
+	driver := database.PostgresDriver(t, "_no_migrate")
+	patch.ModelsSymmetricKey(t)
+
+	_, err := NewDB(driver.Dialector, NewDBOptions{NoMigrate: true})
+	assert.ErrorIs(t, err, ErrMigrationsPending)
+
+	db, err := NewDB(driver.Dialector, NewDBOptions{})
+	assert.NilError(t, err)
+	assert.NilError(t, db.Close())
+
+	db, err = NewDB(driver.Dialector, NewDBOptions{NoMigrate: true})
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		assert.NilError(t, db.Close())
+	})
+	assert.Equal(t, db.DefaultOrg.ID, uid.ID(defaultOrganizationID))
+
+	// a migration which has not been applied
+	all := migrations()
+	_, err = db.Exec(`DELETE FROM migrations WHERE id = ?`, all[len(all)-1].ID)
+	assert.NilError(t, err)
+	_, err = NewDB(driver.Dialector, NewDBOptions{NoMigrate: true})
+	assert.ErrorIs(t, err, ErrMigrationsPending)
+}
+
 func TestDB_Begin(t *testing.T) {
 	runDBTests(t, func(t *testing.T, db *DB) {
 		t.Run("rollback", func(t *testing.T) {
 			ctx := context.Background()
-			tx, err := db.Begin(ctx)
+			tx, err := db.Begin(ctx, nil)
 			assert.NilError(t, err)
 			tx = tx.WithOrgID(db.DefaultOrg.ID)
 
@@ -193,7 +227,7 @@ func TestDB_Begin(t *testing.T) {
 		})
 		t.Run("commit", func(t *testing.T) {
 			ctx := context.Background()
-			tx, err := db.Begin(ctx)
+			tx, err := db.Begin(ctx, nil)
 			assert.NilError(t, err)
 			tx = tx.WithOrgID(db.DefaultOrg.ID)
 
@@ -211,5 +245,26 @@ func TestDB_Begin(t *testing.T) {
 			_, err = GetIdentity(db, ByID(user.ID))
 			assert.NilError(t, err)
 		})
+		t.Run("repeatable read, read only", func(t *testing.T) {
+			ctx := context.Background()
+			tx, err := db.Begin(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+			assert.NilError(t, err)
+			tx = tx.WithOrgID(db.DefaultOrg.ID)
+			t.Cleanup(func() { _ = tx.Rollback() })
+
+			users, err := ListIdentities(tx, nil)
+			assert.NilError(t, err)
+
+			// a commit after the first query is not visible to the tx
+			err = CreateIdentity(db, &models.Identity{Name: "later@example.com"})
+			assert.NilError(t, err)
+
+			again, err := ListIdentities(tx, nil)
+			assert.NilError(t, err)
+			assert.Equal(t, len(again), len(users))
+
+			err = CreateIdentity(tx, &models.Identity{Name: "readonly@example.com"})
+			assert.ErrorContains(t, err, "read-only transaction")
+		})
 	})
 }
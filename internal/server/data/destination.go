@@ -5,10 +5,17 @@ import (
 	"time"
 
 	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/server/data/querybuilder"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
 )
 
+type destinationsTable models.Destination
+
+func (d destinationsTable) Table() string {
+	return "destinations"
+}
+
 func validateDestination(dest *models.Destination) error {
 	if dest.Name == "" {
 		return fmt.Errorf("name is required")
@@ -30,6 +37,66 @@ func SaveDestination(db GormTxn, destination *models.Destination) error {
 	return save(db, destination)
 }
 
+// DestinationPatch is a partial update of a destination. Fields that are nil
+// are not changed.
+type DestinationPatch struct {
+	Name          *string
+	UniqueID      *string
+	Version       *string
+	ConnectionURL *string
+	ConnectionCA  *string
+	Resources     *models.CommaSeparatedStrings
+	Roles         *models.CommaSeparatedStrings
+}
+
+// PatchDestination updates only the columns of the destination that are set in
+// patch, so that a concurrent update of the other columns is not overwritten.
+func PatchDestination(tx WriteTxn, id uid.ID, patch DestinationPatch) error {
+	if patch.Name != nil && *patch.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	query := querybuilder.New("UPDATE destinations")
+	query.B("SET updated_at = ?", time.Now())
+	if patch.Name != nil {
+		query.B(", name = ?", *patch.Name)
+	}
+	if patch.UniqueID != nil {
+		query.B(", unique_id = ?", *patch.UniqueID)
+	}
+	if patch.Version != nil {
+		query.B(", version = ?", *patch.Version)
+	}
+	if patch.ConnectionURL != nil {
+		query.B(", connection_url = ?", *patch.ConnectionURL)
+	}
+	if patch.ConnectionCA != nil {
+		query.B(", connection_ca = ?", *patch.ConnectionCA)
+	}
+	if patch.Resources != nil {
+		query.B(", resources = ?", *patch.Resources)
+	}
+	if patch.Roles != nil {
+		query.B(", roles = ?", *patch.Roles)
+	}
+	query.B("WHERE id = ? AND organization_id = ?", id, tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(destinationsTable{}))
+
+	result, err := tx.Exec(query.String(), query.Args...)
+	if err != nil {
+		return handleError(err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return internal.ErrNotFound
+	}
+	return nil
+}
+
 func GetDestination(db GormTxn, selectors ...SelectorFunc) (*models.Destination, error) {
 	return get[models.Destination](db, selectors...)
 }
@@ -73,7 +140,7 @@ func CountDestinationsByConnectedVersion(tx ReadTxn) ([]destinationsCount, error
 			WHERE deleted_at IS NULL
 		) AS d
 		GROUP BY version, connected`
-	rows, err := tx.Query(stmt, timeout)
+	rows, err := allowUnscoped(tx).Query(stmt, timeout)
 	if err != nil {
 		return nil, err
 
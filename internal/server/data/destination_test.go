@@ -6,6 +6,7 @@ import (
 
 	"gotest.tools/v3/assert"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/models"
 )
 
@@ -31,6 +32,39 @@ func TestDestinationSaveCreatedPersists(t *testing.T) {
 	})
 }
 
+func TestPatchDestination(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		destination := &models.Destination{
+			Name:     "example-cluster-1",
+			UniqueID: "1",
+			Version:  "0.16.0",
+			Roles:    []string{"admin", "view"},
+		}
+		assert.NilError(t, CreateDestination(db, destination))
+
+		// a concurrent write of a field that is not in the patch is kept
+		destination.LastSeenAt = time.Now().Truncate(time.Second).UTC()
+		assert.NilError(t, SaveDestination(db, destination))
+
+		version := "0.17.0"
+		roles := models.CommaSeparatedStrings{}
+		err := PatchDestination(db, destination.ID, DestinationPatch{Version: &version, Roles: &roles})
+		assert.NilError(t, err)
+
+		actual, err := GetDestination(db, ByID(destination.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, actual.Name, "example-cluster-1")
+		assert.Equal(t, actual.Version, "0.17.0")
+		assert.Equal(t, len(actual.Roles), 0)
+		assert.Assert(t, actual.LastSeenAt.Equal(destination.LastSeenAt))
+
+		t.Run("not found", func(t *testing.T) {
+			err := PatchDestination(db, 1234, DestinationPatch{Version: &version})
+			assert.ErrorIs(t, err, internal.ErrNotFound)
+		})
+	})
+}
+
 func TestCountDestinationsByConnectedVersion(t *testing.T) {
 	runDBTests(t, func(t *testing.T, db *DB) {
 		assert.NilError(t, CreateDestination(db, &models.Destination{Name: "1", UniqueID: "1", LastSeenAt: time.Now()}))
@@ -52,3 +86,39 @@ func TestCountDestinationsByConnectedVersion(t *testing.T) {
 		assert.DeepEqual(t, actual, expected)
 	})
 }
+
+func TestUpdateDestinationResourceVersion(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		destination := &models.Destination{Name: "example-cluster-1", UniqueID: "1"}
+		assert.NilError(t, CreateDestination(db, destination))
+		assert.Equal(t, destination.ResourceVersion, int64(0))
+
+		err := UpdateDestinationResourceVersion(db, destination, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, destination.ResourceVersion, int64(1))
+
+		expected := int64(1)
+		err = UpdateDestinationResourceVersion(db, destination, &expected)
+		assert.NilError(t, err)
+		assert.Equal(t, destination.ResourceVersion, int64(2))
+
+		// saving the destination does not change the version
+		destination.ResourceVersion = 0
+		assert.NilError(t, SaveDestination(db, destination))
+
+		actual, err := GetDestination(db, ByID(destination.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, actual.ResourceVersion, int64(2))
+
+		t.Run("stale version", func(t *testing.T) {
+			stale := int64(1)
+			err := UpdateDestinationResourceVersion(db, actual, &stale)
+			assert.ErrorIs(t, err, ErrResourceVersionConflict)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			err := UpdateDestinationResourceVersion(db, &models.Destination{Model: models.Model{ID: 12345}}, nil)
+			assert.ErrorIs(t, err, internal.ErrNotFound)
+		})
+	})
+}
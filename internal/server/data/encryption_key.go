@@ -68,7 +68,8 @@ func GetEncryptionKeyByName(tx StdlibTxn, name string) (*models.EncryptionKey, e
 	query := querybuilder.New("SELECT")
 	query.B(columnsForSelect(table))
 	query.B("FROM encryption_keys")
-	query.B("WHERE deleted_at is null")
+	query.B("WHERE")
+	query.B(notDeleted(table))
 	query.B("AND name = ?", name)
 
 	row := tx.QueryRow(query.String(), query.Args...)
@@ -85,10 +86,12 @@ type EncryptionKeyProvider interface {
 
 var dbKeyName = "dbkey"
 
-func loadDBKey(tx StdlibTxn, provider EncryptionKeyProvider, rootKeyId string) error {
+// loadDBKey sets models.SymmetricKey to the database key. When the key does
+// not exist and create is true, a new key is created.
+func loadDBKey(tx StdlibTxn, provider EncryptionKeyProvider, rootKeyId string, create bool) error {
 	keyRec, err := GetEncryptionKeyByName(tx, dbKeyName)
 	if err != nil {
-		if errors.Is(err, internal.ErrNotFound) {
+		if errors.Is(err, internal.ErrNotFound) && create {
 			return createDBKey(tx, provider, rootKeyId)
 		}
 		return err
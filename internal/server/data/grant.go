@@ -1,6 +1,7 @@
 package data
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
@@ -20,15 +21,15 @@ func (g grantsTable) Table() string {
 }
 
 func (g grantsTable) Columns() []string {
-	return []string{"created_at", "created_by", "deleted_at", "id", "organization_id", "privilege", "resource", "subject", "updated_at"}
+	return []string{"created_at", "created_by", "deleted_at", "expires_at", "id", "organization_id", "privilege", "resource", "subject", "updated_at"}
 }
 
 func (g grantsTable) Values() []any {
-	return []any{g.CreatedAt, g.CreatedBy, g.DeletedAt, g.ID, g.OrganizationID, g.Privilege, g.Resource, g.Subject, g.UpdatedAt}
+	return []any{g.CreatedAt, g.CreatedBy, g.DeletedAt, g.ExpiresAt, g.ID, g.OrganizationID, g.Privilege, g.Resource, g.Subject, g.UpdatedAt}
 }
 
 func (g *grantsTable) ScanFields() []any {
-	return []any{&g.CreatedAt, &g.CreatedBy, &g.DeletedAt, &g.ID, &g.OrganizationID, &g.Privilege, &g.Resource, &g.Subject, &g.UpdatedAt}
+	return []any{&g.CreatedAt, &g.CreatedBy, &g.DeletedAt, &g.ExpiresAt, &g.ID, &g.OrganizationID, &g.Privilege, &g.Resource, &g.Subject, &g.UpdatedAt}
 }
 
 func CreateGrant(tx WriteTxn, grant *models.Grant) error {
@@ -49,6 +50,12 @@ func CreateGrant(tx WriteTxn, grant *models.Grant) error {
 			return err
 		}
 	}
+	// an expired grant that has not been removed yet would conflict with the
+	// new grant, remove it first
+	if err := deleteExpiredGrant(tx, grant); err != nil {
+		_, _ = tx.Exec("ROLLBACK TO SAVEPOINT beforeCreate")
+		return err
+	}
 	if err := insert(tx, (*grantsTable)(grant)); err != nil {
 		_, _ = tx.Exec("ROLLBACK TO SAVEPOINT beforeCreate")
 		return handleError(err)
@@ -57,6 +64,18 @@ func CreateGrant(tx WriteTxn, grant *models.Grant) error {
 	return nil
 }
 
+func deleteExpiredGrant(tx WriteTxn, grant *models.Grant) error {
+	now := time.Now()
+	query := querybuilder.New("UPDATE grants")
+	query.B("SET deleted_at = ?", now)
+	query.B("WHERE organization_id = ? AND", tx.OrganizationID())
+	query.B(notDeleted(grantsTable{}))
+	query.B("AND expires_at <= ?", now)
+	query.B("AND subject = ? AND privilege = ? AND resource = ?", grant.Subject, grant.Privilege, grant.Resource)
+	_, err := tx.Exec(query.String(), query.Args...)
+	return err
+}
+
 func isPgErrorCode(err error, code string) bool {
 	pgError := &pgconn.PgError{}
 	return errors.As(err, &pgError) && pgError.Code == code
@@ -84,7 +103,9 @@ func GetGrant(tx ReadTxn, opts GetGrantOptions) (*models.Grant, error) {
 	query.B(columnsForSelect(table))
 	query.B("FROM grants")
 	query.B("WHERE organization_id = ?", tx.OrganizationID())
-	query.B("AND deleted_at is null")
+	query.B("AND")
+	query.B(notDeleted(table))
+	query.B("AND (expires_at is null OR expires_at > ?)", time.Now())
 
 	switch {
 	case opts.ByID != 0:
@@ -109,6 +130,13 @@ type ListGrantsOptions struct {
 	ByPrivileges []string
 	ByResource   string
 
+	// IncludeWildcardResources instructs ListGrants to include grants with a
+	// wildcard resource that matches ByResource. A wildcard resource ends with
+	// ".*" and matches any resource that starts with the same prefix. For
+	// example, a grant for "kubernetes.*" matches "kubernetes.prod" and
+	// "kubernetes.prod.default", but not "kubernetes".
+	IncludeWildcardResources bool
+
 	// IncludeInheritedFromGroups instructs ListGrants to include grants from
 	// groups where the user is a member. This option can only be used when
 	// BySubject is a non-zero userID.
@@ -121,16 +149,28 @@ type ListGrantsOptions struct {
 	Pagination *Pagination
 }
 
+// ListGrants returns the grants that match opts, ordered by creation time and
+// then ID. When opts.Pagination has a Cursor, only the grants created after the
+// cursor are returned.
 func ListGrants(tx ReadTxn, opts ListGrantsOptions) ([]models.Grant, error) {
+	cursor := opts.Pagination.after()
+
 	table := grantsTable{}
 	query := querybuilder.New("SELECT")
+	if cursor != nil {
+		// select the page from a subquery, so that the count includes the
+		// grants before the cursor.
+		query.B("* FROM (SELECT")
+	}
 	query.B(columnsForSelect(table))
 	if opts.Pagination != nil {
 		query.B(", count(*) OVER()")
 	}
 	query.B("FROM grants")
-	query.B("WHERE deleted_at is null")
+	query.B("WHERE")
+	query.B(notDeleted(table))
 	query.B("AND organization_id = ?", tx.OrganizationID())
+	query.B("AND (expires_at is null OR expires_at > ?)", time.Now())
 
 	if opts.BySubject != "" {
 		if !opts.IncludeInheritedFromGroups {
@@ -158,13 +198,21 @@ func ListGrants(tx ReadTxn, opts ListGrantsOptions) ([]models.Grant, error) {
 		query.B("AND privilege IN (?)", opts.ByPrivileges)
 	}
 	if opts.ByResource != "" {
-		query.B("AND resource = ?", opts.ByResource)
+		if opts.IncludeWildcardResources {
+			query.B("AND (resource = ?", opts.ByResource)
+			query.B("OR (resource LIKE '%.*' AND left(?, length(resource) - 1) = left(resource, -1)))", opts.ByResource)
+		} else {
+			query.B("AND resource = ?", opts.ByResource)
+		}
 	}
 	if opts.ExcludeConnectorGrant {
 		query.B("AND NOT (privilege = 'connector' AND resource = 'infra')")
 	}
 
-	query.B("ORDER BY id ASC")
+	if cursor != nil {
+		query.B(") AS grants WHERE (created_at, id) > (?, ?)", cursor.Created, cursor.ID)
+	}
+	query.B("ORDER BY created_at ASC, id ASC")
 	if opts.Pagination != nil {
 		opts.Pagination.PaginateQuery(query)
 	}
@@ -188,7 +236,33 @@ func ListGrants(tx ReadTxn, opts ListGrantsOptions) ([]models.Grant, error) {
 		}
 		result = append(result, grant)
 	}
-	return result, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if p := opts.Pagination; p != nil && p.hasNextPage(len(result)) {
+		result = result[:p.Limit]
+		last := result[len(result)-1]
+		p.NextCursor = &Cursor{Created: last.CreatedAt, ID: last.ID}
+	}
+	return result, nil
+}
+
+// DeleteExpiredGrants removes the grants which have expired from every
+// organization, and returns the number of grants removed. Expired grants are
+// already excluded from GetGrant and ListGrants, removing them keeps the grants
+// table from growing without bound.
+func DeleteExpiredGrants(tx WriteTxn) (int64, error) {
+	now := time.Now()
+	query := querybuilder.New("UPDATE grants")
+	query.B("SET deleted_at = ? WHERE", now)
+	query.B(notDeleted(grantsTable{}))
+	query.B("AND expires_at <= ?", now)
+	result, err := allowUnscoped(tx).Exec(query.String(), query.Args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 type DeleteGrantsOptions struct {
@@ -207,13 +281,21 @@ type DeleteGrantsOptions struct {
 	// match ByCreatedBy.
 	// Can only be used with ByCreatedBy.
 	NotIDs []uid.ID
+
+	// ActorID is the user who deleted the grants, and is recorded in the
+	// audit event for each deleted grant. Zero means the grants were deleted
+	// by the server.
+	ActorID uid.ID
 }
 
+// DeleteGrants soft-deletes the grants that match opts, and records an audit
+// event for each grant that was deleted.
 func DeleteGrants(tx WriteTxn, opts DeleteGrantsOptions) error {
 	query := querybuilder.New("UPDATE grants")
 	query.B("SET deleted_at = ?", time.Now())
 	query.B("WHERE organization_id = ? AND", tx.OrganizationID())
-	query.B("deleted_at is null AND")
+	query.B(notDeleted(grantsTable{}))
+	query.B("AND")
 
 	switch {
 	case opts.ByID != 0:
@@ -228,7 +310,31 @@ func DeleteGrants(tx WriteTxn, opts DeleteGrantsOptions) error {
 	default:
 		return fmt.Errorf("DeleteGrants requires an ID to delete")
 	}
+	query.B("RETURNING id, subject, privilege, resource")
 
-	_, err := tx.Exec(query.String(), query.Args...)
-	return err
+	rows, err := tx.Query(query.String(), query.Args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := scanDeletedGrants(rows)
+	if err != nil {
+		return err
+	}
+	return CreateGrantAuditEvents(tx, models.AuditActionDeleteGrant, opts.ActorID, deleted...)
+}
+
+// scanDeletedGrants reads and closes rows, so that the connection can be used
+// for the audit events.
+func scanDeletedGrants(rows *sql.Rows) ([]models.Grant, error) {
+	defer rows.Close()
+
+	var result []models.Grant
+	for rows.Next() {
+		var grant models.Grant
+		if err := rows.Scan(&grant.ID, &grant.Subject, &grant.Privilege, &grant.Resource); err != nil {
+			return nil, err
+		}
+		result = append(result, grant)
+	}
+	return result, rows.Err()
 }
@@ -119,9 +119,18 @@ func TestDeleteGrants(t *testing.T) {
 			otherOrgGrant := &models.Grant{Subject: "i:any1", Privilege: "view", Resource: "any"}
 			createGrants(t, tx.WithOrgID(otherOrg.ID), otherOrgGrant)
 
-			err := DeleteGrants(tx, DeleteGrantsOptions{BySubject: grant1.Subject})
+			err := DeleteGrants(tx, DeleteGrantsOptions{BySubject: grant1.Subject, ActorID: 4321})
 			assert.NilError(t, err)
 
+			for _, grant := range []*models.Grant{grant1, grant2} {
+				events, err := ListAuditEvents(tx, ListAuditEventsOptions{ByTargetID: grant.ID})
+				assert.NilError(t, err)
+				assert.Equal(t, len(events), 1)
+				assert.Equal(t, events[0].Action, models.AuditActionDeleteGrant)
+				assert.Equal(t, events[0].ActorID, uid.ID(4321))
+				assert.Equal(t, events[0].TargetName, "i:any1 "+grant.Privilege+" any")
+			}
+
 			actual, err := ListGrants(tx, ListGrantsOptions{ByResource: "any"})
 			assert.NilError(t, err)
 			expected := []models.Grant{
@@ -160,6 +169,60 @@ func TestDeleteGrants(t *testing.T) {
 	})
 }
 
+func TestDeleteExpiredGrants(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+		past := time.Now().Add(-time.Minute)
+		future := time.Now().Add(time.Hour)
+		expired := &models.Grant{Subject: "i:expired", Privilege: "view", Resource: "any", ExpiresAt: &past}
+		expiring := &models.Grant{Subject: "i:expiring", Privilege: "view", Resource: "any", ExpiresAt: &future}
+		permanent := &models.Grant{Subject: "i:permanent", Privilege: "view", Resource: "any"}
+		createGrants(t, tx, expired, expiring, permanent)
+
+		t.Run("expired grants are excluded", func(t *testing.T) {
+			actual, err := ListGrants(tx, ListGrantsOptions{ByResource: "any"})
+			assert.NilError(t, err)
+			expected := []models.Grant{
+				{Model: models.Model{ID: expiring.ID}},
+				{Model: models.Model{ID: permanent.ID}},
+			}
+			assert.DeepEqual(t, actual, expected, cmpModelByID)
+
+			_, err = GetGrant(tx, GetGrantOptions{ByID: expired.ID})
+			assert.ErrorIs(t, err, internal.ErrNotFound)
+		})
+
+		t.Run("expired grants are deleted", func(t *testing.T) {
+			count, err := DeleteExpiredGrants(tx)
+			assert.NilError(t, err)
+			assert.Equal(t, count, int64(1))
+
+			var deletedAt *time.Time
+			err = tx.QueryRow(`SELECT deleted_at FROM grants WHERE id = ?`, expired.ID).Scan(&deletedAt)
+			assert.NilError(t, err)
+			assert.Assert(t, deletedAt != nil)
+
+			actual, err := ListGrants(tx, ListGrantsOptions{ByResource: "any"})
+			assert.NilError(t, err)
+			assert.Equal(t, len(actual), 2)
+		})
+
+		t.Run("create replaces an expired grant", func(t *testing.T) {
+			past := time.Now().Add(-time.Second)
+			grant := &models.Grant{Subject: "i:again", Privilege: "view", Resource: "any", ExpiresAt: &past}
+			createGrants(t, tx, grant)
+
+			again := &models.Grant{Subject: "i:again", Privilege: "view", Resource: "any"}
+			createGrants(t, tx, again)
+
+			actual, err := GetGrant(tx, GetGrantOptions{BySubject: "i:again", ByPrivilege: "view", ByResource: "any"})
+			assert.NilError(t, err)
+			assert.Equal(t, actual.ID, again.ID)
+		})
+	})
+}
+
 func createGrants(t *testing.T, tx WriteTxn, grants ...*models.Grant) {
 	t.Helper()
 	for _, grant := range grants {
@@ -260,6 +323,75 @@ func TestGetGrant(t *testing.T) {
 	})
 }
 
+func TestListGrants_WildcardResources(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+		exact := &models.Grant{
+			Subject:   "i:userchar",
+			Privilege: "view",
+			Resource:  "kubernetes.prod",
+			CreatedBy: uid.ID(777),
+		}
+		wildcard := &models.Grant{
+			Subject:   "i:userchar",
+			Privilege: "admin",
+			Resource:  "kubernetes.*",
+			CreatedBy: uid.ID(777),
+		}
+		other := &models.Grant{
+			Subject:   "i:userchar",
+			Privilege: "admin",
+			Resource:  "kube.*",
+			CreatedBy: uid.ID(777),
+		}
+		createGrants(t, tx, exact, wildcard, other)
+
+		type testCase struct {
+			name     string
+			opts     ListGrantsOptions
+			expected []models.Grant
+		}
+
+		testCases := []testCase{
+			{
+				name:     "exact match without wildcards",
+				opts:     ListGrantsOptions{ByResource: "kubernetes.prod"},
+				expected: []models.Grant{*exact},
+			},
+			{
+				name:     "exact and wildcard match",
+				opts:     ListGrantsOptions{ByResource: "kubernetes.prod", IncludeWildcardResources: true},
+				expected: []models.Grant{*exact, *wildcard},
+			},
+			{
+				name:     "wildcard match",
+				opts:     ListGrantsOptions{ByResource: "kubernetes.staging.default", IncludeWildcardResources: true},
+				expected: []models.Grant{*wildcard},
+			},
+			{
+				name:     "wildcard does not match the prefix",
+				opts:     ListGrantsOptions{ByResource: "kubernetes", IncludeWildcardResources: true},
+				expected: nil,
+			},
+			{
+				name:     "wildcard does not match infra",
+				opts:     ListGrantsOptions{ByResource: "infra", IncludeWildcardResources: true},
+				expected: nil,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.opts.BySubject = "i:userchar"
+				actual, err := ListGrants(tx, tc.opts)
+				assert.NilError(t, err)
+				assert.DeepEqual(t, actual, tc.expected, cmpModelByID)
+			})
+		}
+	})
+}
+
 func TestListGrants(t *testing.T) {
 	runDBTests(t, func(t *testing.T, db *DB) {
 		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
@@ -432,7 +564,11 @@ func TestListGrants(t *testing.T) {
 			expected := []models.Grant{*grant3, *grant4, *gGrant1}
 			assert.DeepEqual(t, actual, expected, cmpModelByID)
 
-			expectedPagination := &Pagination{Page: 2, Limit: 3, TotalCount: 8}
+			expectedPagination := &Pagination{
+				Page:       2,
+				Limit:      3,
+				TotalCount: 8,
+			}
 			assert.DeepEqual(t, pagination, expectedPagination)
 		})
 		t.Run("by resource with pagination", func(t *testing.T) {
@@ -446,8 +582,90 @@ func TestListGrants(t *testing.T) {
 			expected := []models.Grant{*grant1, *grant2}
 			assert.DeepEqual(t, actual, expected, cmpModelByID)
 
-			expectedPagination := &Pagination{Page: 1, Limit: 2, TotalCount: 3}
+			expectedPagination := &Pagination{
+				Page:       1,
+				Limit:      2,
+				TotalCount: 3,
+			}
 			assert.DeepEqual(t, pagination, expectedPagination)
 		})
+		t.Run("by subject with cursor", func(t *testing.T) {
+			page := &Pagination{Limit: 2, Cursor: &Cursor{}}
+			actual, err := ListGrants(tx, ListGrantsOptions{
+				BySubject:  "i:userchar",
+				Pagination: page,
+			})
+			assert.NilError(t, err)
+
+			expected := []models.Grant{*grant1, *grant3}
+			assert.DeepEqual(t, actual, expected, cmpModelByID)
+			assert.DeepEqual(t, page.NextCursor, &Cursor{Created: actual[1].CreatedAt, ID: grant3.ID})
+
+			// a grant created between requests is not repeated, and the
+			// grants after the cursor are not skipped
+			added := &models.Grant{
+				Subject:   "i:userchar",
+				Privilege: "view",
+				Resource:  "added",
+				CreatedBy: uid.ID(777),
+			}
+			createGrants(t, tx, added)
+
+			page = &Pagination{Limit: 2, Cursor: page.NextCursor}
+			actual, err = ListGrants(tx, ListGrantsOptions{
+				BySubject:  "i:userchar",
+				Pagination: page,
+			})
+			assert.NilError(t, err)
+
+			expected = []models.Grant{*grant4, *added}
+			assert.DeepEqual(t, actual, expected, cmpModelByID)
+			// the count includes grants before the cursor
+			assert.Equal(t, page.TotalCount, 4)
+			// the last page has no cursor
+			assert.Assert(t, page.NextCursor == nil)
+		})
+		t.Run("by resource with cursor and concurrent inserts", func(t *testing.T) {
+			var seen []uid.ID
+			page := &Pagination{Limit: 1, Cursor: &Cursor{}}
+			for i := 0; i < 10; i++ {
+				actual, err := ListGrants(tx, ListGrantsOptions{
+					ByResource: "any",
+					Pagination: page,
+				})
+				assert.NilError(t, err)
+				for _, grant := range actual {
+					seen = append(seen, grant.ID)
+				}
+
+				// another grant for the resource is created before every
+				// request for the next page, until the fourth page
+				if i < 3 {
+					createGrants(t, tx, &models.Grant{
+						Subject:   uid.NewIdentityPolymorphicID(uid.ID(900 + i)),
+						Privilege: "view",
+						Resource:  "any",
+						CreatedBy: uid.ID(777),
+					})
+				}
+
+				if page.NextCursor == nil {
+					break
+				}
+				page = &Pagination{Limit: 1, Cursor: page.NextCursor}
+			}
+
+			// every grant is seen exactly once, including the ones created
+			// while paging
+			assert.Equal(t, len(seen), 6)
+			unique := map[uid.ID]bool{}
+			for _, id := range seen {
+				assert.Assert(t, !unique[id], "grant %v was repeated", id)
+				unique[id] = true
+			}
+			assert.Equal(t, seen[0], grant1.ID)
+			assert.Equal(t, seen[1], grant2.ID)
+			assert.Equal(t, seen[2], grant3.ID)
+		})
 	})
 }
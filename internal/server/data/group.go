@@ -1,6 +1,7 @@
 package data
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -104,16 +105,18 @@ func DeleteGroup(tx WriteTxn, id uid.ID) error {
 		return fmt.Errorf("remove useres from group: %w", err)
 	}
 
-	stmt := `
-		UPDATE groups
-		SET deleted_at = ?
-		WHERE id = ?
-		AND deleted_at is null
-		AND organization_id = ?`
-	_, err = tx.Exec(stmt, time.Now(), id, tx.OrganizationID())
+	query := querybuilder.New("UPDATE groups")
+	query.B("SET deleted_at = ?", time.Now())
+	query.B("WHERE id = ? AND", id)
+	query.B(notDeleted(groupsTable{}))
+	query.B("AND organization_id = ?", tx.OrganizationID())
+	_, err = tx.Exec(query.String(), query.Args...)
 	return handleError(err)
 }
 
+// AddUsersToGroup adds the users in idsToAdd to the group with ID groupID.
+// Users which were added to the group by a provider are marked as assigned
+// manually, so that provider sync does not remove them from the group.
 func AddUsersToGroup(tx WriteTxn, groupID uid.ID, idsToAdd []uid.ID) error {
 	query := querybuilder.New("INSERT INTO identities_groups(group_id, identity_id)")
 	query.B("VALUES")
@@ -123,7 +126,7 @@ func AddUsersToGroup(tx WriteTxn, groupID uid.ID, idsToAdd []uid.ID) error {
 			query.B(",")
 		}
 	}
-	query.B("ON CONFLICT DO NOTHING")
+	query.B("ON CONFLICT (identity_id, group_id) DO UPDATE SET created_by_provider = 0")
 
 	_, err := tx.Exec(query.String(), query.Args...)
 	return handleError(err)
@@ -138,6 +141,62 @@ func RemoveUsersFromGroup(tx WriteTxn, groupID uid.ID, idsToRemove []uid.ID) err
 	return handleError(err)
 }
 
+// ErrGroupMembershipFromProvider is returned by RemoveUserFromGroup and
+// RemoveManualUsersFromGroup when the user was added to the group by an
+// identity provider.
+var ErrGroupMembershipFromProvider = errors.New("the user was added to the group by an identity provider, remove the user from the group in the provider")
+
+// RemoveUserFromGroup removes the user with ID userID from the group with ID
+// groupID. A membership that was added by a provider is not removed, and
+// ErrGroupMembershipFromProvider is returned instead, because the provider
+// would add the user to the group again the next time the user logs in.
+func RemoveUserFromGroup(tx WriteTxn, groupID uid.ID, userID uid.ID) error {
+	var providerID uid.ID
+	stmt := `SELECT created_by_provider FROM identities_groups WHERE group_id = ? AND identity_id = ? FOR UPDATE`
+	if err := tx.QueryRow(stmt, groupID, userID).Scan(&providerID); err != nil {
+		return handleReadError(err)
+	}
+	if providerID != 0 {
+		return ErrGroupMembershipFromProvider
+	}
+
+	stmt = `DELETE FROM identities_groups WHERE group_id = ? AND identity_id = ?`
+	_, err := tx.Exec(stmt, groupID, userID)
+	return handleError(err)
+}
+
+// RemoveManualUsersFromGroup removes any user ID listed in idsToRemove from the
+// group with ID groupID, like RemoveUserFromGroup does for a single user. When
+// any of the users was added to the group by a provider, no users are removed,
+// and ErrGroupMembershipFromProvider is returned.
+func RemoveManualUsersFromGroup(tx WriteTxn, groupID uid.ID, idsToRemove []uid.ID) error {
+	stmt := `SELECT created_by_provider FROM identities_groups WHERE group_id = ? AND identity_id IN (?) FOR UPDATE`
+	rows, err := tx.Query(stmt, groupID, idsToRemove)
+	if err != nil {
+		return handleError(err)
+	}
+	var providerIDs []uid.ID
+	for rows.Next() {
+		var providerID uid.ID
+		if err := rows.Scan(&providerID); err != nil {
+			rows.Close()
+			return err
+		}
+		providerIDs = append(providerIDs, providerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return handleError(err)
+	}
+
+	for _, providerID := range providerIDs {
+		if providerID != 0 {
+			return ErrGroupMembershipFromProvider
+		}
+	}
+	return RemoveUsersFromGroup(tx, groupID, idsToRemove)
+}
+
 // TODO: do this with a join in ListGroups and GetGroup
 func CountUsersInGroup(tx GormTxn, groupID uid.ID) (int64, error) {
 	db := tx.GormDB()
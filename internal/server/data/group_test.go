@@ -9,6 +9,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/v3/assert"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
 )
@@ -310,3 +311,92 @@ func TestRemoveUsersFromGroup(t *testing.T) {
 
 	})
 }
+
+func TestRemoveUserFromGroup(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+		everyone := models.Group{Name: "Everyone"}
+		createGroups(t, tx, &everyone)
+
+		bond := models.Identity{Name: "jbond@infrahq.com"}
+		bourne := models.Identity{Name: "jbourne@infrahq.com"}
+		bauer := models.Identity{Name: "jbauer@infrahq.com"}
+		createIdentities(t, tx, &bond, &bourne, &bauer)
+
+		assert.NilError(t, AddUsersToGroup(tx, everyone.ID, []uid.ID{bond.ID, bourne.ID}))
+
+		provider := &models.Provider{Name: "okta", Kind: models.ProviderKindOkta}
+		assert.NilError(t, CreateProvider(tx, provider))
+		_, err := tx.Exec("INSERT INTO identities_groups (identity_id, group_id, created_by_provider) VALUES (?, ?, ?)",
+			bauer.ID, everyone.ID, provider.ID)
+		assert.NilError(t, err)
+
+		t.Run("assigned manually", func(t *testing.T) {
+			err := RemoveUserFromGroup(tx, everyone.ID, bond.ID)
+			assert.NilError(t, err)
+
+			actual, err := ListIdentities(tx, nil, ByOptionalIdentityGroupID(everyone.ID))
+			assert.NilError(t, err)
+			expected := []models.Identity{bauer, bourne}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+		})
+
+		t.Run("not a member", func(t *testing.T) {
+			err := RemoveUserFromGroup(tx, everyone.ID, bond.ID)
+			assert.ErrorIs(t, err, internal.ErrNotFound)
+		})
+
+		t.Run("added by a provider", func(t *testing.T) {
+			err := RemoveUserFromGroup(tx, everyone.ID, bauer.ID)
+			assert.ErrorIs(t, err, ErrGroupMembershipFromProvider)
+
+			actual, err := ListIdentities(tx, nil, ByOptionalIdentityGroupID(everyone.ID))
+			assert.NilError(t, err)
+			expected := []models.Identity{bauer, bourne}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+		})
+	})
+}
+
+func TestRemoveManualUsersFromGroup(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+		everyone := models.Group{Name: "Everyone"}
+		createGroups(t, tx, &everyone)
+
+		bond := models.Identity{Name: "jbond@infrahq.com"}
+		bourne := models.Identity{Name: "jbourne@infrahq.com"}
+		bauer := models.Identity{Name: "jbauer@infrahq.com"}
+		createIdentities(t, tx, &bond, &bourne, &bauer)
+
+		assert.NilError(t, AddUsersToGroup(tx, everyone.ID, []uid.ID{bond.ID, bourne.ID}))
+
+		provider := &models.Provider{Name: "okta", Kind: models.ProviderKindOkta}
+		assert.NilError(t, CreateProvider(tx, provider))
+		_, err := tx.Exec("INSERT INTO identities_groups (identity_id, group_id, created_by_provider) VALUES (?, ?, ?)",
+			bauer.ID, everyone.ID, provider.ID)
+		assert.NilError(t, err)
+
+		t.Run("added by a provider", func(t *testing.T) {
+			err := RemoveManualUsersFromGroup(tx, everyone.ID, []uid.ID{bond.ID, bauer.ID})
+			assert.ErrorIs(t, err, ErrGroupMembershipFromProvider)
+
+			actual, err := ListIdentities(tx, nil, ByOptionalIdentityGroupID(everyone.ID))
+			assert.NilError(t, err)
+			expected := []models.Identity{bauer, bond, bourne}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+		})
+
+		t.Run("assigned manually", func(t *testing.T) {
+			err := RemoveManualUsersFromGroup(tx, everyone.ID, []uid.ID{bond.ID, bourne.ID})
+			assert.NilError(t, err)
+
+			actual, err := ListIdentities(tx, nil, ByOptionalIdentityGroupID(everyone.ID))
+			assert.NilError(t, err)
+			expected := []models.Identity{bauer}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+		})
+	})
+}
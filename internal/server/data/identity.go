@@ -12,6 +12,12 @@ import (
 	"github.com/infrahq/infra/uid"
 )
 
+type identitiesTable models.Identity
+
+func (i identitiesTable) Table() string {
+	return "identities"
+}
+
 func AssignIdentityToGroups(tx GormTxn, user *models.Identity, provider *models.Provider, newGroups []string) error {
 	pu, err := GetProviderUser(tx, provider.ID, user.ID)
 	if err != nil {
@@ -28,21 +34,26 @@ func AssignIdentityToGroups(tx GormTxn, user *models.Identity, provider *models.
 		return fmt.Errorf("save: %w", err)
 	}
 
-	// remove user from groups
+	// remove user from groups, only when the membership was added by this
+	// provider, so that groups assigned manually are not changed
 	if len(groupsToBeRemoved) > 0 {
-		stmt := `DELETE FROM identities_groups WHERE identity_id = ? AND group_id in (
+		stmt := `DELETE FROM identities_groups WHERE identity_id = ? AND created_by_provider = ? AND group_id in (
 		   SELECT id FROM groups WHERE organization_id = ? AND name IN (?))`
-		if _, err := tx.Exec(stmt, user.ID, tx.OrganizationID(), groupsToBeRemoved); err != nil {
+		if _, err := tx.Exec(stmt, user.ID, provider.ID, tx.OrganizationID(), groupsToBeRemoved); err != nil {
+			return err
+		}
+
+		groups, err := groupIDsForUser(tx, user.ID)
+		if err != nil {
 			return err
 		}
-		for _, name := range groupsToBeRemoved {
-			for i, g := range user.Groups {
-				if g.Name == name {
-					// remove from list
-					user.Groups = append(user.Groups[:i], user.Groups[i+1:]...)
-				}
+		remaining := user.Groups[:0]
+		for _, g := range user.Groups {
+			if slice.Contains(groups, g.ID) {
+				remaining = append(remaining, g)
 			}
 		}
+		user.Groups = remaining
 	}
 
 	type idNamePair struct {
@@ -51,8 +62,10 @@ func AssignIdentityToGroups(tx GormTxn, user *models.Identity, provider *models.
 	}
 	var addIDs []idNamePair
 
-	stmt := `SELECT id, name FROM groups WHERE deleted_at is null AND name IN (?) AND organization_id = ?`
-	rows, err := tx.Query(stmt, groupsToBeAdded, tx.OrganizationID())
+	query := querybuilder.New("SELECT id, name FROM groups WHERE")
+	query.B(notDeleted(groupsTable{}))
+	query.B("AND name IN (?) AND organization_id = ?", groupsToBeAdded, tx.OrganizationID())
+	rows, err := tx.Query(query.String(), query.Args...)
 	if err != nil {
 		return err
 	}
@@ -111,7 +124,7 @@ func AssignIdentityToGroups(tx GormTxn, user *models.Identity, provider *models.
 
 		if len(ids) == 0 {
 			// add user to group
-			_, err = tx.Exec("INSERT INTO identities_groups (identity_id, group_id) VALUES (?, ?)", user.ID, groupID)
+			_, err = tx.Exec("INSERT INTO identities_groups (identity_id, group_id, created_by_provider) VALUES (?, ?, ?)", user.ID, groupID, provider.ID)
 			if err != nil {
 				return fmt.Errorf("insert: %w", handleError(err))
 			}
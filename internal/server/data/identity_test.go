@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/ssoroka/slice"
 	"gotest.tools/v3/assert"
 
 	"github.com/infrahq/infra/internal"
@@ -135,6 +136,40 @@ func TestListIdentities(t *testing.T) {
 			expected := []models.Identity{bauer}
 			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
 		})
+
+		t.Run("filter by name contains", func(t *testing.T) {
+			actual, err := ListIdentities(db, nil, ByOptionalNameContains("JBO"))
+			assert.NilError(t, err)
+			expected := []models.Identity{bond, bourne}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+
+			actual, err = ListIdentities(db, nil, ByOptionalNameContains("auer@infrahq"))
+			assert.NilError(t, err)
+			expected = []models.Identity{bauer}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+		})
+
+		t.Run("filter by name contains a wildcard", func(t *testing.T) {
+			actual, err := ListIdentities(db, nil, ByOptionalNameContains("j%@"))
+			assert.NilError(t, err)
+			assert.Equal(t, len(actual), 0)
+		})
+
+		t.Run("sort by created", func(t *testing.T) {
+			actual, err := ListIdentities(db, nil, NotName(connector.Name), OrderBy("created_at"))
+			assert.NilError(t, err)
+			expected := []models.Identity{bond, bourne, bauer}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+		})
+
+		t.Run("sort by created with pagination", func(t *testing.T) {
+			p := &Pagination{Page: 2, Limit: 2}
+			actual, err := ListIdentities(db, p, NotName(connector.Name), OrderBy("created_at"))
+			assert.NilError(t, err)
+			expected := []models.Identity{bauer}
+			assert.DeepEqual(t, actual, expected, cmpModelsIdentityShallow)
+			assert.Equal(t, p.TotalCount, 3)
+		})
 	})
 }
 
@@ -171,6 +206,25 @@ func TestDeleteIdentity(t *testing.T) {
 	})
 }
 
+func TestDeleteIdentities_AuditsGrants(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		user := &models.Identity{Name: "granted@example.com"}
+		createIdentities(t, db, user)
+
+		grant := &models.Grant{Subject: user.PolyID(), Privilege: "view", Resource: "any"}
+		createGrants(t, db, grant)
+
+		err := DeleteIdentities(db, ByIDs([]uid.ID{user.ID}))
+		assert.NilError(t, err)
+
+		events, err := ListAuditEvents(db, ListAuditEventsOptions{ByTargetID: grant.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(events), 1)
+		assert.Equal(t, events[0].Action, models.AuditActionDeleteGrant)
+		assert.Equal(t, events[0].ActorID, uid.ID(0))
+	})
+}
+
 func TestDeleteIdentityWithGroups(t *testing.T) {
 	runDBTests(t, func(t *testing.T, db *DB) {
 		var (
@@ -327,3 +381,66 @@ func TestAssignIdentityToGroups(t *testing.T) {
 		}
 	})
 }
+
+func TestAssignIdentityToGroups_Sync(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		provider := &models.Provider{Name: "okta", URL: "example.okta.com", Kind: models.ProviderKindOkta}
+		assert.NilError(t, CreateProvider(db, provider))
+
+		identity := &models.Identity{Name: "sync@example.com"}
+		assert.NilError(t, CreateIdentity(db, identity))
+		_, err := CreateProviderUser(db, provider, identity)
+		assert.NilError(t, err)
+
+		// groups assigned manually, "shared" is also a group at the provider
+		manual := &models.Group{Name: "manual"}
+		assert.NilError(t, CreateGroup(db, manual))
+		shared := &models.Group{Name: "shared"}
+		assert.NilError(t, CreateGroup(db, shared))
+		assert.NilError(t, AddUsersToGroup(db, manual.ID, []uid.ID{identity.ID}))
+		assert.NilError(t, AddUsersToGroup(db, shared.ID, []uid.ID{identity.ID}))
+
+		groupNames := func(t *testing.T) []string {
+			t.Helper()
+			groups, err := ListGroups(db, nil, ByGroupMember(identity.ID))
+			assert.NilError(t, err)
+			var names []string
+			for _, g := range groups {
+				names = append(names, g.Name)
+			}
+			return names
+		}
+
+		t.Run("groups are added", func(t *testing.T) {
+			err := AssignIdentityToGroups(db, identity, provider, []string{"dev", "ops", "shared"})
+			assert.NilError(t, err)
+
+			assert.DeepEqual(t, groupNames(t), []string{"dev", "manual", "ops", "shared"})
+		})
+
+		t.Run("groups are removed", func(t *testing.T) {
+			err := AssignIdentityToGroups(db, identity, provider, []string{"dev"})
+			assert.NilError(t, err)
+
+			// shared was assigned manually, so it is not removed
+			assert.DeepEqual(t, groupNames(t), []string{"dev", "manual", "shared"})
+
+			var names []string
+			for _, g := range identity.Groups {
+				names = append(names, g.Name)
+			}
+			assert.Assert(t, !slice.Contains(names, "ops"), "identity.Groups: %v", names)
+		})
+
+		t.Run("manual assignment of a provider group is kept", func(t *testing.T) {
+			dev, err := GetGroup(db, ByName("dev"))
+			assert.NilError(t, err)
+			assert.NilError(t, AddUsersToGroup(db, dev.ID, []uid.ID{identity.ID}))
+
+			err = AssignIdentityToGroups(db, identity, provider, []string{})
+			assert.NilError(t, err)
+
+			assert.DeepEqual(t, groupNames(t), []string{"dev", "manual", "shared"})
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "infra:query_start"
+
+// RegisterMetrics registers a histogram of query durations with registry, and
+// records the duration of every query made with db. The histogram is labeled
+// by the type of operation. Queries are not measured unless RegisterMetrics
+// is called. RegisterMetrics must be called before db is used concurrently.
+func RegisterMetrics(db *DB, registry prometheus.Registerer) error {
+	queryDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "infra",
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "A histogram of duration, in seconds, of database queries.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 15),
+	}, []string{"operation"})
+
+	if err := registry.Register(queryDuration); err != nil {
+		return err
+	}
+
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+	observe := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			if started, ok := tx.InstanceGet(queryStartKey); ok {
+				elapsed := time.Since(started.(time.Time)).Seconds()
+				queryDuration.WithLabelValues(operation).Observe(elapsed)
+			}
+		}
+	}
+
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("infra:before_create", start),
+		cb.Create().After("gorm:create").Register("infra:after_create", observe("insert")),
+		cb.Query().Before("gorm:query").Register("infra:before_query", start),
+		cb.Query().After("gorm:query").Register("infra:after_query", observe("select")),
+		cb.Update().Before("gorm:update").Register("infra:before_update", start),
+		cb.Update().After("gorm:update").Register("infra:after_update", observe("update")),
+		cb.Delete().Before("gorm:delete").Register("infra:before_delete", start),
+		cb.Delete().After("gorm:delete").Register("infra:after_delete", observe("delete")),
+		// Row is used by Query and QueryRow, and Raw is used by Exec
+		cb.Row().Before("gorm:row").Register("infra:before_row", start),
+		cb.Row().After("gorm:row").Register("infra:after_row", observe("query")),
+		cb.Raw().Before("gorm:raw").Register("infra:before_raw", start),
+		cb.Raw().After("gorm:raw").Register("infra:after_raw", observe("exec")),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("register query metrics: %w", err)
+		}
+	}
+	return nil
+}
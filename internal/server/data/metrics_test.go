@@ -0,0 +1,44 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/server/models"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		registry := prometheus.NewRegistry()
+		assert.NilError(t, RegisterMetrics(db, registry))
+
+		_, err := db.Exec("SELECT 1")
+		assert.NilError(t, err)
+
+		err = CreateIdentity(db, &models.Identity{Name: "metrics@example.com"})
+		assert.NilError(t, err)
+
+		_, err = GetIdentity(db, ByName("metrics@example.com"))
+		assert.NilError(t, err)
+
+		families, err := registry.Gather()
+		assert.NilError(t, err)
+
+		counts := map[string]uint64{}
+		for _, family := range families {
+			if family.GetName() != "infra_db_query_duration_seconds" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					counts[label.GetValue()] += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		assert.Assert(t, counts["exec"] > 0, "counts: %v", counts)
+		assert.Assert(t, counts["insert"] > 0, "counts: %v", counts)
+		assert.Assert(t, counts["select"] > 0, "counts: %v", counts)
+	})
+}
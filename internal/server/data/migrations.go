@@ -68,6 +68,24 @@ func migrations() []*migrator.Migration {
 		setDefaultOrgID(),
 		addIdentityVerifiedFields(),
 		cleanCrossOrgGroupMemberships(),
+		addAccessKeyLastUsedAt(),
+		addSettingsDefaultAccessKeyTTL(),
+		addProviderGroupsClaimName(),
+		addAccessKeyOneTimeUse(),
+		addAuditEvents(),
+		addAccessKeyCreatedBy(),
+		addProviderRedirectURLs(),
+		addIdentitiesGroupsCreatedByProvider(),
+		addGrantExpiresAt(),
+		addSettingsSessionDurations(),
+		addProviderEmailClaimName(),
+		addSettingsRetiredJWKs(),
+		addResourceVersions(),
+		addSettingsMaxActiveAccessKeys(),
+		addProviderDomains(),
+		addCredentialFailedLogins(),
+		addProviderRequestedScopes(),
+		addUnknownLoginAttempts(),
 		// next one here
 	}
 }
@@ -104,6 +122,7 @@ func autoMigrateSchema(db *gorm.DB) error {
 		&models.Credential{},
 		&models.Organization{},
 		&models.PasswordResetToken{},
+		&models.AuditEvent{},
 	}
 
 	for _, table := range tables {
@@ -191,7 +210,7 @@ func addAuthURLAndScopeToProviders() *migrator.Migration {
 
 					logging.Debugf("migrating %s provider", provider.Name)
 
-					providerClient := providers.NewOIDCClient(provider, "not-used", "http://localhost:8301")
+					providerClient := providers.NewOIDCClient(provider, "not-used", "http://localhost:8301", nil)
 					authServerInfo, err := providerClient.AuthServerInfo(context.Background())
 					if err != nil {
 						if errors.Is(err, context.DeadlineExceeded) {
@@ -655,3 +674,256 @@ func cleanCrossOrgGroupMemberships() *migrator.Migration {
 		},
 	}
 }
+
+func addAccessKeyLastUsedAt() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-04T11:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE access_keys ADD COLUMN IF NOT EXISTS last_used_at timestamp with time zone`
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+			// existing keys have never recorded a use
+			_, err := tx.Exec(`UPDATE access_keys SET last_used_at = ? WHERE last_used_at IS NULL`, time.Time{})
+			return err
+		},
+	}
+}
+
+func addSettingsDefaultAccessKeyTTL() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-05T09:30",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE settings ADD COLUMN IF NOT EXISTS default_access_key_ttl bigint DEFAULT 0`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addProviderGroupsClaimName() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-06T10:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE providers ADD COLUMN IF NOT EXISTS groups_claim_name text`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addAccessKeyOneTimeUse() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-06T14:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE access_keys ADD COLUMN IF NOT EXISTS one_time_use boolean DEFAULT false`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addAuditEvents() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-07T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigint NOT NULL,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    organization_id bigint,
+    action text,
+    actor_id bigint,
+    target_id bigint,
+    target_name text,
+    provider_id bigint,
+    CONSTRAINT audit_events_pkey PRIMARY KEY (id)
+);`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addAccessKeyCreatedBy() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-10T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE access_keys ADD COLUMN IF NOT EXISTS created_by bigint DEFAULT 0 NOT NULL`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addProviderRedirectURLs() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-11T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE providers ADD COLUMN IF NOT EXISTS redirect_urls text`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+// addIdentitiesGroupsCreatedByProvider records which provider added an identity
+// to a group, so that provider sync does not remove group memberships which
+// were assigned manually. Existing memberships in groups created by a provider,
+// or in groups that are listed in the provider_users groups of the identity,
+// are assumed to have been added by that provider.
+func addIdentitiesGroupsCreatedByProvider() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-12T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `
+ALTER TABLE identities_groups ADD COLUMN IF NOT EXISTS created_by_provider bigint DEFAULT 0 NOT NULL;
+
+UPDATE identities_groups SET created_by_provider = groups.created_by_provider
+FROM groups
+WHERE groups.id = identities_groups.group_id AND groups.created_by_provider IS NOT NULL;
+
+UPDATE identities_groups SET created_by_provider = provider_users.provider_id
+FROM groups, provider_users, providers
+WHERE identities_groups.created_by_provider = 0
+	AND groups.id = identities_groups.group_id
+	AND provider_users.identity_id = identities_groups.identity_id
+	AND providers.id = provider_users.provider_id
+	AND providers.organization_id = groups.organization_id
+	AND groups.name = ANY(string_to_array(provider_users.groups, ','));
+`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addGrantExpiresAt() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-13T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE grants ADD COLUMN IF NOT EXISTS expires_at timestamp with time zone`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addSettingsSessionDurations() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-14T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `
+ALTER TABLE settings ADD COLUMN IF NOT EXISTS session_duration bigint DEFAULT 0;
+ALTER TABLE settings ADD COLUMN IF NOT EXISTS session_extension_deadline bigint DEFAULT 0;
+`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addProviderEmailClaimName() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-17T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE providers ADD COLUMN IF NOT EXISTS email_claim_name text`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addSettingsRetiredJWKs() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-18T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE settings ADD COLUMN IF NOT EXISTS retired_jwks text`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addResourceVersions() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-19T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `
+ALTER TABLE destinations ADD COLUMN IF NOT EXISTS resource_version bigint DEFAULT 0 NOT NULL;
+ALTER TABLE identities ADD COLUMN IF NOT EXISTS resource_version bigint DEFAULT 0 NOT NULL;
+`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addSettingsMaxActiveAccessKeys() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-20T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE settings ADD COLUMN IF NOT EXISTS max_active_access_keys bigint DEFAULT 0`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addProviderDomains() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-21T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE providers ADD COLUMN IF NOT EXISTS domains text`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addCredentialFailedLogins() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-22T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS failed_login_attempts bigint DEFAULT 0;
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS failed_login_window_start timestamp with time zone;
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS locked_until timestamp with time zone;
+`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addProviderRequestedScopes() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-23T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `ALTER TABLE providers ADD COLUMN IF NOT EXISTS requested_scopes text`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
+
+func addUnknownLoginAttempts() *migrator.Migration {
+	return &migrator.Migration{
+		ID: "2022-10-24T09:00",
+		Migrate: func(tx migrator.DB) error {
+			stmt := `
+CREATE TABLE IF NOT EXISTS unknown_login_attempts (
+    organization_id bigint NOT NULL,
+    username_hash bytea NOT NULL,
+    failed_login_attempts bigint DEFAULT 0,
+    failed_login_window_start timestamp with time zone,
+    locked_until timestamp with time zone,
+    CONSTRAINT unknown_login_attempts_pkey PRIMARY KEY (organization_id, username_hash)
+);`
+			_, err := tx.Exec(stmt)
+			return err
+		},
+	}
+}
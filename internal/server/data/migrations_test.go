@@ -682,6 +682,188 @@ DELETE FROM settings WHERE id=24567;
 				}
 			},
 		},
+		{
+			label: testCaseLine("2022-10-04T11:00"),
+			setup: func(t *testing.T, tx WriteTxn) {
+				stmt := `INSERT INTO access_keys(id, name, organization_id) VALUES (?, ?, ?)`
+				_, err := tx.Exec(stmt, 1005, "the-key", defaultOrganizationID)
+				assert.NilError(t, err)
+			},
+			cleanup: func(t *testing.T, tx WriteTxn) {
+				_, err := tx.Exec(`DELETE FROM access_keys`)
+				assert.NilError(t, err)
+			},
+			expected: func(t *testing.T, tx WriteTxn) {
+				var lastUsedAt time.Time
+				err := tx.QueryRow(`SELECT last_used_at FROM access_keys WHERE id = ?`, 1005).Scan(&lastUsedAt)
+				assert.NilError(t, err)
+				assert.Assert(t, lastUsedAt.IsZero())
+			},
+		},
+		{
+			label: testCaseLine("2022-10-05T09:30"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-06T10:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-06T14:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-07T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-10T09:00"),
+			setup: func(t *testing.T, tx WriteTxn) {
+				stmt := `INSERT INTO access_keys(id, name, key_id) VALUES (12345, 'existing', 'abcdefghij');`
+				_, err := tx.Exec(stmt)
+				assert.NilError(t, err)
+			},
+			cleanup: func(t *testing.T, tx WriteTxn) {
+				_, err := tx.Exec(`DELETE FROM access_keys WHERE id = 12345`)
+				assert.NilError(t, err)
+			},
+			expected: func(t *testing.T, tx WriteTxn) {
+				// existing keys were created before the creator was recorded
+				var createdBy uid.ID
+				err := tx.QueryRow(`SELECT created_by FROM access_keys WHERE id = 12345`).Scan(&createdBy)
+				assert.NilError(t, err)
+				assert.Equal(t, createdBy, uid.ID(0))
+			},
+		},
+		{
+			label: testCaseLine("2022-10-11T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-12T09:00"),
+			setup: func(t *testing.T, tx WriteTxn) {
+				stmts := []string{
+					`INSERT INTO providers(id, name, organization_id) VALUES (2001, 'okta', ?)`,
+					`INSERT INTO identities(id, name, organization_id) VALUES (2002, 'alice@example.com', ?)`,
+					`INSERT INTO groups(id, name, organization_id, created_by_provider) VALUES (2003, 'created-by-okta', ?, 2001)`,
+					`INSERT INTO groups(id, name, organization_id) VALUES (2004, 'synced-from-okta', ?)`,
+					`INSERT INTO groups(id, name, organization_id) VALUES (2005, 'manual', ?)`,
+				}
+				for _, stmt := range stmts {
+					_, err := tx.Exec(stmt, defaultOrganizationID)
+					assert.NilError(t, err)
+				}
+
+				stmt := `
+INSERT INTO provider_users(identity_id, provider_id, groups) VALUES (2002, 2001, 'synced-from-okta,other');
+INSERT INTO identities_groups(identity_id, group_id) VALUES (2002, 2003), (2002, 2004), (2002, 2005);
+`
+				_, err := tx.Exec(stmt)
+				assert.NilError(t, err)
+			},
+			cleanup: func(t *testing.T, tx WriteTxn) {
+				stmt := `
+DELETE FROM identities_groups WHERE identity_id = 2002;
+DELETE FROM provider_users WHERE identity_id = 2002;
+DELETE FROM groups WHERE id IN (2003, 2004, 2005);
+DELETE FROM identities WHERE id = 2002;
+DELETE FROM providers WHERE id = 2001;
+`
+				_, err := tx.Exec(stmt)
+				assert.NilError(t, err)
+			},
+			expected: func(t *testing.T, tx WriteTxn) {
+				stmt := `SELECT group_id, created_by_provider FROM identities_groups WHERE identity_id = 2002 ORDER BY group_id`
+				rows, err := tx.Query(stmt)
+				assert.NilError(t, err)
+				defer rows.Close()
+
+				actual := map[uid.ID]uid.ID{}
+				for rows.Next() {
+					var groupID, createdBy uid.ID
+					assert.NilError(t, rows.Scan(&groupID, &createdBy))
+					actual[groupID] = createdBy
+				}
+				assert.NilError(t, rows.Err())
+
+				expected := map[uid.ID]uid.ID{
+					2003: 2001, // group created by the provider
+					2004: 2001, // group in the provider_users groups
+					2005: 0,    // group assigned manually
+				}
+				assert.DeepEqual(t, actual, expected)
+			},
+		},
+		{
+			label: testCaseLine("2022-10-13T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-14T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-17T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-18T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-19T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-20T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-21T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-22T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-23T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
+		{
+			label: testCaseLine("2022-10-24T09:00"),
+			expected: func(t *testing.T, tx WriteTxn) {
+				// schema changes are tested with schema comparison
+			},
+		},
 	}
 
 	ids := make(map[string]struct{}, len(testCases))
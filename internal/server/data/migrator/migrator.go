@@ -104,6 +104,33 @@ func (g *Migrator) Migrate() error {
 	return nil
 }
 
+// Pending returns true if Migrate would change the database, because the
+// initial schema has not been applied, or there are migrations in the list that
+// have not been applied. Pending does not change the database.
+func (g *Migrator) Pending() (bool, error) {
+	if err := g.validate(); err != nil {
+		return false, err
+	}
+	if !HasTable(g.tx, "migrations") {
+		return true, nil
+	}
+
+	initSchema, err := g.mustInitializeSchema()
+	if err != nil || initSchema {
+		return initSchema, err
+	}
+
+	for _, migration := range g.migrations {
+		switch migrationRan, err := g.migrationRan(migration); {
+		case err != nil:
+			return false, err
+		case !migrationRan:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (g *Migrator) validate() error {
 	lookup := make(map[string]struct{}, len(g.migrations))
 
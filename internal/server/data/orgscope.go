@@ -0,0 +1,94 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/infrahq/infra/internal/logging"
+)
+
+// tenantTables are the tables that store rows for many organizations. Queries
+// of these tables must filter by organization_id, otherwise they may read or
+// modify the rows of another organization.
+var tenantTables = map[string]bool{
+	"access_keys":            true,
+	"audit_events":           true,
+	"credentials":            true,
+	"destinations":           true,
+	"grants":                 true,
+	"groups":                 true,
+	"identities":             true,
+	"password_reset_tokens":  true,
+	"providers":              true,
+	"settings":               true,
+	"unknown_login_attempts": true,
+}
+
+// panicOnUnscopedQuery controls how a Transaction handles a query of a tenant
+// table that does not filter by organization_id. When false the query is
+// logged as an error. TestMain sets it to true, so that the query fails the test.
+var panicOnUnscopedQuery = false
+
+// guardOrganizationScope checks that a query of a tenant table filters by
+// organization_id. The check is a heuristic that looks for the column name
+// anywhere in the query, so it catches queries that forgot the filter
+// entirely, not queries that use it incorrectly.
+func (t *Transaction) guardOrganizationScope(query string) {
+	if t.unscoped {
+		return
+	}
+	table := unscopedTenantTable(query)
+	if table == "" {
+		return
+	}
+
+	if panicOnUnscopedQuery {
+		panic(fmt.Sprintf("query of %v is not scoped to an organization: %v", table, query))
+	}
+	logging.L.Error().Str("table", table).Str("query", query).
+		Msg("query is not scoped to an organization")
+}
+
+// unscopedTenantTable returns the name of a tenant table used by query, when
+// the query does not filter by organization_id. Otherwise it returns an empty
+// string.
+func unscopedTenantTable(query string) string {
+	query = strings.ToLower(query)
+	if strings.Contains(query, "organization_id") {
+		return ""
+	}
+
+	fields := strings.FieldsFunc(query, func(r rune) bool {
+		switch r {
+		case ' ', '\t', '\n', '(', ')', ',', ';':
+			return true
+		}
+		return false
+	})
+	for i := 1; i < len(fields); i++ {
+		switch fields[i-1] {
+		case "from", "join", "update", "into":
+			if tenantTables[fields[i]] {
+				return fields[i]
+			}
+		}
+	}
+	return ""
+}
+
+// allowUnscoped returns a copy of tx that may query tenant tables without
+// filtering by organization_id. It must only be used for queries that are
+// intentionally global, like looking up an access key by its globally unique
+// key ID.
+func allowUnscoped[T ReadTxn](tx T) T {
+	t, ok := any(tx).(*Transaction)
+	if !ok {
+		return tx
+	}
+	newTxn := *t
+	newTxn.unscoped = true
+	if result, ok := any(&newTxn).(T); ok {
+		return result
+	}
+	return tx
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestUnscopedTenantTable(t *testing.T) {
+	type testCase struct {
+		name     string
+		query    string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "scoped select",
+			query:    "SELECT id FROM grants WHERE deleted_at is null AND organization_id = ?",
+			expected: "",
+		},
+		{
+			name:     "unscoped select",
+			query:    "SELECT id FROM grants WHERE deleted_at is null AND id = ?",
+			expected: "grants",
+		},
+		{
+			name:     "unscoped join",
+			query:    "SELECT groups.id FROM identities_groups JOIN groups ON groups.id = group_id",
+			expected: "groups",
+		},
+		{
+			name:     "unscoped update",
+			query:    "UPDATE access_keys SET deleted_at = ? WHERE id = ?",
+			expected: "access_keys",
+		},
+		{
+			name:     "unscoped subquery",
+			query:    "SELECT * FROM (SELECT id FROM identities) AS i",
+			expected: "identities",
+		},
+		{
+			name:     "table without an organization",
+			query:    "SELECT id FROM encryption_keys WHERE name = ?",
+			expected: "",
+		},
+		{
+			name:     "savepoint",
+			query:    "SAVEPOINT beforeCreate",
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, unscopedTenantTable(tc.query), tc.expected)
+		})
+	}
+}
+
+func TestTransaction_GuardOrganizationScope(t *testing.T) {
+	tx := &Transaction{}
+
+	t.Run("unscoped query of a tenant table", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			assert.Equal(t, r, "query of grants is not scoped to an organization: SELECT id FROM grants WHERE id = ?")
+		}()
+		tx.guardOrganizationScope("SELECT id FROM grants WHERE id = ?")
+		t.Fatal("expected a panic")
+	})
+
+	t.Run("scoped query", func(t *testing.T) {
+		tx.guardOrganizationScope("SELECT id FROM grants WHERE id = ? AND organization_id = ?")
+	})
+
+	t.Run("allow unscoped", func(t *testing.T) {
+		var rtx ReadTxn = tx
+		unscoped := allowUnscoped(rtx)
+		unscoped.(*Transaction).guardOrganizationScope("SELECT id FROM access_keys WHERE key_id = ?")
+
+		// the original transaction is still checked
+		assert.Assert(t, !tx.unscoped)
+	})
+}
@@ -1,12 +1,33 @@
 package data
 
-import "github.com/infrahq/infra/internal/server/data/querybuilder"
+import (
+	"time"
+
+	"github.com/infrahq/infra/internal/server/data/querybuilder"
+	"github.com/infrahq/infra/uid"
+)
 
 // Internal Pagination Data
 type Pagination struct {
 	Page       int
 	Limit      int
 	TotalCount int
+
+	// Cursor is used instead of Page to select the items after the cursor.
+	// Only some list operations support cursors.
+	Cursor *Cursor
+	// NextCursor is set by list operations that support cursors when Cursor
+	// is set, and more items follow the page.
+	NextCursor *Cursor
+}
+
+// Cursor is the position of an item in a list ordered by creation time, and
+// then by ID. Selecting items after a cursor does not skip or repeat items
+// when the list changes between requests. The zero Cursor selects the first
+// page of the list.
+type Cursor struct {
+	Created time.Time
+	ID      uid.ID
 }
 
 func (p *Pagination) SetTotalCount(count int) {
@@ -19,9 +40,30 @@ func (p *Pagination) PaginateQuery(query *querybuilder.Query) {
 	if p.Limit == 0 {
 		return
 	}
+	if p.Cursor != nil {
+		// select one more item than the limit, to find if there is a next page
+		query.B("LIMIT ?", p.Limit+1)
+		return
+	}
 	if p.Page == 0 {
 		p.Page = 1
 	}
 	offset := p.Limit * (p.Page - 1)
 	query.B("LIMIT ? OFFSET ?", p.Limit, offset)
 }
+
+// after returns the cursor that the selected items must follow, or nil if the
+// items are not selected by cursor, or the cursor is the start of the list.
+func (p *Pagination) after() *Cursor {
+	if p == nil || p.Cursor == nil || p.Cursor.ID == 0 {
+		return nil
+	}
+	return p.Cursor
+}
+
+// hasNextPage returns true if count, the number of items selected by a query
+// paginated by cursor, is more than the limit. The extra item is not part of
+// the page.
+func (p *Pagination) hasNextPage(count int) bool {
+	return p.Cursor != nil && p.Limit > 0 && count > p.Limit
+}
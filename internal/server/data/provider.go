@@ -83,7 +83,7 @@ func DeleteProviders(db GormTxn, selectors ...SelectorFunc) error {
 			return fmt.Errorf("delete provider users: %w", err)
 		}
 
-		if err := DeleteAccessKeys(db, DeleteAccessKeysOptions{ByProviderID: p.ID}); err != nil {
+		if _, err := DeleteAccessKeys(db, DeleteAccessKeysOptions{ByProviderID: p.ID}); err != nil {
 			return fmt.Errorf("delete access keys: %w", err)
 		}
 	}
@@ -97,7 +97,7 @@ type providersCount struct {
 }
 
 func CountProvidersByKind(tx ReadTxn) ([]providersCount, error) {
-	rows, err := tx.Query("SELECT kind, COUNT(*) AS count FROM providers WHERE kind <> 'infra' AND deleted_at IS NULL GROUP BY kind")
+	rows, err := allowUnscoped(tx).Query("SELECT kind, COUNT(*) AS count FROM providers WHERE kind <> 'infra' AND deleted_at IS NULL GROUP BY kind")
 	if err != nil {
 		return nil, err
 	}
@@ -75,17 +75,24 @@ func SyncProviderUser(ctx context.Context, tx GormTxn, user *models.Identity, pr
 		return err
 	}
 
-	accessToken, expiry, err := oidcClient.RefreshAccessToken(ctx, providerUser)
+	accessToken, refreshToken, expiry, err := oidcClient.RefreshAccessToken(ctx, providerUser)
 	if err != nil {
 		return fmt.Errorf("refresh provider access: %w", err)
 	}
 
-	// update the stored access token if it was refreshed
-	if accessToken != string(providerUser.AccessToken) {
+	accessTokenChanged := accessToken != string(providerUser.AccessToken)
+	refreshTokenChanged := refreshToken != "" && refreshToken != string(providerUser.RefreshToken)
+
+	// update the stored tokens if they were refreshed
+	if accessTokenChanged || refreshTokenChanged {
 		logging.Debugf("access token for user at provider %s was refreshed", providerUser.ProviderID)
 
 		providerUser.AccessToken = models.EncryptedAtRest(accessToken)
 		providerUser.ExpiresAt = *expiry
+		if refreshTokenChanged {
+			logging.Debugf("refresh token for user at provider %s was rotated", providerUser.ProviderID)
+			providerUser.RefreshToken = models.EncryptedAtRest(refreshToken)
+		}
 
 		err = UpdateProviderUser(tx, providerUser)
 		if err != nil {
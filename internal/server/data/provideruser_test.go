@@ -17,6 +17,9 @@ import (
 type mockOIDCImplementation struct {
 	UserEmailResp  string
 	UserGroupsResp []string
+	// RotatedRefreshToken is returned as the new refresh token when the access
+	// token is refreshed.
+	RotatedRefreshToken string
 }
 
 func (m *mockOIDCImplementation) Validate(_ context.Context) error {
@@ -27,16 +30,36 @@ func (m *mockOIDCImplementation) AuthServerInfo(_ context.Context) (*providers.A
 	return &providers.AuthServerInfo{AuthURL: "example.com/v1/auth", ScopesSupported: []string{"openid", "email"}}, nil
 }
 
-func (m *mockOIDCImplementation) ExchangeAuthCodeForProviderTokens(_ context.Context, _ string) (acc, ref string, exp time.Time, email string, err error) {
-	return "acc", "ref", exp, m.UserEmailResp, nil
+func (m *mockOIDCImplementation) Discover(_ context.Context) (*providers.DiscoveryResult, error) {
+	return &providers.DiscoveryResult{AuthURL: "example.com/v1/auth"}, nil
 }
 
-func (m *mockOIDCImplementation) RefreshAccessToken(_ context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error) {
+func (m *mockOIDCImplementation) ExchangeAuthCodeForProviderTokens(_ context.Context, _, _ string) (acc, ref string, exp time.Time, email string, groups []string, err error) {
+	return "acc", "ref", exp, m.UserEmailResp, nil, nil
+}
+
+func (m *mockOIDCImplementation) VerifyIDToken(_ context.Context, _ string) (email string, groups []string, err error) {
+	return m.UserEmailResp, nil, nil
+}
+
+func (m *mockOIDCImplementation) StartDeviceAuthorization(_ context.Context) (*providers.DeviceAuthorization, error) {
+	return &providers.DeviceAuthorization{DeviceCode: "device-code", UserCode: "ABCD-EFGH"}, nil
+}
+
+func (m *mockOIDCImplementation) PollDeviceAuthorization(_ context.Context, _ *providers.DeviceAuthorization) (acc, ref string, exp time.Time, email string, groups []string, err error) {
+	return "acc", "ref", exp, m.UserEmailResp, nil, nil
+}
+
+func (m *mockOIDCImplementation) RefreshAccessToken(_ context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error) {
+	refreshToken = string(providerUser.RefreshToken)
 	if providerUser.ExpiresAt.Before(time.Now()) {
 		exp := time.Now().Add(1 * time.Hour)
-		return "new-acc-token", &exp, nil
+		if m.RotatedRefreshToken != "" {
+			refreshToken = m.RotatedRefreshToken
+		}
+		return "new-acc-token", refreshToken, &exp, nil
 	}
-	return string(providerUser.AccessToken), &providerUser.ExpiresAt, nil
+	return string(providerUser.AccessToken), refreshToken, &providerUser.ExpiresAt, nil
 }
 
 func (m *mockOIDCImplementation) GetUserInfo(_ context.Context, providerUser *models.ProviderUser) (*providers.UserInfoClaims, error) {
@@ -127,6 +150,46 @@ func TestSyncProviderUser(t *testing.T) {
 					assert.DeepEqual(t, *pu, expected, cmpProviderUser)
 				},
 			},
+			{
+				name: "rotated refresh token is updated",
+				setupProviderUser: func(t *testing.T) *models.Identity {
+					user := &models.Identity{
+						Name: "rotate@example.com",
+					}
+
+					err = CreateIdentity(db, user)
+					assert.NilError(t, err)
+
+					pu := &models.ProviderUser{
+						ProviderID: provider.ID,
+						IdentityID: user.ID,
+
+						Email:        user.Name,
+						RedirectURL:  "http://example.com",
+						AccessToken:  models.EncryptedAtRest("aaa"),
+						RefreshToken: models.EncryptedAtRest("bbb"),
+						ExpiresAt:    time.Now().UTC().Add(-5 * time.Minute),
+						LastUpdate:   time.Now().UTC().Add(-1 * time.Hour),
+					}
+
+					err = UpdateProviderUser(db, pu)
+					assert.NilError(t, err)
+
+					return user
+				},
+				oidcClient: &mockOIDCImplementation{
+					UserEmailResp:       "rotate@example.com",
+					RotatedRefreshToken: "ccc",
+				},
+				verifyFunc: func(t *testing.T, err error, user *models.Identity) {
+					assert.NilError(t, err)
+
+					pu, err := GetProviderUser(db, provider.ID, user.ID)
+					assert.NilError(t, err)
+					assert.Equal(t, string(pu.AccessToken), "new-acc-token")
+					assert.Equal(t, string(pu.RefreshToken), "ccc")
+				},
+			},
 			{
 				name: "groups are updated to match user info",
 				setupProviderUser: func(t *testing.T) *models.Identity {
@@ -20,6 +20,13 @@ type Table interface {
 	Columns() []string
 }
 
+// tableName is implemented by every Table, and by the types which are only
+// used to name a table in a query. The Table method must return a string
+// literal.
+type tableName interface {
+	Table() string
+}
+
 type Insertable interface {
 	Table
 	// Values returns the values for all fields. The values must be in the same
@@ -101,7 +108,9 @@ func update(tx WriteTxn, item Updatable) error {
 	query.B(item.Table())
 	query.B("SET")
 	query.B(columnsForUpdate(item), item.Values()...)
-	query.B("WHERE deleted_at is null AND id = ?;", item.Primary())
+	query.B("WHERE")
+	query.B(notDeleted(item))
+	query.B("AND id = ?;", item.Primary())
 	_, err := tx.Exec(query.String(), query.Args...)
 	return handleError(err)
 }
@@ -115,6 +124,19 @@ func columnsForUpdate(table Table) string {
 	return strings.Join(table.Columns(), " = ?, ") + " = ?"
 }
 
+// notDeleted is a privileged function that is not checked by
+// internal/tools/querylinter. If the arguments to this function change
+// the linter will likely need to be updated.
+// The return value must only include trusted strings from the source code,
+// never untrusted user input.
+//
+// notDeleted returns a condition that excludes the rows of table that were
+// soft-deleted. The column is qualified with the table name, so that the
+// condition can be used in queries that join other tables.
+func notDeleted(table tableName) string {
+	return table.Table() + ".deleted_at is null"
+}
+
 // columnsForSelect is a privileged function that is not checked by
 // internal/tools/querylinter. If the arguments to this function change
 // the linter will likely need to be updated.
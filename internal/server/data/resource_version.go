@@ -0,0 +1,71 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/server/data/querybuilder"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+// ErrResourceVersionConflict is returned when an update expects a resource
+// version that does not match the current version of the resource, because
+// the resource was updated by another request.
+var ErrResourceVersionConflict = errors.New("the resource was modified by another request")
+
+// UpdateDestinationResourceVersion increments the resource version of the
+// destination, and sets destination.ResourceVersion to the new version.
+// When expected is not nil, the update fails with ErrResourceVersionConflict
+// if the current version does not match expected.
+func UpdateDestinationResourceVersion(tx WriteTxn, destination *models.Destination, expected *int64) error {
+	version, err := updateResourceVersion(tx, destinationsTable{}, destination.ID, expected)
+	if err != nil {
+		return err
+	}
+	destination.ResourceVersion = version
+	return nil
+}
+
+// UpdateIdentityResourceVersion increments the resource version of the
+// identity, and sets identity.ResourceVersion to the new version.
+// When expected is not nil, the update fails with ErrResourceVersionConflict
+// if the current version does not match expected.
+func UpdateIdentityResourceVersion(tx WriteTxn, identity *models.Identity, expected *int64) error {
+	version, err := updateResourceVersion(tx, identitiesTable{}, identity.ID, expected)
+	if err != nil {
+		return err
+	}
+	identity.ResourceVersion = version
+	return nil
+}
+
+// updateResourceVersion increments the resource_version of a row in table.
+// The update locks the row until the transaction ends, so a concurrent update
+// that expects the same version waits, and then fails with a conflict.
+func updateResourceVersion(tx WriteTxn, table tableName, id uid.ID, expected *int64) (int64, error) {
+	query := querybuilder.New("UPDATE")
+	query.B(table.Table())
+	query.B("SET resource_version = resource_version + 1")
+	query.B("WHERE id = ? AND organization_id = ?", id, tx.OrganizationID())
+	query.B("AND")
+	query.B(notDeleted(table))
+	if expected != nil {
+		query.B("AND resource_version = ?", *expected)
+	}
+	query.B("RETURNING resource_version")
+
+	var version int64
+	err := tx.QueryRow(query.String(), query.Args...).Scan(&version)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && expected != nil:
+		return 0, fmt.Errorf("%w: expected version %d", ErrResourceVersionConflict, *expected)
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, internal.ErrNotFound
+	case err != nil:
+		return 0, handleError(err)
+	}
+	return version, nil
+}
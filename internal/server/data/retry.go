@@ -0,0 +1,57 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+)
+
+// writeConflictRetry configures how RetryWriteConflicts retries an operation.
+var writeConflictRetry = struct {
+	// attempts is the maximum number of times the operation is run.
+	attempts int
+	// initialDelay is the time to wait before the first retry. The delay is
+	// doubled before each following retry, up to maxDelay.
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	sleep        func(time.Duration)
+}{
+	attempts:     5,
+	initialDelay: 10 * time.Millisecond,
+	maxDelay:     200 * time.Millisecond,
+	sleep:        time.Sleep,
+}
+
+// RetryWriteConflicts calls fn, and calls it again when it fails because it
+// conflicted with a concurrent transaction (a serialization failure, a
+// deadlock, or a lock that was not available). fn is retried with backoff,
+// a limited number of times. If every attempt fails the last error is returned.
+//
+// A transaction that failed with a serialization failure can not be used
+// again, so fn must begin a new transaction, and roll it back when it returns
+// an error.
+func RetryWriteConflicts(fn func() error) error {
+	delay := writeConflictRetry.initialDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isWriteConflict(err) || attempt >= writeConflictRetry.attempts {
+			return err
+		}
+
+		writeConflictRetry.sleep(delay)
+		delay *= 2
+		if delay > writeConflictRetry.maxDelay {
+			delay = writeConflictRetry.maxDelay
+		}
+	}
+}
+
+// isWriteConflict returns true if the error is safe to retry because it was
+// caused by a concurrent transaction.
+func isWriteConflict(err error) bool {
+	return errors.Is(err, ErrWriteConflict) ||
+		isPgErrorCode(err, pgerrcode.SerializationFailure) ||
+		isPgErrorCode(err, pgerrcode.DeadlockDetected) ||
+		isPgErrorCode(err, pgerrcode.LockNotAvailable)
+}
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"gotest.tools/v3/assert"
+)
+
+func TestRetryWriteConflicts(t *testing.T) {
+	var delays []time.Duration
+	orig := writeConflictRetry
+	writeConflictRetry.sleep = func(d time.Duration) { delays = append(delays, d) }
+	t.Cleanup(func() { writeConflictRetry = orig })
+
+	serializationErr := &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+
+	t.Run("succeeds on the second attempt", func(t *testing.T) {
+		delays = nil
+		attempts := 0
+		err := RetryWriteConflicts(func() error {
+			attempts++
+			if attempts == 1 {
+				return handleError(serializationErr)
+			}
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, attempts, 2)
+		assert.DeepEqual(t, delays, []time.Duration{10 * time.Millisecond})
+	})
+
+	t.Run("deadlock is retried", func(t *testing.T) {
+		delays = nil
+		attempts := 0
+		err := RetryWriteConflicts(func() error {
+			attempts++
+			if attempts == 1 {
+				return fmt.Errorf("create grant: %w", &pgconn.PgError{Code: pgerrcode.DeadlockDetected})
+			}
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, attempts, 2)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		delays = nil
+		attempts := 0
+		err := RetryWriteConflicts(func() error {
+			attempts++
+			return errors.New("invalid")
+		})
+		assert.Error(t, err, "invalid")
+		assert.Equal(t, attempts, 1)
+		assert.Equal(t, len(delays), 0)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		delays = nil
+		attempts := 0
+		err := RetryWriteConflicts(func() error {
+			attempts++
+			return serializationErr
+		})
+		assert.Assert(t, isWriteConflict(err))
+		assert.Equal(t, attempts, 5)
+
+		expected := []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+			80 * time.Millisecond,
+		}
+		assert.DeepEqual(t, delays, expected)
+	})
+
+	t.Run("delay is capped", func(t *testing.T) {
+		delays = nil
+		writeConflictRetry.attempts = 7
+		t.Cleanup(func() { writeConflictRetry.attempts = orig.attempts })
+
+		_ = RetryWriteConflicts(func() error {
+			return serializationErr
+		})
+		assert.Equal(t, delays[len(delays)-1], 200*time.Millisecond)
+	})
+}
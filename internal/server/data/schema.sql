@@ -88,7 +88,23 @@ CREATE TABLE access_keys (
     key_id text,
     secret_checksum bytea,
     scopes text,
-    organization_id bigint
+    organization_id bigint,
+    last_used_at timestamp with time zone,
+    one_time_use boolean DEFAULT false,
+    created_by bigint DEFAULT 0 NOT NULL
+);
+
+CREATE TABLE audit_events (
+    id bigint NOT NULL,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    organization_id bigint,
+    action text,
+    actor_id bigint,
+    target_id bigint,
+    target_name text,
+    provider_id bigint
 );
 
 CREATE TABLE credentials (
@@ -99,7 +115,10 @@ CREATE TABLE credentials (
     identity_id bigint,
     password_hash bytea,
     one_time_password boolean,
-    organization_id bigint
+    organization_id bigint,
+    failed_login_attempts bigint DEFAULT 0,
+    failed_login_window_start timestamp with time zone,
+    locked_until timestamp with time zone
 );
 
 CREATE TABLE destinations (
@@ -115,7 +134,8 @@ CREATE TABLE destinations (
     version text,
     resources text,
     roles text,
-    organization_id bigint
+    organization_id bigint,
+    resource_version bigint DEFAULT 0 NOT NULL
 );
 
 CREATE TABLE encryption_keys (
@@ -139,7 +159,8 @@ CREATE TABLE grants (
     privilege text,
     resource text,
     created_by bigint,
-    organization_id bigint
+    organization_id bigint,
+    expires_at timestamp with time zone
 );
 
 CREATE TABLE groups (
@@ -163,12 +184,14 @@ CREATE TABLE identities (
     created_by bigint,
     organization_id bigint,
     verified boolean DEFAULT false NOT NULL,
-    verification_token text DEFAULT substr(replace(translate(encode(decode(md5((random())::text), 'hex'::text), 'base64'::text), '/+'::text, '=='::text), '='::text, ''::text), 1, 10) NOT NULL
+    verification_token text DEFAULT substr(replace(translate(encode(decode(md5((random())::text), 'hex'::text), 'base64'::text), '/+'::text, '=='::text), '='::text, ''::text), 1, 10) NOT NULL,
+    resource_version bigint DEFAULT 0 NOT NULL
 );
 
 CREATE TABLE identities_groups (
     identity_id bigint NOT NULL,
-    group_id bigint NOT NULL
+    group_id bigint NOT NULL,
+    created_by_provider bigint DEFAULT 0 NOT NULL
 );
 
 CREATE TABLE organizations (
@@ -217,7 +240,12 @@ CREATE TABLE providers (
     private_key text,
     client_email text,
     domain_admin_email text,
-    organization_id bigint
+    organization_id bigint,
+    groups_claim_name text,
+    redirect_urls text,
+    email_claim_name text,
+    domains text,
+    requested_scopes text
 );
 
 CREATE TABLE settings (
@@ -232,12 +260,28 @@ CREATE TABLE settings (
     number_min bigint DEFAULT 0,
     symbol_min bigint DEFAULT 0,
     length_min bigint DEFAULT 8,
-    organization_id bigint
+    organization_id bigint,
+    default_access_key_ttl bigint DEFAULT 0,
+    session_duration bigint DEFAULT 0,
+    session_extension_deadline bigint DEFAULT 0,
+    retired_jwks text,
+    max_active_access_keys bigint DEFAULT 0
+);
+
+CREATE TABLE unknown_login_attempts (
+    organization_id bigint NOT NULL,
+    username_hash bytea NOT NULL,
+    failed_login_attempts bigint DEFAULT 0,
+    failed_login_window_start timestamp with time zone,
+    locked_until timestamp with time zone
 );
 
 ALTER TABLE ONLY access_keys
     ADD CONSTRAINT access_keys_pkey PRIMARY KEY (id);
 
+ALTER TABLE ONLY audit_events
+    ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+
 ALTER TABLE ONLY credentials
     ADD CONSTRAINT credentials_pkey PRIMARY KEY (id);
 
@@ -274,6 +318,9 @@ ALTER TABLE ONLY providers
 ALTER TABLE ONLY settings
     ADD CONSTRAINT settings_pkey PRIMARY KEY (id);
 
+ALTER TABLE ONLY unknown_login_attempts
+    ADD CONSTRAINT unknown_login_attempts_pkey PRIMARY KEY (organization_id, username_hash);
+
 CREATE UNIQUE INDEX idx_access_keys_key_id ON access_keys USING btree (key_id) WHERE (deleted_at IS NULL);
 
 CREATE UNIQUE INDEX idx_access_keys_name ON access_keys USING btree (organization_id, name) WHERE (deleted_at IS NULL);
@@ -2,6 +2,7 @@ package data
 
 import (
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/uid"
@@ -37,6 +38,18 @@ func ByOptionalName(name string) SelectorFunc {
 	}
 }
 
+// ByOptionalNameContains selects the rows with a name that contains search,
+// ignoring case.
+func ByOptionalNameContains(search string) SelectorFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(search) > 0 {
+			return db.Where("name ILIKE ?", "%"+escapeLikePattern(search)+"%")
+		}
+
+		return db
+	}
+}
+
 func ByOptionalIDs(ids []uid.ID) SelectorFunc {
 	return func(db *gorm.DB) *gorm.DB {
 		if len(ids) > 0 {
@@ -95,6 +108,16 @@ func ByPagination(p Pagination) SelectorFunc {
 	}
 }
 
+// OrderBy replaces the default sort order of a list with column, in
+// ascending order. Rows with the same value are sorted by id.
+func OrderBy(column string) SelectorFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Reorder: true}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+}
+
 func CreatedBy(id uid.ID) SelectorFunc {
 	return func(db *gorm.DB) *gorm.DB {
 		return db.Where("created_by = ?", id)
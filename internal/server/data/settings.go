@@ -5,6 +5,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
+	"time"
 
 	"gopkg.in/square/go-jose.v2"
 
@@ -18,47 +19,122 @@ func initializeSettings(tx GormTxn, orgID uid.ID) (*models.Settings, error) {
 		return settings, err
 	}
 
-	pubkey, seckey, err := ed25519.GenerateKey(rand.Reader)
+	secs, pubs, err := generateSigningKey()
 	if err != nil {
 		return nil, err
 	}
 
+	settings = &models.Settings{
+		OrganizationMember: models.OrganizationMember{OrganizationID: orgID},
+		PrivateJWK:         models.EncryptedAtRest(secs),
+		PublicJWK:          pubs,
+	}
+
+	db := tx.GormDB()
+	db = ByOrgID(orgID)(db)
+	if err := db.FirstOrCreate(&settings).Error; err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// generateSigningKey returns a new private and public JWK used to sign tokens.
+// The key ID is the thumbprint of the key.
+func generateSigningKey() (secs []byte, pubs []byte, err error) {
+	pubkey, seckey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	sec := jose.JSONWebKey{Key: seckey, KeyID: "", Algorithm: string(jose.ED25519), Use: "sig"}
 
 	thumb, err := sec.Thumbprint(crypto.SHA256)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	sec.KeyID = base64.URLEncoding.EncodeToString(thumb)
 
 	pub := jose.JSONWebKey{Key: pubkey, KeyID: sec.KeyID, Algorithm: string(jose.ED25519), Use: "sig"}
 
-	secs, err := sec.MarshalJSON()
+	secs, err = sec.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubs, err = pub.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+	return secs, pubs, nil
+}
+
+// SigningKeyGracePeriod is the amount of time the public key of a retired
+// signing key is published after the key was rotated. It must be longer than
+// the lifetime of a token, and the time a destination caches the public keys.
+const SigningKeyGracePeriod = time.Hour
+
+// RotateSigningKey replaces the key used to sign new tokens. The public key
+// of the previous signing key is retired, and continues to be published until
+// the end of SigningKeyGracePeriod so that tokens signed by it can be
+// verified. Retired keys older than the grace period are removed.
+func RotateSigningKey(db GormTxn) (*models.Settings, error) {
+	settings, err := GetSettings(db)
 	if err != nil {
 		return nil, err
 	}
 
-	pubs, err := pub.MarshalJSON()
+	secs, pubs, err := generateSigningKey()
 	if err != nil {
 		return nil, err
 	}
 
-	settings = &models.Settings{
-		OrganizationMember: models.OrganizationMember{OrganizationID: orgID},
-		PrivateJWK:         models.EncryptedAtRest(secs),
-		PublicJWK:          pubs,
+	now := time.Now().UTC()
+	retired := models.RetiredJWKs{{PublicJWK: settings.PublicJWK, RetiredAt: now}}
+	for _, key := range settings.RetiredJWKs {
+		if signingKeyActive(key, now) {
+			retired = append(retired, key)
+		}
 	}
 
-	db := tx.GormDB()
-	db = ByOrgID(orgID)(db)
-	if err := db.FirstOrCreate(&settings).Error; err != nil {
+	settings.PrivateJWK = models.EncryptedAtRest(secs)
+	settings.PublicJWK = pubs
+	settings.RetiredJWKs = retired
+	if err := save(db, settings); err != nil {
 		return nil, err
 	}
-
 	return settings, nil
 }
 
+// PublicJWKs returns the public keys that can be used to verify tokens, which
+// are the current signing key followed by any retired keys that are still in
+// their grace period, newest first.
+func PublicJWKs(settings *models.Settings) ([]jose.JSONWebKey, error) {
+	var current jose.JSONWebKey
+	if err := current.UnmarshalJSON(settings.PublicJWK); err != nil {
+		return nil, err
+	}
+	keys := []jose.JSONWebKey{current}
+
+	now := time.Now().UTC()
+	for _, retired := range settings.RetiredJWKs {
+		if !signingKeyActive(retired, now) {
+			continue
+		}
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(retired.PublicJWK); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func signingKeyActive(key models.RetiredJWK, now time.Time) bool {
+	return now.Before(key.RetiredAt.Add(SigningKeyGracePeriod))
+}
+
 func GetSettings(db GormTxn) (*models.Settings, error) {
 	return getSettingsForOrg(db, db.OrganizationID())
 }
@@ -2,9 +2,13 @@ package data
 
 import (
 	"testing"
+	"time"
 
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 	"gotest.tools/v3/assert"
 
+	"github.com/infrahq/infra/internal/claims"
 	"github.com/infrahq/infra/internal/server/models"
 )
 
@@ -29,6 +33,111 @@ func TestInitializeSettings(t *testing.T) {
 	})
 }
 
+func TestRotateSigningKey(t *testing.T) {
+	runDBTests(t, func(t *testing.T, db *DB) {
+		tx := txnForTestCase(t, db, db.DefaultOrg.ID)
+
+		user := &models.Identity{Name: "rotate@example.com"}
+		assert.NilError(t, CreateIdentity(tx, user))
+
+		oldToken, err := CreateIdentityToken(tx, user.ID)
+		assert.NilError(t, err)
+
+		before, err := GetSettings(tx)
+		assert.NilError(t, err)
+
+		settings, err := RotateSigningKey(tx)
+		assert.NilError(t, err)
+		assert.Assert(t, string(settings.PublicJWK) != string(before.PublicJWK))
+		assert.Equal(t, len(settings.RetiredJWKs), 1)
+		assert.Equal(t, string(settings.RetiredJWKs[0].PublicJWK), string(before.PublicJWK))
+
+		// the retired key is stored
+		settings, err = GetSettings(tx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(settings.RetiredJWKs), 1)
+
+		keys, err := PublicJWKs(settings)
+		assert.NilError(t, err)
+		assert.Equal(t, len(keys), 2)
+
+		t.Run("token signed by the retired key is valid", func(t *testing.T) {
+			assert.Equal(t, verifyToken(t, keys, oldToken.Token), "rotate@example.com")
+		})
+
+		t.Run("new token is signed by the new key", func(t *testing.T) {
+			newToken, err := CreateIdentityToken(tx, user.ID)
+			assert.NilError(t, err)
+
+			tok, err := jwt.ParseSigned(newToken.Token)
+			assert.NilError(t, err)
+			assert.Equal(t, tok.Headers[0].KeyID, keys[0].KeyID)
+			assert.Equal(t, verifyToken(t, keys, newToken.Token), "rotate@example.com")
+		})
+
+		t.Run("retired key is dropped after the grace period", func(t *testing.T) {
+			settings.RetiredJWKs[0].RetiredAt = time.Now().Add(-SigningKeyGracePeriod - time.Minute)
+			assert.NilError(t, SaveSettings(tx, settings))
+
+			keys, err := PublicJWKs(settings)
+			assert.NilError(t, err)
+			assert.Equal(t, len(keys), 1)
+
+			settings, err = RotateSigningKey(tx)
+			assert.NilError(t, err)
+			// only the key that was just retired remains
+			assert.Equal(t, len(settings.RetiredJWKs), 1)
+		})
+	})
+}
+
+func TestPublicJWKs(t *testing.T) {
+	newKey := func(t *testing.T) []byte {
+		_, pub, err := generateSigningKey()
+		assert.NilError(t, err)
+		return pub
+	}
+
+	current := newKey(t)
+	settings := &models.Settings{
+		PublicJWK: current,
+		RetiredJWKs: models.RetiredJWKs{
+			{PublicJWK: newKey(t), RetiredAt: time.Now().Add(-time.Minute)},
+			{PublicJWK: newKey(t), RetiredAt: time.Now().Add(-SigningKeyGracePeriod - time.Minute)},
+		},
+	}
+
+	keys, err := PublicJWKs(settings)
+	assert.NilError(t, err)
+	assert.Equal(t, len(keys), 2)
+
+	var expected jose.JSONWebKey
+	assert.NilError(t, expected.UnmarshalJSON(current))
+	assert.Equal(t, keys[0].KeyID, expected.KeyID)
+
+	assert.NilError(t, expected.UnmarshalJSON(settings.RetiredJWKs[0].PublicJWK))
+	assert.Equal(t, keys[1].KeyID, expected.KeyID)
+}
+
+// verifyToken verifies the token with the key from keys that matches its key
+// ID, the same way a destination does, and returns the name claim.
+func verifyToken(t *testing.T, keys []jose.JSONWebKey, raw string) string {
+	t.Helper()
+	tok, err := jwt.ParseSigned(raw)
+	assert.NilError(t, err)
+
+	for _, key := range keys {
+		if key.KeyID != tok.Headers[0].KeyID {
+			continue
+		}
+		var custom claims.Custom
+		assert.NilError(t, tok.Claims(key, &custom))
+		return custom.Name
+	}
+	t.Fatalf("no key with key ID %v", tok.Headers[0].KeyID)
+	return ""
+}
+
 func runStep(t *testing.T, name string, fn func(t *testing.T)) {
 	if !t.Run(name, fn) {
 		t.FailNow()
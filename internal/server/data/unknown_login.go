@@ -0,0 +1,107 @@
+package data
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/infrahq/infra/internal/server/data/querybuilder"
+)
+
+// maxUnknownLoginUsernames is the maximum number of usernames in an
+// organization for which failed login attempts without a password credential
+// are counted.
+const maxUnknownLoginUsernames = 100_000
+
+// ErrTooManyUnknownLogins is returned by RecordFailedUnknownLogin when the
+// attempts for the username can not be counted, because attempts are already
+// counted for maxUnknownLoginUsernames other usernames.
+var ErrTooManyUnknownLogins = fmt.Errorf("too many usernames with failed login attempts")
+
+// usernameHash returns the value used to store the failed login attempts of
+// a username, so that the usernames are not stored in the database.
+func usernameHash(username string) []byte {
+	sum := sha256.Sum256([]byte(username))
+	return sum[:]
+}
+
+// RecordFailedUnknownLogin counts a failed login attempt with a username that
+// does not have a password credential, the same way RecordFailedLogin counts
+// the attempts for a credential. It returns the number of failed attempts in
+// the current window, and the time login with the username is locked until.
+// Attempts are not counted while login is locked. The update is a single
+// statement, so that concurrent attempts are all counted.
+func RecordFailedUnknownLogin(tx WriteTxn, username string, window time.Duration) (int, time.Time, error) {
+	attempts, lockedUntil, err := recordFailedUnknownLogin(tx, username, window)
+	if !errors.Is(err, ErrTooManyUnknownLogins) {
+		return attempts, lockedUntil, err
+	}
+
+	if err := deleteExpiredUnknownLogins(tx, window); err != nil {
+		return 0, time.Time{}, err
+	}
+	return recordFailedUnknownLogin(tx, username, window)
+}
+
+func recordFailedUnknownLogin(tx WriteTxn, username string, window time.Duration) (int, time.Time, error) {
+	now := time.Now().UTC()
+	windowStart := now.Add(-window)
+	orgID := tx.OrganizationID()
+	hash := usernameHash(username)
+
+	query := querybuilder.New("INSERT INTO unknown_login_attempts")
+	query.B("(organization_id, username_hash, failed_login_attempts, failed_login_window_start)")
+	query.B("SELECT ?, ?, 1, ?", orgID, hash, now)
+	// a new username is only added when the organization has room for it
+	query.B("WHERE (SELECT count(*) FROM unknown_login_attempts WHERE organization_id = ?) < ?", orgID, maxUnknownLoginUsernames)
+	query.B("OR EXISTS (SELECT 1 FROM unknown_login_attempts WHERE organization_id = ? AND username_hash = ?)", orgID, hash)
+	query.B("ON CONFLICT (organization_id, username_hash) DO UPDATE SET")
+	query.B("failed_login_attempts = CASE")
+	query.B("WHEN unknown_login_attempts.locked_until > ? THEN unknown_login_attempts.failed_login_attempts", now)
+	query.B("WHEN unknown_login_attempts.failed_login_window_start > ? THEN unknown_login_attempts.failed_login_attempts + 1", windowStart)
+	query.B("ELSE 1 END,")
+	query.B("failed_login_window_start = CASE")
+	query.B("WHEN unknown_login_attempts.locked_until > ? THEN unknown_login_attempts.failed_login_window_start", now)
+	query.B("WHEN unknown_login_attempts.failed_login_window_start > ? THEN unknown_login_attempts.failed_login_window_start", windowStart)
+	query.B("ELSE ? END", now)
+	query.B("RETURNING failed_login_attempts, locked_until")
+
+	var attempts int
+	var lockedUntil sql.NullTime
+	err := tx.QueryRow(query.String(), query.Args...).Scan(&attempts, &lockedUntil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, time.Time{}, ErrTooManyUnknownLogins
+	case err != nil:
+		return 0, time.Time{}, handleError(err)
+	}
+	return attempts, lockedUntil.Time, nil
+}
+
+// deleteExpiredUnknownLogins removes the usernames that are not locked, and
+// have no failed attempts in the current window.
+func deleteExpiredUnknownLogins(tx WriteTxn, window time.Duration) error {
+	now := time.Now().UTC()
+
+	query := querybuilder.New("DELETE FROM unknown_login_attempts")
+	query.B("WHERE organization_id = ?", tx.OrganizationID())
+	query.B("AND (locked_until IS NULL OR locked_until <= ?)", now)
+	query.B("AND (failed_login_window_start IS NULL OR failed_login_window_start <= ?)", now.Add(-window))
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return handleError(err)
+}
+
+// LockUnknownLogin rejects login attempts with a username that does not have
+// a password credential until the time until, and starts counting failed
+// attempts again.
+func LockUnknownLogin(tx WriteTxn, username string, until time.Time) error {
+	query := querybuilder.New("UPDATE unknown_login_attempts")
+	query.B("SET locked_until = ?, failed_login_attempts = 0, failed_login_window_start = null", until)
+	query.B("WHERE organization_id = ? AND username_hash = ?", tx.OrganizationID(), usernameHash(username))
+
+	_, err := tx.Exec(query.String(), query.Args...)
+	return handleError(err)
+}
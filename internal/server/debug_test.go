@@ -61,6 +61,31 @@ func TestAPI_PProfHandler(t *testing.T) {
 			},
 			expectedResp: responseBodyAPIErrorWithCode(http.StatusForbidden),
 		},
+		{
+			// profiles include data from all organizations, so the role of an
+			// organization admin is not sufficient
+			name:         "admin role is not sufficient",
+			expectedCode: http.StatusForbidden,
+			setupRequest: func(t *testing.T, req *http.Request) {
+				key, user := createAccessKey(t, s.DB(), "admin1@example.com")
+				err := data.CreateGrant(s.DB(), &models.Grant{
+					Subject:   user.PolyID(),
+					Privilege: models.InfraAdminRole,
+					Resource:  access.ResourceInfraAPI,
+					CreatedBy: user.ID,
+				})
+				assert.NilError(t, err)
+
+				req.Header.Add("Authorization", "Bearer "+key)
+			},
+			expectedResp: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				var apiError api.Error
+				err := json.Unmarshal(resp.Body.Bytes(), &apiError)
+				assert.NilError(t, err)
+				assert.Equal(t, apiError.ErrorCode, api.ErrorCodeNotAuthorized)
+				assert.Equal(t, apiError.Message, "you do not have permission to run debug, requires role support-admin")
+			},
+		},
 		{
 			name:         "successful profile",
 			expectedCode: http.StatusOK,
@@ -90,6 +115,35 @@ func TestAPI_PProfHandler(t *testing.T) {
 	}
 }
 
+func TestAPI_PProfHandler_Disabled(t *testing.T) {
+	s := setupServer(t, func(_ *testing.T, opts *Options) {
+		opts.EnablePprof = false
+	})
+	routes := s.GenerateRoutes()
+
+	key, user := createAccessKey(t, s.DB(), "user1@example.com")
+	err := data.CreateGrant(s.DB(), &models.Grant{
+		Subject:   user.PolyID(),
+		Privilege: models.InfraSupportAdminRole,
+		Resource:  access.ResourceInfraAPI,
+		CreatedBy: user.ID,
+	})
+	assert.NilError(t, err)
+
+	// nolint:noctx
+	req, err := http.NewRequest(http.MethodGet, "/api/debug/pprof/heap?debug=1", nil)
+	assert.NilError(t, err)
+	req.Header.Add("Infra-Version", "0.12.3")
+	req.Header.Add("Authorization", "Bearer "+key)
+	req.Header.Add("Accept", "application/json")
+
+	resp := httptest.NewRecorder()
+	routes.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusNotFound, resp.Body.String())
+	responseBodyAPIErrorWithCode(http.StatusNotFound)(t, resp)
+}
+
 func responseBodyAPIErrorWithCode(code int32) func(t *testing.T, resp *httptest.ResponseRecorder) {
 	return func(t *testing.T, resp *httptest.ResponseRecorder) {
 		t.Helper()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	"gotest.tools/v3/assert"
 
 	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
 )
 
 func TestAPI_CreateDestination(t *testing.T) {
@@ -70,7 +73,8 @@ func TestAPI_CreateDestination(t *testing.T) {
 	"resources": ["res1", "res2"],
 	"roles": ["role1", "role2"],
 	"created": "%[1]v",
-	"updated": "%[1]v"
+	"updated": "%[1]v",
+	"resourceVersion": 0
 }
 `,
 					time.Now().UTC().Format(time.RFC3339)))
@@ -112,3 +116,171 @@ var cmpAPIDestinationJSON = gocmp.Options{
 	gocmp.FilterPath(pathMapKey(`created`, `updated`), cmpApproximateTime),
 	gocmp.FilterPath(pathMapKey(`id`), cmpAnyValidUID),
 }
+
+func TestAPI_PatchDestination(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	destination := &models.Destination{
+		Name:          "kubernetes",
+		UniqueID:      "unique-id",
+		ConnectionURL: "cluster.production.example",
+		ConnectionCA:  "-----BEGIN CERTIFICATE-----\nok\n-----END CERTIFICATE-----\n",
+		Resources:     []string{"res1", "res2"},
+		Roles:         []string{"role1"},
+		Version:       "0.16.0",
+	}
+	err := data.CreateDestination(srv.DB(), destination)
+	assert.NilError(t, err)
+
+	patchDestination := func(t *testing.T, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodPatch, "/api/destinations/"+destination.ID.String(), strings.NewReader(body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("one field leaves the others unchanged", func(t *testing.T) {
+		resp := patchDestination(t, `{"version": "0.17.0"}`)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		actual, err := data.GetDestination(srv.DB(), data.ByID(destination.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, actual.Version, "0.17.0")
+		assert.Equal(t, actual.Name, "kubernetes")
+		assert.Equal(t, actual.UniqueID, "unique-id")
+		assert.Equal(t, actual.ConnectionURL, "cluster.production.example")
+		assert.Equal(t, actual.ConnectionCA, destination.ConnectionCA)
+		assert.DeepEqual(t, []string(actual.Resources), []string{"res1", "res2"})
+		assert.DeepEqual(t, []string(actual.Roles), []string{"role1"})
+	})
+
+	t.Run("set a list to empty", func(t *testing.T) {
+		resp := patchDestination(t, `{"roles": []}`)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		actual, err := data.GetDestination(srv.DB(), data.ByID(destination.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, len(actual.Roles), 0)
+		assert.DeepEqual(t, []string(actual.Resources), []string{"res1", "res2"})
+		assert.Equal(t, actual.Version, "0.17.0")
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		resp := patchDestination(t, `{"name": ""}`)
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+		var respBody api.Error
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		expected := []api.FieldError{
+			{FieldName: "name", Errors: []string{"can not be empty"}},
+		}
+		assert.DeepEqual(t, respBody.FieldErrors, expected)
+	})
+}
+
+func TestAPI_UpdateDestination_ResourceVersion(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	destination := &models.Destination{
+		Name:          "kubernetes",
+		UniqueID:      "unique-id",
+		ConnectionURL: "cluster.production.example",
+	}
+	err := data.CreateDestination(srv.DB(), destination)
+	assert.NilError(t, err)
+
+	updateDestination := func(t *testing.T, method string, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(method, "/api/destinations/"+destination.ID.String(), jsonBody(t, body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	putBody := api.UpdateDestinationRequest{
+		Name:       "kubernetes",
+		UniqueID:   "unique-id",
+		Connection: api.DestinationConnection{URL: "cluster.staging.example"},
+	}
+
+	t.Run("matching version", func(t *testing.T) {
+		resp := updateDestination(t, http.MethodPut, `"0"`, putBody)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		var respBody api.Destination
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.Equal(t, respBody.ResourceVersion, int64(1))
+		assert.Equal(t, respBody.Connection.URL, "cluster.staging.example")
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		putBody.Connection.URL = "cluster.other.example"
+		resp := updateDestination(t, http.MethodPut, "0", putBody)
+		assert.Equal(t, resp.Code, http.StatusConflict, resp.Body.String())
+
+		var respBody api.Error
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.Equal(t, respBody.ErrorCode, api.ErrorCodeConflict)
+
+		actual, err := data.GetDestination(srv.DB(), data.ByID(destination.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, actual.ConnectionURL, "cluster.staging.example")
+		assert.Equal(t, actual.ResourceVersion, int64(1))
+	})
+
+	t.Run("stale version with patch", func(t *testing.T) {
+		resp := updateDestination(t, http.MethodPatch, "0", map[string]string{"version": "0.18.0"})
+		assert.Equal(t, resp.Code, http.StatusConflict, resp.Body.String())
+	})
+
+	t.Run("no version always updates", func(t *testing.T) {
+		resp := updateDestination(t, http.MethodPatch, "", map[string]string{"version": "0.18.0"})
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		var respBody api.Destination
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.Equal(t, respBody.ResourceVersion, int64(2))
+		assert.Equal(t, respBody.Version, "0.18.0")
+	})
+
+	t.Run("ETag from a GET", func(t *testing.T) {
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodGet, "/api/destinations/"+destination.ID.String(), nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+		etag := resp.Header().Get("ETag")
+
+		resp = updateDestination(t, http.MethodPatch, etag, map[string]string{"version": "0.19.0"})
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		// the ETag is stale after the update
+		resp = updateDestination(t, http.MethodPatch, etag, map[string]string{"version": "0.20.0"})
+		assert.Equal(t, resp.Code, http.StatusConflict, resp.Body.String())
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		resp := updateDestination(t, http.MethodPut, `W/"abcdef"`, putBody)
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+	})
+}
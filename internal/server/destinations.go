@@ -7,6 +7,7 @@ import (
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal/access"
+	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
 )
 
@@ -66,13 +67,52 @@ func (a *API) UpdateDestination(c *gin.Context, r *api.UpdateDestinationRequest)
 		Version:       r.Version,
 	}
 
-	if err := access.SaveDestination(c, destination); err != nil {
+	expectedVersion, err := ifMatchResourceVersion(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := access.UpdateDestination(c, destination, expectedVersion); err != nil {
 		return nil, fmt.Errorf("update destination: %w", err)
 	}
 
 	return destination.ToAPI(), nil
 }
 
+// PatchDestination updates the fields of the destination that are set in the
+// request, and leaves the other fields unchanged.
+func (a *API) PatchDestination(c *gin.Context, r *api.PatchDestinationRequest) (*api.Destination, error) {
+	expectedVersion, err := ifMatchResourceVersion(c)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := data.DestinationPatch{
+		Name:     r.Name,
+		UniqueID: r.UniqueID,
+		Version:  r.Version,
+	}
+	if r.Connection != nil {
+		ca := string(r.Connection.CA)
+		patch.ConnectionURL = &r.Connection.URL
+		patch.ConnectionCA = &ca
+	}
+	if r.Resources != nil {
+		resources := models.CommaSeparatedStrings(*r.Resources)
+		patch.Resources = &resources
+	}
+	if r.Roles != nil {
+		roles := models.CommaSeparatedStrings(*r.Roles)
+		patch.Roles = &roles
+	}
+
+	destination, err := access.PatchDestination(c, r.ID, patch, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("update destination: %w", err)
+	}
+	return destination.ToAPI(), nil
+}
+
 func (a *API) DeleteDestination(c *gin.Context, r *api.Resource) (*api.EmptyResponse, error) {
 	return nil, access.DeleteDestination(c, r.ID)
 }
@@ -6,52 +6,84 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/access"
 	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/providers"
 	"github.com/infrahq/infra/internal/validate"
 )
 
+// writeConflictRetryAfterSeconds is the value of the Retry-After header sent
+// when a request fails because of a conflict with a concurrent write.
+const writeConflictRetryAfterSeconds = 1
+
 // sendAPIError translates err into the appropriate HTTP status code, builds a
 // response body using api.Error, then sends both as a response to the active
-// request.
+// request. The response body is JSON unless the Accept header of the request
+// prefers a text or HTML response.
 func sendAPIError(c *gin.Context, err error) {
 	resp := &api.Error{
-		Code:    http.StatusInternalServerError,
-		Message: "internal server error", // don't leak any info by default
+		Code:      http.StatusInternalServerError,
+		ErrorCode: api.ErrorCodeInternal,
+		Message:   "internal server error", // don't leak any info by default
 	}
 
 	var validationError validate.Error
 	var uniqueConstraintError data.UniqueConstraintError
 	var authzError access.AuthorizationError
+	var removedError removedRouteError
+	var bodyTooLargeError requestBodyTooLargeError
+	var rateLimitErr rateLimitError
+	var idempotencyErr idempotencyInProgressError
+	var signupDisabledErr signupDisabledError
+	var loginLockedErr loginLockedError
 
-	log := logging.L.Debug()
+	log := logging.Ctx(c.Request.Context()).Debug()
 
 	switch {
 	case errors.Is(err, internal.ErrUnauthorized):
 		resp.Code = http.StatusUnauthorized
+		resp.ErrorCode = api.ErrorCodeUnauthorized
 		// hide the error text, it may contain sensitive information
 		resp.Message = "unauthorized"
 		// log the error at info because it is not in the response
-		log = logging.L.Info()
+		log = logging.Ctx(c.Request.Context()).Info()
 
 	case errors.Is(err, data.ErrAccessKeyExpired):
 		resp.Code = http.StatusUnauthorized
+		resp.ErrorCode = api.ErrorCodeAccessKeyExpired
+		if errors.Is(err, data.ErrAccessKeyDeadlineExceeded) {
+			resp.ErrorCode = api.ErrorCodeAccessKeyDeadlineExceeded
+		}
 		// this means the key was once valid, so include some extra details
 		resp.Message = fmt.Sprintf("%s: %s", internal.ErrUnauthorized, err)
 
 	case errors.As(err, &authzError):
 		resp.Code = http.StatusForbidden
+		resp.ErrorCode = api.ErrorCodeNotAuthorized
 		resp.Message = authzError.Error()
 
+	case errors.Is(err, access.ErrNotAuthorized):
+		resp.Code = http.StatusForbidden
+		resp.ErrorCode = api.ErrorCodeNotAuthorized
+		resp.Message = err.Error()
+
+	case errors.Is(err, internal.ErrForbidden):
+		resp.Code = http.StatusForbidden
+		resp.ErrorCode = api.ErrorCodeForbidden
+		resp.Message = err.Error()
+
 	case errors.As(err, &uniqueConstraintError):
 		resp.Code = http.StatusConflict
+		resp.ErrorCode = api.ErrorCodeConflict
 		resp.Message = err.Error()
 		// remove the error trace from field error message
 		errMsg := err.Error()
@@ -66,10 +98,12 @@ func sendAPIError(c *gin.Context, err error) {
 
 	case errors.Is(err, internal.ErrNotFound):
 		resp.Code = http.StatusNotFound
+		resp.ErrorCode = api.ErrorCodeNotFound
 		resp.Message = err.Error()
 
 	case errors.As(err, &validationError):
 		resp.Code = http.StatusBadRequest
+		resp.ErrorCode = api.ErrorCodeValidationFailed
 		resp.Message = err.Error()
 		for name, problems := range validationError {
 			resp.FieldErrors = append(resp.FieldErrors, api.FieldError{
@@ -81,28 +115,97 @@ func sendAPIError(c *gin.Context, err error) {
 			return resp.FieldErrors[i].FieldName < resp.FieldErrors[j].FieldName
 		})
 
+	case errors.As(err, &removedError):
+		resp.Code = http.StatusGone
+		resp.ErrorCode = api.ErrorCodeGone
+		resp.Message = removedError.Error()
+
+	case errors.As(err, &bodyTooLargeError):
+		resp.Code = http.StatusRequestEntityTooLarge
+		resp.ErrorCode = api.ErrorCodeRequestTooLarge
+		resp.Message = bodyTooLargeError.Error()
+
+	case errors.As(err, &rateLimitErr):
+		resp.Code = http.StatusTooManyRequests
+		resp.ErrorCode = api.ErrorCodeRateLimited
+		resp.Message = rateLimitErr.Error()
+		c.Header("Retry-After", strconv.Itoa(rateLimitErr.retryAfterSeconds()))
+
+	case errors.As(err, &loginLockedErr):
+		resp.Code = http.StatusTooManyRequests
+		resp.ErrorCode = api.ErrorCodeRateLimited
+		resp.Message = loginLockedErr.Error()
+		c.Header("Retry-After", strconv.Itoa(loginLockedErr.retryAfterSeconds()))
+
+	case errors.As(err, &idempotencyErr):
+		resp.Code = http.StatusConflict
+		resp.ErrorCode = api.ErrorCodeConflict
+		resp.Message = idempotencyErr.Error()
+
+	case errors.Is(err, errTooManyIdempotencyKeys), errors.Is(err, errTooManyUserEventStreams):
+		resp.Code = http.StatusTooManyRequests
+		resp.ErrorCode = api.ErrorCodeRateLimited
+		resp.Message = err.Error()
+
+	case errors.As(err, &signupDisabledErr):
+		resp.Code = http.StatusForbidden
+		resp.ErrorCode = api.ErrorCodeSignupDisabled
+		resp.Message = signupDisabledErr.Error()
+
+	case errors.Is(err, data.ErrResourceVersionConflict),
+		errors.Is(err, data.ErrGroupMembershipFromProvider):
+		resp.Code = http.StatusConflict
+		resp.ErrorCode = api.ErrorCodeConflict
+		resp.Message = err.Error()
+
+	case errors.Is(err, errTooManyEventStreams):
+		resp.Code = http.StatusServiceUnavailable
+		resp.ErrorCode = api.ErrorCodeUnavailable
+		resp.Message = err.Error()
+
+	case errors.Is(err, data.ErrWriteConflict):
+		resp.Code = http.StatusServiceUnavailable
+		resp.ErrorCode = api.ErrorCodeUnavailable
+		resp.Message = "the server is busy, retry the request"
+		c.Header("Retry-After", strconv.Itoa(writeConflictRetryAfterSeconds))
+
+	case errors.Is(err, providers.ErrDeviceAuthorizationPending):
+		resp.Code = http.StatusBadRequest
+		resp.ErrorCode = api.ErrorCodeAuthorizationPending
+		resp.Message = "the device has not been authorized yet, login again with the same device code"
+
+	case errors.Is(err, providers.ErrDeviceAuthorizationSlowDown):
+		resp.Code = http.StatusBadRequest
+		resp.ErrorCode = api.ErrorCodeSlowDown
+		resp.Message = "the device has not been authorized yet, wait longer before logging in again with the same device code"
+
 	case errors.Is(err, internal.ErrExpired):
 		resp.Code = http.StatusGone
+		resp.ErrorCode = api.ErrorCodeExpired
 		resp.Message = "requested resource has expired"
 
 	case errors.Is(err, internal.ErrBadRequest):
 		resp.Code = http.StatusBadRequest
+		resp.ErrorCode = api.ErrorCodeBadRequest
 		resp.Message = err.Error()
 
 	case errors.Is(err, internal.ErrNotImplemented):
 		resp.Code = http.StatusNotImplemented
+		resp.ErrorCode = api.ErrorCodeNotImplemented
 		resp.Message = internal.ErrNotImplemented.Error()
 
 	case errors.Is(err, internal.ErrBadGateway):
 		resp.Code = http.StatusBadGateway
+		resp.ErrorCode = api.ErrorCodeBadGateway
 		resp.Message = err.Error()
 
 	case errors.Is(err, context.DeadlineExceeded):
 		resp.Code = http.StatusGatewayTimeout // not ideal, but StatusRequestTimeout isn't intended for this.
+		resp.ErrorCode = api.ErrorCodeTimeout
 		resp.Message = "request timed out"
 
 	default:
-		log = logging.L.Error()
+		log = logging.Ctx(c.Request.Context()).Error()
 	}
 
 	log.CallerSkipFrame(1).
@@ -113,6 +216,29 @@ func sendAPIError(c *gin.Context, err error) {
 		Str("remoteAddr", c.Request.RemoteAddr).
 		Msg("api request error")
 
-	c.JSON(int(resp.Code), resp)
+	if acceptsJSON(c, true) {
+		c.JSON(int(resp.Code), resp)
+	} else {
+		c.String(int(resp.Code), "%d %s", resp.Code, resp.Message)
+	}
 	c.Abort()
 }
+
+// acceptsJSON uses the Accept header of the request to decide if the response
+// should be JSON or text. preferJSON is used when the request has no Accept
+// header, accepts any type, or does not accept any of the types we can send.
+func acceptsJSON(c *gin.Context, preferJSON bool) bool {
+	offered := []string{binding.MIMEHTML, binding.MIMEPlain, binding.MIMEJSON}
+	if preferJSON {
+		offered = []string{binding.MIMEJSON, binding.MIMEHTML, binding.MIMEPlain}
+	}
+
+	switch c.NegotiateFormat(offered...) {
+	case binding.MIMEJSON:
+		return true
+	case "":
+		return preferJSON
+	default:
+		return false
+	}
+}
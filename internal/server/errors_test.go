@@ -25,24 +25,26 @@ func TestSendAPIError(t *testing.T) {
 	}{
 		{
 			err:    internal.ErrBadRequest,
-			result: api.Error{Code: http.StatusBadRequest, Message: "bad request"},
+			result: api.Error{Code: http.StatusBadRequest, ErrorCode: api.ErrorCodeBadRequest, Message: "bad request"},
 		},
 		{
 			err: fmt.Errorf("not right: %w", internal.ErrBadRequest),
 			result: api.Error{
-				Code:    http.StatusBadRequest,
-				Message: "not right: bad request",
+				Code:      http.StatusBadRequest,
+				ErrorCode: api.ErrorCodeBadRequest,
+				Message:   "not right: bad request",
 			},
 		},
 		{
 			err:    internal.ErrUnauthorized,
-			result: api.Error{Code: http.StatusUnauthorized, Message: "unauthorized"},
+			result: api.Error{Code: http.StatusUnauthorized, ErrorCode: api.ErrorCodeUnauthorized, Message: "unauthorized"},
 		},
 		{
 			err: validate.Error{"fieldname": []string{"is required"}},
 			result: api.Error{
-				Code:    http.StatusBadRequest,
-				Message: "validation failed: fieldname: is required",
+				Code:      http.StatusBadRequest,
+				ErrorCode: api.ErrorCodeValidationFailed,
+				Message:   "validation failed: fieldname: is required",
 				FieldErrors: []api.FieldError{
 					{FieldName: "fieldname", Errors: []string{"is required"}},
 				},
@@ -50,15 +52,23 @@ func TestSendAPIError(t *testing.T) {
 		},
 		{
 			err:    fmt.Errorf("hide this: %w", internal.ErrUnauthorized),
-			result: api.Error{Code: http.StatusUnauthorized, Message: "unauthorized"},
+			result: api.Error{Code: http.StatusUnauthorized, ErrorCode: api.ErrorCodeUnauthorized, Message: "unauthorized"},
 		},
 		{
-			err:    data.ErrAccessKeyExpired,
-			result: api.Error{Code: http.StatusUnauthorized, Message: "unauthorized: " + data.ErrAccessKeyExpired.Error()},
+			err: data.ErrAccessKeyExpired,
+			result: api.Error{
+				Code:      http.StatusUnauthorized,
+				ErrorCode: api.ErrorCodeAccessKeyExpired,
+				Message:   "unauthorized: " + data.ErrAccessKeyExpired.Error(),
+			},
 		},
 		{
-			err:    data.ErrAccessKeyDeadlineExceeded,
-			result: api.Error{Code: http.StatusUnauthorized, Message: "unauthorized: " + data.ErrAccessKeyDeadlineExceeded.Error()},
+			err: data.ErrAccessKeyDeadlineExceeded,
+			result: api.Error{
+				Code:      http.StatusUnauthorized,
+				ErrorCode: api.ErrorCodeAccessKeyDeadlineExceeded,
+				Message:   "unauthorized: " + data.ErrAccessKeyDeadlineExceeded.Error(),
+			},
 		},
 		{
 			err: access.AuthorizationError{
@@ -67,28 +77,50 @@ func TestSendAPIError(t *testing.T) {
 				RequiredRoles: []string{"admin"},
 			},
 			result: api.Error{
-				Code:    http.StatusForbidden,
-				Message: "you do not have permission to create provider, requires role admin",
+				Code:      http.StatusForbidden,
+				ErrorCode: api.ErrorCodeNotAuthorized,
+				Message:   "you do not have permission to create provider, requires role admin",
 			},
 		},
+		{
+			err:    access.ErrNotAuthorized,
+			result: api.Error{Code: http.StatusForbidden, ErrorCode: api.ErrorCodeNotAuthorized, Message: "not authorized"},
+		},
+		{
+			err:    fmt.Errorf("%w: refresh token was revoked", internal.ErrForbidden),
+			result: api.Error{Code: http.StatusForbidden, ErrorCode: api.ErrorCodeForbidden, Message: "forbidden: refresh token was revoked"},
+		},
 		{
 			err:    internal.ErrNotFound,
-			result: api.Error{Code: http.StatusNotFound, Message: "record not found"},
+			result: api.Error{Code: http.StatusNotFound, ErrorCode: api.ErrorCodeNotFound, Message: "record not found"},
 		},
 		{
 			err:    internal.ErrNotImplemented,
-			result: api.Error{Code: http.StatusNotImplemented, Message: "not implemented"},
+			result: api.Error{Code: http.StatusNotImplemented, ErrorCode: api.ErrorCodeNotImplemented, Message: "not implemented"},
+		},
+		{
+			err:    fmt.Errorf("unexpected"),
+			result: api.Error{Code: http.StatusInternalServerError, ErrorCode: api.ErrorCodeInternal, Message: "internal server error"},
 		},
 		{
 			err: data.UniqueConstraintError{Table: "user", Column: "name"},
 			result: api.Error{
-				Code:    http.StatusConflict,
-				Message: "a user with that name already exists",
+				Code:      http.StatusConflict,
+				ErrorCode: api.ErrorCodeConflict,
+				Message:   "a user with that name already exists",
 				FieldErrors: []api.FieldError{
 					{FieldName: "name", Errors: []string{"a user with that name already exists"}},
 				},
 			},
 		},
+		{
+			err: fmt.Errorf("%w: deadlock detected", data.ErrWriteConflict),
+			result: api.Error{
+				Code:      http.StatusServiceUnavailable,
+				ErrorCode: api.ErrorCodeUnavailable,
+				Message:   "the server is busy, retry the request",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -109,9 +141,72 @@ func TestSendAPIError(t *testing.T) {
 			assert.NilError(t, err)
 
 			assert.Equal(t, test.result.Code, actual.Code)
+			assert.Equal(t, test.result.ErrorCode, actual.ErrorCode)
 			assert.Equal(t, test.result.Message, actual.Message)
 
 			assert.DeepEqual(t, test.result.FieldErrors, actual.FieldErrors)
 		})
 	}
+
+	t.Run("write conflict sets Retry-After", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(resp)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/path", nil)
+
+		sendAPIError(c, data.ErrWriteConflict)
+
+		assert.Equal(t, resp.Code, http.StatusServiceUnavailable)
+		assert.Equal(t, resp.Header().Get("Retry-After"), "1")
+	})
+}
+
+func TestSendAPIError_AcceptHeader(t *testing.T) {
+	type testCase struct {
+		name     string
+		accept   string
+		expected func(t *testing.T, resp *httptest.ResponseRecorder)
+	}
+
+	expectJSON := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		assert.Equal(t, resp.Header().Get("Content-Type"), "application/json; charset=utf-8")
+
+		actual := &api.Error{}
+		err := json.NewDecoder(resp.Body).Decode(actual)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, actual, &api.Error{Code: http.StatusNotFound, ErrorCode: api.ErrorCodeNotFound, Message: "record not found"})
+	}
+
+	expectText := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		assert.Equal(t, resp.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+		assert.Equal(t, resp.Body.String(), "404 record not found")
+	}
+
+	testCases := []testCase{
+		{name: "no accept header", expected: expectJSON},
+		{name: "any type", accept: "*/*", expected: expectJSON},
+		{name: "application/json", accept: "application/json", expected: expectJSON},
+		{name: "unsupported type", accept: "image/png", expected: expectJSON},
+		{
+			name:     "browser",
+			accept:   "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			expected: expectText,
+		},
+		{name: "text/plain", accept: "text/plain", expected: expectText},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(resp)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/path", nil)
+			if tc.accept != "" {
+				c.Request.Header.Set("Accept", tc.accept)
+			}
+
+			sendAPIError(c, internal.ErrNotFound)
+
+			assert.Equal(t, resp.Code, http.StatusNotFound)
+			tc.expected(t, resp)
+		})
+	}
 }
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/access"
+	"github.com/infrahq/infra/internal/logging"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+// maxEventStreams is the maximum number of event streams that may be open at
+// the same time, across all organizations.
+const maxEventStreams = 500
+
+// maxEventStreamsPerUser is the maximum number of event streams that one
+// user may have open at the same time.
+const maxEventStreamsPerUser = 5
+
+// eventStreamBufferSize is the number of events that may be waiting to be
+// written to a stream. A stream that falls further behind is closed, and the
+// client is expected to reconnect.
+const eventStreamBufferSize = 100
+
+// eventStreamKeepAliveInterval is the time between comments written to an
+// idle stream, so that proxies do not close the connection.
+var eventStreamKeepAliveInterval = 30 * time.Second
+
+// eventStreamValidateInterval is the time between checks that the access key
+// used to open a stream has not been revoked.
+var eventStreamValidateInterval = time.Minute
+
+var (
+	errTooManyEventStreams     = errors.New("too many event streams are open, try again later")
+	errTooManyUserEventStreams = errors.New("too many event streams are open for this user")
+)
+
+// eventBroker sends audit events to the open event streams of the same
+// organization.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	userCount   map[uid.ID]int
+	closed      bool
+}
+
+type eventSubscriber struct {
+	orgID  uid.ID
+	userID uid.ID
+	events chan models.AuditEvent
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: map[*eventSubscriber]struct{}{},
+		userCount:   map[uid.ID]int{},
+	}
+}
+
+// subscribe returns a subscriber for the user that receives the events of the
+// organization. The events channel is closed when the subscriber falls behind,
+// or when the broker is closed. Call unsubscribe when the subscriber is no
+// longer used.
+func (b *eventBroker) subscribe(orgID, userID uid.ID) (*eventSubscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.closed || len(b.subscribers) >= maxEventStreams:
+		return nil, errTooManyEventStreams
+	case b.userCount[userID] >= maxEventStreamsPerUser:
+		return nil, errTooManyUserEventStreams
+	}
+	sub := &eventSubscriber{
+		orgID:  orgID,
+		userID: userID,
+		events: make(chan models.AuditEvent, eventStreamBufferSize),
+	}
+	b.subscribers[sub] = struct{}{}
+	b.userCount[userID]++
+	return sub, nil
+}
+
+func (b *eventBroker) unsubscribe(sub *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+// remove must be called with the lock held.
+func (b *eventBroker) remove(sub *eventSubscriber) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+		b.userCount[sub.userID]--
+		if b.userCount[sub.userID] <= 0 {
+			delete(b.userCount, sub.userID)
+		}
+	}
+}
+
+// publish sends the event to the subscribers of its organization. publish
+// does not block.
+func (b *eventBroker) publish(event models.AuditEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if sub.orgID != event.OrganizationID {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			logging.L.Warn().Msg("closing an event stream that fell behind")
+			b.remove(sub)
+		}
+	}
+}
+
+// close ends all the open streams, and prevents new streams from opening. It
+// is called when the server shuts down, because the streams would otherwise
+// prevent the connections from becoming idle.
+func (b *eventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+// grantEventStream is the response of StreamGrantEvents. It is written by
+// wrapRoute after the request transaction is committed.
+type grantEventStream struct {
+	broker    *eventBroker
+	db        data.ReadTxn
+	orgID     uid.ID
+	userID    uid.ID
+	accessKey *models.AccessKey
+	filter    func(event models.AuditEvent) bool
+}
+
+// StreamGrantEvents sends an event with the Server-Sent Events protocol every
+// time a grant that is visible to the user is created or deleted.
+func (a *API) StreamGrantEvents(c *gin.Context, _ *api.EmptyRequest) (*grantEventStream, error) {
+	filter, err := access.WatchGrants(c)
+	if err != nil {
+		return nil, err
+	}
+
+	rCtx := access.GetRequestContext(c)
+	return &grantEventStream{
+		broker:    a.server.events,
+		db:        a.server.db,
+		orgID:     rCtx.Authenticated.Organization.ID,
+		userID:    rCtx.Authenticated.User.ID,
+		accessKey: rCtx.Authenticated.AccessKey,
+		filter:    filter,
+	}, nil
+}
+
+// writeStream writes events until the client disconnects, the subscriber is
+// closed, or the access key used to open the stream expires or is revoked.
+// An error is only returned if the stream could not be started.
+func (s *grantEventStream) writeStream(c *gin.Context) error {
+	sub, err := s.broker.subscribe(s.orgID, s.userID)
+	if err != nil {
+		return err
+	}
+	defer s.broker.unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	validate := time.NewTicker(eventStreamValidateInterval)
+	defer validate.Stop()
+
+	expired := time.NewTimer(time.Until(s.accessKey.ExpiresAt))
+	defer expired.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return nil
+		case <-expired.C:
+			return nil
+		case <-validate.C:
+			if !s.accessKeyValid() {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case event, ok := <-sub.events:
+			if !ok {
+				return nil
+			}
+			if !s.filter(event) {
+				continue
+			}
+			if err := writeGrantEvent(c.Writer, event); err != nil {
+				logging.Ctx(c.Request.Context()).Debug().Err(err).Msg("failed to write grant event")
+				return nil
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// accessKeyValid returns false when the access key used to open the stream was
+// deleted, or has expired.
+func (s *grantEventStream) accessKeyValid() bool {
+	key, err := data.GetAccessKey(s.db, data.GetAccessKeysOptions{ByID: s.accessKey.ID})
+	if err != nil {
+		if !errors.Is(err, internal.ErrNotFound) {
+			logging.L.Warn().Err(err).Msg("failed to validate the access key of an event stream")
+		}
+		return false
+	}
+
+	now := time.Now().UTC()
+	if now.After(key.ExpiresAt) {
+		return false
+	}
+	return key.ExtensionDeadline.IsZero() || !now.After(key.ExtensionDeadline)
+}
+
+func writeGrantEvent(w gin.ResponseWriter, event models.AuditEvent) error {
+	grant, ok := access.GrantFromAuditEvent(event)
+	if !ok {
+		return nil
+	}
+	data := api.GrantEvent{
+		ID:        event.ID,
+		Action:    event.Action,
+		Time:      api.Time(event.CreatedAt),
+		GrantID:   grant.ID,
+		Privilege: grant.Privilege,
+		Resource:  grant.Resource,
+	}
+	switch {
+	case grant.Subject.IsIdentity():
+		data.User, _ = grant.Subject.ID()
+	case grant.Subject.IsGroup():
+		data.Group, _ = grant.Subject.ID()
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Action, body)
+	return err
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+func TestEventBroker(t *testing.T) {
+	event := func(orgID uid.ID, name string) models.AuditEvent {
+		return models.AuditEvent{
+			OrganizationMember: models.OrganizationMember{OrganizationID: orgID},
+			Action:             models.AuditActionCreateGrant,
+			TargetName:         name,
+		}
+	}
+
+	t.Run("events are sent to subscribers of the same org", func(t *testing.T) {
+		broker := newEventBroker()
+		first, err := broker.subscribe(1, 100)
+		assert.NilError(t, err)
+		second, err := broker.subscribe(2, 200)
+		assert.NilError(t, err)
+
+		broker.publish(event(1, "one"))
+		broker.publish(event(2, "two"))
+
+		assert.Equal(t, (<-first.events).TargetName, "one")
+		assert.Equal(t, (<-second.events).TargetName, "two")
+		assert.Equal(t, len(first.events), 0)
+		assert.Equal(t, len(second.events), 0)
+	})
+
+	t.Run("subscriber that falls behind is closed", func(t *testing.T) {
+		broker := newEventBroker()
+		sub, err := broker.subscribe(1, 100)
+		assert.NilError(t, err)
+
+		for i := 0; i < eventStreamBufferSize+1; i++ {
+			broker.publish(event(1, "one"))
+		}
+		for i := 0; i < eventStreamBufferSize; i++ {
+			<-sub.events
+		}
+		_, ok := <-sub.events
+		assert.Assert(t, !ok, "expected the events channel to be closed")
+
+		// unsubscribe after the subscriber was removed does not panic
+		broker.unsubscribe(sub)
+	})
+
+	t.Run("number of subscribers is limited", func(t *testing.T) {
+		broker := newEventBroker()
+		for i := 0; i < maxEventStreams; i++ {
+			_, err := broker.subscribe(1, uid.ID(i))
+			assert.NilError(t, err)
+		}
+		_, err := broker.subscribe(1, 100)
+		assert.ErrorIs(t, err, errTooManyEventStreams)
+	})
+
+	t.Run("number of subscribers for a user is limited", func(t *testing.T) {
+		broker := newEventBroker()
+		subs := make([]*eventSubscriber, maxEventStreamsPerUser)
+		for i := range subs {
+			var err error
+			subs[i], err = broker.subscribe(1, 100)
+			assert.NilError(t, err)
+		}
+		_, err := broker.subscribe(1, 100)
+		assert.ErrorIs(t, err, errTooManyUserEventStreams)
+
+		_, err = broker.subscribe(1, 200)
+		assert.NilError(t, err)
+
+		broker.unsubscribe(subs[0])
+		_, err = broker.subscribe(1, 100)
+		assert.NilError(t, err)
+	})
+
+	t.Run("close ends all streams", func(t *testing.T) {
+		broker := newEventBroker()
+		sub, err := broker.subscribe(1, 100)
+		assert.NilError(t, err)
+
+		broker.close()
+		_, ok := <-sub.events
+		assert.Assert(t, !ok, "expected the events channel to be closed")
+
+		_, err = broker.subscribe(1, 100)
+		assert.ErrorIs(t, err, errTooManyEventStreams)
+	})
+}
+
+func TestAPI_StreamGrantEvents(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+	httpSrv := httptest.NewServer(routes)
+	t.Cleanup(httpSrv.Close)
+
+	user := createUser(t, srv, routes, "usera@example.com")
+	other := createUser(t, srv, routes, "userb@example.com")
+
+	userKey, err := data.CreateAccessKey(srv.DB(), &models.AccessKey{
+		IssuedFor:  user.ID,
+		ProviderID: data.InfraProvider(srv.DB()).ID,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	})
+	assert.NilError(t, err)
+
+	openStream := func(t *testing.T, accessKey string) *bufio.Reader {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpSrv.URL+"/api/grants/stream", nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+accessKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+		t.Cleanup(func() {
+			_ = resp.Body.Close()
+		})
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+		assert.Equal(t, resp.Header.Get("Content-Type"), "text/event-stream")
+		return bufio.NewReader(resp.Body)
+	}
+
+	createGrant := func(t *testing.T, userID uid.ID) *api.CreateGrantResponse {
+		t.Helper()
+		body := api.CreateGrantRequest{User: userID, Privilege: "view", Resource: "prod"}
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodPost, "/api/grants", jsonBody(t, body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		grant := &api.CreateGrantResponse{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), grant))
+		return grant
+	}
+
+	t.Run("created grant produces an event", func(t *testing.T) {
+		stream := openStream(t, adminAccessKey(srv))
+		grant := createGrant(t, other.ID)
+
+		name, event := readGrantEvent(t, stream)
+		assert.Equal(t, name, models.AuditActionCreateGrant)
+		assert.Equal(t, event.Action, models.AuditActionCreateGrant)
+		assert.Equal(t, event.GrantID, grant.ID)
+		assert.Equal(t, event.User, other.ID)
+		assert.Equal(t, event.Privilege, "view")
+		assert.Equal(t, event.Resource, "prod")
+	})
+
+	t.Run("user only receives events for their grants", func(t *testing.T) {
+		stream := openStream(t, userKey)
+		createGrant(t, other.ID)
+		grant := createGrant(t, user.ID)
+
+		_, event := readGrantEvent(t, stream)
+		assert.Equal(t, event.GrantID, grant.ID)
+		assert.Equal(t, event.User, user.ID)
+	})
+
+	t.Run("stream is closed when the access key is revoked", func(t *testing.T) {
+		orig := eventStreamValidateInterval
+		eventStreamValidateInterval = 10 * time.Millisecond
+		t.Cleanup(func() {
+			eventStreamValidateInterval = orig
+		})
+
+		key := &models.AccessKey{
+			IssuedFor:  user.ID,
+			ProviderID: data.InfraProvider(srv.DB()).ID,
+			ExpiresAt:  time.Now().Add(time.Minute),
+		}
+		body, err := data.CreateAccessKey(srv.DB(), key)
+		assert.NilError(t, err)
+
+		stream := openStream(t, body)
+		_, err = data.DeleteAccessKeys(srv.DB(), data.DeleteAccessKeysOptions{ByID: key.ID})
+		assert.NilError(t, err)
+		readEndOfStream(t, stream)
+	})
+
+	t.Run("stream is closed when the access key expires", func(t *testing.T) {
+		body, err := data.CreateAccessKey(srv.DB(), &models.AccessKey{
+			IssuedFor:  user.ID,
+			ProviderID: data.InfraProvider(srv.DB()).ID,
+			ExpiresAt:  time.Now().Add(time.Second),
+		})
+		assert.NilError(t, err)
+
+		stream := openStream(t, body)
+		readEndOfStream(t, stream)
+	})
+}
+
+// readEndOfStream reads from the stream until the server closes it.
+func readEndOfStream(t *testing.T, stream *bufio.Reader) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, stream)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the stream to close")
+	}
+}
+
+// readGrantEvent reads the next event from a Server-Sent Events stream.
+func readGrantEvent(t *testing.T, stream *bufio.Reader) (string, api.GrantEvent) {
+	t.Helper()
+	type result struct {
+		name string
+		data string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		var r result
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				r.err = err
+				break
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && r.data != "":
+				ch <- r
+				return
+			case strings.HasPrefix(line, "event: "):
+				r.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				r.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		ch <- r
+	}()
+
+	select {
+	case r := <-ch:
+		assert.NilError(t, r.err)
+		var event api.GrantEvent
+		assert.NilError(t, json.Unmarshal([]byte(r.data), &event))
+		return r.name, event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for an event")
+		return "", api.GrantEvent{}
+	}
+}
@@ -1,22 +1,31 @@
 package server
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/access"
+	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/uid"
 )
 
 func (a *API) ListGrants(c *gin.Context, r *api.ListGrantsRequest) (*api.ListResponse[api.Grant], error) {
 	var subject uid.PolymorphicID
-	p := PaginationFromRequest(r.PaginationRequest)
+	p, err := cursorPaginationFromRequest(r.PaginationRequest, r.Cursor)
+	if err != nil {
+		return nil, err
+	}
 	switch {
 	case r.User != 0:
 		subject = uid.NewIdentityPolymorphicID(r.User)
@@ -24,7 +33,7 @@ func (a *API) ListGrants(c *gin.Context, r *api.ListGrantsRequest) (*api.ListRes
 		subject = uid.NewGroupPolymorphicID(r.Group)
 	}
 
-	grants, err := access.ListGrants(c, subject, r.Resource, r.Privilege, r.ShowInherited, r.ShowSystem, &p)
+	grants, err := access.ListGrants(c, subject, r.Resource, r.Privilege, r.ShowInherited, r.ShowSystem, r.ShowWildcard, &p)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +45,46 @@ func (a *API) ListGrants(c *gin.Context, r *api.ListGrantsRequest) (*api.ListRes
 	return result, nil
 }
 
+// ListEffectiveGrants lists the grants which apply to a user, including the
+// grants inherited from groups, and the source of each grant.
+func (a *API) ListEffectiveGrants(c *gin.Context, r *api.ListEffectiveGrantsRequest) (*api.ListResponse[api.EffectiveGrant], error) {
+	if r.ID.IsSelf {
+		iden := access.GetRequestContext(c).Authenticated.User
+		if iden == nil {
+			return nil, fmt.Errorf("%w: no user is logged in", internal.ErrUnauthorized)
+		}
+		r.ID.ID = iden.ID
+	}
+
+	p := PaginationFromRequest(r.PaginationRequest)
+	grants, err := access.ListGrants(c, uid.NewIdentityPolymorphicID(r.ID.ID), "", nil, true, false, false, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := access.ListGroups(c, "", r.ID.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	groupNames := make(map[uid.PolymorphicID]string, len(groups))
+	for _, group := range groups {
+		groupNames[group.PolyID()] = group.Name
+	}
+
+	result := api.NewListResponse(grants, PaginationToResponse(p), func(grant models.Grant) api.EffectiveGrant {
+		if grant.Subject.IsGroup() {
+			return api.EffectiveGrant{
+				Grant:     grant.ToAPI(),
+				Source:    api.GrantSourceGroup,
+				GroupName: groupNames[grant.Subject],
+			}
+		}
+		return api.EffectiveGrant{Grant: grant.ToAPI(), Source: api.GrantSourceDirect}
+	})
+
+	return result, nil
+}
+
 func (a *API) GetGrant(c *gin.Context, r *api.Resource) (*api.Grant, error) {
 	grant, err := access.GetGrant(c, r.ID)
 	if err != nil {
@@ -45,7 +94,7 @@ func (a *API) GetGrant(c *gin.Context, r *api.Resource) (*api.Grant, error) {
 	return grant.ToAPI(), nil
 }
 
-func (a *API) CreateGrant(c *gin.Context, r *api.CreateGrantRequest) (*api.CreateGrantResponse, error) {
+func grantFromRequest(r api.CreateGrantRequest) *models.Grant {
 	var subject uid.PolymorphicID
 
 	switch {
@@ -60,12 +109,20 @@ func (a *API) CreateGrant(c *gin.Context, r *api.CreateGrantRequest) (*api.Creat
 		Resource:  r.Resource,
 		Privilege: r.Privilege,
 	}
+	if expires := r.Expires.Time(); !expires.IsZero() {
+		grant.ExpiresAt = &expires
+	}
+	return grant
+}
+
+func (a *API) CreateGrant(c *gin.Context, r *api.CreateGrantRequest) (*api.CreateGrantResponse, error) {
+	grant := grantFromRequest(*r)
 
 	err := access.CreateGrant(c, grant)
 	var ucerr data.UniqueConstraintError
 
 	if errors.As(err, &ucerr) {
-		grants, err := access.ListGrants(c, grant.Subject, grant.Resource, grant.Privilege, false, false, nil)
+		grants, err := access.ListGrants(c, grant.Subject, grant.Resource, []string{grant.Privilege}, false, false, false, nil)
 
 		if err != nil {
 			return nil, err
@@ -86,6 +143,50 @@ func (a *API) CreateGrant(c *gin.Context, r *api.CreateGrantRequest) (*api.Creat
 
 }
 
+// CreateGrants creates many grants in a single transaction, so that either
+// all of them are created or none are. Identical grants in the request are
+// only created once.
+func (a *API) CreateGrants(c *gin.Context, r *api.CreateGrantsRequest) (*api.CreateGrantsResponse, error) {
+	type grantKey struct {
+		subject   uid.PolymorphicID
+		privilege string
+		resource  string
+	}
+
+	// positions maps each grant in the request to its position in grants
+	positions := make([]int, len(r.Grants))
+	seen := make(map[grantKey]int, len(r.Grants))
+	grants := make([]*models.Grant, 0, len(r.Grants))
+	for i, item := range r.Grants {
+		grant := grantFromRequest(item)
+		key := grantKey{subject: grant.Subject, privilege: grant.Privilege, resource: grant.Resource}
+		pos, ok := seen[key]
+		if !ok {
+			pos = len(grants)
+			seen[key] = pos
+			grants = append(grants, grant)
+		}
+		positions[i] = pos
+	}
+
+	created, err := access.CreateGrants(c, grants)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.CreateGrantsResponse{Results: make([]api.CreateGrantsResult, len(r.Grants))}
+	reported := make([]bool, len(grants))
+	for i, pos := range positions {
+		resp.Results[i] = api.CreateGrantsResult{
+			Grant:      grants[pos].ToAPI(),
+			WasCreated: created[pos] && !reported[pos],
+			Duplicate:  reported[pos],
+		}
+		reported[pos] = true
+	}
+	return resp, nil
+}
+
 func (a *API) DeleteGrant(c *gin.Context, r *api.Resource) (*api.EmptyResponse, error) {
 	grant, err := access.GetGrant(c, r.ID)
 	if err != nil {
@@ -93,7 +194,7 @@ func (a *API) DeleteGrant(c *gin.Context, r *api.Resource) (*api.EmptyResponse,
 	}
 
 	if grant.Resource == access.ResourceInfraAPI && grant.Privilege == models.InfraAdminRole {
-		infraAdminGrants, err := access.ListGrants(c, "", grant.Resource, grant.Privilege, false, false, nil)
+		infraAdminGrants, err := access.ListGrants(c, "", grant.Resource, []string{grant.Privilege}, false, false, false, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -105,3 +206,284 @@ func (a *API) DeleteGrant(c *gin.Context, r *api.Resource) (*api.EmptyResponse,
 
 	return nil, access.DeleteGrant(c, r.ID)
 }
+
+func (a *API) CheckAuthorization(c *gin.Context, r *api.CheckAuthorizationRequest) (*api.CheckAuthorizationResponse, error) {
+	rCtx := getRequestContext(c)
+
+	queries := make([]access.ResourceQuery, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		queries = append(queries, access.ResourceQuery{Resource: check.Resource, Privileges: check.Privileges})
+	}
+
+	allowed, err := access.CanBatch(rCtx.DBTxn, rCtx.Authenticated.User.PolyID(), queries)
+	if err != nil {
+		return nil, err
+	}
+	return &api.CheckAuthorizationResponse{Allowed: allowed}, nil
+}
+
+// exportGrantsPageSize is the number of grants read from the database for
+// each part of the export stream.
+var exportGrantsPageSize = 500
+
+// grantExport is the response of ExportGrants. It is written by wrapRoute
+// after the request transaction is committed, so that the grants can be read
+// one page at a time while the response is written.
+type grantExport struct {
+	db    *data.DB
+	orgID uid.ID
+}
+
+// ExportGrants writes all the grants in the organization in the format of
+// api.ExportGrantsResponse. The internal connector grant is not included.
+func (a *API) ExportGrants(c *gin.Context, _ *api.EmptyRequest) (*grantExport, error) {
+	if _, err := access.RequireInfraRole(c, models.InfraAdminRole); err != nil {
+		return nil, access.HandleAuthErr(err, "grants", "export", models.InfraAdminRole)
+	}
+
+	rCtx := getRequestContext(c)
+	return &grantExport{db: a.server.db, orgID: rCtx.Authenticated.Organization.ID}, nil
+}
+
+func (e *grantExport) writeStream(c *gin.Context) error {
+	// all the pages are read from the same snapshot, so that a grant created or
+	// deleted while the response is written is not exported twice, or missed.
+	tx, err := e.db.Begin(c.Request.Context(), &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to rollback database transaction")
+		}
+	}()
+	db := tx.WithOrgID(e.orgID)
+
+	// read the first page before writing the status, so that an error can
+	// still be sent as an error response
+	p := &data.Pagination{Limit: exportGrantsPageSize, Cursor: &data.Cursor{}}
+	opts := data.ListGrantsOptions{ExcludeConnectorGrant: true, Pagination: p}
+	grants, err := data.ListGrants(db, opts)
+	if err != nil {
+		return err
+	}
+	names := map[uid.PolymorphicID]string{}
+	items, err := exportedGrants(db, grants, names)
+	if err != nil {
+		return err
+	}
+
+	c.Header("Content-Type", jsonContentType)
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString(`{"grants":[`); err != nil {
+		return nil
+	}
+
+	first := true
+	for {
+		for _, item := range items {
+			body, err := json.Marshal(item)
+			if err != nil {
+				logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to encode exported grant")
+				return nil
+			}
+			if !first {
+				body = append([]byte(","), body...)
+			}
+			first = false
+			if _, err := c.Writer.Write(body); err != nil {
+				return nil
+			}
+		}
+		c.Writer.Flush()
+
+		if p.NextCursor == nil {
+			break
+		}
+		p.Cursor, p.NextCursor = p.NextCursor, nil
+
+		grants, err = data.ListGrants(db, opts)
+		if err == nil {
+			items, err = exportedGrants(db, grants, names)
+		}
+		if err != nil {
+			// the status was already sent, the client finds the response
+			// is not valid JSON.
+			logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to export grants")
+			return nil
+		}
+	}
+
+	_, _ = c.Writer.WriteString("]}\n")
+	return nil
+}
+
+// exportedGrants converts grants to the export format. names caches the name
+// of each subject across calls. Grants for a subject that no longer exists are
+// not exported.
+func exportedGrants(db data.GormTxn, grants []models.Grant, names map[uid.PolymorphicID]string) ([]api.ExportedGrant, error) {
+	var userIDs, groupIDs []uid.ID
+	for _, grant := range grants {
+		if _, ok := names[grant.Subject]; ok {
+			continue
+		}
+		id, err := grant.Subject.ID()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case grant.Subject.IsIdentity():
+			userIDs = append(userIDs, id)
+		case grant.Subject.IsGroup():
+			groupIDs = append(groupIDs, id)
+		}
+		// the subject may not exist, remember that it was looked up
+		names[grant.Subject] = ""
+	}
+
+	if len(userIDs) > 0 {
+		users, err := data.ListIdentities(db, nil, data.ByIDs(userIDs))
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			names[uid.NewIdentityPolymorphicID(user.ID)] = user.Name
+		}
+	}
+	if len(groupIDs) > 0 {
+		groups, err := data.ListGroups(db, nil, data.ByIDs(groupIDs))
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			names[uid.NewGroupPolymorphicID(group.ID)] = group.Name
+		}
+	}
+
+	result := make([]api.ExportedGrant, 0, len(grants))
+	for _, grant := range grants {
+		name := names[grant.Subject]
+		if name == "" {
+			continue
+		}
+		item := api.ExportedGrant{Privilege: grant.Privilege, Resource: grant.Resource}
+		if grant.Subject.IsIdentity() {
+			item.User = name
+		} else {
+			item.Group = name
+		}
+		if grant.ExpiresAt != nil {
+			item.Expires = api.Time(*grant.ExpiresAt)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// ImportGrants creates the grants from an export in a single transaction.
+// Grants that already exist are skipped, and so are grants that have expired.
+func (a *API) ImportGrants(c *gin.Context, r *api.ImportGrantsRequest) (*api.ImportGrantsResponse, error) {
+	db, err := access.RequireInfraRole(c, models.InfraAdminRole)
+	if err != nil {
+		return nil, access.HandleAuthErr(err, "grants", "import", models.InfraAdminRole)
+	}
+
+	resp := &api.ImportGrantsResponse{}
+	subjects := map[string]uid.PolymorphicID{}
+	failures := validate.Error{}
+	now := time.Now()
+
+	grants := make([]*models.Grant, 0, len(r.Grants))
+	for i, item := range r.Grants {
+		expires := item.Expires.Time()
+		if !expires.IsZero() && !expires.After(now) {
+			resp.Expired++
+			continue
+		}
+
+		subject, err := importedGrantSubject(db, item, subjects)
+		var notFound importSubjectNotFoundError
+		switch {
+		case errors.As(err, &notFound):
+			field := fmt.Sprintf("grants[%d].%s", i, notFound.kind)
+			failures[field] = append(failures[field], notFound.Error())
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		grant := &models.Grant{Subject: subject, Privilege: item.Privilege, Resource: item.Resource}
+		if !expires.IsZero() {
+			grant.ExpiresAt = &expires
+		}
+		grants = append(grants, grant)
+	}
+	if len(failures) > 0 {
+		return nil, failures
+	}
+	if len(grants) == 0 {
+		return resp, nil
+	}
+
+	created, err := access.CreateGrants(c, grants)
+	if err != nil {
+		return nil, err
+	}
+	for _, ok := range created {
+		if ok {
+			resp.Created++
+		} else {
+			resp.Skipped++
+		}
+	}
+	return resp, nil
+}
+
+type importSubjectNotFoundError struct {
+	kind string
+	name string
+}
+
+func (e importSubjectNotFoundError) Error() string {
+	return fmt.Sprintf("%s %q does not exist", e.kind, e.name)
+}
+
+// importedGrantSubject returns the subject of item. subjects caches the
+// subject of each name across calls.
+func importedGrantSubject(db data.GormTxn, item api.ExportedGrant, subjects map[string]uid.PolymorphicID) (uid.PolymorphicID, error) {
+	kind, name := "user", item.User
+	if item.Group != "" {
+		kind, name = "group", item.Group
+	}
+	key := kind + ":" + name
+	if subject, ok := subjects[key]; ok {
+		return subject, nil
+	}
+
+	var subject uid.PolymorphicID
+	switch kind {
+	case "user":
+		user, err := data.GetIdentity(db, data.ByName(name))
+		if err != nil {
+			if errors.Is(err, internal.ErrNotFound) {
+				return "", importSubjectNotFoundError{kind: kind, name: name}
+			}
+			return "", err
+		}
+		subject = uid.NewIdentityPolymorphicID(user.ID)
+	default:
+		group, err := data.GetGroup(db, data.ByName(name))
+		if err != nil {
+			if errors.Is(err, internal.ErrNotFound) {
+				return "", importSubjectNotFoundError{kind: kind, name: name}
+			}
+			return "", err
+		}
+		subject = uid.NewGroupPolymorphicID(group.ID)
+	}
+	subjects[key] = subject
+	return subject, nil
+}
@@ -291,9 +291,26 @@ func TestAPI_ListGrants(t *testing.T) {
 					},
 				}
 				assert.DeepEqual(t, grants.Items, expected, cmpAPIGrantShallow)
+				// a page was requested, so the response has no cursor
 				assert.Equal(t, grants.PaginationResponse, api.PaginationResponse{Limit: 2, Page: 2, TotalCount: 5, TotalPages: 3})
 			},
 		},
+		"no filter, first page by cursor": {
+			urlPath: "/api/grants?limit=2&showSystem=true",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+				var grants api.ListResponse[api.Grant]
+				err = json.NewDecoder(resp.Body).Decode(&grants)
+				assert.NilError(t, err)
+
+				assert.Equal(t, len(grants.Items), 2)
+				// more grants follow the page, so the response includes a cursor
+				assert.Assert(t, grants.NextCursor != "")
+			},
+		},
 		"hide infra connector": {
 			urlPath: "/api/grants",
 			setup: func(t *testing.T, req *http.Request) {
@@ -387,6 +404,69 @@ func TestAPI_ListGrants(t *testing.T) {
 				assert.DeepEqual(t, grants.Items, expected, cmpAPIGrantShallow)
 			},
 		},
+		"filter by resource and multiple privileges": {
+			urlPath: "/api/grants?resource=res1&privilege=custom1&privilege=connector",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+				var grants api.ListResponse[api.Grant]
+				err = json.NewDecoder(resp.Body).Decode(&grants)
+				assert.NilError(t, err)
+
+				expected := []api.Grant{
+					{
+						User:      idInGroup,
+						Privilege: "custom1",
+						Resource:  "res1",
+					},
+					{
+						User:      idOther,
+						Privilege: "connector",
+						Resource:  "res1",
+					},
+				}
+				assert.DeepEqual(t, grants.Items, expected, cmpAPIGrantShallow)
+			},
+		},
+		"filter by privilege with no matching resource": {
+			urlPath: "/api/grants?resource=infra&privilege=connector",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+				var grants api.ListResponse[api.Grant]
+				err = json.NewDecoder(resp.Body).Decode(&grants)
+				assert.NilError(t, err)
+
+				// the connector grant for res1 does not match the infra resource,
+				// and the infra connector grant is hidden without showSystem
+				assert.Equal(t, len(grants.Items), 0, grants.Items)
+			},
+		},
+		"bad request, unknown infra privilege": {
+			urlPath: "/api/grants?resource=infra&privilege=admin&privilege=custom1",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{{
+					FieldName: "privilege",
+					Errors: []string{
+						`"custom1" is not a role of the infra resource, must be one of (admin, view, connector, support-admin)`,
+					},
+				}}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
 		"full JSON response": {
 			urlPath: "/api/grants?user=" + idInGroup.String(),
 			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
@@ -406,7 +486,8 @@ func TestAPI_ListGrants(t *testing.T) {
 							"resource": "res1",
 							"user": "%[2]v",
 							"created": "%[3]v",
-							"updated": "%[3]v"
+							"updated": "%[3]v",
+							"expires": null
 						}]
 					}`,
 					admin.ID,
@@ -606,6 +687,186 @@ func TestAPI_ListGrants_InheritedGrants(t *testing.T) {
 	}
 }
 
+func TestAPI_ListGrants_Wildcard(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	user := createUser(t, srv, routes, "wildcard@example.com")
+	subject := uid.NewIdentityPolymorphicID(user.ID)
+	for _, resource := range []string{"kubernetes.*", "kubernetes.prod", "kubernetes", "other.*"} {
+		err := data.CreateGrant(srv.DB(), &models.Grant{Subject: subject, Privilege: "view", Resource: resource})
+		assert.NilError(t, err)
+	}
+
+	listGrants := func(t *testing.T, query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/grants?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resources := func(t *testing.T, resp *httptest.ResponseRecorder) []string {
+		t.Helper()
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+		var grants api.ListResponse[api.Grant]
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &grants))
+
+		var result []string
+		for _, grant := range grants.Items {
+			result = append(result, grant.Resource)
+		}
+		return result
+	}
+
+	t.Run("exact match", func(t *testing.T) {
+		resp := listGrants(t, "resource=kubernetes.prod")
+		assert.DeepEqual(t, resources(t, resp), []string{"kubernetes.prod"})
+	})
+
+	t.Run("with wildcard", func(t *testing.T) {
+		resp := listGrants(t, "resource=kubernetes.prod&showWildcard=true")
+		assert.DeepEqual(t, resources(t, resp), []string{"kubernetes.*", "kubernetes.prod"})
+	})
+
+	t.Run("wildcard does not match the prefix", func(t *testing.T) {
+		resp := listGrants(t, "resource=kubernetes&showWildcard=true")
+		assert.DeepEqual(t, resources(t, resp), []string{"kubernetes"})
+	})
+
+	t.Run("requires a resource", func(t *testing.T) {
+		resp := listGrants(t, "showWildcard=true")
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+	})
+}
+
+func TestAPI_ListEffectiveGrants(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	createUser := func(t *testing.T, name string) uid.ID {
+		t.Helper()
+		user := &models.Identity{Name: name}
+		err := data.CreateIdentity(srv.DB(), user)
+		assert.NilError(t, err)
+		return user.ID
+	}
+
+	mikhail := createUser(t, "mikhail@example.com")
+	other := createUser(t, "other@example.com")
+
+	zoologists := &models.Group{Name: "Zoologists"}
+	err := data.CreateGroup(srv.DB(), zoologists)
+	assert.NilError(t, err)
+	err = data.AddUsersToGroup(srv.DB(), zoologists.ID, []uid.ID{mikhail})
+	assert.NilError(t, err)
+
+	err = data.CreateGrant(srv.DB(), &models.Grant{
+		Subject:   uid.NewIdentityPolymorphicID(mikhail),
+		Privilege: "feed",
+		Resource:  "butterflies",
+	})
+	assert.NilError(t, err)
+	err = data.CreateGrant(srv.DB(), &models.Grant{
+		Subject:   uid.NewGroupPolymorphicID(zoologists.ID),
+		Privilege: "examine",
+		Resource:  "butterflies",
+	})
+	assert.NilError(t, err)
+
+	accessKeyFor := func(t *testing.T, userID uid.ID) string {
+		t.Helper()
+		token := &models.AccessKey{
+			IssuedFor:  userID,
+			ProviderID: data.InfraProvider(srv.DB()).ID,
+			ExpiresAt:  time.Now().Add(10 * time.Minute),
+		}
+		accessKey, err := data.CreateAccessKey(srv.DB(), token)
+		assert.NilError(t, err)
+		return accessKey
+	}
+
+	type testCase struct {
+		urlPath   string
+		accessKey string
+		expected  func(t *testing.T, resp *httptest.ResponseRecorder)
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req, err := http.NewRequest(http.MethodGet, tc.urlPath, nil)
+		assert.NilError(t, err)
+		req.Header.Add("Infra-Version", "0.12.3")
+		if tc.accessKey != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.accessKey)
+		}
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+
+		tc.expected(t, resp)
+	}
+
+	expectedGrants := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		t.Helper()
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		var grants api.ListResponse[api.EffectiveGrant]
+		err := json.NewDecoder(resp.Body).Decode(&grants)
+		assert.NilError(t, err)
+
+		sort.Slice(grants.Items, func(i, j int) bool {
+			return grants.Items[i].Privilege < grants.Items[j].Privilege
+		})
+		expected := []api.EffectiveGrant{
+			{
+				Grant:     &api.Grant{Group: zoologists.ID, Privilege: "examine", Resource: "butterflies"},
+				Source:    api.GrantSourceGroup,
+				GroupName: "Zoologists",
+			},
+			{
+				Grant:  &api.Grant{User: mikhail, Privilege: "feed", Resource: "butterflies"},
+				Source: api.GrantSourceDirect,
+			},
+		}
+		assert.DeepEqual(t, grants.Items, expected, cmpAPIGrantShallow)
+		assert.Equal(t, grants.Items[0].Group, zoologists.ID)
+	}
+
+	testCases := map[string]testCase{
+		"not authenticated": {
+			urlPath: "/api/users/" + mikhail.String() + "/effective-grants",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+			},
+		},
+		"not authorized for another user": {
+			urlPath:   "/api/users/" + mikhail.String() + "/effective-grants",
+			accessKey: accessKeyFor(t, other),
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+			},
+		},
+		"user can list their own grants": {
+			urlPath:   "/api/users/self/effective-grants",
+			accessKey: accessKeyFor(t, mikhail),
+			expected:  expectedGrants,
+		},
+		"admin can list grants of a user": {
+			urlPath:   "/api/users/" + mikhail.String() + "/effective-grants",
+			accessKey: adminAccessKey(srv),
+			expected:  expectedGrants,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
 var cmpAPIGrantShallow = gocmp.Comparer(func(x, y api.Grant) bool {
 	return x.User == y.User && x.Privilege == y.Privilege && x.Resource == y.Resource
 })
@@ -692,6 +953,50 @@ func TestAPI_CreateGrant(t *testing.T) {
 				assert.DeepEqual(t, respBody.FieldErrors, expected)
 			},
 		},
+		"expires in the past": {
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			},
+			body: api.CreateGrantRequest{
+				User:      someUser.ID,
+				Privilege: "view",
+				Resource:  "some-cluster",
+				Expires:   api.Time(time.Now().Add(-time.Minute)),
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "expires", Errors: []string{"must be in the future"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		"with expires": {
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			},
+			body: api.CreateGrantRequest{
+				User:      someUser.ID,
+				Privilege: "view",
+				Resource:  "expiring-cluster",
+				Expires:   api.Time(time.Now().Add(time.Hour)),
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+				respBody := &api.CreateGrantResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.Assert(t, respBody.WasCreated)
+				expires := time.Time(respBody.Expires)
+				assert.Assert(t, time.Until(expires) > 59*time.Minute, "expires=%v", expires)
+			},
+		},
 		"admin for wrong domain": {
 			setup: func(t *testing.T, req *http.Request) {
 				req.Host = "example.com"
@@ -727,6 +1032,7 @@ func TestAPI_CreateGrant(t *testing.T) {
 					"user": "%[3]v",
 					"created": "%[4]v",
 					"updated": "%[4]v",
+					"expires": null,
 					"wasCreated": true
 				}`,
 					accessKey.IssuedFor,
@@ -772,6 +1078,7 @@ func TestAPI_CreateGrant(t *testing.T) {
 					"user": "%[3]v",
 					"created": "%[4]v",
 					"updated": "%[4]v",
+					"expires": null,
 					"wasCreated": true
 				}`,
 					supportAdmin.ID,
@@ -792,6 +1099,167 @@ func TestAPI_CreateGrant(t *testing.T) {
 	}
 }
 
+func TestAPI_CreateGrants(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	user := models.Identity{Name: "someone@example.com"}
+	err := data.CreateIdentity(srv.DB(), &user)
+	assert.NilError(t, err)
+
+	createGrants := func(t *testing.T, body api.CreateGrantsRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/grants/batch", jsonBody(t, body))
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	listGrants := func(t *testing.T, resource string) []models.Grant {
+		t.Helper()
+		grants, err := data.ListGrants(srv.DB(), data.ListGrantsOptions{ByResource: resource})
+		assert.NilError(t, err)
+		return grants
+	}
+
+	t.Run("all created", func(t *testing.T) {
+		resp := createGrants(t, api.CreateGrantsRequest{
+			Grants: []api.CreateGrantRequest{
+				{User: user.ID, Privilege: "view", Resource: "team.alpha"},
+				{User: user.ID, Privilege: "edit", Resource: "team.alpha"},
+			},
+		})
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		var respBody api.CreateGrantsResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &respBody)
+		assert.NilError(t, err)
+
+		expected := []api.CreateGrantsResult{
+			{
+				Grant:      &api.Grant{User: user.ID, Privilege: "view", Resource: "team.alpha"},
+				WasCreated: true,
+			},
+			{
+				Grant:      &api.Grant{User: user.ID, Privilege: "edit", Resource: "team.alpha"},
+				WasCreated: true,
+			},
+		}
+		assert.DeepEqual(t, respBody.Results, expected, cmpAPIGrantShallow)
+		assert.Equal(t, len(listGrants(t, "team.alpha")), 2)
+	})
+
+	t.Run("validation failure creates no grants", func(t *testing.T) {
+		resp := createGrants(t, api.CreateGrantsRequest{
+			Grants: []api.CreateGrantRequest{
+				{User: user.ID, Privilege: "view", Resource: "team.beta"},
+				{User: user.ID, Resource: "team.beta"},
+			},
+		})
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+		var respBody api.Error
+		err := json.Unmarshal(resp.Body.Bytes(), &respBody)
+		assert.NilError(t, err)
+		expected := []api.FieldError{
+			{FieldName: "grants[1].privilege", Errors: []string{"is required"}},
+		}
+		assert.DeepEqual(t, respBody.FieldErrors, expected)
+
+		assert.Equal(t, len(listGrants(t, "team.beta")), 0)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		existing := &models.Grant{Subject: user.PolyID(), Privilege: "view", Resource: "team.gamma"}
+		err := data.CreateGrant(srv.DB(), existing)
+		assert.NilError(t, err)
+
+		resp := createGrants(t, api.CreateGrantsRequest{
+			Grants: []api.CreateGrantRequest{
+				{User: user.ID, Privilege: "view", Resource: "team.gamma"},
+				{User: user.ID, Privilege: "admin", Resource: "team.gamma"},
+				{User: user.ID, Privilege: "admin", Resource: "team.gamma"},
+			},
+		})
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		var respBody api.CreateGrantsResponse
+		err = json.Unmarshal(resp.Body.Bytes(), &respBody)
+		assert.NilError(t, err)
+
+		expected := []api.CreateGrantsResult{
+			{
+				Grant: &api.Grant{User: user.ID, Privilege: "view", Resource: "team.gamma"},
+			},
+			{
+				Grant:      &api.Grant{User: user.ID, Privilege: "admin", Resource: "team.gamma"},
+				WasCreated: true,
+			},
+			{
+				Grant:     &api.Grant{User: user.ID, Privilege: "admin", Resource: "team.gamma"},
+				Duplicate: true,
+			},
+		}
+		assert.DeepEqual(t, respBody.Results, expected, cmpAPIGrantShallow)
+		assert.Equal(t, respBody.Results[0].ID, existing.ID)
+		assert.Equal(t, respBody.Results[1].ID, respBody.Results[2].ID)
+		assert.Equal(t, len(listGrants(t, "team.gamma")), 2)
+	})
+
+	t.Run("database failure rolls back the batch", func(t *testing.T) {
+		// fail the insert of one grant in the middle of the batch
+		_, err := srv.DB().Exec(`
+CREATE FUNCTION fail_grant_insert() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'insert failed for %', NEW.resource;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER fail_grant_insert BEFORE INSERT ON grants
+FOR EACH ROW WHEN (NEW.resource = 'team.fail') EXECUTE FUNCTION fail_grant_insert();
+`)
+		assert.NilError(t, err)
+		t.Cleanup(func() {
+			_, err := srv.DB().Exec(`
+DROP TRIGGER fail_grant_insert ON grants;
+DROP FUNCTION fail_grant_insert;`)
+			assert.NilError(t, err)
+		})
+
+		resp := createGrants(t, api.CreateGrantsRequest{
+			Grants: []api.CreateGrantRequest{
+				{User: user.ID, Privilege: "view", Resource: "team.epsilon"},
+				{User: user.ID, Privilege: "view", Resource: "team.fail"},
+				{User: user.ID, Privilege: "edit", Resource: "team.epsilon"},
+			},
+		})
+		assert.Equal(t, resp.Code, http.StatusInternalServerError, resp.Body.String())
+
+		// the grant created before the failure was rolled back
+		assert.Equal(t, len(listGrants(t, "team.epsilon")), 0)
+		assert.Equal(t, len(listGrants(t, "team.fail")), 0)
+	})
+
+	t.Run("not authorized", func(t *testing.T) {
+		key, _ := createAccessKey(t, srv.DB(), "notadmin@example.com")
+
+		body := api.CreateGrantsRequest{
+			Grants: []api.CreateGrantRequest{{User: user.ID, Privilege: "view", Resource: "team.delta"}},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/grants/batch", jsonBody(t, body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+		assert.Equal(t, len(listGrants(t, "team.delta")), 0)
+	})
+}
+
 func TestAPI_DeleteGrant(t *testing.T) {
 	srv := setupServer(t, withAdminUser, withMultiOrgEnabled)
 	routes := srv.GenerateRoutes()
@@ -899,3 +1367,232 @@ func TestAPI_DeleteGrant(t *testing.T) {
 		assert.Equal(t, resp.Code, http.StatusNoContent, resp.Body.String())
 	})
 }
+
+func TestAPI_CheckAuthorization(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	userKey, user := createAccessKey(t, srv.DB(), "checker@example.com")
+
+	group := &models.Group{Name: "checkers"}
+	createGroups(t, srv.DB(), group)
+	err := data.AddUsersToGroup(srv.DB(), group.ID, []uid.ID{user.ID})
+	assert.NilError(t, err)
+
+	err = data.CreateGrant(srv.DB(), &models.Grant{Subject: user.PolyID(), Privilege: "view", Resource: "kubernetes.prod"})
+	assert.NilError(t, err)
+	err = data.CreateGrant(srv.DB(), &models.Grant{Subject: group.PolyID(), Privilege: "edit", Resource: "kubernetes.staging"})
+	assert.NilError(t, err)
+
+	type testCase struct {
+		body     api.CheckAuthorizationRequest
+		expected func(t *testing.T, resp *httptest.ResponseRecorder)
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req := httptest.NewRequest(http.MethodPost, "/api/grants/check", jsonBody(t, tc.body))
+		req.Header.Set("Authorization", "Bearer "+userKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+
+		tc.expected(t, resp)
+	}
+
+	testCases := map[string]testCase{
+		"missing checks": {
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "checks", Errors: []string{"is required"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		"mixed allowed and denied": {
+			body: api.CheckAuthorizationRequest{
+				Checks: []api.AuthorizationCheck{
+					{Resource: "kubernetes.prod", Privileges: []string{"view"}},
+					{Resource: "kubernetes.staging", Privileges: []string{"view", "edit"}},
+					{Resource: "kubernetes.dev", Privileges: []string{"view"}},
+					{Resource: "infra", Privileges: []string{models.InfraAdminRole}},
+				},
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.CheckAuthorizationResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := map[string]bool{
+					"kubernetes.prod":    true,
+					"kubernetes.staging": true,
+					"kubernetes.dev":     false,
+					"infra":              false,
+				}
+				assert.DeepEqual(t, respBody.Allowed, expected)
+			},
+		},
+		"duplicate resource": {
+			body: api.CheckAuthorizationRequest{
+				Checks: []api.AuthorizationCheck{
+					{Resource: "kubernetes.prod", Privileges: []string{"view"}},
+					{Resource: "kubernetes.prod", Privileges: []string{"edit"}},
+				},
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestAPI_ExportImportGrants(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+	otherOrg := createOtherOrg(t, srv.db)
+
+	// read the export in more than one page
+	exportGrantsPageSize = 2
+	t.Cleanup(func() {
+		exportGrantsPageSize = 500
+	})
+
+	setupSubjects := func(t *testing.T, tx data.GormTxn) (*models.Identity, *models.Group) {
+		t.Helper()
+		user := &models.Identity{Name: "alice@example.com"}
+		assert.NilError(t, data.CreateIdentity(tx, user))
+		group := &models.Group{Name: "developers"}
+		assert.NilError(t, data.CreateGroup(tx, group))
+		return user, group
+	}
+
+	user, group := setupSubjects(t, srv.DB())
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	for _, grant := range []*models.Grant{
+		{Subject: user.PolyID(), Privilege: "view", Resource: "team.alpha"},
+		{Subject: uid.NewGroupPolymorphicID(group.ID), Privilege: "edit", Resource: "team.beta"},
+		{Subject: user.PolyID(), Privilege: "admin", Resource: "team.gamma", ExpiresAt: &expires},
+	} {
+		assert.NilError(t, data.CreateGrant(srv.DB(), grant))
+	}
+
+	tx := txnForTestCase(t, srv.db).WithOrgID(otherOrg.Organization.ID)
+	setupSubjects(t, tx)
+	assert.NilError(t, data.CreateIdentity(tx, &models.Identity{Name: "admin@example.com"}))
+	assert.NilError(t, tx.Commit())
+
+	exportGrants := func(t *testing.T, host, accessKey string) []api.ExportedGrant {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/grants/export", nil)
+		if host != "" {
+			req.Host = host
+		}
+		req.Header.Set("Authorization", "Bearer "+accessKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		var respBody api.ExportGrantsResponse
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		sort.Slice(respBody.Grants, func(i, j int) bool {
+			return respBody.Grants[i].Resource < respBody.Grants[j].Resource
+		})
+		return respBody.Grants
+	}
+
+	importGrants := func(t *testing.T, body api.ImportGrantsRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/grants/import", jsonBody(t, body))
+		req.Host = otherOrg.Organization.Domain
+		req.Header.Set("Authorization", "Bearer "+otherOrg.AdminAccessKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	exported := exportGrants(t, "", adminAccessKey(srv))
+	expected := []api.ExportedGrant{
+		{User: "admin@example.com", Privilege: "admin", Resource: "infra"},
+		{User: "alice@example.com", Privilege: "view", Resource: "team.alpha"},
+		{Group: "developers", Privilege: "edit", Resource: "team.beta"},
+		{User: "alice@example.com", Privilege: "admin", Resource: "team.gamma", Expires: api.Time(expires)},
+	}
+	assert.DeepEqual(t, exported, expected)
+
+	t.Run("import into another org", func(t *testing.T) {
+		resp := importGrants(t, api.ImportGrantsRequest{Grants: exported})
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		var respBody api.ImportGrantsResponse
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.DeepEqual(t, respBody, api.ImportGrantsResponse{Created: 4})
+
+		actual := exportGrants(t, otherOrg.Organization.Domain, otherOrg.AdminAccessKey)
+		// the other org also has a grant for its own admin
+		var imported []api.ExportedGrant
+		for _, grant := range actual {
+			if grant.User != otherOrg.Admin.Name {
+				imported = append(imported, grant)
+			}
+		}
+		assert.DeepEqual(t, imported, exported)
+	})
+
+	t.Run("existing grants are skipped", func(t *testing.T) {
+		resp := importGrants(t, api.ImportGrantsRequest{Grants: exported})
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		var respBody api.ImportGrantsResponse
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.DeepEqual(t, respBody, api.ImportGrantsResponse{Skipped: 4})
+	})
+
+	t.Run("expired grants are not imported", func(t *testing.T) {
+		resp := importGrants(t, api.ImportGrantsRequest{Grants: []api.ExportedGrant{
+			{User: "alice@example.com", Privilege: "view", Resource: "team.delta", Expires: api.Time(time.Now().Add(-time.Minute))},
+		}})
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		var respBody api.ImportGrantsResponse
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.DeepEqual(t, respBody, api.ImportGrantsResponse{Expired: 1})
+	})
+
+	t.Run("unknown subject imports no grants", func(t *testing.T) {
+		resp := importGrants(t, api.ImportGrantsRequest{Grants: []api.ExportedGrant{
+			{User: "alice@example.com", Privilege: "view", Resource: "team.epsilon"},
+			{Group: "missing", Privilege: "view", Resource: "team.epsilon"},
+		}})
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+		var respBody api.Error
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		expected := []api.FieldError{
+			{FieldName: "grants[1].group", Errors: []string{`group "missing" does not exist`}},
+		}
+		assert.DeepEqual(t, respBody.FieldErrors, expected)
+
+		tx := txnForTestCase(t, srv.db).WithOrgID(otherOrg.Organization.ID)
+		grants, err := data.ListGrants(tx, data.ListGrantsOptions{ByResource: "team.epsilon"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(grants), 0)
+	})
+}
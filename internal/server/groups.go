@@ -56,3 +56,11 @@ func (a *API) DeleteGroup(c *gin.Context, r *api.Resource) (*api.EmptyResponse,
 func (a *API) UpdateUsersInGroup(c *gin.Context, r *api.UpdateUsersInGroupRequest) (*api.EmptyResponse, error) {
 	return nil, access.UpdateUsersInGroup(c, r.GroupID, r.UserIDsToAdd, r.UserIDsToRemove)
 }
+
+func (a *API) AddUserToGroup(c *gin.Context, r *api.AddUserToGroupRequest) (*api.EmptyResponse, error) {
+	return nil, access.AddUserToGroup(c, r.GroupID, r.UserID)
+}
+
+func (a *API) RemoveUserFromGroup(c *gin.Context, r *api.RemoveUserFromGroupRequest) (*api.EmptyResponse, error) {
+	return nil, access.RemoveUserFromGroup(c, r.GroupID, r.UserID)
+}
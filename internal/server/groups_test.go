@@ -472,6 +472,138 @@ func TestAPI_UpdateUsersInGroup(t *testing.T) {
 	}
 }
 
+func TestAPI_AddUserToGroup(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	humans := models.Group{Name: "humans"}
+	createGroups(t, srv.DB(), &humans)
+
+	first := models.Identity{Name: "first@example.com"}
+	createIdentities(t, srv.DB(), &first)
+
+	run := func(t *testing.T, groupID uid.ID, body api.AddUserToGroupRequest, accessKey string) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/groups/%s/users", groupID), jsonBody(t, body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+accessKey)
+		req.Header.Add("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("add user", func(t *testing.T) {
+		resp := run(t, humans.ID, api.AddUserToGroupRequest{UserID: first.ID}, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		idents, err := data.ListIdentities(srv.DB(), nil, data.ByOptionalIdentityGroupID(humans.ID))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, idents, []models.Identity{first}, cmpModelsIdentityShallow)
+	})
+
+	t.Run("add user again", func(t *testing.T) {
+		resp := run(t, humans.ID, api.AddUserToGroupRequest{UserID: first.ID}, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		resp := run(t, humans.ID, api.AddUserToGroupRequest{UserID: 1337}, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+	})
+
+	t.Run("unknown group", func(t *testing.T) {
+		resp := run(t, 1337, api.AddUserToGroupRequest{UserID: first.ID}, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusNotFound, resp.Body.String())
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		resp := run(t, humans.ID, api.AddUserToGroupRequest{}, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		key, err := data.CreateAccessKey(srv.DB(), &models.AccessKey{
+			IssuedFor:  first.ID,
+			ProviderID: data.InfraProvider(srv.DB()).ID,
+			ExpiresAt:  time.Now().Add(time.Minute),
+		})
+		assert.NilError(t, err)
+
+		resp := run(t, humans.ID, api.AddUserToGroupRequest{UserID: first.ID}, key)
+		assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+	})
+}
+
+func TestAPI_RemoveUserFromGroup(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	humans := models.Group{Name: "humans"}
+	createGroups(t, srv.DB(), &humans)
+
+	var (
+		first  = models.Identity{Name: "first@example.com"}
+		second = models.Identity{Name: "second@example.com"}
+	)
+	createIdentities(t, srv.DB(), &first, &second)
+
+	assert.NilError(t, data.AddUsersToGroup(srv.DB(), humans.ID, []uid.ID{first.ID}))
+
+	provider := &models.Provider{Name: "okta", Kind: models.ProviderKindOkta}
+	assert.NilError(t, data.CreateProvider(srv.DB(), provider))
+	_, err := srv.DB().Exec("INSERT INTO identities_groups (identity_id, group_id, created_by_provider) VALUES (?, ?, ?)",
+		second.ID, humans.ID, provider.ID)
+	assert.NilError(t, err)
+
+	run := func(t *testing.T, groupID, userID uid.ID) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("/api/groups/%s/users/%s", groupID, userID), nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Add("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("remove user", func(t *testing.T) {
+		resp := run(t, humans.ID, first.ID)
+		assert.Equal(t, resp.Code, http.StatusNoContent, resp.Body.String())
+
+		idents, err := data.ListIdentities(srv.DB(), nil, data.ByOptionalIdentityGroupID(humans.ID))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, idents, []models.Identity{second}, cmpModelsIdentityShallow)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		resp := run(t, humans.ID, first.ID)
+		assert.Equal(t, resp.Code, http.StatusNotFound, resp.Body.String())
+	})
+
+	t.Run("added by a provider", func(t *testing.T) {
+		resp := run(t, humans.ID, second.ID)
+		assert.Equal(t, resp.Code, http.StatusConflict, resp.Body.String())
+
+		var respBody api.Error
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		assert.Equal(t, respBody.ErrorCode, api.ErrorCodeConflict)
+
+		idents, err := data.ListIdentities(srv.DB(), nil, data.ByOptionalIdentityGroupID(humans.ID))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, idents, []models.Identity{second}, cmpModelsIdentityShallow)
+	})
+
+	t.Run("unknown group", func(t *testing.T) {
+		resp := run(t, 1337, first.ID)
+		assert.Equal(t, resp.Code, http.StatusNotFound, resp.Body.String())
+	})
+}
+
 var cmpModelsIdentityShallow = cmp.Comparer(func(x, y models.Identity) bool {
 	return x.Name == y.Name
 })
@@ -9,7 +9,6 @@ import (
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gin-gonic/gin"
-	"github.com/infrahq/secrets"
 	"gopkg.in/square/go-jose.v2"
 
 	"github.com/infrahq/infra/api"
@@ -17,6 +16,7 @@ import (
 	"github.com/infrahq/infra/internal/access"
 	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/authn"
+	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/email"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/internal/server/providers"
@@ -27,6 +27,13 @@ type API struct {
 	server     *Server
 	migrations []apiMigration
 	openAPIDoc openapi3.T
+
+	// idempotency stores the responses of requests with an Idempotency-Key.
+	idempotency *idempotencyCache
+
+	// deprecations are the routes added with a deprecatedSince or
+	// removedIn version, in the order they were added.
+	deprecations []api.DeprecatedRoute
 }
 
 func (a *API) CreateToken(c *gin.Context, r *api.EmptyRequest) (*api.CreateTokenResponse, error) {
@@ -35,6 +42,10 @@ func (a *API) CreateToken(c *gin.Context, r *api.EmptyRequest) (*api.CreateToken
 	if rCtx.Authenticated.User != nil {
 		err := a.UpdateIdentityInfoFromProvider(rCtx)
 		if err != nil {
+			if errors.Is(err, internal.ErrForbidden) {
+				// the session was revoked at the identity provider, the user must login again
+				deleteCookie(c, a.server.options.Cookie.name(cookieAuthorizationName), c.Request.Host)
+			}
 			// this will fail if the user was removed from the IDP, which means they no longer are a valid user
 			return nil, fmt.Errorf("%w: failed to update identity info from provider: %s", internal.ErrUnauthorized, err)
 		}
@@ -50,11 +61,17 @@ func (a *API) CreateToken(c *gin.Context, r *api.EmptyRequest) (*api.CreateToken
 	return nil, fmt.Errorf("%w: no identity found in access key", internal.ErrUnauthorized)
 }
 
+// jwksCacheMaxAge is the amount of time a client may cache the JWKs. It is
+// much shorter than data.SigningKeyGracePeriod, so that clients find the new
+// key long before a retired key is no longer published.
+const jwksCacheMaxAge = data.SigningKeyGracePeriod / 12
+
 var wellKnownJWKsRoute = route[api.EmptyRequest, WellKnownJWKResponse]{
 	handler:                    wellKnownJWKsHandler,
 	omitFromDocs:               true,
 	omitFromTelemetry:          true,
 	infraVersionHeaderOptional: true,
+	cacheMaxAge:                jwksCacheMaxAge,
 }
 
 func wellKnownJWKsHandler(c *gin.Context, _ *api.EmptyRequest) (WellKnownJWKResponse, error) {
@@ -71,9 +88,39 @@ type WellKnownJWKResponse struct {
 	Keys []jose.JSONWebKey `json:"keys"`
 }
 
+// signupDisabled returns the reason signup is disabled, or nil when signup is
+// enabled.
+func (s *Server) signupDisabled() *api.SignupDisabled {
+	switch {
+	case !s.options.EnableSignup:
+		return &api.SignupDisabled{
+			Reason:  api.SignupDisabledReasonNotEnabled,
+			Message: "signup is not enabled on this server",
+		}
+	case s.options.DisableSignup:
+		message := s.options.SignupDisabledMessage
+		if message == "" {
+			message = "signup is disabled"
+		}
+		return &api.SignupDisabled{
+			Reason:  api.SignupDisabledReasonDisabled,
+			Message: message,
+		}
+	}
+	return nil
+}
+
+type signupDisabledError struct {
+	disabled *api.SignupDisabled
+}
+
+func (e signupDisabledError) Error() string {
+	return e.disabled.Message
+}
+
 func (a *API) Signup(c *gin.Context, r *api.SignupRequest) (*api.SignupResponse, error) {
-	if !a.server.options.EnableSignup {
-		return nil, fmt.Errorf("%w: signup is disabled", internal.ErrBadRequest)
+	if disabled := a.server.signupDisabled(); disabled != nil {
+		return nil, signupDisabledError{disabled: disabled}
 	}
 
 	keyExpires := time.Now().UTC().Add(a.server.options.SessionDuration)
@@ -97,10 +144,11 @@ func (a *API) Signup(c *gin.Context, r *api.SignupRequest) (*api.SignupResponse,
 		but we want auth cookies to only be sent to their respective orgs so they must be set on their org specific sub-domain after redirect.
 	*/
 	cookie := cookieConfig{
-		Name:    cookieSignupName,
-		Value:   bearer,
-		Domain:  a.server.options.BaseDomain,
-		Expires: time.Now().Add(1 * time.Minute),
+		Name:     a.server.options.Cookie.name(cookieSignupName),
+		Value:    bearer,
+		Domain:   a.server.options.BaseDomain,
+		Expires:  time.Now().Add(1 * time.Minute),
+		SameSite: a.server.options.Cookie.sameSiteMode(),
 	}
 	setCookie(c, cookie)
 
@@ -115,7 +163,7 @@ func (a *API) Signup(c *gin.Context, r *api.SignupRequest) (*api.SignupResponse,
 	})
 	if err != nil {
 		// if email failed, continue on anyway.
-		logging.L.Error().Err(err).Msg("could not send signup email")
+		logging.Ctx(c.Request.Context()).Error().Err(err).Msg("could not send signup email")
 	}
 
 	return &api.SignupResponse{
@@ -133,6 +181,7 @@ func (a *API) Login(c *gin.Context, r *api.LoginRequest) (*api.LoginResponse, er
 	rCtx := getRequestContext(c)
 
 	var loginMethod authn.LoginMethod
+	providerName := models.InternalInfraProviderName
 	switch {
 	case r.AccessKey != "":
 		loginMethod = authn.NewKeyExchangeAuthentication(r.AccessKey)
@@ -150,29 +199,94 @@ func (a *API) Login(c *gin.Context, r *api.LoginRequest) (*api.LoginResponse, er
 		}
 
 		loginMethod = authn.NewOIDCAuthentication(r.OIDC.ProviderID, r.OIDC.RedirectURL, r.OIDC.Code, providerClient)
+		providerName = provider.Name
+	case r.IDToken != nil:
+		provider, err := access.GetProvider(c, r.IDToken.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity provider: %w", err)
+		}
+
+		providerClient, err := a.providerClient(c, provider, "")
+		if err != nil {
+			return nil, fmt.Errorf("update provider client: %w", err)
+		}
+
+		loginMethod = authn.NewIDTokenAuthentication(r.IDToken.ProviderID, r.IDToken.IDToken, providerClient)
+		providerName = provider.Name
+	case r.Device != nil:
+		provider, err := access.GetProvider(c, r.Device.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity provider: %w", err)
+		}
+
+		providerClient, err := a.providerClient(c, provider, "")
+		if err != nil {
+			return nil, fmt.Errorf("update provider client: %w", err)
+		}
+
+		loginMethod = authn.NewDeviceAuthentication(r.Device.ProviderID, r.Device.DeviceCode, providerClient)
+		providerName = provider.Name
 	default:
 		// make sure to always fail by default
 		return nil, fmt.Errorf("%w: missing login credentials", internal.ErrBadRequest)
 	}
 
 	// do the actual login now that we know the method selected
-	expires := time.Now().UTC().Add(a.server.options.SessionDuration)
-	result, err := authn.Login(rCtx.Request.Context(), rCtx.DBTxn, loginMethod, expires, a.server.options.SessionExtensionDeadline)
+	sessionDuration, sessionExtension, err := a.sessionDurations(rCtx.DBTxn)
 	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().UTC().Add(sessionDuration)
+	result, err := authn.Login(rCtx.Request.Context(), rCtx.DBTxn, loginMethod, expires, sessionExtension)
+	a.server.authnMetrics.login(providerName, err)
+	if err != nil {
+		var locked authn.LoginLockedError
+		if errors.As(err, &locked) {
+			return nil, loginLockedError{retryAfter: time.Until(locked.Until)}
+		}
+		var invalidPassword authn.InvalidPasswordError
+		if errors.As(err, &invalidPassword) {
+			if err := a.recordFailedLogin(c, invalidPassword.IdentityID); err != nil {
+				logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to record failed login attempt")
+			}
+		}
+		var unknownUser authn.UnknownUserError
+		if errors.As(err, &unknownUser) {
+			err := a.recordFailedUnknownLogin(c, unknownUser.Username)
+			var locked loginLockedError
+			switch {
+			case errors.As(err, &locked):
+				return nil, err
+			case err != nil:
+				logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to record failed login attempt")
+			}
+		}
 		if errors.Is(err, internal.ErrBadGateway) {
 			// the user should be shown this explicitly
 			// this means an external request failed, probably to an IDP
 			return nil, err
 		}
+		if errors.Is(err, internal.ErrBadRequest) {
+			// the client used a redirect URL the provider does not allow
+			return nil, err
+		}
+		if errors.Is(err, providers.ErrDeviceAuthorizationPending) ||
+			errors.Is(err, providers.ErrDeviceAuthorizationSlowDown) {
+			// the client should login again with the same device code
+			return nil, err
+		}
 		// all other failures from login should result in an unauthorized response
 		return nil, fmt.Errorf("%w: login failed: %v", internal.ErrUnauthorized, err)
 	}
+	// login with an identity provider updates the groups of the user
+	access.ClearAuthorizationCache(rCtx.DBTxn)
 
 	cookie := cookieConfig{
-		Name:    cookieAuthorizationName,
-		Value:   result.Bearer,
-		Domain:  c.Request.Host,
-		Expires: result.AccessKey.ExpiresAt,
+		Name:     a.server.options.Cookie.name(cookieAuthorizationName),
+		Value:    result.Bearer,
+		Domain:   c.Request.Host,
+		Expires:  result.AccessKey.ExpiresAt,
+		SameSite: a.server.options.Cookie.sameSiteMode(),
 	}
 	setCookie(c, cookie)
 
@@ -201,7 +315,7 @@ func (a *API) Logout(c *gin.Context, _ *api.EmptyRequest) (*api.EmptyResponse, e
 		return nil, err
 	}
 
-	deleteCookie(c, cookieAuthorizationName, c.Request.Host)
+	deleteCookie(c, a.server.options.Cookie.name(cookieAuthorizationName), c.Request.Host)
 	return nil, nil
 }
 
@@ -209,6 +323,14 @@ func (a *API) Version(c *gin.Context, r *api.EmptyRequest) (*api.Version, error)
 	return &api.Version{Version: internal.FullVersion()}, nil
 }
 
+// ListDeprecatedRoutes lists the routes that are deprecated or have been
+// removed, so that clients can find them without parsing response headers.
+func (a *API) ListDeprecatedRoutes(c *gin.Context, r *api.EmptyRequest) (*api.ListResponse[api.DeprecatedRoute], error) {
+	return api.NewListResponse(a.deprecations, api.PaginationResponse{}, func(route api.DeprecatedRoute) api.DeprecatedRoute {
+		return route
+	}), nil
+}
+
 // UpdateIdentityInfoFromProvider calls the identity provider used to authenticate this user session to update their current information
 func (a *API) UpdateIdentityInfoFromProvider(rCtx access.RequestContext) error {
 	provider, redirectURL, err := access.GetContextProviderIdentity(rCtx)
@@ -225,7 +347,11 @@ func (a *API) UpdateIdentityInfoFromProvider(rCtx access.RequestContext) error {
 		return fmt.Errorf("update provider client: %w", err)
 	}
 
-	return access.UpdateIdentityInfoFromProvider(rCtx, oidc)
+	if err := access.UpdateIdentityInfoFromProvider(rCtx, oidc); err != nil {
+		a.server.authnMetrics.refreshFailed(provider.Name)
+		return err
+	}
+	return nil
 }
 
 func (a *API) providerClient(ctx context.Context, provider *models.Provider, redirectURL string) (providers.OIDCClient, error) {
@@ -234,11 +360,5 @@ func (a *API) providerClient(ctx context.Context, provider *models.Provider, red
 		return c, nil
 	}
 
-	clientSecret, err := secrets.GetSecret(string(provider.ClientSecret), a.server.secrets)
-	if err != nil {
-		logging.Debugf("could not get client secret: %s", err)
-		return nil, fmt.Errorf("client secret not found")
-	}
-
-	return providers.NewOIDCClient(*provider, clientSecret, redirectURL), nil
+	return providers.NewOIDCClient(*provider, string(provider.ClientSecret), redirectURL, a.server.secrets), nil
 }
@@ -94,7 +94,7 @@ func loginAs(tx *data.Transaction, user *models.Identity) *gin.Context {
 
 func txnForTestCase(t *testing.T, db *data.DB) *data.Transaction {
 	t.Helper()
-	tx, err := db.Begin(context.Background())
+	tx, err := db.Begin(context.Background(), nil)
 	assert.NilError(t, err)
 	t.Cleanup(func() {
 		assert.NilError(t, tx.Rollback())
@@ -330,6 +330,26 @@ func TestWellKnownJWKs(t *testing.T) {
 				assert.DeepEqual(t, response.Keys[0], otherOrgKey)
 			},
 		},
+		{
+			name: "cache headers",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+				assert.Equal(t, resp.Header().Get("Cache-Control"), "public, max-age=300")
+
+				etag := resp.Header().Get("ETag")
+				assert.Assert(t, etag != "")
+
+				// nolint:noctx
+				req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+				assert.NilError(t, err)
+				req.Header.Set("If-None-Match", etag)
+
+				notModified := httptest.NewRecorder()
+				routes.ServeHTTP(notModified, req)
+				assert.Equal(t, notModified.Code, http.StatusNotModified)
+				assert.Equal(t, notModified.Body.Len(), 0)
+			},
+		},
 		{
 			name: "unknown org",
 			setup: func(t *testing.T, req *http.Request) {
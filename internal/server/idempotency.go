@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/infrahq/infra/internal"
+)
+
+const (
+	// idempotencyKeyHeader is the request header used by clients to make a
+	// POST request safe to retry.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader is set on a response that was stored by an
+	// earlier request with the same Idempotency-Key.
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// idempotencyKeyTTL is the amount of time a response is stored for an
+	// Idempotency-Key.
+	idempotencyKeyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLength is the maximum length of an Idempotency-Key.
+	maxIdempotencyKeyLength = 255
+	// maxIdempotencyKeysPerUser is the maximum number of unexpired
+	// Idempotency-Keys stored for each user.
+	maxIdempotencyKeysPerUser = 1000
+	// maxIdempotencyKeys is the maximum number of unexpired Idempotency-Keys
+	// stored for all users.
+	maxIdempotencyKeys = 100_000
+)
+
+var errTooManyIdempotencyKeys = errors.New("too many requests with an " + idempotencyKeyHeader + ", try again later")
+
+// idempotencyCache stores the responses to requests that include an
+// Idempotency-Key header, so that a retry of the request receives the original
+// response instead of creating another resource. Keys are scoped to the
+// organization and user that made the request, and to the route. A nil
+// idempotencyCache stores nothing.
+//
+// Responses are stored in memory, so a retry that reaches a different server
+// is not deduplicated. The number of entries is limited for each user, and
+// overall. Routes that respond with a secret store a different response,
+// without the secret, so that the secret is not kept in memory.
+type idempotencyCache struct {
+	ttl        time.Duration
+	now        func() time.Time
+	maxPerUser int
+	maxEntries int
+
+	mu        sync.Mutex
+	entries   map[idempotencyCacheKey]*idempotencyEntry
+	userCount map[idempotencyUser]int
+	lastPurge time.Time
+}
+
+type idempotencyCacheKey struct {
+	idempotencyUser
+	method string
+	path   string
+	key    string
+}
+
+type idempotencyUser struct {
+	organization string
+	user         string
+}
+
+type idempotencyEntry struct {
+	requestHash [sha256.Size]byte
+	// done is false while the first request with the key is in progress.
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:        ttl,
+		now:        time.Now,
+		maxPerUser: maxIdempotencyKeysPerUser,
+		maxEntries: maxIdempotencyKeys,
+		entries:    map[idempotencyCacheKey]*idempotencyEntry{},
+		userCount:  map[idempotencyUser]int{},
+	}
+}
+
+// idempotentRequest is a request with an Idempotency-Key that is in progress.
+// release must be called when the request is finished.
+type idempotentRequest struct {
+	cache     *idempotencyCache
+	key       idempotencyCacheKey
+	completed bool
+}
+
+// begin starts an idempotent request. When a previous request with the same
+// key has completed, begin returns the stored entry, and the caller should send
+// it as the response. When the request does not have an Idempotency-Key header,
+// both return values are nil. When too many keys are stored for the user, or
+// for all users, begin returns errTooManyIdempotencyKeys.
+func (i *idempotencyCache) begin(c *gin.Context, routeID routeIdentifier) (*idempotentRequest, *idempotencyEntry, error) {
+	value := c.GetHeader(idempotencyKeyHeader)
+	if i == nil || value == "" {
+		return nil, nil, nil
+	}
+	if len(value) > maxIdempotencyKeyLength {
+		return nil, nil, fmt.Errorf("%w: %v header must be at most %d characters",
+			internal.ErrBadRequest, idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+
+	hash, err := hashRequest(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rCtx := getRequestContext(c)
+	key := idempotencyCacheKey{
+		method: routeID.method,
+		path:   routeID.path,
+		key:    value,
+	}
+	if org := rCtx.Authenticated.Organization; org != nil {
+		key.organization = org.ID.String()
+	}
+	if user := rCtx.Authenticated.User; user != nil {
+		key.user = user.ID.String()
+	}
+
+	now := i.now()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.purgeExpired(now)
+
+	entry, ok := i.entries[key]
+	switch {
+	case !ok:
+		if i.full(key.idempotencyUser) {
+			// an expired entry may still be counted, check again after
+			// removing them.
+			i.lastPurge = time.Time{}
+			i.purgeExpired(now)
+			if i.full(key.idempotencyUser) {
+				return nil, nil, errTooManyIdempotencyKeys
+			}
+		}
+		i.add(key, &idempotencyEntry{requestHash: hash, expiresAt: now.Add(i.ttl)})
+		return &idempotentRequest{cache: i, key: key}, nil, nil
+	case entry.requestHash != hash:
+		return nil, nil, fmt.Errorf("%w: %v was already used for a different request",
+			internal.ErrBadRequest, idempotencyKeyHeader)
+	case !entry.done:
+		return nil, nil, idempotencyInProgressError{}
+	default:
+		return nil, entry, nil
+	}
+}
+
+// complete stores the response for the request.
+func (r *idempotentRequest) complete(status int, contentType string, body []byte) {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	r.completed = true
+	if entry, ok := r.cache.entries[r.key]; ok {
+		entry.done = true
+		entry.status = status
+		entry.contentType = contentType
+		entry.body = body
+	}
+}
+
+// release removes the key if the request did not complete, so that a failed
+// request can be retried with the same key.
+func (r *idempotentRequest) release() {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	if !r.completed {
+		r.cache.remove(r.key)
+	}
+}
+
+// full returns true if no more entries can be stored for user. The caller must
+// hold the lock.
+func (i *idempotencyCache) full(user idempotencyUser) bool {
+	return len(i.entries) >= i.maxEntries || i.userCount[user] >= i.maxPerUser
+}
+
+// add stores entry for key. The caller must hold the lock.
+func (i *idempotencyCache) add(key idempotencyCacheKey, entry *idempotencyEntry) {
+	i.entries[key] = entry
+	i.userCount[key.idempotencyUser]++
+}
+
+// remove deletes the entry for key. The caller must hold the lock.
+func (i *idempotencyCache) remove(key idempotencyCacheKey) {
+	if _, ok := i.entries[key]; !ok {
+		return
+	}
+	delete(i.entries, key)
+	if i.userCount[key.idempotencyUser] <= 1 {
+		delete(i.userCount, key.idempotencyUser)
+		return
+	}
+	i.userCount[key.idempotencyUser]--
+}
+
+// purgeExpired removes expired entries, so that the number of entries does not
+// grow without bound. The caller must hold the lock.
+func (i *idempotencyCache) purgeExpired(now time.Time) {
+	if now.Sub(i.lastPurge) < time.Minute {
+		return
+	}
+	for key, entry := range i.entries {
+		if now.After(entry.expiresAt) {
+			i.remove(key)
+		}
+	}
+	i.lastPurge = now
+}
+
+// hashRequest returns a hash of the query and body of the request, so that a
+// key that is reused for a different request can be detected. The body is
+// replaced so that it can be read again.
+func hashRequest(c *gin.Context) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.URL.RawQuery))
+	h.Write([]byte{0})
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// idempotencyInProgressError is returned when a request uses the same
+// Idempotency-Key as another request that has not completed yet.
+type idempotencyInProgressError struct{}
+
+func (idempotencyInProgressError) Error() string {
+	return "a request with the same " + idempotencyKeyHeader + " is in progress, retry the request"
+}
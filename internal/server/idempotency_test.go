@@ -0,0 +1,318 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/access"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+func TestIdempotencyCache_Begin(t *testing.T) {
+	routeID := routeIdentifier{method: http.MethodPost, path: "/api/grants"}
+	user := &models.Identity{Model: models.Model{ID: uid.ID(1234)}}
+
+	newContext := func(key, body string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/grants", strings.NewReader(body))
+		if key != "" {
+			c.Request.Header.Set(idempotencyKeyHeader, key)
+		}
+		c.Set(access.RequestContextKey, access.RequestContext{
+			Authenticated: access.Authenticated{User: user},
+		})
+		return c
+	}
+
+	t.Run("no key", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		req, stored, err := cache.begin(newContext("", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req == nil)
+		assert.Assert(t, stored == nil)
+	})
+
+	t.Run("nil cache", func(t *testing.T) {
+		var cache *idempotencyCache
+		req, stored, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req == nil)
+		assert.Assert(t, stored == nil)
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		key := strings.Repeat("a", maxIdempotencyKeyLength+1)
+		_, _, err := cache.begin(newContext(key, `{}`), routeID)
+		assert.Assert(t, errors.Is(err, internal.ErrBadRequest))
+	})
+
+	t.Run("replay completed request", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		c := newContext("abc", `{"name":"first"}`)
+		req, stored, err := cache.begin(c, routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, stored == nil)
+
+		// the body can still be read by the handler
+		var body map[string]string
+		assert.NilError(t, json.NewDecoder(c.Request.Body).Decode(&body))
+		assert.Equal(t, body["name"], "first")
+
+		req.complete(http.StatusCreated, jsonContentType, []byte(`{"id":"1"}`))
+		req.release()
+
+		req, stored, err = cache.begin(newContext("abc", `{"name":"first"}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req == nil)
+		assert.Equal(t, stored.status, http.StatusCreated)
+		assert.Equal(t, stored.contentType, jsonContentType)
+		assert.Equal(t, string(stored.body), `{"id":"1"}`)
+	})
+
+	t.Run("key used for a different request", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		req, _, err := cache.begin(newContext("abc", `{"name":"first"}`), routeID)
+		assert.NilError(t, err)
+		req.complete(http.StatusCreated, jsonContentType, []byte(`{}`))
+
+		_, _, err = cache.begin(newContext("abc", `{"name":"second"}`), routeID)
+		assert.Assert(t, errors.Is(err, internal.ErrBadRequest))
+		assert.ErrorContains(t, err, "was already used for a different request")
+	})
+
+	t.Run("request in progress", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		_, _, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+
+		_, _, err = cache.begin(newContext("abc", `{}`), routeID)
+		assert.ErrorIs(t, err, idempotencyInProgressError{})
+	})
+
+	t.Run("failed request can be retried", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		req, _, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+		req.release()
+
+		req, stored, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req != nil)
+		assert.Assert(t, stored == nil)
+	})
+
+	t.Run("keys are scoped to the route", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		req, _, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+		req.complete(http.StatusCreated, jsonContentType, []byte(`{}`))
+
+		other := routeIdentifier{method: http.MethodPost, path: "/api/access-keys"}
+		req, stored, err := cache.begin(newContext("abc", `{}`), other)
+		assert.NilError(t, err)
+		assert.Assert(t, req != nil)
+		assert.Assert(t, stored == nil)
+	})
+
+	t.Run("expired entries are removed", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		req, _, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+		req.complete(http.StatusCreated, jsonContentType, []byte(`{}`))
+
+		now = now.Add(2 * time.Hour)
+		req, stored, err := cache.begin(newContext("abc", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req != nil)
+		assert.Assert(t, stored == nil)
+	})
+
+	t.Run("too many keys for the user", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		cache.maxPerUser = 2
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		for _, key := range []string{"one", "two"} {
+			req, _, err := cache.begin(newContext(key, `{}`), routeID)
+			assert.NilError(t, err)
+			req.complete(http.StatusCreated, jsonContentType, []byte(`{}`))
+		}
+
+		_, _, err := cache.begin(newContext("three", `{}`), routeID)
+		assert.ErrorIs(t, err, errTooManyIdempotencyKeys)
+
+		// a completed request can still be replayed
+		_, stored, err := cache.begin(newContext("one", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, stored != nil)
+
+		// another user has their own limit
+		c := newContext("three", `{}`)
+		c.Set(access.RequestContextKey, access.RequestContext{
+			Authenticated: access.Authenticated{User: &models.Identity{Model: models.Model{ID: uid.ID(5678)}}},
+		})
+		req, _, err := cache.begin(c, routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req != nil)
+
+		// expired keys are not counted
+		now = now.Add(2 * time.Hour)
+		req, _, err = cache.begin(newContext("three", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req != nil)
+	})
+
+	t.Run("too many keys", func(t *testing.T) {
+		cache := newIdempotencyCache(time.Hour)
+		cache.maxEntries = 1
+
+		req, _, err := cache.begin(newContext("one", `{}`), routeID)
+		assert.NilError(t, err)
+
+		_, _, err = cache.begin(newContext("two", `{}`), routeID)
+		assert.ErrorIs(t, err, errTooManyIdempotencyKeys)
+
+		// a failed request does not count towards the limit
+		req.release()
+		req, _, err = cache.begin(newContext("two", `{}`), routeID)
+		assert.NilError(t, err)
+		assert.Assert(t, req != nil)
+	})
+}
+
+func TestAPI_IdempotencyKey(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	createGrant := func(t *testing.T, key string, body api.CreateGrantRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/grants", jsonBody(t, body))
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		req.Header.Set(idempotencyKeyHeader, key)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	body := api.CreateGrantRequest{User: uid.ID(4567), Privilege: "view", Resource: "idempotent"}
+	first := createGrant(t, "key-1", body)
+	assert.Equal(t, first.Code, http.StatusCreated, first.Body.String())
+	assert.Equal(t, first.Header().Get(idempotencyReplayedHeader), "")
+
+	second := createGrant(t, "key-1", body)
+	assert.Equal(t, second.Code, http.StatusCreated, second.Body.String())
+	assert.Equal(t, second.Header().Get(idempotencyReplayedHeader), "true")
+	assert.Equal(t, second.Body.String(), first.Body.String())
+
+	body.Privilege = "edit"
+	resp := createGrant(t, "key-1", body)
+	assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+	// a different key is a new request, and the grant already exists
+	body.Privilege = "view"
+	resp = createGrant(t, "key-2", body)
+	assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+	assert.Equal(t, resp.Header().Get(idempotencyReplayedHeader), "")
+
+	t.Run("replay has the content type of the stored response", func(t *testing.T) {
+		createGrant := func() *httptest.ResponseRecorder {
+			body := api.CreateGrantRequest{User: uid.ID(4567), Privilege: "view", Resource: "enveloped"}
+			req := httptest.NewRequest(http.MethodPost, "/api/grants", jsonBody(t, body))
+			req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			req.Header.Set("Infra-Version", apiVersionLatest)
+			req.Header.Set("Accept", api.EnvelopeMediaType)
+			req.Header.Set(idempotencyKeyHeader, "key-4")
+
+			resp := httptest.NewRecorder()
+			routes.ServeHTTP(resp, req)
+			return resp
+		}
+
+		first := createGrant()
+		assert.Equal(t, first.Code, http.StatusCreated, first.Body.String())
+		assert.Equal(t, first.Header().Get("Content-Type"), envelopeContentType)
+
+		second := createGrant()
+		assert.Equal(t, second.Code, http.StatusCreated, second.Body.String())
+		assert.Equal(t, second.Header().Get(idempotencyReplayedHeader), "true")
+		assert.Equal(t, second.Header().Get("Content-Type"), envelopeContentType)
+		assert.Equal(t, second.Body.String(), first.Body.String())
+	})
+
+	t.Run("responses with secrets are not stored", func(t *testing.T) {
+		createUser := func() *httptest.ResponseRecorder {
+			body := api.CreateUserRequest{Name: "idempotent@example.com"}
+			req := httptest.NewRequest(http.MethodPost, "/api/users", jsonBody(t, body))
+			req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			req.Header.Set("Infra-Version", apiVersionLatest)
+			req.Header.Set(idempotencyKeyHeader, "key-3")
+
+			resp := httptest.NewRecorder()
+			routes.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := createUser()
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		resp = createUser()
+		assert.Equal(t, resp.Code, http.StatusConflict, resp.Body.String())
+		assert.Equal(t, resp.Header().Get(idempotencyReplayedHeader), "")
+	})
+
+	t.Run("access key secret is not sent again", func(t *testing.T) {
+		user := &models.Identity{Name: "keyholder@example.com"}
+		assert.NilError(t, data.CreateIdentity(srv.DB(), user))
+
+		createAccessKey := func() *httptest.ResponseRecorder {
+			body := api.CreateAccessKeyRequest{UserID: user.ID, Name: "idempotent-key"}
+			req := httptest.NewRequest(http.MethodPost, "/api/access-keys", jsonBody(t, body))
+			req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+			req.Header.Set("Infra-Version", apiVersionLatest)
+			req.Header.Set(idempotencyKeyHeader, "key-5")
+
+			resp := httptest.NewRecorder()
+			routes.ServeHTTP(resp, req)
+			return resp
+		}
+
+		first := createAccessKey()
+		assert.Equal(t, first.Code, http.StatusCreated, first.Body.String())
+		created := &api.CreateAccessKeyResponse{}
+		assert.NilError(t, json.Unmarshal(first.Body.Bytes(), created))
+		assert.Assert(t, created.AccessKey != "")
+
+		second := createAccessKey()
+		assert.Equal(t, second.Code, http.StatusConflict, second.Body.String())
+		assert.Equal(t, second.Header().Get(idempotencyReplayedHeader), "true")
+		assert.Assert(t, !strings.Contains(second.Body.String(), created.AccessKey), second.Body.String())
+
+		respBody := &api.Error{}
+		assert.NilError(t, json.Unmarshal(second.Body.Bytes(), respBody))
+		assert.Equal(t, respBody.ErrorCode, api.ErrorCodeConflict)
+		assert.Assert(t, strings.Contains(respBody.Message, created.ID.String()), respBody.Message)
+
+		keys, err := data.ListAccessKeys(srv.DB(), data.ListAccessKeyOptions{ByIssuedForID: user.ID})
+		assert.NilError(t, err)
+		assert.Equal(t, len(keys), 1)
+	})
+}
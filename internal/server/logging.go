@@ -47,7 +47,7 @@ func loggingMiddleware(enableSampling bool) gin.HandlerFunc {
 
 		method := c.Request.Method
 		status := c.Writer.Status()
-		logger := logging.L.Logger
+		logger := *logging.Ctx(c.Request.Context())
 
 		// sample logs for successful GET request if the log level is INFO or above
 		if enableSampling && status < 400 && method == http.MethodGet && zerolog.GlobalLevel() >= zerolog.InfoLevel {
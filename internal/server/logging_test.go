@@ -94,6 +94,32 @@ func TestLoggingMiddleware(t *testing.T) {
 		assert.DeepEqual(t, actual, expected)
 	})
 
+	t.Run("with requestID", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		logging.PatchLogger(t, b)
+
+		router := gin.New()
+		router.Use(RequestIDMiddleware(), loggingMiddleware(true))
+		router.POST("/good/:id", func(c *gin.Context) {})
+		resp := httptest.NewRecorder()
+
+		req := httptest.NewRequest("POST", "/good/1", nil)
+		req.Header.Set("X-Request-ID", "the-request-id")
+		router.ServeHTTP(resp, req)
+
+		actual := decodeLogs(t, b)
+		expected := []logEntry{
+			{
+				Method:     "POST",
+				Path:       "/good/1",
+				StatusCode: 200,
+				Level:      "info",
+				RequestID:  "the-request-id",
+			},
+		}
+		assert.DeepEqual(t, actual, expected)
+	})
+
 	t.Run("with userID and orgID", func(t *testing.T) {
 		b := &bytes.Buffer{}
 		router := setup(t, b)
@@ -138,4 +164,5 @@ type logEntry struct {
 	Level      string `json:"level"`
 	UserID     uid.ID `json:"userID"`
 	OrgID      uid.ID `json:"orgID"`
+	RequestID  string `json:"requestID"`
 }
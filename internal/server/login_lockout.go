@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/infrahq/infra/internal/logging"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/uid"
+)
+
+// LoginLockoutOptions configure how login with the password of a user is
+// locked after too many failed attempts. Attempts are counted for each user,
+// so that a lock on one user does not prevent any other user from logging in.
+type LoginLockoutOptions struct {
+	// MaxAttempts is the number of failed attempts within Window that lock
+	// login. Zero disables the lockout.
+	MaxAttempts int
+	// Window is the amount of time in which failed attempts are counted.
+	Window time.Duration
+	// Duration is the amount of time login is locked.
+	Duration time.Duration
+}
+
+type loginLockedError struct {
+	retryAfter time.Duration
+}
+
+func (e loginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %d seconds", e.retryAfterSeconds())
+}
+
+func (e loginLockedError) retryAfterSeconds() int {
+	return int(math.Ceil(e.retryAfter.Seconds()))
+}
+
+// recordFailedLogin counts a failed login attempt with the password of the
+// identity, and locks login when there are too many. The request transaction
+// is rolled back when login fails, so the attempt is written in a separate
+// transaction.
+func (a *API) recordFailedLogin(c *gin.Context, identityID uid.ID) error {
+	opts := a.server.options.LoginLockout
+	if opts.MaxAttempts <= 0 {
+		return nil
+	}
+
+	rCtx := getRequestContext(c)
+	tx, err := a.server.db.Begin(c.Request.Context(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to rollback database transaction")
+		}
+	}()
+	db := tx.WithOrgID(rCtx.DBTxn.OrganizationID())
+
+	attempts, err := data.RecordFailedLogin(db, identityID, opts.Window)
+	if err != nil {
+		return err
+	}
+
+	if attempts >= opts.MaxAttempts {
+		until := time.Now().Add(opts.Duration)
+		if err := data.LockLogin(db, identityID, until); err != nil {
+			return err
+		}
+		logging.Ctx(c.Request.Context()).Info().
+			Str("identityID", identityID.String()).
+			Int("attempts", attempts).
+			Time("lockedUntil", until).
+			Msg("login locked after too many failed attempts")
+	}
+
+	return tx.Commit()
+}
+
+// recordFailedUnknownLogin counts a failed login attempt with a username that
+// does not have a password credential, and locks login with the username after
+// the same number of attempts as a credential, so that the response does not
+// reveal which usernames exist. It returns a loginLockedError if login with the
+// username is locked, or if the attempt can not be counted.
+func (a *API) recordFailedUnknownLogin(c *gin.Context, username string) error {
+	opts := a.server.options.LoginLockout
+	if opts.MaxAttempts <= 0 {
+		return nil
+	}
+
+	rCtx := getRequestContext(c)
+	tx, err := a.server.db.Begin(c.Request.Context(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to rollback database transaction")
+		}
+	}()
+	db := tx.WithOrgID(rCtx.DBTxn.OrganizationID())
+
+	attempts, lockedUntil, err := data.RecordFailedUnknownLogin(db, username, opts.Window)
+	switch {
+	case errors.Is(err, data.ErrTooManyUnknownLogins):
+		// fail closed, so that attempts with many usernames can not be used
+		// to avoid the lockout
+		logging.Ctx(c.Request.Context()).Warn().Msg("too many usernames with failed login attempts")
+		return loginLockedError{retryAfter: opts.Window}
+	case err != nil:
+		return err
+	}
+
+	if now := time.Now(); now.Before(lockedUntil) {
+		return loginLockedError{retryAfter: lockedUntil.Sub(now)}
+	}
+
+	if attempts >= opts.MaxAttempts {
+		until := time.Now().Add(opts.Duration)
+		if err := data.LockUnknownLogin(db, username, until); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
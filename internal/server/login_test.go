@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -183,7 +184,7 @@ func TestAPI_Login(t *testing.T) {
 				assert.NilError(t, err)
 
 				expected := []api.FieldError{
-					{Errors: []string{"one of (accessKey, passwordCredentials, oidc) is required"}},
+					{Errors: []string{"one of (accessKey, passwordCredentials, oidc, idToken) is required"}},
 				}
 				assert.DeepEqual(t, respBody.FieldErrors, expected)
 			},
@@ -272,6 +273,31 @@ func TestAPI_Login(t *testing.T) {
 				assert.DeepEqual(t, respBody.FieldErrors, expected)
 			},
 		},
+		{
+			name: "missing idToken fields",
+			setup: func(t *testing.T) api.LoginRequest {
+				return api.LoginRequest{IDToken: &api.LoginRequestIDToken{}}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{
+						FieldName: "idToken.idToken",
+						Errors:    []string{"is required"},
+					},
+					{
+						FieldName: "idToken.providerID",
+						Errors:    []string{"is required"},
+					},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -281,6 +307,75 @@ func TestAPI_Login(t *testing.T) {
 	}
 }
 
+func TestAPI_Login_CookieNamePrefix(t *testing.T) {
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.Cookie.NamePrefix = "infra_acme_"
+	})
+	routes := srv.GenerateRoutes()
+
+	user := &models.Identity{Name: "prefixed@example.com"}
+	err := data.CreateIdentity(srv.DB(), user)
+	assert.NilError(t, err)
+
+	_, err = data.CreateProviderUser(srv.DB(), data.InfraProvider(srv.DB()), user)
+	assert.NilError(t, err)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	assert.NilError(t, err)
+	err = data.CreateCredential(srv.DB(), &models.Credential{IdentityID: user.ID, PasswordHash: hash})
+	assert.NilError(t, err)
+
+	body := jsonBody(t, api.LoginRequest{
+		PasswordCredentials: &api.LoginRequestPasswordCredentials{
+			Name:     "prefixed@example.com",
+			Password: "hunter2",
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/login", body)
+	req.Header.Set("Infra-Version", apiVersionLatest)
+
+	resp := httptest.NewRecorder()
+	routes.ServeHTTP(resp, req)
+	assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+	cookies := resp.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	authCookie := cookies[0]
+	assert.Equal(t, authCookie.Name, "infra_acme_auth")
+
+	logout := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/logout", nil)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		req.AddCookie(cookie)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("cookie without the prefix is not used", func(t *testing.T) {
+		resp := logout(&http.Cookie{Name: "auth", Value: authCookie.Value})
+		assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+	})
+
+	t.Run("logout deletes the prefixed cookie", func(t *testing.T) {
+		resp := logout(authCookie)
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		expected := []*http.Cookie{
+			{
+				Name:     "infra_acme_auth",
+				Path:     "/",
+				Domain:   "example.com",
+				MaxAge:   -1,
+				Secure:   true,
+				HttpOnly: true,
+			},
+		}
+		assert.DeepEqual(t, resp.Result().Cookies(), expected, cmpSetCookies)
+	})
+}
+
 var cmpSetCookies = cmp.Options{
 	cmp.FilterPath(opt.PathField(http.Cookie{}, "MaxAge"), cmpApproximateInt),
 	cmp.FilterPath(opt.PathField(http.Cookie{}, "Raw"), cmp.Ignore()),
@@ -312,3 +407,162 @@ func cmpApiTimeWithThreshold(threshold time.Duration) cmp.Option {
 		return delta <= threshold && delta >= -threshold
 	})
 }
+
+func TestAPI_Login_Lockout(t *testing.T) {
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.LoginLockout = LoginLockoutOptions{
+			MaxAttempts: 3,
+			Window:      time.Minute,
+			Duration:    time.Hour,
+		}
+	})
+	routes := srv.GenerateRoutes()
+
+	createUser := func(t *testing.T, name string) *models.Identity {
+		t.Helper()
+		user := &models.Identity{Name: name}
+		assert.NilError(t, data.CreateIdentity(srv.DB(), user))
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+		assert.NilError(t, err)
+		credential := &models.Credential{IdentityID: user.ID, PasswordHash: hash}
+		assert.NilError(t, data.CreateCredential(srv.DB(), credential))
+		return user
+	}
+
+	login := func(t *testing.T, name, password string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := jsonBody(t, api.LoginRequest{
+			PasswordCredentials: &api.LoginRequestPasswordCredentials{Name: name, Password: password},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/login", body)
+		req.Header.Add("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("too many failed attempts lock login", func(t *testing.T) {
+		createUser(t, "locked@example.com")
+		createUser(t, "other@example.com")
+
+		for i := 0; i < 3; i++ {
+			resp := login(t, "locked@example.com", "wrong")
+			assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+		}
+
+		// the correct password is rejected while login is locked
+		resp := login(t, "locked@example.com", "hunter2")
+		assert.Equal(t, resp.Code, http.StatusTooManyRequests, resp.Body.String())
+		retryAfter, err := strconv.Atoi(resp.Header().Get("Retry-After"))
+		assert.NilError(t, err)
+		assert.Assert(t, retryAfter > 3500 && retryAfter <= 3600, retryAfter)
+
+		respBody := &api.Error{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+		assert.Equal(t, respBody.ErrorCode, api.ErrorCodeRateLimited)
+
+		// other users are not locked
+		resp = login(t, "other@example.com", "hunter2")
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+	})
+
+	t.Run("successful login resets the count", func(t *testing.T) {
+		user := createUser(t, "reset@example.com")
+
+		for i := 0; i < 2; i++ {
+			resp := login(t, "reset@example.com", "wrong")
+			assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+		}
+
+		resp := login(t, "reset@example.com", "hunter2")
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+		credential, err := data.GetCredential(srv.DB(), data.ByIdentityID(user.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, credential.FailedLoginAttempts, 0)
+
+		for i := 0; i < 2; i++ {
+			resp := login(t, "reset@example.com", "wrong")
+			assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+		}
+
+		resp = login(t, "reset@example.com", "hunter2")
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+	})
+
+	t.Run("unknown user is locked after the same number of attempts", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp := login(t, "unknown@example.com", "wrong")
+			assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+		}
+
+		resp := login(t, "unknown@example.com", "wrong")
+		assert.Equal(t, resp.Code, http.StatusTooManyRequests, resp.Body.String())
+		retryAfter, err := strconv.Atoi(resp.Header().Get("Retry-After"))
+		assert.NilError(t, err)
+		assert.Assert(t, retryAfter > 3500 && retryAfter <= 3600, retryAfter)
+
+		respBody := &api.Error{}
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), respBody))
+		assert.Equal(t, respBody.ErrorCode, api.ErrorCodeRateLimited)
+
+		// the lock is stored in the database, so every server uses it
+		routes = srv.GenerateRoutes()
+		resp = login(t, "unknown@example.com", "wrong")
+		assert.Equal(t, resp.Code, http.StatusTooManyRequests, resp.Body.String())
+	})
+
+	t.Run("admin password reset unlocks login", func(t *testing.T) {
+		user := createUser(t, "unlock@example.com")
+
+		for i := 0; i < 3; i++ {
+			resp := login(t, "unlock@example.com", "wrong")
+			assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+		}
+		resp := login(t, "unlock@example.com", "hunter2")
+		assert.Equal(t, resp.Code, http.StatusTooManyRequests, resp.Body.String())
+
+		body := jsonBody(t, api.UpdateUserRequest{Password: "1234567890987654321a!"})
+		req := httptest.NewRequest(http.MethodPut, "/api/users/"+user.ID.String(), body)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		resp = httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		resp = login(t, "unlock@example.com", "1234567890987654321a!")
+		assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+	})
+}
+
+func TestAPI_Logout(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	key, _ := createAccessKey(t, srv.DB(), "logout@example.com")
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		req.Header.Set("Authorization", "Bearer "+key)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := send(http.MethodPost, "/api/logout")
+	assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+	t.Run("access key is deleted", func(t *testing.T) {
+		resp := send(http.MethodGet, "/api/users/self")
+		assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+	})
+
+	t.Run("logout again is unauthorized", func(t *testing.T) {
+		resp := send(http.MethodPost, "/api/logout")
+		assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+	})
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"errors"
+	"runtime"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
@@ -13,6 +17,9 @@ import (
 func setupMetrics(db *data.DB) *prometheus.Registry {
 	registry := metrics.NewRegistry(productVersion())
 	registry.MustRegister(collectors.NewDBStatsCollector(db.SQLdb(), db.DriverName()))
+	if err := data.RegisterMetrics(db, registry); err != nil {
+		logging.L.Warn().Err(err).Msg("database query metrics")
+	}
 
 	registry.MustRegister(metrics.NewCollector(prometheus.Opts{
 		Namespace: "infra",
@@ -126,3 +133,83 @@ func setupMetrics(db *data.DB) *prometheus.Registry {
 
 	return registry
 }
+
+// newBuildInfoMetric returns a gauge with a constant value of 1, labeled with
+// the version of the server and the version of Go used to build it.
+func newBuildInfoMetric() prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "infra",
+		Name:      "build_info",
+		Help:      "A metric with a constant '1' value labeled by the version, commit, and Go version used to build infra",
+		ConstLabels: prometheus.Labels{
+			"version":   internal.FullVersion(),
+			"commit":    internal.Commit,
+			"goversion": runtime.Version(),
+		},
+	}, func() float64 { return 1 })
+}
+
+// authnMetrics counts the outcomes of authentication, so that operators can
+// alert on unusual numbers of failures, like those caused by credential stuffing.
+// The methods of authnMetrics do nothing when it is nil.
+type authnMetrics struct {
+	logins            *prometheus.CounterVec
+	accessKeyFailures *prometheus.CounterVec
+	refreshFailures   *prometheus.CounterVec
+}
+
+func newAuthnMetrics(registry prometheus.Registerer) *authnMetrics {
+	m := &authnMetrics{
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "infra",
+			Name:      "logins_total",
+			Help:      "The total number of logins, by provider and result",
+		}, []string{"provider", "result"}),
+		accessKeyFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "infra",
+			Name:      "access_key_validation_failures_total",
+			Help:      "The total number of requests with an access key that failed validation, by reason",
+		}, []string{"reason"}),
+		refreshFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "infra",
+			Name:      "token_refresh_failures_total",
+			Help:      "The total number of failures to refresh a session with an identity provider, by provider",
+		}, []string{"provider"}),
+	}
+	registry.MustRegister(m.logins, m.accessKeyFailures, m.refreshFailures)
+	return m
+}
+
+func (m *authnMetrics) login(provider string, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.logins.WithLabelValues(provider, result).Inc()
+}
+
+func (m *authnMetrics) accessKeyFailed(err error) {
+	if m == nil {
+		return
+	}
+	reason := "invalid"
+	switch {
+	case errors.Is(err, data.ErrAccessKeyDeadlineExceeded):
+		reason = "deadline_exceeded"
+	case errors.Is(err, data.ErrAccessKeyExpired):
+		reason = "expired"
+	case errors.Is(err, data.ErrAccessKeyInvalid):
+		reason = "unknown_key_or_bad_secret"
+	}
+	m.accessKeyFailures.WithLabelValues(reason).Inc()
+}
+
+func (m *authnMetrics) refreshFailed(provider string) {
+	if m == nil {
+		return
+	}
+	m.refreshFailures.WithLabelValues(provider).Inc()
+}
@@ -2,15 +2,23 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
+	"runtime"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/golden"
 
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
 )
@@ -95,3 +103,147 @@ func TestMetrics(t *testing.T) {
 		golden.Assert(t, string(actual), t.Name())
 	})
 }
+
+func TestAuthnMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := newAuthnMetrics(registry)
+
+	m.login("infra", nil)
+	m.login("infra", errors.New("bad password"))
+	m.login("okta", errors.New("bad code"))
+	m.accessKeyFailed(data.ErrAccessKeyExpired)
+	m.accessKeyFailed(data.ErrAccessKeyDeadlineExceeded)
+	m.accessKeyFailed(fmt.Errorf("wrapped: %w", data.ErrAccessKeyInvalid))
+	m.accessKeyFailed(errors.New("invalid access key format"))
+	m.refreshFailed("okta")
+
+	assert.Equal(t, testutil.ToFloat64(m.logins.WithLabelValues("infra", "success")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.logins.WithLabelValues("infra", "failure")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.logins.WithLabelValues("okta", "failure")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.accessKeyFailures.WithLabelValues("expired")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.accessKeyFailures.WithLabelValues("deadline_exceeded")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.accessKeyFailures.WithLabelValues("unknown_key_or_bad_secret")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.accessKeyFailures.WithLabelValues("invalid")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(m.refreshFailures.WithLabelValues("okta")), float64(1))
+
+	count, err := testutil.GatherAndCount(registry,
+		"infra_logins_total",
+		"infra_access_key_validation_failures_total",
+		"infra_token_refresh_failures_total")
+	assert.NilError(t, err)
+	assert.Equal(t, count, 8)
+
+	t.Run("nil metrics", func(t *testing.T) {
+		var m *authnMetrics
+		m.login("infra", nil)
+		m.accessKeyFailed(data.ErrAccessKeyExpired)
+		m.refreshFailed("okta")
+	})
+}
+
+func TestAuthnMetrics_Requests(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	user := &models.Identity{Name: "metrics@example.com"}
+	assert.NilError(t, data.CreateIdentity(srv.DB(), user))
+
+	t.Run("login failure", func(t *testing.T) {
+		body := jsonBody(t, api.LoginRequest{
+			PasswordCredentials: &api.LoginRequestPasswordCredentials{
+				Name:     "metrics@example.com",
+				Password: "wrong",
+			},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/login", body)
+		req.Header.Add("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+
+		failures := srv.authnMetrics.logins.WithLabelValues(models.InternalInfraProviderName, "failure")
+		assert.Equal(t, testutil.ToFloat64(failures), float64(1))
+	})
+
+	t.Run("access key with bad secret", func(t *testing.T) {
+		key := &models.AccessKey{
+			IssuedFor:  user.ID,
+			ProviderID: data.InfraProvider(srv.DB()).ID,
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+		_, err := data.CreateAccessKey(srv.DB(), key)
+		assert.NilError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/users/self", nil)
+		req.Header.Set("Authorization", "Bearer "+key.KeyID+".aaaaaaaaaaaaaaaaaaaaaaaa")
+		req.Header.Add("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusUnauthorized, resp.Body.String())
+
+		failures := srv.authnMetrics.accessKeyFailures.WithLabelValues("unknown_key_or_bad_secret")
+		assert.Equal(t, testutil.ToFloat64(failures), float64(1))
+	})
+}
+
+func TestBuildInfoMetric(t *testing.T) {
+	s := Server{metricsRegistry: prometheus.NewRegistry()}
+	s.GenerateRoutes()
+
+	families, err := s.metricsRegistry.Gather()
+	assert.NilError(t, err)
+
+	var labels map[string]string
+	for _, family := range families {
+		if family.GetName() != "infra_build_info" {
+			continue
+		}
+		assert.Equal(t, len(family.GetMetric()), 1)
+		metric := family.GetMetric()[0]
+		assert.Equal(t, metric.GetGauge().GetValue(), float64(1))
+
+		labels = map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+	}
+
+	expected := map[string]string{
+		"version":   internal.FullVersion(),
+		"commit":    internal.Commit,
+		"goversion": runtime.Version(),
+	}
+	assert.DeepEqual(t, labels, expected)
+}
+
+func TestMetrics_UnmatchedRoute(t *testing.T) {
+	s := Server{metricsRegistry: prometheus.NewRegistry()}
+	routes := s.GenerateRoutes()
+
+	for _, path := range []string{"/api/not-a-route/1", "/api/not-a-route/2"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusNotFound)
+	}
+
+	families, err := s.metricsRegistry.Gather()
+	assert.NilError(t, err)
+
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "path" {
+					counts[label.GetValue()] += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	assert.DeepEqual(t, counts, map[string]uint64{"unmatched": 2})
+}
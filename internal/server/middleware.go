@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/access"
@@ -19,23 +21,201 @@ import (
 	"github.com/infrahq/infra/internal/server/models"
 )
 
+const (
+	// defaultRequestTimeout is the timeout for requests to routes that do not
+	// set their own timeout.
+	defaultRequestTimeout = time.Minute
+	// bulkRequestTimeout is the timeout for routes that may create or update
+	// many records in a single request.
+	bulkRequestTimeout = 5 * time.Minute
+)
+
 // TimeoutMiddleware adds a timeout to the request context within the Gin context.
 // To correctly abort long-running requests, this depends on the users of the context to
 // stop working when the context cancels.
 // Note: The goroutine for the request is never halted; if the context is not
 // passed down to lower packages and long-running tasks, then the app will not
-// magically stop working on the request. No effort should be made to write
-// an early http response here; it's up to the users of the context to watch for
-// c.Request.Context().Err() or <-c.Request.Context().Done()
+// magically stop working on the request. Once the deadline has passed anything
+// the handler writes to the response is discarded, and when the handler returns
+// the response is a timeout error, unless the handler had already started
+// writing the response before the deadline.
 func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
 		defer cancel()
 		c.Request = c.Request.WithContext(ctx)
+
+		w := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.expired() {
+			// remove any headers that describe the discarded response
+			for _, name := range []string{"Content-Type", "Content-Length", "ETag", "Cache-Control"} {
+				c.Writer.Header().Del(name)
+			}
+			sendAPIError(c, ctx.Err())
+		}
+	}
+}
+
+// timeoutWriter discards the response written by a handler after the deadline
+// of ctx, so that TimeoutMiddleware can write the timeout error instead. The
+// response is only discarded if none of it was sent before the deadline.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && !w.ResponseWriter.Written() && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.expired() {
+		// gin panics on write errors, so report the discarded data as written
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+const (
+	headerRequestID       = "X-Request-ID"
+	requestIDContextKey   = "requestID"
+	maxRequestIDLength    = 128
+	requestIDLogFieldName = "requestID"
+)
+
+// RequestIDMiddleware assigns an ID to each request, so that an error reported
+// by a client can be found in the server logs. The ID is read from the
+// X-Request-ID header of the request, or generated if the header is missing or
+// invalid. The ID is sent back in the X-Request-ID header of the response, and
+// is included in the logs for the request by the logger stored in the request
+// context. Handlers should use logging.Ctx to retrieve that logger.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(headerRequestID)
+		if !isValidRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDContextKey, id)
+		c.Header(headerRequestID, id)
+
+		logger := logging.L.With().Str(requestIDLogFieldName, id).Logger()
+		c.Request = c.Request.WithContext(logging.WithContext(c.Request.Context(), logger))
+		c.Next()
+	}
+}
+
+// isValidRequestID returns true if id is short, and only contains printable
+// ASCII characters, so that it is safe to include in logs and headers.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// getRequestID returns the ID assigned to the request by RequestIDMiddleware.
+func getRequestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+// MaxBodyBytesMiddleware limits the size of request bodies to limit bytes.
+// Reading more than limit bytes from the body returns an error, which results
+// in a 413 response. Routes may override the limit by setting maxBodyBytes.
+func MaxBodyBytesMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit > 0 {
+			limitRequestBody(c, limit)
+		}
 		c.Next()
 	}
 }
 
+// limitedRequestBody is a request body that returns requestBodyTooLargeError
+// when more than limit bytes are read. It keeps a reference to the original
+// body so that the limit can be replaced by a route.
+type limitedRequestBody struct {
+	io.ReadCloser
+	original io.ReadCloser
+	limit    int64
+	read     int64
+}
+
+func (b *limitedRequestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) && b.read >= b.limit {
+		return n, requestBodyTooLargeError{limit: b.limit}
+	}
+	return n, err
+}
+
+// limitRequestBody replaces the body of the request with one that can not be
+// larger than limit bytes. Any previous limit is replaced.
+func limitRequestBody(c *gin.Context, limit int64) {
+	body := c.Request.Body
+	if body == nil {
+		return
+	}
+	if b, ok := body.(*limitedRequestBody); ok {
+		body = b.original
+	}
+	c.Request.Body = &limitedRequestBody{
+		ReadCloser: http.MaxBytesReader(c.Writer, body, limit),
+		original:   body,
+		limit:      limit,
+	}
+}
+
+type requestBodyTooLargeError struct {
+	limit int64
+}
+
+func (e requestBodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than the limit of %d bytes", e.limit)
+}
+
 func handleInfraDestinationHeader(c *gin.Context) error {
 	uniqueID := c.Request.Header.Get("Infra-Destination")
 	if uniqueID == "" {
@@ -81,8 +261,12 @@ func authenticateRequest(c *gin.Context, tx *data.Transaction, srv *Server) erro
 		return err
 	}
 
+	if err := checkAccessKeyScopes(authned.AccessKey, c.Request); err != nil {
+		return err
+	}
+
 	if _, err := validateOrgMatchesRequest(c.Request, tx, authned.Organization); err != nil {
-		logging.L.Warn().Err(err).Msg("org validation failed")
+		logging.Ctx(c.Request.Context()).Warn().Err(err).Msg("org validation failed")
 		return internal.ErrBadRequest
 	}
 
@@ -137,7 +321,7 @@ func validateRequestOrganization(c *gin.Context, tx *data.Transaction, srv *Serv
 
 	org, err := validateOrgMatchesRequest(c.Request, tx, authned.Organization)
 	if err != nil {
-		logging.L.Warn().Err(err).Msg("org validation failed")
+		logging.Ctx(c.Request.Context()).Warn().Err(err).Msg("org validation failed")
 		return internal.ErrBadRequest
 	}
 	authned.Organization = org
@@ -175,6 +359,7 @@ func requireAccessKey(c *gin.Context, db *data.Transaction, srv *Server) (access
 
 	accessKey, err := data.ValidateRequestAccessKey(db, bearer)
 	if err != nil {
+		srv.authnMetrics.accessKeyFailed(err)
 		if errors.Is(err, data.ErrAccessKeyExpired) {
 			return u, err
 		}
@@ -237,7 +422,7 @@ func getRequestContext(c *gin.Context) access.RequestContext {
 func getOrgFromRequest(req *http.Request, tx data.GormTxn) (*models.Organization, error) {
 	host := req.Host
 
-	logging.Debugf("Host: %s", host)
+	logging.Ctx(req.Context()).Debug().Msgf("Host: %s", host)
 	if host == "" {
 		return nil, nil
 	}
@@ -246,7 +431,7 @@ hostLookup:
 	org, err := data.GetOrganization(tx, data.ByDomain(host))
 	if err != nil {
 		if errors.Is(err, internal.ErrNotFound) {
-			logging.Debugf("Host not found: %s", host)
+			logging.Ctx(req.Context()).Debug().Msgf("Host not found: %s", host)
 			// first, remove port and try again
 			h, p, err := net.SplitHostPort(host)
 			if len(p) > 0 && err == nil {
@@ -277,10 +462,10 @@ func reqBearerToken(c *gin.Context, opts Options) (string, error) {
 		*/
 		cookie := exchangeSignupCookieForSession(c, opts)
 		if cookie == "" {
-			logging.L.Trace().Msg("sign-up cookie not found, falling back to auth cookie")
+			logging.Ctx(c.Request.Context()).Trace().Msg("sign-up cookie not found, falling back to auth cookie")
 
 			var err error
-			cookie, err = getCookie(c.Request, cookieAuthorizationName)
+			cookie, err = getCookie(c.Request, opts.Cookie.name(cookieAuthorizationName))
 			if err != nil {
 				return "", fmt.Errorf("%w: valid token not found in request", internal.ErrUnauthorized)
 			}
@@ -296,3 +481,51 @@ func reqBearerToken(c *gin.Context, opts Options) (string, error) {
 
 	return bearer, nil
 }
+
+// requiredScope returns the privilege scope an access key needs to make a
+// request with method to path. Requests that only read require the read
+// operation, all other requests require the write operation. The resource is
+// the first element of the path after /api/.
+func requiredScope(method, path string) (operation, resource string) {
+	resource = strings.TrimPrefix(path, "/api/")
+	resource, _, _ = strings.Cut(resource, "/")
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return models.ScopeOperationRead, resource
+	default:
+		return models.ScopeOperationWrite, resource
+	}
+}
+
+// checkAccessKeyScopes returns an error if the privilege scopes of the access key
+// do not permit the request. Keys without privilege scopes are not limited.
+func checkAccessKeyScopes(key *models.AccessKey, req *http.Request) error {
+	if key == nil {
+		return nil
+	}
+
+	operation, resource := requiredScope(req.Method, req.URL.Path)
+
+	var hasPrivilegeScopes bool
+	for _, scope := range key.Scopes {
+		op, res, ok := strings.Cut(scope, ":")
+		if !ok || (op != models.ScopeOperationRead && op != models.ScopeOperationWrite) {
+			continue
+		}
+		hasPrivilegeScopes = true
+
+		if res != resource && res != "*" {
+			continue
+		}
+		// write scopes also permit reading the same resource
+		if op == operation || op == models.ScopeOperationWrite {
+			return nil
+		}
+	}
+
+	if !hasPrivilegeScopes {
+		return nil
+	}
+	return fmt.Errorf("%w: access key requires scope %s:%s", internal.ErrForbidden, operation, resource)
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -19,6 +21,7 @@ import (
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/access"
 	"github.com/infrahq/infra/internal/generate"
+	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/internal/testing/database"
@@ -69,7 +72,56 @@ func TestRequestTimeoutError(t *testing.T) {
 
 		c.Status(200)
 	})
-	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, resp.Code, http.StatusGatewayTimeout)
+}
+
+func TestRequestTimeout_SlowHandlerResponseDiscarded(t *testing.T) {
+	router := gin.New()
+	router.Use(TimeoutMiddleware(20 * time.Millisecond))
+	router.GET("/", func(c *gin.Context) {
+		time.Sleep(50 * time.Millisecond)
+
+		c.Header("ETag", `W/"abc"`)
+		c.JSON(http.StatusOK, api.Version{Version: "0.1.0"})
+		assert.Check(t, !c.Writer.Written())
+	})
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, resp.Code, http.StatusGatewayTimeout)
+	assert.Equal(t, resp.Header().Get("ETag"), "")
+	assert.Equal(t, resp.Header().Get("Content-Type"), "application/json; charset=utf-8")
+
+	decoder := json.NewDecoder(resp.Body)
+	var apiError api.Error
+	assert.NilError(t, decoder.Decode(&apiError))
+	expected := api.Error{
+		Code:      http.StatusGatewayTimeout,
+		ErrorCode: api.ErrorCodeTimeout,
+		Message:   "request timed out",
+	}
+	assert.DeepEqual(t, apiError, expected)
+	assert.Assert(t, !decoder.More(), "response body has more than one value")
+}
+
+func TestRequestTimeout_ResponseStartedBeforeDeadline(t *testing.T) {
+	router := gin.New()
+	router.Use(TimeoutMiddleware(20 * time.Millisecond))
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		time.Sleep(50 * time.Millisecond)
+		_, _ = c.Writer.WriteString("done")
+	})
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, resp.Code, http.StatusOK)
+	assert.Equal(t, resp.Body.String(), "done")
 }
 
 func TestRequestTimeoutSuccess(t *testing.T) {
@@ -83,6 +135,124 @@ func TestRequestTimeoutSuccess(t *testing.T) {
 	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
 }
 
+func TestRequestIDMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, getRequestID(c))
+	})
+	router.GET("/log", func(c *gin.Context) {
+		logging.Ctx(c.Request.Context()).Info().Msg("from the handler")
+		c.Status(http.StatusOK)
+	})
+
+	t.Run("request ID from header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, resp.Header().Get("X-Request-ID"), "abc-123")
+		assert.Equal(t, resp.Body.String(), "abc-123")
+	})
+
+	t.Run("generated request ID", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		id := resp.Header().Get("X-Request-ID")
+		assert.Equal(t, len(id), 36, id)
+		assert.Equal(t, resp.Body.String(), id)
+	})
+
+	t.Run("invalid request ID is replaced", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", strings.Repeat("a", maxRequestIDLength+1))
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		id := resp.Header().Get("X-Request-ID")
+		assert.Equal(t, len(id), 36, id)
+	})
+
+	t.Run("request ID in handler logs", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		logging.PatchLogger(t, logs)
+
+		req := httptest.NewRequest(http.MethodGet, "/log", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry map[string]interface{}
+		assert.NilError(t, json.Unmarshal(logs.Bytes(), &entry))
+		assert.Equal(t, entry["message"], "from the handler")
+		assert.Equal(t, entry["requestID"], "abc-123")
+	})
+}
+
+func TestMaxBodyBytesMiddleware(t *testing.T) {
+	type testCase struct {
+		name         string
+		body         string
+		routeLimit   int64
+		expectedCode int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		router := gin.New()
+		router.Use(MaxBodyBytesMiddleware(20))
+		router.POST("/", func(c *gin.Context) {
+			if tc.routeLimit > 0 {
+				limitRequestBody(c, tc.routeLimit)
+			}
+
+			req := &api.CreateUserRequest{}
+			if err := readRequest(c, req); err != nil {
+				sendAPIError(c, err)
+				return
+			}
+			c.Status(http.StatusOK)
+		})
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest("POST", "/", strings.NewReader(tc.body)))
+		assert.Equal(t, resp.Code, tc.expectedCode, resp.Body.String())
+	}
+
+	testCases := []testCase{
+		{
+			name:         "under the limit",
+			body:         `{"name":"a@b.com"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "over the limit",
+			body:         `{"name":"abcdefghijk@example.com"}`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "route raises the limit",
+			body:         `{"name":"abcdefghijk@example.com"}`,
+			routeLimit:   100,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "route lowers the limit",
+			body:         `{"name":"a@b.com"}`,
+			routeLimit:   10,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
 func TestDBTimeout(t *testing.T) {
 	var ctx context.Context
 	var cancel context.CancelFunc
@@ -99,7 +269,7 @@ func TestDBTimeout(t *testing.T) {
 
 			c.Request = c.Request.WithContext(ctx)
 
-			tx, err := srv.db.Begin(c.Request.Context())
+			tx, err := srv.db.Begin(c.Request.Context(), nil)
 			if err != nil {
 				sendAPIError(c, err)
 				return
@@ -232,6 +402,29 @@ func TestRequireAccessKey(t *testing.T) {
 			},
 			expected: func(t *testing.T, _ access.Authenticated, err error) {
 				assert.ErrorIs(t, err, data.ErrAccessKeyExpired)
+				assert.Assert(t, !errors.Is(err, data.ErrAccessKeyDeadlineExceeded))
+			},
+		},
+		"AccessKeyExtensionDeadlineExceeded": {
+			setup: func(t *testing.T, db data.GormTxn) *http.Request {
+				user := &models.Identity{Name: "deadline@infrahq.com"}
+				assert.NilError(t, data.CreateIdentity(db, user))
+
+				token := &models.AccessKey{
+					IssuedFor:         user.ID,
+					ProviderID:        data.InfraProvider(db).ID,
+					ExpiresAt:         time.Now().Add(time.Hour).UTC(),
+					ExtensionDeadline: time.Now().Add(-time.Minute).UTC(),
+				}
+				authentication, err := data.CreateAccessKey(db, token)
+				assert.NilError(t, err)
+
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header.Add("Authorization", "Bearer "+authentication)
+				return r
+			},
+			expected: func(t *testing.T, _ access.Authenticated, err error) {
+				assert.ErrorIs(t, err, data.ErrAccessKeyDeadlineExceeded)
 			},
 		},
 		"AccessKeyInvalidKey": {
@@ -244,7 +437,7 @@ func TestRequireAccessKey(t *testing.T) {
 				return r
 			},
 			expected: func(t *testing.T, _ access.Authenticated, err error) {
-				assert.ErrorContains(t, err, "record not found")
+				assert.ErrorIs(t, err, data.ErrAccessKeyInvalid)
 			},
 		},
 		"AccessKeyNoMatch": {
@@ -255,7 +448,7 @@ func TestRequireAccessKey(t *testing.T) {
 				return r
 			},
 			expected: func(t *testing.T, _ access.Authenticated, err error) {
-				assert.ErrorContains(t, err, "record not found")
+				assert.ErrorIs(t, err, data.ErrAccessKeyInvalid)
 			},
 		},
 		"AccessKeyInvalidSecret": {
@@ -267,7 +460,7 @@ func TestRequireAccessKey(t *testing.T) {
 				return r
 			},
 			expected: func(t *testing.T, _ access.Authenticated, err error) {
-				assert.ErrorContains(t, err, "access key invalid secret")
+				assert.ErrorIs(t, err, data.ErrAccessKeyInvalid)
 			},
 		},
 		"UnknownAuthenticationMethod": {
@@ -355,6 +548,33 @@ func TestRequireAccessKey(t *testing.T) {
 	}
 }
 
+func TestRequireAccessKey_UnknownKeyIDAndBadSecretAreIndistinguishable(t *testing.T) {
+	db := setupDB(t)
+	srv := &Server{options: Options{BaseDomain: "example.com"}}
+
+	token := issueToken(t, db, "existing@infrahq.com", time.Minute*1)
+	keyID, secret, _ := strings.Cut(token, ".")
+	badSecret := generate.MathRandom(models.AccessKeySecretLength, generate.CharsetAlphaNumeric)
+
+	authenticate := func(t *testing.T, bearer string) error {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Add("Authorization", "Bearer "+bearer)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = r
+
+		_, err := requireAccessKey(c, txnForTestCase(t, db), srv)
+		return err
+	}
+
+	unknownKeyErr := authenticate(t, uid.New().String()+"."+secret)
+	badSecretErr := authenticate(t, keyID+"."+badSecret)
+
+	assert.ErrorIs(t, unknownKeyErr, internal.ErrUnauthorized)
+	assert.ErrorIs(t, unknownKeyErr, data.ErrAccessKeyInvalid)
+	assert.Equal(t, unknownKeyErr.Error(), badSecretErr.Error())
+}
+
 func TestHandleInfraDestinationHeader(t *testing.T) {
 	srv := setupServer(t, withAdminUser)
 	routes := srv.GenerateRoutes()
@@ -438,7 +658,7 @@ func TestAuthenticateRequest(t *testing.T) {
 	}
 	createOrgs(t, srv.db, otherOrg, org)
 
-	tx, err := srv.db.Begin(context.Background())
+	tx, err := srv.db.Begin(context.Background(), nil)
 	assert.NilError(t, err)
 	tx = tx.WithOrgID(org.ID)
 
@@ -458,6 +678,23 @@ func TestAuthenticateRequest(t *testing.T) {
 	key, err := data.CreateAccessKey(tx, token)
 	assert.NilError(t, err)
 
+	expiredKey, err := data.CreateAccessKey(tx, &models.AccessKey{
+		IssuedFor:          user.ID,
+		ProviderID:         data.InfraProvider(tx).ID,
+		ExpiresAt:          time.Now().Add(-time.Minute),
+		OrganizationMember: models.OrganizationMember{OrganizationID: org.ID},
+	})
+	assert.NilError(t, err)
+
+	deadlineExceededKey, err := data.CreateAccessKey(tx, &models.AccessKey{
+		IssuedFor:          user.ID,
+		ProviderID:         data.InfraProvider(tx).ID,
+		ExpiresAt:          time.Now().Add(time.Hour),
+		ExtensionDeadline:  time.Now().Add(-time.Minute),
+		OrganizationMember: models.OrganizationMember{OrganizationID: org.ID},
+	})
+	assert.NilError(t, err)
+
 	assert.NilError(t, tx.Commit())
 
 	httpSrv := httptest.NewServer(routes)
@@ -542,6 +779,34 @@ func TestAuthenticateRequest(t *testing.T) {
 				assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
 			},
 		},
+		{
+			name: "Access key expired",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+expiredKey)
+			},
+			expected: func(t *testing.T, resp *http.Response) {
+				assert.Equal(t, resp.StatusCode, http.StatusUnauthorized)
+
+				respErr := &api.Error{}
+				assert.NilError(t, json.NewDecoder(resp.Body).Decode(respErr))
+				assert.Equal(t, respErr.ErrorCode, api.ErrorCodeAccessKeyExpired)
+				assert.Equal(t, respErr.Message, "unauthorized: access key expired")
+			},
+		},
+		{
+			name: "Access key extension deadline exceeded",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+deadlineExceededKey)
+			},
+			expected: func(t *testing.T, resp *http.Response) {
+				assert.Equal(t, resp.StatusCode, http.StatusUnauthorized)
+
+				respErr := &api.Error{}
+				assert.NilError(t, json.NewDecoder(resp.Body).Decode(respErr))
+				assert.Equal(t, respErr.ErrorCode, api.ErrorCodeAccessKeyDeadlineExceeded)
+				assert.Equal(t, respErr.Message, "unauthorized: access key expired: extension deadline exceeded")
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -567,7 +832,7 @@ func TestValidateRequestOrganization(t *testing.T) {
 	}
 	createOrgs(t, srv.db, otherOrg, org)
 
-	tx, err := srv.db.Begin(context.Background())
+	tx, err := srv.db.Begin(context.Background(), nil)
 	assert.NilError(t, err)
 	tx = tx.WithOrgID(org.ID)
 
@@ -727,3 +992,120 @@ func TestValidateRequestOrganization(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthenticateRequest_AccessKeyScopes(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	admin, err := data.GetIdentity(srv.DB(), data.ByName("admin@example.com"))
+	assert.NilError(t, err)
+
+	readOnlyKey, err := data.CreateAccessKey(srv.DB(), &models.AccessKey{
+		IssuedFor:  admin.ID,
+		ProviderID: data.InfraProvider(srv.DB()).ID,
+		ExpiresAt:  time.Now().Add(time.Hour).UTC(),
+		Scopes:     models.CommaSeparatedStrings{"read:users"},
+	})
+	assert.NilError(t, err)
+
+	run := func(method, path string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, body)
+		req.Header.Set("Authorization", "Bearer "+readOnlyKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("read with read scope", func(t *testing.T) {
+		resp := run(http.MethodGet, "/api/users", nil)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+	})
+
+	t.Run("write with read scope", func(t *testing.T) {
+		body := jsonBody(t, api.CreateUserRequest{Name: "scoped@example.com"})
+		resp := run(http.MethodPost, "/api/users", body)
+		assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+	})
+
+	t.Run("read another resource", func(t *testing.T) {
+		resp := run(http.MethodGet, "/api/grants", nil)
+		assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+	})
+}
+
+func TestCheckAccessKeyScopes(t *testing.T) {
+	type testCase struct {
+		name        string
+		scopes      []string
+		method      string
+		path        string
+		expectedErr string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		key := &models.AccessKey{Scopes: tc.scopes}
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+
+		err := checkAccessKeyScopes(key, req)
+		if tc.expectedErr == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorIs(t, err, internal.ErrForbidden)
+		assert.ErrorContains(t, err, tc.expectedErr)
+	}
+
+	testCases := []testCase{
+		{
+			name:   "no scopes",
+			method: http.MethodPost,
+			path:   "/api/users",
+		},
+		{
+			name:   "only non-privilege scopes",
+			scopes: []string{models.ScopeAllowCreateAccessKey},
+			method: http.MethodDelete,
+			path:   "/api/users/1234",
+		},
+		{
+			name:   "read scope allows get",
+			scopes: []string{"read:users"},
+			method: http.MethodGet,
+			path:   "/api/users/1234",
+		},
+		{
+			name:        "read scope rejects post",
+			scopes:      []string{"read:users"},
+			method:      http.MethodPost,
+			path:        "/api/users",
+			expectedErr: "requires scope write:users",
+		},
+		{
+			name:   "write scope allows get",
+			scopes: []string{"write:users"},
+			method: http.MethodGet,
+			path:   "/api/users",
+		},
+		{
+			name:   "wildcard resource",
+			scopes: []string{"read:*"},
+			method: http.MethodGet,
+			path:   "/api/grants",
+		},
+		{
+			name:        "wildcard read rejects put",
+			scopes:      []string{"read:*"},
+			method:      http.MethodPut,
+			path:        "/api/grants",
+			expectedErr: "requires scope write:grants",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -30,9 +30,16 @@ func (a *API) deprecatedRoutes(noAuthnNoOrg *routeGroup) {
 	type SignupEnabledResponse struct {
 		Enabled bool `json:"enabled"`
 	}
-	addDeprecated(a, noAuthnNoOrg, http.MethodGet, "/api/signup",
+	addDeprecated(a, noAuthnNoOrg, http.MethodGet, "/api/signup", "0.14.4",
 		func(c *gin.Context, _ *api.EmptyRequest) (*SignupEnabledResponse, error) {
 			return &SignupEnabledResponse{Enabled: false}, nil
 		},
 	)
+
+	// v0.9.0 replaced the users and machines with identities, which are now users
+	addRemoved(a, noAuthnNoOrg, http.MethodGet, "/v1/machines", "0.9.0", "/api/users")
+	addRemoved(a, noAuthnNoOrg, http.MethodPost, "/v1/machines", "0.9.0", "/api/users")
+	addRemoved(a, noAuthnNoOrg, http.MethodGet, "/v1/users", "0.9.0", "/api/users")
+	addRemoved(a, noAuthnNoOrg, http.MethodPost, "/v1/users", "0.9.0", "/api/users")
+	addRemoved(a, noAuthnNoOrg, http.MethodGet, "/v1/introspect", "0.12.0", "/api/users/self")
 }
@@ -8,13 +8,26 @@ import (
 )
 
 var (
-	AccessKeyKeyLength    = 10 // the length of the ID used to look-up the access key
-	AccessKeySecretLength = 24 // the length of the secret used to validate an access key
+	AccessKeyKeyLength    = 10                        // the length of the ID used to look-up the access key
+	AccessKeySecretLength = api.AccessKeySecretLength // the length of the secret used to validate an access key
 )
 
 const (
 	ScopePasswordReset        = "password-reset"
 	ScopeAllowCreateAccessKey = "create-key"
+	// ScopeIDTokenLogin is added to the access key of a session created from
+	// an ID token. The session has no provider tokens, so the user info is
+	// not refreshed from the provider.
+	ScopeIDTokenLogin = "id-token"
+)
+
+// Privilege scopes limit the API requests an access key can be used for. They
+// have the form <operation>:<resource>, for example read:users. The resource
+// may be * to match all resources. A key with no privilege scopes may be used
+// for any request.
+const (
+	ScopeOperationRead  = "read"
+	ScopeOperationWrite = "write"
 )
 
 // AccessKey is a session token presented to the Infra server as proof of authentication
@@ -26,16 +39,25 @@ type AccessKey struct {
 	IssuedFor     uid.ID
 	IssuedForName string
 	ProviderID    uid.ID
+	// CreatedBy is the ID of the identity that created this access key. It is
+	// different from IssuedFor when an admin creates a key for another user.
+	CreatedBy uid.ID
 
 	ExpiresAt         time.Time
 	Extension         time.Duration // how long to increase the lifetime extension deadline by
 	ExtensionDeadline time.Time
+	// LastUsedAt is the last time the key was used to authenticate a request.
+	// The value is only updated periodically, so it may be a few minutes
+	// behind the actual time of last use.
+	LastUsedAt time.Time
 
 	KeyID          string `gorm:"<-;uniqueIndex:idx_access_keys_key_id,where:deleted_at is NULL"`
 	Secret         string `gorm:"-" db:"-"`
 	SecretChecksum []byte
 
 	Scopes CommaSeparatedStrings // if set, scopes limit what the key can be used for
+	// OneTimeUse keys are deleted after they are used to authenticate once.
+	OneTimeUse bool
 }
 
 func (ak *AccessKey) ToAPI() *api.AccessKey {
@@ -46,7 +68,9 @@ func (ak *AccessKey) ToAPI() *api.AccessKey {
 		IssuedFor:         ak.IssuedFor,
 		IssuedForName:     ak.IssuedForName,
 		ProviderID:        ak.ProviderID,
+		CreatedBy:         ak.CreatedBy,
 		Expires:           api.Time(ak.ExpiresAt),
 		ExtensionDeadline: api.Time(ak.ExtensionDeadline),
+		LastUsed:          api.Time(ak.LastUsedAt),
 	}
 }
@@ -0,0 +1,26 @@
+package models
+
+import "github.com/infrahq/infra/uid"
+
+const (
+	AuditActionCreateAccessKey = "access-key.create"
+	AuditActionDeleteAccessKey = "access-key.delete"
+	AuditActionCreateGrant     = "grant.create"
+	AuditActionDeleteGrant     = "grant.delete"
+)
+
+// AuditEvent records a change to a credential or a grant. The time of the
+// event is the CreatedAt time of the model.
+type AuditEvent struct {
+	Model
+	OrganizationMember
+
+	Action string
+	// ActorID is the identity that performed the action.
+	ActorID uid.ID
+	// TargetID and TargetName identify the credential or grant that was
+	// changed.
+	TargetID   uid.ID
+	TargetName string
+	ProviderID uid.ID
+}
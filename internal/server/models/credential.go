@@ -1,6 +1,10 @@
 package models
 
-import "github.com/infrahq/infra/uid"
+import (
+	"time"
+
+	"github.com/infrahq/infra/uid"
+)
 
 type Credential struct {
 	Model
@@ -9,4 +13,14 @@ type Credential struct {
 	IdentityID      uid.ID `gorm:"<-;uniqueIndex:idx_credentials_identity_id,where:deleted_at is NULL"`
 	PasswordHash    []byte
 	OneTimePassword bool
+
+	// FailedLoginAttempts is the number of failed login attempts since
+	// FailedLoginWindowStart.
+	FailedLoginAttempts int
+	// FailedLoginWindowStart is the time of the first failed login attempt
+	// that is counted in FailedLoginAttempts.
+	FailedLoginWindowStart time.Time
+	// LockedUntil is the time until which login attempts with the credential
+	// are rejected, because of too many failed login attempts.
+	LockedUntil time.Time
 }
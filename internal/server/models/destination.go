@@ -20,6 +20,10 @@ type Destination struct {
 
 	Resources CommaSeparatedStrings
 	Roles     CommaSeparatedStrings
+
+	// ResourceVersion is incremented every time the destination is updated
+	// with the API. It is only written by data.UpdateDestinationResourceVersion.
+	ResourceVersion int64 `gorm:"<-:false"`
 }
 
 func (d *Destination) ToAPI() *api.Destination {
@@ -45,5 +49,7 @@ func (d *Destination) ToAPI() *api.Destination {
 		LastSeen:  api.Time(d.LastSeenAt),
 		Connected: connected,
 		Version:   d.Version,
+
+		ResourceVersion: d.ResourceVersion,
 	}
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/uid"
 )
@@ -46,6 +48,7 @@ type Grant struct {
 	Privilege string            `gorm:"uniqueIndex:idx_grant_srp,where:deleted_at is NULL"` // role or permission
 	Resource  string            `gorm:"uniqueIndex:idx_grant_srp,where:deleted_at is NULL"` // Universal Resource Notation
 	CreatedBy uid.ID
+	ExpiresAt *time.Time // nil if the grant does not expire
 }
 
 func (r *Grant) ToAPI() *api.Grant {
@@ -58,6 +61,10 @@ func (r *Grant) ToAPI() *api.Grant {
 		Resource:  r.Resource,
 	}
 
+	if r.ExpiresAt != nil {
+		grant.Expires = api.Time(*r.ExpiresAt)
+	}
+
 	switch {
 	case r.Subject.IsIdentity():
 		identity, err := r.Subject.ID()
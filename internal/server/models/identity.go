@@ -25,6 +25,10 @@ type Identity struct {
 	Verified          bool
 	VerificationToken string
 
+	// ResourceVersion is incremented every time the user is updated with the
+	// API. It is only written by data.UpdateIdentityResourceVersion.
+	ResourceVersion int64 `gorm:"<-:false"`
+
 	// for eager loading, don't use these for saving.
 	Groups    []Group    `gorm:"many2many:identities_groups"`
 	Providers []Provider `gorm:"many2many:provider_users;"`
@@ -40,6 +44,7 @@ func (i *Identity) ToAPI() *api.User {
 		ProviderNames: slice.Map[Provider, string](i.Providers, func(p Provider) string {
 			return p.Name
 		}),
+		ResourceVersion: i.ResourceVersion,
 	}
 }
 
@@ -53,12 +53,35 @@ type Provider struct {
 	CreatedBy    uid.ID
 	Kind         ProviderKind
 	AuthURL      string
-	Scopes       CommaSeparatedStrings
+	// Scopes are the scopes supported by the provider, from its discovery
+	// document.
+	Scopes CommaSeparatedStrings
 
 	// fields used to directly query an external API
 	PrivateKey       EncryptedAtRest
 	ClientEmail      string
 	DomainAdminEmail string
+
+	// GroupsClaimName is the name of the claim in the user info response
+	// which contains the groups of the user. Defaults to "groups".
+	GroupsClaimName string
+
+	// EmailClaimName is the name of the claim in the ID token which contains
+	// the email address of the user. Defaults to "email".
+	EmailClaimName string
+
+	// RedirectURLs are the redirect URLs clients may use to login with the
+	// provider. When empty only the CLI redirect URL is allowed.
+	RedirectURLs CommaSeparatedStrings
+
+	// RequestedScopes are the scopes requested from the provider at login.
+	// When empty providers.DefaultScopes are requested.
+	RequestedScopes CommaSeparatedStrings
+
+	// Domains are the email domains of the users who login with the provider.
+	// Clients use them to select the provider from the email address of a
+	// user.
+	Domains CommaSeparatedStrings
 }
 
 func (p *Provider) ToAPI() *api.Provider {
@@ -73,5 +96,11 @@ func (p *Provider) ToAPI() *api.Provider {
 		Kind:     p.Kind.String(),
 		AuthURL:  p.AuthURL,
 		Scopes:   p.Scopes,
+
+		GroupsClaimName: p.GroupsClaimName,
+		EmailClaimName:  p.EmailClaimName,
+		RedirectURLs:    p.RedirectURLs,
+		RequestedScopes: p.RequestedScopes,
+		Domains:         p.Domains,
 	}
 }
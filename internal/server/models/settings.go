@@ -1,6 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/infrahq/infra/api"
 )
 
@@ -8,32 +13,117 @@ type Settings struct {
 	Model
 	OrganizationMember
 
+	// PrivateJWK is the key used to sign new tokens, and PublicJWK is the
+	// public part of the same key.
 	PrivateJWK EncryptedAtRest
 	PublicJWK  []byte
+	// RetiredJWKs are the public keys that were used to sign tokens before the
+	// signing key was rotated. They are published with PublicJWK until the
+	// grace period after rotation ends, so that existing tokens can be verified.
+	RetiredJWKs RetiredJWKs
 
 	LowercaseMin int `gorm:"default:0"`
 	UppercaseMin int `gorm:"default:0"`
 	NumberMin    int `gorm:"default:0"`
 	SymbolMin    int `gorm:"default:0"`
 	LengthMin    int `gorm:"default:8"`
+
+	// DefaultAccessKeyTTL is the lifetime of access keys that are created
+	// without an explicit expiry. When zero a default of 12 hours is used.
+	DefaultAccessKeyTTL time.Duration `gorm:"default:0"`
+	// MaxActiveAccessKeys is the maximum number of access keys, that are not
+	// expired, which may be issued for each identity. When zero a default of
+	// 100 is used.
+	MaxActiveAccessKeys int `gorm:"default:0"`
+
+	// SessionDuration is the maximum lifetime of a login session. Using the
+	// session does not extend it past this time. When zero the server default
+	// is used.
+	SessionDuration time.Duration `gorm:"default:0"`
+	// SessionExtensionDeadline is the amount of time a login session remains
+	// valid without being used. When zero the server default is used.
+	SessionExtensionDeadline time.Duration `gorm:"default:0"`
+}
+
+// RetiredJWK is a public key that was replaced by a newer signing key.
+type RetiredJWK struct {
+	PublicJWK json.RawMessage `json:"publicJWK"`
+	RetiredAt time.Time       `json:"retiredAt"`
+}
+
+// RetiredJWKs are stored as a JSON array.
+type RetiredJWKs []RetiredJWK
+
+func (k RetiredJWKs) Value() (driver.Value, error) {
+	if len(k) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal([]RetiredJWK(k))
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (k *RetiredJWKs) Scan(v interface{}) error {
+	var raw []byte
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		raw = []byte(value)
+	case []byte:
+		raw = value
+	default:
+		return fmt.Errorf("expected string type for retired JWKs, got %T", v)
+	}
+	return json.Unmarshal(raw, (*[]RetiredJWK)(k))
+}
+
+func (k RetiredJWKs) GormDataType() string {
+	return "text"
 }
 
 func (s *Settings) ToAPI() *api.Settings {
+	defaultAccessKeyTTL := api.Duration(s.DefaultAccessKeyTTL)
+	maxActiveAccessKeys := s.MaxActiveAccessKeys
+	sessionDuration := api.Duration(s.SessionDuration)
+	sessionExtensionDeadline := api.Duration(s.SessionExtensionDeadline)
 	return &api.Settings{
-		PasswordRequirements: api.PasswordRequirements{
+		PasswordRequirements: &api.PasswordRequirements{
 			LowercaseMin: s.LowercaseMin,
 			UppercaseMin: s.UppercaseMin,
 			NumberMin:    s.NumberMin,
 			SymbolMin:    s.SymbolMin,
 			LengthMin:    s.LengthMin,
 		},
+		DefaultAccessKeyTTL:      &defaultAccessKeyTTL,
+		MaxActiveAccessKeys:      &maxActiveAccessKeys,
+		SessionDuration:          &sessionDuration,
+		SessionExtensionDeadline: &sessionExtensionDeadline,
 	}
 }
 
+// SetFromAPI updates the settings from the fields that are set in a. Fields
+// that are nil are not changed.
 func (s *Settings) SetFromAPI(a *api.Settings) {
-	s.LengthMin = a.PasswordRequirements.LowercaseMin
-	s.UppercaseMin = a.PasswordRequirements.UppercaseMin
-	s.LowercaseMin = a.PasswordRequirements.LowercaseMin
-	s.SymbolMin = a.PasswordRequirements.SymbolMin
-	s.NumberMin = a.PasswordRequirements.NumberMin
+	if r := a.PasswordRequirements; r != nil {
+		s.LengthMin = r.LengthMin
+		s.UppercaseMin = r.UppercaseMin
+		s.LowercaseMin = r.LowercaseMin
+		s.SymbolMin = r.SymbolMin
+		s.NumberMin = r.NumberMin
+	}
+	if a.DefaultAccessKeyTTL != nil {
+		s.DefaultAccessKeyTTL = time.Duration(*a.DefaultAccessKeyTTL)
+	}
+	if a.MaxActiveAccessKeys != nil {
+		s.MaxActiveAccessKeys = *a.MaxActiveAccessKeys
+	}
+	if a.SessionDuration != nil {
+		s.SessionDuration = time.Duration(*a.SessionDuration)
+	}
+	if a.SessionExtensionDeadline != nil {
+		s.SessionExtensionDeadline = time.Duration(*a.SessionExtensionDeadline)
+	}
 }
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+)
+
+func TestRetiredJWKs_ValueAndScan(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		val, err := RetiredJWKs(nil).Value()
+		assert.NilError(t, err)
+		assert.Assert(t, val == nil)
+
+		var keys RetiredJWKs
+		assert.NilError(t, keys.Scan(nil))
+		assert.Equal(t, len(keys), 0)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		retiredAt := time.Date(2022, 10, 18, 9, 0, 0, 0, time.UTC)
+		keys := RetiredJWKs{{PublicJWK: []byte(`{"kid":"abc"}`), RetiredAt: retiredAt}}
+
+		val, err := keys.Value()
+		assert.NilError(t, err)
+
+		var scanned RetiredJWKs
+		assert.NilError(t, scanned.Scan(val))
+		assert.DeepEqual(t, scanned, keys)
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		var keys RetiredJWKs
+		assert.Error(t, keys.Scan(12), "expected string type for retired JWKs, got int")
+	})
+}
+
+func TestSettings_SetFromAPI(t *testing.T) {
+	settings := &Settings{
+		LengthMin:                8,
+		DefaultAccessKeyTTL:      time.Hour,
+		MaxActiveAccessKeys:      10,
+		SessionDuration:          8 * time.Hour,
+		SessionExtensionDeadline: time.Hour,
+	}
+
+	t.Run("omitted fields are unchanged", func(t *testing.T) {
+		s := *settings
+		maxActive := 20
+		s.SetFromAPI(&api.Settings{MaxActiveAccessKeys: &maxActive})
+
+		expected := *settings
+		expected.MaxActiveAccessKeys = 20
+		assert.DeepEqual(t, s, expected)
+	})
+
+	t.Run("all fields", func(t *testing.T) {
+		s := *settings
+		ttl := api.Duration(2 * time.Hour)
+		maxActive := 5
+		duration := api.Duration(4 * time.Hour)
+		extension := api.Duration(30 * time.Minute)
+		s.SetFromAPI(&api.Settings{
+			PasswordRequirements: &api.PasswordRequirements{
+				LowercaseMin: 1,
+				UppercaseMin: 2,
+				NumberMin:    3,
+				SymbolMin:    4,
+				LengthMin:    12,
+			},
+			DefaultAccessKeyTTL:      &ttl,
+			MaxActiveAccessKeys:      &maxActive,
+			SessionDuration:          &duration,
+			SessionExtensionDeadline: &extension,
+		})
+
+		expected := Settings{
+			LowercaseMin:             1,
+			UppercaseMin:             2,
+			NumberMin:                3,
+			SymbolMin:                4,
+			LengthMin:                12,
+			DefaultAccessKeyTTL:      2 * time.Hour,
+			MaxActiveAccessKeys:      5,
+			SessionDuration:          4 * time.Hour,
+			SessionExtensionDeadline: 30 * time.Minute,
+		}
+		assert.DeepEqual(t, s, expected)
+	})
+}
@@ -54,14 +54,21 @@ func openAPIRouteDefinition[Req, Res any](routeID routeIdentifier, route route[R
 	funcName string,
 	requestType reflect.Type,
 	resultType reflect.Type,
+	authenticated bool,
+	versionHeaderOptional bool,
 ) {
 	//nolint:gocritic
 	reqT, resultT := reflect.TypeOf(*new(Req)), reflect.TypeOf(*new(Res))
-	return routeID.method, routeID.path, getFuncName(route.handler), reqT, resultT
+	return routeID.method, routeID.path, getFuncName(route.handler), reqT, resultT,
+		!route.noAuthentication, route.infraVersionHeaderOptional
 }
 
+// bearerSecurityScheme is the name of the security scheme used by routes that
+// require an access key.
+const bearerSecurityScheme = "bearerAuth"
+
 // register adds the route to the API.OpenAPIDocument.
-func (a *API) register(method, path, funcName string, rqt, rst reflect.Type) {
+func (a *API) register(method, path, funcName string, rqt, rst reflect.Type, authenticated, versionHeaderOptional bool) {
 	path = pathIDReplacer.ReplaceAllStringFunc(path, func(s string) string {
 		return "{" + strings.TrimLeft(s, ":") + "}"
 	})
@@ -70,6 +77,18 @@ func (a *API) register(method, path, funcName string, rqt, rst reflect.Type) {
 		a.openAPIDoc.Components.Schemas = openapi3.Schemas{}
 	}
 
+	if a.openAPIDoc.Components.SecuritySchemes == nil {
+		a.openAPIDoc.Components.SecuritySchemes = openapi3.SecuritySchemes{
+			bearerSecurityScheme: &openapi3.SecuritySchemeRef{
+				Value: &openapi3.SecurityScheme{
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "An access key, which is a key ID and a secret separated by a period",
+				},
+			},
+		}
+	}
+
 	if a.openAPIDoc.Paths == nil {
 		a.openAPIDoc.Paths = openapi3.Paths{}
 	}
@@ -84,7 +103,17 @@ func (a *API) register(method, path, funcName string, rqt, rst reflect.Type) {
 	op.Description = funcName
 	op.Summary = funcName
 	buildRequest(rqt, op, method)
+	versionParam := &openapi3.ParameterRef{Value: a.infraVersionParameter(versionHeaderOptional)}
+	op.Parameters = append(openapi3.Parameters{versionParam}, op.Parameters...)
 	op.Responses = buildResponse(a.openAPIDoc.Components.Schemas, rst)
+	if example, ok := openAPIExamples[funcName]; ok {
+		addExamples(op, example)
+	}
+	if authenticated {
+		op.Security = &openapi3.SecurityRequirements{
+			openapi3.NewSecurityRequirement().Authenticate(bearerSecurityScheme),
+		}
+	}
 
 	for _, item := range funcPartialNameToTagNames {
 		if strings.Contains(funcName, item.partial) {
@@ -188,6 +217,11 @@ func buildProperty(f reflect.StructField, t, parent reflect.Type, parentSchema *
 		s.Items = buildProperty(f, t.Elem(), parent, parentSchema)
 	}
 
+	if t.Kind() == reflect.Map {
+		s.AdditionalProperties = buildProperty(reflect.StructField{}, t.Elem(), parent, parentSchema)
+		return &openapi3.SchemaRef{Value: s}
+	}
+
 	if s.Type == "object" {
 		s.Properties = openapi3.Schemas{}
 
@@ -255,6 +289,10 @@ type describeSchema interface {
 // `type` can be one of the following only: "object", "array", "string", "number", "integer", "boolean", "null".
 // `format` has a few defined types, but can be anything. https://swagger.io/docs/specification/data-models/data-types/
 func setTypeInfo(t reflect.Type, schema *openapi3.Schema) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
 	// TODO: convert to value earlier?
 	value := reflect.New(t).Interface()
 	if ds, ok := value.(describeSchema); ok {
@@ -269,10 +307,6 @@ func setTypeInfo(t reflect.Type, schema *openapi3.Schema) {
 		panic("field must use api.Duration")
 	}
 
-	if t.Kind() == reflect.Pointer {
-		t = t.Elem()
-	}
-
 	//nolint:exhaustive
 	switch t.Kind() {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -295,7 +329,7 @@ func setTypeInfo(t reflect.Type, schema *openapi3.Schema) {
 			return
 		}
 		schema.Type = "array"
-	case reflect.Struct:
+	case reflect.Struct, reflect.Map:
 		schema.Type = "object"
 	default:
 		panic("unexpected type " + t.Kind().String())
@@ -373,6 +407,27 @@ func buildResponse(schemas openapi3.Schemas, rst reflect.Type) openapi3.Response
 // so that tests expect a consistent value that does not change with every release.
 var productVersion = internal.FullVersion
 
+// infraVersionParameter returns the Infra-Version header parameter. The header
+// is required unless the route does not check it, or the server has a
+// DefaultAPIVersion for requests without the header.
+func (a *API) infraVersionParameter(routeOptional bool) *openapi3.Parameter {
+	schema := &openapi3.Schema{
+		Example:     productVersion(),
+		Format:      `\d+\.\d+\(.\d+)?(-.\w(+\w)?)?`,
+		Type:        "string",
+		Description: "Version of the API being requested",
+	}
+	if version := a.defaultVersion(); version != "" {
+		schema.Default = version
+	}
+	return &openapi3.Parameter{
+		Name:     "Infra-Version",
+		In:       "header",
+		Required: !routeOptional && a.defaultVersion() == "",
+		Schema:   &openapi3.SchemaRef{Value: schema},
+	}
+}
+
 func buildRequest(r reflect.Type, op *openapi3.Operation, method string) {
 	if r.Kind() == reflect.Pointer {
 		r = r.Elem()
@@ -384,20 +439,6 @@ func buildRequest(r reflect.Type, op *openapi3.Operation, method string) {
 
 	op.Parameters = openapi3.NewParameters()
 
-	op.AddParameter(&openapi3.Parameter{
-		Name:     "Infra-Version",
-		In:       "header",
-		Required: true,
-		Schema: &openapi3.SchemaRef{
-			Value: &openapi3.Schema{
-				Example:     productVersion(),
-				Format:      `\d+\.\d+\(.\d+)?(-.\w(+\w)?)?`,
-				Type:        "string",
-				Description: "Version of the API being requested",
-			},
-		},
-	})
-
 	schema := &openapi3.Schema{
 		Type:       "object",
 		Properties: openapi3.Schemas{},
@@ -410,9 +451,7 @@ func buildRequest(r reflect.Type, op *openapi3.Operation, method string) {
 
 			buildRequest(f.Type, tmpOp, method)
 			for _, param := range tmpOp.Parameters {
-				if param.Value.Name != "Infra-Version" {
-					op.AddParameter(param.Value)
-				}
+				op.AddParameter(param.Value)
 			}
 
 			if req, ok := reflect.New(f.Type).Interface().(validate.Request); ok {
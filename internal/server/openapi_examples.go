@@ -0,0 +1,117 @@
+package server
+
+import (
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/uid"
+)
+
+// openAPIExample is an example request body and response body for an
+// operation in the OpenAPI document. Either may be nil.
+type openAPIExample struct {
+	request  any
+	response any
+}
+
+// openAPIExamples maps the name of a handler to the examples for the operation
+// in the OpenAPI document. The examples use fixed values, so that the document
+// does not change every time it is generated.
+var openAPIExamples = map[string]openAPIExample{
+	"CreateAccessKey": {
+		request: api.CreateAccessKeyRequest{
+			UserID:            exampleUserID,
+			Name:              "deploy-pipeline",
+			TTL:               api.Duration(30 * 24 * time.Hour),
+			ExtensionDeadline: api.Duration(7 * 24 * time.Hour),
+		},
+		response: api.CreateAccessKeyResponse{
+			ID:                exampleAccessKeyID,
+			Created:           exampleTime,
+			Name:              "deploy-pipeline",
+			IssuedFor:         exampleUserID,
+			ProviderID:        exampleProviderID,
+			CreatedBy:         exampleAdminID,
+			Expires:           api.Time(time.Time(exampleTime).Add(30 * 24 * time.Hour)),
+			ExtensionDeadline: api.Time(time.Time(exampleTime).Add(7 * 24 * time.Hour)),
+			AccessKey:         "2tGUBfzPqh.KVj4zdTpSaMxQjiUR7LJG3Fb",
+		},
+	},
+	"ListAccessKeys": {
+		response: api.ListResponse[api.AccessKey]{
+			Count: 1,
+			Items: []api.AccessKey{
+				{
+					ID:                exampleAccessKeyID,
+					Created:           exampleTime,
+					Name:              "deploy-pipeline",
+					IssuedForName:     "deploy@example.com",
+					IssuedFor:         exampleUserID,
+					ProviderID:        exampleProviderID,
+					CreatedBy:         exampleAdminID,
+					Expires:           api.Time(time.Time(exampleTime).Add(30 * 24 * time.Hour)),
+					ExtensionDeadline: api.Time(time.Time(exampleTime).Add(7 * 24 * time.Hour)),
+					LastUsed:          exampleTime,
+				},
+			},
+		},
+	},
+	"CreateGrant": {
+		request: api.CreateGrantRequest{
+			User:      exampleUserID,
+			Privilege: "view",
+			Resource:  "production.web",
+		},
+		response: api.CreateGrantResponse{
+			Grant:      &exampleGrant,
+			WasCreated: true,
+		},
+	},
+	"ListGrants": {
+		response: api.ListResponse[api.Grant]{
+			Count: 1,
+			Items: []api.Grant{exampleGrant},
+		},
+	},
+}
+
+var (
+	exampleTime        = api.Time(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	exampleUserID      = uid.ID(71776463228551168)
+	exampleAdminID     = uid.ID(71776463228551169)
+	exampleProviderID  = uid.ID(71776463228551170)
+	exampleAccessKeyID = uid.ID(71776463228551171)
+
+	exampleGrant = api.Grant{
+		ID:        uid.ID(71776463228551172),
+		Created:   exampleTime,
+		CreatedBy: exampleAdminID,
+		Updated:   exampleTime,
+		User:      exampleUserID,
+		Privilege: "view",
+		Resource:  "production.web",
+	}
+)
+
+// addExamples adds the request and response examples to the operation.
+func addExamples(op *openapi3.Operation, example openAPIExample) {
+	if example.request != nil && op.RequestBody != nil {
+		if content := op.RequestBody.Value.Content.Get("application/json"); content != nil {
+			content.Examples = openapi3.Examples{
+				"example": &openapi3.ExampleRef{Value: openapi3.NewExample(example.request)},
+			}
+		}
+	}
+
+	if example.response != nil {
+		if resp := op.Responses.Default(); resp != nil {
+			if content := resp.Value.Content.Get("application/json"); content != nil {
+				content.Examples = openapi3.Examples{
+					"example": &openapi3.ExampleRef{Value: openapi3.NewExample(example.response)},
+				}
+			}
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package server
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/prometheus/client_golang/prometheus"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/golden"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal/validate"
 )
 
 // TestWriteOpenAPIDocToFile runs the OpenAPI document generation to preview the changes.
@@ -40,3 +45,81 @@ func patchProductVersion(t *testing.T, version string) {
 	})
 
 }
+
+func TestOpenAPIDocument_Security(t *testing.T) {
+	s := Server{metricsRegistry: prometheus.NewRegistry()}
+	doc := s.GenerateRoutes().OpenAPIDocument
+
+	scheme, ok := doc.Components.SecuritySchemes[bearerSecurityScheme]
+	assert.Assert(t, ok, "missing security scheme")
+	assert.Equal(t, scheme.Value.Type, "http")
+	assert.Equal(t, scheme.Value.Scheme, "bearer")
+
+	t.Run("authenticated route", func(t *testing.T) {
+		op := doc.Paths["/api/users"].Get
+		assert.Assert(t, op.Security != nil)
+		expected := openapi3.SecurityRequirements{{bearerSecurityScheme: []string{}}}
+		assert.DeepEqual(t, *op.Security, expected)
+
+		versionHeader := op.Parameters.GetByInAndName("header", "Infra-Version")
+		assert.Assert(t, versionHeader != nil)
+		assert.Assert(t, versionHeader.Required)
+	})
+
+	t.Run("route without authentication", func(t *testing.T) {
+		op := doc.Paths["/api/login"].Post
+		assert.Assert(t, op.Security == nil)
+
+		versionHeader := op.Parameters.GetByInAndName("header", "Infra-Version")
+		assert.Assert(t, versionHeader != nil)
+		assert.Assert(t, versionHeader.Required)
+	})
+}
+
+func TestOpenAPIDocument_DefaultAPIVersion(t *testing.T) {
+	s := Server{
+		metricsRegistry: prometheus.NewRegistry(),
+		options:         Options{DefaultAPIVersion: "0.14.0"},
+	}
+	doc := s.GenerateRoutes().OpenAPIDocument
+
+	versionHeader := doc.Paths["/api/users"].Get.Parameters.GetByInAndName("header", "Infra-Version")
+	assert.Assert(t, versionHeader != nil)
+	assert.Assert(t, !versionHeader.Required)
+	assert.Equal(t, versionHeader.Schema.Value.Default, "0.14.0")
+}
+
+func TestOpenAPIDocument_Examples(t *testing.T) {
+	s := Server{metricsRegistry: prometheus.NewRegistry()}
+	doc := s.GenerateRoutes().OpenAPIDocument
+
+	op := doc.Paths["/api/access-keys"].Post
+	assert.Equal(t, op.OperationID, "CreateAccessKey")
+
+	requestExamples := op.RequestBody.Value.Content.Get("application/json").Examples
+	assert.DeepEqual(t, requestExamples["example"].Value.Value, openAPIExamples["CreateAccessKey"].request)
+
+	responseExamples := op.Responses.Default().Value.Content.Get("application/json").Examples
+	assert.DeepEqual(t, responseExamples["example"].Value.Value, openAPIExamples["CreateAccessKey"].response)
+
+	t.Run("examples are valid for the schema", func(t *testing.T) {
+		raw, err := json.Marshal(openAPIExamples["CreateAccessKey"].request)
+		assert.NilError(t, err)
+
+		var req api.CreateAccessKeyRequest
+		assert.NilError(t, json.Unmarshal(raw, &req))
+		assert.NilError(t, validate.Validate(req))
+	})
+
+	t.Run("every example is for a registered operation", func(t *testing.T) {
+		operations := map[string]bool{}
+		for _, item := range doc.Paths {
+			for _, op := range item.Operations() {
+				operations[op.OperationID] = true
+			}
+		}
+		for name := range openAPIExamples {
+			assert.Assert(t, operations[name], "no operation named %v", name)
+		}
+	})
+}
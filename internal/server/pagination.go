@@ -1,10 +1,16 @@
 package server
 
 import (
+	"encoding/base64"
 	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/validate"
+	"github.com/infrahq/infra/uid"
 )
 
 // PaginationFromRequest translates an api.PaginationRequest into the internal
@@ -26,6 +32,26 @@ func PaginationFromRequest(pr api.PaginationRequest) data.Pagination {
 	}
 }
 
+// cursorPaginationFromRequest translates an api.PaginationRequest into the
+// internal Pagination type for list operations that support cursors. The
+// items after cursor are selected when it is set. When neither cursor nor a
+// page is requested the first page is selected by cursor, so that the
+// response includes the cursor of the next page.
+func cursorPaginationFromRequest(pr api.PaginationRequest, cursor string) (data.Pagination, error) {
+	p := PaginationFromRequest(pr)
+	switch {
+	case cursor != "":
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return p, err
+		}
+		p.Cursor = c
+	case pr.Page == 0:
+		p.Cursor = &data.Cursor{}
+	}
+	return p, nil
+}
+
 // PaginationToResponse translates an internal Pagination type into the pagination
 // response.
 func PaginationToResponse(p data.Pagination) api.PaginationResponse {
@@ -34,5 +60,39 @@ func PaginationToResponse(p data.Pagination) api.PaginationResponse {
 		Limit:      p.Limit,
 		TotalCount: p.TotalCount,
 		TotalPages: int(math.Ceil(float64(p.TotalCount) / float64(p.Limit))),
+		NextCursor: encodeCursor(p.NextCursor),
+	}
+}
+
+// encodeCursor returns an opaque string that can be sent in an API response,
+// and decoded by decodeCursor. The ID and creation time of the cursor are
+// separated by a tilde.
+func encodeCursor(cursor *data.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+	raw := cursor.ID.String() + "~" + strconv.FormatInt(cursor.Created.UnixNano(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (*data.Cursor, error) {
+	invalid := validate.Error{"cursor": {"invalid cursor"}}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalid
+	}
+	id, created, ok := strings.Cut(string(raw), "~")
+	if !ok {
+		return nil, invalid
+	}
+	cursorID, err := uid.Parse([]byte(id))
+	if err != nil {
+		return nil, invalid
+	}
+	nanos, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return nil, invalid
 	}
+	return &data.Cursor{Created: time.Unix(0, nanos).UTC(), ID: cursorID}, nil
 }
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/validate"
+)
+
+func TestEncodeDecodeCursor(t *testing.T) {
+	cursor := &data.Cursor{
+		Created: time.Date(2022, 10, 14, 9, 30, 15, 123456000, time.UTC),
+		ID:      12345,
+	}
+
+	encoded := encodeCursor(cursor)
+	assert.Assert(t, encoded != "")
+
+	decoded, err := decodeCursor(encoded)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, decoded, cursor)
+
+	assert.Equal(t, encodeCursor(nil), "")
+
+	_, err = decodeCursor("not a cursor")
+	assert.DeepEqual(t, err, validate.Error{"cursor": {"invalid cursor"}})
+
+	invalid := base64.RawURLEncoding.EncodeToString([]byte("12345~yesterday"))
+	_, err = decodeCursor(invalid)
+	assert.DeepEqual(t, err, validate.Error{"cursor": {"invalid cursor"}})
+
+	invalid = base64.RawURLEncoding.EncodeToString([]byte("12345.the-key"))
+	_, err = decodeCursor(invalid)
+	assert.DeepEqual(t, err, validate.Error{"cursor": {"invalid cursor"}})
+}
+
+func TestCursorPaginationFromRequest(t *testing.T) {
+	t.Run("page", func(t *testing.T) {
+		p, err := cursorPaginationFromRequest(api.PaginationRequest{Page: 2, Limit: 10}, "")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, p, data.Pagination{Page: 2, Limit: 10})
+	})
+
+	t.Run("first page by cursor", func(t *testing.T) {
+		p, err := cursorPaginationFromRequest(api.PaginationRequest{Limit: 10}, "")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, p, data.Pagination{Page: 1, Limit: 10, Cursor: &data.Cursor{}})
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		cursor := &data.Cursor{Created: time.Date(2022, 10, 14, 9, 30, 0, 0, time.UTC), ID: 12345}
+		p, err := cursorPaginationFromRequest(api.PaginationRequest{Page: 3}, encodeCursor(cursor))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, p, data.Pagination{Page: 3, Limit: 100, Cursor: cursor})
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := cursorPaginationFromRequest(api.PaginationRequest{}, "not a cursor")
+		assert.DeepEqual(t, err, validate.Error{"cursor": {"invalid cursor"}})
+	})
+}
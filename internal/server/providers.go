@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -13,6 +14,8 @@ import (
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/access"
 	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/server/providers"
+	"github.com/infrahq/infra/internal/validate"
 )
 
 // caution: this endpoint is unauthenticated, do not return sensitive info
@@ -60,6 +63,11 @@ func (a *API) CreateProvider(c *gin.Context, r *api.CreateProviderRequest) (*api
 		URL:          cleanupURL(r.URL),
 		ClientID:     r.ClientID,
 		ClientSecret: models.EncryptedAtRest(r.ClientSecret),
+
+		GroupsClaimName: r.GroupsClaimName,
+		EmailClaimName:  r.EmailClaimName,
+		RedirectURLs:    r.RedirectURLs,
+		Domains:         r.Domains,
 	}
 
 	if r.API != nil {
@@ -75,7 +83,14 @@ func (a *API) CreateProvider(c *gin.Context, r *api.CreateProviderRequest) (*api
 	}
 	provider.Kind = kind
 
-	if err := a.setProviderInfoFromServer(c, provider); err != nil {
+	if len(r.RequestedScopes) > 0 {
+		provider.RequestedScopes = providers.ScopesWithOpenID(r.RequestedScopes)
+	}
+
+	if r.SkipValidation {
+		// the provider can not be reached, use the scopes required for login
+		provider.Scopes = []string{"openid", "email"}
+	} else if err := a.setProviderInfoFromServer(c, provider); err != nil {
 		return nil, err
 	}
 
@@ -95,6 +110,11 @@ func (a *API) UpdateProvider(c *gin.Context, r *api.UpdateProviderRequest) (*api
 		URL:          cleanupURL(r.URL),
 		ClientID:     r.ClientID,
 		ClientSecret: models.EncryptedAtRest(r.ClientSecret),
+
+		GroupsClaimName: r.GroupsClaimName,
+		EmailClaimName:  r.EmailClaimName,
+		RedirectURLs:    r.RedirectURLs,
+		Domains:         r.Domains,
 	}
 
 	if r.API != nil {
@@ -110,7 +130,25 @@ func (a *API) UpdateProvider(c *gin.Context, r *api.UpdateProviderRequest) (*api
 	}
 	provider.Kind = kind
 
-	if err := a.setProviderInfoFromServer(c, provider); err != nil {
+	if len(r.RequestedScopes) > 0 {
+		provider.RequestedScopes = providers.ScopesWithOpenID(r.RequestedScopes)
+	}
+
+	existing, err := access.GetProvider(c, r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the provider configuration may have changed, so discover it again
+	providers.InvalidateDiscoveryCache(existing.URL)
+	providers.InvalidateDiscoveryCache(provider.URL)
+
+	if r.SkipValidation {
+		// the provider can not be reached, keep the information from when it
+		// was last checked
+		provider.AuthURL = existing.AuthURL
+		provider.Scopes = existing.Scopes
+	} else if err := a.setProviderInfoFromServer(c, provider); err != nil {
 		return nil, err
 	}
 
@@ -125,6 +163,87 @@ func (a *API) DeleteProvider(c *gin.Context, r *api.Resource) (*api.EmptyRespons
 	return nil, access.DeleteProvider(c, r.ID)
 }
 
+// TestProvider checks that the OpenID discovery document of a provider can be
+// retrieved, so that admins can find configuration problems before users try
+// to login.
+func (a *API) TestProvider(c *gin.Context, r *api.Resource) (*api.TestProviderResponse, error) {
+	if _, err := access.RequireInfraRole(c, models.InfraAdminRole); err != nil {
+		return nil, access.HandleAuthErr(err, "provider", "test", models.InfraAdminRole)
+	}
+
+	provider, err := access.GetProvider(c, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if provider.Kind == models.ProviderKindInfra {
+		return nil, fmt.Errorf("%w: the infra provider can not be tested", internal.ErrBadRequest)
+	}
+
+	client, err := a.providerClient(c, provider, "http://localhost:8301")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", internal.ErrBadRequest, err)
+	}
+
+	result, err := client.Discover(c)
+	if err != nil {
+		var discoveryErr providers.DiscoveryError
+		if !errors.As(err, &discoveryErr) {
+			return nil, err
+		}
+		return &api.TestProviderResponse{
+			ErrorKind: discoveryErr.Kind,
+			Error:     discoveryErr.Err.Error(),
+		}, nil
+	}
+
+	return &api.TestProviderResponse{
+		Success:     true,
+		Issuer:      result.Issuer,
+		AuthURL:     result.AuthURL,
+		TokenURL:    result.TokenURL,
+		UserInfoURL: result.UserInfoURL,
+		JWKSURL:     result.JWKSURL,
+	}, nil
+}
+
+// StartDeviceAuthorization starts the device authorization grant with an
+// identity provider, for clients that can not open a browser or receive a
+// redirect. The client logs in with the device code after the user has
+// authorized the device.
+func (a *API) StartDeviceAuthorization(c *gin.Context, r *api.Resource) (*api.DeviceAuthorizationResponse, error) {
+	provider, err := access.GetProvider(c, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if provider.Kind == models.ProviderKindInfra {
+		return nil, fmt.Errorf("%w: the infra provider does not support device authorization", internal.ErrBadRequest)
+	}
+
+	client, err := a.providerClient(c, provider, "")
+	if err != nil {
+		return nil, fmt.Errorf("update provider client: %w", err)
+	}
+
+	auth, err := client.StartDeviceAuthorization(c)
+	switch {
+	case errors.Is(err, providers.ErrDeviceAuthorizationNotSupported):
+		return nil, fmt.Errorf("%w: %s", internal.ErrBadRequest, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, fmt.Errorf("%w: %s", internal.ErrBadGateway, err)
+	case err != nil:
+		return nil, err
+	}
+
+	return &api.DeviceAuthorizationResponse{
+		DeviceCode:              auth.DeviceCode,
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		Expires:                 api.Time(auth.ExpiresAt),
+		Interval:                int(auth.Interval / time.Second),
+	}, nil
+}
+
 // setProviderInfoFromServer checks information provided by an OIDC server
 func (a *API) setProviderInfoFromServer(c *gin.Context, provider *models.Provider) error {
 	// create a provider client to validate the server and get its info
@@ -133,6 +252,18 @@ func (a *API) setProviderInfoFromServer(c *gin.Context, provider *models.Provide
 		return fmt.Errorf("%w: %s", internal.ErrBadRequest, err)
 	}
 
+	// check the domain has a discovery document before checking the client
+	// credentials, so that the error describes why discovery failed
+	if _, err := oidc.Discover(c); err != nil {
+		var discoveryErr providers.DiscoveryError
+		if !errors.As(err, &discoveryErr) {
+			return err
+		}
+		return validate.Error{"url": {
+			fmt.Sprintf("could not find an OpenID configuration (%v): %v", discoveryErr.Kind, discoveryErr.Err),
+		}}
+	}
+
 	err = oidc.Validate(c)
 	if err != nil {
 		return err
@@ -43,11 +43,37 @@ func (a *azure) AuthServerInfo(ctx context.Context) (*AuthServerInfo, error) {
 	return a.OIDCClient.AuthServerInfo(ctx)
 }
 
-func (a *azure) ExchangeAuthCodeForProviderTokens(ctx context.Context, code string) (rawAccessToken, rawRefreshToken string, accessTokenExpiry time.Time, email string, err error) {
-	return a.OIDCClient.ExchangeAuthCodeForProviderTokens(ctx, code)
+func (a *azure) Discover(ctx context.Context) (*DiscoveryResult, error) {
+	return a.OIDCClient.Discover(ctx)
 }
 
-func (a *azure) RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error) {
+// ExchangeAuthCodeForProviderTokens never returns groups. The groups claim of
+// an Azure ID token contains the object IDs of the groups, not their names, so
+// the groups are read from the Graph API by GetUserInfo.
+func (a *azure) ExchangeAuthCodeForProviderTokens(ctx context.Context, code, redirectURL string) (rawAccessToken, rawRefreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	rawAccessToken, rawRefreshToken, accessTokenExpiry, email, _, err = a.OIDCClient.ExchangeAuthCodeForProviderTokens(ctx, code, redirectURL)
+	return rawAccessToken, rawRefreshToken, accessTokenExpiry, email, nil, err
+}
+
+// VerifyIDToken never returns groups, for the same reason as
+// ExchangeAuthCodeForProviderTokens.
+func (a *azure) VerifyIDToken(ctx context.Context, rawIDToken string) (email string, groups []string, err error) {
+	email, _, err = a.OIDCClient.VerifyIDToken(ctx, rawIDToken)
+	return email, nil, err
+}
+
+func (a *azure) StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	return a.OIDCClient.StartDeviceAuthorization(ctx)
+}
+
+// PollDeviceAuthorization never returns groups, for the same reason as
+// ExchangeAuthCodeForProviderTokens.
+func (a *azure) PollDeviceAuthorization(ctx context.Context, auth *DeviceAuthorization) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	accessToken, refreshToken, accessTokenExpiry, email, _, err = a.OIDCClient.PollDeviceAuthorization(ctx, auth)
+	return accessToken, refreshToken, accessTokenExpiry, email, nil, err
+}
+
+func (a *azure) RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error) {
 	return a.OIDCClient.RefreshAccessToken(ctx, providerUser)
 }
 
@@ -95,7 +121,7 @@ func checkMemberOfGraphGroups(ctx context.Context, accessToken string) ([]string
 
 	req.Header.Add("Authorization", bearer)
 
-	ctx, cancel := context.WithTimeout(ctx, oidcProviderRequestTimeout)
+	ctx, cancel := withRequestContext(ctx)
 	defer cancel()
 
 	client := http.DefaultClient
@@ -0,0 +1,280 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/infrahq/infra/internal/logging"
+)
+
+// deviceCodeGrantType is the grant_type used to request tokens with a device
+// code, from RFC 8628.
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultDevicePollInterval is the time to wait between requests to the token
+// endpoint when the provider does not include an interval in the device
+// authorization response.
+const defaultDevicePollInterval = 5 * time.Second
+
+var (
+	// ErrDeviceAuthorizationNotSupported is returned when the provider does
+	// not advertise a device_authorization_endpoint.
+	ErrDeviceAuthorizationNotSupported = errors.New("provider does not support the device authorization grant")
+	// ErrDeviceAuthorizationPending is returned when the user has not
+	// completed the device authorization yet. The same device code may be
+	// polled again until it expires.
+	ErrDeviceAuthorizationPending = errors.New("device authorization is pending")
+	// ErrDeviceAuthorizationSlowDown is returned when the device code was
+	// polled too often. The interval between polls must be increased.
+	ErrDeviceAuthorizationSlowDown = errors.New("device authorization is pending, poll less often")
+	// ErrDeviceAuthorizationExpired is returned when the device code expired
+	// before the user completed the device authorization.
+	ErrDeviceAuthorizationExpired = errors.New("device authorization expired")
+	// ErrDeviceAuthorizationDenied is returned when the user denied the
+	// device authorization.
+	ErrDeviceAuthorizationDenied = errors.New("device authorization was denied")
+)
+
+// DeviceAuthorization is the response from the device authorization endpoint
+// of a provider. The user must visit VerificationURI and enter UserCode to
+// authorize the device, while the device polls the token endpoint with
+// DeviceCode.
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresAt               time.Time
+	// Interval is the minimum amount of time to wait between requests to the
+	// token endpoint.
+	Interval time.Duration
+}
+
+// StartDeviceAuthorization starts the device authorization grant by requesting
+// a device code and user code from the provider.
+func (o *oidcClientImplementation) StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	ctx, cancel := withRequestContext(ctx)
+	defer cancel()
+
+	conf, provider, err := o.clientConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client device authorization: %w", err)
+	}
+
+	var claims struct {
+		DeviceAuthorizationURL string `json:"device_authorization_endpoint"`
+	}
+	if err := provider.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("could not parse provider claims: %w", err)
+	}
+	if claims.DeviceAuthorizationURL == "" {
+		return nil, ErrDeviceAuthorizationNotSupported
+	}
+
+	form := url.Values{
+		"client_id": {o.ClientID},
+		"scope":     {strings.Join(o.Scopes, " ")},
+	}
+	if conf.ClientSecret != "" {
+		form.Set("client_secret", conf.ClientSecret)
+	}
+
+	var resp struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURL         string `json:"verification_url"` // used by Google
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	status, errResp, err := postForm(ctx, claims.DeviceAuthorizationURL, form, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("device authorization: %v", errResp)
+	}
+	if resp.DeviceCode == "" || resp.UserCode == "" {
+		return nil, errors.New("device authorization response is missing the device code or user code")
+	}
+
+	auth := &DeviceAuthorization{
+		DeviceCode:              resp.DeviceCode,
+		UserCode:                resp.UserCode,
+		VerificationURI:         resp.VerificationURI,
+		VerificationURIComplete: resp.VerificationURIComplete,
+		ExpiresAt:               time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+		Interval:                time.Duration(resp.Interval) * time.Second,
+	}
+	if auth.VerificationURI == "" {
+		auth.VerificationURI = resp.VerificationURL
+	}
+	if auth.Interval <= 0 {
+		auth.Interval = defaultDevicePollInterval
+	}
+	return auth, nil
+}
+
+// PollDeviceAuthorization sends a single request to the token endpoint of the
+// provider with the device code. It does not wait for the user, the client
+// polls again after the interval of the device authorization while it receives
+// ErrDeviceAuthorizationPending, and increases the interval when it receives
+// ErrDeviceAuthorizationSlowDown.
+// groups is nil when the ID token does not include a groups claim, in which case
+// the groups must be read from the user info endpoint.
+func (o *oidcClientImplementation) PollDeviceAuthorization(ctx context.Context, auth *DeviceAuthorization) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	if !auth.ExpiresAt.IsZero() && time.Now().After(auth.ExpiresAt) {
+		return "", "", time.Time{}, "", nil, ErrDeviceAuthorizationExpired
+	}
+
+	token, errResp, err := o.requestDeviceToken(ctx, auth.DeviceCode)
+	if err != nil {
+		return "", "", time.Time{}, "", nil, err
+	}
+
+	switch errResp.Error {
+	case "":
+		return o.deviceTokenResult(ctx, token)
+	case "authorization_pending":
+		return "", "", time.Time{}, "", nil, ErrDeviceAuthorizationPending
+	case "slow_down":
+		return "", "", time.Time{}, "", nil, ErrDeviceAuthorizationSlowDown
+	case "expired_token":
+		return "", "", time.Time{}, "", nil, ErrDeviceAuthorizationExpired
+	case "access_denied":
+		return "", "", time.Time{}, "", nil, ErrDeviceAuthorizationDenied
+	default:
+		return "", "", time.Time{}, "", nil, fmt.Errorf("device access token: %v", errResp)
+	}
+}
+
+type deviceTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+// requestDeviceToken sends a single request to the token endpoint with the
+// device code. errResp is set when the provider responded with an error.
+func (o *oidcClientImplementation) requestDeviceToken(ctx context.Context, deviceCode string) (*deviceTokenResponse, oauthErrorResponse, error) {
+	ctx, cancel := withRequestContext(ctx)
+	defer cancel()
+
+	conf, _, err := o.clientConfig(ctx)
+	if err != nil {
+		return nil, oauthErrorResponse{}, fmt.Errorf("client device access token: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {deviceCode},
+		"client_id":   {o.ClientID},
+	}
+	if conf.ClientSecret != "" {
+		form.Set("client_secret", conf.ClientSecret)
+	}
+
+	token := &deviceTokenResponse{}
+	status, errResp, err := postForm(ctx, conf.Endpoint.TokenURL, form, token)
+	if err != nil {
+		return nil, oauthErrorResponse{}, fmt.Errorf("device access token: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, errResp, nil
+	}
+	return token, errResp, nil
+}
+
+func (o *oidcClientImplementation) deviceTokenResult(ctx context.Context, token *deviceTokenResponse) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	ctx, cancel := withRequestContext(ctx)
+	defer cancel()
+
+	_, provider, err := o.clientConfig(ctx)
+	if err != nil {
+		return "", "", time.Time{}, "", nil, fmt.Errorf("client device access token: %w", err)
+	}
+
+	if token.IDToken == "" {
+		return "", "", time.Time{}, "", nil, errors.New("could not extract id_token from device access token response")
+	}
+	if token.RefreshToken == "" {
+		logging.Warnf("no refresh token returned from oidc client for %q, session lifetime will be reduced", o.Domain)
+	}
+
+	email, groups, err = o.verifyIDToken(ctx, provider, token.IDToken)
+	if err != nil {
+		return "", "", time.Time{}, "", nil, err
+	}
+
+	var expiry time.Time
+	if token.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token.AccessToken, token.RefreshToken, expiry, email, groups, nil
+}
+
+// oauthErrorResponse is the body of an error response from an OAuth2 endpoint.
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e oauthErrorResponse) String() string {
+	if e.ErrorDescription == "" {
+		return e.Error
+	}
+	return e.Error + ": " + e.ErrorDescription
+}
+
+// postForm sends a form encoded POST request to an OAuth2 endpoint. When the
+// response is successful the body is decoded into v, otherwise it is decoded
+// as an error response.
+func postForm(ctx context.Context, endpoint string, form url.Values, v interface{}) (int, oauthErrorResponse, error) {
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = c
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, oauthErrorResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, oauthErrorResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, oauthErrorResponse{}, err
+	}
+
+	var errResp oauthErrorResponse
+	if resp.StatusCode != http.StatusOK {
+		// the body may not be JSON, the status is used when there is no error
+		_ = json.Unmarshal(body, &errResp)
+		if errResp.Error == "" {
+			errResp.Error = strings.ToLower(http.StatusText(resp.StatusCode))
+		}
+		return resp.StatusCode, errResp, nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return 0, oauthErrorResponse{}, fmt.Errorf("decode response: %w", err)
+	}
+	return resp.StatusCode, errResp, nil
+}
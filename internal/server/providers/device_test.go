@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/server/models"
+)
+
+func TestStartDeviceAuthorization(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	var form map[string][]string
+	serverURL := server.run(t, func(t *testing.T, mux *http.ServeMux) {
+		mux.HandleFunc("/device", func(w http.ResponseWriter, req *http.Request) {
+			assert.Check(t, req.ParseForm())
+			form = req.PostForm
+			w.Header().Add("Content-Type", "application/json")
+			_, err := io.WriteString(w, `{
+				"device_code": "the-device-code",
+				"user_code": "WDJB-MJHT",
+				"verification_uri": "https://example.com/activate",
+				"expires_in": 600,
+				"interval": 3
+			}`)
+			assert.Check(t, err)
+		})
+	})
+
+	client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "client-secret", "", nil)
+
+	before := time.Now()
+	auth, err := client.StartDeviceAuthorization(ctx)
+	assert.NilError(t, err)
+
+	assert.Equal(t, auth.DeviceCode, "the-device-code")
+	assert.Equal(t, auth.UserCode, "WDJB-MJHT")
+	assert.Equal(t, auth.VerificationURI, "https://example.com/activate")
+	assert.Equal(t, auth.Interval, 3*time.Second)
+	assert.Assert(t, !auth.ExpiresAt.Before(before.Add(10*time.Minute)))
+
+	assert.Equal(t, form["client_id"][0], "client-id")
+	assert.Equal(t, form["client_secret"][0], "client-secret")
+	assert.Equal(t, form["scope"][0], "openid email groups offline_access")
+}
+
+func TestPollDeviceAuthorization(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	now := time.Now().UTC()
+	success, err := testTokenResponse(jwt.Claims{
+		Audience:  jwt.Audience([]string{"client-id"}),
+		NotBefore: jwt.NewNumericDate(now.Add(-5 * time.Minute)), // adjust for clock drift
+		Expiry:    jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    "https://" + serverURL,
+	}, server.signingKey, "device@example.com")
+	assert.NilError(t, err)
+
+	errorResponse := func(code string) tokenResponse {
+		return tokenResponse{code: http.StatusBadRequest, body: fmt.Sprintf(`{"error": %q}`, code)}
+	}
+
+	client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "client-secret", "", nil)
+	newAuth := func() *DeviceAuthorization {
+		return &DeviceAuthorization{
+			DeviceCode: "the-device-code",
+			ExpiresAt:  time.Now().Add(10 * time.Minute),
+			Interval:   2 * time.Second,
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		server.tokenResponses = nil
+		server.tokenResponse = tokenResponse{code: http.StatusOK, body: success}
+
+		accessToken, refreshToken, _, email, _, err := client.PollDeviceAuthorization(ctx, newAuth())
+		assert.NilError(t, err)
+		assert.Assert(t, accessToken != "")
+		assert.Equal(t, refreshToken, "a9VpZDRCeFh3Nkk2VdY")
+		assert.Equal(t, email, "device@example.com")
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		server.tokenResponses = []tokenResponse{errorResponse("authorization_pending")}
+		server.tokenResponse = tokenResponse{code: http.StatusOK, body: success}
+
+		_, _, _, _, _, err := client.PollDeviceAuthorization(ctx, newAuth())
+		assert.ErrorIs(t, err, ErrDeviceAuthorizationPending)
+	})
+
+	t.Run("slow down", func(t *testing.T) {
+		server.tokenResponses = []tokenResponse{errorResponse("slow_down")}
+		server.tokenResponse = tokenResponse{code: http.StatusOK, body: success}
+
+		_, _, _, _, _, err := client.PollDeviceAuthorization(ctx, newAuth())
+		assert.ErrorIs(t, err, ErrDeviceAuthorizationSlowDown)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		server.tokenResponses = nil
+		server.tokenResponse = errorResponse("expired_token")
+
+		_, _, _, _, _, err := client.PollDeviceAuthorization(ctx, newAuth())
+		assert.ErrorIs(t, err, ErrDeviceAuthorizationExpired)
+	})
+
+	t.Run("access denied", func(t *testing.T) {
+		server.tokenResponses = nil
+		server.tokenResponse = errorResponse("access_denied")
+
+		_, _, _, _, _, err := client.PollDeviceAuthorization(ctx, newAuth())
+		assert.ErrorIs(t, err, ErrDeviceAuthorizationDenied)
+	})
+
+	t.Run("device code expired before the first poll", func(t *testing.T) {
+		auth := newAuth()
+		auth.ExpiresAt = time.Now().Add(-time.Second)
+
+		_, _, _, _, _, err := client.PollDeviceAuthorization(ctx, auth)
+		assert.ErrorIs(t, err, ErrDeviceAuthorizationExpired)
+	})
+}
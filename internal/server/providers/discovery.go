@@ -0,0 +1,145 @@
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+const defaultDiscoveryCacheTTL = 10 * time.Minute
+
+var discoveryCacheTTL = int64(defaultDiscoveryCacheTTL)
+
+// SetDiscoveryCacheTTL sets the amount of time the OpenID discovery document
+// of a provider is cached before it is requested again. A ttl of zero uses the
+// default of 10 minutes.
+func SetDiscoveryCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultDiscoveryCacheTTL
+	}
+	atomic.StoreInt64(&discoveryCacheTTL, int64(ttl))
+}
+
+func getDiscoveryCacheTTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&discoveryCacheTTL))
+}
+
+type discoveryCacheEntry struct {
+	provider  *oidc.Provider
+	expiresAt time.Time
+}
+
+// discoveryCache stores the result of oidc.NewProvider by domain, so that
+// repeated logins do not request the discovery document from the identity
+// provider every time.
+type discoveryCache struct {
+	mu      sync.Mutex
+	entries map[string]discoveryCacheEntry
+}
+
+var providerDiscoveryCache = &discoveryCache{entries: map[string]discoveryCacheEntry{}}
+
+func (c *discoveryCache) get(ctx context.Context, domain string) (*oidc.Provider, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[domain]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, fmt.Sprintf("https://%s", domain))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[domain] = discoveryCacheEntry{
+		provider:  provider,
+		expiresAt: time.Now().Add(getDiscoveryCacheTTL()),
+	}
+	c.mu.Unlock()
+
+	return provider, nil
+}
+
+func (c *discoveryCache) invalidate(domain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, domain)
+}
+
+// InvalidateDiscoveryCache removes the cached discovery document for domain.
+// It should be called when the configuration of a provider changes.
+func InvalidateDiscoveryCache(domain string) {
+	providerDiscoveryCache.invalidate(domain)
+}
+
+// DiscoveryResult describes the endpoints found in the OpenID discovery
+// document of an identity provider.
+type DiscoveryResult struct {
+	Issuer      string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+}
+
+// Values of DiscoveryError.Kind.
+const (
+	DiscoveryErrorDNS        = "dns"
+	DiscoveryErrorTLS        = "tls"
+	DiscoveryErrorConnection = "connection"
+	DiscoveryErrorTimeout    = "timeout"
+	DiscoveryErrorNotOIDC    = "not_oidc"
+)
+
+// DiscoveryError is returned when the OpenID discovery document of an identity
+// provider could not be retrieved. Kind describes the cause of the failure.
+type DiscoveryError struct {
+	Kind string
+	Err  error
+}
+
+func (e DiscoveryError) Error() string {
+	return fmt.Sprintf("discovery failed (%v): %v", e.Kind, e.Err)
+}
+
+func (e DiscoveryError) Unwrap() error {
+	return e.Err
+}
+
+func newDiscoveryError(err error) DiscoveryError {
+	var (
+		dnsErr          *net.DNSError
+		unknownAuthErr  x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		certInvalidErr  x509.CertificateInvalidError
+		recordHeaderErr tls.RecordHeaderError
+		netErr          net.Error
+		opErr           *net.OpError
+	)
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return DiscoveryError{Kind: DiscoveryErrorDNS, Err: err}
+	case errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr),
+		errors.As(err, &certInvalidErr), errors.As(err, &recordHeaderErr):
+		return DiscoveryError{Kind: DiscoveryErrorTLS, Err: err}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return DiscoveryError{Kind: DiscoveryErrorTimeout, Err: err}
+	case errors.As(err, &opErr):
+		return DiscoveryError{Kind: DiscoveryErrorConnection, Err: err}
+	default:
+		// the server responded, but not with a valid discovery document
+		return DiscoveryError{Kind: DiscoveryErrorNotOIDC, Err: err}
+	}
+}
@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/server/models"
+)
+
+func TestDiscoveryCache(t *testing.T) {
+	_, ctx := setupOIDCTest(t, "")
+
+	var requests int32
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, err := fmt.Fprintf(w, `{"issuer": "%[1]s", "authorization_endpoint": "%[1]s/auth"}`, server.URL)
+		assert.Check(t, err)
+	})
+
+	domain := strings.TrimPrefix(server.URL, "https://")
+	cache := &discoveryCache{entries: map[string]discoveryCacheEntry{}}
+
+	first, err := cache.get(ctx, domain)
+	assert.NilError(t, err)
+	assert.Equal(t, first.Endpoint().AuthURL, server.URL+"/auth")
+
+	t.Run("cached", func(t *testing.T) {
+		provider, err := cache.get(ctx, domain)
+		assert.NilError(t, err)
+		assert.Equal(t, provider, first)
+		assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+	})
+
+	t.Run("invalidated", func(t *testing.T) {
+		cache.invalidate(domain)
+
+		provider, err := cache.get(ctx, domain)
+		assert.NilError(t, err)
+		assert.Assert(t, provider != first)
+		assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cache.mu.Lock()
+		entry := cache.entries[domain]
+		entry.expiresAt = time.Now().Add(-time.Second)
+		cache.entries[domain] = entry
+		cache.mu.Unlock()
+
+		_, err := cache.get(ctx, domain)
+		assert.NilError(t, err)
+		assert.Equal(t, atomic.LoadInt32(&requests), int32(3))
+	})
+
+	t.Run("configured ttl", func(t *testing.T) {
+		SetDiscoveryCacheTTL(time.Hour)
+		t.Cleanup(func() {
+			SetDiscoveryCacheTTL(0)
+		})
+		cache.invalidate(domain)
+
+		_, err := cache.get(ctx, domain)
+		assert.NilError(t, err)
+
+		cache.mu.Lock()
+		expiresAt := cache.entries[domain].expiresAt
+		cache.mu.Unlock()
+		assert.Assert(t, time.Until(expiresAt) > 50*time.Minute, expiresAt)
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	t.Run("success", func(t *testing.T) {
+		client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL}, "secret", "http://localhost:8301", nil)
+
+		result, err := client.Discover(ctx)
+		assert.NilError(t, err)
+
+		base := "https://" + serverURL
+		expected := &DiscoveryResult{
+			Issuer:      base,
+			AuthURL:     base + "/auth",
+			TokenURL:    base + "/token",
+			UserInfoURL: base + "/userinfo",
+			JWKSURL:     base + "/keys",
+		}
+		assert.DeepEqual(t, result, expected)
+	})
+
+	notOIDC := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(notOIDC.Close)
+
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closed.Close()
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		url      string
+		expected string
+	}{
+		{
+			name:     "not an OIDC server",
+			ctx:      ctx,
+			url:      strings.TrimPrefix(notOIDC.URL, "https://"),
+			expected: DiscoveryErrorNotOIDC,
+		},
+		{
+			name:     "untrusted certificate",
+			ctx:      context.Background(),
+			url:      serverURL,
+			expected: DiscoveryErrorTLS,
+		},
+		{
+			name:     "unknown host",
+			ctx:      ctx,
+			url:      "nonexistent.invalid",
+			expected: DiscoveryErrorDNS,
+		},
+		{
+			name:     "connection refused",
+			ctx:      ctx,
+			url:      strings.TrimPrefix(closed.URL, "https://"),
+			expected: DiscoveryErrorConnection,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: tc.url}, "secret", "http://localhost:8301", nil)
+
+			_, err := client.Discover(tc.ctx)
+			var discoveryErr DiscoveryError
+			assert.Assert(t, errors.As(err, &discoveryErr), "expected a discovery error, got %v", err)
+			assert.Equal(t, discoveryErr.Kind, tc.expected, err)
+		})
+	}
+}
@@ -41,11 +41,27 @@ func (g *google) AuthServerInfo(ctx context.Context) (*AuthServerInfo, error) {
 	return g.OIDCClient.AuthServerInfo(ctx)
 }
 
-func (g *google) ExchangeAuthCodeForProviderTokens(ctx context.Context, code string) (rawAccessToken, rawRefreshToken string, accessTokenExpiry time.Time, email string, err error) {
-	return g.OIDCClient.ExchangeAuthCodeForProviderTokens(ctx, code)
+func (g *google) Discover(ctx context.Context) (*DiscoveryResult, error) {
+	return g.OIDCClient.Discover(ctx)
 }
 
-func (g *google) RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error) {
+func (g *google) ExchangeAuthCodeForProviderTokens(ctx context.Context, code, redirectURL string) (rawAccessToken, rawRefreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	return g.OIDCClient.ExchangeAuthCodeForProviderTokens(ctx, code, redirectURL)
+}
+
+func (g *google) VerifyIDToken(ctx context.Context, rawIDToken string) (email string, groups []string, err error) {
+	return g.OIDCClient.VerifyIDToken(ctx, rawIDToken)
+}
+
+func (g *google) StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	return g.OIDCClient.StartDeviceAuthorization(ctx)
+}
+
+func (g *google) PollDeviceAuthorization(ctx context.Context, auth *DeviceAuthorization) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	return g.OIDCClient.PollDeviceAuthorization(ctx, auth)
+}
+
+func (g *google) RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error) {
 	return g.OIDCClient.RefreshAccessToken(ctx, providerUser)
 }
 
@@ -2,22 +2,25 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/mail"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/infrahq/secrets"
 	"golang.org/x/oauth2"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/uid"
 )
 
-const oidcProviderRequestTimeout = time.Second * 10
-
 // UserInfoClaims captures the claims fields from a user-info response that we care about
 type UserInfoClaims struct {
 	Email  string   `json:"email"` // returned by default for Okta user info
@@ -33,9 +36,13 @@ type AuthServerInfo struct {
 type OIDCClient interface {
 	Validate(context.Context) error
 	AuthServerInfo(context.Context) (*AuthServerInfo, error)
-	ExchangeAuthCodeForProviderTokens(ctx context.Context, code string) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, err error)
-	RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error)
+	ExchangeAuthCodeForProviderTokens(ctx context.Context, code, redirectURL string) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error)
+	VerifyIDToken(ctx context.Context, rawIDToken string) (email string, groups []string, err error)
+	StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error)
+	PollDeviceAuthorization(ctx context.Context, auth *DeviceAuthorization) (accessToken, refreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error)
+	RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error)
 	GetUserInfo(ctx context.Context, providerUser *models.ProviderUser) (*UserInfoClaims, error)
+	Discover(ctx context.Context) (*DiscoveryResult, error)
 }
 
 type key struct{}
@@ -53,21 +60,84 @@ func WithOIDCClient(ctx context.Context, client OIDCClient) context.Context {
 	return context.WithValue(ctx, ctxKey, client)
 }
 
+// defaultGroupsClaimName is the claim used for groups when a provider does
+// not set one.
+const defaultGroupsClaimName = "groups"
+
+// defaultEmailClaimName is the ID token claim used for the email of a user
+// when a provider does not set one.
+const defaultEmailClaimName = "email"
+
+// DefaultRedirectURL is the redirect URL used by the CLI to login. It is the
+// only redirect URL allowed when a provider does not set any.
+const DefaultRedirectURL = "http://localhost:8301"
+
+// DefaultScopes are the scopes requested from a provider that does not set any.
+var DefaultScopes = []string{oidc.ScopeOpenID, "email", "groups", oidc.ScopeOfflineAccess}
+
+// ScopesWithOpenID returns scopes with the openid scope added to the start,
+// when it is not already included. The openid scope is required for login.
+func ScopesWithOpenID(scopes []string) []string {
+	for _, scope := range scopes {
+		if scope == oidc.ScopeOpenID {
+			return scopes
+		}
+	}
+	return append([]string{oidc.ScopeOpenID}, scopes...)
+}
+
+// ErrRedirectURLNotAllowed is returned when the redirect URL used to login is
+// not one of the redirect URLs allowed by the provider.
+var ErrRedirectURLNotAllowed = errors.New("redirect URL is not allowed by the provider")
+
 type oidcClientImplementation struct {
-	ProviderID   uid.ID
-	Domain       string
-	ClientID     string
-	ClientSecret string
-	RedirectURL  string
+	ProviderID      uid.ID
+	Domain          string
+	ClientID        string
+	ClientSecret    string
+	RedirectURL     string
+	RedirectURLs    []string
+	Scopes          []string
+	GroupsClaimName string
+	EmailClaimName  string
+	// SecretStorage resolves ClientSecret every time the client is configured,
+	// so that a rotated secret is used without restarting the server. When
+	// it is nil, ClientSecret is used as is.
+	//
+	// The name in a reference is resolved by the storage of that kind. For
+	// example, the name in file:/run/secrets/okta is relative to the Path of
+	// the file storage, so it is an absolute path only when the Path is empty,
+	// as it is for the default file storage.
+	SecretStorage map[string]secrets.SecretStorage
 }
 
-func NewOIDCClient(provider models.Provider, clientSecret, redirectURL string) OIDCClient {
+func NewOIDCClient(provider models.Provider, clientSecret, redirectURL string, secretStorage map[string]secrets.SecretStorage) OIDCClient {
+	groupsClaimName := provider.GroupsClaimName
+	if groupsClaimName == "" {
+		groupsClaimName = defaultGroupsClaimName
+	}
+
+	emailClaimName := provider.EmailClaimName
+	if emailClaimName == "" {
+		emailClaimName = defaultEmailClaimName
+	}
+
+	scopes := DefaultScopes
+	if len(provider.RequestedScopes) > 0 {
+		scopes = ScopesWithOpenID(provider.RequestedScopes)
+	}
+
 	oidcClient := &oidcClientImplementation{
-		ProviderID:   provider.ID,
-		Domain:       provider.URL,
-		ClientID:     provider.ClientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURL,
+		ProviderID:      provider.ID,
+		Domain:          provider.URL,
+		ClientID:        provider.ClientID,
+		ClientSecret:    clientSecret,
+		RedirectURL:     redirectURL,
+		RedirectURLs:    provider.RedirectURLs,
+		Scopes:          scopes,
+		GroupsClaimName: groupsClaimName,
+		EmailClaimName:  emailClaimName,
+		SecretStorage:   secretStorage,
 	}
 
 	// nolint:exhaustive
@@ -90,6 +160,9 @@ func NewOIDCClient(provider models.Provider, clientSecret, redirectURL string) O
 
 // Validate tests if an identity provider has valid attributes to support user login
 func (o *oidcClientImplementation) Validate(ctx context.Context) error {
+	ctx, cancel := withRequestContext(ctx)
+	defer cancel()
+
 	conf, _, err := o.clientConfig(ctx)
 	if err != nil {
 		logging.Debugf("error validating oidc provider: %s", err)
@@ -118,16 +191,36 @@ func (o *oidcClientImplementation) Validate(ctx context.Context) error {
 	return nil
 }
 
+// isInvalidGrant returns true if err is an OAuth2 invalid_grant error response,
+// which means the refresh token is expired or was revoked.
+func isInvalidGrant(err error) bool {
+	var errRetrieve *oauth2.RetrieveError
+	if !errors.As(err, &errRetrieve) {
+		return false
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if jsonErr := json.Unmarshal(errRetrieve.Body, &body); jsonErr == nil {
+		return body.Error == "invalid_grant"
+	}
+
+	// some providers respond with a form encoded body
+	values, parseErr := url.ParseQuery(string(errRetrieve.Body))
+	return parseErr == nil && values.Get("error") == "invalid_grant"
+}
+
 func newValidationError(field string) error {
 	return validate.Error{field: {"invalid provider " + field}}
 }
 
 // AuthServerInfo returns details about the oidc server auth URL, and the scopes it supports
 func (o *oidcClientImplementation) AuthServerInfo(ctx context.Context) (*AuthServerInfo, error) {
-	ctx, cancel := context.WithTimeout(ctx, oidcProviderRequestTimeout)
+	ctx, cancel := withRequestContext(ctx)
 	defer cancel()
 	// find out what the authorization endpoint is
-	provider, err := oidc.NewProvider(ctx, fmt.Sprintf("https://%s", o.Domain))
+	provider, err := providerDiscoveryCache.get(ctx, o.Domain)
 	if err != nil {
 		return nil, fmt.Errorf("get provider oidc info: %w", err)
 	}
@@ -161,24 +254,79 @@ func (o *oidcClientImplementation) AuthServerInfo(ctx context.Context) (*AuthSer
 	}, nil
 }
 
+// Discover requests the OpenID discovery document from the identity provider,
+// and returns the endpoints it describes. The discovery document is always
+// requested, even when it is cached. If the request fails the error is a
+// DiscoveryError.
+func (o *oidcClientImplementation) Discover(ctx context.Context) (*DiscoveryResult, error) {
+	ctx, cancel := withRequestContext(ctx)
+	defer cancel()
+
+	providerDiscoveryCache.invalidate(o.Domain)
+	conf, provider, err := o.clientConfig(ctx)
+	if err != nil {
+		return nil, newDiscoveryError(err)
+	}
+
+	var claims struct {
+		Issuer      string `json:"issuer"`
+		UserInfoURL string `json:"userinfo_endpoint"`
+		JWKSURL     string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&claims); err != nil {
+		return nil, DiscoveryError{Kind: DiscoveryErrorNotOIDC, Err: err}
+	}
+
+	return &DiscoveryResult{
+		Issuer:      claims.Issuer,
+		AuthURL:     conf.Endpoint.AuthURL,
+		TokenURL:    conf.Endpoint.TokenURL,
+		UserInfoURL: claims.UserInfoURL,
+		JWKSURL:     claims.JWKSURL,
+	}, nil
+}
+
 // clientConfig returns the OAuth client configuration needed to interact with an identity provider
 func (o *oidcClientImplementation) clientConfig(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
-	provider, err := oidc.NewProvider(ctx, fmt.Sprintf("https://%s", o.Domain))
+	clientSecret := o.ClientSecret
+	if o.SecretStorage != nil {
+		var err error
+		clientSecret, err = secrets.GetSecret(clientSecret, o.SecretStorage)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client secret %v: %w", secretReference(o.ClientSecret, o.SecretStorage), err)
+		}
+	}
+
+	provider, err := providerDiscoveryCache.get(ctx, o.Domain)
 	if err != nil {
 		return nil, nil, fmt.Errorf("get provider openid info: %w", err)
 	}
 
 	conf := &oauth2.Config{
 		ClientID:     o.ClientID,
-		ClientSecret: o.ClientSecret,
+		ClientSecret: clientSecret,
 		RedirectURL:  o.RedirectURL,
-		Scopes:       []string{oidc.ScopeOpenID, "email", "groups", oidc.ScopeOfflineAccess},
+		Scopes:       o.Scopes,
 		Endpoint:     provider.Endpoint(),
 	}
 
 	return conf, provider, nil
 }
 
+// secretReference returns a description of the secret reference ref for an
+// error message. The reference is only included when its kind is one of the
+// storage, so that a plaintext secret which contains a colon is not included.
+func secretReference(ref string, storage map[string]secrets.SecretStorage) string {
+	kind, _, ok := strings.Cut(ref, ":")
+	if !ok {
+		return "(plaintext)"
+	}
+	if _, found := storage[kind]; !found {
+		return "(unknown secret storage)"
+	}
+	return fmt.Sprintf("%q", ref)
+}
+
 // tokenSource is used to call an identity provider with the specified provider tokens
 func (o *oidcClientImplementation) tokenSource(ctx context.Context, conf *oauth2.Config, providerTokens *models.ProviderUser) (oauth2.TokenSource, error) {
 	userToken := &oauth2.Token{
@@ -190,24 +338,33 @@ func (o *oidcClientImplementation) tokenSource(ctx context.Context, conf *oauth2
 	return conf.TokenSource(ctx, userToken), nil
 }
 
-// ExchangeAuthCodeForProviderTokens exchanges the authorization code a user received on login for valid identity provider tokens
-func (o *oidcClientImplementation) ExchangeAuthCodeForProviderTokens(ctx context.Context, code string) (rawAccessToken, rawRefreshToken string, accessTokenExpiry time.Time, email string, err error) {
-	ctx, cancel := context.WithTimeout(ctx, oidcProviderRequestTimeout)
+// ExchangeAuthCodeForProviderTokens exchanges the authorization code a user received on login for valid identity provider tokens.
+// redirectURL must be the redirect URL the client used to request the code, and
+// must be one of the redirect URLs allowed by the provider.
+// groups is nil when the ID token does not include a groups claim, in which case
+// the groups must be read from the user info endpoint.
+func (o *oidcClientImplementation) ExchangeAuthCodeForProviderTokens(ctx context.Context, code, redirectURL string) (rawAccessToken, rawRefreshToken string, accessTokenExpiry time.Time, email string, groups []string, err error) {
+	if !o.redirectURLAllowed(redirectURL) {
+		return "", "", time.Time{}, "", nil, fmt.Errorf("%w: %v", ErrRedirectURLNotAllowed, redirectURL)
+	}
+
+	ctx, cancel := withRequestContext(ctx)
 	defer cancel()
 
 	conf, provider, err := o.clientConfig(ctx)
 	if err != nil {
-		return "", "", time.Time{}, "", fmt.Errorf("client exchange code: %w", err)
+		return "", "", time.Time{}, "", nil, fmt.Errorf("client exchange code: %w", err)
 	}
+	conf.RedirectURL = redirectURL
 
 	exchanged, err := conf.Exchange(ctx, code)
 	if err != nil {
-		return "", "", time.Time{}, "", fmt.Errorf("code exchange: %w", err)
+		return "", "", time.Time{}, "", nil, fmt.Errorf("code exchange: %w", err)
 	}
 
 	rawAccessToken, ok := exchanged.Extra("access_token").(string)
 	if !ok {
-		return "", "", time.Time{}, "", errors.New("could not extract access token from oauth2")
+		return "", "", time.Time{}, "", nil, errors.New("could not extract access token from oauth2")
 	}
 
 	rawRefreshToken, ok = exchanged.Extra("refresh_token").(string)
@@ -218,65 +375,112 @@ func (o *oidcClientImplementation) ExchangeAuthCodeForProviderTokens(ctx context
 
 	rawIDToken, ok := exchanged.Extra("id_token").(string)
 	if !ok {
-		return "", "", time.Time{}, "", errors.New("could not extract id_token from oauth2 token")
+		return "", "", time.Time{}, "", nil, errors.New("could not extract id_token from oauth2 token")
 	}
 
+	email, groups, err = o.verifyIDToken(ctx, provider, rawIDToken)
+	if err != nil {
+		return "", "", time.Time{}, "", nil, err
+	}
+
+	return rawAccessToken, rawRefreshToken, exchanged.Expiry, email, groups, nil
+}
+
+// VerifyIDToken verifies an ID token that was issued by the provider to a
+// client without the auth code exchange, for example a CI system that
+// receives an ID token from its own OIDC provider. The token must be signed by
+// the provider, be unexpired, and have the client ID of the provider as its
+// audience.
+// groups is nil when the ID token does not include a groups claim.
+func (o *oidcClientImplementation) VerifyIDToken(ctx context.Context, rawIDToken string) (email string, groups []string, err error) {
+	ctx, cancel := withRequestContext(ctx)
+	defer cancel()
+
+	_, provider, err := o.clientConfig(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("client verify id token: %w", err)
+	}
+
+	return o.verifyIDToken(ctx, provider, rawIDToken)
+}
+
+func (o *oidcClientImplementation) verifyIDToken(ctx context.Context, provider *oidc.Provider, rawIDToken string) (email string, groups []string, err error) {
 	// we get sensitive claims from the ID token, must validate them
 	verifier := provider.Verifier(&oidc.Config{ClientID: o.ClientID})
 
 	idToken, err := verifier.Verify(ctx, rawIDToken)
 	if err != nil {
-		return "", "", time.Time{}, "", fmt.Errorf("validate id token: %w", err)
+		return "", nil, fmt.Errorf("validate id token: %w", err)
 	}
 
-	var claims struct {
-		Email string `json:"email"`
+	email, err = emailFromClaim(idToken, o.EmailClaimName)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if err := idToken.Claims(&claims); err != nil {
-		return "", "", time.Time{}, "", fmt.Errorf("id token claims: %w", err)
+	// some providers only include groups in the ID token, use them when they
+	// are present to avoid a request to the user info endpoint
+	groups, err = groupsFromClaim(idToken, o.GroupsClaimName)
+	if err != nil {
+		return "", nil, fmt.Errorf("id token claims: %w", err)
 	}
+	return email, groups, nil
+}
 
-	if claims.Email == "" {
-		err := fmt.Errorf("ID token claim is missing an email address")
-		return "", "", time.Time{}, "", err
+// redirectURLAllowed returns true if redirectURL is one of the redirect URLs
+// allowed by the provider. Only DefaultRedirectURL is allowed when the provider
+// does not set any.
+func (o *oidcClientImplementation) redirectURLAllowed(redirectURL string) bool {
+	if len(o.RedirectURLs) == 0 {
+		return redirectURL == DefaultRedirectURL
 	}
-
-	if strings.ContainsAny(claims.Email, ` '`) {
-		err := fmt.Errorf("ID token claim has invalid email address")
-		return "", "", time.Time{}, "", err
+	for _, allowed := range o.RedirectURLs {
+		if redirectURL == allowed {
+			return true
+		}
 	}
-
-	return rawAccessToken, rawRefreshToken, exchanged.Expiry, claims.Email, nil
+	return false
 }
 
-// RefreshAccessToken uses the refresh token to get a new access token if it is expired
-func (o *oidcClientImplementation) RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error) {
-	ctx, cancel := context.WithTimeout(ctx, oidcProviderRequestTimeout)
+// RefreshAccessToken uses the refresh token to get a new access token if it is expired.
+// The returned refresh token is different from the one in providerUser when
+// the identity provider rotates refresh tokens.
+func (o *oidcClientImplementation) RefreshAccessToken(ctx context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error) {
+	ctx, cancel := withRequestContext(ctx)
 	defer cancel()
 
 	conf, _, err := o.clientConfig(ctx)
 	if err != nil {
-		return "", nil, fmt.Errorf("call idp with tokens: %w", err)
+		return "", "", nil, fmt.Errorf("call idp with tokens: %w", err)
 	}
 
 	tokenSource, err := o.tokenSource(ctx, conf, providerUser)
 	if err != nil {
-		return "", nil, fmt.Errorf("ref token source: %w", err)
+		return "", "", nil, fmt.Errorf("ref token source: %w", err)
 	}
 
 	newToken, err := tokenSource.Token() // this refreshes token if needed
 	if err != nil {
-		return "", nil, fmt.Errorf("refresh user token: %w", err)
+		if isInvalidGrant(err) {
+			// the session was revoked at the identity provider, retrying will not help
+			return "", "", nil, fmt.Errorf("%w: refresh token was revoked: %s", internal.ErrForbidden, err)
+		}
+		return "", "", nil, fmt.Errorf("refresh user token: %w", err)
+	}
+
+	refreshToken = newToken.RefreshToken
+	if refreshToken == "" {
+		// the identity provider did not issue a new refresh token
+		refreshToken = string(providerUser.RefreshToken)
 	}
 
-	return newToken.AccessToken, &newToken.Expiry, nil
+	return newToken.AccessToken, refreshToken, &newToken.Expiry, nil
 }
 
 // GetUserInfo uses a provider token to call the OpenID Connect UserInfo endpoint,
 // make sure an access token is valid (not expired) before using this
 func (o *oidcClientImplementation) GetUserInfo(ctx context.Context, providerUser *models.ProviderUser) (*UserInfoClaims, error) {
-	ctx, cancel := context.WithTimeout(ctx, oidcProviderRequestTimeout)
+	ctx, cancel := withRequestContext(ctx)
 	defer cancel()
 
 	conf, provider, err := o.clientConfig(ctx)
@@ -289,6 +493,11 @@ func (o *oidcClientImplementation) GetUserInfo(ctx context.Context, providerUser
 		return nil, fmt.Errorf("info token source: %w", err)
 	}
 
+	provider, err = userInfoProvider(ctx, provider, o.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("user info endpoint: %w", err)
+	}
+
 	info, err := provider.UserInfo(ctx, tokenSource)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -302,9 +511,100 @@ func (o *oidcClientImplementation) GetUserInfo(ctx context.Context, providerUser
 		return nil, fmt.Errorf("user info claims: %w", err)
 	}
 
+	if o.GroupsClaimName != defaultGroupsClaimName {
+		groups, err := groupsFromClaim(info, o.GroupsClaimName)
+		if err != nil {
+			return nil, fmt.Errorf("user info claims: %w", err)
+		}
+		claims.Groups = groups
+	}
+
 	if claims.Name == "" && claims.Email == "" {
 		return nil, fmt.Errorf("claim must include either a name or email")
 	}
 
 	return claims, nil
 }
+
+// oktaUserInfoPath is the path of the UserInfo endpoint used by Okta. It is
+// only used when a provider does not advertise a userinfo_endpoint.
+const oktaUserInfoPath = "/oauth2/v1/userinfo"
+
+// userInfoProvider returns a provider which can call the UserInfo endpoint.
+// The endpoint advertised in the discovery document is used when it is present,
+// otherwise the endpoint falls back to the Okta path on the provider domain.
+func userInfoProvider(ctx context.Context, provider *oidc.Provider, domain string) (*oidc.Provider, error) {
+	var claims struct {
+		UserInfoURL string `json:"userinfo_endpoint"`
+	}
+
+	if err := provider.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("could not parse provider claims: %w", err)
+	}
+
+	if claims.UserInfoURL != "" {
+		return provider, nil
+	}
+
+	config := &oidc.ProviderConfig{
+		UserInfoURL: fmt.Sprintf("https://%s%s", domain, oktaUserInfoPath),
+	}
+	return config.NewProvider(ctx), nil
+}
+
+// claimer is implemented by oidc.UserInfo and oidc.IDToken.
+type claimer interface {
+	Claims(v interface{}) error
+}
+
+// groupsFromClaim returns the groups from the claim with name. The groups are
+// nil when the claim does not exist.
+func groupsFromClaim(info claimer, name string) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := info.Claims(&raw); err != nil {
+		return nil, err
+	}
+
+	value, ok := raw[name]
+	if !ok {
+		return nil, nil
+	}
+
+	var groups []string
+	if err := json.Unmarshal(value, &groups); err != nil {
+		return nil, fmt.Errorf("%s claim: %w", name, err)
+	}
+	return groups, nil
+}
+
+// emailFromClaim returns the email address of the user from the claim with
+// name. Claims other than the email claim, like upn or preferred_username, are
+// not always an email address, so their value must parse as one.
+func emailFromClaim(info claimer, name string) (string, error) {
+	var raw map[string]json.RawMessage
+	if err := info.Claims(&raw); err != nil {
+		return "", fmt.Errorf("id token claims: %w", err)
+	}
+
+	var email string
+	if value, ok := raw[name]; ok {
+		if err := json.Unmarshal(value, &email); err != nil {
+			return "", fmt.Errorf("id token claims: %s claim: %w", name, err)
+		}
+	}
+
+	if email == "" {
+		return "", fmt.Errorf("ID token claim is missing an email address")
+	}
+
+	if strings.ContainsAny(email, ` '`) {
+		return "", fmt.Errorf("ID token claim has invalid email address")
+	}
+
+	if name != defaultEmailClaimName {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return "", fmt.Errorf("ID token %s claim has invalid email address", name)
+		}
+	}
+	return email, nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -12,15 +13,19 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/infrahq/secrets"
 	"golang.org/x/oauth2"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 	"gotest.tools/v3/assert"
 
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/server/models"
 	"github.com/infrahq/infra/internal/validate"
 )
@@ -35,6 +40,14 @@ type testOIDCServer struct {
 	userInfoResponse string
 	tokenResponse    tokenResponse
 	signingKey       *rsa.PrivateKey
+	// userInfoPath is the path of the userinfo_endpoint advertised by the
+	// discovery document. Defaults to /userinfo. When omitUserInfoEndpoint
+	// is true the endpoint is not advertised.
+	userInfoPath         string
+	omitUserInfoEndpoint bool
+	// tokenResponses are sent in order by the token endpoint, before
+	// tokenResponse is used.
+	tokenResponses []tokenResponse
 }
 
 const (
@@ -76,14 +89,26 @@ func (ts *testOIDCServer) run(t *testing.T, addHandlers func(*testing.T, *http.S
 		]
 	}`
 
+	userInfoPath := ts.userInfoPath
+	if userInfoPath == "" {
+		userInfoPath = "/userinfo"
+	}
+
+	userInfoEndpoint := fmt.Sprintf(`"userinfo_endpoint": "%s%s",`, server.URL, userInfoPath)
+	if ts.omitUserInfoEndpoint {
+		userInfoEndpoint = ""
+		userInfoPath = oktaUserInfoPath
+	}
+
 	wellKnown := fmt.Sprintf(`{
 		"issuer": "%[1]s",
 		"authorization_endpoint": "%[1]s/auth",
 		"token_endpoint": "%[1]s/token",
 		"jwks_uri": "%[1]s/keys",
-		"userinfo_endpoint": "%[1]s/userinfo",
+		"device_authorization_endpoint": "%[1]s/device",
+		%[2]s
 		"id_token_signing_alg_values_supported": ["RS256"]
-	}`, server.URL)
+	}`, server.URL, userInfoEndpoint)
 
 	// general OIDC endpoints
 	newMux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
@@ -96,12 +121,16 @@ func (ts *testOIDCServer) run(t *testing.T, addHandlers func(*testing.T, *http.S
 		assert.Check(t, err, "failed to write keys response")
 	})
 	newMux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		resp := ts.tokenResponse
+		if len(ts.tokenResponses) > 0 {
+			resp, ts.tokenResponses = ts.tokenResponses[0], ts.tokenResponses[1:]
+		}
 		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(ts.tokenResponse.code)
-		_, err := io.WriteString(w, ts.tokenResponse.body)
+		w.WriteHeader(resp.code)
+		_, err := io.WriteString(w, resp.body)
 		assert.Check(t, err, "failed to write token response")
 	})
-	newMux.HandleFunc("/userinfo", func(w http.ResponseWriter, req *http.Request) {
+	newMux.HandleFunc(userInfoPath, func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
 		_, err := io.WriteString(w, ts.userInfoResponse)
 		assert.Check(t, err, "failed to write user info response")
@@ -128,7 +157,9 @@ func loadTestSecretKey(t *testing.T) *rsa.PrivateKey {
 	return rsaSecKey
 }
 
-func testTokenResponse(claims jwt.Claims, signingKey *rsa.PrivateKey, email string) (string, error) {
+// testTokenResponse returns a token response with an ID token signed by
+// signingKey. Any extra claims are added to the ID token.
+func testTokenResponse(claims jwt.Claims, signingKey *rsa.PrivateKey, email string, extra ...interface{}) (string, error) {
 	options := &jose.SignerOptions{}
 
 	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm("RS256"), Key: signingKey}, options.WithType("JWT"))
@@ -136,21 +167,21 @@ func testTokenResponse(claims jwt.Claims, signingKey *rsa.PrivateKey, email stri
 		return "", err
 	}
 
-	raw := ""
+	builder := jwt.Signed(signer).Claims(claims)
 	if email != "" {
 		type Custom struct {
 			Email string `json:"email"`
 		}
 
-		raw, err = jwt.Signed(signer).Claims(claims).Claims(Custom{Email: email}).CompactSerialize()
-		if err != nil {
-			return "", err
-		}
-	} else {
-		raw, err = jwt.Signed(signer).Claims(claims).CompactSerialize()
-		if err != nil {
-			return "", err
-		}
+		builder = builder.Claims(Custom{Email: email})
+	}
+	for _, c := range extra {
+		builder = builder.Claims(c)
+	}
+
+	raw, err := builder.CompactSerialize()
+	if err != nil {
+		return "", err
 	}
 
 	return fmt.Sprintf(`{
@@ -198,7 +229,7 @@ func TestValidate(t *testing.T) {
 	}{
 		{
 			name:     "invalid URL",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: "example.com"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: "example.com"}, "some_client_secret", "http://localhost:8301", nil),
 			verifyFunc: func(t *testing.T, err error) {
 				var vErr validate.Error
 				assert.Assert(t, errors.As(err, &vErr), "expected validation error")
@@ -208,7 +239,7 @@ func TestValidate(t *testing.T) {
 		},
 		{
 			name:     "invalid client ID",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid-client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid-client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: tokenResponse{
 				code: 500,
 				body: oktaInvalidClientIDResp,
@@ -222,7 +253,7 @@ func TestValidate(t *testing.T) {
 		},
 		{
 			name:     "invalid client secret",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: tokenResponse{
 				code: 500,
 				body: oktaInvalidClientSecretResp,
@@ -237,7 +268,7 @@ func TestValidate(t *testing.T) {
 
 		{
 			name:     "valid provider client",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: tokenResponse{
 				code: 500,
 				body: oktaInvalidAuthCodeResp,
@@ -269,7 +300,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 	}{
 		{
 			name:     "invalid provider client fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid"}, "invalid", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid"}, "invalid", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				return tokenResponse{
 					code: 500,
@@ -286,7 +317,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "invalid auth code fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				return tokenResponse{
 					code: 500,
@@ -303,7 +334,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "empty access token response fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				return tokenResponse{
 					code: 200,
@@ -320,7 +351,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "id token issued by a different provider fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				claims := jwt.Claims{
 					Issuer: "unknown-issuer",
@@ -345,7 +376,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "id token issued for wrong audience fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				claims := jwt.Claims{
 					Issuer:   "https://" + serverURL,
@@ -371,7 +402,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "expired id token fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				now := time.Now().UTC()
 
@@ -402,7 +433,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "id token without email claim fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				now := time.Now().UTC()
 
@@ -433,7 +464,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "empty email claim fails",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				now := time.Now().UTC()
 
@@ -464,7 +495,7 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 		},
 		{
 			name:     "valid id token is successful",
-			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301"),
+			provider: NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "http://localhost:8301", nil),
 			tokenResponse: func(t *testing.T) tokenResponse {
 				now := time.Now().UTC()
 
@@ -498,16 +529,405 @@ func TestExchangeAuthCodeForProviderToken(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			server.tokenResponse = test.tokenResponse(t)
-			accToken, refToken, accTokenExp, email, err := test.provider.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code")
+			accToken, refToken, accTokenExp, email, _, err := test.provider.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", "http://localhost:8301")
 			test.verifyFunc(t, accToken, refToken, accTokenExp, email, err)
 		})
 	}
 }
 
+func TestExchangeAuthCodeForProviderToken_RedirectURL(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	now := time.Now().UTC()
+	claims := jwt.Claims{
+		Audience:  jwt.Audience([]string{"client-id"}),
+		NotBefore: jwt.NewNumericDate(now.Add(-5 * time.Minute)), // adjust for clock drift
+		Expiry:    jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    "https://" + serverURL,
+	}
+	body, err := testTokenResponse(claims, server.signingKey, "hello@example.com")
+	assert.NilError(t, err)
+	server.tokenResponse = tokenResponse{code: 200, body: body}
+
+	withRedirectURLs := models.Provider{
+		Kind:         models.ProviderKindOIDC,
+		URL:          serverURL,
+		ClientID:     "client-id",
+		RedirectURLs: []string{"https://infra.example.com/login/callback"},
+	}
+	withDefault := models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}
+
+	t.Run("allowed redirect URL", func(t *testing.T) {
+		client := NewOIDCClient(withRedirectURLs, "some_client_secret", "https://infra.example.com/login/callback", nil)
+		_, _, _, email, _, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", "https://infra.example.com/login/callback")
+		assert.NilError(t, err)
+		assert.Equal(t, email, "hello@example.com")
+	})
+
+	t.Run("disallowed redirect URL", func(t *testing.T) {
+		client := NewOIDCClient(withRedirectURLs, "some_client_secret", "http://localhost:8301", nil)
+		_, _, _, _, _, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", "http://localhost:8301")
+		assert.ErrorIs(t, err, ErrRedirectURLNotAllowed)
+	})
+
+	t.Run("default allows localhost", func(t *testing.T) {
+		client := NewOIDCClient(withDefault, "some_client_secret", DefaultRedirectURL, nil)
+		_, _, _, email, _, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", DefaultRedirectURL)
+		assert.NilError(t, err)
+		assert.Equal(t, email, "hello@example.com")
+	})
+
+	t.Run("default rejects other redirect URLs", func(t *testing.T) {
+		client := NewOIDCClient(withDefault, "some_client_secret", "https://evil.example.com/callback", nil)
+		_, _, _, _, _, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", "https://evil.example.com/callback")
+		assert.ErrorIs(t, err, ErrRedirectURLNotAllowed)
+	})
+}
+
+func TestClientConfig_Scopes(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	clientScopes := func(t *testing.T, scopes []string) []string {
+		t.Helper()
+		provider := models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id", RequestedScopes: scopes}
+		client, ok := NewOIDCClient(provider, "some_client_secret", DefaultRedirectURL, nil).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		conf, _, err := client.clientConfig(ctx)
+		assert.NilError(t, err)
+		return conf.Scopes
+	}
+
+	t.Run("default scopes", func(t *testing.T) {
+		assert.DeepEqual(t, clientScopes(t, nil), []string{"openid", "email", "groups", "offline_access"})
+	})
+
+	t.Run("supported scopes are not requested", func(t *testing.T) {
+		provider := models.Provider{
+			Kind:     models.ProviderKindOIDC,
+			URL:      serverURL,
+			ClientID: "client-id",
+			Scopes:   []string{"openid", "email"},
+		}
+		client, ok := NewOIDCClient(provider, "some_client_secret", DefaultRedirectURL, nil).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		conf, _, err := client.clientConfig(ctx)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, conf.Scopes, DefaultScopes)
+	})
+
+	t.Run("custom scopes", func(t *testing.T) {
+		scopes := []string{"openid", "email", "custom_groups"}
+		assert.DeepEqual(t, clientScopes(t, scopes), scopes)
+	})
+
+	t.Run("openid is added when omitted", func(t *testing.T) {
+		scopes := []string{"email", "custom_groups"}
+		assert.DeepEqual(t, clientScopes(t, scopes), []string{"openid", "email", "custom_groups"})
+	})
+}
+
+func TestClientConfig_SecretReference(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+	provider := models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}
+
+	dir := t.TempDir()
+	storage := map[string]secrets.SecretStorage{
+		"env":       secrets.NewEnvSecretProviderFromConfig(secrets.GenericConfig{}),
+		"file":      secrets.NewFileSecretProviderFromConfig(secrets.FileConfig{Path: dir}),
+		"plaintext": secrets.NewPlainSecretProviderFromConfig(secrets.GenericConfig{}),
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("TEST_OIDC_CLIENT_SECRET", "secret-from-env")
+		client, ok := NewOIDCClient(provider, "env:TEST_OIDC_CLIENT_SECRET", DefaultRedirectURL, storage).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		conf, _, err := client.clientConfig(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.ClientSecret, "secret-from-env")
+		// the secret is not kept by the client
+		assert.Equal(t, client.ClientSecret, "env:TEST_OIDC_CLIENT_SECRET")
+	})
+
+	t.Run("file", func(t *testing.T) {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "okta"), []byte("secret-from-file"), 0o600))
+		client, ok := NewOIDCClient(provider, "file:okta", DefaultRedirectURL, storage).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		conf, _, err := client.clientConfig(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.ClientSecret, "secret-from-file")
+
+		// a rotated secret is used without creating a new client
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "okta"), []byte("rotated-secret"), 0o600))
+		conf, _, err = client.clientConfig(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.ClientSecret, "rotated-secret")
+	})
+
+	t.Run("file path is relative to the storage path", func(t *testing.T) {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "okta"), []byte("secret-from-file"), 0o600))
+		client, ok := NewOIDCClient(provider, "file:/okta", DefaultRedirectURL, storage).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		conf, _, err := client.clientConfig(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.ClientSecret, "secret-from-file")
+	})
+
+	t.Run("absolute file path with the default file storage", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "okta")
+		assert.NilError(t, os.WriteFile(filename, []byte("secret-from-absolute-path"), 0o600))
+		storage := map[string]secrets.SecretStorage{
+			"file": secrets.NewFileSecretProviderFromConfig(secrets.FileConfig{}),
+		}
+		client, ok := NewOIDCClient(provider, "file:"+filename, DefaultRedirectURL, storage).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		conf, _, err := client.clientConfig(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.ClientSecret, "secret-from-absolute-path")
+	})
+
+	t.Run("missing reference", func(t *testing.T) {
+		client, ok := NewOIDCClient(provider, "file:missing", DefaultRedirectURL, storage).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		_, _, err := client.clientConfig(ctx)
+		assert.ErrorContains(t, err, `client secret "file:missing"`)
+		assert.ErrorIs(t, err, secrets.ErrNotFound)
+	})
+
+	t.Run("unknown storage", func(t *testing.T) {
+		client, ok := NewOIDCClient(provider, "vault:okta", DefaultRedirectURL, storage).(*oidcClientImplementation)
+		assert.Assert(t, ok)
+
+		_, _, err := client.clientConfig(ctx)
+		assert.ErrorContains(t, err, "client secret (unknown secret storage)")
+	})
+}
+
+func TestExchangeAuthCodeForProviderToken_Groups(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	tokenWithClaims := func(t *testing.T, extra ...interface{}) tokenResponse {
+		now := time.Now().UTC()
+		claims := jwt.Claims{
+			Audience:  jwt.Audience([]string{"client-id"}),
+			NotBefore: jwt.NewNumericDate(now.Add(-5 * time.Minute)), // adjust for clock drift
+			Expiry:    jwt.NewNumericDate(now.Add(5 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "https://" + serverURL,
+		}
+		body, err := testTokenResponse(claims, server.signingKey, "hello@example.com", extra...)
+		assert.NilError(t, err)
+		return tokenResponse{code: 200, body: body}
+	}
+
+	t.Run("groups in the ID token", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, map[string]interface{}{"groups": []string{"Everyone", "developers"}})
+
+		client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", DefaultRedirectURL, nil)
+		_, _, _, _, groups, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", DefaultRedirectURL)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, groups, []string{"Everyone", "developers"})
+	})
+
+	t.Run("groups in a custom ID token claim", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, map[string]interface{}{"roles": []string{"admins"}})
+
+		provider := models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id", GroupsClaimName: "roles"}
+		client := NewOIDCClient(provider, "some_client_secret", DefaultRedirectURL, nil)
+		_, _, _, _, groups, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", DefaultRedirectURL)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, groups, []string{"admins"})
+	})
+
+	t.Run("azure ignores group IDs in the ID token", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, map[string]interface{}{"groups": []string{"8d2b1a3c-55e4-4f7e-9a1b-0c6d2e4f8a90"}})
+
+		provider := models.Provider{Kind: models.ProviderKindAzure, URL: serverURL, ClientID: "client-id"}
+		client := NewOIDCClient(provider, "some_client_secret", DefaultRedirectURL, nil)
+		_, _, _, email, groups, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", DefaultRedirectURL)
+		assert.NilError(t, err)
+		assert.Equal(t, email, "hello@example.com")
+		assert.Assert(t, groups == nil, "expected no groups from the ID token, got %v", groups)
+	})
+
+	t.Run("groups only in user info", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t)
+
+		client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", DefaultRedirectURL, nil)
+		_, _, _, _, groups, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", DefaultRedirectURL)
+		assert.NilError(t, err)
+		assert.Assert(t, groups == nil, "expected no groups from the ID token, got %v", groups)
+	})
+}
+
+func TestExchangeAuthCodeForProviderToken_EmailClaimName(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	tokenWithClaims := func(t *testing.T, email string, extra ...interface{}) tokenResponse {
+		now := time.Now().UTC()
+		claims := jwt.Claims{
+			Audience:  jwt.Audience([]string{"client-id"}),
+			NotBefore: jwt.NewNumericDate(now.Add(-5 * time.Minute)), // adjust for clock drift
+			Expiry:    jwt.NewNumericDate(now.Add(5 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "https://" + serverURL,
+		}
+		body, err := testTokenResponse(claims, server.signingKey, email, extra...)
+		assert.NilError(t, err)
+		return tokenResponse{code: 200, body: body}
+	}
+
+	exchange := func(t *testing.T, emailClaimName string) (string, error) {
+		t.Helper()
+		provider := models.Provider{
+			Kind:           models.ProviderKindOIDC,
+			URL:            serverURL,
+			ClientID:       "client-id",
+			EmailClaimName: emailClaimName,
+		}
+		client := NewOIDCClient(provider, "some_client_secret", DefaultRedirectURL, nil)
+		_, _, _, email, _, err := client.ExchangeAuthCodeForProviderTokens(ctx, "some-auth-code", DefaultRedirectURL)
+		return email, err
+	}
+
+	t.Run("default email claim", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, "hello@example.com",
+			map[string]interface{}{"upn": "other@example.com"})
+
+		email, err := exchange(t, "")
+		assert.NilError(t, err)
+		assert.Equal(t, email, "hello@example.com")
+	})
+
+	t.Run("upn claim", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, "",
+			map[string]interface{}{"upn": "jdoe@corp.example.com"})
+
+		email, err := exchange(t, "upn")
+		assert.NilError(t, err)
+		assert.Equal(t, email, "jdoe@corp.example.com")
+	})
+
+	t.Run("preferred_username claim", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, "hello@example.com",
+			map[string]interface{}{"preferred_username": "jdoe@example.com"})
+
+		email, err := exchange(t, "preferred_username")
+		assert.NilError(t, err)
+		assert.Equal(t, email, "jdoe@example.com")
+	})
+
+	t.Run("claim is missing", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, "hello@example.com")
+
+		_, err := exchange(t, "upn")
+		assert.ErrorContains(t, err, "missing an email address")
+	})
+
+	t.Run("claim is not an email address", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, "",
+			map[string]interface{}{"preferred_username": "jdoe"})
+
+		_, err := exchange(t, "preferred_username")
+		assert.Error(t, err, "ID token preferred_username claim has invalid email address")
+	})
+
+	t.Run("claim is not a string", func(t *testing.T) {
+		server.tokenResponse = tokenWithClaims(t, "",
+			map[string]interface{}{"upn": []string{"jdoe@example.com"}})
+
+		_, err := exchange(t, "upn")
+		assert.ErrorContains(t, err, "upn claim")
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, ctx := setupOIDCTest(t, "")
+	serverURL := server.run(t, nil)
+
+	signIDToken := func(t *testing.T, claims jwt.Claims, extra ...interface{}) string {
+		t.Helper()
+		body, err := testTokenResponse(claims, server.signingKey, "ci@example.com", extra...)
+		assert.NilError(t, err)
+
+		var resp struct {
+			IDToken string `json:"id_token"`
+		}
+		assert.NilError(t, json.Unmarshal([]byte(body), &resp))
+		return resp.IDToken
+	}
+
+	now := time.Now().UTC()
+	validClaims := jwt.Claims{
+		Audience:  jwt.Audience([]string{"client-id"}),
+		NotBefore: jwt.NewNumericDate(now.Add(-5 * time.Minute)), // adjust for clock drift
+		Expiry:    jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    "https://" + serverURL,
+	}
+
+	client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "client-id"}, "some_client_secret", "", nil)
+
+	t.Run("valid", func(t *testing.T) {
+		rawIDToken := signIDToken(t, validClaims, map[string]interface{}{"groups": []string{"deployers"}})
+
+		email, groups, err := client.VerifyIDToken(ctx, rawIDToken)
+		assert.NilError(t, err)
+		assert.Equal(t, email, "ci@example.com")
+		assert.DeepEqual(t, groups, []string{"deployers"})
+	})
+
+	t.Run("azure ignores group IDs", func(t *testing.T) {
+		rawIDToken := signIDToken(t, validClaims, map[string]interface{}{"groups": []string{"8d2b1a3c-55e4-4f7e-9a1b-0c6d2e4f8a90"}})
+
+		provider := models.Provider{Kind: models.ProviderKindAzure, URL: serverURL, ClientID: "client-id"}
+		azureClient := NewOIDCClient(provider, "some_client_secret", "", nil)
+		email, groups, err := azureClient.VerifyIDToken(ctx, rawIDToken)
+		assert.NilError(t, err)
+		assert.Equal(t, email, "ci@example.com")
+		assert.Assert(t, groups == nil, "expected no groups, got %v", groups)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		claims := validClaims
+		claims.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Hour))
+		claims.NotBefore = jwt.NewNumericDate(now.Add(-2 * time.Hour))
+		claims.Expiry = jwt.NewNumericDate(now.Add(-time.Hour))
+
+		_, _, err := client.VerifyIDToken(ctx, signIDToken(t, claims))
+		assert.ErrorContains(t, err, "token is expired")
+	})
+
+	t.Run("invalid audience", func(t *testing.T) {
+		claims := validClaims
+		claims.Audience = jwt.Audience([]string{"other-client"})
+
+		_, _, err := client.VerifyIDToken(ctx, signIDToken(t, claims))
+		assert.ErrorContains(t, err, "expected audience")
+	})
+
+	t.Run("not a token", func(t *testing.T) {
+		_, _, err := client.VerifyIDToken(ctx, "not-a-jwt")
+		assert.ErrorContains(t, err, "validate id token")
+	})
+}
+
 func TestRefreshAccessToken(t *testing.T) {
 	server, ctx := setupOIDCTest(t, "")
 	serverURL := server.run(t, nil)
-	provider := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "whatever"}, "secret", "http://localhost:8301")
+	provider := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "whatever"}, "secret", "http://localhost:8301", nil)
 
 	now := time.Now().UTC()
 
@@ -522,11 +942,14 @@ func TestRefreshAccessToken(t *testing.T) {
 	body, err := testTokenResponse(claims, server.signingKey, "hello@example.com")
 	assert.NilError(t, err)
 
+	// some identity providers do not issue a new refresh token on refresh
+	bodyWithoutRefreshToken := strings.Replace(body, `"refresh_token": "a9VpZDRCeFh3Nkk2VdY",`, "", 1)
+
 	tests := []struct {
 		name          string
 		providerUser  *models.ProviderUser
 		tokenResponse tokenResponse
-		verifyFunc    func(t *testing.T, accessToken string, expiry *time.Time, err error)
+		verifyFunc    func(t *testing.T, accessToken, refreshToken string, expiry *time.Time, err error)
 	}{
 		{
 			name: "invalid/expired refresh token fails",
@@ -539,10 +962,26 @@ func TestRefreshAccessToken(t *testing.T) {
 				code: 403,
 				body: "",
 			},
-			verifyFunc: func(t *testing.T, accessToken string, expiry *time.Time, err error) {
+			verifyFunc: func(t *testing.T, accessToken, refreshToken string, expiry *time.Time, err error) {
 				assert.ErrorContains(t, err, "cannot fetch token")
 			},
 		},
+		{
+			name: "revoked refresh token fails with forbidden",
+			providerUser: &models.ProviderUser{
+				AccessToken:  models.EncryptedAtRest("aaa"),
+				RefreshToken: models.EncryptedAtRest("bbb"),
+				ExpiresAt:    time.Now().UTC().Add(-5 * time.Minute),
+			},
+			tokenResponse: tokenResponse{
+				code: 400,
+				body: `{"error": "invalid_grant", "error_description": "The refresh token is invalid or expired."}`,
+			},
+			verifyFunc: func(t *testing.T, accessToken, refreshToken string, expiry *time.Time, err error) {
+				assert.ErrorIs(t, err, internal.ErrForbidden)
+				assert.ErrorContains(t, err, "refresh token was revoked")
+			},
+		},
 		{
 			name: "valid access token is not refreshed",
 			providerUser: &models.ProviderUser{
@@ -550,9 +989,10 @@ func TestRefreshAccessToken(t *testing.T) {
 				RefreshToken: models.EncryptedAtRest("bbb"),
 				ExpiresAt:    time.Now().UTC().Add(5 * time.Minute),
 			},
-			verifyFunc: func(t *testing.T, accessToken string, expiry *time.Time, err error) {
+			verifyFunc: func(t *testing.T, accessToken, refreshToken string, expiry *time.Time, err error) {
 				assert.NilError(t, err)
 				assert.Equal(t, accessToken, "aaa")
+				assert.Equal(t, refreshToken, "bbb")
 			},
 		},
 		{
@@ -562,13 +1002,31 @@ func TestRefreshAccessToken(t *testing.T) {
 				RefreshToken: models.EncryptedAtRest("bbb"),
 				ExpiresAt:    time.Now().UTC().Add(-5 * time.Minute),
 			},
+			tokenResponse: tokenResponse{
+				code: 200,
+				body: bodyWithoutRefreshToken,
+			},
+			verifyFunc: func(t *testing.T, accessToken, refreshToken string, expiry *time.Time, err error) {
+				assert.NilError(t, err)
+				assert.Assert(t, accessToken != "aaa")
+				assert.Equal(t, refreshToken, "bbb")
+			},
+		},
+		{
+			name: "rotated refresh token is returned",
+			providerUser: &models.ProviderUser{
+				AccessToken:  models.EncryptedAtRest("aaa"),
+				RefreshToken: models.EncryptedAtRest("bbb"),
+				ExpiresAt:    time.Now().UTC().Add(-5 * time.Minute),
+			},
 			tokenResponse: tokenResponse{
 				code: 200,
 				body: body,
 			},
-			verifyFunc: func(t *testing.T, accessToken string, expiry *time.Time, err error) {
+			verifyFunc: func(t *testing.T, accessToken, refreshToken string, expiry *time.Time, err error) {
 				assert.NilError(t, err)
 				assert.Assert(t, accessToken != "aaa")
+				assert.Equal(t, refreshToken, "a9VpZDRCeFh3Nkk2VdY")
 			},
 		},
 	}
@@ -576,8 +1034,8 @@ func TestRefreshAccessToken(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			server.tokenResponse = test.tokenResponse
-			accessToken, exp, err := provider.RefreshAccessToken(ctx, test.providerUser)
-			test.verifyFunc(t, accessToken, exp, err)
+			accessToken, refreshToken, exp, err := provider.RefreshAccessToken(ctx, test.providerUser)
+			test.verifyFunc(t, accessToken, refreshToken, exp, err)
 		})
 	}
 }
@@ -661,9 +1119,58 @@ func TestOIDC_GetUserInfo(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			server, ctx := setupOIDCTest(t, test.infoResponse)
 			serverURL := server.run(t, nil)
-			provider := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid"}, "invalid", "http://localhost:8301")
+			provider := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid"}, "invalid", "http://localhost:8301", nil)
 			info, err := provider.GetUserInfo(ctx, &models.ProviderUser{AccessToken: "aaa", RefreshToken: "bbb", ExpiresAt: time.Now().UTC().Add(5 * time.Minute)})
 			test.verifyFunc(t, info, err)
 		})
 	}
 }
+
+func TestOIDC_GetUserInfo_Endpoint(t *testing.T) {
+	infoResponse := `{"email": "hello@example.com"}`
+
+	t.Run("endpoint from discovery", func(t *testing.T) {
+		server, ctx := setupOIDCTest(t, infoResponse)
+		server.userInfoPath = "/v1/openid/userinfo"
+		serverURL := server.run(t, nil)
+
+		provider := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid"}, "invalid", "http://localhost:8301", nil)
+		info, err := provider.GetUserInfo(ctx, &models.ProviderUser{AccessToken: "aaa", RefreshToken: "bbb", ExpiresAt: time.Now().UTC().Add(5 * time.Minute)})
+		assert.NilError(t, err)
+		assert.Equal(t, info.Email, "hello@example.com")
+	})
+
+	t.Run("fallback when discovery has no endpoint", func(t *testing.T) {
+		server, ctx := setupOIDCTest(t, infoResponse)
+		server.omitUserInfoEndpoint = true
+		serverURL := server.run(t, nil)
+
+		provider := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: serverURL, ClientID: "invalid"}, "invalid", "http://localhost:8301", nil)
+		info, err := provider.GetUserInfo(ctx, &models.ProviderUser{AccessToken: "aaa", RefreshToken: "bbb", ExpiresAt: time.Now().UTC().Add(5 * time.Minute)})
+		assert.NilError(t, err)
+		assert.Equal(t, info.Email, "hello@example.com")
+	})
+}
+
+func TestOIDC_GetUserInfo_GroupsClaimName(t *testing.T) {
+	infoResponse := `{
+		"email": "hello@example.com",
+		"groups": ["Everyone"],
+		"http://schemas.microsoft.com/ws/2008/06/identity/claims/groups": ["Developers", "Admins"]
+	}`
+
+	server, ctx := setupOIDCTest(t, infoResponse)
+	serverURL := server.run(t, nil)
+
+	provider := NewOIDCClient(models.Provider{
+		Kind:            models.ProviderKindOIDC,
+		URL:             serverURL,
+		ClientID:        "invalid",
+		GroupsClaimName: "http://schemas.microsoft.com/ws/2008/06/identity/claims/groups",
+	}, "invalid", "http://localhost:8301", nil)
+
+	info, err := provider.GetUserInfo(ctx, &models.ProviderUser{AccessToken: "aaa", RefreshToken: "bbb", ExpiresAt: time.Now().UTC().Add(5 * time.Minute)})
+	assert.NilError(t, err)
+	assert.Equal(t, info.Email, "hello@example.com")
+	assert.DeepEqual(t, info.Groups, []string{"Developers", "Admins"})
+}
@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// defaultRequestTimeout is the default amount of time to wait for a response
+// to a single HTTP request to an identity provider.
+const defaultRequestTimeout = 10 * time.Second
+
+// maxRequestAttempts is the maximum number of times a request to an identity
+// provider is sent before the error is returned.
+const maxRequestAttempts = 3
+
+// retryBackoff is the time to wait before the first retry. The time doubles
+// for every retry after the first.
+var retryBackoff = 200 * time.Millisecond
+
+var requestTimeout = int64(defaultRequestTimeout)
+
+// SetRequestTimeout sets the amount of time to wait for a response to a
+// single HTTP request to an identity provider. A timeout of zero uses the
+// default of 10 seconds.
+func SetRequestTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	atomic.StoreInt64(&requestTimeout, int64(timeout))
+}
+
+func getRequestTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&requestTimeout))
+}
+
+// operationTimeout is the maximum amount of time for an operation which makes
+// requests to an identity provider, including all of the retries.
+func operationTimeout() time.Duration {
+	timeout := getRequestTimeout() * maxRequestAttempts
+	for i := 0; i < maxRequestAttempts-1; i++ {
+		timeout += retryBackoff << i
+	}
+	return timeout
+}
+
+// withRequestContext returns a context for an operation which makes requests
+// to an identity provider. The context has a timeout, and an HTTP client that
+// retries requests which failed with a transient error. An HTTP client already
+// in ctx is used to send the requests.
+func withRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = c // used in tests for specific transport needs, like skipping TLS verify
+	}
+
+	if _, ok := client.Transport.(*retryTransport); !ok {
+		retrying := *client
+		retrying.Transport = &retryTransport{next: client.Transport}
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &retrying)
+	}
+	return context.WithTimeout(ctx, operationTimeout())
+}
+
+// retryTransport is an http.RoundTripper which limits the time of each request,
+// and retries requests that failed because of a network error or a 5xx
+// response. Only GET and HEAD requests are retried, because other requests,
+// like the exchange of an authorization code, must not be sent twice.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := t.roundTrip(req)
+		if attempt >= maxRequestAttempts || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(retryBackoff << (attempt - 1))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// roundTrip sends a single request with the request timeout. The timeout
+// applies until the response body is closed.
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), getRequestTimeout())
+	resp, err := next.RoundTrip(req.Clone(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Context().Err() != nil {
+		// the caller gave up, not the request
+		return false
+	}
+	if err != nil {
+		// DNS and TLS errors are not expected to change on retry
+		switch newDiscoveryError(err).Kind {
+		case DiscoveryErrorTimeout, DiscoveryErrorConnection:
+			return true
+		default:
+			return false
+		}
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
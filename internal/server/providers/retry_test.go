@@ -0,0 +1,173 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/server/models"
+)
+
+func setRetryOptions(t *testing.T, timeout, backoff time.Duration) {
+	t.Helper()
+	origBackoff := retryBackoff
+	retryBackoff = backoff
+	SetRequestTimeout(timeout)
+	t.Cleanup(func() {
+		retryBackoff = origBackoff
+		SetRequestTimeout(0)
+	})
+}
+
+func TestRetryTransport(t *testing.T) {
+	setRetryOptions(t, 100*time.Millisecond, time.Millisecond)
+
+	type testCase struct {
+		name             string
+		method           string
+		handler          func(attempt int32, w http.ResponseWriter)
+		expectedCode     int
+		expectedErr      error
+		expectedAttempts int32
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			tc.handler(atomic.AddInt32(&attempts, 1), w)
+		}))
+		t.Cleanup(server.Close)
+
+		client := &http.Client{Transport: &retryTransport{}}
+		req, err := http.NewRequestWithContext(context.Background(), tc.method, server.URL, nil)
+		assert.NilError(t, err)
+
+		resp, err := client.Do(req)
+		if tc.expectedErr != nil {
+			assert.Assert(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+		} else {
+			assert.NilError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, resp.StatusCode, tc.expectedCode)
+		}
+		assert.Equal(t, atomic.LoadInt32(&attempts), tc.expectedAttempts)
+	}
+
+	testCases := []testCase{
+		{
+			name:   "success",
+			method: http.MethodGet,
+			handler: func(_ int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedCode:     http.StatusOK,
+			expectedAttempts: 1,
+		},
+		{
+			name:   "flaky server succeeds on retry",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedCode:     http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:   "server error after all attempts",
+			method: http.MethodGet,
+			handler: func(_ int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedCode:     http.StatusServiceUnavailable,
+			expectedAttempts: maxRequestAttempts,
+		},
+		{
+			name:   "unauthorized is not retried",
+			method: http.MethodGet,
+			handler: func(_ int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			expectedCode:     http.StatusUnauthorized,
+			expectedAttempts: 1,
+		},
+		{
+			name:   "post is not retried",
+			method: http.MethodPost,
+			handler: func(_ int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedCode:     http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:   "slow server times out",
+			method: http.MethodGet,
+			handler: func(_ int32, w http.ResponseWriter) {
+				time.Sleep(300 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedErr:      context.DeadlineExceeded,
+			expectedAttempts: maxRequestAttempts,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDiscover_Retry(t *testing.T) {
+	_, ctx := setupOIDCTest(t, "")
+	setRetryOptions(t, time.Second, time.Millisecond)
+
+	var attempts int32
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err := fmt.Fprintf(w, `{"issuer": "%[1]s", "authorization_endpoint": "%[1]s/auth"}`, server.URL)
+		assert.Check(t, err)
+	})
+
+	domain := strings.TrimPrefix(server.URL, "https://")
+	client := NewOIDCClient(models.Provider{Kind: models.ProviderKindOIDC, URL: domain}, "secret", DefaultRedirectURL, nil)
+
+	result, err := client.Discover(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, result.AuthURL, server.URL+"/auth")
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(2))
+
+	t.Run("client in context is wrapped once", func(t *testing.T) {
+		ctx, cancel := withRequestContext(ctx)
+		defer cancel()
+		again, cancel := withRequestContext(ctx)
+		defer cancel()
+
+		client, ok := again.Value(oauth2.HTTPClient).(*http.Client)
+		assert.Assert(t, ok)
+		transport, ok := client.Transport.(*retryTransport)
+		assert.Assert(t, ok)
+		_, nested := transport.next.(*retryTransport)
+		assert.Assert(t, !nested)
+	})
+}
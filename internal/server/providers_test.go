@@ -303,6 +303,60 @@ func TestAPI_CreateProvider(t *testing.T) {
 				assert.DeepEqual(t, respBody, expected)
 			},
 		},
+		{
+			name: "custom scopes",
+			body: api.CreateProviderRequest{
+				Name:            "custom-scopes",
+				URL:             "example.com",
+				ClientID:        "client-id",
+				ClientSecret:    "client-secret",
+				RequestedScopes: []string{"email", "custom_groups"},
+			},
+			setup: func(t *testing.T, req *http.Request) {
+				ctx := providers.WithOIDCClient(req.Context(), &fakeOIDCImplementation{})
+				*req = *req.WithContext(ctx)
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+				respBody := &api.Provider{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.DeepEqual(t, respBody.RequestedScopes, []string{"openid", "email", "custom_groups"})
+				// the supported scopes are still read from the provider
+				assert.DeepEqual(t, respBody.Scopes, []string{"openid", "email"})
+			},
+		},
+		{
+			name: "unreachable domain",
+			body: api.CreateProviderRequest{
+				Name:         "olive",
+				URL:          "nonexistent.invalid",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+			},
+			setup: func(t *testing.T, req *http.Request) {
+				ctx := providers.WithOIDCClient(req.Context(), &fakeOIDCImplementation{
+					DiscoveryErr: providers.DiscoveryError{
+						Kind: providers.DiscoveryErrorDNS,
+						Err:  fmt.Errorf("no such host"),
+					},
+				})
+				*req = *req.WithContext(ctx)
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "url", Errors: []string{"could not find an OpenID configuration (dns): no such host"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -312,6 +366,35 @@ func TestAPI_CreateProvider(t *testing.T) {
 	}
 }
 
+func TestAPI_CreateProvider_SkipValidation(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	body := jsonBody(t, api.CreateProviderRequest{
+		Name:           "airgapped",
+		URL:            "nonexistent.invalid",
+		ClientID:       "client-id",
+		ClientSecret:   "client-secret",
+		Kind:           string(models.ProviderKindOIDC),
+		SkipValidation: true,
+	})
+
+	req, err := http.NewRequest(http.MethodPost, "/api/providers", body)
+	assert.NilError(t, err)
+	req.Header.Add("Authorization", "Bearer "+adminAccessKey(srv))
+	req.Header.Set("Infra-Version", apiVersionLatest)
+
+	resp := httptest.NewRecorder()
+	routes.ServeHTTP(resp, req)
+	assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+
+	respBody := &api.Provider{}
+	err = json.Unmarshal(resp.Body.Bytes(), respBody)
+	assert.NilError(t, err)
+	assert.Equal(t, respBody.URL, "nonexistent.invalid")
+	assert.DeepEqual(t, respBody.Scopes, []string{"openid", "email"})
+}
+
 func TestAPI_UpdateProvider(t *testing.T) {
 	srv := setupServer(t, withAdminUser)
 	routes := srv.GenerateRoutes()
@@ -461,6 +544,43 @@ func TestAPI_UpdateProvider(t *testing.T) {
 				assert.DeepEqual(t, respBody, expected)
 			},
 		},
+		{
+			name: "skip validation keeps the provider info",
+			body: api.UpdateProviderRequest{
+				Name:           "google",
+				URL:            "nonexistent.invalid",
+				ClientID:       "client-id",
+				ClientSecret:   "client-secret",
+				Kind:           string(models.ProviderKindGoogle),
+				SkipValidation: true,
+			},
+			setup: func(t *testing.T, req *http.Request) {
+				existing, err := data.GetProvider(srv.DB(), data.ByID(provider.ID))
+				assert.NilError(t, err)
+				existing.AuthURL = "https://idp.example.com/auth"
+				existing.Scopes = []string{"openid", "email", "groups"}
+				assert.NilError(t, data.SaveProvider(srv.DB(), existing))
+
+				// the provider is not called
+				ctx := providers.WithOIDCClient(req.Context(), &fakeOIDCImplementation{
+					DiscoveryErr: providers.DiscoveryError{
+						Kind: providers.DiscoveryErrorDNS,
+						Err:  fmt.Errorf("no such host"),
+					},
+				})
+				*req = *req.WithContext(ctx)
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.Provider{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.Equal(t, respBody.URL, "nonexistent.invalid")
+				assert.Equal(t, respBody.AuthURL, "https://idp.example.com/auth")
+				assert.DeepEqual(t, respBody.Scopes, []string{"openid", "email", "groups"})
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -470,9 +590,115 @@ func TestAPI_UpdateProvider(t *testing.T) {
 	}
 }
 
+func TestAPI_TestProvider(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	provider := &models.Provider{Name: "mokta", Kind: models.ProviderKindOkta, URL: "example.com"}
+	err := data.CreateProvider(srv.DB(), provider)
+	assert.NilError(t, err)
+
+	type testCase struct {
+		urlPath  string
+		setup    func(t *testing.T, req *http.Request)
+		expected func(t *testing.T, resp *httptest.ResponseRecorder)
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req, err := http.NewRequest(http.MethodPost, tc.urlPath, nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		if tc.setup != nil {
+			tc.setup(t, req)
+		}
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		tc.expected(t, resp)
+	}
+
+	withOIDCClient := func(client providers.OIDCClient) func(t *testing.T, req *http.Request) {
+		return func(t *testing.T, req *http.Request) {
+			ctx := providers.WithOIDCClient(req.Context(), client)
+			*req = *req.WithContext(ctx)
+		}
+	}
+
+	testCases := map[string]testCase{
+		"not authorized": {
+			urlPath: "/api/providers/" + provider.ID.String() + "/test",
+			setup: func(t *testing.T, req *http.Request) {
+				key, _ := createAccessKey(t, srv.DB(), "someonenew@example.com")
+				req.Header.Set("Authorization", "Bearer "+key)
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+			},
+		},
+		"infra provider can not be tested": {
+			urlPath: "/api/providers/" + data.InfraProvider(srv.DB()).ID.String() + "/test",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+			},
+		},
+		"success": {
+			urlPath: "/api/providers/" + provider.ID.String() + "/test",
+			setup:   withOIDCClient(&fakeOIDCImplementation{}),
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.TestProviderResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := &api.TestProviderResponse{
+					Success:     true,
+					Issuer:      "https://example.com",
+					AuthURL:     "https://example.com/v1/auth",
+					TokenURL:    "https://example.com/v1/token",
+					UserInfoURL: "https://example.com/v1/userinfo",
+					JWKSURL:     "https://example.com/v1/keys",
+				}
+				assert.DeepEqual(t, respBody, expected)
+			},
+		},
+		"discovery failed": {
+			urlPath: "/api/providers/" + provider.ID.String() + "/test",
+			setup: withOIDCClient(&fakeOIDCImplementation{
+				DiscoveryErr: providers.DiscoveryError{
+					Kind: providers.DiscoveryErrorDNS,
+					Err:  fmt.Errorf("no such host"),
+				},
+			}),
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.TestProviderResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := &api.TestProviderResponse{
+					ErrorKind: "dns",
+					Error:     "no such host",
+				}
+				assert.DeepEqual(t, respBody, expected)
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
 // mockOIDC is a fake oidc identity provider
 type fakeOIDCImplementation struct {
-	UserInfoRevoked bool // when true returns an error fromt the user info endpoint
+	UserInfoRevoked bool  // when true returns an error fromt the user info endpoint
+	DiscoveryErr    error // when set it is returned from Discover
 }
 
 func (m *fakeOIDCImplementation) Validate(_ context.Context) error {
@@ -483,13 +709,44 @@ func (m *fakeOIDCImplementation) AuthServerInfo(_ context.Context) (*providers.A
 	return &providers.AuthServerInfo{AuthURL: "example.com/v1/auth", ScopesSupported: []string{"openid", "email"}}, nil
 }
 
-func (m *fakeOIDCImplementation) ExchangeAuthCodeForProviderTokens(_ context.Context, _ string) (acc, ref string, exp time.Time, email string, err error) {
-	return "acc", "ref", exp, "", nil
+func (m *fakeOIDCImplementation) Discover(_ context.Context) (*providers.DiscoveryResult, error) {
+	if m.DiscoveryErr != nil {
+		return nil, m.DiscoveryErr
+	}
+	return &providers.DiscoveryResult{
+		Issuer:      "https://example.com",
+		AuthURL:     "https://example.com/v1/auth",
+		TokenURL:    "https://example.com/v1/token",
+		UserInfoURL: "https://example.com/v1/userinfo",
+		JWKSURL:     "https://example.com/v1/keys",
+	}, nil
+}
+
+func (m *fakeOIDCImplementation) ExchangeAuthCodeForProviderTokens(_ context.Context, _, _ string) (acc, ref string, exp time.Time, email string, groups []string, err error) {
+	return "acc", "ref", exp, "", nil, nil
+}
+
+func (m *fakeOIDCImplementation) VerifyIDToken(_ context.Context, _ string) (email string, groups []string, err error) {
+	return "", nil, nil
+}
+
+func (m *fakeOIDCImplementation) StartDeviceAuthorization(_ context.Context) (*providers.DeviceAuthorization, error) {
+	return &providers.DeviceAuthorization{
+		DeviceCode:      "device-code",
+		UserCode:        "ABCD-EFGH",
+		VerificationURI: "https://example.com/device",
+		ExpiresAt:       time.Date(2022, 10, 17, 9, 10, 0, 0, time.UTC),
+		Interval:        5 * time.Second,
+	}, nil
+}
+
+func (m *fakeOIDCImplementation) PollDeviceAuthorization(_ context.Context, _ *providers.DeviceAuthorization) (acc, ref string, exp time.Time, email string, groups []string, err error) {
+	return "acc", "ref", exp, "", nil, nil
 }
 
-func (m *fakeOIDCImplementation) RefreshAccessToken(_ context.Context, providerUser *models.ProviderUser) (accessToken string, expiry *time.Time, err error) {
+func (m *fakeOIDCImplementation) RefreshAccessToken(_ context.Context, providerUser *models.ProviderUser) (accessToken, refreshToken string, expiry *time.Time, err error) {
 	// never update
-	return string(providerUser.AccessToken), &providerUser.ExpiresAt, nil
+	return string(providerUser.AccessToken), string(providerUser.RefreshToken), &providerUser.ExpiresAt, nil
 }
 
 func (m *fakeOIDCImplementation) GetUserInfo(_ context.Context, _ *models.ProviderUser) (*providers.UserInfoClaims, error) {
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitOptions configure the number of requests a single identity, or
+// client IP address for unauthenticated requests, may make to a group of
+// routes.
+type RateLimitOptions struct {
+	// RequestsPerMinute is the rate at which requests are allowed. Zero
+	// disables rate limiting.
+	RequestsPerMinute int
+	// Burst is the number of requests that may be made at once before the
+	// rate applies.
+	Burst int
+	// TrustedProxies are the IP addresses, or CIDR ranges, of the proxies in
+	// front of the server. The client IP address of a request from a trusted
+	// proxy is read from the X-Forwarded-For header. The header is ignored
+	// when the request is from any other address.
+	TrustedProxies []string
+}
+
+func (o RateLimitOptions) validate() error {
+	_, err := parseTrustedProxies(o.TrustedProxies)
+	return err
+}
+
+// parseTrustedProxies parses each value as a CIDR range, or as a single IP
+// address.
+func parseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			_, ipNet, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			result = append(result, ipNet)
+			continue
+		}
+
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: not an IP address or CIDR range", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return result, nil
+}
+
+// rateLimiterIdleTimeout is the amount of time after the last request from a
+// key before its limiter is removed.
+const rateLimiterIdleTimeout = 10 * time.Minute
+
+// rateLimiter is a token bucket rate limiter that tracks a separate bucket for
+// each key.
+type rateLimiter struct {
+	limit          rate.Limit
+	burst          int
+	trustedProxies []*net.IPNet
+	now            func() time.Time
+
+	mu        sync.Mutex
+	limiters  map[string]*rateLimiterEntry
+	lastPurge time.Time
+}
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter returns a rateLimiter using opts, or nil if rate limiting is
+// disabled. opts must be valid.
+func newRateLimiter(opts RateLimitOptions) *rateLimiter {
+	if opts.RequestsPerMinute <= 0 {
+		return nil
+	}
+	burst := opts.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	// the options are validated when the server is created
+	trustedProxies, _ := parseTrustedProxies(opts.TrustedProxies)
+	return &rateLimiter{
+		limit:          rate.Limit(float64(opts.RequestsPerMinute) / 60),
+		burst:          burst,
+		trustedProxies: trustedProxies,
+		now:            time.Now,
+		limiters:       map[string]*rateLimiterEntry{},
+	}
+}
+
+// allow returns a rateLimitError if the request identified by key exceeds the
+// rate limit.
+func (r *rateLimiter) allow(key string) error {
+	now := r.now()
+
+	r.mu.Lock()
+	r.purgeIdle(now)
+	entry, ok := r.limiters[key]
+	if !ok {
+		entry = &rateLimiterEntry{limiter: rate.NewLimiter(r.limit, r.burst)}
+		r.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	r.mu.Unlock()
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return rateLimitError{retryAfter: delay}
+	}
+	return nil
+}
+
+// purgeIdle removes the limiters of keys that have not made a request recently,
+// so that the number of limiters does not grow without bound. The caller must
+// hold the lock.
+func (r *rateLimiter) purgeIdle(now time.Time) {
+	if now.Sub(r.lastPurge) < rateLimiterIdleTimeout {
+		return
+	}
+	for key, entry := range r.limiters {
+		if now.Sub(entry.lastSeen) > rateLimiterIdleTimeout {
+			delete(r.limiters, key)
+		}
+	}
+	r.lastPurge = now
+}
+
+// key returns the key used to rate limit the request. Authenticated requests
+// are limited by identity, and all other requests by client IP address.
+func (r *rateLimiter) key(c *gin.Context) string {
+	if user := getRequestContext(c).Authenticated.User; user != nil {
+		return "identity:" + user.ID.String()
+	}
+	return "ip:" + r.clientIP(c.Request, c.RemoteIP())
+}
+
+// clientIP returns the IP address of the client that made req. The address is
+// remoteIP, the address of the connection, unless the connection is from a
+// trusted proxy. Then the address is the last address in the X-Forwarded-For
+// header that is not a trusted proxy. The addresses before it are set by the
+// client, and can be changed on every request.
+func (r *rateLimiter) clientIP(req *http.Request, remoteIP string) string {
+	ip := remoteIP
+	if !r.isTrustedProxy(ip) {
+		return ip
+	}
+
+	var forwarded []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if addr == nil {
+			break
+		}
+		ip = addr.String()
+		if !r.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (r *rateLimiter) isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, ipNet := range r.trustedProxies {
+		if ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitError is returned when a request exceeds the rate limit.
+type rateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e rateLimitError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %d seconds", e.retryAfterSeconds())
+}
+
+func (e rateLimitError) retryAfterSeconds() int {
+	return int(math.Ceil(e.retryAfter.Seconds()))
+}
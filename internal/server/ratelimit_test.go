@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimitOptions{RequestsPerMinute: 60, Burst: 2})
+
+	now := time.Date(2022, time.October, 7, 10, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	assert.NilError(t, limiter.allow("first"))
+	assert.NilError(t, limiter.allow("first"))
+
+	err := limiter.allow("first")
+	assert.Error(t, err, "too many requests, retry after 1 seconds")
+
+	t.Run("other keys are not limited", func(t *testing.T) {
+		assert.NilError(t, limiter.allow("second"))
+	})
+
+	t.Run("allowed after waiting", func(t *testing.T) {
+		now = now.Add(time.Second)
+		assert.NilError(t, limiter.allow("first"))
+		assert.ErrorType(t, limiter.allow("first"), rateLimitError{})
+	})
+
+	t.Run("idle limiters are removed", func(t *testing.T) {
+		now = now.Add(rateLimiterIdleTimeout + time.Second)
+		assert.NilError(t, limiter.allow("third"))
+		assert.Equal(t, len(limiter.limiters), 1)
+	})
+}
+
+func TestRateLimiter_ClientIP(t *testing.T) {
+	limiter := newRateLimiter(RateLimitOptions{
+		RequestsPerMinute: 60,
+		TrustedProxies:    []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+
+	type testCase struct {
+		name         string
+		remoteIP     string
+		forwardedFor []string
+		expected     string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "no header",
+			remoteIP: "10.0.0.1",
+			expected: "10.0.0.1",
+		},
+		{
+			name:         "untrusted peer",
+			remoteIP:     "203.0.113.9",
+			forwardedFor: []string{"198.51.100.1"},
+			expected:     "203.0.113.9",
+		},
+		{
+			name:         "trusted peer",
+			remoteIP:     "10.0.0.1",
+			forwardedFor: []string{"198.51.100.1"},
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "spoofed addresses before the client are ignored",
+			remoteIP:     "10.0.0.1",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.1, 192.168.1.1"},
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "multiple headers",
+			remoteIP:     "10.0.0.1",
+			forwardedFor: []string{"1.2.3.4", "198.51.100.1"},
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "invalid address",
+			remoteIP:     "10.0.0.1",
+			forwardedFor: []string{"198.51.100.1, not-an-ip, 10.0.0.2"},
+			expected:     "10.0.0.2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+			for _, value := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, limiter.clientIP(req, tc.remoteIP), tc.expected)
+		})
+	}
+}
+
+func TestRateLimitOptions_Validate(t *testing.T) {
+	opts := RateLimitOptions{TrustedProxies: []string{"10.0.0.0/8", "::1", "fd00::/8"}}
+	assert.NilError(t, opts.validate())
+
+	opts = RateLimitOptions{TrustedProxies: []string{"10.0.0.0/33"}}
+	assert.ErrorContains(t, opts.validate(), `invalid trusted proxy "10.0.0.0/33"`)
+
+	opts = RateLimitOptions{TrustedProxies: []string{"proxy.example.com"}}
+	assert.ErrorContains(t, opts.validate(), "not an IP address or CIDR range")
+}
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	assert.Assert(t, newRateLimiter(RateLimitOptions{}) == nil)
+}
+
+func TestAPI_Login_RateLimit(t *testing.T) {
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.AuthRateLimit = RateLimitOptions{RequestsPerMinute: 1, Burst: 2}
+	})
+	routes := srv.GenerateRoutes()
+
+	login := func(forwardedFor string) *httptest.ResponseRecorder {
+		body := jsonBody(t, api.LoginRequest{
+			PasswordCredentials: &api.LoginRequestPasswordCredentials{
+				Name:     "nobody@example.com",
+				Password: "wrong-password",
+			},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/login", body)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		req.RemoteAddr = "10.0.0.1:41234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := login(fmt.Sprintf("192.168.0.%d", i))
+		assert.Assert(t, resp.Code != http.StatusTooManyRequests, resp.Body.String())
+	}
+
+	// changing X-Forwarded-For does not bypass the limit
+	resp := login("192.168.0.100")
+	assert.Equal(t, resp.Code, http.StatusTooManyRequests, resp.Body.String())
+	assert.Equal(t, resp.Header().Get("Retry-After"), "60")
+
+	respBody := &api.Error{}
+	err := json.Unmarshal(resp.Body.Bytes(), respBody)
+	assert.NilError(t, err)
+	assert.Equal(t, respBody.Message, "too many requests, retry after 60 seconds")
+
+	t.Run("clients behind a trusted proxy are limited separately", func(t *testing.T) {
+		srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+			opts.AuthRateLimit = RateLimitOptions{
+				RequestsPerMinute: 1,
+				Burst:             1,
+				TrustedProxies:    []string{"10.0.0.0/8"},
+			}
+		})
+		routes = srv.GenerateRoutes()
+
+		resp := login("192.168.0.1")
+		assert.Assert(t, resp.Code != http.StatusTooManyRequests, resp.Body.String())
+
+		resp = login("192.168.0.2")
+		assert.Assert(t, resp.Code != http.StatusTooManyRequests, resp.Body.String())
+
+		resp = login("192.168.0.1")
+		assert.Equal(t, resp.Code, http.StatusTooManyRequests, resp.Body.String())
+	})
+}
@@ -13,7 +13,7 @@ import (
 
 func (a *API) VerifyAndRedirect(c *gin.Context, r *api.VerifyAndRedirectRequest) (*api.RedirectResponse, error) {
 	if err := access.VerifyUserByToken(getRequestContext(c), r.VerificationToken); err != nil {
-		logging.L.Error().Msg("VerifyUserByToken: " + err.Error())
+		logging.Ctx(c.Request.Context()).Error().Msg("VerifyUserByToken: " + err.Error())
 	}
 
 	redirectTo, err := base64.URLEncoding.DecodeString(r.Base64RedirectURL)
@@ -1,19 +1,29 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/logging"
+	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/validate"
 	"github.com/infrahq/infra/metrics"
 )
@@ -34,36 +44,63 @@ type Routes struct {
 // with all the middleware that will apply to the route when the
 // Router.{GET,POST,etc} method is called.
 func (s *Server) GenerateRoutes() Routes {
-	a := &API{t: s.tel, server: s}
+	a := &API{
+		t:           s.tel,
+		server:      s,
+		idempotency: newIdempotencyCache(idempotencyKeyTTL),
+	}
 	a.addRewrites()
 	a.addRedirects()
 
+	// metricsMiddleware is shared by the API routes and the not found handler,
+	// so that requests which do not match a route are also measured
+	metricsMiddleware := metrics.Middleware(s.metricsRegistry)
+	s.metricsRegistry.MustRegister(newBuildInfoMetric())
+	s.authnMetrics = newAuthnMetrics(s.metricsRegistry)
+
 	router := gin.New()
-	router.NoRoute(a.notFoundHandler)
+	router.NoRoute(metricsMiddleware, a.notFoundHandler)
 
 	router.Use(gin.Recovery())
 	router.GET("/healthz", healthHandler)
+	router.GET("/readyz", readyHandler(s.db))
 
 	// This group of middleware will apply to everything, including the UI
 	router.Use(
+		RequestIDMiddleware(),
 		loggingMiddleware(s.options.EnableLogSampling),
-		TimeoutMiddleware(1*time.Minute),
+		CORSMiddleware(s.options.CORS),
 	)
 
 	// This group of middleware only applies to non-ui routes
-	apiGroup := router.Group("/", metrics.Middleware(s.metricsRegistry))
+	apiGroup := router.Group("/",
+		metricsMiddleware,
+		SecurityHeadersMiddleware(s.options.SecurityHeaders),
+		MaxBodyBytesMiddleware(s.options.MaxRequestBodyBytes),
+	)
 
 	// auth required, org required
 	authn := &routeGroup{RouterGroup: apiGroup.Group("/")}
 
 	get(a, authn, "/api/users", a.ListUsers)
-	post(a, authn, "/api/users", a.CreateUser)
+	// responses include a one time password, which must not be stored for
+	// an Idempotency-Key.
+	add(a, authn, http.MethodPost, "/api/users", route[api.CreateUserRequest, *api.CreateUserResponse]{
+		handler: a.CreateUser,
+	})
 	get(a, authn, "/api/users/:id", a.GetUser)
 	put(a, authn, "/api/users/:id", a.UpdateUser)
+	patch(a, authn, "/api/users/:id", a.PatchUser)
 	del(a, authn, "/api/users/:id", a.DeleteUser)
+	get(a, authn, "/api/users/:id/effective-grants", a.ListEffectiveGrants)
 
 	get(a, authn, "/api/access-keys", a.ListAccessKeys)
-	post(a, authn, "/api/access-keys", a.CreateAccessKey)
+	add(a, authn, http.MethodPost, "/api/access-keys", route[api.CreateAccessKeyRequest, *api.CreateAccessKeyResponse]{
+		handler:             a.CreateAccessKey,
+		idempotent:          true,
+		idempotencyResponse: accessKeyIdempotencyResponse,
+	})
+	del(a, authn, "/api/access-keys", a.DeleteAccessKeys)
 	del(a, authn, "/api/access-keys/:id", a.DeleteAccessKey)
 
 	get(a, authn, "/api/groups", a.ListGroups)
@@ -71,6 +108,8 @@ func (s *Server) GenerateRoutes() Routes {
 	get(a, authn, "/api/groups/:id", a.GetGroup)
 	del(a, authn, "/api/groups/:id", a.DeleteGroup)
 	patch(a, authn, "/api/groups/:id/users", a.UpdateUsersInGroup)
+	post(a, authn, "/api/groups/:id/users", a.AddUserToGroup)
+	del(a, authn, "/api/groups/:id/users/:userID", a.RemoveUserFromGroup)
 
 	get(a, authn, "/api/organizations", a.ListOrganizations)
 	post(a, authn, "/api/organizations", a.CreateOrganization)
@@ -81,35 +120,58 @@ func (s *Server) GenerateRoutes() Routes {
 	get(a, authn, "/api/grants/:id", a.GetGrant)
 	post(a, authn, "/api/grants", a.CreateGrant)
 	del(a, authn, "/api/grants/:id", a.DeleteGrant)
+	post(a, authn, "/api/grants/check", a.CheckAuthorization)
+	add(a, authn, http.MethodGet, "/api/grants/stream", route[api.EmptyRequest, *grantEventStream]{
+		handler:      a.StreamGrantEvents,
+		omitFromDocs: true,
+	})
+	add(a, authn, http.MethodPost, "/api/grants/batch", route[api.CreateGrantsRequest, *api.CreateGrantsResponse]{
+		handler:             a.CreateGrants,
+		idempotent:          true,
+		retryWriteConflicts: true,
+		timeout:             bulkRequestTimeout,
+	})
+	add(a, authn, http.MethodGet, "/api/grants/export", route[api.EmptyRequest, *grantExport]{
+		handler:      a.ExportGrants,
+		omitFromDocs: true,
+	})
+	add(a, authn, http.MethodPost, "/api/grants/import", route[api.ImportGrantsRequest, *api.ImportGrantsResponse]{
+		handler: a.ImportGrants,
+		timeout: bulkRequestTimeout,
+	})
 
 	post(a, authn, "/api/providers", a.CreateProvider)
 	put(a, authn, "/api/providers/:id", a.UpdateProvider)
 	del(a, authn, "/api/providers/:id", a.DeleteProvider)
+	post(a, authn, "/api/providers/:id/test", a.TestProvider)
 
 	get(a, authn, "/api/destinations", a.ListDestinations)
 	get(a, authn, "/api/destinations/:id", a.GetDestination)
 	post(a, authn, "/api/destinations", a.CreateDestination)
 	put(a, authn, "/api/destinations/:id", a.UpdateDestination)
+	patch(a, authn, "/api/destinations/:id", a.PatchDestination)
 	del(a, authn, "/api/destinations/:id", a.DeleteDestination)
 
-	post(a, authn, "/api/tokens", a.CreateToken)
 	post(a, authn, "/api/logout", a.Logout)
 
 	put(a, authn, "/api/settings", a.UpdateSettings)
+	post(a, authn, "/api/settings/rotate-signing-key", a.RotateSigningKey)
 
-	add(a, authn, http.MethodGet, "/api/debug/pprof/*profile", pprofRoute)
+	if s.options.EnablePprof {
+		add(a, authn, http.MethodGet, "/api/debug/pprof/*profile", pprofRoute)
+	}
 
 	// no auth required, org not required
 	noAuthnNoOrg := &routeGroup{RouterGroup: apiGroup.Group("/"), noAuthentication: true, noOrgRequired: true}
 	post(a, noAuthnNoOrg, "/api/signup", a.Signup)
 	get(a, noAuthnNoOrg, "/api/version", a.Version)
+	get(a, noAuthnNoOrg, "/api/version/deprecations", a.ListDeprecatedRoutes)
 	get(a, noAuthnNoOrg, "/api/server-configuration", a.GetServerConfiguration)
 	post(a, noAuthnNoOrg, "/api/forgot-domain-request", a.RequestForgotDomains)
 
 	// no auth required, org required
 	noAuthnWithOrg := &routeGroup{RouterGroup: apiGroup.Group("/"), noAuthentication: true}
 
-	post(a, noAuthnWithOrg, "/api/login", a.Login)
 	post(a, noAuthnWithOrg, "/api/password-reset-request", a.RequestPasswordReset)
 	post(a, noAuthnWithOrg, "/api/password-reset", a.VerifiedPasswordReset)
 
@@ -125,14 +187,31 @@ func (s *Server) GenerateRoutes() Routes {
 
 	add(a, noAuthnWithOrg, http.MethodGet, "/.well-known/jwks.json", wellKnownJWKsRoute)
 
+	// rate limited by identity or client IP address
+	authRateLimiter := newRateLimiter(s.options.AuthRateLimit)
+	authnRateLimited := &routeGroup{RouterGroup: apiGroup.Group("/"), rateLimiter: authRateLimiter}
+	noAuthnWithOrgRateLimited := &routeGroup{RouterGroup: apiGroup.Group("/"), noAuthentication: true, rateLimiter: authRateLimiter}
+
+	// responses include a token, which must not be stored for an
+	// Idempotency-Key.
+	add(a, authnRateLimited, http.MethodPost, "/api/tokens", route[api.EmptyRequest, *api.CreateTokenResponse]{
+		handler: a.CreateToken,
+	})
+	post(a, noAuthnWithOrgRateLimited, "/api/login", a.Login)
+	post(a, noAuthnWithOrgRateLimited, "/api/providers/:id/device-authorization", a.StartDeviceAuthorization)
+
 	a.deprecatedRoutes(noAuthnNoOrg)
 
+	// API routes apply their own timeout in wrapRoute, this one only applies
+	// to the UI and the not found handler.
+	router.Use(TimeoutMiddleware(defaultRequestTimeout))
+
 	// registerUIRoutes must happen last because it uses catch-all middleware
 	// with no handlers. Any route added after the UI will end up using the
 	// UI middleware unnecessarily.
 	// This is a limitation because we serve the UI from / instead of a specific
 	// path prefix.
-	registerUIRoutes(router, s.options.UI)
+	registerUIRoutes(router, s.options.UI, s.options.SecurityHeaders)
 	return Routes{Handler: router, OpenAPIDocument: a.openAPIDoc}
 }
 
@@ -145,6 +224,50 @@ type route[Req, Res any] struct {
 	infraVersionHeaderOptional bool
 	noAuthentication           bool
 	noOrgRequired              bool
+
+	// maxBodyBytes overrides the MaxRequestBodyBytes server option for the
+	// route, when it is greater than zero.
+	maxBodyBytes int64
+	rateLimiter  *rateLimiter
+
+	// cacheMaxAge, when greater than zero, adds a Cache-Control header to
+	// successful responses, so that clients may use the response for that
+	// long without requesting it again.
+	cacheMaxAge time.Duration
+
+	// timeout overrides defaultRequestTimeout for the route, when it is
+	// greater than zero.
+	timeout time.Duration
+
+	// idempotent routes store the response to an authenticated request with
+	// an Idempotency-Key header, and send the same response to a retry of
+	// the request. Only the status code, content type, and body of the
+	// response are stored.
+	// Routes that respond with a secret must set idempotencyResponse.
+	idempotent bool
+	// idempotencyResponse returns the status code and body to store for an
+	// Idempotency-Key, instead of the response to the first request. Routes
+	// that respond with a secret use it so that the secret is not stored, or
+	// sent again.
+	idempotencyResponse func(resp Res) (int, any)
+
+	// retryWriteConflicts runs the request again, in a new transaction, when
+	// the transaction conflicts with a concurrent write. The handler must
+	// not have side effects outside of the transaction.
+	retryWriteConflicts bool
+
+	// deprecatedSince is the version of infra that deprecated the route.
+	// Responses from a deprecated route include a Deprecation header.
+	deprecatedSince string
+	// sunset is the date after which a deprecated route may be removed. When
+	// set, responses include a Sunset header.
+	sunset time.Time
+	// removedIn is the version of infra that removed the route. Requests to a
+	// removed route receive a 410 Gone response.
+	removedIn string
+	// replacedBy is the path of the route that should be used instead of a
+	// deprecated or removed route.
+	replacedBy string
 }
 
 type routeIdentifier struct {
@@ -158,6 +281,7 @@ type routeGroup struct {
 	*gin.RouterGroup
 	noAuthentication bool
 	noOrgRequired    bool
+	rateLimiter      *rateLimiter
 }
 
 func add[Req, Res any](a *API, group *routeGroup, method, urlPath string, route route[Req, Res]) {
@@ -166,13 +290,18 @@ func add[Req, Res any](a *API, group *routeGroup, method, urlPath string, route
 		path:   path.Join(group.BasePath(), urlPath),
 	}
 
+	route.noAuthentication = group.noAuthentication
+	route.noOrgRequired = group.noOrgRequired
+	route.rateLimiter = group.rateLimiter
+
+	if route.deprecatedSince != "" || route.removedIn != "" {
+		a.deprecations = append(a.deprecations, deprecatedRouteDefinition(routeID, route))
+	}
+
 	if !route.omitFromDocs {
 		a.register(openAPIRouteDefinition(routeID, route))
 	}
 
-	route.noAuthentication = group.noAuthentication
-	route.noOrgRequired = group.noOrgRequired
-
 	handler := func(c *gin.Context) {
 		if err := wrapRoute(a, routeID, route)(c); err != nil {
 			sendAPIError(c, err)
@@ -190,62 +319,332 @@ func add[Req, Res any](a *API, group *routeGroup, method, urlPath string, route
 // status code and response body built from the response type.
 func wrapRoute[Req, Res any](a *API, routeID routeIdentifier, route route[Req, Res]) func(*gin.Context) error {
 	return func(c *gin.Context) error {
+		if route.removedIn != "" {
+			return removedRouteError{version: route.removedIn, replacedBy: route.replacedBy}
+		}
+
+		if route.deprecatedSince != "" {
+			setDeprecationHeaders(c, route.sunset, route.replacedBy)
+		}
+
+		if route.maxBodyBytes > 0 {
+			limitRequestBody(c, route.maxBodyBytes)
+		}
+
 		if !route.infraVersionHeaderOptional {
-			if _, err := requestVersion(c.Request); err != nil {
+			if _, err := a.requestVersion(c.Request); err != nil {
 				return err
 			}
 		}
 
-		tx, err := a.server.db.Begin(c.Request.Context())
-		if err != nil {
-			return err
+		timeout := route.timeout
+		if timeout <= 0 {
+			timeout = defaultRequestTimeout
 		}
+		reqCtx := c.Request.Context()
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// the body is read again when the request is retried
+		var body []byte
+		if route.retryWriteConflicts && c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				return err
+			}
+		}
+
+		var (
+			resp       Res
+			idempotent *idempotentRequest
+			stored     *idempotencyEntry
+			attempt    int
+		)
 		defer func() {
-			if err := tx.Rollback(); err != nil {
-				logging.L.Error().Err(err).Msg("failed to rollback database transaction")
+			if idempotent != nil {
+				idempotent.release()
 			}
 		}()
 
-		if route.noAuthentication {
-			err = validateRequestOrganization(c, tx, a.server)
-		} else {
-			err = authenticateRequest(c, tx, a.server)
-		}
-		if err != nil {
-			return err
-		}
+		handle := func() error {
+			attempt++
+			if body != nil {
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			}
 
-		if !route.noOrgRequired {
-			if org := getRequestContext(c).Authenticated.Organization; org == nil {
-				return internal.ErrBadRequest
+			tx, err := a.server.db.Begin(c.Request.Context(), nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := tx.Rollback(); err != nil {
+					logging.Ctx(c.Request.Context()).Error().Err(err).Msg("failed to rollback database transaction")
+				}
+			}()
+
+			if route.noAuthentication {
+				err = validateRequestOrganization(c, tx, a.server)
+			} else {
+				err = authenticateRequest(c, tx, a.server)
+			}
+			if err != nil {
+				return err
 			}
-		}
 
-		req := new(Req)
-		if err := readRequest(c, req); err != nil {
-			return err
+			if route.rateLimiter != nil && attempt == 1 {
+				if err := route.rateLimiter.allow(route.rateLimiter.key(c)); err != nil {
+					return err
+				}
+			}
+
+			if !route.noOrgRequired {
+				if org := getRequestContext(c).Authenticated.Organization; org == nil {
+					return internal.ErrBadRequest
+				}
+			}
+
+			// the key is held by this request until it is released, so it
+			// is only checked by the first attempt.
+			if route.idempotent && !route.noAuthentication && attempt == 1 {
+				idempotent, stored, err = a.idempotency.begin(c, routeID)
+				if err != nil || stored != nil {
+					return err
+				}
+			}
+
+			req := new(Req)
+			if err := readRequest(c, req); err != nil {
+				return err
+			}
+
+			resp, err = route.handler(c, req)
+			if err != nil {
+				return err
+			}
+
+			// the handler may not have noticed the deadline, don't commit changes
+			// for a request that will be reported as timed out.
+			if err := ctx.Err(); errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+
+			return tx.Commit()
 		}
 
-		resp, err := route.handler(c, req)
+		var err error
+		if route.retryWriteConflicts {
+			err = data.RetryWriteConflicts(handle)
+		} else {
+			err = handle()
+		}
 		if err != nil {
 			return err
 		}
 
-		if err := tx.Commit(); err != nil {
-			return err
+		if stored != nil {
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(stored.status, stored.contentType, stored.body)
+			return nil
 		}
 
 		if !route.omitFromTelemetry {
 			a.t.RouteEvent(c, routeID.path, Properties{"method": strings.ToLower(routeID.method)})
 		}
 
+		if s, ok := any(resp).(streamResponse); ok {
+			// the stream is not limited by the request timeout, it continues
+			// until the client disconnects.
+			c.Request = c.Request.WithContext(reqCtx)
+			return s.writeStream(c)
+		}
+
 		if r, ok := responseIsRedirect(resp); ok {
 			c.Redirect(http.StatusPermanentRedirect, r.RedirectURL())
-		} else {
-			c.JSON(responseStatusCode(routeID.method, resp), resp)
+			return nil
+		}
+
+		status := responseStatusCode(routeID.method, resp)
+		if idempotent != nil && route.idempotencyResponse != nil {
+			storedStatus, storedResp := route.idempotencyResponse(resp)
+			body, err := json.Marshal(storedResp)
+			if err != nil {
+				return err
+			}
+			idempotent.complete(storedStatus, jsonContentType, body)
+			return writeResponse(c, status, resp)
 		}
+		if idempotent != nil {
+			respBody, contentType := negotiateResponseBody(c, resp)
+			body, err := json.Marshal(respBody)
+			if err != nil {
+				return err
+			}
+			idempotent.complete(status, contentType, body)
+			c.Data(status, contentType, body)
+			return nil
+		}
+		if route.cacheMaxAge > 0 && status == http.StatusOK {
+			setCacheHeaders(c, route.cacheMaxAge)
+		}
+		return writeResponse(c, status, resp)
+	}
+}
+
+// writeResponse writes resp as the JSON body of the response. Successful
+// responses to GET requests include a weak ETag computed from the body, or
+// for a resource with a resourceVersion, an ETag that can also be sent in the
+// If-Match header of an update. When the request has an If-None-Match header
+// that matches the ETag, the response is a 304 Not Modified with no body, so
+// that polling clients do not download the same response again.
+func writeResponse(c *gin.Context, status int, resp any) error {
+	respBody, contentType := negotiateResponseBody(c, resp)
+	body, err := json.Marshal(respBody)
+	if err != nil {
+		return err
+	}
+
+	if c.Request.Method != http.MethodGet || status != http.StatusOK {
+		c.Data(status, contentType, body)
+		return nil
+	}
+
+	etag := weakETag(body)
+	if version, ok := resourceVersion(resp); ok {
+		etag = resourceVersionETag(version, body)
+	}
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
 		return nil
 	}
+	c.Data(status, contentType, body)
+	return nil
+}
+
+const (
+	jsonContentType     = "application/json; charset=utf-8"
+	envelopeContentType = api.EnvelopeMediaType + "; charset=utf-8"
+)
+
+// envelope is implemented by responses that are not wrapped as a whole when
+// the request accepts api.EnvelopeMediaType, like api.ListResponse.
+type envelope interface {
+	Envelope() api.Envelope
+}
+
+// negotiateResponseBody returns the response body and its content type. The
+// body is resp, unless the Accept header of the request prefers
+// api.EnvelopeMediaType, in which case resp is wrapped in an api.Envelope.
+func negotiateResponseBody(c *gin.Context, resp any) (any, string) {
+	if c.NegotiateFormat(binding.MIMEJSON, api.EnvelopeMediaType) != api.EnvelopeMediaType {
+		return resp, jsonContentType
+	}
+	if e, ok := resp.(envelope); ok {
+		return e.Envelope(), envelopeContentType
+	}
+	return api.Envelope{Data: resp}, envelopeContentType
+}
+
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// resourceVersionETag returns an ETag that starts with the resource version,
+// so that ifMatchResourceVersion accepts it. The hash of the body is included
+// because fields like lastSeenAt change without changing the resource version.
+func resourceVersionETag(version int64, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.FormatInt(version, 10) + "-" + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// resourceVersion returns the resource version of resp, when resp is a
+// resource that may be updated with an If-Match header.
+func resourceVersion(resp any) (int64, bool) {
+	switch r := resp.(type) {
+	case *api.User:
+		return r.ResourceVersion, true
+	case *api.Destination:
+		return r.ResourceVersion, true
+	}
+	return 0, false
+}
+
+// etagMatches returns true if the value of an If-None-Match header matches
+// etag. As described by RFC 9110, the comparison is weak, so the W/ prefix
+// is ignored.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setCacheHeaders adds a Cache-Control header which allows the response to be
+// cached for maxAge. The organization of a request may come from the access
+// key, so the response varies by the Authorization header. The format of the
+// response varies by the Accept header.
+func setCacheHeaders(c *gin.Context, maxAge time.Duration) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	c.Writer.Header().Add("Vary", "Authorization")
+	c.Writer.Header().Add("Vary", "Accept")
+}
+
+// ifMatchResourceVersion returns the resource version from the If-Match header
+// of the request, or nil if the request does not have an If-Match header, or
+// the header is *, which matches any version of the resource.
+// The header may be the ETag from a GET of the resource, or the version, which
+// may be quoted like an entity tag.
+func ifMatchResourceVersion(c *gin.Context) (*int64, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	tag := strings.Trim(value, `"`)
+	if i := strings.Index(tag, "-"); i > 0 {
+		tag = tag[:i]
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: If-Match must be the ETag or the resourceVersion of the resource, not %q",
+			internal.ErrBadRequest, value)
+	}
+	return &version, nil
+}
+
+// setDeprecationHeaders adds the Deprecation, Sunset, and Link headers to the
+// response so that clients can detect the use of a deprecated route.
+func setDeprecationHeaders(c *gin.Context, sunset time.Time, replacedBy string) {
+	c.Header("Deprecation", "true")
+	if !sunset.IsZero() {
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	if replacedBy != "" {
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, replacedBy))
+	}
+}
+
+// removedRouteError is returned when a request is made to a route that has
+// been removed from the API.
+type removedRouteError struct {
+	version    string
+	replacedBy string
+}
+
+func (e removedRouteError) Error() string {
+	if e.replacedBy == "" {
+		return fmt.Sprintf("this endpoint was removed in version %v", e.version)
+	}
+	return fmt.Sprintf("this endpoint was removed in version %v, use %v instead", e.version, e.replacedBy)
 }
 
 type isRedirect interface {
@@ -257,11 +656,21 @@ func responseIsRedirect(resp interface{}) (isRedirect, bool) {
 	return r, ok
 }
 
+// streamResponse is implemented by responses that write to the client until
+// it disconnects, instead of writing a single JSON body.
+type streamResponse interface {
+	writeStream(c *gin.Context) error
+}
+
 type statusCoder interface {
 	StatusCode() int
 }
 
-var reflectTypeString = reflect.TypeOf("")
+var (
+	reflectTypeString      = reflect.TypeOf("")
+	reflectTypeStringSlice = reflect.TypeOf([]string{})
+	reflectTypeStringPtr   = reflect.TypeOf((*string)(nil))
+)
 
 // trimWhitespace trims leading and trailing whitespace from any string fields
 // in req. The req argument must be a non-nil pointer to a struct.
@@ -270,8 +679,17 @@ func trimWhitespace(req interface{}) {
 	if v.Kind() == reflect.Struct {
 		for i := 0; i < v.NumField(); i++ {
 			f := v.Field(i)
-			if f.Type() == reflectTypeString {
+			switch f.Type() {
+			case reflectTypeString:
 				f.SetString(strings.TrimSpace(f.String()))
+			case reflectTypeStringSlice:
+				for j := 0; j < f.Len(); j++ {
+					f.Index(j).SetString(strings.TrimSpace(f.Index(j).String()))
+				}
+			case reflectTypeStringPtr:
+				if !f.IsNil() {
+					f.Elem().SetString(strings.TrimSpace(f.Elem().String()))
+				}
 			}
 		}
 	}
@@ -301,7 +719,7 @@ func get[Req, Res any](a *API, r *routeGroup, path string, handler HandlerFunc[R
 }
 
 func post[Req, Res any](a *API, r *routeGroup, path string, handler HandlerFunc[Req, Res]) {
-	add(a, r, http.MethodPost, path, route[Req, Res]{handler: handler})
+	add(a, r, http.MethodPost, path, route[Req, Res]{handler: handler, idempotent: true})
 }
 
 func put[Req, Res any](a *API, r *routeGroup, path string, handler HandlerFunc[Req, Res]) {
@@ -316,14 +734,47 @@ func del[Req any, Res any](a *API, r *routeGroup, path string, handler HandlerFu
 	add(a, r, http.MethodDelete, path, route[Req, Res]{handler: handler})
 }
 
-func addDeprecated[Req, Res any](a *API, r *routeGroup, method string, path string, handler HandlerFunc[Req, Res]) {
+// addDeprecated adds a route which responds with a Deprecation header. since
+// is the version of infra that deprecated the route.
+func addDeprecated[Req, Res any](a *API, r *routeGroup, method string, path string, since string, handler HandlerFunc[Req, Res]) {
 	add(a, r, method, path, route[Req, Res]{
 		handler:           handler,
 		omitFromTelemetry: true,
 		omitFromDocs:      true,
+		deprecatedSince:   since,
 	})
 }
 
+// addRemoved adds a route which responds with 410 Gone. version is the version
+// of infra that removed the route, and replacedBy is the path of the route
+// that replaces it, if there is one.
+func addRemoved(a *API, r *routeGroup, method string, path string, version, replacedBy string) {
+	add(a, r, method, path, route[api.EmptyRequest, *api.EmptyResponse]{
+		omitFromTelemetry:          true,
+		omitFromDocs:               true,
+		infraVersionHeaderOptional: true,
+		removedIn:                  version,
+		replacedBy:                 replacedBy,
+	})
+}
+
+// deprecatedRouteDefinition describes a deprecated or removed route for the
+// ListDeprecatedRoutes endpoint.
+func deprecatedRouteDefinition[Req, Res any](routeID routeIdentifier, route route[Req, Res]) api.DeprecatedRoute {
+	result := api.DeprecatedRoute{
+		Method:       routeID.method,
+		Path:         routeID.path,
+		DeprecatedIn: route.deprecatedSince,
+		RemovedIn:    route.removedIn,
+		ReplacedBy:   route.replacedBy,
+	}
+	if !route.sunset.IsZero() {
+		sunset := api.Time(route.sunset)
+		result.Sunset = &sunset
+	}
+	return result
+}
+
 func readRequest(c *gin.Context, req interface{}) error {
 	if err := c.ShouldBindUri(req); err != nil {
 		return fmt.Errorf("%w: %s", internal.ErrBadRequest, err)
@@ -335,6 +786,10 @@ func readRequest(c *gin.Context, req interface{}) error {
 
 	if c.Request.Body != nil && c.Request.ContentLength > 0 {
 		if err := c.ShouldBindJSON(req); err != nil {
+			var bodyErr requestBodyTooLargeError
+			if errors.As(err, &bodyErr) {
+				return bodyErr
+			}
 			return fmt.Errorf("%w: %s", internal.ErrBadRequest, err)
 		}
 	}
@@ -353,13 +808,30 @@ func init() {
 	gin.DisableBindValidation()
 }
 
+// healthHandler is a liveness check. It always responds with 200 while the
+// server is running.
 func healthHandler(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// readyHandler is a readiness check. It responds with 503 when the database
+// can not be reached.
+func readyHandler(db *data.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		if err := db.Ping(ctx); err != nil {
+			logging.L.Warn().Err(err).Msg("readiness check failed")
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
 func (a *API) notFoundHandler(c *gin.Context) {
-	accept := c.Request.Header.Get("Accept")
-	if strings.HasPrefix(accept, "application/json") {
+	if acceptsJSON(c, false) {
 		sendAPIError(c, internal.ErrNotFound)
 		return
 	}
@@ -368,6 +840,6 @@ func (a *API) notFoundHandler(c *gin.Context) {
 
 	_, err := c.Writer.Write([]byte("404 not found"))
 	if err != nil {
-		logging.Errorf("%s", err.Error())
+		logging.Ctx(c.Request.Context()).Error().Msg(err.Error())
 	}
 }
@@ -253,6 +253,54 @@ func TestWrapRoute_HandleErrorOnCommit(t *testing.T) {
 	assert.Equal(t, resp.Code, http.StatusInternalServerError)
 }
 
+func TestWrapRoute_RetryWriteConflicts(t *testing.T) {
+	srv := newServer(Options{})
+	srv.db = setupDB(t)
+
+	router := gin.New()
+
+	var names []string
+	r := route[api.CreateGroupRequest, *api.EmptyResponse]{
+		handler: func(c *gin.Context, request *api.CreateGroupRequest) (*api.EmptyResponse, error) {
+			rCtx := getRequestContext(c)
+			names = append(names, request.Name)
+
+			user := &models.Identity{
+				Model:              models.Model{ID: 1556},
+				Name:               "retry@example.com",
+				OrganizationMember: models.OrganizationMember{OrganizationID: srv.db.DefaultOrg.ID},
+			}
+			// fails with a unique constraint error if the first attempt was
+			// not rolled back
+			if err := data.CreateIdentity(rCtx.DBTxn, user); err != nil {
+				return nil, err
+			}
+
+			if len(names) == 1 {
+				return nil, fmt.Errorf("create: %w", data.ErrWriteConflict)
+			}
+			return nil, nil
+		},
+		retryWriteConflicts:        true,
+		infraVersionHeaderOptional: true,
+		noAuthentication:           true,
+		noOrgRequired:              true,
+	}
+
+	api := &API{server: srv}
+	add(api, rg(router.Group("/")), "POST", "/do", r)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/do", strings.NewReader(`{"name":"retried"}`))
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+	assert.DeepEqual(t, names, []string{"retried", "retried"})
+
+	_, err := data.GetIdentity(srv.db, data.ByID(uid.ID(1556)))
+	assert.NilError(t, err)
+}
+
 func TestInfraVersionHeader(t *testing.T) {
 	srv := setupServer(t, withAdminUser)
 	routes := srv.GenerateRoutes()
@@ -275,4 +323,568 @@ func TestInfraVersionHeader(t *testing.T) {
 	assert.Assert(t, strings.Contains(respBody.Message, "Infra-Version header is required"), respBody.Message)
 }
 
+func TestInfraVersionHeader_Policy(t *testing.T) {
+	type testCase struct {
+		name            string
+		header          []string
+		expectedCode    int
+		expectedMessage string
+	}
+
+	run := func(t *testing.T, routes Routes, srv *Server, tc testCase) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		if tc.header != nil {
+			req.Header["Infra-Version"] = tc.header
+		}
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, tc.expectedCode, resp.Body.String())
+
+		if tc.expectedMessage != "" {
+			respBody := &api.Error{}
+			err := json.Unmarshal(resp.Body.Bytes(), respBody)
+			assert.NilError(t, err)
+			assert.Assert(t, strings.Contains(respBody.Message, tc.expectedMessage), respBody.Message)
+		}
+	}
+
+	t.Run("header required", func(t *testing.T) {
+		srv := setupServer(t, withAdminUser)
+		routes := srv.GenerateRoutes()
+
+		testCases := []testCase{
+			{
+				name:            "missing",
+				expectedCode:    http.StatusBadRequest,
+				expectedMessage: "Infra-Version header is required",
+			},
+			{
+				name:            "empty",
+				header:          []string{""},
+				expectedCode:    http.StatusBadRequest,
+				expectedMessage: "Infra-Version header is required",
+			},
+			{
+				name:            "malformed",
+				header:          []string{"not-a-version"},
+				expectedCode:    http.StatusBadRequest,
+				expectedMessage: "invalid Infra-Version header",
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				run(t, routes, srv, tc)
+			})
+		}
+	})
+
+	t.Run("default version", func(t *testing.T) {
+		srv := setupServer(t, withAdminUser, func(t *testing.T, opts *Options) {
+			opts.DefaultAPIVersion = apiVersionLatest
+		})
+		routes := srv.GenerateRoutes()
+
+		testCases := []testCase{
+			{
+				name:         "missing",
+				expectedCode: http.StatusOK,
+			},
+			{
+				name:         "empty",
+				header:       []string{""},
+				expectedCode: http.StatusOK,
+			},
+			{
+				name:            "malformed",
+				header:          []string{"not-a-version"},
+				expectedCode:    http.StatusBadRequest,
+				expectedMessage: "invalid Infra-Version header",
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				run(t, routes, srv, tc)
+			})
+		}
+	})
+}
+
 var apiVersionLatest = internal.FullVersion()
+
+func TestWrapRoute_DeprecatedRoute(t *testing.T) {
+	srv := setupServer(t)
+	routes := srv.GenerateRoutes()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/signup", nil)
+	req.Header.Set("Infra-Version", apiVersionLatest)
+
+	resp := httptest.NewRecorder()
+	routes.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+	assert.Equal(t, resp.Header().Get("Deprecation"), "true")
+	assert.Equal(t, resp.Header().Get("Sunset"), "")
+}
+
+func TestWrapRoute_DeprecatedRouteWithSunset(t *testing.T) {
+	srv := setupServer(t)
+	router := gin.New()
+
+	sunset := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	r := route[api.EmptyRequest, *api.EmptyResponse]{
+		handler: func(c *gin.Context, request *api.EmptyRequest) (*api.EmptyResponse, error) {
+			return nil, nil
+		},
+		infraVersionHeaderOptional: true,
+		deprecatedSince:            "0.16.0",
+		sunset:                     sunset,
+		replacedBy:                 "/api/new",
+	}
+
+	a := &API{server: srv}
+	add(a, rg(router.Group("/")), "GET", "/old", r)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/old", nil)
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+	assert.Equal(t, resp.Header().Get("Deprecation"), "true")
+	assert.Equal(t, resp.Header().Get("Sunset"), "Wed, 01 Mar 2023 00:00:00 GMT")
+	assert.Equal(t, resp.Header().Get("Link"), `</api/new>; rel="successor-version"`)
+}
+
+func TestWrapRoute_CacheMaxAge(t *testing.T) {
+	srv := setupServer(t)
+	router := gin.New()
+
+	r := route[api.EmptyRequest, *api.Version]{
+		handler: func(c *gin.Context, request *api.EmptyRequest) (*api.Version, error) {
+			return &api.Version{Version: "0.14.0"}, nil
+		},
+		infraVersionHeaderOptional: true,
+		cacheMaxAge:                5 * time.Minute,
+	}
+
+	a := &API{server: srv}
+	add(a, rg(router.Group("/")), "GET", "/cached", r)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/cached", nil)
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+	assert.Equal(t, resp.Header().Get("Cache-Control"), "public, max-age=300")
+	assert.DeepEqual(t, resp.Header().Values("Vary"), []string{"Authorization", "Accept"})
+
+	etag := resp.Header().Get("ETag")
+	assert.Assert(t, etag != "")
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/cached", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusNotModified)
+	assert.Equal(t, resp.Header().Get("Cache-Control"), "public, max-age=300")
+}
+
+func TestWrapRoute_Timeout(t *testing.T) {
+	srv := setupServer(t)
+	router := gin.New()
+
+	r := route[api.EmptyRequest, *api.EmptyResponse]{
+		handler: func(c *gin.Context, request *api.EmptyRequest) (*api.EmptyResponse, error) {
+			rCtx := getRequestContext(c)
+			err := data.CreateGroup(rCtx.DBTxn, &models.Group{Name: "slow"})
+			assert.Check(t, err)
+
+			time.Sleep(100 * time.Millisecond)
+			return nil, nil
+		},
+		infraVersionHeaderOptional: true,
+		timeout:                    20 * time.Millisecond,
+	}
+
+	a := &API{server: srv}
+	add(a, rg(router.Group("/")), "POST", "/slow", r)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/slow", nil)
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusGatewayTimeout, resp.Body.String())
+
+	var apiErr api.Error
+	err := json.NewDecoder(resp.Body).Decode(&apiErr)
+	assert.NilError(t, err)
+	assert.Equal(t, apiErr.ErrorCode, api.ErrorCodeTimeout)
+
+	// the transaction was rolled back
+	_, err = data.GetGroup(srv.db, data.ByName("slow"))
+	assert.ErrorIs(t, err, internal.ErrNotFound)
+}
+
+func TestWrapRoute_TimeoutNotExceeded(t *testing.T) {
+	srv := setupServer(t)
+	router := gin.New()
+
+	var deadline time.Time
+	r := route[api.EmptyRequest, *api.EmptyResponse]{
+		handler: func(c *gin.Context, request *api.EmptyRequest) (*api.EmptyResponse, error) {
+			deadline, _ = c.Request.Context().Deadline()
+			return nil, nil
+		},
+		infraVersionHeaderOptional: true,
+		timeout:                    5 * time.Second,
+	}
+
+	a := &API{server: srv}
+	add(a, rg(router.Group("/")), "POST", "/fast", r)
+
+	before := time.Now()
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/fast", nil)
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusCreated, resp.Body.String())
+	assert.Assert(t, deadline.After(before.Add(4*time.Second)), deadline)
+	assert.Assert(t, deadline.Before(before.Add(defaultRequestTimeout)), deadline)
+}
+
+func TestWriteResponse_ETag(t *testing.T) {
+	type testCase struct {
+		name         string
+		method       string
+		status       int
+		ifNoneMatch  string
+		expectedCode int
+		expectETag   bool
+	}
+
+	resp := &api.Version{Version: "0.14.0"}
+	body, err := json.Marshal(resp)
+	assert.NilError(t, err)
+	etag := weakETag(body)
+
+	run := func(t *testing.T, tc testCase) {
+		w := httptest.NewRecorder()
+		c, router := gin.CreateTestContext(w)
+		router.Handle(tc.method, "/", func(c *gin.Context) {
+			assert.NilError(t, writeResponse(c, tc.status, resp))
+		})
+
+		c.Request = httptest.NewRequest(tc.method, "/", nil)
+		if tc.ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		router.HandleContext(c)
+
+		assert.Equal(t, w.Code, tc.expectedCode)
+		if tc.expectETag {
+			assert.Equal(t, w.Header().Get("ETag"), etag)
+		} else {
+			assert.Equal(t, w.Header().Get("ETag"), "")
+		}
+		if tc.expectedCode == http.StatusNotModified {
+			assert.Equal(t, w.Body.Len(), 0)
+		} else {
+			assert.Equal(t, w.Body.String(), string(body))
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name:         "GET without If-None-Match",
+			method:       http.MethodGet,
+			status:       http.StatusOK,
+			expectedCode: http.StatusOK,
+			expectETag:   true,
+		},
+		{
+			name:         "GET with matching ETag",
+			method:       http.MethodGet,
+			status:       http.StatusOK,
+			ifNoneMatch:  etag,
+			expectedCode: http.StatusNotModified,
+			expectETag:   true,
+		},
+		{
+			name:         "GET with one of many matching",
+			method:       http.MethodGet,
+			status:       http.StatusOK,
+			ifNoneMatch:  `W/"other", ` + strings.TrimPrefix(etag, "W/"),
+			expectedCode: http.StatusNotModified,
+			expectETag:   true,
+		},
+		{
+			name:         "GET with a different ETag",
+			method:       http.MethodGet,
+			status:       http.StatusOK,
+			ifNoneMatch:  `W/"other"`,
+			expectedCode: http.StatusOK,
+			expectETag:   true,
+		},
+		{
+			name:         "POST is not conditional",
+			method:       http.MethodPost,
+			status:       http.StatusCreated,
+			ifNoneMatch:  etag,
+			expectedCode: http.StatusCreated,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+
+	t.Run("resource with a resourceVersion", func(t *testing.T) {
+		user := &api.User{Name: "user@example.com", ResourceVersion: 4}
+		body, err := json.Marshal(user)
+		assert.NilError(t, err)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.NilError(t, writeResponse(c, http.StatusOK, user))
+
+		etag := w.Header().Get("ETag")
+		assert.Equal(t, etag, resourceVersionETag(4, body))
+		assert.Assert(t, strings.HasPrefix(etag, `"4-`), etag)
+	})
+}
+
+func TestAPI_ConditionalGet(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	get := func(t *testing.T, etag string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/grants", nil)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	first := get(t, "")
+	assert.Equal(t, first.Code, http.StatusOK, first.Body.String())
+	etag := first.Header().Get("ETag")
+	assert.Assert(t, strings.HasPrefix(etag, `W/"`), etag)
+
+	second := get(t, etag)
+	assert.Equal(t, second.Code, http.StatusNotModified, second.Body.String())
+	assert.Equal(t, second.Body.Len(), 0)
+
+	// a change to the grants changes the ETag
+	err := data.CreateGrant(srv.DB(), &models.Grant{
+		Subject:   uid.NewIdentityPolymorphicID(uid.New()),
+		Privilege: "view",
+		Resource:  "example",
+	})
+	assert.NilError(t, err)
+
+	third := get(t, etag)
+	assert.Equal(t, third.Code, http.StatusOK, third.Body.String())
+	assert.Assert(t, third.Header().Get("ETag") != etag)
+}
+
+func TestAPI_ListDeprecatedRoutes(t *testing.T) {
+	t.Run("deprecated and removed routes", func(t *testing.T) {
+		srv := newServer(Options{})
+		router := gin.New()
+
+		a := &API{server: srv}
+		group := rg(router.Group("/"))
+		addRemoved(a, group, "GET", "/v1/old", "0.16.0", "/api/new")
+		addRemoved(a, group, "POST", "/v1/old", "0.16.0", "")
+		add(a, group, "GET", "/api/old", route[api.EmptyRequest, *api.EmptyResponse]{
+			handler: func(c *gin.Context, _ *api.EmptyRequest) (*api.EmptyResponse, error) {
+				return nil, nil
+			},
+			deprecatedSince: "0.15.0",
+			sunset:          time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+			replacedBy:      "/api/new",
+		})
+		get(a, group, "/api/current", func(c *gin.Context, _ *api.EmptyRequest) (*api.EmptyResponse, error) {
+			return nil, nil
+		})
+
+		resp, err := a.ListDeprecatedRoutes(nil, &api.EmptyRequest{})
+		assert.NilError(t, err)
+
+		sunset := api.Time(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC))
+		expected := []api.DeprecatedRoute{
+			{Method: "GET", Path: "/v1/old", RemovedIn: "0.16.0", ReplacedBy: "/api/new"},
+			{Method: "POST", Path: "/v1/old", RemovedIn: "0.16.0"},
+			{Method: "GET", Path: "/api/old", DeprecatedIn: "0.15.0", Sunset: &sunset, ReplacedBy: "/api/new"},
+		}
+		assert.DeepEqual(t, resp.Items, expected)
+		assert.Equal(t, resp.Count, 3)
+	})
+
+	t.Run("routes from GenerateRoutes", func(t *testing.T) {
+		srv := setupServer(t)
+		routes := srv.GenerateRoutes()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/version/deprecations", nil)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		respBody := &api.ListResponse[api.DeprecatedRoute]{}
+		err := json.Unmarshal(resp.Body.Bytes(), respBody)
+		assert.NilError(t, err)
+
+		var signup *api.DeprecatedRoute
+		var machines []api.DeprecatedRoute
+		for i, item := range respBody.Items {
+			switch item.Path {
+			case "/api/signup":
+				signup = &respBody.Items[i]
+			case "/v1/machines":
+				machines = append(machines, item)
+			}
+		}
+		expected := &api.DeprecatedRoute{Method: "GET", Path: "/api/signup", DeprecatedIn: "0.14.4"}
+		assert.DeepEqual(t, signup, expected)
+
+		expectedMachines := []api.DeprecatedRoute{
+			{Method: "GET", Path: "/v1/machines", RemovedIn: "0.9.0", ReplacedBy: "/api/users"},
+			{Method: "POST", Path: "/v1/machines", RemovedIn: "0.9.0", ReplacedBy: "/api/users"},
+		}
+		assert.DeepEqual(t, machines, expectedMachines)
+	})
+}
+
+func TestWrapRoute_RemovedRoute(t *testing.T) {
+	srv := newServer(Options{})
+	router := gin.New()
+
+	a := &API{server: srv}
+	addRemoved(a, rg(router.Group("/")), "GET", "/old", "0.16.0", "/api/new")
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/old", nil)
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusGone, resp.Body.String())
+
+	respBody := &api.Error{}
+	err := json.Unmarshal(resp.Body.Bytes(), respBody)
+	assert.NilError(t, err)
+
+	expected := &api.Error{
+		Code:      http.StatusGone,
+		ErrorCode: api.ErrorCodeGone,
+		Message:   "this endpoint was removed in version 0.16.0, use /api/new instead",
+	}
+	assert.DeepEqual(t, respBody, expected)
+}
+
+func TestAPI_RemovedRoutes(t *testing.T) {
+	srv := setupServer(t)
+	routes := srv.GenerateRoutes()
+
+	run := func(method, path string) *api.Error {
+		// removed routes do not require authentication or an Infra-Version header
+		req := httptest.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusGone, resp.Body.String())
+
+		respBody := &api.Error{}
+		err := json.Unmarshal(resp.Body.Bytes(), respBody)
+		assert.NilError(t, err)
+		return respBody
+	}
+
+	respBody := run(http.MethodGet, "/v1/machines")
+	assert.Equal(t, respBody.Message, "this endpoint was removed in version 0.9.0, use /api/users instead")
+
+	respBody = run(http.MethodPost, "/v1/users")
+	assert.Equal(t, respBody.Message, "this endpoint was removed in version 0.9.0, use /api/users instead")
+
+	respBody = run(http.MethodGet, "/v1/introspect")
+	assert.Equal(t, respBody.Message, "this endpoint was removed in version 0.12.0, use /api/users/self instead")
+}
+
+func TestIfMatchResourceVersion(t *testing.T) {
+	run := func(header string) (*int64, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+		if header != "" {
+			c.Request.Header.Set("If-Match", header)
+		}
+		return ifMatchResourceVersion(c)
+	}
+
+	version, err := run("")
+	assert.NilError(t, err)
+	assert.Assert(t, version == nil)
+
+	// * matches any version
+	version, err = run("*")
+	assert.NilError(t, err)
+	assert.Assert(t, version == nil)
+
+	version, err = run("12")
+	assert.NilError(t, err)
+	assert.Equal(t, *version, int64(12))
+
+	version, err = run(`"3"`)
+	assert.NilError(t, err)
+	assert.Equal(t, *version, int64(3))
+
+	version, err = run(resourceVersionETag(7, []byte(`{"name":"a"}`)))
+	assert.NilError(t, err)
+	assert.Equal(t, *version, int64(7))
+
+	_, err = run(`W/"a1b2c3"`)
+	assert.ErrorIs(t, err, internal.ErrBadRequest)
+}
+
+func TestNegotiateResponseBody(t *testing.T) {
+	run := func(accept string, resp any) (any, string) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			c.Request.Header.Set("Accept", accept)
+		}
+		return negotiateResponseBody(c, resp)
+	}
+
+	version := &api.Version{Version: "0.14.0"}
+	list := &api.ListResponse[string]{
+		Items:              []string{"one"},
+		Count:              1,
+		PaginationResponse: api.PaginationResponse{Page: 1, Limit: 10, TotalPages: 1, TotalCount: 1},
+	}
+
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		body, contentType := run(accept, version)
+		assert.Equal(t, contentType, jsonContentType)
+		assert.Equal(t, body, any(version))
+	}
+
+	body, contentType := run(api.EnvelopeMediaType, version)
+	assert.Equal(t, contentType, envelopeContentType)
+	assert.DeepEqual(t, body, api.Envelope{Data: version})
+
+	body, _ = run(api.EnvelopeMediaType, list)
+	expected := api.Envelope{
+		Data: []string{"one"},
+		Meta: api.EnvelopeMeta{
+			Pagination: &api.PaginationResponse{Page: 1, Limit: 10, TotalPages: 1, TotalCount: 1},
+		},
+	}
+	assert.DeepEqual(t, body, expected)
+}
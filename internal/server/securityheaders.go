@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	headerStrictTransportSecurity = "Strict-Transport-Security"
+	headerContentTypeOptions      = "X-Content-Type-Options"
+	headerFrameOptions            = "X-Frame-Options"
+	headerContentSecurityPolicy   = "Content-Security-Policy"
+)
+
+// SecurityHeadersOptions configure the security headers added to responses
+// from the API and the UI. Responses proxied from the UI keep the headers set
+// by the UI, and only the headers that are missing are added. A header is not
+// sent when its value is empty.
+type SecurityHeadersOptions struct {
+	// StrictTransportSecurity is the value of the Strict-Transport-Security
+	// header, which tells browsers to only connect to the server with HTTPS.
+	// It is only sent in responses to requests made with TLS.
+	StrictTransportSecurity string
+	// ContentTypeOptions is the value of the X-Content-Type-Options header.
+	ContentTypeOptions string
+	// FrameOptions is the value of the X-Frame-Options header, which controls
+	// if the UI may be embedded in a frame on another page.
+	FrameOptions string
+	// ContentSecurityPolicy is the value of the Content-Security-Policy
+	// header.
+	ContentSecurityPolicy string
+}
+
+// DefaultSecurityHeadersOptions returns the security headers used when the
+// server config does not set them.
+func DefaultSecurityHeadersOptions() SecurityHeadersOptions {
+	return SecurityHeadersOptions{
+		StrictTransportSecurity: "max-age=31536000",
+		ContentTypeOptions:      "nosniff",
+		FrameOptions:            "DENY",
+		ContentSecurityPolicy:   "default-src 'self'",
+	}
+}
+
+// headers returns the headers with a value, by name.
+func (o SecurityHeadersOptions) headers() map[string]string {
+	headers := map[string]string{}
+	for name, value := range map[string]string{
+		headerStrictTransportSecurity: o.StrictTransportSecurity,
+		headerContentTypeOptions:      o.ContentTypeOptions,
+		headerFrameOptions:            o.FrameOptions,
+		headerContentSecurityPolicy:   o.ContentSecurityPolicy,
+	} {
+		if value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// SecurityHeadersMiddleware adds the security headers from opts to the
+// response.
+func SecurityHeadersMiddleware(opts SecurityHeadersOptions) gin.HandlerFunc {
+	headers := opts.headers()
+	return func(c *gin.Context) {
+		setSecurityHeaders(c.Writer.Header(), headers, c.Request.TLS != nil, true)
+		c.Next()
+	}
+}
+
+// setSecurityHeaders sets headers in h. When overwrite is false, a header that
+// already has a value in h is not changed. Strict-Transport-Security is only
+// set when the request was made with TLS.
+func setSecurityHeaders(h http.Header, headers map[string]string, isTLS bool, overwrite bool) {
+	for name, value := range headers {
+		if name == headerStrictTransportSecurity && !isTLS {
+			continue
+		}
+		if !overwrite && h.Get(name) != "" {
+			continue
+		}
+		h.Set(name, value)
+	}
+}
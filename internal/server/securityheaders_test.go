@@ -0,0 +1,105 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gotest.tools/v3/assert"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	run := func(t *testing.T, opts SecurityHeadersOptions) http.Header {
+		t.Helper()
+		router := gin.New()
+		router.Use(SecurityHeadersMiddleware(opts))
+		router.GET("/api/version", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "https://example.com/api/version", nil))
+		assert.Equal(t, resp.Code, http.StatusOK)
+		return resp.Header()
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		header := run(t, DefaultSecurityHeadersOptions())
+		assert.Equal(t, header.Get("Strict-Transport-Security"), "max-age=31536000")
+		assert.Equal(t, header.Get("X-Content-Type-Options"), "nosniff")
+		assert.Equal(t, header.Get("X-Frame-Options"), "DENY")
+		assert.Equal(t, header.Get("Content-Security-Policy"), "default-src 'self'")
+	})
+
+	t.Run("overridden", func(t *testing.T) {
+		opts := DefaultSecurityHeadersOptions()
+		opts.FrameOptions = "SAMEORIGIN"
+		opts.ContentSecurityPolicy = "default-src 'self'; img-src *"
+		opts.StrictTransportSecurity = ""
+
+		header := run(t, opts)
+		assert.Equal(t, header.Get("X-Frame-Options"), "SAMEORIGIN")
+		assert.Equal(t, header.Get("Content-Security-Policy"), "default-src 'self'; img-src *")
+		assert.Equal(t, header.Get("X-Content-Type-Options"), "nosniff")
+		_, ok := header["Strict-Transport-Security"]
+		assert.Assert(t, !ok, "header with an empty value should not be sent")
+	})
+
+	t.Run("no Strict-Transport-Security without TLS", func(t *testing.T) {
+		router := gin.New()
+		router.Use(SecurityHeadersMiddleware(DefaultSecurityHeadersOptions()))
+		router.GET("/api/version", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com/api/version", nil))
+		_, ok := resp.Header()["Strict-Transport-Security"]
+		assert.Assert(t, !ok)
+		assert.Equal(t, resp.Header().Get("X-Frame-Options"), "DENY")
+	})
+}
+
+func TestSecurityHeadersMiddleware_UIProxy(t *testing.T) {
+	uiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	t.Cleanup(uiSrv.Close)
+
+	srv := setupServer(t, func(t *testing.T, opts *Options) {
+		assert.NilError(t, opts.UI.ProxyURL.Set(uiSrv.URL))
+		opts.SecurityHeaders = DefaultSecurityHeadersOptions()
+	})
+
+	// the reverse proxy requires a ResponseWriter that implements CloseNotifier
+	httpSrv := httptest.NewServer(srv.GenerateRoutes())
+	t.Cleanup(httpSrv.Close)
+
+	// nolint:noctx
+	resp, err := http.Get(httpSrv.URL + "/destinations")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, string(body), "<html></html>")
+	// the headers from the UI are not changed
+	assert.DeepEqual(t, resp.Header.Values("X-Frame-Options"), []string{"SAMEORIGIN"})
+	// missing headers are added
+	assert.DeepEqual(t, resp.Header.Values("Content-Security-Policy"), []string{"default-src 'self'"})
+	assert.DeepEqual(t, resp.Header.Values("X-Content-Type-Options"), []string{"nosniff"})
+	_, ok := resp.Header["Strict-Transport-Security"]
+	assert.Assert(t, !ok, "Strict-Transport-Security should not be sent without TLS")
+
+	// nolint:noctx
+	resp, err = http.Get(httpSrv.URL + "/api/version")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, resp.Header.Get("X-Frame-Options"), "DENY")
+}
@@ -9,8 +9,10 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/gin-gonic/gin"
 	"github.com/infrahq/secrets"
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,12 +21,16 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/infrahq/infra/internal"
+	"github.com/infrahq/infra/internal/access"
 	"github.com/infrahq/infra/internal/cmd/types"
 	"github.com/infrahq/infra/internal/ginutil"
 	"github.com/infrahq/infra/internal/logging"
 	"github.com/infrahq/infra/internal/repeat"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/email"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/server/providers"
+	"github.com/infrahq/infra/internal/server/webhook"
 	"github.com/infrahq/infra/metrics"
 )
 
@@ -40,14 +46,111 @@ type Options struct {
 	// support admin).
 	EnableSignup bool
 
+	// DisableSignup stops new signups on a server with EnableSignup, without
+	// disabling multi-tenancy or the organizations that already exist. Use
+	// SignupDisabledMessage to tell users why signup is disabled.
+	DisableSignup bool
+
+	// SignupDisabledMessage is returned to users when DisableSignup is set.
+	// Defaults to "signup is disabled".
+	SignupDisabledMessage string
+
 	// EnableLogSampling indicates whether or not to sample HTTP access logs.
 	// When true, non-error HTTP GET logs will sampled down to 1 every 7 seconds
 	// grouped by the request path.
 	EnableLogSampling bool
 
+	// LogSampling drops identical debug and info log messages after a number
+	// of them are logged in a period. Unlike EnableLogSampling, this applies
+	// to all logs, not only HTTP access logs. Disabled by default.
+	LogSampling logging.SamplingOptions
+
+	// LogFile writes logs to a file that is rotated, instead of to stderr.
+	LogFile logging.FileOptions
+
+	// LogRedactionPatterns are regular expressions that match sensitive values
+	// to remove from logs, in addition to logging.DefaultRedactionPatterns.
+	LogRedactionPatterns []string
+
 	SessionDuration          time.Duration
 	SessionExtensionDeadline time.Duration
 
+	// MaxAccessKeyTTL is the largest value an organization may use as the
+	// default lifetime of its access keys. Zero means there is no limit.
+	MaxAccessKeyTTL time.Duration
+
+	// MaxRequestBodyBytes is the largest request body the API will accept.
+	// Requests with a larger body receive a 413 response.
+	MaxRequestBodyBytes int64
+
+	// AuthRateLimit limits the number of requests to the login and token
+	// endpoints made by each identity or client IP address.
+	AuthRateLimit RateLimitOptions
+
+	// AuthorizationCacheTTL is the amount of time the result of an
+	// authorization check is cached. Zero disables the cache.
+	AuthorizationCacheTTL time.Duration
+
+	// EnablePprof registers the /api/debug/pprof routes, which serve runtime
+	// profiles to users with the support-admin role. When false the routes
+	// are not registered and respond with a 404.
+	EnablePprof bool
+
+	// DefaultAPIVersion is the API version used for requests that do not
+	// include an Infra-Version header, or include an empty one. When empty,
+	// the header is required and requests without it fail with a 400.
+	DefaultAPIVersion string
+
+	// AccessKeyExtensionInterval is the minimum amount the extension deadline
+	// of an access key must advance before it is written to the database.
+	// Zero uses the default of 1 minute.
+	AccessKeyExtensionInterval time.Duration
+
+	// LoginLockout locks login with the password of a user after too many
+	// failed attempts.
+	LoginLockout LoginLockoutOptions
+
+	// AccessKeyChecksum configures the keys used to compute the checksum of
+	// access key secrets stored in the database.
+	AccessKeyChecksum AccessKeyChecksumOptions
+
+	// ProviderRequestTimeout is the amount of time to wait for a response to
+	// each request made to an identity provider. Requests that fail with a
+	// transient error are retried. Zero uses the default of 10 seconds.
+	ProviderRequestTimeout time.Duration
+
+	// ProviderDiscoveryCacheTTL is the amount of time the OpenID discovery
+	// document of an identity provider is cached. Zero uses the default of 10
+	// minutes.
+	ProviderDiscoveryCacheTTL time.Duration
+
+	// ShutdownGracePeriod is the maximum amount of time to wait for in-flight
+	// requests to complete when the server is shutting down. Connections that
+	// are still active after the grace period are closed. Zero waits without a
+	// limit.
+	ShutdownGracePeriod time.Duration
+
+	// DryRun logs the changes that loading Config would make to the database,
+	// and rolls them back instead of applying them. New returns the server
+	// without listening, and the server should not be run. Database migrations
+	// are not applied, and New returns an error when there are migrations
+	// that have not been applied.
+	DryRun bool
+
+	// Cookie configures the cookies used to authenticate requests from a
+	// browser.
+	Cookie CookieOptions
+
+	// CORS configures the origins of browser apps that may call the API.
+	CORS CORSOptions
+
+	// SecurityHeaders configures the security headers added to responses.
+	SecurityHeaders SecurityHeadersOptions
+
+	// Webhook configures the endpoint that receives an event every time an
+	// access key or a grant is created or deleted.
+	Webhook webhook.Options
+
 	DBEncryptionKey         string
 	DBEncryptionKeyProvider string
 	DBHost                  string
@@ -103,6 +206,20 @@ type TLSOptions struct {
 	ACME bool
 }
 
+type AccessKeyChecksumOptions struct {
+	// Keys are references to the secret keys of the HMAC used as the checksum
+	// of access key secrets, for example env:INFRA_CHECKSUM_KEY. The first key
+	// is used for new checksums, the others are only used to validate existing
+	// checksums. To rotate a key add the new key to the front of the list, and
+	// remove the old key once every access key in use has been migrated. When
+	// empty, the checksum is an unkeyed SHA-256 hash.
+	Keys []string
+	// DisableLegacy rejects access keys that still have an unkeyed SHA-256
+	// checksum. Access keys are migrated to a keyed checksum the first time
+	// they are used, so set this once the migration window has passed.
+	DisableLegacy bool
+}
+
 type Server struct {
 	options         Options
 	db              *data.DB
@@ -112,6 +229,9 @@ type Server struct {
 	Addrs           Addrs
 	routines        []routine
 	metricsRegistry *prometheus.Registry
+	authnMetrics    *authnMetrics
+	webhooks        *webhook.Sender
+	events          *eventBroker
 }
 
 type Addrs struct {
@@ -126,6 +246,7 @@ func newServer(options Options) *Server {
 		options: options,
 		secrets: map[string]secrets.SecretStorage{},
 		keys:    map[string]secrets.SymmetricKeyProvider{},
+		events:  newEventBroker(),
 	}
 }
 
@@ -135,7 +256,37 @@ func New(options Options) (*Server, error) {
 		return nil, errors.New("cannot enable signup without setting base domain")
 	}
 
+	if options.Cookie.HostPrefix && options.BaseDomain != "" {
+		return nil, errors.New("cannot use the __Host- cookie prefix with a base domain, because the signup cookie is set on the base domain")
+	}
+
+	if err := options.Cookie.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := options.CORS.validate(options.Cookie); err != nil {
+		return nil, err
+	}
+
+	if err := options.Webhook.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := options.AuthRateLimit.validate(); err != nil {
+		return nil, fmt.Errorf("auth rate limit: %w", err)
+	}
+
+	if options.DefaultAPIVersion != "" {
+		if _, err := semver.NewVersion(options.DefaultAPIVersion); err != nil {
+			return nil, fmt.Errorf("invalid default API version %q: %w", options.DefaultAPIVersion, err)
+		}
+	}
+
 	server := newServer(options)
+	access.SetAuthorizationCacheTTL(options.AuthorizationCacheTTL)
+	data.SetAccessKeyExtensionInterval(options.AccessKeyExtensionInterval)
+	providers.SetRequestTimeout(options.ProviderRequestTimeout)
+	providers.SetDiscoveryCacheTTL(options.ProviderDiscoveryCacheTTL)
 
 	if err := importSecrets(options.Secrets, server.secrets); err != nil {
 		return nil, fmt.Errorf("secrets config: %w", err)
@@ -145,6 +296,10 @@ func New(options Options) (*Server, error) {
 		return nil, fmt.Errorf("key config: %w", err)
 	}
 
+	if err := loadAccessKeyChecksumKeys(options.AccessKeyChecksum, server.secrets); err != nil {
+		return nil, fmt.Errorf("access key checksum: %w", err)
+	}
+
 	driver, err := getDatabaseDriver(options, server.secrets)
 	if err != nil {
 		return nil, fmt.Errorf("driver: %w", err)
@@ -154,15 +309,21 @@ func New(options Options) (*Server, error) {
 	if !ok {
 		return nil, fmt.Errorf("key provider %s not configured", options.DBEncryptionKeyProvider)
 	}
+	// a dry run must not change the database, so it does not run migrations
 	db, err := data.NewDB(driver, data.NewDBOptions{
 		EncryptionKeyProvider: dbKeyProvider,
 		RootKeyID:             options.DBEncryptionKey,
+		NoMigrate:             options.DryRun,
 	})
-	if err != nil {
+	switch {
+	case errors.Is(err, data.ErrMigrationsPending):
+		return nil, fmt.Errorf("db: %w, start the server without --dry-run to apply them", err)
+	case err != nil:
 		return nil, fmt.Errorf("db: %w", err)
 	}
 	server.db = db
 	server.metricsRegistry = setupMetrics(server.db)
+	server.setupAuditEvents()
 
 	if options.EnableTelemetry {
 		server.tel = NewTelemetry(server.DB(), db.DefaultOrgSettings.ID)
@@ -172,6 +333,10 @@ func New(options Options) (*Server, error) {
 		return nil, fmt.Errorf("configs: %w", err)
 	}
 
+	if options.DryRun {
+		return server, nil
+	}
+
 	if err := server.listen(); err != nil {
 		return nil, fmt.Errorf("listening: %w", err)
 	}
@@ -194,6 +359,12 @@ func (s *Server) Run(ctx context.Context) error {
 		})
 	}
 
+	repeat.Start(ctx, deleteExpiredGrantsInterval, s.deleteExpiredGrants)
+
+	if s.webhooks != nil {
+		go s.webhooks.Run(ctx)
+	}
+
 	group, _ := errgroup.WithContext(ctx)
 	for i := range s.routines {
 		group.Go(s.routines[i].run)
@@ -203,8 +374,8 @@ func (s *Server) Run(ctx context.Context) error {
 		internal.FullVersion(), s.Addrs.HTTP, s.Addrs.HTTPS, s.Addrs.Metrics)
 
 	<-ctx.Done()
-	for i := range s.routines {
-		s.routines[i].stop()
+	if err := s.Shutdown(context.Background()); err != nil {
+		logging.L.Warn().Err(err).Msg("failed to shutdown gracefully")
 	}
 
 	err := group.Wait()
@@ -220,7 +391,77 @@ func (s *Server) Run(ctx context.Context) error {
 	return err
 }
 
-func registerUIRoutes(router *gin.Engine, opts UIOptions) {
+// setupAuditEvents sends audit events to the open event streams, and to the
+// webhook endpoint when one is configured.
+func (s *Server) setupAuditEvents() {
+	if s.options.Webhook.URL != "" {
+		s.webhooks = webhook.NewSender(s.options.Webhook)
+	}
+	s.db.OnAuditEvent = func(event models.AuditEvent) {
+		s.events.publish(event)
+		if s.webhooks != nil {
+			s.webhooks.Send(event)
+		}
+	}
+}
+
+// deleteExpiredGrantsInterval is the time between each removal of expired grants.
+const deleteExpiredGrantsInterval = time.Minute
+
+func (s *Server) deleteExpiredGrants(context.Context) {
+	count, err := data.DeleteExpiredGrants(s.db)
+	if err != nil {
+		logging.L.Warn().Err(err).Msg("failed to delete expired grants")
+		return
+	}
+	if count > 0 {
+		logging.Debugf("deleted %d expired grants", count)
+	}
+}
+
+// Shutdown stops the server from accepting new connections, and waits for
+// in-flight requests to complete. Shutdown waits for at most
+// Options.ShutdownGracePeriod, or until ctx is done, and then closes any
+// connections that remain.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.options.ShutdownGracePeriod > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.ShutdownGracePeriod)
+		defer cancel()
+	}
+
+	logging.Infof("shutting down, waiting for in-flight requests to complete")
+
+	// event streams never complete, end them so that their connections can
+	// be closed
+	s.events.close()
+
+	// stop the servers concurrently, so that each of them has the full grace
+	// period to complete in-flight requests
+	results := make([]error, len(s.routines))
+	var wg sync.WaitGroup
+	for i := range s.routines {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = s.routines[i].stop(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d servers were closed before in-flight requests completed: %w", len(errs), errs[0])
+	}
+	return nil
+}
+
+func registerUIRoutes(router *gin.Engine, opts UIOptions, securityHeaders SecurityHeadersOptions) {
 	if opts.ProxyURL.Host != "" {
 		remote := opts.ProxyURL.Value()
 		proxy := httputil.NewSingleHostReverseProxy(remote)
@@ -229,6 +470,13 @@ func registerUIRoutes(router *gin.Engine, opts UIOptions) {
 			req.URL.Scheme = remote.Scheme
 			req.URL.Host = remote.Host
 		}
+		headers := securityHeaders.headers()
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			// resp.Request is a copy of the request to the server, so its TLS
+			// field is set when the client connected with TLS.
+			setSecurityHeaders(resp.Header, headers, resp.Request.TLS != nil, false)
+			return nil
+		}
 		proxy.ErrorLog = log.New(logging.NewFilteredHTTPLogger(), "", 0)
 
 		router.Use(func(c *gin.Context) {
@@ -313,16 +561,33 @@ func (s *Server) setupServer(server *http.Server) (net.Addr, error) {
 			}
 			return nil
 		},
-		stop: func() {
-			_ = server.Close()
+		stop: func(ctx context.Context) error {
+			if err := server.Shutdown(ctx); err != nil {
+				_ = server.Close()
+				return err
+			}
+			return nil
 		},
 	})
 	return l.Addr(), nil
 }
 
 type routine struct {
-	run  func() error
-	stop func()
+	run func() error
+	// stop the routine, waiting until ctx is done for it to finish gracefully.
+	stop func(ctx context.Context) error
+}
+
+func loadAccessKeyChecksumKeys(opts AccessKeyChecksumOptions, secretStorage map[string]secrets.SecretStorage) error {
+	keys := make([][]byte, 0, len(opts.Keys))
+	for i, ref := range opts.Keys {
+		key, err := secrets.GetSecret(ref, secretStorage)
+		if err != nil {
+			return fmt.Errorf("key %d: %w", i, err)
+		}
+		keys = append(keys, []byte(key))
+	}
+	return data.SetAccessKeyChecksumKeys(keys, !opts.DisableLegacy)
 }
 
 func getDatabaseDriver(options Options, secretStorage map[string]secrets.SecretStorage) (gorm.Dialector, error) {
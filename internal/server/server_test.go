@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -31,6 +32,7 @@ func setupServer(t *testing.T, ops ...func(*testing.T, *Options)) *Server {
 	options := Options{
 		SessionDuration:          10 * time.Minute,
 		SessionExtensionDeadline: 30 * time.Minute,
+		EnablePprof:              true,
 	}
 	for _, op := range ops {
 		op(t, &options)
@@ -46,6 +48,7 @@ func setupServer(t *testing.T, ops ...func(*testing.T, *Options)) *Server {
 	assert.NilError(t, err)
 
 	s.metricsRegistry = prometheus.NewRegistry()
+	s.setupAuditEvents()
 	return s
 }
 
@@ -97,6 +100,152 @@ func TestGetPostgresConnectionURL(t *testing.T) {
 	})
 }
 
+func TestNew_InvalidCookieOptions(t *testing.T) {
+	opts := Options{
+		BaseDomain: "example.com",
+		Cookie:     CookieOptions{HostPrefix: true},
+	}
+	_, err := New(opts)
+	assert.ErrorContains(t, err, "cannot use the __Host- cookie prefix with a base domain")
+
+	_, err = New(Options{Cookie: CookieOptions{SameSite: "sometimes"}})
+	assert.ErrorContains(t, err, "invalid cookie sameSite")
+}
+
+func TestNew_InvalidDefaultAPIVersion(t *testing.T) {
+	_, err := New(Options{DefaultAPIVersion: "latest"})
+	assert.ErrorContains(t, err, `invalid default API version "latest"`)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	type result struct {
+		body string
+		err  error
+	}
+
+	setup := func(t *testing.T, opts Options) (srv *Server, addr net.Addr, started chan struct{}, release chan struct{}) {
+		srv = newServer(opts)
+		started = make(chan struct{}, 1)
+		release = make(chan struct{})
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			started <- struct{}{}
+			<-release
+			_, _ = w.Write([]byte("done"))
+		})
+		addr, err := srv.setupServer(&http.Server{Handler: handler, ReadHeaderTimeout: time.Second})
+		assert.NilError(t, err)
+
+		go func() {
+			_ = srv.routines[0].run()
+		}()
+		return srv, addr, started, release
+	}
+
+	get := func(addr net.Addr) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			// nolint:noctx
+			resp, err := client.Get("http://" + addr.String())
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			ch <- result{body: string(body), err: err}
+		}()
+		return ch
+	}
+
+	t.Run("in-flight requests complete", func(t *testing.T) {
+		srv, addr, started, release := setup(t, Options{ShutdownGracePeriod: 10 * time.Second})
+
+		inflight := get(addr)
+		<-started
+
+		shutdown := make(chan error, 1)
+		go func() {
+			shutdown <- srv.Shutdown(context.Background())
+		}()
+
+		// wait for the listener to close, new connections are refused
+		for {
+			conn, err := net.DialTimeout("tcp", addr.String(), time.Second)
+			if err != nil {
+				break
+			}
+			_ = conn.Close()
+			time.Sleep(10 * time.Millisecond)
+		}
+		res := <-get(addr)
+		assert.ErrorContains(t, res.err, "connection refused")
+
+		close(release)
+		res = <-inflight
+		assert.NilError(t, res.err)
+		assert.Equal(t, res.body, "done")
+		assert.NilError(t, <-shutdown)
+	})
+
+	t.Run("connections closed after grace period", func(t *testing.T) {
+		srv, addr, started, release := setup(t, Options{ShutdownGracePeriod: 50 * time.Millisecond})
+		t.Cleanup(func() {
+			close(release)
+		})
+
+		inflight := get(addr)
+		<-started
+
+		err := srv.Shutdown(context.Background())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		res := <-inflight
+		assert.Assert(t, res.err != nil)
+	})
+
+	t.Run("servers shut down concurrently", func(t *testing.T) {
+		srv, addr, started, release := setup(t, Options{ShutdownGracePeriod: 10 * time.Second})
+
+		idle := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+		idleAddr, err := srv.setupServer(&http.Server{Handler: idle, ReadHeaderTimeout: time.Second})
+		assert.NilError(t, err)
+		go func() {
+			_ = srv.routines[1].run()
+		}()
+
+		inflight := get(addr)
+		<-started
+
+		shutdown := make(chan error, 1)
+		go func() {
+			shutdown <- srv.Shutdown(context.Background())
+		}()
+
+		// the second server closes while the first is waiting for its
+		// in-flight request
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			conn, err := net.DialTimeout("tcp", idleAddr.String(), time.Second)
+			if err != nil {
+				break
+			}
+			_ = conn.Close()
+			if time.Now().After(deadline) {
+				close(release)
+				t.Fatal("second server was not shut down while a request was in-flight")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		close(release)
+		res := <-inflight
+		assert.NilError(t, res.err)
+		assert.NilError(t, <-shutdown)
+	})
+}
+
 func TestServer_Run(t *testing.T) {
 	if testing.Short() {
 		t.Skip("too slow for short run")
@@ -251,6 +400,31 @@ func TestServer_Run_UIProxy(t *testing.T) {
 	})
 }
 
+func TestServer_Readyz(t *testing.T) {
+	srv := setupServer(t)
+	routes := srv.GenerateRoutes()
+
+	ready := func() int {
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return resp.Code
+	}
+
+	t.Run("database is reachable", func(t *testing.T) {
+		assert.Equal(t, ready(), http.StatusOK)
+	})
+
+	t.Run("database is closed", func(t *testing.T) {
+		assert.NilError(t, srv.db.Close())
+		assert.Equal(t, ready(), http.StatusServiceUnavailable)
+
+		// healthz does not depend on the database
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, resp.Code, http.StatusOK)
+	})
+}
+
 func TestServer_GenerateRoutes_NoRoute(t *testing.T) {
 	type testCase struct {
 		name     string
@@ -291,6 +465,27 @@ func TestServer_GenerateRoutes_NoRoute(t *testing.T) {
 				assert.Equal(t, contentType, expected)
 			},
 		},
+		{
+			name: "Using application/json on a non-api path",
+			path: "/not/found",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Accept", "application/json")
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				contentType := resp.Header().Get("Content-Type")
+				assert.Equal(t, contentType, "application/json; charset=utf-8")
+			},
+		},
+		{
+			name: "Using text/html on an api path",
+			path: "/api/not/found",
+			setup: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, "404 not found", resp.Body.String())
+			},
+		},
 		{
 			name: "Other type",
 			path: "/not/found",
@@ -2,11 +2,15 @@ package server
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/infrahq/infra/api"
 	"github.com/infrahq/infra/internal/access"
+	"github.com/infrahq/infra/internal/server/data"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/internal/validate"
 )
 
 func (a *API) GetSettings(c *gin.Context, r *api.EmptyRequest) (*api.Settings, error) {
@@ -19,15 +23,98 @@ func (a *API) GetSettings(c *gin.Context, r *api.EmptyRequest) (*api.Settings, e
 }
 
 func (a *API) UpdateSettings(c *gin.Context, s *api.Settings) (*api.Settings, error) {
+	if s.DefaultAccessKeyTTL != nil {
+		err := a.validateAccessKeyTTL("defaultAccessKeyTTL", time.Duration(*s.DefaultAccessKeyTTL))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	settings, err := access.GetSettings(c)
 	if err != nil {
 		return nil, err
 	}
 
 	settings.SetFromAPI(s)
+	if err := a.validateSessionSettings(settings); err != nil {
+		return nil, err
+	}
 	if err = access.SaveSettings(c, settings); err != nil {
 		return nil, err
 	}
 
-	return s, nil
+	return settings.ToAPI(), nil
+}
+
+// validateAccessKeyTTL checks that ttl is not longer than the maximum lifetime
+// of access keys allowed by the server.
+func (a *API) validateAccessKeyTTL(name string, ttl time.Duration) error {
+	maxTTL := a.server.options.MaxAccessKeyTTL
+	switch {
+	case ttl < 0:
+		return validate.Error{name: {"must be a positive duration"}}
+	case maxTTL > 0 && ttl > maxTTL:
+		return validate.Error{name: {fmt.Sprintf("must be at most %v", maxTTL)}}
+	}
+	return nil
+}
+
+// RotateSigningKey replaces the key used to sign the tokens that are used to
+// authenticate with destinations. Tokens signed by the previous key can be
+// verified until the end of the grace period.
+func (a *API) RotateSigningKey(c *gin.Context, _ *api.EmptyRequest) (*api.EmptyResponse, error) {
+	return nil, access.RotateSigningKey(c)
+}
+
+// validateSessionSettings checks the session settings after the update is
+// applied, so that a field omitted from the update is compared using its
+// current value.
+func (a *API) validateSessionSettings(s *models.Settings) error {
+	duration := s.SessionDuration
+	extension := s.SessionExtensionDeadline
+
+	errs := validate.Error{}
+	if duration < 0 {
+		errs["sessionDuration"] = []string{"must be a positive duration"}
+	}
+	if extension < 0 {
+		errs["sessionExtensionDeadline"] = []string{"must be a positive duration"}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if duration == 0 {
+		duration = a.server.options.SessionDuration
+	}
+	if extension == 0 {
+		extension = a.server.options.SessionExtensionDeadline
+	}
+	if extension > duration {
+		return validate.Error{"sessionExtensionDeadline": {
+			fmt.Sprintf("must be at most the session duration of %v", duration),
+		}}
+	}
+	return nil
+}
+
+// sessionDurations returns the maximum lifetime of login sessions in the
+// organization, and the amount of time a session remains valid without being
+// used. The settings of the organization take precedence over the server
+// options.
+func (a *API) sessionDurations(tx data.GormTxn) (duration, extension time.Duration, err error) {
+	settings, err := data.GetSettings(tx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("session settings: %w", err)
+	}
+
+	duration = a.server.options.SessionDuration
+	if settings.SessionDuration > 0 {
+		duration = settings.SessionDuration
+	}
+	extension = a.server.options.SessionExtensionDeadline
+	if settings.SessionExtensionDeadline > 0 {
+		extension = settings.SessionExtensionDeadline
+	}
+	return duration, extension, nil
 }
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/api"
+)
+
+func TestAPI_UpdateSettings(t *testing.T) {
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.MaxAccessKeyTTL = 48 * time.Hour
+	})
+	routes := srv.GenerateRoutes()
+
+	ttl := func(d time.Duration) *api.Duration {
+		v := api.Duration(d)
+		return &v
+	}
+	intPtr := func(i int) *int {
+		return &i
+	}
+
+	type testCase struct {
+		name     string
+		body     api.Settings
+		expected func(t *testing.T, resp *httptest.ResponseRecorder)
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		req := httptest.NewRequest(http.MethodPut, "/api/settings", jsonBody(t, tc.body))
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+
+		tc.expected(t, resp)
+	}
+
+	testCases := []testCase{
+		{
+			name: "default access key ttl",
+			body: api.Settings{DefaultAccessKeyTTL: ttl(24 * time.Hour)},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.Settings{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.DeepEqual(t, respBody.DefaultAccessKeyTTL, ttl(24*time.Hour))
+			},
+		},
+		{
+			name: "default access key ttl exceeds maximum",
+			body: api.Settings{DefaultAccessKeyTTL: ttl(72 * time.Hour)},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "defaultAccessKeyTTL", Errors: []string{"must be at most 48h0m0s"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "max active access keys",
+			body: api.Settings{MaxActiveAccessKeys: intPtr(20)},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.Settings{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.DeepEqual(t, respBody.MaxActiveAccessKeys, intPtr(20))
+				// omitting the ttl keeps the value from the previous update
+				assert.DeepEqual(t, respBody.DefaultAccessKeyTTL, ttl(24*time.Hour))
+			},
+		},
+		{
+			name: "negative max active access keys",
+			body: api.Settings{MaxActiveAccessKeys: intPtr(-1)},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "maxActiveAccessKeys", Errors: []string{"value -1 must be at least 1"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "session durations",
+			body: api.Settings{
+				SessionDuration:          ttl(8 * time.Hour),
+				SessionExtensionDeadline: ttl(time.Hour),
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.Settings{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				assert.DeepEqual(t, respBody.SessionDuration, ttl(8*time.Hour))
+				assert.DeepEqual(t, respBody.SessionExtensionDeadline, ttl(time.Hour))
+			},
+		},
+		{
+			name: "omitted fields are unchanged",
+			body: api.Settings{
+				PasswordRequirements: &api.PasswordRequirements{LengthMin: 12, SymbolMin: 1},
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+				respBody := &api.Settings{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+				expected := &api.Settings{
+					PasswordRequirements:     &api.PasswordRequirements{LengthMin: 12, SymbolMin: 1},
+					DefaultAccessKeyTTL:      ttl(24 * time.Hour),
+					MaxActiveAccessKeys:      intPtr(20),
+					SessionDuration:          ttl(8 * time.Hour),
+					SessionExtensionDeadline: ttl(time.Hour),
+				}
+				assert.DeepEqual(t, respBody, expected)
+			},
+		},
+		{
+			name: "session extension exceeds the current session duration",
+			body: api.Settings{SessionExtensionDeadline: ttl(9 * time.Hour)},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "sessionExtensionDeadline", Errors: []string{"must be at most the session duration of 8h0m0s"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "session extension exceeds session duration",
+			body: api.Settings{
+				SessionDuration:          ttl(time.Hour),
+				SessionExtensionDeadline: ttl(2 * time.Hour),
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "sessionExtensionDeadline", Errors: []string{"must be at most the session duration of 1h0m0s"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+		{
+			name: "negative session duration",
+			body: api.Settings{SessionDuration: ttl(-time.Hour)},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "sessionDuration", Errors: []string{"must be a positive duration"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
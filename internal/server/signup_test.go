@@ -135,13 +135,49 @@ func TestAPI_Signup(t *testing.T) {
 				}
 			},
 			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
-				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+				assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
 
 				respBody := &api.Error{}
 				err := json.Unmarshal(resp.Body.Bytes(), respBody)
 				assert.NilError(t, err)
 
-				assert.Equal(t, respBody.Message, "bad request: signup is disabled")
+				expected := &api.Error{
+					Code:      http.StatusForbidden,
+					ErrorCode: api.ErrorCodeSignupDisabled,
+					Message:   "signup is not enabled on this server",
+				}
+				assert.DeepEqual(t, respBody, expected)
+			},
+		},
+		{
+			name: "signup disabled with a message",
+			setup: func(t *testing.T) api.SignupRequest {
+				srv.options.DisableSignup = true
+				srv.options.SignupDisabledMessage = "signup is paused during maintenance"
+				t.Cleanup(func() {
+					srv.options.DisableSignup = false
+					srv.options.SignupDisabledMessage = ""
+				})
+
+				return api.SignupRequest{
+					Name:     "admin@example.com",
+					Password: "password",
+					Org:      api.SignupOrg{Name: "acme", Subdomain: "acme-co"},
+				}
+			},
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusForbidden, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := &api.Error{
+					Code:      http.StatusForbidden,
+					ErrorCode: api.ErrorCodeSignupDisabled,
+					Message:   "signup is paused during maintenance",
+				}
+				assert.DeepEqual(t, respBody, expected)
 			},
 		},
 		{
@@ -201,3 +237,88 @@ func TestAPI_Signup(t *testing.T) {
 		})
 	}
 }
+
+func TestAPI_GetServerConfiguration_Signup(t *testing.T) {
+	type testCase struct {
+		name     string
+		options  Options
+		expected api.ServerConfiguration
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		srv := setupServer(t)
+		srv.options.EnableSignup = tc.options.EnableSignup
+		srv.options.DisableSignup = tc.options.DisableSignup
+		srv.options.SignupDisabledMessage = tc.options.SignupDisabledMessage
+		srv.options.BaseDomain = "exampledomain.com"
+		routes := srv.GenerateRoutes()
+
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodGet, "/api/server-configuration", nil)
+		assert.NilError(t, err)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		actual := api.ServerConfiguration{}
+		err = json.Unmarshal(resp.Body.Bytes(), &actual)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, actual, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:    "signup enabled",
+			options: Options{EnableSignup: true},
+			expected: api.ServerConfiguration{
+				IsSignupEnabled: true,
+				BaseDomain:      "exampledomain.com",
+			},
+		},
+		{
+			name:    "signup not enabled",
+			options: Options{},
+			expected: api.ServerConfiguration{
+				SignupDisabled: &api.SignupDisabled{
+					Reason:  api.SignupDisabledReasonNotEnabled,
+					Message: "signup is not enabled on this server",
+				},
+				BaseDomain: "exampledomain.com",
+			},
+		},
+		{
+			name:    "signup disabled",
+			options: Options{EnableSignup: true, DisableSignup: true},
+			expected: api.ServerConfiguration{
+				SignupDisabled: &api.SignupDisabled{
+					Reason:  api.SignupDisabledReasonDisabled,
+					Message: "signup is disabled",
+				},
+				BaseDomain: "exampledomain.com",
+			},
+		},
+		{
+			name: "signup disabled with a message",
+			options: Options{
+				EnableSignup:          true,
+				DisableSignup:         true,
+				SignupDisabledMessage: "signup is paused during maintenance",
+			},
+			expected: api.ServerConfiguration{
+				SignupDisabled: &api.SignupDisabled{
+					Reason:  api.SignupDisabledReasonDisabled,
+					Message: "signup is paused during maintenance",
+				},
+				BaseDomain: "exampledomain.com",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -17,7 +17,7 @@ import (
 
 func (a *API) ListUsers(c *gin.Context, r *api.ListUsersRequest) (*api.ListResponse[api.User], error) {
 	p := PaginationFromRequest(r.PaginationRequest)
-	users, err := access.ListIdentities(c, r.Name, r.Group, r.IDs, r.ShowSystem, &p)
+	users, err := access.ListIdentities(c, r.Name, r.Search, r.Group, r.IDs, r.ShowSystem, r.Sort, &p)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +50,7 @@ func (a *API) CreateUser(c *gin.Context, r *api.CreateUserRequest) (*api.CreateU
 	user := &models.Identity{Name: r.Name}
 
 	// infra identity creation should be attempted even if an identity is already known
-	identities, err := access.ListIdentities(c, user.Name, 0, nil, false, &data.Pagination{Limit: 2})
+	identities, err := access.ListIdentities(c, user.Name, "", 0, nil, false, "", &data.Pagination{Limit: 2})
 	if err != nil {
 		return nil, fmt.Errorf("list identities: %w", err)
 	}
@@ -63,7 +63,7 @@ func (a *API) CreateUser(c *gin.Context, r *api.CreateUserRequest) (*api.CreateU
 	case 1:
 		user.ID = identities[0].ID
 	default:
-		logging.Errorf("Multiple identites match name %q. DB is missing unique index on user names", r.Name)
+		logging.Ctx(c.Request.Context()).Error().Msgf("Multiple identites match name %q. DB is missing unique index on user names", r.Name)
 		return nil, fmt.Errorf("multiple identities match specified name") // should not happen
 	}
 
@@ -111,11 +111,20 @@ func (a *API) CreateUser(c *gin.Context, r *api.CreateUserRequest) (*api.CreateU
 
 func (a *API) UpdateUser(c *gin.Context, r *api.UpdateUserRequest) (*api.User, error) {
 	// right now this endpoint can only update a user's credentials, so get the user identity
+	expectedVersion, err := ifMatchResourceVersion(c)
+	if err != nil {
+		return nil, err
+	}
+
 	identity, err := access.GetIdentity(c, r.ID)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := access.UpdateIdentityResourceVersion(c, identity, expectedVersion); err != nil {
+		return nil, err
+	}
+
 	err = access.UpdateCredential(c, identity, r.Password)
 	if err != nil {
 		return nil, err
@@ -123,6 +132,29 @@ func (a *API) UpdateUser(c *gin.Context, r *api.UpdateUserRequest) (*api.User, e
 	return identity.ToAPI(), nil
 }
 
+// PatchUser updates the fields of the user that are set in the request, and
+// leaves the other fields unchanged.
+func (a *API) PatchUser(c *gin.Context, r *api.PatchUserRequest) (*api.User, error) {
+	expectedVersion, err := ifMatchResourceVersion(c)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := access.GetIdentity(c, r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := access.UpdateIdentityResourceVersion(c, identity, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	if err := access.UpdateCredential(c, identity, *r.Password); err != nil {
+		return nil, err
+	}
+	return identity.ToAPI(), nil
+}
+
 func (a *API) DeleteUser(c *gin.Context, r *api.Resource) (*api.EmptyResponse, error) {
 	return nil, access.DeleteIdentity(c, r.ID)
 }
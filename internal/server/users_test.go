@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"gotest.tools/v3/assert"
 
 	"github.com/infrahq/infra/api"
+	"github.com/infrahq/infra/internal"
 	"github.com/infrahq/infra/internal/generate"
 	"github.com/infrahq/infra/internal/server/data"
 	"github.com/infrahq/infra/internal/server/models"
@@ -159,7 +161,8 @@ func TestAPI_GetUser(t *testing.T) {
 						"lastSeenAt": "%[2]v",
 						"created": "%[2]v",
 						"providerNames": ["infra"],
-						"updated": "%[2]v"
+						"updated": "%[2]v",
+						"resourceVersion": 0
 					}`,
 					idMe.String(),
 					time.Now().UTC().Format(time.RFC3339),
@@ -395,6 +398,107 @@ func TestAPI_ListUsers(t *testing.T) {
 				assert.DeepEqual(t, actual, expected, cmpAPIUserShallow)
 			},
 		},
+		"search by name": {
+			urlPath: "/api/users?search=hal",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK)
+
+				var actual api.ListResponse[api.User]
+				err := json.NewDecoder(resp.Body).Decode(&actual)
+				assert.NilError(t, err)
+				expected := api.ListResponse[api.User]{
+					Count: 2,
+					Items: []api.User{
+						{Name: "HAL@example.com"},
+						{Name: "other-HAL@example.com"},
+					},
+					PaginationResponse: api.PaginationResponse{Page: 1, Limit: 100, TotalPages: 1, TotalCount: 2},
+				}
+				assert.DeepEqual(t, actual, expected, cmpAPIUserShallow)
+			},
+		},
+		"search by name with pagination": {
+			urlPath: "/api/users?search=OTHER&limit=1&page=2",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK)
+
+				var actual api.ListResponse[api.User]
+				err := json.NewDecoder(resp.Body).Decode(&actual)
+				assert.NilError(t, err)
+				expected := api.ListResponse[api.User]{
+					Count: 1,
+					Items: []api.User{
+						{Name: "other@example.com"},
+					},
+					PaginationResponse: api.PaginationResponse{Page: 2, Limit: 1, TotalPages: 2, TotalCount: 2},
+				}
+				assert.DeepEqual(t, actual, expected, cmpAPIUserShallow)
+			},
+		},
+		"sort by name": {
+			urlPath: "/api/users?search=example.com&sort=name",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				if runtime.GOOS == "darwin" {
+					t.Skip("this test doesn't do the right thing on mac due to a different default postgres sort order collation")
+				}
+				assert.Equal(t, resp.Code, http.StatusOK)
+
+				var actual api.ListResponse[api.User]
+				err := json.NewDecoder(resp.Body).Decode(&actual)
+				assert.NilError(t, err)
+				expected := api.ListResponse[api.User]{
+					Count: 6,
+					Items: []api.User{
+						{Name: "AnotherUser@example.com"},
+						{Name: "HAL@example.com"},
+						{Name: "admin@example.com"},
+						{Name: "me@example.com"},
+						{Name: "other-HAL@example.com"},
+						{Name: "other@example.com"},
+					},
+					PaginationResponse: api.PaginationResponse{Page: 1, Limit: 100, TotalPages: 1, TotalCount: 6},
+				}
+				assert.DeepEqual(t, actual, expected, cmpAPIUserShallow)
+			},
+		},
+		"sort by created": {
+			urlPath: "/api/users?sort=created",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusOK)
+
+				var actual api.ListResponse[api.User]
+				err := json.NewDecoder(resp.Body).Decode(&actual)
+				assert.NilError(t, err)
+				expected := api.ListResponse[api.User]{
+					Count: 6,
+					Items: []api.User{
+						{Name: "admin@example.com"},
+						{Name: "AnotherUser@example.com"},
+						{Name: "me@example.com"},
+						{Name: "other@example.com"},
+						{Name: "HAL@example.com"},
+						{Name: "other-HAL@example.com"},
+					},
+					PaginationResponse: api.PaginationResponse{Page: 1, Limit: 100, TotalPages: 1, TotalCount: 6},
+				}
+				assert.DeepEqual(t, actual, expected, cmpAPIUserShallow)
+			},
+		},
+		"invalid sort": {
+			urlPath: "/api/users?sort=lastSeenAt",
+			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+				respBody := &api.Error{}
+				err := json.Unmarshal(resp.Body.Bytes(), respBody)
+				assert.NilError(t, err)
+
+				expected := []api.FieldError{
+					{FieldName: "sort", Errors: []string{"must be one of (name, created)"}},
+				}
+				assert.DeepEqual(t, respBody.FieldErrors, expected)
+			},
+		},
 		"invalid limit": {
 			urlPath: "/api/users?limit=1001",
 			expected: func(t *testing.T, resp *httptest.ResponseRecorder) {
@@ -692,6 +796,31 @@ func TestAPI_CreateUserAndUpdatePassword(t *testing.T) {
 	})
 }
 
+func TestAPI_CreateUser_BodyTooLarge(t *testing.T) {
+	srv := setupServer(t, withAdminUser, func(_ *testing.T, opts *Options) {
+		opts.MaxRequestBodyBytes = 64
+	})
+	routes := srv.GenerateRoutes()
+
+	body := jsonBody(t, api.CreateUserRequest{Name: strings.Repeat("a", 64) + "@example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/api/users", body)
+	req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+	req.Header.Set("Infra-Version", apiVersionLatest)
+
+	resp := httptest.NewRecorder()
+	routes.ServeHTTP(resp, req)
+
+	assert.Equal(t, resp.Code, http.StatusRequestEntityTooLarge, resp.Body.String())
+
+	respBody := &api.Error{}
+	err := json.Unmarshal(resp.Body.Bytes(), respBody)
+	assert.NilError(t, err)
+	assert.Equal(t, respBody.Message, "request body is larger than the limit of 64 bytes")
+
+	_, err = data.GetIdentity(srv.DB(), data.ByName(strings.Repeat("a", 64)+"@example.com"))
+	assert.ErrorIs(t, err, internal.ErrNotFound)
+}
+
 func TestAPI_DeleteUser(t *testing.T) {
 	srv := setupServer(t, withAdminUser)
 	routes := srv.GenerateRoutes()
@@ -860,3 +989,95 @@ func TestAPI_UpdateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestAPI_PatchUser(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	user := &models.Identity{Name: "tamale@example.com"}
+	err := data.CreateIdentity(srv.DB(), user)
+	assert.NilError(t, err)
+
+	hash := []byte("the-original-hash")
+	err = data.CreateCredential(srv.DB(), &models.Credential{IdentityID: user.ID, PasswordHash: hash})
+	assert.NilError(t, err)
+
+	patchUser := func(t *testing.T, body string, accessKey string) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodPatch, "/api/users/"+user.ID.String(), strings.NewReader(body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+accessKey)
+		req.Header.Set("Infra-Version", apiVersionLatest)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("password leaves the name unchanged", func(t *testing.T) {
+		resp := patchUser(t, `{"password": "a-new-password"}`, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+		identity, err := data.GetIdentity(srv.DB(), data.ByID(user.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, identity.Name, "tamale@example.com")
+
+		credential, err := data.GetCredential(srv.DB(), data.ByIdentityID(user.ID))
+		assert.NilError(t, err)
+		assert.Assert(t, string(credential.PasswordHash) != string(hash))
+	})
+
+	t.Run("name can not be changed", func(t *testing.T) {
+		resp := patchUser(t, `{"name": "burrito@example.com"}`, adminAccessKey(srv))
+		assert.Equal(t, resp.Code, http.StatusBadRequest, resp.Body.String())
+
+		var respBody api.Error
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+		expected := []api.FieldError{
+			{FieldName: "password", Errors: []string{"is required"}},
+		}
+		assert.DeepEqual(t, respBody.FieldErrors, expected)
+
+		identity, err := data.GetIdentity(srv.DB(), data.ByID(user.ID))
+		assert.NilError(t, err)
+		assert.Equal(t, identity.Name, "tamale@example.com")
+	})
+}
+
+func TestAPI_PatchUser_ResourceVersion(t *testing.T) {
+	srv := setupServer(t, withAdminUser)
+	routes := srv.GenerateRoutes()
+
+	user := &models.Identity{Name: "churro@example.com"}
+	err := data.CreateIdentity(srv.DB(), user)
+	assert.NilError(t, err)
+
+	patchUser := func(t *testing.T, ifMatch string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		// nolint:noctx
+		req, err := http.NewRequest(http.MethodPatch, "/api/users/"+user.ID.String(), strings.NewReader(body))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminAccessKey(srv))
+		req.Header.Set("Infra-Version", apiVersionLatest)
+		req.Header.Set("If-Match", ifMatch)
+
+		resp := httptest.NewRecorder()
+		routes.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := patchUser(t, "0", `{"password": "the-first-password"}`)
+	assert.Equal(t, resp.Code, http.StatusOK, resp.Body.String())
+
+	var respBody api.User
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &respBody))
+	assert.Equal(t, respBody.ResourceVersion, int64(1))
+
+	resp = patchUser(t, "0", `{"password": "the-second-password"}`)
+	assert.Equal(t, resp.Code, http.StatusConflict, resp.Body.String())
+
+	identity, err := data.GetIdentity(srv.DB(), data.ByID(user.ID))
+	assert.NilError(t, err)
+	assert.Equal(t, identity.ResourceVersion, int64(1))
+}
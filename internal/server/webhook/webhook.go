@@ -0,0 +1,221 @@
+// Package webhook sends audit events to an HTTP endpoint, so that other
+// systems can react to changes to access keys and grants.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/infrahq/infra/internal/logging"
+	"github.com/infrahq/infra/internal/server/models"
+	"github.com/infrahq/infra/uid"
+)
+
+// SignatureHeader is the request header that contains the HMAC-SHA256 of the
+// request body, as hex, with a sha256= prefix. The HMAC key is the shared
+// secret from Options.Secret.
+const SignatureHeader = "Infra-Signature"
+
+// maxDeliveryAttempts is the maximum number of times an event is sent before
+// it is dropped.
+const maxDeliveryAttempts = 5
+
+// queueSize is the number of events that may be waiting to be sent. Events
+// are dropped when the queue is full.
+const queueSize = 1000
+
+// requestTimeout is the amount of time to wait for a response to a single
+// request.
+const requestTimeout = 10 * time.Second
+
+// retryBackoff is the time to wait before the first retry. The time doubles
+// for every retry after the first.
+var retryBackoff = time.Second
+
+// Options configure the endpoint that receives events.
+type Options struct {
+	// URL is the endpoint that events are sent to with a POST request. When
+	// empty no events are sent.
+	URL string
+	// Secret is the key used to sign the request body. The receiver uses the
+	// same secret to verify the signature in the Infra-Signature header.
+	Secret string
+}
+
+// Validate returns an error if the options are not valid.
+func (o Options) Validate() error {
+	if o.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(o.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %q, must be an http or https url", o.URL)
+	}
+	if o.Secret == "" {
+		return errors.New("webhook secret is required")
+	}
+	return nil
+}
+
+// Event is the JSON body of a request sent to the webhook endpoint.
+type Event struct {
+	ID             uid.ID    `json:"id"`
+	Action         string    `json:"action"`
+	Time           time.Time `json:"time"`
+	OrganizationID uid.ID    `json:"organizationID"`
+	ActorID        uid.ID    `json:"actorID"`
+	TargetID       uid.ID    `json:"targetID"`
+	TargetName     string    `json:"targetName"`
+	ProviderID     uid.ID    `json:"providerID,omitempty"`
+}
+
+func newEvent(event models.AuditEvent) Event {
+	return Event{
+		ID:             event.ID,
+		Action:         event.Action,
+		Time:           event.CreatedAt.UTC(),
+		OrganizationID: event.OrganizationID,
+		ActorID:        event.ActorID,
+		TargetID:       event.TargetID,
+		TargetName:     event.TargetName,
+		ProviderID:     event.ProviderID,
+	}
+}
+
+// Sign returns the value of the Infra-Signature header for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender sends events to the webhook endpoint from a queue, so that the
+// request which caused the event does not wait for the delivery.
+type Sender struct {
+	opts   Options
+	client *http.Client
+	queue  chan Event
+}
+
+func NewSender(opts Options) *Sender {
+	return &Sender{
+		opts:   opts,
+		client: &http.Client{Timeout: requestTimeout},
+		queue:  make(chan Event, queueSize),
+	}
+}
+
+// Send adds the event to the queue. Send does not block. When the queue is
+// full the event is written to the dead-letter log and dropped.
+func (s *Sender) Send(event models.AuditEvent) {
+	e := newEvent(event)
+	select {
+	case s.queue <- e:
+	default:
+		deadLetter(e, errors.New("queue is full"))
+	}
+}
+
+// Run sends the events in the queue until ctx is done. Events that are still
+// in the queue when ctx is done are written to the dead-letter log.
+func (s *Sender) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.drain(ctx.Err())
+			return
+		case event := <-s.queue:
+			if err := s.deliver(ctx, event); err != nil {
+				deadLetter(event, err)
+			}
+		}
+	}
+}
+
+func (s *Sender) drain(err error) {
+	for {
+		select {
+		case event := <-s.queue:
+			deadLetter(event, err)
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends the event, and retries when the request fails with a network
+// error, a 5xx, or a 429 response, up to maxDeliveryAttempts times.
+func (s *Sender) deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := s.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= maxDeliveryAttempts {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		logging.L.Debug().Err(err).Int("attempt", attempt).Str("action", event.Action).
+			Msg("webhook delivery failed, retrying")
+
+		timer := time.NewTimer(retryBackoff << (attempt - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends a single request, and returns whether the request should be
+// retried when it fails.
+func (s *Sender) send(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(s.opts.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("response status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("response status %d", resp.StatusCode)
+	}
+}
+
+// deadLetter writes an event that could not be delivered to the log, so that
+// it can be sent again by an operator.
+func deadLetter(event Event, err error) {
+	body, _ := json.Marshal(event)
+	logging.L.Error().Err(err).
+		Str("action", event.Action).
+		Str("eventID", event.ID.String()).
+		RawJSON("event", body).
+		Msg("webhook event could not be delivered")
+}
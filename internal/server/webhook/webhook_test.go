@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/infrahq/infra/internal/logging"
+	"github.com/infrahq/infra/internal/server/models"
+)
+
+func patchRetryBackoff(t *testing.T, backoff time.Duration) {
+	orig := retryBackoff
+	retryBackoff = backoff
+	t.Cleanup(func() {
+		retryBackoff = orig
+	})
+}
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver starts a server which responds to each request with the next
+// status code from statusCodes, and then with 200.
+func receiver(t *testing.T, statusCodes ...int) (*httptest.Server, chan request, *int32) {
+	t.Helper()
+	requests := make(chan request, 10)
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.Check(t, err)
+
+		n := int(atomic.AddInt32(&count, 1))
+		if n <= len(statusCodes) {
+			w.WriteHeader(statusCodes[n-1])
+			return
+		}
+		requests <- request{header: req.Header, body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests, &count
+}
+
+func runSender(t *testing.T, sender *Sender) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sender.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+var auditEvent = models.AuditEvent{
+	Model:              models.Model{ID: 1234, CreatedAt: time.Date(2022, 10, 20, 9, 0, 0, 0, time.UTC)},
+	OrganizationMember: models.OrganizationMember{OrganizationID: 5678},
+	Action:             models.AuditActionCreateAccessKey,
+	ActorID:            100,
+	TargetID:           200,
+	TargetName:         "my-key",
+	ProviderID:         300,
+}
+
+func TestSender_Send(t *testing.T) {
+	srv, requests, _ := receiver(t)
+	sender := NewSender(Options{URL: srv.URL, Secret: "the-secret"})
+	runSender(t, sender)
+
+	sender.Send(auditEvent)
+
+	var req request
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the webhook request")
+	}
+
+	assert.Equal(t, req.header.Get("Content-Type"), "application/json")
+	assert.Equal(t, req.header.Get(SignatureHeader), Sign("the-secret", req.body))
+
+	var event Event
+	assert.NilError(t, json.Unmarshal(req.body, &event))
+	expected := Event{
+		ID:             1234,
+		Action:         "access-key.create",
+		Time:           time.Date(2022, 10, 20, 9, 0, 0, 0, time.UTC),
+		OrganizationID: 5678,
+		ActorID:        100,
+		TargetID:       200,
+		TargetName:     "my-key",
+		ProviderID:     300,
+	}
+	assert.DeepEqual(t, event, expected)
+}
+
+func TestSender_RetriesServerErrors(t *testing.T) {
+	patchRetryBackoff(t, time.Millisecond)
+
+	srv, requests, count := receiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	sender := NewSender(Options{URL: srv.URL, Secret: "the-secret"})
+	runSender(t, sender)
+
+	sender.Send(auditEvent)
+
+	select {
+	case req := <-requests:
+		assert.Equal(t, req.header.Get(SignatureHeader), Sign("the-secret", req.body))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the webhook request")
+	}
+	assert.Equal(t, atomic.LoadInt32(count), int32(3))
+}
+
+func TestSender_DeadLetter(t *testing.T) {
+	patchRetryBackoff(t, time.Millisecond)
+	logs := &syncBuffer{}
+	logging.PatchLogger(t, logs)
+
+	t.Run("after the maximum attempts", func(t *testing.T) {
+		codes := make([]int, maxDeliveryAttempts+1)
+		for i := range codes {
+			codes[i] = http.StatusServiceUnavailable
+		}
+		srv, _, count := receiver(t, codes...)
+		sender := NewSender(Options{URL: srv.URL, Secret: "the-secret"})
+
+		err := sender.deliver(context.Background(), newEvent(auditEvent))
+		assert.ErrorContains(t, err, "attempt 5: response status 503")
+		assert.Equal(t, atomic.LoadInt32(count), int32(maxDeliveryAttempts))
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		srv, _, count := receiver(t, http.StatusBadRequest)
+		sender := NewSender(Options{URL: srv.URL, Secret: "the-secret"})
+
+		err := sender.deliver(context.Background(), newEvent(auditEvent))
+		assert.ErrorContains(t, err, "attempt 1: response status 400")
+		assert.Equal(t, atomic.LoadInt32(count), int32(1))
+	})
+
+	t.Run("logged when the event is dropped", func(t *testing.T) {
+		srv, _, _ := receiver(t, http.StatusBadRequest)
+		sender := NewSender(Options{URL: srv.URL, Secret: "the-secret"})
+		runSender(t, sender)
+
+		sender.Send(auditEvent)
+
+		poll(t, func() bool {
+			return bytes.Contains(logs.Bytes(), []byte("webhook event could not be delivered"))
+		})
+		assert.Assert(t, bytes.Contains(logs.Bytes(), []byte(`"targetName":"my-key"`)), logs.String())
+	})
+}
+
+func TestSign(t *testing.T) {
+	// generated with: printf '{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=6146142a2ce0159e84c0767881e4ec80bc397da62526e7d19f70795eb79460c0"
+	assert.Equal(t, Sign("secret", []byte(`{"id":"1"}`)), expected)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	assert.NilError(t, Options{}.Validate())
+	assert.NilError(t, Options{URL: "https://example.com/hook", Secret: "s"}.Validate())
+	assert.ErrorContains(t, Options{URL: "https://example.com/hook"}.Validate(), "secret is required")
+	assert.ErrorContains(t, Options{URL: "example.com/hook", Secret: "s"}.Validate(), "invalid webhook url")
+}
+
+func poll(t *testing.T, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe to use from the sender goroutine
+// and the test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func (b *syncBuffer) String() string {
+	return string(b.Bytes())
+}
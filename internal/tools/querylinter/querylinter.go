@@ -118,9 +118,10 @@ func checkB(pass *analysis.Pass, node ast.Node) error {
 	case *ast.CallExpr:
 		if id, ok := arg.Fun.(*ast.Ident); ok {
 			switch id.Name {
-			case "columnsForSelect", "columnsForInsert", "placeholderForColumns", "columnsForUpdate":
+			case "columnsForSelect", "columnsForInsert", "placeholderForColumns", "columnsForUpdate",
+				"notDeleted":
 				// these functions should all accept a Table method, and only add the value of
-				// Columns, which we check to ensure always return string literals.
+				// Columns or Table, which we check to ensure always return string literals.
 				return nil
 			}
 		}
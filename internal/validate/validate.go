@@ -26,6 +26,10 @@ func Validate(req Request) error {
 
 func validateStruct(v reflect.Value) Error {
 	err := make(Error)
+	// unexported fields, like the fields of a time.Time, are not validated
+	if !v.IsValid() || !v.CanInterface() {
+		return err
+	}
 
 	req, ok := v.Interface().(Request)
 	if ok && (v.Kind() != reflect.Pointer || !v.IsNil()) {
@@ -49,23 +53,38 @@ func validateStruct(v reflect.Value) Error {
 			}
 			name := fieldName(v.Type().Field(i))
 			for k, v := range validateStruct(f) {
-				n := name
-				if k != "" {
-					n = name + "." + k
-				}
+				n := joinFieldPath(name, k)
 				err[n] = append(err[n], v...)
 			}
 		}
 	case reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
+			index := fmt.Sprintf("[%d]", i)
 			for k, v := range validateStruct(v.Index(i)) {
-				err[k] = append(err[k], v...)
+				n := joinFieldPath(index, k)
+				err[n] = append(err[n], v...)
 			}
 		}
 	}
 	return err
 }
 
+// joinFieldPath returns the path of a nested field, using a dot to separate
+// the names of fields, and the index of items in a slice. For example:
+// grants[0].resource.
+func joinFieldPath(parent, child string) string {
+	switch {
+	case child == "":
+		return parent
+	case parent == "":
+		return child
+	case strings.HasPrefix(child, "["):
+		return parent + child
+	default:
+		return parent + "." + child
+	}
+}
+
 // ValidationRule performs validation on one or more struct fields and can
 // describe the validation for public API documentation.
 //
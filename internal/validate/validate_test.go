@@ -3,6 +3,7 @@ package validate
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -191,8 +192,53 @@ func TestValidate_Traversal(t *testing.T) {
 			"sub.nested.id": {"is required"},
 			"sub.ok":        {"is required"},
 			"tooFew":        {"must be at least 5 characters"},
-			"many":          {"one of (first, second, third) is required"},
-			"many.id":       {"is required"},
+			"many[0]":       {"one of (first, second, third) is required"},
+			"many[0].id":    {"is required"},
+		}
+		assert.DeepEqual(t, fieldError, expected)
+	})
+}
+
+type ListExample struct {
+	Items []ListItem `json:"items"`
+}
+
+func (l ListExample) ValidationRules() []ValidationRule {
+	return []ValidationRule{Required("items", l.Items)}
+}
+
+type ListItem struct {
+	Name    string     `json:"name"`
+	Labels  []ListItem `json:"labels"`
+	Expires time.Time  `json:"expires"`
+}
+
+func (l ListItem) ValidationRules() []ValidationRule {
+	return []ValidationRule{Required("name", l.Name)}
+}
+
+func TestValidate_SliceFieldPaths(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		l := ListExample{Items: []ListItem{
+			{Name: "one", Expires: time.Now()},
+			{Name: "two", Labels: []ListItem{{Name: "label"}}},
+		}}
+		assert.NilError(t, Validate(l))
+	})
+
+	t.Run("with failures", func(t *testing.T) {
+		l := ListExample{Items: []ListItem{
+			{Name: "one"},
+			{Expires: time.Now()},
+			{Name: "three", Labels: []ListItem{{Name: "label"}, {}}},
+		}}
+		err := Validate(l)
+
+		var fieldError Error
+		assert.Assert(t, errors.As(err, &fieldError))
+		expected := Error{
+			"items[1].name":           {"is required"},
+			"items[2].labels[1].name": {"is required"},
 		}
 		assert.DeepEqual(t, fieldError, expected)
 	})
@@ -313,3 +359,28 @@ func TestRequireOneOf_Validate(t *testing.T) {
 		assert.Error(t, err, "validation failed: only one of (first, third) can have a value")
 	})
 }
+
+type TimeExample struct {
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+}
+
+func (e TimeExample) ValidationRules() []ValidationRule {
+	return []ValidationRule{Required("name", e.Name)}
+}
+
+func TestValidate_StructWithUnexportedFields(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		err := Validate(TimeExample{Name: "ok", Expires: time.Now()})
+		assert.NilError(t, err)
+	})
+
+	t.Run("with failures", func(t *testing.T) {
+		err := Validate(TimeExample{})
+
+		var fieldError Error
+		assert.Assert(t, errors.As(err, &fieldError))
+		expected := Error{"name": {"is required"}}
+		assert.DeepEqual(t, fieldError, expected)
+	})
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"io"
 	"strconv"
 	"time"
 
@@ -37,8 +38,12 @@ func NewRegistry(version string) *prometheus.Registry {
 	return registry
 }
 
-// Middleware registers the http_request_duration_seconds histogram metric with registry
-// and returns a middleware that emits a request_duration_seconds metric on every request.
+// Middleware registers the http_request_duration_seconds, http_request_size_bytes,
+// and http_response_size_bytes histogram metrics with registry and returns a
+// middleware that emits those metrics on every request.
+//
+// All the metrics are labeled by the route template, not the request path, so
+// that the number of label values is bounded by the number of routes.
 func Middleware(registry prometheus.Registerer) gin.HandlerFunc {
 	requestDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "http",
@@ -47,22 +52,80 @@ func Middleware(registry prometheus.Registerer) gin.HandlerFunc {
 		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
 	}, []string{"host", "method", "path", "status"})
 
-	registry.MustRegister(requestDuration)
+	requestSize := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http",
+		Name:      "request_size_bytes",
+		Help:      "A histogram of the size, in bytes, of HTTP request bodies.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+	}, []string{"method", "path"})
+
+	responseSize := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http",
+		Name:      "response_size_bytes",
+		Help:      "A histogram of the size, in bytes, of HTTP response bodies.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+	}, []string{"method", "path"})
+
+	registry.MustRegister(requestDuration, requestSize, responseSize)
 
 	return func(c *gin.Context) {
 		t := time.Now()
 
+		body := &countingReader{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil {
+			c.Request.Body = body
+		}
+
 		c.Next()
 
+		host, method, path := c.Request.Host, c.Request.Method, c.FullPath()
+		if path == "" {
+			// requests that do not match a route share a single value for
+			// each label, so that clients can not create new label values
+			// with arbitrary paths, methods, or hosts
+			host, method, path = unmatchedLabel, unmatchedLabel, unmatchedLabel
+		}
 		requestDuration.With(prometheus.Labels{
-			"host":   c.Request.Host,
-			"method": c.Request.Method,
-			"path":   c.FullPath(),
+			"host":   host,
+			"method": method,
+			"path":   path,
 			"status": strconv.Itoa(c.Writer.Status()),
 		}).Observe(time.Since(t).Seconds())
+
+		// use the number of bytes read when the handler reads the body, so
+		// that requests without a Content-Length are measured
+		size := body.n
+		if size == 0 && c.Request.ContentLength > 0 {
+			size = c.Request.ContentLength
+		}
+		requestSize.WithLabelValues(method, path).Observe(float64(size))
+
+		// the gin.ResponseWriter counts the bytes written, and returns -1
+		// when nothing was written
+		written := c.Writer.Size()
+		if written < 0 {
+			written = 0
+		}
+		responseSize.WithLabelValues(method, path).Observe(float64(written))
 	}
 }
 
+// unmatchedLabel is the value of the request labels for requests that do not
+// match a route.
+const unmatchedLabel = "unmatched"
+
+// countingReader counts the number of bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
 // NewHandler creates a new gin.Engine, and adds a 'GET /metrics' handler to it.
 // The handler serves prometheus metrics from the promRegistry.
 func NewHandler(promRegistry *prometheus.Registry) *gin.Engine {
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"gotest.tools/v3/assert"
+)
+
+func TestMiddleware_Sizes(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	router := gin.New()
+	router.Use(Middleware(registry))
+	router.POST("/api/users/:id", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		assert.NilError(t, err)
+		c.String(http.StatusOK, strings.Repeat("a", 2*len(body)))
+	})
+
+	for _, id := range []string{"1", "2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/"+id, strings.NewReader("0123456789"))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Code, http.StatusOK)
+	}
+
+	families, err := registry.Gather()
+	assert.NilError(t, err)
+
+	counts := map[string]uint64{}
+	sums := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			// every request uses the route template as the path
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "path" {
+					assert.Equal(t, label.GetValue(), "/api/users/:id")
+				}
+			}
+			counts[family.GetName()] += metric.GetHistogram().GetSampleCount()
+			sums[family.GetName()] += metric.GetHistogram().GetSampleSum()
+		}
+	}
+
+	assert.Equal(t, counts["http_request_size_bytes"], uint64(2))
+	assert.Equal(t, sums["http_request_size_bytes"], float64(20))
+	assert.Equal(t, counts["http_response_size_bytes"], uint64(2))
+	assert.Equal(t, sums["http_response_size_bytes"], float64(40))
+}
+
+func TestMiddleware_UnreadBody(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	router := gin.New()
+	router.Use(Middleware(registry))
+	router.POST("/api/tokens", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader("0123456789"))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	families, err := registry.Gather()
+	assert.NilError(t, err)
+
+	sums := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			sums[family.GetName()] = metric.GetHistogram().GetSampleSum()
+		}
+	}
+	// the Content-Length is used when the handler does not read the body
+	assert.Equal(t, sums["http_request_size_bytes"], float64(10))
+	assert.Equal(t, sums["http_response_size_bytes"], float64(0))
+}
+
+func TestMiddleware_PathLabel(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	router := gin.New()
+	router.Use(Middleware(registry))
+	router.GET("/api/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	requests := []struct {
+		method string
+		host   string
+		path   string
+	}{
+		{method: http.MethodGet, host: "example.com", path: "/api/users/1"},
+		{method: http.MethodGet, host: "example.com", path: "/api/users/2"},
+		{method: http.MethodGet, host: "example.com", path: "/not/found/1"},
+		{method: "PURGE", host: "one.example.com", path: "/not/found/2"},
+		{method: "NOTAMETHOD", host: "two.example.com", path: "/api/users/3"},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, nil)
+		req.Host = r.host
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	families, err := registry.Gather()
+	assert.NilError(t, err)
+
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			key := labels["host"] + " " + labels["method"] + " " + labels["path"]
+			counts[key] += metric.GetHistogram().GetSampleCount()
+		}
+	}
+
+	expected := map[string]uint64{
+		"example.com GET /api/users/:id": 2,
+		"unmatched unmatched unmatched":  3,
+	}
+	assert.DeepEqual(t, counts, expected)
+}